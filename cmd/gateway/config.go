@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+// configUsage describes the config subcommands
+const configUsage = `usage: gateway config dump [-config path] [-format yaml|json]
+       gateway config default`
+
+// runConfigCommand runs a config subcommand and returns the exit code
+func runConfigCommand(args []string) int {
+	if len(args) == 1 && args[0] == "default" {
+		// The built-in configuration, a starting point for a config file
+		if _, err := os.Stdout.Write(config.DefaultYAML()); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write configuration: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	if len(args) == 0 || args[0] != "dump" {
+		fmt.Fprintln(os.Stderr, configUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("config dump", flag.ContinueOnError)
+	path := fs.String("config", "", "Path to configuration file")
+	format := fs.String("format", "yaml", "Output format: yaml or json")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	// Load applies defaults, the file and environment overrides in order
+	cfg, err := config.Load(*path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	data, err := cfg.Export(*format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to export configuration: %v\n", err)
+		return 1
+	}
+
+	if _, err := os.Stdout.Write(data); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write configuration: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/maltehedderich/api-gateway-go/internal/config"
 	"github.com/maltehedderich/api-gateway-go/internal/health"
+	"github.com/maltehedderich/api-gateway-go/internal/lifecycle"
 	"github.com/maltehedderich/api-gateway-go/internal/logger"
 	"github.com/maltehedderich/api-gateway-go/internal/metrics"
 	"github.com/maltehedderich/api-gateway-go/internal/server"
@@ -21,6 +25,11 @@ var (
 )
 
 func main() {
+	// Subcommands are handled before the server flags are parsed
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(os.Args[2:]))
+	}
+
 	flag.Parse()
 
 	// Print version info
@@ -67,10 +76,17 @@ func main() {
 	// Get logger
 	log := logger.Get().WithComponent("main")
 	log.Info("starting API gateway", logger.Fields{
-		"version":    version,
-		"git_commit": gitCommit,
-		"build_time": buildTime,
+		"version":     version,
+		"git_commit":  gitCommit,
+		"build_time":  buildTime,
+		"environment": cfg.Environment,
 	})
+	if unsafe := cfg.UnsafeAcknowledged(); len(unsafe) > 0 {
+		log.Warn("starting with acknowledged unsafe settings", logger.Fields{
+			"environment": cfg.Environment,
+			"interlocks":  unsafe,
+		})
+	}
 
 	// Set up sanitize patterns if configured
 	if len(cfg.Logging.SanitizePatterns) > 0 {
@@ -135,26 +151,78 @@ func main() {
 		return config.Get() != nil
 	}))
 
-	// Create and start server
-	srv := server.New(cfg, healthMgr)
+	// Create server, initializing its subsystems in dependency order
+	srv, err := server.New(cfg, healthMgr)
+	if err != nil {
+		exitOnStartupError(log, err)
+	}
+	srv.SetVersion(version)
 
 	log.Info("configuration loaded successfully", logger.Fields{
-		"http_port":  cfg.Server.HTTPPort,
-		"https_port": cfg.Server.HTTPSPort,
+		"http_port":   cfg.Server.HTTPPort,
+		"https_port":  cfg.Server.HTTPSPort,
 		"tls_enabled": cfg.Server.TLSEnabled,
 	})
 
+	// SIGHUP reloads the routes from the configuration file
+	if *configFile != "" {
+		go reloadRoutesOnHangup(srv, *configFile, log)
+	}
+
 	// Start server (blocks until shutdown)
 	if err := srv.Start(); err != nil {
-		log.Error("server error", logger.Fields{
-			"error": err.Error(),
-		})
-		os.Exit(1)
+		exitOnStartupError(log, err)
 	}
 
 	log.Info("API gateway stopped")
 }
 
+// reloadRoutesOnHangup reloads the routes from the configuration file on
+// every SIGHUP. Only routes are reloaded; other settings need a restart. A
+// file that fails to load or validate leaves the current routes in place.
+func reloadRoutesOnHangup(srv *server.Server, path string, log *logger.ComponentLogger) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	for range hangup {
+		cfg, err := config.Load(path)
+		if err == nil {
+			err = srv.ReloadRoutes(cfg.Routes)
+		}
+		if err != nil {
+			log.Error("failed to reload routes, keeping the current ones", logger.Fields{
+				"config_file": path,
+				"error":       err.Error(),
+			})
+			continue
+		}
+		log.Info("routes reloaded", logger.Fields{
+			"config_file": path,
+			"routes":      len(cfg.Routes),
+		})
+	}
+}
+
+// exitOnStartupError reports err and exits. Required subsystems that failed
+// to start exit with lifecycle.ExitStartupFailure and print the startup
+// report; other errors exit with 1.
+func exitOnStartupError(log *logger.ComponentLogger, err error) {
+	var startupErr *lifecycle.StartupError
+	if errors.As(err, &startupErr) {
+		log.Error("gateway startup failed", logger.Fields{
+			"subsystem": startupErr.Subsystem,
+			"error":     startupErr.Err,
+		})
+		fmt.Fprintf(os.Stderr, "Startup failed:\n%s", startupErr.Report)
+		os.Exit(lifecycle.ExitStartupFailure)
+	}
+
+	log.Error("server error", logger.Fields{
+		"error": err.Error(),
+	})
+	os.Exit(1)
+}
+
 // getEnvironment determines the deployment environment
 func getEnvironment(cfg *config.Config) string {
 	// Try to determine environment from configuration or environment variables
@@ -168,4 +236,3 @@ func getEnvironment(cfg *config.Config) string {
 	// Default to development
 	return "development"
 }
-
@@ -0,0 +1,510 @@
+// Command gatewayctl manages a running gateway through its admin API.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// usage describes the available commands
+const usage = `usage: gatewayctl [-addr url] [-token token] <command> [args]
+
+commands:
+  compat                              show the gateway version and supported config schema versions
+  routes list                         list routes in matching order
+  routes test <method> <path>         show the route a request would match
+  breakers list                       list circuit breakers
+  breakers reset [name]               close one circuit breaker, or all
+  breakers history [name]             show recent state changes of one circuit breaker, or all
+  log-level get                       show log levels
+  log-level set <level> [component]   change the global or a component log level
+  drain                               fail readiness so load balancers stop routing here
+  undrain                             make the instance ready again
+  bans list                           list banned clients
+  bans add [-duration d] [-reason r] <ip|cidr>
+  bans remove <ip|cidr>
+  api-keys list                       list API keys
+  api-keys create [-role r] [-permission p] [-tier t] [-expires rfc3339] <id>
+  api-keys rotate <id>                issue a new key; the old one stays valid for the grace period
+  api-keys revoke <id>                reject the key immediately
+  auth invalidate [user-id]           drop cached authorization decisions of a user, or all
+  cache purge [-key k] [-route r] [-pattern glob]
+                                      drop cached responses by surrogate key, route and path, here and on the CDN
+  quotas get [-tier t] <subject>      show the quota of key:<id> or user:<id> this period
+  quotas set [-tier t] <subject> <used>
+  quotas grant [-tier t] <subject> <requests>
+                                      allow extra requests for the rest of the period
+  rate-limits list [prefix]           show rate limit keys, e.g. ratelimit:user:alice
+  rate-limits reset <key>             drop the state of a key so its limits start over
+  rate-limits block <key> <duration>  reject requests of a key on this instance
+  rate-limits unblock <key>
+
+The address and token default to $GATEWAYCTL_ADDR and $GATEWAYCTL_TOKEN.`
+
+// client calls the admin API
+type client struct {
+	addr  string
+	token string
+	http  *http.Client
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout))
+}
+
+// run executes a command and returns the exit code
+func run(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("gatewayctl", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, usage) }
+	addr := fs.String("addr", envOr("GATEWAYCTL_ADDR", "http://127.0.0.1:9901"), "Admin API address")
+	token := fs.String("token", os.Getenv("GATEWAYCTL_TOKEN"), "Admin API token")
+	timeout := fs.Duration("timeout", 10*time.Second, "Request timeout")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	c := &client{
+		addr:  strings.TrimSuffix(*addr, "/"),
+		token: *token,
+		http:  &http.Client{Timeout: *timeout},
+	}
+
+	err := c.dispatch(fs.Args(), out)
+	if err == errUsage {
+		fs.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gatewayctl: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// errUsage reports an unknown command or missing arguments
+var errUsage = fmt.Errorf("invalid usage")
+
+// dispatch runs the command in args
+func (c *client) dispatch(args []string, out io.Writer) error {
+	command, sub := args[0], ""
+	if len(args) > 1 {
+		sub = args[1]
+	}
+
+	switch {
+	case command == "compat" && len(args) == 1:
+		return c.printJSON(out, http.MethodGet, "/admin/compat", nil)
+	case command == "routes" && sub == "list":
+		return c.listRoutes(out)
+	case command == "routes" && sub == "test" && len(args) == 4:
+		query := url.Values{"method": {args[2]}, "path": {args[3]}}
+		return c.printJSON(out, http.MethodGet, "/admin/routes/test?"+query.Encode(), nil)
+	case command == "breakers" && sub == "list":
+		return c.listBreakers(out)
+	case command == "breakers" && sub == "reset" && len(args) <= 3:
+		path := "/admin/circuit-breakers/reset"
+		if len(args) == 3 {
+			path += "?" + url.Values{"name": {args[2]}}.Encode()
+		}
+		return c.do(http.MethodPost, path, nil, nil)
+	case command == "breakers" && sub == "history" && len(args) <= 3:
+		name := ""
+		if len(args) == 3 {
+			name = args[2]
+		}
+		return c.breakerHistory(out, name)
+	case command == "log-level" && (sub == "" || sub == "get"):
+		return c.printJSON(out, http.MethodGet, "/admin/log-level", nil)
+	case command == "log-level" && sub == "set" && (len(args) == 3 || len(args) == 4):
+		body := map[string]string{"level": args[2]}
+		if len(args) == 4 {
+			body["component"] = args[3]
+		}
+		return c.printJSON(out, http.MethodPut, "/admin/log-level", body)
+	case command == "drain" && len(args) == 1:
+		return c.do(http.MethodPost, "/admin/drain", nil, nil)
+	case command == "undrain" && len(args) == 1:
+		return c.do(http.MethodDelete, "/admin/drain", nil, nil)
+	case command == "bans" && sub == "list":
+		return c.listBans(out)
+	case command == "bans" && sub == "add":
+		return c.addBan(args[2:])
+	case command == "bans" && sub == "remove" && len(args) == 3:
+		return c.do(http.MethodDelete, "/admin/bans?"+url.Values{"target": {args[2]}}.Encode(), nil, nil)
+	case command == "api-keys" && sub == "list":
+		return c.listAPIKeys(out)
+	case command == "api-keys" && sub == "create":
+		return c.createAPIKey(args[2:], out)
+	case command == "api-keys" && sub == "rotate" && len(args) == 3:
+		return c.printJSON(out, http.MethodPost, "/admin/api-keys/"+url.PathEscape(args[2])+"/rotate", nil)
+	case command == "api-keys" && sub == "revoke" && len(args) == 3:
+		return c.do(http.MethodPost, "/admin/api-keys/"+url.PathEscape(args[2])+"/revoke", nil, nil)
+	case command == "auth" && sub == "invalidate" && len(args) <= 3:
+		path := "/admin/auth/decisions"
+		if len(args) == 3 {
+			path += "?" + url.Values{"user_id": {args[2]}}.Encode()
+		}
+		return c.printJSON(out, http.MethodDelete, path, nil)
+	case command == "cache" && sub == "purge":
+		return c.purgeCache(args[2:], out)
+	case command == "quotas" && (sub == "get" || sub == "set" || sub == "grant"):
+		return c.quota(sub, args[2:], out)
+	case command == "rate-limits" && sub == "list" && len(args) <= 3:
+		prefix := ""
+		if len(args) == 3 {
+			prefix = args[2]
+		}
+		return c.listRateLimits(prefix, out)
+	case command == "rate-limits" && sub == "reset" && len(args) == 3:
+		return c.do(http.MethodDelete, "/admin/rate-limits?"+url.Values{"key": {args[2]}}.Encode(), nil, nil)
+	case command == "rate-limits" && sub == "block" && len(args) == 4:
+		return c.do(http.MethodPost, "/admin/rate-limits/blocks", map[string]string{"key": args[2], "duration": args[3]}, nil)
+	case command == "rate-limits" && sub == "unblock" && len(args) == 3:
+		return c.do(http.MethodDelete, "/admin/rate-limits/blocks?"+url.Values{"key": {args[2]}}.Encode(), nil, nil)
+	}
+	return errUsage
+}
+
+func (c *client) listRoutes(out io.Writer) error {
+	var routes []struct {
+		PathPattern string   `json:"path_pattern"`
+		Methods     []string `json:"methods"`
+		BackendURL  string   `json:"backend_url"`
+		AuthPolicy  string   `json:"auth_policy"`
+	}
+	if err := c.do(http.MethodGet, "/admin/routes", nil, &routes); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tMETHODS\tBACKEND\tAUTH")
+	for _, route := range routes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", route.PathPattern, strings.Join(route.Methods, ","), route.BackendURL, route.AuthPolicy)
+	}
+	return tw.Flush()
+}
+
+func (c *client) listBreakers(out io.Writer) error {
+	var breakers []struct {
+		Name      string `json:"name"`
+		State     string `json:"state"`
+		Failures  int    `json:"failures"`
+		Successes int    `json:"successes"`
+	}
+	if err := c.do(http.MethodGet, "/admin/circuit-breakers", nil, &breakers); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSTATE\tFAILURES\tSUCCESSES")
+	for _, breaker := range breakers {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\n", breaker.Name, breaker.State, breaker.Failures, breaker.Successes)
+	}
+	return tw.Flush()
+}
+
+func (c *client) breakerHistory(out io.Writer, name string) error {
+	var events []struct {
+		Name     string    `json:"name"`
+		From     string    `json:"from"`
+		To       string    `json:"to"`
+		Reason   string    `json:"reason"`
+		Failures int       `json:"failures"`
+		Time     time.Time `json:"time"`
+	}
+	path := "/admin/circuit-breakers/history"
+	if name != "" {
+		path += "?" + url.Values{"name": {name}}.Encode()
+	}
+	if err := c.do(http.MethodGet, path, nil, &events); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tNAME\tTRANSITION\tFAILURES\tREASON")
+	for _, event := range events {
+		fmt.Fprintf(tw, "%s\t%s\t%s -> %s\t%d\t%s\n", event.Time.Local().Format(time.RFC3339), event.Name, event.From, event.To, event.Failures, event.Reason)
+	}
+	return tw.Flush()
+}
+
+func (c *client) listBans(out io.Writer) error {
+	var bans []struct {
+		Target    string    `json:"target"`
+		Reason    string    `json:"reason"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := c.do(http.MethodGet, "/admin/bans", nil, &bans); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tEXPIRES\tREASON")
+	for _, ban := range bans {
+		expires := "never"
+		if !ban.ExpiresAt.IsZero() {
+			expires = ban.ExpiresAt.Local().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", ban.Target, expires, ban.Reason)
+	}
+	return tw.Flush()
+}
+
+func (c *client) addBan(args []string) error {
+	fs := flag.NewFlagSet("bans add", flag.ContinueOnError)
+	duration := fs.String("duration", "", "Ban duration, e.g. 1h (default permanent)")
+	reason := fs.String("reason", "", "Reason recorded with the ban")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+
+	body := map[string]string{
+		"target":   fs.Arg(0),
+		"duration": *duration,
+		"reason":   *reason,
+	}
+	return c.do(http.MethodPost, "/admin/bans", body, nil)
+}
+
+func (c *client) listAPIKeys(out io.Writer) error {
+	var keys []struct {
+		ID            string    `json:"id"`
+		Roles         []string  `json:"roles"`
+		Tier          string    `json:"tier"`
+		ExpiresAt     time.Time `json:"expires_at"`
+		Revoked       bool      `json:"revoked"`
+		ActiveSecrets int       `json:"active_secrets"`
+	}
+	if err := c.do(http.MethodGet, "/admin/api-keys", nil, &keys); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tROLES\tTIER\tEXPIRES\tSTATUS")
+	for _, key := range keys {
+		expires := "never"
+		if !key.ExpiresAt.IsZero() {
+			expires = key.ExpiresAt.Local().Format(time.RFC3339)
+		}
+		status := fmt.Sprintf("active (%d keys)", key.ActiveSecrets)
+		if key.Revoked {
+			status = "revoked"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", key.ID, strings.Join(key.Roles, ","), key.Tier, expires, status)
+	}
+	return tw.Flush()
+}
+
+func (c *client) createAPIKey(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("api-keys create", flag.ContinueOnError)
+	var roles, permissions stringList
+	fs.Var(&roles, "role", "Role granted to the key (repeatable)")
+	fs.Var(&permissions, "permission", "Permission granted to the key (repeatable)")
+	tier := fs.String("tier", "", "Rate limit tier")
+	expires := fs.String("expires", "", "Expiry time in RFC 3339 format (default never)")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+
+	body := map[string]interface{}{
+		"id":          fs.Arg(0),
+		"roles":       roles,
+		"permissions": permissions,
+		"tier":        *tier,
+	}
+	if *expires != "" {
+		expiresAt, err := time.Parse(time.RFC3339, *expires)
+		if err != nil {
+			return fmt.Errorf("invalid expiry: %w", err)
+		}
+		body["expires_at"] = expiresAt
+	}
+	return c.printJSON(out, http.MethodPost, "/admin/api-keys", body)
+}
+
+// quota shows or adjusts the quota of a subject
+func (c *client) purgeCache(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("cache purge", flag.ContinueOnError)
+	key := fs.String("key", "", "Surrogate key the backend tagged responses with")
+	route := fs.String("route", "", "Path pattern of the route that cached the responses")
+	pattern := fs.String("pattern", "", "Glob the request path must match, e.g. /products/*")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 || *key+*route+*pattern == "" {
+		return errUsage
+	}
+
+	query := url.Values{}
+	for name, value := range map[string]string{"key": *key, "route": *route, "pattern": *pattern} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	return c.printJSON(out, http.MethodDelete, "/admin/cache?"+query.Encode(), nil)
+}
+
+func (c *client) quota(action string, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("quotas "+action, flag.ContinueOnError)
+	tier := fs.String("tier", "", "Rate limit tier of the subject")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	path := "/admin/quotas/" + url.PathEscape(fs.Arg(0))
+
+	if action == "get" {
+		if fs.NArg() != 1 {
+			return errUsage
+		}
+		if *tier != "" {
+			path += "?" + url.Values{"tier": {*tier}}.Encode()
+		}
+		return c.printJSON(out, http.MethodGet, path, nil)
+	}
+
+	if fs.NArg() != 2 {
+		return errUsage
+	}
+	n, err := strconv.ParseInt(fs.Arg(1), 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid request count: %q", fs.Arg(1))
+	}
+	body := map[string]interface{}{"tier": *tier}
+	if action == "set" {
+		body["used"] = n
+	} else {
+		body["add"] = -n
+	}
+	return c.printJSON(out, http.MethodPatch, path, body)
+}
+
+func (c *client) listRateLimits(prefix string, out io.Writer) error {
+	path := "/admin/rate-limits"
+	if prefix != "" {
+		path += "?" + url.Values{"prefix": {prefix}}.Encode()
+	}
+	var keys []struct {
+		Key          string    `json:"key"`
+		Algorithm    string    `json:"algorithm"`
+		Tokens       *float64  `json:"tokens"`
+		Requests     int       `json:"requests"`
+		ResetAt      time.Time `json:"reset_at"`
+		BlockedUntil time.Time `json:"blocked_until"`
+	}
+	if err := c.do(http.MethodGet, path, nil, &keys); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tALGORITHM\tSTATE\tRESET\tBLOCKED UNTIL")
+	for _, key := range keys {
+		state := "-"
+		if key.Tokens != nil {
+			state = fmt.Sprintf("%.1f tokens", *key.Tokens)
+		} else if key.Algorithm == "sliding_window" {
+			state = fmt.Sprintf("%d requests", key.Requests)
+		}
+		reset, blocked := "-", "-"
+		if !key.ResetAt.IsZero() {
+			reset = key.ResetAt.Local().Format(time.RFC3339)
+		}
+		if !key.BlockedUntil.IsZero() {
+			blocked = key.BlockedUntil.Local().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", key.Key, key.Algorithm, state, reset, blocked)
+	}
+	return tw.Flush()
+}
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// printJSON performs a request and prints the indented response
+func (c *client) printJSON(out io.Writer, method, path string, body interface{}) error {
+	var result json.RawMessage
+	if err := c.do(method, path, body, &result); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, result, "", "  "); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err := buf.WriteTo(out)
+	return err
+}
+
+// do performs an admin API request, encoding body and decoding the response
+// into result if they are non-nil
+func (c *client) do(method, path string, body, result interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.addr+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("admin API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Message != "" {
+			return fmt.Errorf("%s (status %d)", apiErr.Message, resp.StatusCode)
+		}
+		return fmt.Errorf("admin API returned status %d", resp.StatusCode)
+	}
+
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// envOr returns the environment variable key, or fallback if it is unset
+func envOr(key, fallback string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return fallback
+}
@@ -0,0 +1,397 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+)
+
+// RateLimitTierAttribute is the user attribute holding the rate limit tier
+// of an API key
+const RateLimitTierAttribute = "rate_limit_tier"
+
+// apiKeyPrefix marks keys generated by the gateway
+const apiKeyPrefix = "gk_"
+
+var (
+	// ErrAPIKeyNotFound is returned by stores for unknown keys
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	// ErrAPIKeyExists is returned when creating a key whose ID is taken
+	ErrAPIKeyExists = errors.New("api key already exists")
+	// ErrAPIKeyRevoked is returned when rotating a revoked key
+	ErrAPIKeyRevoked = errors.New("api key is revoked")
+)
+
+// APIKey is a stored API key. Only hashes of the key material are kept; a
+// rotated key has several secrets until the previous ones expire.
+type APIKey struct {
+	ID          string         `json:"id"`
+	Roles       []string       `json:"roles,omitempty"`
+	Permissions []string       `json:"permissions,omitempty"`
+	Tier        string         `json:"tier,omitempty"`
+	ExpiresAt   time.Time      `json:"expires_at,omitzero"`
+	Revoked     bool           `json:"revoked,omitempty"`
+	CreatedAt   time.Time      `json:"created_at,omitzero"`
+	Secrets     []APIKeySecret `json:"secrets"`
+}
+
+// APIKeySecret is the hash of one valid key of an API key
+type APIKeySecret struct {
+	Hash      string    `json:"hash"` // hex SHA-256
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+// clone returns a deep copy so stores never share records with callers
+func (k *APIKey) clone() *APIKey {
+	out := *k
+	out.Roles = slices.Clone(k.Roles)
+	out.Permissions = slices.Clone(k.Permissions)
+	out.Secrets = slices.Clone(k.Secrets)
+	return &out
+}
+
+// ActiveSecrets returns the number of keys currently accepted
+func (k *APIKey) ActiveSecrets(now time.Time) int {
+	n := 0
+	for _, secret := range k.Secrets {
+		if secret.ExpiresAt.IsZero() || now.Before(secret.ExpiresAt) {
+			n++
+		}
+	}
+	return n
+}
+
+// APIKeyStore persists API keys
+type APIKeyStore interface {
+	// Get returns the key with the given ID, or ErrAPIKeyNotFound
+	Get(ctx context.Context, id string) (*APIKey, error)
+	// FindByHash returns the key owning a secret hash, or ErrAPIKeyNotFound
+	FindByHash(ctx context.Context, hash string) (*APIKey, error)
+	// List returns all keys
+	List(ctx context.Context) ([]*APIKey, error)
+	// Save creates or replaces a key and its secret hashes
+	Save(ctx context.Context, key *APIKey) error
+}
+
+// APIKeyStoreFactory creates an APIKeyStore from configuration
+type APIKeyStoreFactory func(cfg *config.APIKeyConfig) (APIKeyStore, error)
+
+var (
+	apiKeyStores = map[string]APIKeyStoreFactory{
+		"config": newConfigAPIKeyStore,
+		"redis":  newRedisAPIKeyStore,
+	}
+	apiKeyStoresMu sync.RWMutex
+)
+
+// RegisterAPIKeyStore makes an API key store available by name, so stores
+// such as DynamoDB can be plugged in without changing this package
+func RegisterAPIKeyStore(name string, factory APIKeyStoreFactory) {
+	apiKeyStoresMu.Lock()
+	defer apiKeyStoresMu.Unlock()
+
+	apiKeyStores[name] = factory
+}
+
+// APIKeyManager authenticates API keys and manages their lifecycle
+type APIKeyManager struct {
+	config *config.APIKeyConfig
+	store  APIKeyStore
+	logger *logger.ComponentLogger
+	now    func() time.Time
+}
+
+// NewAPIKeyManager creates an API key manager for the configured store
+func NewAPIKeyManager(cfg *config.APIKeyConfig) (*APIKeyManager, error) {
+	apiKeyStoresMu.RLock()
+	factory, ok := apiKeyStores[cfg.Store]
+	apiKeyStoresMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown api key store: %s", cfg.Store)
+	}
+
+	store, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s api key store: %w", cfg.Store, err)
+	}
+
+	return &APIKeyManager{
+		config: cfg,
+		store:  store,
+		logger: logger.Get().WithComponent("auth.apikeys"),
+		now:    time.Now,
+	}, nil
+}
+
+// Header returns the request header carrying API keys
+func (m *APIKeyManager) Header() string {
+	return m.config.Header
+}
+
+// Authenticate looks up a presented key and returns the user context of its
+// owner. Unknown, revoked and expired keys yield a *ValidationError.
+func (m *APIKeyManager) Authenticate(ctx context.Context, presented string) (*UserContext, error) {
+	key, err := m.store.FindByHash(ctx, hashToken(presented))
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, &ValidationError{
+			Code:    "invalid_api_key",
+			Message: "API key is not valid",
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up api key: %w", err)
+	}
+
+	now := m.now()
+	if key.Revoked {
+		return nil, &ValidationError{
+			Code:    "api_key_revoked",
+			Message: "API key has been revoked",
+		}
+	}
+	if !key.ExpiresAt.IsZero() && !now.Before(key.ExpiresAt) {
+		return nil, &ValidationError{
+			Code:    "api_key_expired",
+			Message: "API key has expired",
+		}
+	}
+	hash := hashToken(presented)
+	for _, secret := range key.Secrets {
+		if secret.Hash == hash && !secret.ExpiresAt.IsZero() && !now.Before(secret.ExpiresAt) {
+			return nil, &ValidationError{
+				Code:    "api_key_expired",
+				Message: "API key was rotated and is no longer valid",
+			}
+		}
+	}
+
+	user := &UserContext{
+		UserID:      key.ID,
+		Roles:       key.Roles,
+		Permissions: key.Permissions,
+		Attributes:  map[string]interface{}{"auth_method": "api_key"},
+	}
+	if key.Tier != "" {
+		user.Attributes[RateLimitTierAttribute] = key.Tier
+	}
+	return user, nil
+}
+
+// Create stores a new key and returns it with the plain key, which is not
+// retrievable afterwards
+func (m *APIKeyManager) Create(ctx context.Context, key *APIKey) (string, *APIKey, error) {
+	if key.ID == "" {
+		return "", nil, fmt.Errorf("id is required")
+	}
+	if _, err := m.store.Get(ctx, key.ID); err == nil {
+		return "", nil, fmt.Errorf("%w: %s", ErrAPIKeyExists, key.ID)
+	} else if !errors.Is(err, ErrAPIKeyNotFound) {
+		return "", nil, err
+	}
+
+	plain, err := generateAPIKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	key = key.clone()
+	key.Revoked = false
+	key.CreatedAt = m.now()
+	key.Secrets = []APIKeySecret{{Hash: hashToken(plain)}}
+	if err := m.store.Save(ctx, key); err != nil {
+		return "", nil, fmt.Errorf("failed to save api key: %w", err)
+	}
+
+	m.logger.Info("api key created", logger.Fields{
+		"key_id": key.ID,
+		"tier":   key.Tier,
+	})
+	return plain, key, nil
+}
+
+// Rotate issues a new key for id. The previous keys stay valid for the
+// configured grace period; expired ones are dropped.
+func (m *APIKeyManager) Rotate(ctx context.Context, id string) (string, *APIKey, error) {
+	key, err := m.store.Get(ctx, id)
+	if err != nil {
+		return "", nil, err
+	}
+	if key.Revoked {
+		return "", nil, fmt.Errorf("%w: %s", ErrAPIKeyRevoked, id)
+	}
+
+	plain, err := generateAPIKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := m.now()
+	graceEnd := now.Add(m.config.RotationGracePeriod)
+	secrets := make([]APIKeySecret, 0, len(key.Secrets)+1)
+	for _, secret := range key.Secrets {
+		if !secret.ExpiresAt.IsZero() && !now.Before(secret.ExpiresAt) {
+			continue
+		}
+		if m.config.RotationGracePeriod <= 0 {
+			continue
+		}
+		if secret.ExpiresAt.IsZero() || secret.ExpiresAt.After(graceEnd) {
+			secret.ExpiresAt = graceEnd
+		}
+		secrets = append(secrets, secret)
+	}
+	key.Secrets = append(secrets, APIKeySecret{Hash: hashToken(plain)})
+
+	if err := m.store.Save(ctx, key); err != nil {
+		return "", nil, fmt.Errorf("failed to save api key: %w", err)
+	}
+
+	m.logger.Info("api key rotated", logger.Fields{
+		"key_id":       id,
+		"grace_period": m.config.RotationGracePeriod.String(),
+	})
+	return plain, key, nil
+}
+
+// Revoke rejects all keys of id from now on
+func (m *APIKeyManager) Revoke(ctx context.Context, id string) (*APIKey, error) {
+	key, err := m.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	key.Revoked = true
+	if err := m.store.Save(ctx, key); err != nil {
+		return nil, fmt.Errorf("failed to save api key: %w", err)
+	}
+
+	m.logger.Warn("api key revoked", logger.Fields{
+		"key_id": id,
+	})
+	return key, nil
+}
+
+// List returns all keys sorted by ID
+func (m *APIKeyManager) List(ctx context.Context) ([]*APIKey, error) {
+	keys, err := m.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(keys, func(a, b *APIKey) int {
+		switch {
+		case a.ID < b.ID:
+			return -1
+		case a.ID > b.ID:
+			return 1
+		}
+		return 0
+	})
+	return keys, nil
+}
+
+// hashToken returns the hex SHA-256 of an API key or access token, so they
+// are never kept in plain text
+func hashToken(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateAPIKey returns a random key with 256 bits of entropy
+func generateAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// memoryAPIKeyStore keeps keys in memory, seeded from the configuration
+type memoryAPIKeyStore struct {
+	mu     sync.RWMutex
+	keys   map[string]*APIKey
+	hashes map[string]string // secret hash -> key ID
+}
+
+// newConfigAPIKeyStore creates a store holding the configured keys
+func newConfigAPIKeyStore(cfg *config.APIKeyConfig) (APIKeyStore, error) {
+	store := &memoryAPIKeyStore{
+		keys:   make(map[string]*APIKey, len(cfg.Keys)),
+		hashes: make(map[string]string, len(cfg.Keys)),
+	}
+	for _, def := range cfg.Keys {
+		key := &APIKey{
+			ID:          def.ID,
+			Roles:       def.Roles,
+			Permissions: def.Permissions,
+			Tier:        def.Tier,
+			ExpiresAt:   def.ExpiresAt,
+			Secrets:     []APIKeySecret{{Hash: def.KeySHA256}},
+		}
+		if err := store.Save(context.Background(), key); err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+func (s *memoryAPIKeyStore) Get(_ context.Context, id string) (*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key.clone(), nil
+}
+
+func (s *memoryAPIKeyStore) FindByHash(ctx context.Context, hash string) (*APIKey, error) {
+	s.mu.RLock()
+	id, ok := s.hashes[hash]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	return s.Get(ctx, id)
+}
+
+func (s *memoryAPIKeyStore) List(_ context.Context) ([]*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]*APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key.clone())
+	}
+	return keys, nil
+}
+
+func (s *memoryAPIKeyStore) Save(_ context.Context, key *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, secret := range key.Secrets {
+		if owner, ok := s.hashes[secret.Hash]; ok && owner != key.ID {
+			return fmt.Errorf("api key %q reuses the key of %q", key.ID, owner)
+		}
+	}
+	if old, ok := s.keys[key.ID]; ok {
+		for _, secret := range old.Secrets {
+			delete(s.hashes, secret.Hash)
+		}
+	}
+	for _, secret := range key.Secrets {
+		s.hashes[secret.Hash] = key.ID
+	}
+	s.keys[key.ID] = key.clone()
+	return nil
+}
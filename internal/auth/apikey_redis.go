@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+// redisAPIKeyStore keeps API keys in Redis so all gateway instances share
+// them. Each key is a JSON record under <prefix>key:<id>, each secret hash
+// points to its key under <prefix>hash:<sha256>, and <prefix>ids lists all IDs.
+type redisAPIKeyStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// newRedisAPIKeyStore creates a Redis API key store
+func newRedisAPIKeyStore(cfg *config.APIKeyConfig) (APIKeyStore, error) {
+	if cfg.RedisAddr == "" {
+		return nil, fmt.Errorf("redis_addr is required")
+	}
+	return &redisAPIKeyStore{
+		client: redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddr,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+		}),
+		prefix: cfg.RedisKeyPrefix,
+	}, nil
+}
+
+func (s *redisAPIKeyStore) recordKey(id string) string { return s.prefix + "key:" + id }
+func (s *redisAPIKeyStore) hashKey(hash string) string { return s.prefix + "hash:" + hash }
+func (s *redisAPIKeyStore) idsKey() string             { return s.prefix + "ids" }
+
+func (s *redisAPIKeyStore) Get(ctx context.Context, id string) (*APIKey, error) {
+	data, err := s.client.Get(ctx, s.recordKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var key APIKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("invalid api key record %s: %w", id, err)
+	}
+	return &key, nil
+}
+
+func (s *redisAPIKeyStore) FindByHash(ctx context.Context, hash string) (*APIKey, error) {
+	id, err := s.client.Get(ctx, s.hashKey(hash)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+func (s *redisAPIKeyStore) List(ctx context.Context) ([]*APIKey, error) {
+	ids, err := s.client.SMembers(ctx, s.idsKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*APIKey, 0, len(ids))
+	for _, id := range ids {
+		key, err := s.Get(ctx, id)
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (s *redisAPIKeyStore) Save(ctx context.Context, key *APIKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+
+	old, err := s.Get(ctx, key.ID)
+	if err != nil && !errors.Is(err, ErrAPIKeyNotFound) {
+		return err
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if old != nil {
+			for _, secret := range old.Secrets {
+				pipe.Del(ctx, s.hashKey(secret.Hash))
+			}
+		}
+		for _, secret := range key.Secrets {
+			pipe.Set(ctx, s.hashKey(secret.Hash), key.ID, 0)
+		}
+		pipe.Set(ctx, s.recordKey(key.ID), data, 0)
+		pipe.SAdd(ctx, s.idsKey(), key.ID)
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+func newTestAPIKeyManager(t *testing.T, keys ...config.APIKeyDefinition) *APIKeyManager {
+	t.Helper()
+	manager, err := NewAPIKeyManager(&config.APIKeyConfig{
+		Enabled:             true,
+		Header:              "X-Api-Key",
+		Store:               "config",
+		Keys:                keys,
+		RotationGracePeriod: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	return manager
+}
+
+func validationCode(err error) string {
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		return verr.Code
+	}
+	return ""
+}
+
+func TestAPIKeyManager_ConfigStore(t *testing.T) {
+	manager := newTestAPIKeyManager(t,
+		config.APIKeyDefinition{ID: "reporting", KeySHA256: hashToken("report-key"), Roles: []string{"reader"}, Tier: "partner"},
+		config.APIKeyDefinition{ID: "legacy", KeySHA256: hashToken("legacy-key"), ExpiresAt: time.Now().Add(-time.Minute)},
+	)
+
+	user, err := manager.Authenticate(context.Background(), "report-key")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if user.UserID != "reporting" || !user.HasRole("reader") {
+		t.Errorf("Unexpected user: %+v", user)
+	}
+	if user.Attributes["auth_method"] != "api_key" || user.Attributes[RateLimitTierAttribute] != "partner" {
+		t.Errorf("Unexpected attributes: %v", user.Attributes)
+	}
+
+	if _, err := manager.Authenticate(context.Background(), "wrong-key"); validationCode(err) != "invalid_api_key" {
+		t.Errorf("Expected invalid_api_key, got: %v", err)
+	}
+	if _, err := manager.Authenticate(context.Background(), "legacy-key"); validationCode(err) != "api_key_expired" {
+		t.Errorf("Expected api_key_expired, got: %v", err)
+	}
+}
+
+func TestAPIKeyManager_ConfigStoreRejectsReusedKey(t *testing.T) {
+	_, err := NewAPIKeyManager(&config.APIKeyConfig{
+		Store: "config",
+		Keys: []config.APIKeyDefinition{
+			{ID: "a", KeySHA256: hashToken("same")},
+			{ID: "b", KeySHA256: hashToken("same")},
+		},
+	})
+	if err == nil {
+		t.Error("Expected error for a key shared by two IDs")
+	}
+}
+
+func TestAPIKeyManager_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	manager := newTestAPIKeyManager(t)
+	now := time.Now()
+	manager.now = func() time.Time { return now }
+
+	first, key, err := manager.Create(ctx, &APIKey{ID: "ci", Roles: []string{"deployer"}})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if key.CreatedAt.IsZero() || len(key.Secrets) != 1 {
+		t.Errorf("Unexpected key: %+v", key)
+	}
+	if _, _, err := manager.Create(ctx, &APIKey{ID: "ci"}); !errors.Is(err, ErrAPIKeyExists) {
+		t.Errorf("Expected ErrAPIKeyExists, got: %v", err)
+	}
+
+	second, key, err := manager.Rotate(ctx, "ci")
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	if first == second || key.ActiveSecrets(now) != 2 {
+		t.Errorf("Expected two active keys after rotation, got %d", key.ActiveSecrets(now))
+	}
+
+	// Both keys work during the grace period
+	for _, presented := range []string{first, second} {
+		if _, err := manager.Authenticate(ctx, presented); err != nil {
+			t.Errorf("Expected key to be valid during grace period, got: %v", err)
+		}
+	}
+
+	// Only the new key works afterwards
+	now = now.Add(2 * time.Hour)
+	if _, err := manager.Authenticate(ctx, first); validationCode(err) != "api_key_expired" {
+		t.Errorf("Expected rotated key to expire, got: %v", err)
+	}
+	if _, err := manager.Authenticate(ctx, second); err != nil {
+		t.Errorf("Expected new key to be valid, got: %v", err)
+	}
+
+	// The next rotation drops the expired key
+	_, key, err = manager.Rotate(ctx, "ci")
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	if len(key.Secrets) != 2 {
+		t.Errorf("Expected expired secret to be dropped, got %d secrets", len(key.Secrets))
+	}
+
+	if _, err := manager.Revoke(ctx, "ci"); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if _, err := manager.Authenticate(ctx, second); validationCode(err) != "api_key_revoked" {
+		t.Errorf("Expected api_key_revoked, got: %v", err)
+	}
+	if _, _, err := manager.Rotate(ctx, "ci"); !errors.Is(err, ErrAPIKeyRevoked) {
+		t.Errorf("Expected ErrAPIKeyRevoked, got: %v", err)
+	}
+	if _, err := manager.Revoke(ctx, "unknown"); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("Expected ErrAPIKeyNotFound, got: %v", err)
+	}
+}
+
+func TestAPIKeyManager_UnknownStore(t *testing.T) {
+	if _, err := NewAPIKeyManager(&config.APIKeyConfig{Store: "dynamodb"}); err == nil {
+		t.Error("Expected error for an unregistered store")
+	}
+
+	RegisterAPIKeyStore("test-memory", newConfigAPIKeyStore)
+	if _, err := NewAPIKeyManager(&config.APIKeyConfig{Store: "test-memory"}); err != nil {
+		t.Errorf("Expected registered store to be usable, got: %v", err)
+	}
+}
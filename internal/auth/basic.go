@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// authMethodBasic is the auth_method attribute of users authenticated by
+// HTTP Basic credentials
+const authMethodBasic = "basic"
+
+// dummyHash is compared against when a user is unknown, so unknown and known
+// users take equally long to reject
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// BasicAuthenticator checks HTTP Basic credentials against bcrypt hashes.
+// The htpasswd file is re-read when its modification time changes; a file
+// that fails to reload keeps the previous users.
+type BasicAuthenticator struct {
+	config *config.BasicAuthConfig
+	logger *logger.ComponentLogger
+
+	mu       sync.Mutex
+	htpasswd map[string]string
+	modTime  time.Time
+}
+
+// NewBasicAuthenticator creates an authenticator and loads the htpasswd file
+func NewBasicAuthenticator(cfg *config.BasicAuthConfig) (*BasicAuthenticator, error) {
+	b := &BasicAuthenticator{
+		config: cfg,
+		logger: logger.Get().WithComponent("auth.basic"),
+	}
+	if cfg.HtpasswdFile != "" {
+		if err := b.reload(); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Authenticate returns the user for a user name and password
+func (b *BasicAuthenticator) Authenticate(username, password string) (*UserContext, error) {
+	hash, known, err := b.passwordHash(username)
+	if err != nil {
+		return nil, err
+	}
+	if !known {
+		_ = bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return nil, &ValidationError{Code: "invalid_credentials", Message: "Invalid user name or password"}
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return nil, &ValidationError{Code: "invalid_credentials", Message: "Invalid user name or password"}
+	}
+
+	grants := b.config.Users[username]
+	return &UserContext{
+		UserID:      username,
+		Roles:       grants.Roles,
+		Permissions: grants.Permissions,
+		Attributes:  map[string]interface{}{"auth_method": authMethodBasic},
+	}, nil
+}
+
+// passwordHash returns the bcrypt hash of a user from its configured source
+// or else from the htpasswd file
+func (b *BasicAuthenticator) passwordHash(username string) (string, bool, error) {
+	if user, ok := b.config.Users[username]; ok && user.PasswordHash != (config.SecretRef{}) {
+		hash, err := user.PasswordHash.Resolve()
+		if err != nil {
+			return "", false, fmt.Errorf("password hash of %s: %w", username, err)
+		}
+		return hash, true, nil
+	}
+	if b.config.HtpasswdFile == "" {
+		return "", false, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if info, err := os.Stat(b.config.HtpasswdFile); err == nil && !info.ModTime().Equal(b.modTime) {
+		if err := b.reloadLocked(); err != nil {
+			b.logger.Error("failed to reload htpasswd file, keeping previous users", logger.Fields{
+				"file":  b.config.HtpasswdFile,
+				"error": err.Error(),
+			})
+		}
+	}
+	hash, ok := b.htpasswd[username]
+	return hash, ok, nil
+}
+
+// reload reads the htpasswd file
+func (b *BasicAuthenticator) reload() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.reloadLocked()
+}
+
+func (b *BasicAuthenticator) reloadLocked() error {
+	info, err := os.Stat(b.config.HtpasswdFile)
+	if err != nil {
+		return fmt.Errorf("failed to stat htpasswd file: %w", err)
+	}
+	data, err := os.ReadFile(b.config.HtpasswdFile)
+	if err != nil {
+		return fmt.Errorf("failed to read htpasswd file: %w", err)
+	}
+	users, err := parseHtpasswd(data)
+	if err != nil {
+		return fmt.Errorf("htpasswd file %s: %w", b.config.HtpasswdFile, err)
+	}
+
+	if b.htpasswd != nil {
+		b.logger.Info("htpasswd file reloaded", logger.Fields{
+			"file":  b.config.HtpasswdFile,
+			"users": len(users),
+		})
+	}
+	b.htpasswd = users
+	b.modTime = info.ModTime()
+	return nil
+}
+
+// parseHtpasswd parses user:hash lines, skipping blank lines and comments.
+// Only bcrypt hashes (htpasswd -B) are accepted.
+func parseHtpasswd(data []byte) (map[string]string, error) {
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, hash, found := strings.Cut(text, ":")
+		if !found || name == "" {
+			return nil, fmt.Errorf("line %d: expected user:hash", line)
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("line %d: hash of %s is not a bcrypt hash", line, name)
+		}
+		users[name] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// authenticateBasic checks the Basic credentials of r and returns the user.
+// It writes the error response with a Basic challenge and returns false if
+// the request is rejected.
+func (m *Middleware) authenticateBasic(w http.ResponseWriter, r *http.Request, match *router.Match, policy *Policy, start time.Time) (*UserContext, bool) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		metrics.RecordAuthAttempt("failure")
+		metrics.RecordAuthFailure("missing_credentials")
+		m.logDecision(r, match, policy, nil, false, "", "missing basic credentials", start)
+		m.writeBasicChallenge(w)
+		m.writeError(w, r, http.StatusUnauthorized, "unauthorized", "Basic credentials required", nil)
+		return nil, false
+	}
+
+	validationStart := time.Now()
+	user, err := m.basicAuth.Authenticate(username, password)
+	metrics.RecordAuthValidationDuration(time.Since(validationStart))
+	if err != nil {
+		metrics.RecordAuthAttempt("failure")
+		metrics.RecordAuthFailure("invalid_credentials")
+		m.logDecision(r, match, policy, nil, false, "", err.Error(), start)
+		m.writeBasicChallenge(w)
+		m.handleAuthError(w, r, err, "basic authentication failed")
+		return nil, false
+	}
+
+	// The password is a credential; backends must not see it
+	r.Header.Del("Authorization")
+	return user, true
+}
+
+// writeBasicChallenge asks the client for Basic credentials
+func (m *Middleware) writeBasicChallenge(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, m.config.BasicAuth.Realm))
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/middleware"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+func bcryptHash(t *testing.T, password string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword() error = %v", err)
+	}
+	return string(hash)
+}
+
+func TestBasicAuthenticator_Authenticate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "htpasswd")
+	if err := os.WriteFile(file, []byte("# ops tooling\nbackup:"+bcryptHash(t, "backup-pass")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := NewBasicAuthenticator(&config.BasicAuthConfig{
+		Enabled:      true,
+		HtpasswdFile: file,
+		Users: map[string]config.BasicAuthUser{
+			"backup":     {Roles: []string{"ops"}},
+			"prometheus": {PasswordHash: config.SecretRef{Value: bcryptHash(t, "scrape-pass")}, Roles: []string{"metrics"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewBasicAuthenticator() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		username     string
+		password     string
+		expectedRole string
+	}{
+		{"htpasswd user", "backup", "backup-pass", "ops"},
+		{"configured hash", "prometheus", "scrape-pass", "metrics"},
+		{"wrong password", "backup", "guess", ""},
+		{"unknown user", "nobody", "backup-pass", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := b.Authenticate(tt.username, tt.password)
+			if tt.expectedRole == "" {
+				if valErr, ok := err.(*ValidationError); !ok || valErr.Code != "invalid_credentials" {
+					t.Errorf("Authenticate() error = %v, expected invalid_credentials", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+			if user.UserID != tt.username || len(user.Roles) != 1 || user.Roles[0] != tt.expectedRole {
+				t.Errorf("unexpected user: %+v", user)
+			}
+			if user.Attributes["auth_method"] != authMethodBasic {
+				t.Errorf("expected auth_method %s, got %v", authMethodBasic, user.Attributes["auth_method"])
+			}
+		})
+	}
+
+	t.Run("reloads changed file", func(t *testing.T) {
+		if err := os.WriteFile(file, []byte("backup:"+bcryptHash(t, "rotated-pass")+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		later := time.Now().Add(time.Minute)
+		if err := os.Chtimes(file, later, later); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.Authenticate("backup", "rotated-pass"); err != nil {
+			t.Errorf("expected the rotated password to be accepted, got %v", err)
+		}
+		if _, err := b.Authenticate("backup", "backup-pass"); err == nil {
+			t.Error("expected the old password to be rejected")
+		}
+	})
+}
+
+func TestParseHtpasswd(t *testing.T) {
+	hash := "$2y$05$" + strings.Repeat("a", 53)
+
+	tests := []struct {
+		name        string
+		data        string
+		expectError bool
+	}{
+		{"bcrypt entries", "alice:" + hash + "\n\n# comment\nbob:" + hash, false},
+		{"missing separator", "alice", true},
+		{"apr1 hash", "alice:$apr1$salt$hash", true},
+		{"plain password", "alice:secret", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseHtpasswd([]byte(tt.data))
+			if (err != nil) != tt.expectError {
+				t.Errorf("parseHtpasswd() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestMiddleware_BasicRoute(t *testing.T) {
+	m, err := NewMiddleware(&config.AuthorizationConfig{
+		Enabled:             true,
+		CookieName:          "session_token",
+		JWTSigningAlgorithm: "HS256",
+		JWTSharedSecret:     "default-secret-key-for-hmac-tests",
+		BasicAuth: config.BasicAuthConfig{
+			Enabled: true,
+			Realm:   "ops",
+			Users: map[string]config.BasicAuthUser{
+				"prometheus": {PasswordHash: config.SecretRef{Value: bcryptHash(t, "scrape-pass")}, Roles: []string{"metrics"}},
+				"intern":     {PasswordHash: config.SecretRef{Value: bcryptHash(t, "intern-pass")}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create middleware: %v", err)
+	}
+	var forwarded *http.Request
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	route := &router.Route{PathPattern: "/internal/metrics", AuthPolicy: "basic", RequiredRoles: []string{"metrics"}}
+	serve := func(username, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/internal/metrics", nil)
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+		req = req.WithContext(middleware.WithRouteMatch(req.Context(), &router.Match{Route: route}))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve("prometheus", "scrape-pass"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if forwarded.Header.Get("Authorization") != "" {
+		t.Error("expected the credentials to be removed before forwarding")
+	}
+
+	rec := serve("", "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without credentials, got %d", rec.Code)
+	}
+	if challenge := rec.Header().Get("WWW-Authenticate"); !strings.HasPrefix(challenge, `Basic realm="ops"`) {
+		t.Errorf("expected a Basic challenge, got %q", challenge)
+	}
+	if rec := serve("prometheus", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for a wrong password, got %d", rec.Code)
+	}
+	if rec := serve("intern", "intern-pass"); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for a user without the role, got %d", rec.Code)
+	}
+}
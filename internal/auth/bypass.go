@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/clientip"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// authMethodBypass is the auth_method attribute of requests admitted by the
+// trusted-source bypass
+const authMethodBypass = "bypass"
+
+// Sources of bypassed requests
+const (
+	bypassSourceNetwork = "network"
+	bypassSourceService = "service"
+)
+
+// Bypass admits requests from trusted sources without credentials: clients
+// in trusted networks and service accounts presenting a pre-shared secret
+type Bypass struct {
+	config   *config.AuthBypassConfig
+	logger   *logger.ComponentLogger
+	networks []*net.IPNet
+	policies map[PolicyType]bool
+	services []string
+}
+
+// NewBypass creates the bypass for the trusted sources of cfg
+func NewBypass(cfg *config.AuthBypassConfig) (*Bypass, error) {
+	networks, err := config.ParseNetworks(cfg.Networks)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bypass networks: %w", err)
+	}
+
+	policies := make(map[PolicyType]bool, len(cfg.Policies))
+	for _, policy := range cfg.Policies {
+		policies[PolicyType(policy)] = true
+	}
+
+	// Secrets are compared in a fixed order so timing does not depend on
+	// map iteration
+	services := make([]string, 0, len(cfg.Services))
+	for name := range cfg.Services {
+		services = append(services, name)
+	}
+	sort.Strings(services)
+
+	return &Bypass{
+		config:   cfg,
+		logger:   logger.Get().WithComponent("auth.bypass"),
+		networks: networks,
+		policies: policies,
+		services: services,
+	}, nil
+}
+
+// Header returns the header carrying service account secrets
+func (b *Bypass) Header() string {
+	return b.config.Header
+}
+
+// Applies reports whether routes with the policy may be bypassed
+func (b *Bypass) Applies(policy PolicyType) bool {
+	return b.policies[policy]
+}
+
+// Service returns the service account whose secret r carries, or "" if the
+// header is missing or matches no secret
+func (b *Bypass) Service(r *http.Request) string {
+	presented := r.Header.Get(b.config.Header)
+	if presented == "" {
+		return ""
+	}
+
+	matched := ""
+	for _, name := range b.services {
+		secret, err := b.config.Services[name].Resolve()
+		if err != nil {
+			b.logger.Error("failed to resolve bypass secret", logger.Fields{
+				"service": name,
+				"error":   err.Error(),
+			})
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(secret)) == 1 && matched == "" {
+			matched = name
+		}
+	}
+	if matched == "" {
+		b.logger.Warn("invalid bypass secret presented", logger.Fields{
+			"client_ip": clientip.FromRequest(r),
+			"path":      r.URL.Path,
+		})
+	}
+	return matched
+}
+
+// Network returns the trusted network containing the client IP of r, or ""
+func (b *Bypass) Network(r *http.Request) string {
+	ip := clientip.ParseAddr(clientip.FromRequest(r))
+	if ip == nil {
+		return ""
+	}
+	for _, network := range b.networks {
+		if network.Contains(ip) {
+			return network.String()
+		}
+	}
+	return ""
+}
+
+// bypassUser returns the user for a request admitted by the bypass
+func bypassUser(source, name string) *UserContext {
+	return &UserContext{
+		UserID: authMethodBypass + ":" + name,
+		Attributes: map[string]interface{}{
+			"auth_method":   authMethodBypass,
+			"bypass_source": source,
+		},
+	}
+}
+
+// authenticateBypass admits r if it comes from a trusted source and returns
+// the bypass user, or nil if r has to authenticate as usual. Trusted
+// networks apply only to requests without credentials, so callers that
+// present a token keep their identity.
+func (m *Middleware) authenticateBypass(r *http.Request, match *router.Match, policy *Policy, start time.Time) *UserContext {
+	// Services on the internal listener already proved their identity
+	if !m.bypass.Applies(policy.Type) || GetServiceIdentity(r.Context()) != "" {
+		return nil
+	}
+
+	source, name := "", ""
+	if service := m.bypass.Service(r); service != "" {
+		source, name = bypassSourceService, service
+	} else if !m.presentsCredentials(r, match) {
+		if network := m.bypass.Network(r); network != "" {
+			source, name = bypassSourceNetwork, network
+		}
+	}
+	if source == "" {
+		return nil
+	}
+
+	user := bypassUser(source, name)
+	m.logger.Info("authorization bypassed for trusted source", logger.Fields{
+		"audit":       true,
+		"source":      source,
+		"trusted_as":  name,
+		"client_ip":   clientip.FromRequest(r),
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"route":       match.Route.PathPattern,
+		"policy_type": policy.Type,
+	})
+	metrics.RecordAuthAttempt("bypass")
+	metrics.RecordAuthBypass(source, match.Route.PathPattern)
+	m.logDecision(r, match, policy, user, true, "bypass: "+source, "trusted "+source+" "+name, start)
+	return user
+}
+
+// presentsCredentials reports whether r carries a session token, API key or
+// Authorization header
+func (m *Middleware) presentsCredentials(r *http.Request, match *router.Match) bool {
+	if r.Header.Get("Authorization") != "" {
+		return true
+	}
+	if m.apiKeys != nil && r.Header.Get(m.apiKeys.Header()) != "" {
+		return true
+	}
+	_, err := m.extractor.ExtractToken(r, match.Route)
+	return err == nil
+}
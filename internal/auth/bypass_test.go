@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/middleware"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+func TestMiddleware_Bypass(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+	m, err := NewMiddleware(&config.AuthorizationConfig{
+		Enabled:             true,
+		CookieName:          "session_token",
+		JWTSigningAlgorithm: "HS256",
+		JWTSharedSecret:     "default-secret-key-for-hmac-tests",
+		Bypass: config.AuthBypassConfig{
+			Enabled:  true,
+			Networks: []string{"10.0.0.0/8"},
+			Header:   "X-Gateway-Service-Token",
+			Services: map[string]config.SecretRef{"nightly-export": {Value: "export-secret-0123456789"}},
+			Policies: []string{"authenticated"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create middleware: %v", err)
+	}
+	var user, forwardedSecret string
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if userCtx, ok := GetUserContext(r.Context()); ok {
+			user = userCtx.UserID
+		}
+		forwardedSecret = r.Header.Get("X-Gateway-Service-Token")
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name           string
+		policy         string
+		remoteAddr     string
+		secret         string
+		token          string
+		expectedStatus int
+		expectedUser   string
+	}{
+		{"trusted network", "authenticated", "10.1.2.3:4711", "", "", http.StatusOK, "bypass:10.0.0.0/8"},
+		{"trusted network with credentials", "authenticated", "10.1.2.3:4711", "", "invalid-token", http.StatusUnauthorized, ""},
+		{"untrusted network", "authenticated", "203.0.113.7:4711", "", "", http.StatusUnauthorized, ""},
+		{"service secret", "authenticated", "203.0.113.7:4711", "export-secret-0123456789", "", http.StatusOK, "bypass:nightly-export"},
+		{"wrong service secret", "authenticated", "203.0.113.7:4711", "guessed-secret", "", http.StatusUnauthorized, ""},
+		{"policy not bypassable", "role-based", "10.1.2.3:4711", "export-secret-0123456789", "", http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, forwardedSecret = "", ""
+			route := &router.Route{PathPattern: "/reports", AuthPolicy: tt.policy, RequiredRoles: []string{"reporter"}}
+			req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+			req = req.WithContext(middleware.WithRouteMatch(req.Context(), &router.Match{Route: route}))
+			req.RemoteAddr = tt.remoteAddr
+			if tt.secret != "" {
+				req.Header.Set("X-Gateway-Service-Token", tt.secret)
+			}
+			if tt.token != "" {
+				req.AddCookie(&http.Cookie{Name: "session_token", Value: tt.token})
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, rec.Code, rec.Body.String())
+			}
+			if user != tt.expectedUser {
+				t.Errorf("Expected user %q, got %q", tt.expectedUser, user)
+			}
+			if forwardedSecret != "" {
+				t.Error("bypass secret was forwarded to the backend")
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"crypto/x509"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// authMethodClientCert is the auth_method attribute of users authenticated
+// by a TLS client certificate
+const authMethodClientCert = "client_cert"
+
+// authenticateClientCert returns the user for the verified client
+// certificate of r. It writes the error response and returns false if the
+// client presented none.
+func (m *Middleware) authenticateClientCert(w http.ResponseWriter, r *http.Request, match *router.Match, policy *Policy, start time.Time) (*UserContext, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		metrics.RecordAuthAttempt("failure")
+		metrics.RecordAuthFailure("missing_client_cert")
+		m.logDecision(r, match, policy, nil, false, "", "missing client certificate", start)
+		m.writeError(w, r, http.StatusUnauthorized, "client_certificate_required", "A valid client certificate is required", nil)
+		return nil, false
+	}
+	return UserFromCert(r.TLS.VerifiedChains[0][0], m.config.ClientCert.Mappings), true
+}
+
+// UserFromCert returns the user for a verified client certificate. Its ID is
+// the certificate's service identity, and it is granted the roles and
+// permissions of every mapping the certificate matches.
+func UserFromCert(cert *x509.Certificate, mappings []config.ClientCertMapping) *UserContext {
+	sans := certSANs(cert)
+	user := &UserContext{
+		UserID: ServiceIdentityFromCert(cert),
+		Attributes: map[string]interface{}{
+			"auth_method": authMethodClientCert,
+			"cert_cn":     cert.Subject.CommonName,
+			"cert_ou":     cert.Subject.OrganizationalUnit,
+			"cert_sans":   sans,
+			"cert_serial": cert.SerialNumber.String(),
+		},
+	}
+
+	for _, mapping := range mappings {
+		var values []string
+		switch mapping.Attribute {
+		case "san":
+			values = sans
+		case "ou":
+			values = cert.Subject.OrganizationalUnit
+		case "cn":
+			values = []string{cert.Subject.CommonName}
+		}
+		if matchesAny(mapping.Pattern, values) {
+			user.Roles = appendUnique(user.Roles, mapping.Roles)
+			user.Permissions = appendUnique(user.Permissions, mapping.Permissions)
+		}
+	}
+	return user
+}
+
+// certSANs returns the DNS, URI and email subject alternative names of cert
+func certSANs(cert *x509.Certificate) []string {
+	sans := make([]string, 0, len(cert.DNSNames)+len(cert.URIs)+len(cert.EmailAddresses))
+	sans = append(sans, cert.DNSNames...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	return append(sans, cert.EmailAddresses...)
+}
+
+// matchesAny reports whether any value matches the glob pattern
+func matchesAny(pattern string, values []string) bool {
+	for _, value := range values {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
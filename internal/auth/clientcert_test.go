@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/middleware"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+var testClientCertMappings = []config.ClientCertMapping{
+	{Attribute: "san", Pattern: "spiffe://mesh.example/ns/billing/*", Roles: []string{"billing"}},
+	{Attribute: "ou", Pattern: "payments", Roles: []string{"billing", "payments"}, Permissions: []string{"invoices:write"}},
+	{Attribute: "cn", Pattern: "batch-*", Roles: []string{"batch"}},
+}
+
+func newTestClientCert(cn string, ou []string, uris ...string) *x509.Certificate {
+	cert := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: cn, OrganizationalUnit: ou},
+	}
+	for _, raw := range uris {
+		uri, _ := url.Parse(raw)
+		cert.URIs = append(cert.URIs, uri)
+	}
+	return cert
+}
+
+func TestUserFromCert(t *testing.T) {
+	tests := []struct {
+		name          string
+		cert          *x509.Certificate
+		expectedID    string
+		expectedRoles []string
+	}{
+		{"san", newTestClientCert("invoicer", nil, "spiffe://mesh.example/ns/billing/invoicer"), "spiffe://mesh.example/ns/billing/invoicer", []string{"billing"}},
+		{"ou merges roles", newTestClientCert("invoicer", []string{"payments"}, "spiffe://mesh.example/ns/billing/invoicer"), "spiffe://mesh.example/ns/billing/invoicer", []string{"billing", "payments"}},
+		{"cn", newTestClientCert("batch-nightly", nil), "batch-nightly", []string{"batch"}},
+		{"no mapping", newTestClientCert("reporting", []string{"analytics"}), "reporting", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := UserFromCert(tt.cert, testClientCertMappings)
+			if user.UserID != tt.expectedID {
+				t.Errorf("UserID = %q, expected %q", user.UserID, tt.expectedID)
+			}
+			if !slices.Equal(user.Roles, tt.expectedRoles) {
+				t.Errorf("Roles = %v, expected %v", user.Roles, tt.expectedRoles)
+			}
+			if user.Attributes["auth_method"] != authMethodClientCert {
+				t.Errorf("expected auth method %s, got %v", authMethodClientCert, user.Attributes["auth_method"])
+			}
+		})
+	}
+}
+
+func TestMiddleware_ClientCert(t *testing.T) {
+	m, err := NewMiddleware(&config.AuthorizationConfig{
+		Enabled:             true,
+		CookieName:          "session_token",
+		JWTSigningAlgorithm: "HS256",
+		JWTSharedSecret:     "default-secret-key-for-hmac-tests",
+		ClientCert:          config.ClientCertConfig{Enabled: true, Mappings: testClientCertMappings},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create middleware: %v", err)
+	}
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	billing := newTestClientCert("invoicer", nil, "spiffe://mesh.example/ns/billing/invoicer")
+	tests := []struct {
+		name           string
+		cert           *x509.Certificate
+		roles          []string
+		expectedStatus int
+	}{
+		{"verified certificate", billing, nil, http.StatusOK},
+		{"mapped role", billing, []string{"billing"}, http.StatusOK},
+		{"missing role", billing, []string{"batch"}, http.StatusForbidden},
+		{"no certificate", nil, nil, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := &router.Route{PathPattern: "/invoices", AuthPolicy: "client-cert", RequiredRoles: tt.roles}
+			req := httptest.NewRequest(http.MethodPost, "/invoices", nil)
+			req = req.WithContext(middleware.WithRouteMatch(req.Context(), &router.Match{Route: route}))
+			if tt.cert != nil {
+				req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{tt.cert}}}
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
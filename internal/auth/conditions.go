@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/clientip"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+)
+
+// conditionEnv resolves the attributes available to route conditions:
+//
+//	claims.<name>   any token claim
+//	user.<field>    id, session_id, roles, permissions, scopes
+//	attr.<name>     attributes loaded by claims enrichment
+//	path.<name>     path parameters of the matched route (also params.<name>)
+//	request.<field> method, path, host, client_ip
+//	header.<name>   request header
+//	query.<name>    query parameter
+//	time.<field>    hour, minute, weekday (monday..sunday), clock (HH:MM),
+//	                date (YYYY-MM-DD) and unix, in the configured time zone
+type conditionEnv struct {
+	user   *UserContext
+	req    *http.Request
+	params map[string]string
+	now    time.Time
+}
+
+// Resolve implements expr.Resolver
+func (e *conditionEnv) Resolve(name string) (interface{}, bool) {
+	root, key, _ := strings.Cut(name, ".")
+
+	switch root {
+	case "claims":
+		if e.user == nil || e.user.Claims == nil {
+			return nil, false
+		}
+		return e.user.Claims.Claim(key)
+	case "user":
+		if e.user == nil {
+			return nil, false
+		}
+		switch key {
+		case "id":
+			return nonEmpty(e.user.UserID)
+		case "session_id":
+			return nonEmpty(e.user.SessionID)
+		case "roles":
+			return e.user.Roles, true
+		case "permissions":
+			return e.user.Permissions, true
+		case "scopes":
+			return e.user.Scopes, true
+		}
+	case "attr":
+		if e.user == nil {
+			return nil, false
+		}
+		value, ok := e.user.Attributes[key]
+		return value, ok
+	case "path", "params":
+		value, ok := e.params[key]
+		return value, ok
+	case "request":
+		switch key {
+		case "method":
+			return e.req.Method, true
+		case "path":
+			return e.req.URL.Path, true
+		case "host":
+			return e.req.Host, true
+		case "client_ip":
+			return nonEmpty(clientip.FromRequest(e.req))
+		}
+	case "header":
+		return nonEmpty(e.req.Header.Get(key))
+	case "query":
+		values, ok := e.req.URL.Query()[key]
+		if !ok || len(values) == 0 {
+			return nil, false
+		}
+		return values[0], true
+	case "time":
+		switch key {
+		case "hour":
+			return float64(e.now.Hour()), true
+		case "minute":
+			return float64(e.now.Minute()), true
+		case "weekday":
+			return strings.ToLower(e.now.Weekday().String()), true
+		case "clock":
+			return e.now.Format("15:04"), true
+		case "date":
+			return e.now.Format("2006-01-02"), true
+		case "unix":
+			return float64(e.now.Unix()), true
+		}
+	}
+	return nil, false
+}
+
+// nonEmpty treats empty strings as unresolved
+func nonEmpty(value string) (interface{}, bool) {
+	return value, value != ""
+}
+
+// EvaluateConditions checks the attribute-based conditions of a policy for a
+// request. Decisions depend on the request, so they are never cached.
+func (pe *PolicyEvaluator) EvaluateConditions(policy *Policy, user *UserContext, r *http.Request, params map[string]string) *Decision {
+	env := &conditionEnv{user: user, req: r, params: params, now: pe.now().In(pe.location)}
+
+	for _, condition := range policy.Conditions {
+		if !condition.Eval(env) {
+			pe.logger.Debug("policy condition not met", logger.Fields{
+				"user_id":   getUserID(user),
+				"condition": condition.String(),
+			})
+			return &Decision{
+				Allowed: false,
+				Reason:  "access conditions not met",
+				Details: map[string]interface{}{
+					"condition": condition.String(),
+				},
+			}
+		}
+	}
+
+	return &Decision{
+		Allowed: true,
+		Reason:  "conditions met",
+	}
+}
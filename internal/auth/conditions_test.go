@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/expr"
+)
+
+func TestPolicyEvaluator_EvaluateConditions(t *testing.T) {
+	evaluator := NewPolicyEvaluator(false, 5*time.Minute)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("failed to load time zone: %v", err)
+	}
+	evaluator.SetConditionLocation(berlin)
+	// A Wednesday, 16:30 in Berlin
+	evaluator.now = func() time.Time { return time.Date(2026, 3, 11, 15, 30, 0, 0, time.UTC) }
+
+	user := &UserContext{
+		UserID: "user123",
+		Roles:  []string{"user"},
+		Claims: &Claims{Extra: map[string]interface{}{
+			"tenant": "acme",
+			"org_id": float64(7),
+		}},
+		Attributes: map[string]interface{}{"plan": "enterprise"},
+	}
+
+	tests := []struct {
+		name       string
+		conditions []string
+		target     string
+		params     map[string]string
+		expected   bool
+	}{
+		{
+			name:       "own tenant",
+			conditions: []string{"claims.tenant == path.tenantId"},
+			target:     "/tenants/acme/orders",
+			params:     map[string]string{"tenantId": "acme"},
+			expected:   true,
+		},
+		{
+			name:       "other tenant",
+			conditions: []string{"claims.tenant == path.tenantId"},
+			target:     "/tenants/other/orders",
+			params:     map[string]string{"tenantId": "other"},
+			expected:   false,
+		},
+		{
+			name:       "numeric claim against path",
+			conditions: []string{"claims.org_id == path.orgId"},
+			target:     "/orgs/7",
+			params:     map[string]string{"orgId": "7"},
+			expected:   true,
+		},
+		{
+			name:       "request and query attributes",
+			conditions: []string{"request.method == 'GET'", "query.view != 'admin'"},
+			target:     "/reports?view=summary",
+			expected:   true,
+		},
+		{
+			name:       "all conditions must hold",
+			conditions: []string{"'user' in user.roles", "attr.plan == 'free'"},
+			target:     "/reports",
+			expected:   false,
+		},
+		{
+			name:       "params alias",
+			conditions: []string{"claims.tenant == params.tenantId"},
+			target:     "/tenants/acme/orders",
+			params:     map[string]string{"tenantId": "acme"},
+			expected:   true,
+		},
+		{
+			name:       "client network",
+			conditions: []string{"request.client_ip in_cidr ['192.0.2.0/24']"},
+			target:     "/reports",
+			expected:   true,
+		},
+		{
+			name:       "office hours in configured time zone",
+			conditions: []string{"time.hour >= 9 && time.hour < 17", "time.weekday in ['monday', 'tuesday', 'wednesday', 'thursday', 'friday']"},
+			target:     "/reports",
+			expected:   true,
+		},
+		{
+			name:       "outside maintenance window",
+			conditions: []string{"time.clock >= '17:00'"},
+			target:     "/reports",
+			expected:   false,
+		},
+		{
+			name:       "missing path parameter",
+			conditions: []string{"claims.tenant == path.tenantId"},
+			target:     "/orders",
+			expected:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := &Policy{Type: PolicyAuthenticated}
+			for _, condition := range tt.conditions {
+				compiled, err := expr.Compile(condition)
+				if err != nil {
+					t.Fatalf("failed to compile %q: %v", condition, err)
+				}
+				policy.Conditions = append(policy.Conditions, compiled)
+			}
+
+			req := httptest.NewRequest("GET", tt.target, nil)
+			decision := evaluator.EvaluateConditions(policy, user, req, tt.params)
+			if decision.Allowed != tt.expected {
+				t.Errorf("Expected allowed=%v, got %v (%s)", tt.expected, decision.Allowed, decision.Reason)
+			}
+		})
+	}
+}
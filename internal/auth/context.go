@@ -10,6 +10,8 @@ type ContextKey string
 const (
 	// UserContextKey is the context key for user information
 	UserContextKey ContextKey = "auth_user"
+	// PolicyResultKey is the context key for the policy that allowed a request
+	PolicyResultKey ContextKey = "auth_policy_result"
 )
 
 // UserContext represents authenticated user information stored in request context
@@ -18,7 +20,11 @@ type UserContext struct {
 	SessionID   string
 	Roles       []string
 	Permissions []string
-	Claims      *Claims
+	// Scopes are the OAuth scopes granted to the token
+	Scopes []string
+	Claims *Claims
+	// Attributes are loaded from an external user store by the claims enricher
+	Attributes map[string]interface{}
 }
 
 // SetUserContext stores user context in the request context
@@ -32,6 +38,18 @@ func GetUserContext(ctx context.Context) (*UserContext, bool) {
 	return user, ok
 }
 
+// SetPolicyResult records the type of the auth policy that allowed the request
+func SetPolicyResult(ctx context.Context, policy string) context.Context {
+	return context.WithValue(ctx, PolicyResultKey, policy)
+}
+
+// GetPolicyResult returns the type of the auth policy that allowed the
+// request, or "" if authorization did not run
+func GetPolicyResult(ctx context.Context) string {
+	policy, _ := ctx.Value(PolicyResultKey).(string)
+	return policy
+}
+
 // NewUserContext creates a new user context from validated claims
 func NewUserContext(claims *Claims) *UserContext {
 	return &UserContext{
@@ -39,6 +57,7 @@ func NewUserContext(claims *Claims) *UserContext {
 		SessionID:   claims.SessionID,
 		Roles:       claims.Roles,
 		Permissions: claims.Permissions,
+		Scopes:      claims.Scopes(),
 		Claims:      claims,
 	}
 }
@@ -73,10 +92,22 @@ func (uc *UserContext) HasAllRoles(roles []string) bool {
 	return true
 }
 
-// HasPermission checks if the user has a specific permission
+// HasPermission checks if the user has a specific permission, either
+// directly or through a wildcard such as "orders:*"
 func (uc *UserContext) HasPermission(permission string) bool {
 	for _, p := range uc.Permissions {
-		if p == permission {
+		if permissionMatches(p, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope checks if the token was granted a scope. Scopes match like
+// permissions, so the scope "orders:*" covers "orders:read".
+func (uc *UserContext) HasScope(scope string) bool {
+	for _, s := range uc.Scopes {
+		if permissionMatches(s, scope) {
 			return true
 		}
 	}
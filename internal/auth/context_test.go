@@ -119,6 +119,38 @@ func TestUserContext_HasPermission(t *testing.T) {
 	}
 }
 
+func TestUserContext_HasPermissionWildcard(t *testing.T) {
+	tests := []struct {
+		name       string
+		granted    []string
+		permission string
+		expected   bool
+	}{
+		{"NamespaceWildcard", []string{"orders:*"}, "orders:read", true},
+		{"SingleSegmentWildcard", []string{"orders:*"}, "orders:items:write", false},
+		{"NamespaceItself", []string{"orders:*"}, "orders", false},
+		{"RecursiveWildcard", []string{"admin:**"}, "admin:users:delete", true},
+		{"RecursiveWildcardOneSegment", []string{"admin:**"}, "admin:users", true},
+		{"RecursiveWildcardNotNamespace", []string{"admin:**"}, "admin", false},
+		{"InnerWildcard", []string{"orders:*:read"}, "orders:items:read", true},
+		{"InnerWildcardAction", []string{"orders:*:read"}, "orders:items:write", false},
+		{"OtherNamespace", []string{"orders:*"}, "users:read", false},
+		{"PrefixIsNotNamespace", []string{"orders:*"}, "ordersarchive:read", false},
+		{"GlobalWildcard", []string{"*"}, "users:delete", true},
+		{"PartialWildcardIsLiteral", []string{"ord*"}, "orders:read", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &UserContext{UserID: "user123", Permissions: tt.granted}
+			result := user.HasPermission(tt.permission)
+			if result != tt.expected {
+				t.Errorf("HasPermission(%s) = %v, want %v", tt.permission, result, tt.expected)
+			}
+		})
+	}
+}
+
 func TestUserContext_HasAnyPermission(t *testing.T) {
 	user := &UserContext{
 		UserID:      "user123",
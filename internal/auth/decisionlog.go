@@ -0,0 +1,156 @@
+package auth
+
+import (
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/clientip"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+)
+
+// decisionLogPath is the policy path reported in decision log entries
+const decisionLogPath = "gateway/authz/allow"
+
+// DecisionLogger writes authorization decisions to a dedicated sink so that
+// security teams can audit who accessed what. Entries follow the OPA decision
+// log format, so existing OPA tooling can ingest them.
+type DecisionLogger struct {
+	config *config.DecisionLogConfig
+	out    io.Writer
+	closer io.Closer
+	mu     sync.Mutex
+	logger *logger.ComponentLogger
+}
+
+// DecisionLogEntry is a single decision in the OPA decision log format
+type DecisionLogEntry struct {
+	DecisionID  string                 `json:"decision_id"`
+	Path        string                 `json:"path"`
+	Input       DecisionInput          `json:"input"`
+	Result      DecisionResult         `json:"result"`
+	RequestedBy string                 `json:"requested_by,omitempty"`
+	Timestamp   time.Time              `json:"timestamp"`
+	Labels      map[string]string      `json:"labels,omitempty"`
+	Metrics     map[string]interface{} `json:"metrics,omitempty"`
+}
+
+// DecisionInput describes the request a decision was made for
+type DecisionInput struct {
+	Method        string   `json:"method"`
+	Path          string   `json:"path"`
+	Route         string   `json:"route,omitempty"`
+	Subject       string   `json:"subject,omitempty"`
+	SessionID     string   `json:"session_id,omitempty"`
+	Roles         []string `json:"roles,omitempty"`
+	CorrelationID string   `json:"correlation_id,omitempty"`
+}
+
+// DecisionResult describes the outcome of a decision
+type DecisionResult struct {
+	Allow      bool   `json:"allow"`
+	PolicyType string `json:"policy_type"`
+	Rule       string `json:"rule,omitempty"`
+	Reason     string `json:"reason"`
+}
+
+// NewDecisionLogger creates a decision logger writing to the configured output
+func NewDecisionLogger(cfg *config.DecisionLogConfig) (*DecisionLogger, error) {
+	dl := &DecisionLogger{
+		config: cfg,
+		logger: logger.Get().WithComponent("auth.decisions"),
+	}
+
+	switch cfg.Output {
+	case "stdout":
+		dl.out = os.Stdout
+	case "stderr":
+		dl.out = os.Stderr
+	default:
+		file, err := os.OpenFile(cfg.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open decision log: %w", err)
+		}
+		dl.out = file
+		dl.closer = file
+	}
+
+	return dl, nil
+}
+
+// Log records a decision, subject to the configured sampling rates
+func (dl *DecisionLogger) Log(r *http.Request, route string, user *UserContext, result DecisionResult, duration time.Duration) {
+	rate := dl.config.DenySampleRate
+	if result.Allow {
+		rate = dl.config.AllowSampleRate
+	}
+	if rate < 1 && rand.Float64() >= rate {
+		return
+	}
+
+	entry := DecisionLogEntry{
+		DecisionID: newDecisionID(),
+		Path:       decisionLogPath,
+		Input: DecisionInput{
+			Method:        r.Method,
+			Path:          r.URL.Path,
+			Route:         route,
+			CorrelationID: logger.GetCorrelationID(r.Context()),
+		},
+		Result:      result,
+		RequestedBy: clientip.FromRequest(r),
+		Timestamp:   time.Now().UTC(),
+		Labels:      dl.config.Labels,
+		Metrics: map[string]interface{}{
+			"timer_server_handler_ns": duration.Nanoseconds(),
+		},
+	}
+	if user != nil {
+		entry.Input.Subject = user.UserID
+		entry.Input.SessionID = maskSessionID(user.SessionID)
+		entry.Input.Roles = user.Roles
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		dl.logger.Error("failed to encode decision log entry", logger.Fields{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	if _, err := dl.out.Write(append(data, '\n')); err != nil {
+		dl.logger.Error("failed to write decision log entry", logger.Fields{
+			"error": err.Error(),
+		})
+	}
+}
+
+// Close closes the decision log file, if any
+func (dl *DecisionLogger) Close() error {
+	if dl.closer == nil {
+		return nil
+	}
+	return dl.closer.Close()
+}
+
+// newDecisionID returns a random UUID (version 4)
+func newDecisionID() string {
+	var b [16]byte
+	_, _ = crand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	s := hex.EncodeToString(b[:])
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
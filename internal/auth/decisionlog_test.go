@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+func readDecisionLog(t *testing.T, path string) []DecisionLogEntry {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open decision log: %v", err)
+	}
+	defer file.Close()
+
+	var entries []DecisionLogEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry DecisionLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid decision log line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestDecisionLogger_Log(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decisions.log")
+	dl, err := NewDecisionLogger(&config.DecisionLogConfig{
+		Enabled:         true,
+		Output:          path,
+		AllowSampleRate: 1,
+		DenySampleRate:  1,
+		Labels:          map[string]string{"env": "test"},
+	})
+	if err != nil {
+		t.Fatalf("NewDecisionLogger() error = %v", err)
+	}
+
+	req := httptest.NewRequest("DELETE", "/api/v1/users/42", nil)
+	user := &UserContext{UserID: "user123", SessionID: "session-abcdef123456", Roles: []string{"admin"}}
+	dl.Log(req, "/api/v1/users/{id}", user, DecisionResult{
+		Allow:      false,
+		PolicyType: string(PolicyRoleBased),
+		Rule:       "roles: admin",
+		Reason:     "missing required roles",
+	}, time.Millisecond)
+	if err := dl.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	entries := readDecisionLog(t, path)
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	entry := entries[0]
+
+	if entry.DecisionID == "" || entry.Path != decisionLogPath {
+		t.Errorf("unexpected decision_id %q or path %q", entry.DecisionID, entry.Path)
+	}
+	if entry.Input.Method != "DELETE" || entry.Input.Route != "/api/v1/users/{id}" || entry.Input.Subject != "user123" {
+		t.Errorf("unexpected input: %+v", entry.Input)
+	}
+	if entry.Input.SessionID == user.SessionID {
+		t.Error("session ID should be masked")
+	}
+	if entry.Result.Allow || entry.Result.Rule != "roles: admin" {
+		t.Errorf("unexpected result: %+v", entry.Result)
+	}
+	if entry.Labels["env"] != "test" {
+		t.Errorf("expected labels to be included, got %v", entry.Labels)
+	}
+}
+
+func TestDecisionLogger_Sampling(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decisions.log")
+	dl, err := NewDecisionLogger(&config.DecisionLogConfig{
+		Enabled:         true,
+		Output:          path,
+		AllowSampleRate: 0,
+		DenySampleRate:  1,
+	})
+	if err != nil {
+		t.Fatalf("NewDecisionLogger() error = %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/profile", nil)
+	for i := 0; i < 10; i++ {
+		dl.Log(req, "/api/v1/profile", nil, DecisionResult{Allow: true, PolicyType: "authenticated"}, 0)
+	}
+	dl.Log(req, "/api/v1/profile", nil, DecisionResult{Allow: false, PolicyType: "authenticated", Reason: "missing token"}, 0)
+	_ = dl.Close()
+
+	entries := readDecisionLog(t, path)
+	if len(entries) != 1 || entries[0].Result.Allow {
+		t.Fatalf("expected only the deny decision to be logged, got %+v", entries)
+	}
+}
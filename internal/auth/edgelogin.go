@@ -0,0 +1,638 @@
+package auth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+)
+
+const (
+	// loginStateTTL bounds the time between the redirect to the identity
+	// provider and the callback
+	loginStateTTL = 10 * time.Minute
+	// maxCookieSize is the largest cookie browsers reliably store
+	maxCookieSize = 4096
+	// maxTokenResponse bounds token endpoint responses
+	maxTokenResponse = 1 << 20
+)
+
+// errSessionTooLarge is returned when the tokens do not fit into a cookie
+var errSessionTooLarge = errors.New("session does not fit into a cookie")
+
+// EdgeLogin runs the OIDC authorization code flow for browser apps. It keeps
+// the tokens in an encrypted session cookie and hands the configured token to
+// the authorization middleware as its session cookie.
+type EdgeLogin struct {
+	config       *config.EdgeLoginConfig
+	authCookie   string
+	callbackPath string
+	aead         cipher.AEAD
+	client       *http.Client
+	logger       *logger.ComponentLogger
+	now          func() time.Time
+
+	mu        sync.Mutex
+	endpoints *oidcEndpoints
+
+	refreshMu  sync.Mutex
+	refreshing map[string]*refreshCall
+}
+
+// oidcEndpoints are the endpoints of the identity provider
+type oidcEndpoints struct {
+	Authorization string `json:"authorization_endpoint"`
+	Token         string `json:"token_endpoint"`
+}
+
+// edgeSession is the content of the session cookie
+type edgeSession struct {
+	Token        string `json:"t"`
+	RefreshToken string `json:"r,omitempty"`
+	Expiry       int64  `json:"e,omitempty"` // unix seconds; 0 if unknown
+	Created      int64  `json:"c"`
+}
+
+// loginState is the content of the state cookie set before the redirect to
+// the identity provider
+type loginState struct {
+	State    string `json:"s"`
+	Verifier string `json:"v"`
+	ReturnTo string `json:"r"`
+	Expires  int64  `json:"e"`
+}
+
+// tokenResponse is a token endpoint response
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	IDToken      string `json:"id_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// refreshCall is a refresh in progress; concurrent requests of a session
+// share it so a rotating refresh token is only used once
+type refreshCall struct {
+	done    chan struct{}
+	session *edgeSession
+	err     error
+}
+
+// NewEdgeLogin creates the login flow. authCookie is the cookie the
+// authorization middleware reads tokens from.
+func NewEdgeLogin(cfg *config.EdgeLoginConfig, authCookie string) (*EdgeLogin, error) {
+	key, err := cfg.EncryptionKey.Resolve()
+	if err != nil {
+		return nil, fmt.Errorf("edge login encryption key: %w", err)
+	}
+	digest := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("edge login encryption key: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("edge login encryption key: %w", err)
+	}
+
+	redirect, err := url.Parse(cfg.RedirectURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid edge login redirect url: %w", err)
+	}
+
+	e := &EdgeLogin{
+		config:       cfg,
+		authCookie:   authCookie,
+		callbackPath: redirect.Path,
+		aead:         aead,
+		client:       &http.Client{Timeout: cfg.Timeout},
+		logger:       logger.Get().WithComponent("auth.edgelogin"),
+		now:          time.Now,
+		refreshing:   make(map[string]*refreshCall),
+	}
+	if cfg.AuthorizationURL != "" && cfg.TokenURL != "" {
+		e.endpoints = &oidcEndpoints{Authorization: cfg.AuthorizationURL, Token: cfg.TokenURL}
+	}
+	return e, nil
+}
+
+// Handler serves the callback and logout paths, turns session cookies into
+// tokens for the authorization middleware and redirects browser navigations
+// without a session to the identity provider when they would get a 401
+func (e *EdgeLogin) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case e.callbackPath:
+			e.handleCallback(w, r)
+			return
+		case e.config.LogoutPath:
+			e.handleLogout(w, r)
+			return
+		}
+
+		if session := e.readSession(r); session != nil {
+			if session, ok := e.freshSession(w, r, session); ok {
+				e.forwardToken(r, session.Token)
+				next.ServeHTTP(w, r)
+				return
+			}
+			// The session ended; the next navigation logs in again
+			e.clearCookie(w, e.config.CookieName, "/")
+			e.forwardToken(r, "")
+		}
+
+		if !isBrowserNavigation(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(&loginRedirectWriter{ResponseWriter: w, edge: e, request: r}, r)
+	})
+}
+
+// isBrowserNavigation reports whether r is a page load by a browser, which
+// can follow a redirect to the identity provider
+func isBrowserNavigation(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if r.Header.Get("Authorization") != "" {
+		return false
+	}
+	if mode := r.Header.Get("Sec-Fetch-Mode"); mode != "" && mode != "navigate" {
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// loginRedirectWriter replaces a 401 response with the redirect to the
+// identity provider
+type loginRedirectWriter struct {
+	http.ResponseWriter
+	edge        *EdgeLogin
+	request     *http.Request
+	wroteHeader bool
+	redirected  bool
+}
+
+func (lw *loginRedirectWriter) WriteHeader(statusCode int) {
+	if lw.wroteHeader {
+		return
+	}
+	if statusCode < http.StatusOK {
+		// Informational responses precede the final status
+		lw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	lw.wroteHeader = true
+	if statusCode == http.StatusUnauthorized {
+		lw.redirected = true
+		lw.edge.redirectToLogin(lw.ResponseWriter, lw.request)
+		return
+	}
+	lw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (lw *loginRedirectWriter) Write(b []byte) (int, error) {
+	if !lw.wroteHeader {
+		lw.WriteHeader(http.StatusOK)
+	}
+	if lw.redirected {
+		// The 401 body is replaced by the redirect
+		return len(b), nil
+	}
+	return lw.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController
+func (lw *loginRedirectWriter) Unwrap() http.ResponseWriter {
+	return lw.ResponseWriter
+}
+
+// redirectToLogin sends the browser to the authorization endpoint, keeping
+// the state, PKCE verifier and requested page in the state cookie
+func (e *EdgeLogin) redirectToLogin(w http.ResponseWriter, r *http.Request) {
+	endpoints, err := e.discover(r.Context())
+	if err != nil {
+		e.logger.Error("identity provider discovery failed", logger.Fields{
+			"issuer": e.config.Issuer,
+			"error":  err.Error(),
+		})
+		metrics.RecordAuthEdgeLogin("login_failure")
+		http.Error(w, "Login is currently unavailable", http.StatusBadGateway)
+		return
+	}
+
+	state := loginState{
+		State:    randomToken(),
+		Verifier: randomToken(),
+		ReturnTo: r.URL.RequestURI(),
+		Expires:  e.now().Add(loginStateTTL).Unix(),
+	}
+	value, err := e.seal(e.stateCookieName(), state)
+	if err != nil {
+		e.logger.Error("failed to encode login state", logger.Fields{"error": err.Error()})
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	e.setCookie(w, e.stateCookieName(), value, e.callbackPath, loginStateTTL)
+
+	challenge := sha256.Sum256([]byte(state.Verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {e.config.ClientID},
+		"redirect_uri":          {e.config.RedirectURL},
+		"scope":                 {strings.Join(e.config.Scopes, " ")},
+		"state":                 {state.State},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	target := endpoints.Authorization + "?" + query.Encode()
+	if strings.Contains(endpoints.Authorization, "?") {
+		target = endpoints.Authorization + "&" + query.Encode()
+	}
+
+	metrics.RecordAuthEdgeLogin("login_redirect")
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// handleCallback exchanges the authorization code for tokens, issues the
+// session cookie and returns the browser to the page it requested
+func (e *EdgeLogin) handleCallback(w http.ResponseWriter, r *http.Request) {
+	fail := func(status int, reason, message string) {
+		e.logger.Warn("login failed", logger.Fields{
+			"reason": reason,
+			"path":   r.URL.Path,
+		})
+		metrics.RecordAuthEdgeLogin("login_failure")
+		e.clearCookie(w, e.stateCookieName(), e.callbackPath)
+		http.Error(w, message, status)
+	}
+
+	query := r.URL.Query()
+	if idpError := query.Get("error"); idpError != "" {
+		fail(http.StatusUnauthorized, "identity provider error: "+idpError, "Login failed")
+		return
+	}
+
+	var state loginState
+	cookie, err := r.Cookie(e.stateCookieName())
+	if err != nil || e.open(e.stateCookieName(), cookie.Value, &state) != nil {
+		fail(http.StatusBadRequest, "missing login state", "Login session expired, please try again")
+		return
+	}
+	if e.now().Unix() > state.Expires ||
+		subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state.State)) != 1 {
+		fail(http.StatusBadRequest, "state mismatch", "Login session expired, please try again")
+		return
+	}
+
+	tokens, err := e.requestTokens(r.Context(), url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {query.Get("code")},
+		"redirect_uri":  {e.config.RedirectURL},
+		"code_verifier": {state.Verifier},
+	})
+	if err != nil {
+		fail(http.StatusBadGateway, err.Error(), "Login failed")
+		return
+	}
+
+	session := &edgeSession{Created: e.now().Unix()}
+	if err := e.applyTokens(session, tokens); err != nil {
+		fail(http.StatusBadGateway, err.Error(), "Login failed")
+		return
+	}
+	if err := e.writeSession(w, session); err != nil {
+		fail(http.StatusInternalServerError, err.Error(), "Login failed")
+		return
+	}
+
+	e.clearCookie(w, e.stateCookieName(), e.callbackPath)
+	metrics.RecordAuthEdgeLogin("login_success")
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, safeReturnTo(state.ReturnTo), http.StatusFound)
+}
+
+// handleLogout ends the session
+func (e *EdgeLogin) handleLogout(w http.ResponseWriter, r *http.Request) {
+	e.clearCookie(w, e.config.CookieName, "/")
+	metrics.RecordAuthEdgeLogin("logout")
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, e.config.PostLogoutRedirect, http.StatusFound)
+}
+
+// safeReturnTo only allows returning to a path on this host
+func safeReturnTo(target string) string {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return "/"
+	}
+	return target
+}
+
+// readSession returns the session of r, or nil if it has none or it ended
+func (e *EdgeLogin) readSession(r *http.Request) *edgeSession {
+	cookie, err := r.Cookie(e.config.CookieName)
+	if err != nil {
+		return nil
+	}
+	var session edgeSession
+	if err := e.open(e.config.CookieName, cookie.Value, &session); err != nil {
+		e.logger.Debug("invalid session cookie", logger.Fields{"error": err.Error()})
+		return nil
+	}
+	if e.now().Sub(time.Unix(session.Created, 0)) > e.config.SessionTTL {
+		return nil
+	}
+	return &session
+}
+
+// freshSession refreshes the tokens of a session about to expire. It
+// returns false if the session cannot be used anymore.
+func (e *EdgeLogin) freshSession(w http.ResponseWriter, r *http.Request, session *edgeSession) (*edgeSession, bool) {
+	if session.Expiry == 0 {
+		return session, true
+	}
+	now := e.now()
+	expiry := time.Unix(session.Expiry, 0)
+	if now.Before(expiry.Add(-e.config.RefreshBefore)) {
+		return session, true
+	}
+	if session.RefreshToken == "" {
+		return session, now.Before(expiry)
+	}
+
+	refreshed, err := e.refresh(r.Context(), session)
+	if err != nil {
+		e.logger.Warn("token refresh failed", logger.Fields{"error": err.Error()})
+		metrics.RecordAuthEdgeLogin("refresh_failure")
+		// Tokens that have not expired yet stay usable
+		return session, now.Before(expiry)
+	}
+	if err := e.writeSession(w, refreshed); err != nil {
+		e.logger.Error("failed to store refreshed session", logger.Fields{"error": err.Error()})
+		return session, now.Before(expiry)
+	}
+	metrics.RecordAuthEdgeLogin("refresh_success")
+	return refreshed, true
+}
+
+// refresh redeems the refresh token of a session. Concurrent refreshes of
+// the same session share one request to the identity provider.
+func (e *EdgeLogin) refresh(ctx context.Context, session *edgeSession) (*edgeSession, error) {
+	e.refreshMu.Lock()
+	if call, ok := e.refreshing[session.RefreshToken]; ok {
+		e.refreshMu.Unlock()
+		<-call.done
+		return call.session, call.err
+	}
+	call := &refreshCall{done: make(chan struct{})}
+	e.refreshing[session.RefreshToken] = call
+	e.refreshMu.Unlock()
+
+	defer func() {
+		e.refreshMu.Lock()
+		delete(e.refreshing, session.RefreshToken)
+		e.refreshMu.Unlock()
+		close(call.done)
+	}()
+
+	tokens, err := e.requestTokens(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {session.RefreshToken},
+	})
+	if err != nil {
+		call.err = err
+		return nil, err
+	}
+
+	refreshed := &edgeSession{RefreshToken: session.RefreshToken, Created: session.Created}
+	if err := e.applyTokens(refreshed, tokens); err != nil {
+		call.err = err
+		return nil, err
+	}
+	call.session = refreshed
+	return refreshed, nil
+}
+
+// applyTokens stores the configured token of a token response in session
+func (e *EdgeLogin) applyTokens(session *edgeSession, tokens *tokenResponse) error {
+	session.Token = tokens.AccessToken
+	if e.config.Token == "id_token" {
+		session.Token = tokens.IDToken
+	}
+	if session.Token == "" {
+		return fmt.Errorf("token response has no %s", e.config.Token)
+	}
+	if tokens.RefreshToken != "" {
+		session.RefreshToken = tokens.RefreshToken
+	}
+
+	session.Expiry = 0
+	if tokens.ExpiresIn > 0 {
+		session.Expiry = e.now().Unix() + tokens.ExpiresIn
+	}
+	// A JWT's own expiry wins, e.g. for ID tokens living shorter than the
+	// access token
+	if claims, _, err := jwt.NewParser().ParseUnverified(session.Token, jwt.MapClaims{}); err == nil {
+		if exp, err := claims.Claims.GetExpirationTime(); err == nil && exp != nil {
+			session.Expiry = exp.Unix()
+		}
+	}
+	return nil
+}
+
+// requestTokens calls the token endpoint with the client credentials
+func (e *EdgeLogin) requestTokens(ctx context.Context, form url.Values) (*tokenResponse, error) {
+	endpoints, err := e.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := e.config.ClientSecret.Resolve()
+	if err != nil {
+		return nil, fmt.Errorf("client secret: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoints.Token, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// RFC 6749 section 2.3.1 form-encodes client credentials for basic auth
+	req.SetBasicAuth(url.QueryEscape(e.config.ClientID), url.QueryEscape(secret))
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTokenResponse))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var idpError struct {
+			Error string `json:"error"`
+		}
+		_ = json.Unmarshal(body, &idpError)
+		return nil, fmt.Errorf("token endpoint returned status %d %s", resp.StatusCode, idpError.Error)
+	}
+
+	var tokens tokenResponse
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, fmt.Errorf("invalid token response: %w", err)
+	}
+	return &tokens, nil
+}
+
+// discover returns the provider endpoints, fetching the issuer's discovery
+// document on first use
+func (e *EdgeLogin) discover(ctx context.Context) (*oidcEndpoints, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.endpoints != nil {
+		return e.endpoints, nil
+	}
+
+	discoveryURL := strings.TrimSuffix(e.config.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery request: %w", err)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("discovery request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery endpoint returned status %d", resp.StatusCode)
+	}
+
+	var endpoints oidcEndpoints
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxTokenResponse)).Decode(&endpoints); err != nil {
+		return nil, fmt.Errorf("invalid discovery document: %w", err)
+	}
+	if endpoints.Authorization == "" || endpoints.Token == "" {
+		return nil, fmt.Errorf("discovery document lacks authorization or token endpoint")
+	}
+	if e.config.AuthorizationURL != "" {
+		endpoints.Authorization = e.config.AuthorizationURL
+	}
+	if e.config.TokenURL != "" {
+		endpoints.Token = e.config.TokenURL
+	}
+	e.endpoints = &endpoints
+	return e.endpoints, nil
+}
+
+// forwardToken replaces the session cookie of r with the authorization
+// cookie carrying token, or removes both if token is empty
+func (e *EdgeLogin) forwardToken(r *http.Request, token string) {
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	for _, cookie := range cookies {
+		if cookie.Name != e.config.CookieName && cookie.Name != e.authCookie {
+			r.AddCookie(cookie)
+		}
+	}
+	if token != "" {
+		r.AddCookie(&http.Cookie{Name: e.authCookie, Value: token})
+	}
+}
+
+// writeSession sets the session cookie
+func (e *EdgeLogin) writeSession(w http.ResponseWriter, session *edgeSession) error {
+	value, err := e.seal(e.config.CookieName, session)
+	if err != nil {
+		return err
+	}
+	remaining := e.config.SessionTTL - e.now().Sub(time.Unix(session.Created, 0))
+	e.setCookie(w, e.config.CookieName, value, "/", remaining)
+	return nil
+}
+
+func (e *EdgeLogin) setCookie(w http.ResponseWriter, name, value, path string, maxAge time.Duration) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   e.config.CookieDomain,
+		MaxAge:   int(maxAge.Seconds()),
+		Secure:   e.config.CookieSecure,
+		HttpOnly: true,
+		// Lax lets the cookies through the top-level redirects of the flow
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (e *EdgeLogin) clearCookie(w http.ResponseWriter, name, path string) {
+	e.setCookie(w, name, "", path, -time.Second)
+}
+
+func (e *EdgeLogin) stateCookieName() string {
+	return e.config.CookieName + "_state"
+}
+
+// seal encrypts v for the cookie called name; the name is authenticated so
+// one cookie cannot be replayed as another
+func (e *EdgeLogin) seal(name string, v interface{}) (string, error) {
+	plaintext, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := e.aead.Seal(nonce, nonce, plaintext, []byte(name))
+	value := base64.RawURLEncoding.EncodeToString(sealed)
+	if len(name)+len(value) > maxCookieSize {
+		return "", errSessionTooLarge
+	}
+	return value, nil
+}
+
+// open decrypts a cookie sealed for name into v
+func (e *EdgeLogin) open(name, value string, v interface{}) error {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return err
+	}
+	if len(sealed) < e.aead.NonceSize() {
+		return fmt.Errorf("cookie too short")
+	}
+	nonce, ciphertext := sealed[:e.aead.NonceSize()], sealed[e.aead.NonceSize():]
+	plaintext, err := e.aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(plaintext, v)
+}
+
+// randomToken returns 32 random bytes, base64url encoded
+func randomToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+)
+
+// testIdP is an identity provider issuing opaque access tokens
+type testIdP struct {
+	server    *httptest.Server
+	challenge string
+	refreshes atomic.Int32
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	idp := &testIdP{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, ok := r.BasicAuth(); !ok || id != "gateway" || secret != "client-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = r.ParseForm()
+		switch r.PostForm.Get("grant_type") {
+		case "authorization_code":
+			digest := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+			if r.PostForm.Get("code") != "auth-code" || base64.RawURLEncoding.EncodeToString(digest[:]) != idp.challenge {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+				return
+			}
+			_, _ = fmt.Fprint(w, `{"access_token":"token-1","refresh_token":"refresh-1","expires_in":300}`)
+		case "refresh_token":
+			n := idp.refreshes.Add(1)
+			_, _ = fmt.Fprintf(w, `{"access_token":"token-%d","refresh_token":"refresh-%d","expires_in":300}`, n+1, n+1)
+		}
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func newTestEdgeLogin(t *testing.T, issuer string) *EdgeLogin {
+	t.Helper()
+	e, err := NewEdgeLogin(&config.EdgeLoginConfig{
+		Enabled:            true,
+		Issuer:             issuer,
+		ClientID:           "gateway",
+		ClientSecret:       config.SecretRef{Value: "client-secret"},
+		RedirectURL:        "https://app.example.com/_auth/callback",
+		Scopes:             []string{"openid", "profile"},
+		Token:              "access_token",
+		LogoutPath:         "/_auth/logout",
+		PostLogoutRedirect: "/goodbye",
+		CookieName:         "gateway_session",
+		EncryptionKey:      config.SecretRef{Value: strings.Repeat("k", 32)},
+		SessionTTL:         time.Hour,
+		RefreshBefore:      time.Minute,
+		Timeout:            time.Second,
+	}, "session_token")
+	if err != nil {
+		t.Fatalf("NewEdgeLogin() error = %v", err)
+	}
+	return e
+}
+
+// protected answers 401 without the authorization cookie and echoes its
+// token otherwise
+var protected = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie("session_token")
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":"unauthorized"}`))
+		return
+	}
+	if _, err := r.Cookie("gateway_session"); err == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	_, _ = w.Write([]byte(cookie.Value))
+})
+
+func browserRequest(method, target string, cookies []*http.Cookie) *http.Request {
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	for _, cookie := range cookies {
+		if cookie.MaxAge >= 0 {
+			req.AddCookie(cookie)
+		}
+	}
+	return req
+}
+
+func findCookie(cookies []*http.Cookie, name string) *http.Cookie {
+	for _, cookie := range cookies {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestEdgeLogin_Flow(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+	idp := newTestIdP(t)
+	handler := newTestEdgeLogin(t, idp.server.URL).Handler(protected)
+
+	// Navigation without a session is sent to the identity provider
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, browserRequest(http.MethodGet, "/dashboard?tab=2", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected redirect to login, got %d", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "unauthorized") {
+		t.Error("401 body leaked into the login redirect")
+	}
+	location, _ := url.Parse(rec.Header().Get("Location"))
+	if location.Path != "/authorize" {
+		t.Fatalf("expected redirect to authorize endpoint, got %s", location)
+	}
+	query := location.Query()
+	if query.Get("client_id") != "gateway" || query.Get("code_challenge_method") != "S256" || query.Get("scope") != "openid profile" {
+		t.Errorf("unexpected authorization request %s", location.RawQuery)
+	}
+	idp.challenge = query.Get("code_challenge")
+	stateCookies := rec.Result().Cookies()
+
+	// A callback with a forged state is rejected
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, browserRequest(http.MethodGet, "/_auth/callback?code=auth-code&state=forged", stateCookies))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected forged state to be rejected, got %d", rec.Code)
+	}
+
+	// The callback exchanges the code and returns to the requested page
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, browserRequest(http.MethodGet, "/_auth/callback?code=auth-code&state="+query.Get("state"), stateCookies))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected redirect after callback, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Location"); got != "/dashboard?tab=2" {
+		t.Errorf("expected return to /dashboard?tab=2, got %s", got)
+	}
+	session := findCookie(rec.Result().Cookies(), "gateway_session")
+	if session == nil || !session.HttpOnly || session.Value == "" {
+		t.Fatalf("expected HttpOnly session cookie, got %v", session)
+	}
+	if strings.Contains(session.Value, "token-1") {
+		t.Error("session cookie is not encrypted")
+	}
+
+	// The session cookie is forwarded as the authorization cookie
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, browserRequest(http.MethodGet, "/dashboard", []*http.Cookie{session}))
+	if rec.Code != http.StatusOK || rec.Body.String() != "token-1" {
+		t.Errorf("expected token-1 to be forwarded, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// Logout clears the session
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, browserRequest(http.MethodGet, "/_auth/logout", []*http.Cookie{session}))
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/goodbye" {
+		t.Errorf("expected redirect to /goodbye, got %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+	if cleared := findCookie(rec.Result().Cookies(), "gateway_session"); cleared == nil || cleared.MaxAge >= 0 {
+		t.Errorf("expected session cookie to be cleared, got %v", cleared)
+	}
+}
+
+func TestEdgeLogin_Refresh(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+	idp := newTestIdP(t)
+	e := newTestEdgeLogin(t, idp.server.URL)
+	handler := e.Handler(protected)
+
+	now := time.Now()
+	e.now = func() time.Time { return now }
+	rec := httptest.NewRecorder()
+	if err := e.writeSession(rec, &edgeSession{
+		Token:        "token-1",
+		RefreshToken: "refresh-1",
+		Expiry:       now.Add(30 * time.Second).Unix(),
+		Created:      now.Unix(),
+	}); err != nil {
+		t.Fatalf("writeSession() error = %v", err)
+	}
+	session := rec.Result().Cookies()
+
+	// Tokens about to expire are refreshed and the cookie is re-issued
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, browserRequest(http.MethodGet, "/dashboard", session))
+	if rec.Body.String() != "token-2" {
+		t.Errorf("expected refreshed token-2, got %q", rec.Body.String())
+	}
+	if findCookie(rec.Result().Cookies(), "gateway_session") == nil {
+		t.Error("expected refreshed session cookie")
+	}
+
+	// Sessions past their TTL log in again
+	now = now.Add(2 * time.Hour)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, browserRequest(http.MethodGet, "/dashboard", session))
+	if rec.Code != http.StatusFound || !strings.Contains(rec.Header().Get("Location"), "/authorize") {
+		t.Errorf("expected expired session to log in again, got %d", rec.Code)
+	}
+}
+
+func TestEdgeLogin_NonNavigation(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+	handler := newTestEdgeLogin(t, "http://idp.invalid").Handler(protected)
+
+	tests := []struct {
+		name   string
+		method string
+		accept string
+	}{
+		{"api request", http.MethodGet, "application/json"},
+		{"form post", http.MethodPost, "text/html"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/orders", nil)
+			req.Header.Set("Accept", tt.accept)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("expected 401 to pass through, got %d", rec.Code)
+			}
+		})
+	}
+}
+
+func TestSafeReturnTo(t *testing.T) {
+	tests := map[string]string{
+		"/orders?id=1":         "/orders?id=1",
+		"//evil.example.com":   "/",
+		"/\\evil.example.com":  "/",
+		"https://evil.example": "/",
+	}
+	for input, expected := range tests {
+		if got := safeReturnTo(input); got != expected {
+			t.Errorf("safeReturnTo(%q) = %q, want %q", input, got, expected)
+		}
+	}
+}
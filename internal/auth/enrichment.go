@@ -0,0 +1,339 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+)
+
+// Enricher loads additional attributes for an authenticated user from an
+// external store
+type Enricher interface {
+	Attributes(ctx context.Context, userID string) (map[string]interface{}, error)
+}
+
+// EnricherFactory creates an Enricher from configuration
+type EnricherFactory func(cfg *config.EnrichmentConfig) (Enricher, error)
+
+var (
+	enricherSources = map[string]EnricherFactory{
+		"http":  newHTTPEnricher,
+		"redis": newRedisEnricher,
+	}
+	enricherSourcesMu sync.RWMutex
+)
+
+// RegisterEnricherSource makes an enrichment source available by name, so
+// stores such as DynamoDB can be plugged in without changing this package
+func RegisterEnricherSource(name string, factory EnricherFactory) {
+	enricherSourcesMu.Lock()
+	defer enricherSourcesMu.Unlock()
+
+	enricherSources[name] = factory
+}
+
+// ClaimsEnricher merges attributes from an external user store into user
+// contexts, caching lookups per user
+type ClaimsEnricher struct {
+	config *config.EnrichmentConfig
+	source Enricher
+	cache  *attributeCache
+	logger *logger.ComponentLogger
+}
+
+// NewClaimsEnricher creates a claims enricher for the configured source
+func NewClaimsEnricher(cfg *config.EnrichmentConfig) (*ClaimsEnricher, error) {
+	enricherSourcesMu.RLock()
+	factory, ok := enricherSources[cfg.Source]
+	enricherSourcesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown enrichment source: %s", cfg.Source)
+	}
+
+	source, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s enrichment source: %w", cfg.Source, err)
+	}
+
+	var cache *attributeCache
+	if cfg.CacheTTL > 0 {
+		cache = newAttributeCache(cfg.CacheTTL)
+	}
+
+	return &ClaimsEnricher{
+		config: cfg,
+		source: source,
+		cache:  cache,
+		logger: logger.Get().WithComponent("auth.enrichment"),
+	}, nil
+}
+
+// Enrich loads the user's attributes and merges them into the user context
+func (e *ClaimsEnricher) Enrich(ctx context.Context, user *UserContext) error {
+	userID := user.UserID
+	if userID == "" && user.Claims != nil {
+		userID = user.Claims.Subject
+	}
+	if userID == "" {
+		return nil
+	}
+
+	if e.cache != nil {
+		if attrs, found := e.cache.get(userID); found {
+			metrics.RecordAuthEnrichment("cache_hit")
+			mergeAttributes(user, attrs)
+			return nil
+		}
+	}
+
+	if e.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.config.Timeout)
+		defer cancel()
+	}
+
+	attrs, err := e.source.Attributes(ctx, userID)
+	if err != nil {
+		metrics.RecordAuthEnrichment("error")
+		return fmt.Errorf("failed to load user attributes: %w", err)
+	}
+	metrics.RecordAuthEnrichment("success")
+
+	if e.cache != nil {
+		e.cache.set(userID, attrs)
+	}
+
+	e.logger.Debug("user attributes loaded", logger.Fields{
+		"user_id":    userID,
+		"attributes": len(attrs),
+	})
+
+	mergeAttributes(user, attrs)
+	return nil
+}
+
+// FailClosed reports whether requests must be rejected when enrichment fails
+func (e *ClaimsEnricher) FailClosed() bool {
+	return e.config.FailureMode == "fail-closed"
+}
+
+// mergeAttributes stores attributes on the user context. Roles and
+// permissions from the store are added to those from the token so policies
+// can use entitlements that are not carried in the token.
+func mergeAttributes(user *UserContext, attrs map[string]interface{}) {
+	if user.Attributes == nil {
+		user.Attributes = make(map[string]interface{}, len(attrs))
+	}
+	for key, value := range attrs {
+		user.Attributes[key] = value
+	}
+
+	user.Roles = appendUnique(user.Roles, stringList(attrs["roles"]))
+	user.Permissions = appendUnique(user.Permissions, stringList(attrs["permissions"]))
+}
+
+// stringList converts a JSON array or comma separated string to a list
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	case string:
+		var out []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// appendUnique appends values not yet present in list
+func appendUnique(list, values []string) []string {
+	if len(values) == 0 {
+		return list
+	}
+
+	// Copy so the token's slices are never modified
+	out := make([]string, len(list), len(list)+len(values))
+	copy(out, list)
+	seen := make(map[string]bool, len(list))
+	for _, item := range list {
+		seen[item] = true
+	}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			out = append(out, value)
+		}
+	}
+	return out
+}
+
+// httpEnricher loads attributes from an HTTP endpoint
+type httpEnricher struct {
+	url    string
+	client *http.Client
+}
+
+// newHTTPEnricher creates an HTTP enrichment source
+func newHTTPEnricher(cfg *config.EnrichmentConfig) (Enricher, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	return &httpEnricher{
+		url:    cfg.URL,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}, nil
+}
+
+// Attributes implements Enricher. Unknown users (404) have no attributes.
+func (h *httpEnricher) Attributes(ctx context.Context, userID string) (map[string]interface{}, error) {
+	reqURL := fmt.Sprintf("%s?user_id=%s", h.url, url.QueryEscape(userID))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return map[string]interface{}{}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("user store returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var attrs map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&attrs); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return attrs, nil
+}
+
+// redisEnricher loads attributes from a Redis hash per user
+type redisEnricher struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+// newRedisEnricher creates a Redis enrichment source
+func newRedisEnricher(cfg *config.EnrichmentConfig) (Enricher, error) {
+	if cfg.RedisAddr == "" {
+		return nil, fmt.Errorf("redis_addr is required")
+	}
+	return &redisEnricher{
+		client: redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddr,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+		}),
+		keyPrefix: cfg.RedisKeyPrefix,
+	}, nil
+}
+
+// Attributes implements Enricher
+func (r *redisEnricher) Attributes(ctx context.Context, userID string) (map[string]interface{}, error) {
+	fields, err := r.client.HGetAll(ctx, r.keyPrefix+userID).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		attrs[key] = value
+	}
+	return attrs, nil
+}
+
+// attributeCache caches user attributes
+type attributeCache struct {
+	cache map[string]*attributeEntry
+	ttl   time.Duration
+	mu    sync.RWMutex
+}
+
+type attributeEntry struct {
+	attrs     map[string]interface{}
+	expiresAt time.Time
+}
+
+// newAttributeCache creates a new attribute cache
+func newAttributeCache(ttl time.Duration) *attributeCache {
+	ac := &attributeCache{
+		cache: make(map[string]*attributeEntry),
+		ttl:   ttl,
+	}
+
+	// Start cleanup goroutine
+	go ac.cleanup()
+
+	return ac
+}
+
+// get retrieves attributes from cache
+func (ac *attributeCache) get(userID string) (map[string]interface{}, bool) {
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+
+	entry, found := ac.cache[userID]
+	if !found || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.attrs, true
+}
+
+// set stores attributes in cache
+func (ac *attributeCache) set(userID string, attrs map[string]interface{}) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	ac.cache[userID] = &attributeEntry{
+		attrs:     attrs,
+		expiresAt: time.Now().Add(ac.ttl),
+	}
+}
+
+// cleanup periodically removes expired entries
+func (ac *attributeCache) cleanup() {
+	ticker := time.NewTicker(ac.ttl)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ac.mu.Lock()
+		now := time.Now()
+		for key, entry := range ac.cache {
+			if now.After(entry.expiresAt) {
+				delete(ac.cache, key)
+			}
+		}
+		ac.mu.Unlock()
+	}
+}
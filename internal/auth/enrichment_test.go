@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+func TestClaimsEnricher_HTTP(t *testing.T) {
+	var lookups int32
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&lookups, 1)
+		if r.URL.Query().Get("user_id") != "user123" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"tenant":"acme","roles":["billing","user"],"permissions":"invoices:read"}`))
+	}))
+	defer store.Close()
+
+	enricher, err := NewClaimsEnricher(&config.EnrichmentConfig{
+		Source:   "http",
+		URL:      store.URL,
+		Timeout:  time.Second,
+		CacheTTL: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create enricher: %v", err)
+	}
+
+	t.Run("MergesAttributes", func(t *testing.T) {
+		user := &UserContext{UserID: "user123", Roles: []string{"user"}}
+		if err := enricher.Enrich(context.Background(), user); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		if user.Attributes["tenant"] != "acme" {
+			t.Errorf("Expected tenant acme, got: %v", user.Attributes["tenant"])
+		}
+		if !user.HasAllRoles([]string{"user", "billing"}) || len(user.Roles) != 2 {
+			t.Errorf("Expected roles [user billing], got: %v", user.Roles)
+		}
+		if len(user.Permissions) != 1 || user.Permissions[0] != "invoices:read" {
+			t.Errorf("Expected permissions [invoices:read], got: %v", user.Permissions)
+		}
+	})
+
+	t.Run("CachesLookups", func(t *testing.T) {
+		before := atomic.LoadInt32(&lookups)
+		if err := enricher.Enrich(context.Background(), &UserContext{UserID: "user123"}); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if got := atomic.LoadInt32(&lookups); got != before {
+			t.Errorf("Expected cached lookup, got %d store requests", got-before)
+		}
+	})
+
+	t.Run("UnknownUser", func(t *testing.T) {
+		user := &UserContext{UserID: "unknown"}
+		if err := enricher.Enrich(context.Background(), user); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(user.Attributes) != 0 {
+			t.Errorf("Expected no attributes, got: %v", user.Attributes)
+		}
+	})
+}
+
+// staticEnricher is a registered test source
+type staticEnricher struct {
+	attrs map[string]interface{}
+	err   error
+}
+
+func (s *staticEnricher) Attributes(ctx context.Context, userID string) (map[string]interface{}, error) {
+	return s.attrs, s.err
+}
+
+func TestClaimsEnricher_RegisteredSource(t *testing.T) {
+	source := &staticEnricher{attrs: map[string]interface{}{"tenant": "globex"}}
+	RegisterEnricherSource("static-test", func(cfg *config.EnrichmentConfig) (Enricher, error) {
+		return source, nil
+	})
+
+	enricher, err := NewClaimsEnricher(&config.EnrichmentConfig{Source: "static-test", FailureMode: "fail-closed"})
+	if err != nil {
+		t.Fatalf("Failed to create enricher: %v", err)
+	}
+	if !enricher.FailClosed() {
+		t.Error("Expected enricher to fail closed")
+	}
+
+	user := &UserContext{UserID: "user123"}
+	if err := enricher.Enrich(context.Background(), user); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if user.Attributes["tenant"] != "globex" {
+		t.Errorf("Expected tenant globex, got: %v", user.Attributes["tenant"])
+	}
+
+	source.err = errors.New("store unavailable")
+	if err := enricher.Enrich(context.Background(), &UserContext{UserID: "user456"}); err == nil {
+		t.Error("Expected error from failing source")
+	}
+
+	if _, err := NewClaimsEnricher(&config.EnrichmentConfig{Source: "unknown"}); err == nil {
+		t.Error("Expected error for unknown source")
+	}
+}
@@ -0,0 +1,268 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/maltehedderich/api-gateway-go/internal/clientip"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// ErrExternalAuthzUnavailable is returned when the external authorization
+// service cannot be reached or gives no usable answer
+var ErrExternalAuthzUnavailable = errors.New("external authorization unavailable")
+
+// maxExternalAuthzResponse caps the size of authorization responses
+const maxExternalAuthzResponse = 1 << 20
+
+// externalAuthzSkipHeaders are never sent to the authorization service:
+// credentials, which it gets as verified claims instead, and per-request
+// IDs, which would defeat decision caching
+var externalAuthzSkipHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Proxy-Authorization",
+	"X-Correlation-Id",
+	"X-Request-Id",
+	"Traceparent",
+	"Tracestate",
+}
+
+// ExternalAuthorizer asks an OPA or webhook service to decide requests on
+// routes with the external policy
+type ExternalAuthorizer struct {
+	config *config.ExternalAuthzConfig
+	client *http.Client
+	cache  *policyCache
+	skip   map[string]bool
+	logger *logger.ComponentLogger
+}
+
+// ExternalAuthzInput is the request description sent to the authorization
+// service as {"input": ...}
+type ExternalAuthzInput struct {
+	Method   string             `json:"method"`
+	Path     string             `json:"path"`
+	Query    string             `json:"query,omitempty"`
+	Route    string             `json:"route"`
+	Params   map[string]string  `json:"params,omitempty"`
+	Headers  map[string]string  `json:"headers,omitempty"`
+	ClientIP string             `json:"client_ip,omitempty"`
+	User     *ExternalAuthzUser `json:"user,omitempty"`
+}
+
+// ExternalAuthzUser describes the authenticated user of a request
+type ExternalAuthzUser struct {
+	ID          string                 `json:"id"`
+	Roles       []string               `json:"roles,omitempty"`
+	Permissions []string               `json:"permissions,omitempty"`
+	Claims      map[string]interface{} `json:"claims,omitempty"`
+	Attributes  map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// NewExternalAuthorizer creates an authorizer for the configured service
+func NewExternalAuthorizer(cfg *config.ExternalAuthzConfig, extraSkip ...string) (*ExternalAuthorizer, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("external authz url is required")
+	}
+
+	skip := make(map[string]bool, len(externalAuthzSkipHeaders)+len(cfg.ExcludeHeaders)+len(extraSkip))
+	for _, lists := range [][]string{externalAuthzSkipHeaders, cfg.ExcludeHeaders, extraSkip} {
+		for _, name := range lists {
+			skip[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+
+	var cache *policyCache
+	if cfg.CacheTTL > 0 {
+		cache = newPolicyCache(cfg.CacheTTL, defaultDecisionCacheEntries)
+	}
+
+	return &ExternalAuthorizer{
+		config: cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		cache:  cache,
+		skip:   skip,
+		logger: logger.Get().WithComponent("auth.external"),
+	}, nil
+}
+
+// FailOpen reports whether requests are allowed when the service is
+// unavailable
+func (a *ExternalAuthorizer) FailOpen() bool {
+	return a.config.FailureMode == "fail-open"
+}
+
+// Authorize asks the service whether user may make request r. Errors wrap
+// ErrExternalAuthzUnavailable.
+func (a *ExternalAuthorizer) Authorize(r *http.Request, match *router.Match, user *UserContext) (*Decision, error) {
+	input := a.buildInput(r, match, user)
+	body, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExternalAuthzUnavailable, err)
+	}
+
+	cacheKey := hashKey(string(body))
+	if a.cache != nil {
+		if decision, found := a.cache.get(cacheKey); found {
+			metrics.RecordAuthExternal("cache_hit")
+			return decision, nil
+		}
+	}
+
+	decision, err := a.query(r.Context(), body, logger.GetCorrelationID(r.Context()))
+	if err != nil {
+		metrics.RecordAuthExternal("error")
+		a.logger.Warn("external authorization failed", logger.Fields{
+			"path":  r.URL.Path,
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	if decision.Allowed {
+		metrics.RecordAuthExternal("allow")
+	} else {
+		metrics.RecordAuthExternal("deny")
+	}
+
+	if a.cache != nil {
+		a.cache.set(cacheKey, getUserID(user), decision)
+	}
+	return decision, nil
+}
+
+// InvalidateUser drops the cached decisions of userID and returns their
+// number
+func (a *ExternalAuthorizer) InvalidateUser(userID string) int {
+	if a.cache == nil {
+		return 0
+	}
+	return a.cache.invalidateUser(userID)
+}
+
+// InvalidateAll drops all cached decisions and returns their number
+func (a *ExternalAuthorizer) InvalidateAll() int {
+	if a.cache == nil {
+		return 0
+	}
+	return a.cache.clear()
+}
+
+// buildInput describes r for the authorization service
+func (a *ExternalAuthorizer) buildInput(r *http.Request, match *router.Match, user *UserContext) *ExternalAuthzInput {
+	input := &ExternalAuthzInput{
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Query:    r.URL.RawQuery,
+		Route:    match.Route.PathPattern,
+		Params:   match.Params,
+		ClientIP: clientip.FromRequest(r),
+	}
+
+	for name, values := range r.Header {
+		if a.skip[name] {
+			continue
+		}
+		if input.Headers == nil {
+			input.Headers = make(map[string]string, len(r.Header))
+		}
+		input.Headers[strings.ToLower(name)] = strings.Join(values, ", ")
+	}
+
+	if user != nil {
+		input.User = &ExternalAuthzUser{
+			ID:          user.UserID,
+			Roles:       user.Roles,
+			Permissions: user.Permissions,
+			Attributes:  user.Attributes,
+		}
+		if user.Claims != nil {
+			input.User.Claims = user.Claims.Extra
+		}
+	}
+	return input
+}
+
+// query posts the input and parses the decision
+func (a *ExternalAuthorizer) query(ctx context.Context, body []byte, correlationID string) (*Decision, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExternalAuthzUnavailable, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if correlationID != "" {
+		req.Header.Set("X-Correlation-ID", correlationID)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExternalAuthzUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: service returned status %d", ErrExternalAuthzUnavailable, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxExternalAuthzResponse))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExternalAuthzUnavailable, err)
+	}
+	decision, err := parseExternalDecision(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExternalAuthzUnavailable, err)
+	}
+	return decision, nil
+}
+
+// parseExternalDecision accepts OPA responses, {"result": true} or
+// {"result": {"allow": true, "reason": "..."}}, and plain
+// {"allow": true, "reason": "..."} webhook responses
+func parseExternalDecision(data []byte) (*Decision, error) {
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		externalVerdict
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+
+	verdict := response.externalVerdict
+	if len(response.Result) > 0 {
+		var allowed bool
+		if err := json.Unmarshal(response.Result, &allowed); err == nil {
+			verdict = externalVerdict{Allow: &allowed}
+		} else if err := json.Unmarshal(response.Result, &verdict); err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+	}
+	if verdict.Allow == nil {
+		// OPA omits the result when the policy is undefined
+		return nil, fmt.Errorf("response has no decision")
+	}
+
+	decision := &Decision{Allowed: *verdict.Allow, Reason: verdict.Reason}
+	if decision.Reason == "" {
+		decision.Reason = "denied by external policy"
+		if decision.Allowed {
+			decision.Reason = "allowed by external policy"
+		}
+	}
+	return decision, nil
+}
+
+// externalVerdict is the allow/reason object of a decision
+type externalVerdict struct {
+	Allow  *bool  `json:"allow"`
+	Reason string `json:"reason"`
+}
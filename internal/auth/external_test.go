@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+func TestParseExternalDecision(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		allowed     bool
+		reason      string
+		expectError bool
+	}{
+		{"opa boolean", `{"result": true}`, true, "allowed by external policy", false},
+		{"opa object", `{"result": {"allow": false, "reason": "tenant mismatch"}}`, false, "tenant mismatch", false},
+		{"webhook", `{"allow": true, "reason": "owner"}`, true, "owner", false},
+		{"opa undefined", `{}`, false, "", true},
+		{"invalid result", `{"result": "yes"}`, false, "", true},
+		{"invalid json", `allow`, false, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := parseExternalDecision([]byte(tt.body))
+			if (err != nil) != tt.expectError {
+				t.Fatalf("parseExternalDecision() error = %v, expectError %v", err, tt.expectError)
+			}
+			if err != nil {
+				return
+			}
+			if decision.Allowed != tt.allowed || decision.Reason != tt.reason {
+				t.Errorf("Expected allowed=%v reason=%q, got: %+v", tt.allowed, tt.reason, decision)
+			}
+		})
+	}
+}
+
+func TestExternalAuthorizer(t *testing.T) {
+	var calls int32
+	var lastInput ExternalAuthzInput
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		var body struct {
+			Input ExternalAuthzInput `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		lastInput = body.Input
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case body.Input.Path == "/unavailable":
+			w.WriteHeader(http.StatusInternalServerError)
+		case body.Input.User != nil && body.Input.User.ID == body.Input.Params["id"]:
+			w.Write([]byte(`{"result": {"allow": true, "reason": "owner"}}`))
+		default:
+			w.Write([]byte(`{"result": false}`))
+		}
+	}))
+	defer server.Close()
+
+	authz, err := NewExternalAuthorizer(&config.ExternalAuthzConfig{
+		URL:            server.URL,
+		Timeout:        time.Second,
+		FailureMode:    "fail-closed",
+		CacheTTL:       time.Minute,
+		ExcludeHeaders: []string{"X-Internal"},
+	}, "X-API-Key")
+	if err != nil {
+		t.Fatalf("Failed to create authorizer: %v", err)
+	}
+	if authz.FailOpen() {
+		t.Error("Expected fail-closed authorizer")
+	}
+
+	match := &router.Match{
+		Route:  &router.Route{PathPattern: "/api/v1/users/:id"},
+		Params: map[string]string{"id": "user123"},
+	}
+	user := &UserContext{UserID: "user123", Roles: []string{"user"}}
+
+	newRequest := func(path string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("X-API-Key", "key")
+		req.Header.Set("X-Internal", "secret")
+		req.Header.Set("X-Tenant-ID", "acme")
+		return req
+	}
+
+	t.Run("Allow", func(t *testing.T) {
+		decision, err := authz.Authorize(newRequest("/api/v1/users/user123"), match, user)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if !decision.Allowed || decision.Reason != "owner" {
+			t.Errorf("Expected allow by owner, got: %+v", decision)
+		}
+		if lastInput.Route != "/api/v1/users/:id" || lastInput.Method != http.MethodGet {
+			t.Errorf("Unexpected input: %+v", lastInput)
+		}
+	})
+
+	t.Run("SkipsCredentialHeaders", func(t *testing.T) {
+		for _, name := range []string{"authorization", "x-api-key", "x-internal"} {
+			if _, ok := lastInput.Headers[name]; ok {
+				t.Errorf("Expected header %s not to be sent", name)
+			}
+		}
+		if lastInput.Headers["x-tenant-id"] != "acme" {
+			t.Errorf("Expected x-tenant-id header, got: %v", lastInput.Headers)
+		}
+	})
+
+	t.Run("CachesDecisions", func(t *testing.T) {
+		before := atomic.LoadInt32(&calls)
+		if _, err := authz.Authorize(newRequest("/api/v1/users/user123"), match, user); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if got := atomic.LoadInt32(&calls); got != before {
+			t.Errorf("Expected cached decision, got %d service calls", got-before)
+		}
+	})
+
+	t.Run("Deny", func(t *testing.T) {
+		other := &UserContext{UserID: "user456"}
+		decision, err := authz.Authorize(newRequest("/api/v1/users/user123"), match, other)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if decision.Allowed {
+			t.Error("Expected deny for other user")
+		}
+	})
+
+	t.Run("Unavailable", func(t *testing.T) {
+		_, err := authz.Authorize(newRequest("/unavailable"), match, user)
+		if !errors.Is(err, ErrExternalAuthzUnavailable) {
+			t.Errorf("Expected ErrExternalAuthzUnavailable, got: %v", err)
+		}
+	})
+}
@@ -3,9 +3,11 @@ package auth
 import (
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/maltehedderich/api-gateway-go/internal/config"
 	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
 )
 
 // TokenExtractor extracts tokens from HTTP requests
@@ -31,35 +33,87 @@ func NewTokenExtractor(cfg *config.AuthorizationConfig) *TokenExtractor {
 	}
 }
 
-// ExtractToken extracts the session token from the request
-func (te *TokenExtractor) ExtractToken(r *http.Request) (string, error) {
-	// Extract from cookie
-	cookie, err := r.Cookie(te.config.CookieName)
+// defaultTokenSources reads only the session cookie
+var defaultTokenSources = []config.TokenSource{{Type: "cookie"}}
+
+// Sources returns the token sources of route, falling back to the
+// configured ones
+func (te *TokenExtractor) Sources(route *router.Route) []config.TokenSource {
+	if route != nil && len(route.TokenSources) > 0 {
+		return route.TokenSources
+	}
+	if len(te.config.TokenSources) > 0 {
+		return te.config.TokenSources
+	}
+	return defaultTokenSources
+}
+
+// ExtractToken extracts the session token from the request, trying the token
+// sources of route in order
+func (te *TokenExtractor) ExtractToken(r *http.Request, route *router.Route) (string, error) {
+	for _, source := range te.Sources(route) {
+		token, err := te.extractFrom(r, source)
+		if err != nil {
+			return "", err
+		}
+		if token != "" {
+			return token, nil
+		}
+	}
+
+	te.logger.Debug("session token not found", logger.Fields{
+		"sources": te.Sources(route),
+		"path":    r.URL.Path,
+	})
+	return "", &ValidationError{
+		Code:    "missing_token",
+		Message: "Session token is required for this resource",
+	}
+}
+
+// extractFrom returns the token r carries in source, or "" if there is none
+func (te *TokenExtractor) extractFrom(r *http.Request, source config.TokenSource) (string, error) {
+	switch source.Type {
+	case "bearer":
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return "", nil
+		}
+		return strings.TrimSpace(token), nil
+	case "header":
+		return strings.TrimSpace(r.Header.Get(source.Name)), nil
+	case "query":
+		// Browsers cannot set headers on WebSocket handshakes; anywhere else
+		// a token in the URL would leak into logs and Referer headers
+		if !isWebSocketUpgrade(r) {
+			return "", nil
+		}
+		return r.URL.Query().Get(source.Name), nil
+	default:
+		return te.extractCookie(r, source.Name)
+	}
+}
+
+// extractCookie returns the token in the named cookie, or in the session
+// cookie if name is empty
+func (te *TokenExtractor) extractCookie(r *http.Request, name string) (string, error) {
+	if name == "" {
+		name = te.config.CookieName
+	}
+	cookie, err := r.Cookie(name)
 	if err != nil {
 		if err == http.ErrNoCookie {
-			te.logger.Debug("session cookie not found", logger.Fields{
-				"cookie_name": te.config.CookieName,
-				"path":        r.URL.Path,
-			})
-			return "", &ValidationError{
-				Code:    "missing_token",
-				Message: "Session token is required for this resource",
-			}
+			return "", nil
 		}
 		return "", fmt.Errorf("failed to read cookie: %w", err)
 	}
-
-	// Validate cookie value
 	if cookie.Value == "" {
-		return "", &ValidationError{
-			Code:    "missing_token",
-			Message: "Session token is empty",
-		}
+		return "", nil
 	}
 
 	// Log cookie attributes for security validation (in debug mode)
 	te.logger.Debug("session cookie found", logger.Fields{
-		"cookie_name": te.config.CookieName,
+		"cookie_name": name,
 		"secure":      cookie.Secure,
 		"http_only":   cookie.HttpOnly,
 		"same_site":   sameSiteToString(cookie.SameSite),
@@ -74,6 +128,11 @@ func (te *TokenExtractor) ExtractToken(r *http.Request) (string, error) {
 	return cookie.Value, nil
 }
 
+// isWebSocketUpgrade reports whether r is a WebSocket handshake
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
 // sameSiteToString converts SameSite value to string
 func sameSiteToString(sameSite http.SameSite) string {
 	switch sameSite {
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+func TestTokenExtractor_Sources(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+
+	extractor := NewTokenExtractor(&config.AuthorizationConfig{
+		CookieName: "session_token",
+		TokenSources: config.TokenSources{
+			{Type: "bearer"},
+			{Type: "header", Name: "X-Session-Token"},
+			{Type: "cookie"},
+			{Type: "query", Name: "access_token"},
+		},
+	})
+
+	tests := []struct {
+		name     string
+		route    *router.Route
+		target   string
+		headers  map[string]string
+		expected string
+	}{
+		{
+			name:     "bearer wins over cookie",
+			headers:  map[string]string{"Authorization": "bearer from-bearer", "Cookie": "session_token=from-cookie"},
+			expected: "from-bearer",
+		},
+		{
+			name:     "other schemes are ignored",
+			headers:  map[string]string{"Authorization": "Basic dXNlcjpwYXNz", "X-Session-Token": "from-header"},
+			expected: "from-header",
+		},
+		{
+			name:     "cookie",
+			headers:  map[string]string{"Cookie": "session_token=from-cookie"},
+			expected: "from-cookie",
+		},
+		{
+			name:     "query on WebSocket handshake",
+			target:   "/ws?access_token=from-query",
+			headers:  map[string]string{"Upgrade": "websocket", "Connection": "Upgrade"},
+			expected: "from-query",
+		},
+		{
+			name:   "query ignored without upgrade",
+			target: "/ws?access_token=from-query",
+		},
+		{
+			name:     "route sources override",
+			route:    &router.Route{TokenSources: []config.TokenSource{{Type: "cookie", Name: "legacy_session"}}},
+			headers:  map[string]string{"Authorization": "Bearer from-bearer", "Cookie": "legacy_session=from-legacy"},
+			expected: "from-legacy",
+		},
+		{
+			name:    "missing token",
+			route:   &router.Route{TokenSources: []config.TokenSource{{Type: "bearer"}}},
+			headers: map[string]string{"Cookie": "session_token=from-cookie"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := tt.target
+			if target == "" {
+				target = "/api/resource"
+			}
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			token, err := extractor.ExtractToken(req, tt.route)
+			if tt.expected == "" {
+				if verr, ok := err.(*ValidationError); !ok || verr.Code != "missing_token" {
+					t.Fatalf("expected missing_token error, got token %q, error %v", token, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if token != tt.expected {
+				t.Errorf("expected token %q, got %q", tt.expected, token)
+			}
+		})
+	}
+}
+
+func TestTokenExtractor_DefaultSource(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+
+	extractor := NewTokenExtractor(&config.AuthorizationConfig{CookieName: "session_token"})
+	req := httptest.NewRequest(http.MethodGet, "/api/resource", nil)
+	req.Header.Set("Authorization", "Bearer from-bearer")
+	if _, err := extractor.ExtractToken(req, nil); err == nil {
+		t.Error("expected the default sources to ignore bearer tokens")
+	}
+
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: "from-cookie"})
+	token, err := extractor.ExtractToken(req, nil)
+	if err != nil || token != "from-cookie" {
+		t.Errorf("expected cookie token, got %q, error %v", token, err)
+	}
+}
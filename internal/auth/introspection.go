@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+)
+
+// ErrIntrospectionUnavailable is returned when the introspection endpoint
+// cannot be reached or answers with an error
+var ErrIntrospectionUnavailable = errors.New("token introspection unavailable")
+
+// maxIntrospectionResponse caps the size of introspection responses
+const maxIntrospectionResponse = 1 << 20
+
+// Introspector validates opaque access tokens through an OAuth2 token
+// introspection endpoint (RFC 7662). Active results are cached by token
+// hash until the cache TTL or the token's exp, whichever comes first.
+type Introspector struct {
+	config *config.IntrospectionConfig
+	client *http.Client
+	logger *logger.ComponentLogger
+	now    func() time.Time
+
+	mu        sync.Mutex
+	cache     map[string]*introspectionEntry
+	lastSweep time.Time
+}
+
+type introspectionEntry struct {
+	claims    *Claims
+	expiresAt time.Time
+}
+
+// NewIntrospector creates an introspector for the configured endpoint
+func NewIntrospector(cfg *config.IntrospectionConfig) (*Introspector, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("introspection url is required")
+	}
+	return &Introspector{
+		config: cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		logger: logger.Get().WithComponent("auth.introspection"),
+		now:    time.Now,
+		cache:  make(map[string]*introspectionEntry),
+	}, nil
+}
+
+// Handles reports whether token is validated by introspection rather than
+// as a JWT
+func (in *Introspector) Handles(token string) bool {
+	return in.config.Mode == "always" || strings.Count(token, ".") != 2
+}
+
+// Introspect returns the claims of an active token. Inactive tokens and
+// tokens failing the expectations or required claims yield a
+// *ValidationError; endpoint failures wrap ErrIntrospectionUnavailable.
+func (in *Introspector) Introspect(ctx context.Context, token string, expect TokenExpectations, required []string, tolerance time.Duration) (*Claims, error) {
+	key := hashToken(token)
+	claims, cached := in.cached(key)
+	if !cached {
+		var err error
+		claims, err = in.fetch(ctx, token)
+		if err != nil {
+			in.logger.Warn("token introspection failed", logger.Fields{
+				"error": err.Error(),
+			})
+			return nil, err
+		}
+		if claims == nil {
+			return nil, &ValidationError{
+				Code:    "inactive_token",
+				Message: "Token is not active",
+			}
+		}
+		in.store(key, claims)
+	}
+
+	if err := validateExpiration(claims, tolerance); err != nil {
+		return nil, err
+	}
+	if err := validateIssuerAndAudience(claims, expect); err != nil {
+		return nil, err
+	}
+	if err := validateRequiredClaims(claims, required); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// fetch calls the introspection endpoint and returns the claims of an
+// active token, or nil if the token is not active
+func (in *Introspector) fetch(ctx context.Context, token string) (*Claims, error) {
+	form := url.Values{
+		"token":           {token},
+		"token_type_hint": {"access_token"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, in.config.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrIntrospectionUnavailable, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// RFC 6749 section 2.3.1 form-encodes client credentials for basic auth
+	req.SetBasicAuth(url.QueryEscape(in.config.ClientID), url.QueryEscape(in.config.ClientSecret))
+
+	resp, err := in.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrIntrospectionUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: endpoint returned status %d", ErrIntrospectionUnavailable, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxIntrospectionResponse))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrIntrospectionUnavailable, err)
+	}
+
+	var result struct {
+		Active   bool   `json:"active"`
+		Username string `json:"username"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("%w: invalid response: %v", ErrIntrospectionUnavailable, err)
+	}
+	if !result.Active {
+		return nil, nil
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(body, &claims); err != nil {
+		return nil, fmt.Errorf("%w: invalid response: %v", ErrIntrospectionUnavailable, err)
+	}
+	delete(claims.Extra, "active")
+	// Authorization servers identify the user by sub or username rather
+	// than the gateway's user_id claim
+	if claims.UserID == "" {
+		claims.UserID = claims.Subject
+	}
+	if claims.UserID == "" {
+		claims.UserID = result.Username
+	}
+	return &claims, nil
+}
+
+// cached returns the cached claims for a token hash
+func (in *Introspector) cached(key string) (*Claims, bool) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	entry, ok := in.cache[key]
+	if !ok {
+		return nil, false
+	}
+	if !in.now().Before(entry.expiresAt) {
+		delete(in.cache, key)
+		return nil, false
+	}
+	return entry.claims, true
+}
+
+// store caches active claims until the cache TTL or exp, whichever is first
+func (in *Introspector) store(key string, claims *Claims) {
+	if in.config.CacheTTL <= 0 {
+		return
+	}
+	now := in.now()
+	expiresAt := now.Add(in.config.CacheTTL)
+	if claims.ExpiresAt != nil && claims.ExpiresAt.Before(expiresAt) {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	if !now.Before(expiresAt) {
+		return
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	// Drop expired entries once per TTL so tokens seen once do not accumulate
+	if now.Sub(in.lastSweep) >= in.config.CacheTTL {
+		for k, entry := range in.cache {
+			if !now.Before(entry.expiresAt) {
+				delete(in.cache, k)
+			}
+		}
+		in.lastSweep = now
+	}
+	in.cache[key] = &introspectionEntry{claims: claims, expiresAt: expiresAt}
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+func TestIntrospector(t *testing.T) {
+	var calls int32
+	exp := time.Now().Add(time.Hour).Unix()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if id, secret, ok := r.BasicAuth(); !ok || id != "gateway" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.PostFormValue("token") {
+		case "active-token":
+			fmt.Fprintf(w, `{"active":true,"sub":"user123","aud":"api","iss":"https://auth.example.com","scope":"read write","roles":["user"],"exp":%d}`, exp)
+		case "unavailable":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			fmt.Fprint(w, `{"active":false}`)
+		}
+	}))
+	defer server.Close()
+
+	introspector, err := NewIntrospector(&config.IntrospectionConfig{
+		Enabled:      true,
+		URL:          server.URL,
+		ClientID:     "gateway",
+		ClientSecret: "s3cret",
+		Mode:         "opaque",
+		Timeout:      time.Second,
+		CacheTTL:     time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create introspector: %v", err)
+	}
+	ctx := context.Background()
+
+	t.Run("ActiveToken", func(t *testing.T) {
+		claims, err := introspector.Introspect(ctx, "active-token", TokenExpectations{Audiences: []string{"api"}}, []string{"user_id"}, 0)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if claims.UserID != "user123" || len(claims.Roles) != 1 {
+			t.Errorf("Unexpected claims: %+v", claims)
+		}
+		if scope, _ := claims.Claim("scope"); scope != "read write" {
+			t.Errorf("Expected scope claim, got: %v", scope)
+		}
+	})
+
+	t.Run("CachesActiveResults", func(t *testing.T) {
+		before := atomic.LoadInt32(&calls)
+		if _, err := introspector.Introspect(ctx, "active-token", TokenExpectations{}, nil, 0); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if got := atomic.LoadInt32(&calls); got != before {
+			t.Errorf("Expected cached result, got %d endpoint calls", got-before)
+		}
+	})
+
+	t.Run("ChecksExpectationsOnCachedResults", func(t *testing.T) {
+		_, err := introspector.Introspect(ctx, "active-token", TokenExpectations{Issuer: "https://other.example.com"}, nil, 0)
+		if validationCode(err) != "invalid_issuer" {
+			t.Errorf("Expected invalid_issuer, got: %v", err)
+		}
+	})
+
+	t.Run("InactiveToken", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			if _, err := introspector.Introspect(ctx, "revoked-token", TokenExpectations{}, nil, 0); validationCode(err) != "inactive_token" {
+				t.Errorf("Expected inactive_token, got: %v", err)
+			}
+		}
+	})
+
+	t.Run("EndpointFailure", func(t *testing.T) {
+		if _, err := introspector.Introspect(ctx, "unavailable", TokenExpectations{}, nil, 0); !errors.Is(err, ErrIntrospectionUnavailable) {
+			t.Errorf("Expected ErrIntrospectionUnavailable, got: %v", err)
+		}
+	})
+
+	t.Run("CacheHonorsExp", func(t *testing.T) {
+		now := time.Now()
+		introspector.now = func() time.Time { return now }
+		defer func() { introspector.now = time.Now }()
+
+		introspector.store("short-lived", &Claims{
+			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(now.Add(10 * time.Second))},
+		})
+		if _, ok := introspector.cached("short-lived"); !ok {
+			t.Fatal("Expected result to be cached")
+		}
+		now = now.Add(11 * time.Second)
+		if _, ok := introspector.cached("short-lived"); ok {
+			t.Error("Expected cached result to expire with the token")
+		}
+	})
+}
+
+func TestIntrospector_Handles(t *testing.T) {
+	opaque := &Introspector{config: &config.IntrospectionConfig{Mode: "opaque"}}
+	always := &Introspector{config: &config.IntrospectionConfig{Mode: "always"}}
+
+	jwtLike := "eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiJ1In0.c2ln"
+	if opaque.Handles(jwtLike) {
+		t.Error("Expected JWTs to be validated locally in mode opaque")
+	}
+	if !opaque.Handles("2YotnFZFEjr1zCsicMWpAA") {
+		t.Error("Expected opaque tokens to be introspected")
+	}
+	if !always.Handles(jwtLike) {
+		t.Error("Expected every token to be introspected in mode always")
+	}
+}
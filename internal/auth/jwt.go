@@ -1,12 +1,12 @@
 package auth
 
 import (
-	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
+	"crypto"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,11 +17,13 @@ import (
 
 // TokenValidator validates JWT tokens
 type TokenValidator struct {
-	config    *config.AuthorizationConfig
-	logger    *logger.ComponentLogger
-	publicKey *rsa.PublicKey
-	hmacKey   []byte
-	mu        sync.RWMutex
+	config *config.AuthorizationConfig
+	logger *logger.ComponentLogger
+	// publicKeys holds the verification keys by key ID; a PEM key file
+	// yields a single key under the empty ID
+	publicKeys map[string]crypto.PublicKey
+	hmacKey    []byte
+	mu         sync.RWMutex
 }
 
 // Claims represents the JWT claims we expect
@@ -31,6 +33,44 @@ type Claims struct {
 	SessionID   string   `json:"session_id"`
 	Roles       []string `json:"roles"`
 	Permissions []string `json:"permissions"`
+
+	// Extra holds every claim in the token, including custom ones
+	Extra map[string]interface{} `json:"-"`
+}
+
+// UnmarshalJSON decodes the known claims and keeps all claims in Extra
+func (c *Claims) UnmarshalJSON(data []byte) error {
+	type plain Claims
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &c.Extra)
+}
+
+// Claim returns the value of an arbitrary claim
+func (c *Claims) Claim(name string) (interface{}, bool) {
+	value, ok := c.Extra[name]
+	return value, ok
+}
+
+// Scopes returns the OAuth scopes of the token: the space-delimited "scope"
+// claim of RFC 8693, or the "scp" claim as a list or string
+func (c *Claims) Scopes() []string {
+	for _, name := range []string{"scope", "scp"} {
+		switch value := c.Extra[name].(type) {
+		case string:
+			return strings.Fields(value)
+		case []interface{}:
+			scopes := make([]string, 0, len(value))
+			for _, item := range value {
+				if scope, ok := item.(string); ok {
+					scopes = append(scopes, scope)
+				}
+			}
+			return scopes
+		}
+	}
+	return nil
 }
 
 // NewTokenValidator creates a new token validator
@@ -56,16 +96,16 @@ func NewTokenValidator(cfg *config.AuthorizationConfig) (*TokenValidator, error)
 func (tv *TokenValidator) loadSigningKey() error {
 	algo := tv.config.JWTSigningAlgorithm
 
-	// RS* algorithms require public key
-	if algo == "RS256" || algo == "RS384" || algo == "RS512" {
+	switch algo {
+	// RS*, ES* and EdDSA algorithms require public keys
+	case "RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA":
 		if tv.config.JWTPublicKeyFile == "" {
-			return fmt.Errorf("RS* algorithm requires public key file")
+			return fmt.Errorf("%s algorithm requires public key file", algo)
 		}
-		return tv.loadRSAPublicKey(tv.config.JWTPublicKeyFile)
-	}
+		return tv.loadPublicKeys(tv.config.JWTPublicKeyFile)
 
 	// HS* algorithms require shared secret
-	if algo == "HS256" || algo == "HS384" || algo == "HS512" {
+	case "HS256", "HS384", "HS512":
 		if tv.config.JWTSharedSecret == "" {
 			return fmt.Errorf("HS* algorithm requires shared secret")
 		}
@@ -73,50 +113,76 @@ func (tv *TokenValidator) loadSigningKey() error {
 		return nil
 	}
 
-	// ES* algorithms not yet implemented
-	if algo == "ES256" || algo == "ES384" || algo == "ES512" {
-		return fmt.Errorf("ES* algorithms not yet implemented")
-	}
-
 	return fmt.Errorf("unsupported algorithm: %s", algo)
 }
 
-// loadRSAPublicKey loads an RSA public key from a PEM file
-func (tv *TokenValidator) loadRSAPublicKey(path string) error {
-	keyData, err := os.ReadFile(path)
+// loadPublicKeys loads the verification keys from a PEM file or a JWKS
+// document. JWKS keys of other algorithms are ignored.
+func (tv *TokenValidator) loadPublicKeys(path string) error {
+	keys, err := loadPublicKeys(path)
 	if err != nil {
-		return fmt.Errorf("failed to read public key file: %w", err)
-	}
-
-	block, _ := pem.Decode(keyData)
-	if block == nil {
-		return fmt.Errorf("failed to decode PEM block")
+		return err
 	}
 
-	// Try parsing as PKIX public key
-	pubKey, err := x509.ParsePKIXPublicKey(block.Bytes)
-	if err != nil {
-		// Try parsing as PKCS1 public key
-		pubKey, err = x509.ParsePKCS1PublicKey(block.Bytes)
-		if err != nil {
-			return fmt.Errorf("failed to parse public key: %w", err)
+	algo := tv.config.JWTSigningAlgorithm
+	for kid, key := range keys {
+		if keyMatchesAlgorithm(key, algo) {
+			continue
 		}
+		if kid == "" {
+			return fmt.Errorf("public key does not match algorithm %s", algo)
+		}
+		delete(keys, kid)
 	}
-
-	rsaKey, ok := pubKey.(*rsa.PublicKey)
-	if !ok {
-		return fmt.Errorf("public key is not RSA")
+	if len(keys) == 0 {
+		return fmt.Errorf("no public key for algorithm %s", algo)
 	}
 
 	tv.mu.Lock()
-	tv.publicKey = rsaKey
+	tv.publicKeys = keys
 	tv.mu.Unlock()
 
 	return nil
 }
 
-// ValidateToken validates a JWT token and returns the claims
+// TokenExpectations are the issuer and audiences a token must have been
+// issued by and for. Empty values accept any.
+type TokenExpectations struct {
+	Issuer    string
+	Audiences []string
+}
+
+// Expectations returns the configured expectations, overridden by the
+// non-empty route values
+func (tv *TokenValidator) Expectations(issuer string, audiences []string) TokenExpectations {
+	return expectationsFor(tv.config, issuer, audiences)
+}
+
+// expectationsFor returns the expectations of cfg, overridden by the
+// non-empty route values
+func expectationsFor(cfg *config.AuthorizationConfig, issuer string, audiences []string) TokenExpectations {
+	expect := TokenExpectations{
+		Issuer:    cfg.ExpectedIssuer,
+		Audiences: cfg.ExpectedAudiences,
+	}
+	if issuer != "" {
+		expect.Issuer = issuer
+	}
+	if len(audiences) > 0 {
+		expect.Audiences = audiences
+	}
+	return expect
+}
+
+// ValidateToken validates a JWT token against the configured expectations
+// and returns the claims
 func (tv *TokenValidator) ValidateToken(tokenString string) (*Claims, error) {
+	return tv.ValidateTokenFor(tokenString, tv.Expectations("", nil))
+}
+
+// ValidateTokenFor validates a JWT token against the given issuer and
+// audiences and returns the claims
+func (tv *TokenValidator) ValidateTokenFor(tokenString string, expect TokenExpectations) (*Claims, error) {
 	// Parse token
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, tv.keyFunc)
 	if err != nil {
@@ -158,12 +224,17 @@ func (tv *TokenValidator) ValidateToken(tokenString string) (*Claims, error) {
 	}
 
 	// Validate expiration with clock skew tolerance
-	if err := tv.validateExpiration(claims); err != nil {
+	if err := validateExpiration(claims, tv.config.ClockSkewTolerance); err != nil {
+		return nil, err
+	}
+
+	// Validate who issued the token and for whom
+	if err := validateIssuerAndAudience(claims, expect); err != nil {
 		return nil, err
 	}
 
 	// Validate required claims
-	if err := tv.validateRequiredClaims(claims); err != nil {
+	if err := validateRequiredClaims(claims, tv.config.RequiredClaims); err != nil {
 		return nil, err
 	}
 
@@ -187,10 +258,8 @@ func (tv *TokenValidator) keyFunc(token *jwt.Token) (interface{}, error) {
 
 	// Return appropriate key based on algorithm
 	switch expectedMethod {
-	case "RS256", "RS384", "RS512":
-		tv.mu.RLock()
-		defer tv.mu.RUnlock()
-		return tv.publicKey, nil
+	case "RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA":
+		return tv.publicKeyFor(token)
 	case "HS256", "HS384", "HS512":
 		return tv.hmacKey, nil
 	default:
@@ -198,10 +267,30 @@ func (tv *TokenValidator) keyFunc(token *jwt.Token) (interface{}, error) {
 	}
 }
 
+// publicKeyFor selects the verification key by the token's kid header. A
+// single key is used for tokens without a kid, and a PEM key for all tokens.
+func (tv *TokenValidator) publicKeyFor(token *jwt.Token) (crypto.PublicKey, error) {
+	tv.mu.RLock()
+	defer tv.mu.RUnlock()
+
+	if key, ok := tv.publicKeys[""]; ok {
+		return key, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := tv.publicKeys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(tv.publicKeys) == 1 {
+		for _, key := range tv.publicKeys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key ID %q", kid)
+}
+
 // validateExpiration validates token expiration with clock skew tolerance
-func (tv *TokenValidator) validateExpiration(claims *Claims) error {
+func validateExpiration(claims *Claims, tolerance time.Duration) error {
 	now := time.Now()
-	tolerance := tv.config.ClockSkewTolerance
 
 	// Check expiration
 	if claims.ExpiresAt != nil {
@@ -231,9 +320,32 @@ func (tv *TokenValidator) validateExpiration(claims *Claims) error {
 	return nil
 }
 
+// validateIssuerAndAudience checks the iss claim and that aud contains one
+// of the expected audiences
+func validateIssuerAndAudience(claims *Claims, expect TokenExpectations) error {
+	if expect.Issuer != "" && claims.Issuer != expect.Issuer {
+		return &ValidationError{
+			Code:    "invalid_issuer",
+			Message: "Token was not issued by the expected issuer",
+		}
+	}
+	if len(expect.Audiences) == 0 {
+		return nil
+	}
+	for _, audience := range claims.Audience {
+		if slices.Contains(expect.Audiences, audience) {
+			return nil
+		}
+	}
+	return &ValidationError{
+		Code:    "invalid_audience",
+		Message: "Token was not issued for this audience",
+	}
+}
+
 // validateRequiredClaims validates that required claims are present
-func (tv *TokenValidator) validateRequiredClaims(claims *Claims) error {
-	for _, requiredClaim := range tv.config.RequiredClaims {
+func validateRequiredClaims(claims *Claims, required []string) error {
+	for _, requiredClaim := range required {
 		switch requiredClaim {
 		case "user_id":
 			if claims.UserID == "" {
@@ -263,6 +375,13 @@ func (tv *TokenValidator) validateRequiredClaims(claims *Claims) error {
 					Message: fmt.Sprintf("Required claim missing: %s", requiredClaim),
 				}
 			}
+		default:
+			if value, ok := claims.Claim(requiredClaim); !ok || value == nil {
+				return &ValidationError{
+					Code:    "missing_claim",
+					Message: fmt.Sprintf("Required claim missing: %s", requiredClaim),
+				}
+			}
 		}
 	}
 
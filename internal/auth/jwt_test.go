@@ -164,6 +164,44 @@ func TestTokenValidator_ValidateToken(t *testing.T) {
 	})
 }
 
+func TestClaims_Extra(t *testing.T) {
+	cfg := &config.AuthorizationConfig{
+		JWTSigningAlgorithm: "HS256",
+		JWTSharedSecret:     "test-secret-key-for-hmac",
+		ClockSkewTolerance:  5 * time.Second,
+	}
+
+	validator, err := NewTokenValidator(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create validator: %v", err)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp":       time.Now().Add(1 * time.Hour).Unix(),
+		"user_id":   "user123",
+		"synthetic": true,
+	})
+	tokenString, err := token.SignedString([]byte(cfg.JWTSharedSecret))
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+
+	claims, err := validator.ValidateToken(tokenString)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if claims.UserID != "user123" {
+		t.Errorf("Expected UserID user123, got: %s", claims.UserID)
+	}
+	if value, ok := claims.Claim("synthetic"); !ok || value != true {
+		t.Errorf("Expected custom claim synthetic=true, got: %v", value)
+	}
+	if _, ok := claims.Claim("missing"); ok {
+		t.Error("Expected missing claim to be absent")
+	}
+}
+
 func TestTokenValidator_HMAC(t *testing.T) {
 	// Create validator with HMAC
 	cfg := &config.AuthorizationConfig{
@@ -261,3 +299,62 @@ func writePublicKeyToTempFile(t *testing.T, publicKey *rsa.PublicKey) string {
 
 	return tmpFile
 }
+
+func TestTokenValidator_IssuerAndAudience(t *testing.T) {
+	cfg := &config.AuthorizationConfig{
+		JWTSigningAlgorithm: "HS256",
+		JWTSharedSecret:     "test-secret-key-for-hmac",
+		ExpectedIssuer:      "https://idp.example.com",
+		ExpectedAudiences:   []string{"gateway", "api"},
+	}
+	validator, err := NewTokenValidator(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create validator: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		issuer        string
+		audience      []string
+		routeIssuer   string
+		routeAudience []string
+		expectedCode  string
+	}{
+		{name: "matching issuer and audience", issuer: "https://idp.example.com", audience: []string{"other", "api"}},
+		{name: "wrong issuer", issuer: "https://evil.example.com", audience: []string{"api"}, expectedCode: "invalid_issuer"},
+		{name: "missing audience", issuer: "https://idp.example.com", expectedCode: "invalid_audience"},
+		{name: "wrong audience", issuer: "https://idp.example.com", audience: []string{"billing"}, expectedCode: "invalid_audience"},
+		{name: "route audience override", issuer: "https://idp.example.com", audience: []string{"billing"}, routeAudience: []string{"billing"}},
+		{name: "route override replaces defaults", issuer: "https://idp.example.com", audience: []string{"api"}, routeAudience: []string{"billing"}, expectedCode: "invalid_audience"},
+		{name: "route issuer override", issuer: "https://partner.example.com", audience: []string{"api"}, routeIssuer: "https://partner.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := &Claims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+					Issuer:    tt.issuer,
+					Audience:  tt.audience,
+				},
+				UserID: "user123",
+			}
+			tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.JWTSharedSecret))
+			if err != nil {
+				t.Fatalf("Failed to sign token: %v", err)
+			}
+
+			_, err = validator.ValidateTokenFor(tokenString, validator.Expectations(tt.routeIssuer, tt.routeAudience))
+			if tt.expectedCode == "" {
+				if err != nil {
+					t.Errorf("Expected valid token, got: %v", err)
+				}
+				return
+			}
+			valErr, ok := err.(*ValidationError)
+			if !ok || valErr.Code != tt.expectedCode {
+				t.Errorf("Expected %s, got: %v", tt.expectedCode, err)
+			}
+		})
+	}
+}
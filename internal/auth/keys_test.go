@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+func TestTokenValidator_AsymmetricAlgorithms(t *testing.T) {
+	ecKey := func(curve elliptic.Curve) crypto.Signer {
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to generate EC key: %v", err)
+		}
+		return key
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		method jwt.SigningMethod
+		key    crypto.Signer
+	}{
+		{"ES256", jwt.SigningMethodES256, ecKey(elliptic.P256())},
+		{"ES384", jwt.SigningMethodES384, ecKey(elliptic.P384())},
+		{"ES512", jwt.SigningMethodES512, ecKey(elliptic.P521())},
+		{"EdDSA", jwt.SigningMethodEdDSA, edKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for format, path := range map[string]string{
+				"pem":  writePEMKey(t, tt.key.Public()),
+				"jwks": writeJWKS(t, map[string]crypto.PublicKey{"key-1": tt.key.Public()}),
+			} {
+				validator, err := NewTokenValidator(&config.AuthorizationConfig{
+					JWTSigningAlgorithm: tt.method.Alg(),
+					JWTPublicKeyFile:    path,
+				})
+				if err != nil {
+					t.Fatalf("%s: failed to create validator: %v", format, err)
+				}
+
+				token := signTestToken(t, tt.method, tt.key, "key-1")
+				if _, err := validator.ValidateToken(token); err != nil {
+					t.Errorf("%s: expected valid token, got %v", format, err)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenValidator_JWKSKeySelection(t *testing.T) {
+	first, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	second, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	other, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+
+	path := writeJWKS(t, map[string]crypto.PublicKey{
+		"first":  first.Public(),
+		"second": second.Public(),
+		"other":  other.Public(),
+	})
+	validator, err := NewTokenValidator(&config.AuthorizationConfig{
+		JWTSigningAlgorithm: "ES256",
+		JWTPublicKeyFile:    path,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create validator: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		key         crypto.Signer
+		kid         string
+		expectValid bool
+	}{
+		{"first key", first, "first", true},
+		{"second key", second, "second", true},
+		{"wrong key for kid", first, "second", false},
+		{"unknown kid", first, "missing", false},
+		{"key of other algorithm is ignored", other, "other", false},
+		{"no kid with several keys", first, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := jwt.SigningMethodES256
+			if tt.key == other {
+				method = jwt.SigningMethodES384
+			}
+			_, err := validator.ValidateToken(signTestToken(t, method, tt.key, tt.kid))
+			if (err == nil) != tt.expectValid {
+				t.Errorf("expected valid %v, got error %v", tt.expectValid, err)
+			}
+		})
+	}
+}
+
+func TestLoadSigningKey_Mismatch(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	path := writePEMKey(t, key.Public())
+
+	for _, algo := range []string{"ES256", "RS256", "EdDSA"} {
+		_, err := NewTokenValidator(&config.AuthorizationConfig{
+			JWTSigningAlgorithm: algo,
+			JWTPublicKeyFile:    path,
+		})
+		if err == nil {
+			t.Errorf("%s: expected error for P-384 key", algo)
+		}
+	}
+}
+
+func signTestToken(t *testing.T, method jwt.SigningMethod, key crypto.Signer, kid string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		UserID: "user123",
+	})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func writePEMKey(t *testing.T, key crypto.PublicKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal public key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "public_key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600); err != nil {
+		t.Fatalf("Failed to write public key file: %v", err)
+	}
+	return path
+}
+
+func writeJWKS(t *testing.T, keys map[string]crypto.PublicKey) string {
+	t.Helper()
+	encode := base64.RawURLEncoding.EncodeToString
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	for kid, key := range keys {
+		k := jwk{Kid: kid, Use: "sig"}
+		switch key := key.(type) {
+		case *ecdsa.PublicKey:
+			size := (key.Curve.Params().BitSize + 7) / 8
+			k.Kty, k.Crv = "EC", key.Curve.Params().Name
+			k.X, k.Y = encode(key.X.FillBytes(make([]byte, size))), encode(key.Y.FillBytes(make([]byte, size)))
+		case ed25519.PublicKey:
+			k.Kty, k.Crv, k.X = "OKP", "Ed25519", encode(key)
+		default:
+			t.Fatalf("unsupported key type %T", key)
+		}
+		set.Keys = append(set.Keys, k)
+	}
+	// An encryption key must be skipped
+	set.Keys = append(set.Keys, jwk{Kty: "RSA", Kid: "enc", Use: "enc"})
+
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("Failed to marshal JWKS: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("Failed to write JWKS file: %v", err)
+	}
+	return path
+}
@@ -1,13 +1,18 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/maltehedderich/api-gateway-go/internal/config"
 	"github.com/maltehedderich/api-gateway-go/internal/logger"
 	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+	"github.com/maltehedderich/api-gateway-go/internal/middleware"
 	"github.com/maltehedderich/api-gateway-go/internal/router"
 )
 
@@ -17,8 +22,20 @@ type Middleware struct {
 	logger            *logger.ComponentLogger
 	extractor         *TokenExtractor
 	validator         *TokenValidator
+	providers         map[string]*TokenValidator
 	revocationChecker *RevocationChecker
+	replayGuard       *ReplayGuard
 	policyEvaluator   *PolicyEvaluator
+	enricher          *ClaimsEnricher
+	decisionLog       *DecisionLogger
+	apiKeys           *APIKeyManager
+	introspector      *Introspector
+	externalAuthz     *ExternalAuthorizer
+	verifier          *RequestVerifier
+	basicAuth         *BasicAuthenticator
+	bypass            *Bypass
+	services          map[string]config.ServiceIdentityConfig
+	isExempt          func(path string) bool
 	enabled           bool
 }
 
@@ -35,21 +52,115 @@ func NewMiddleware(cfg *config.AuthorizationConfig) (*Middleware, error) {
 	// Create components
 	extractor := NewTokenExtractor(cfg)
 
-	validator, err := NewTokenValidator(cfg)
+	// Without JWT validation every token is introspected
+	var validator *TokenValidator
+	var err error
+	if !cfg.Introspection.IntrospectsAll() {
+		validator, err = NewTokenValidator(cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	providers, err := newProviderValidators(cfg)
 	if err != nil {
 		return nil, err
 	}
 
+	var introspector *Introspector
+	if cfg.Introspection.Enabled {
+		introspector, err = NewIntrospector(&cfg.Introspection)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	revocationChecker := NewRevocationChecker(cfg)
 	policyEvaluator := NewPolicyEvaluator(cfg.CacheAuthDecisions, cfg.CacheDecisionTTL)
+	policyEvaluator.SetCacheSize(cfg.CacheDecisionMaxEntries)
+	if len(cfg.RoleHierarchy) > 0 {
+		policyEvaluator.SetRoleHierarchy(NewRoleHierarchy(cfg.RoleHierarchy))
+	}
+	location, err := time.LoadLocation(cfg.ConditionTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition timezone: %w", err)
+	}
+	policyEvaluator.SetConditionLocation(location)
+
+	var enricher *ClaimsEnricher
+	if cfg.Enrichment.Enabled {
+		enricher, err = NewClaimsEnricher(&cfg.Enrichment)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var decisionLog *DecisionLogger
+	if cfg.DecisionLog.Enabled {
+		decisionLog, err = NewDecisionLogger(&cfg.DecisionLog)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var apiKeys *APIKeyManager
+	if cfg.APIKeys.Enabled {
+		apiKeys, err = NewAPIKeyManager(&cfg.APIKeys)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var externalAuthz *ExternalAuthorizer
+	if cfg.ExternalAuthz.URL != "" {
+		var skip []string
+		if apiKeys != nil {
+			skip = append(skip, apiKeys.Header())
+		}
+		externalAuthz, err = NewExternalAuthorizer(&cfg.ExternalAuthz, skip...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var verifier *RequestVerifier
+	if cfg.RequestSigning.Enabled {
+		verifier = NewRequestVerifier(&cfg.RequestSigning)
+	}
+
+	var basicAuth *BasicAuthenticator
+	if cfg.BasicAuth.Enabled {
+		basicAuth, err = NewBasicAuthenticator(&cfg.BasicAuth)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var bypass *Bypass
+	if cfg.Bypass.Enabled {
+		bypass, err = NewBypass(&cfg.Bypass)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return &Middleware{
 		config:            cfg,
 		logger:            logger.Get().WithComponent("auth.middleware"),
 		extractor:         extractor,
 		validator:         validator,
+		providers:         providers,
 		revocationChecker: revocationChecker,
+		replayGuard:       NewReplayGuard(cfg),
 		policyEvaluator:   policyEvaluator,
+		enricher:          enricher,
+		decisionLog:       decisionLog,
+		apiKeys:           apiKeys,
+		introspector:      introspector,
+		externalAuthz:     externalAuthz,
+		verifier:          verifier,
+		basicAuth:         basicAuth,
+		bypass:            bypass,
 		enabled:           true,
 	}, nil
 }
@@ -63,11 +174,13 @@ func (m *Middleware) Handler(next http.Handler) http.Handler {
 			return
 		}
 
+		start := time.Now()
+
 		// Get route match from context to determine policy
-		routeMatch := getRouteFromContext(r)
-		if routeMatch == nil {
+		match := getMatchFromContext(r)
+		if match == nil {
 			// No route match - this should not happen, but allow for health checks
-			if isHealthCheckPath(r.URL.Path, m.config) {
+			if m.isExempt != nil && m.isExempt(r.URL.Path) {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -81,73 +194,76 @@ func (m *Middleware) Handler(next http.Handler) http.Handler {
 		}
 
 		// Build policy from route configuration
-		policy := m.buildPolicy(routeMatch)
+		policy := m.buildPolicy(match.Route)
 
-		// For public routes, skip token validation
+		// For public routes, skip token validation; callers presenting
+		// credentials may still be identified
 		if policy.Type == PolicyPublic {
 			m.logger.Debug("public route, skipping authorization", logger.Fields{
 				"path": r.URL.Path,
 			})
 			metrics.RecordAuthAttempt("bypass")
-			next.ServeHTTP(w, r)
+			ctx := SetPolicyResult(r.Context(), string(policy.Type))
+			var userCtx *UserContext
+			if m.config.IdentifyPublicRequests {
+				if userCtx = m.identifyCaller(r, match); userCtx != nil {
+					ctx = SetUserContext(ctx, userCtx)
+					middleware.MarkAuthenticated(ctx)
+				}
+			}
+			m.logDecision(r, match, policy, userCtx, true, "", "public route", start)
+			if m.apiKeys != nil {
+				// Keys are credentials; backends of public routes must not see them
+				r.Header.Del(m.apiKeys.Header())
+			}
+			if m.bypass != nil {
+				r.Header.Del(m.bypass.Header())
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
 			return
 		}
 
-		// Extract token
-		tokenString, err := m.extractor.ExtractToken(r)
-		if err != nil {
-			metrics.RecordAuthAttempt("failure")
-			metrics.RecordAuthFailure("missing_token")
-			m.handleAuthError(w, r, err, "token extraction failed")
-			return
+		// Admit trusted sources; their secret never reaches the backend
+		if m.bypass != nil {
+			userCtx := m.authenticateBypass(r, match, policy, start)
+			r.Header.Del(m.bypass.Header())
+			if userCtx != nil {
+				ctx := SetUserContext(r.Context(), userCtx)
+				ctx = SetPolicyResult(ctx, string(policy.Type))
+				middleware.MarkAuthenticated(ctx)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
 		}
 
-		// Validate token
-		validationStart := time.Now()
-		claims, err := m.validator.ValidateToken(tokenString)
-		metrics.RecordAuthValidationDuration(time.Since(validationStart))
-
-		if err != nil {
-			metrics.RecordAuthAttempt("failure")
-			// Determine error type from validation error
-			if valErr, ok := err.(*ValidationError); ok {
-				switch valErr.Code {
-				case "token_expired":
-					metrics.RecordAuthFailure("expired_token")
-				case "invalid_token":
-					metrics.RecordAuthFailure("invalid_token")
-				default:
-					metrics.RecordAuthFailure("invalid_token")
-				}
-			} else {
-				metrics.RecordAuthFailure("invalid_token")
-			}
-			m.handleAuthError(w, r, err, "token validation failed")
+		// Authenticate calling services by their client certificate, then
+		// with an API key if one is presented, otherwise with the session
+		// token. Client-cert, signed and basic routes accept only their
+		// credential, and one-time-token routes only session tokens.
+		var userCtx *UserContext
+		ok := true
+		if service := GetServiceIdentity(r.Context()); service != "" {
+			userCtx = m.authenticateService(r, match, service)
+		} else if policy.Type == PolicyClientCert {
+			userCtx, ok = m.authenticateClientCert(w, r, match, policy, start)
+		} else if policy.Type == PolicySigned && m.verifier != nil {
+			userCtx, ok = m.authenticateSignature(w, r, match, policy, start)
+		} else if policy.Type == PolicyBasic && m.basicAuth != nil {
+			userCtx, ok = m.authenticateBasic(w, r, match, policy, start)
+		} else if m.apiKeys != nil && !match.Route.OneTimeToken && r.Header.Get(m.apiKeys.Header()) != "" {
+			userCtx, ok = m.authenticateAPIKey(w, r, match, policy, start)
+		} else {
+			userCtx, ok = m.authenticateToken(w, r, match, policy, start)
+		}
+		if !ok {
 			return
 		}
 
-		// Check revocation
-		revoked, err := m.revocationChecker.IsRevoked(r.Context(), claims.SessionID)
-		if err != nil {
-			m.logger.Warn("revocation check failed, allowing request", logger.Fields{
-				"session_id": maskSessionID(claims.SessionID),
-				"error":      err.Error(),
-			})
-			// Continue despite revocation check failure (fail-open)
-		} else if revoked {
-			m.logger.Info("token revoked", logger.Fields{
-				"user_id":    claims.UserID,
-				"session_id": maskSessionID(claims.SessionID),
-			})
-			metrics.RecordAuthAttempt("failure")
-			metrics.RecordAuthFailure("revoked_token")
-			m.writeError(w, r, http.StatusUnauthorized, "token_revoked", "Session token has been revoked", nil)
+		// Sensitive routes may require a stronger authentication
+		if !m.checkStepUp(w, r, match, policy, userCtx, start) {
 			return
 		}
 
-		// Create user context
-		userCtx := NewUserContext(claims)
-
 		// Evaluate policy
 		decision, err := m.policyEvaluator.Evaluate(policy, userCtx)
 		if err != nil {
@@ -161,37 +277,392 @@ func (m *Middleware) Handler(next http.Handler) http.Handler {
 		// Check authorization decision
 		if !decision.Allowed {
 			m.logger.Info("authorization denied", logger.Fields{
-				"user_id":     claims.UserID,
+				"user_id":     userCtx.UserID,
 				"path":        r.URL.Path,
 				"reason":      decision.Reason,
 				"policy_type": policy.Type,
 			})
 			metrics.RecordAuthAttempt("failure")
 			metrics.RecordAuthFailure("insufficient_permissions")
+			m.logDecision(r, match, policy, userCtx, false, policyRule(policy), decision.Reason, start)
 			m.writeError(w, r, http.StatusForbidden, "forbidden", decision.Reason, decision.Details)
 			return
 		}
 
+		// Check attribute-based conditions, e.g. tenant isolation
+		if len(policy.Conditions) > 0 {
+			decision = m.policyEvaluator.EvaluateConditions(policy, userCtx, r, match.Params)
+			if !decision.Allowed {
+				m.logger.Info("authorization denied by condition", logger.Fields{
+					"user_id": userCtx.UserID,
+					"path":    r.URL.Path,
+					"reason":  decision.Reason,
+				})
+				metrics.RecordAuthAttempt("failure")
+				metrics.RecordAuthFailure("condition_not_met")
+				rule, _ := decision.Details["condition"].(string)
+				m.logDecision(r, match, policy, userCtx, false, rule, decision.Reason, start)
+				m.writeError(w, r, http.StatusForbidden, "forbidden", decision.Reason, decision.Details)
+				return
+			}
+		}
+
+		// Ask the external authorization service
+		if policy.Type == PolicyExternal {
+			if decision, ok = m.authorizeExternal(w, r, match, policy, userCtx, start); !ok {
+				return
+			}
+		}
+
 		// Store user context in request context
 		ctx := SetUserContext(r.Context(), userCtx)
+		ctx = SetPolicyResult(ctx, string(policy.Type))
 
 		// Log successful authorization
 		m.logger.Info("authorization successful", logger.Fields{
-			"user_id":     claims.UserID,
-			"session_id":  maskSessionID(claims.SessionID),
+			"user_id":     userCtx.UserID,
+			"session_id":  maskSessionID(userCtx.SessionID),
 			"path":        r.URL.Path,
-			"roles":       claims.Roles,
+			"roles":       userCtx.Roles,
 			"policy_type": policy.Type,
 		})
 
 		// Record successful authorization
 		metrics.RecordAuthAttempt("success")
+		middleware.MarkAuthenticated(ctx)
+		m.logDecision(r, match, policy, userCtx, true, policyRule(policy), decision.Reason, start)
 
 		// Call next handler with updated context
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// authenticateToken validates the session token of r and returns the user.
+// It writes the error response and returns false if the request is rejected.
+func (m *Middleware) authenticateToken(w http.ResponseWriter, r *http.Request, match *router.Match, policy *Policy, start time.Time) (*UserContext, bool) {
+	// Extract token
+	tokenString, err := m.extractor.ExtractToken(r, match.Route)
+	if err != nil {
+		metrics.RecordAuthAttempt("failure")
+		metrics.RecordAuthFailure("missing_token")
+		m.logDecision(r, match, policy, nil, false, "", "missing token", start)
+		m.handleAuthError(w, r, err, "token extraction failed")
+		return nil, false
+	}
+
+	// Validate token
+	validationStart := time.Now()
+	claims, err := m.validateRouteToken(r.Context(), match.Route, tokenString)
+	metrics.RecordAuthValidationDuration(time.Since(validationStart))
+
+	if errors.Is(err, ErrIntrospectionUnavailable) {
+		metrics.RecordAuthAttempt("failure")
+		metrics.RecordAuthFailure("introspection_unavailable")
+		m.logDecision(r, match, policy, nil, false, "", "token introspection unavailable", start)
+		m.writeError(w, r, http.StatusServiceUnavailable, "introspection_unavailable", "Token validation is temporarily unavailable", nil)
+		return nil, false
+	}
+	if err != nil {
+		metrics.RecordAuthAttempt("failure")
+		// Determine error type from validation error
+		if valErr, ok := err.(*ValidationError); ok {
+			switch valErr.Code {
+			case "token_expired":
+				metrics.RecordAuthFailure("expired_token")
+			case "invalid_token":
+				metrics.RecordAuthFailure("invalid_token")
+			case "invalid_issuer", "invalid_audience", "inactive_token":
+				metrics.RecordAuthFailure(valErr.Code)
+			default:
+				metrics.RecordAuthFailure("invalid_token")
+			}
+		} else {
+			metrics.RecordAuthFailure("invalid_token")
+		}
+		m.logDecision(r, match, policy, nil, false, "", err.Error(), start)
+		m.handleAuthError(w, r, err, "token validation failed")
+		return nil, false
+	}
+
+	// Check revocation
+	revoked, err := m.revocationChecker.IsRevoked(r.Context(), claims.SessionID, claims.ID)
+	if err != nil && m.revocationChecker.FailClosed() {
+		m.logger.Error("revocation check failed, rejecting request", logger.Fields{
+			"session_id": maskSessionID(claims.SessionID),
+			"error":      err.Error(),
+		})
+		metrics.RecordAuthAttempt("failure")
+		metrics.RecordAuthFailure("revocation_unavailable")
+		m.logDecision(r, match, policy, nil, false, "", "revocation check unavailable", start)
+		m.writeError(w, r, http.StatusServiceUnavailable, "revocation_unavailable", "Token revocation cannot be verified right now", nil)
+		return nil, false
+	} else if err != nil {
+		m.logger.Warn("revocation check failed, allowing request", logger.Fields{
+			"session_id": maskSessionID(claims.SessionID),
+			"error":      err.Error(),
+		})
+		// Continue despite revocation check failure (fail-open)
+	} else if revoked {
+		m.logger.Info("token revoked", logger.Fields{
+			"user_id":    claims.UserID,
+			"session_id": maskSessionID(claims.SessionID),
+		})
+		metrics.RecordAuthAttempt("failure")
+		metrics.RecordAuthFailure("revoked_token")
+		m.logDecision(r, match, policy, NewUserContext(claims), false, "", "token revoked", start)
+		m.writeError(w, r, http.StatusUnauthorized, "token_revoked", "Session token has been revoked", nil)
+		return nil, false
+	}
+
+	// One-time-token routes accept each token only once
+	if match.Route.OneTimeToken && !m.checkReplay(w, r, match, policy, claims, start) {
+		return nil, false
+	}
+
+	// Create user context
+	userCtx := NewUserContext(claims)
+
+	// Load additional attributes from the user store
+	if m.enricher != nil {
+		if err := m.enricher.Enrich(r.Context(), userCtx); err != nil {
+			if m.enricher.FailClosed() {
+				m.logger.Error("claims enrichment failed, rejecting request", logger.Fields{
+					"user_id": claims.UserID,
+					"error":   err.Error(),
+				})
+				metrics.RecordAuthAttempt("failure")
+				m.logDecision(r, match, policy, userCtx, false, "", "user attributes unavailable", start)
+				m.writeError(w, r, http.StatusServiceUnavailable, "enrichment_unavailable", "User attributes are temporarily unavailable", nil)
+				return nil, false
+			}
+			m.logger.Warn("claims enrichment failed, continuing with token claims", logger.Fields{
+				"user_id": claims.UserID,
+				"error":   err.Error(),
+			})
+		}
+	}
+
+	return userCtx, true
+}
+
+// validateRouteToken validates a token against the route's auth provider, or
+// the authorization defaults, with the route's issuer and audiences
+func (m *Middleware) validateRouteToken(ctx context.Context, route *router.Route, token string) (*Claims, error) {
+	if provider, ok := m.providers[route.AuthProvider]; ok {
+		return provider.ValidateTokenFor(token, provider.Expectations(route.ExpectedIssuer, route.ExpectedAudiences))
+	}
+	expect := expectationsFor(m.config, route.ExpectedIssuer, route.ExpectedAudiences)
+	return m.validateToken(ctx, token, expect)
+}
+
+// validateToken validates a JWT locally, or an opaque token, or any token
+// in introspection mode always, through the introspection endpoint
+func (m *Middleware) validateToken(ctx context.Context, token string, expect TokenExpectations) (*Claims, error) {
+	if m.introspector != nil && (m.validator == nil || m.introspector.Handles(token)) {
+		return m.introspector.Introspect(ctx, token, expect, m.config.RequiredClaims, m.config.ClockSkewTolerance)
+	}
+	return m.validator.ValidateTokenFor(token, expect)
+}
+
+// authenticateAPIKey authenticates r by its API key and returns the key's
+// user. The key is removed from the request so it never reaches the backend.
+func (m *Middleware) authenticateAPIKey(w http.ResponseWriter, r *http.Request, match *router.Match, policy *Policy, start time.Time) (*UserContext, bool) {
+	presented := r.Header.Get(m.apiKeys.Header())
+	r.Header.Del(m.apiKeys.Header())
+
+	validationStart := time.Now()
+	user, err := m.apiKeys.Authenticate(r.Context(), presented)
+	metrics.RecordAuthValidationDuration(time.Since(validationStart))
+
+	if err != nil {
+		metrics.RecordAuthAttempt("failure")
+		if valErr, ok := err.(*ValidationError); ok {
+			metrics.RecordAuthFailure(valErr.Code)
+			m.logDecision(r, match, policy, nil, false, "", err.Error(), start)
+			m.handleAuthError(w, r, err, "api key validation failed")
+			return nil, false
+		}
+		m.logger.Error("api key lookup failed", logger.Fields{
+			"error": err.Error(),
+		})
+		m.logDecision(r, match, policy, nil, false, "", "api key store unavailable", start)
+		m.writeError(w, r, http.StatusServiceUnavailable, "api_key_store_unavailable", "API keys cannot be verified right now", nil)
+		return nil, false
+	}
+	return user, true
+}
+
+// identifyCaller authenticates the caller of a public route by its service
+// identity, API key or session token, if it presented one. It returns nil
+// for anonymous callers and for credentials that fail any check, which are
+// served anonymously rather than rejected.
+func (m *Middleware) identifyCaller(r *http.Request, match *router.Match) *UserContext {
+	if service := GetServiceIdentity(r.Context()); service != "" {
+		return m.authenticateService(r, match, service)
+	}
+
+	if m.apiKeys != nil {
+		if presented := r.Header.Get(m.apiKeys.Header()); presented != "" {
+			user, err := m.apiKeys.Authenticate(r.Context(), presented)
+			if err != nil {
+				m.logger.Debug("api key on public route not accepted, serving anonymously", logger.Fields{
+					"path":  r.URL.Path,
+					"error": err.Error(),
+				})
+				return nil
+			}
+			return user
+		}
+	}
+
+	tokenString, err := m.extractor.ExtractToken(r, match.Route)
+	if err != nil {
+		return nil
+	}
+	claims, err := m.validateRouteToken(r.Context(), match.Route, tokenString)
+	if err != nil {
+		m.logger.Debug("token on public route not accepted, serving anonymously", logger.Fields{
+			"path":  r.URL.Path,
+			"error": err.Error(),
+		})
+		return nil
+	}
+
+	revoked, err := m.revocationChecker.IsRevoked(r.Context(), claims.SessionID, claims.ID)
+	if revoked || (err != nil && m.revocationChecker.FailClosed()) {
+		return nil
+	}
+
+	userCtx := NewUserContext(claims)
+	if m.enricher != nil {
+		if err := m.enricher.Enrich(r.Context(), userCtx); err != nil && m.enricher.FailClosed() {
+			return nil
+		}
+	}
+	return userCtx
+}
+
+// Close releases the connections of the revocation denylist and replay store
+func (m *Middleware) Close() error {
+	if m.revocationChecker == nil {
+		return nil
+	}
+	return errors.Join(m.revocationChecker.Close(), m.replayGuard.Close())
+}
+
+// InvalidateDecisions drops the cached authorization decisions of userID, or
+// all cached decisions if userID is empty, so that changed roles take effect
+// immediately. It returns the number of dropped decisions.
+func (m *Middleware) InvalidateDecisions(userID string) int {
+	if m.policyEvaluator == nil {
+		return 0
+	}
+	removed := 0
+	if userID == "" {
+		removed = m.policyEvaluator.InvalidateAll()
+		if m.externalAuthz != nil {
+			removed += m.externalAuthz.InvalidateAll()
+		}
+	} else {
+		removed = m.policyEvaluator.InvalidateUser(userID)
+		if m.externalAuthz != nil {
+			removed += m.externalAuthz.InvalidateUser(userID)
+		}
+	}
+	m.logger.Info("authorization decisions invalidated", logger.Fields{
+		"user_id": userID,
+		"removed": removed,
+	})
+	return removed
+}
+
+// SetExemptPaths sets the check for gateway endpoints, such as health
+// checks, that are served without a route and without authorization
+func (m *Middleware) SetExemptPaths(isExempt func(path string) bool) {
+	m.isExempt = isExempt
+}
+
+// APIKeys returns the API key manager, or nil if API keys are disabled
+func (m *Middleware) APIKeys() *APIKeyManager {
+	return m.apiKeys
+}
+
+// authorizeExternal asks the external authorization service to decide r.
+// It writes the error response and returns false if the request is rejected.
+func (m *Middleware) authorizeExternal(w http.ResponseWriter, r *http.Request, match *router.Match, policy *Policy, user *UserContext, start time.Time) (*Decision, bool) {
+	if m.externalAuthz == nil {
+		m.logger.Error("external auth policy without external authorization service", logger.Fields{
+			"path": r.URL.Path,
+		})
+		m.writeError(w, r, http.StatusInternalServerError, "internal_error", "Internal server error", nil)
+		return nil, false
+	}
+
+	decision, err := m.externalAuthz.Authorize(r, match, user)
+	if err != nil {
+		if m.externalAuthz.FailOpen() {
+			return &Decision{Allowed: true, Reason: "external authorization unavailable, failing open"}, true
+		}
+		metrics.RecordAuthAttempt("failure")
+		metrics.RecordAuthFailure("external_authz_unavailable")
+		m.logDecision(r, match, policy, user, false, "external", "external authorization unavailable", start)
+		m.writeError(w, r, http.StatusServiceUnavailable, "authz_unavailable", "Authorization service unavailable", nil)
+		return nil, false
+	}
+
+	if !decision.Allowed {
+		m.logger.Info("authorization denied by external policy", logger.Fields{
+			"user_id": user.UserID,
+			"path":    r.URL.Path,
+			"reason":  decision.Reason,
+		})
+		metrics.RecordAuthAttempt("failure")
+		metrics.RecordAuthFailure("external_denied")
+		m.logDecision(r, match, policy, user, false, "external", decision.Reason, start)
+		m.writeError(w, r, http.StatusForbidden, "forbidden", decision.Reason, decision.Details)
+		return nil, false
+	}
+	return decision, true
+}
+
+// logDecision writes a decision to the decision log, if enabled
+func (m *Middleware) logDecision(r *http.Request, match *router.Match, policy *Policy, user *UserContext, allow bool, rule, reason string, start time.Time) {
+	if m.decisionLog == nil {
+		return
+	}
+	m.decisionLog.Log(r, match.Route.PathPattern, user, DecisionResult{
+		Allow:      allow,
+		PolicyType: string(policy.Type),
+		Rule:       rule,
+		Reason:     reason,
+	}, time.Since(start))
+}
+
+// policyRule describes the rule of a policy that decided a request
+func policyRule(policy *Policy) string {
+	logic := strings.ToLower(policy.Logic)
+	if logic == "" {
+		logic = "or"
+	}
+	switch policy.Type {
+	case PolicyRoleBased:
+		return "roles: " + strings.Join(policy.Roles, " "+logic+" ")
+	case PolicyPermissionBased:
+		return "permissions: " + strings.Join(policy.Permissions, " "+logic+" ")
+	case PolicyScopeBased:
+		return "scopes: " + strings.Join(policy.Scopes, " "+logic+" ")
+	case PolicyClientCert, PolicySigned, PolicyBasic:
+		if len(policy.Roles) > 0 {
+			return string(policy.Type) + " roles: " + strings.Join(policy.Roles, " "+logic+" ")
+		}
+	case PolicyExpression:
+		if policy.Requirement != nil {
+			return "expression: " + policy.Requirement.String()
+		}
+	}
+	return string(policy.Type)
+}
+
 // buildPolicy builds an authorization policy from route configuration
 func (m *Middleware) buildPolicy(route *router.Route) *Policy {
 	// Default to authenticated if no policy specified
@@ -201,15 +672,27 @@ func (m *Middleware) buildPolicy(route *router.Route) *Policy {
 	}
 
 	policy := &Policy{
-		Type: policyType,
+		Type:       policyType,
+		Conditions: route.Conditions,
 	}
 
 	// Add required roles if role-based
-	if policyType == PolicyRoleBased {
+	if policyType == PolicyRoleBased || policyType == PolicyClientCert || policyType == PolicySigned || policyType == PolicyBasic {
 		policy.Roles = route.RequiredRoles
 		policy.Logic = "OR" // Default to OR logic
 	}
 
+	switch policyType {
+	case PolicyPermissionBased:
+		policy.Permissions = route.RequiredPermissions
+		policy.Logic = "OR"
+	case PolicyScopeBased:
+		policy.Scopes = route.RequiredScopes
+		policy.Logic = "OR"
+	case PolicyExpression:
+		policy.Requirement = route.AuthExpression
+	}
+
 	return policy
 }
 
@@ -262,9 +745,11 @@ func (m *Middleware) writeError(w http.ResponseWriter, r *http.Request, statusCo
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("X-Correlation-ID", correlationID)
 
-	// For 401, add WWW-Authenticate header
+	// For 401, add WWW-Authenticate header unless a challenge is set
 	if statusCode == http.StatusUnauthorized {
-		w.Header().Set("WWW-Authenticate", "Bearer")
+		if w.Header().Get("WWW-Authenticate") == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
 		w.Header().Set("Cache-Control", "no-store")
 	}
 
@@ -287,35 +772,17 @@ type ErrorResponse struct {
 	Details       map[string]interface{} `json:"details,omitempty"`
 }
 
-// getRouteFromContext retrieves route from context
-func getRouteFromContext(r *http.Request) *router.Route {
-	// Try to get route match from context
-	match := r.Context().Value("route_match")
+// getMatchFromContext retrieves the route match from context
+func getMatchFromContext(r *http.Request) *router.Match {
+	// Try to get route match from context, as stored by the server
+	match := middleware.RouteMatchFromContext(r.Context())
 	if match == nil {
 		return nil
 	}
 
 	if routeMatch, ok := match.(*router.Match); ok {
-		return routeMatch.Route
+		return routeMatch
 	}
 
 	return nil
 }
-
-// isHealthCheckPath checks if the path is a health check endpoint
-func isHealthCheckPath(path string, cfg *config.AuthorizationConfig) bool {
-	healthPaths := []string{
-		"/_health",
-		"/_health/live",
-		"/_health/ready",
-		"/metrics",
-	}
-
-	for _, healthPath := range healthPaths {
-		if path == healthPath {
-			return true
-		}
-	}
-
-	return false
-}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/middleware"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+func TestMiddleware_IdentifyPublicRequests(t *testing.T) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp":     time.Now().Add(time.Hour).Unix(),
+		"user_id": "user123",
+	})
+	valid, err := token.SignedString([]byte("default-secret-key-for-hmac-tests"))
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		identify     bool
+		token        string
+		expectedUser string
+	}{
+		{"valid token identified", true, valid, "user123"},
+		{"invalid token stays anonymous", true, "not-a-token", ""},
+		{"no token stays anonymous", true, "", ""},
+		{"identification disabled", false, valid, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMiddleware(&config.AuthorizationConfig{
+				Enabled:                true,
+				CookieName:             "session_token",
+				JWTSigningAlgorithm:    "HS256",
+				JWTSharedSecret:        "default-secret-key-for-hmac-tests",
+				IdentifyPublicRequests: tt.identify,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create middleware: %v", err)
+			}
+			var user string
+			handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if userCtx, ok := GetUserContext(r.Context()); ok && userCtx != nil {
+					user = userCtx.UserID
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			route := &router.Route{PathPattern: "/catalog", AuthPolicy: "public"}
+			req := httptest.NewRequest(http.MethodGet, "/catalog", nil)
+			req = req.WithContext(middleware.WithRouteMatch(req.Context(), &router.Match{Route: route}))
+			if tt.token != "" {
+				req.AddCookie(&http.Cookie{Name: "session_token", Value: tt.token})
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Errorf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if user != tt.expectedUser {
+				t.Errorf("Expected user %q, got %q", tt.expectedUser, user)
+			}
+		})
+	}
+}
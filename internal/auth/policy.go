@@ -1,11 +1,17 @@
 package auth
 
 import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/maltehedderich/api-gateway-go/internal/expr"
 	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
 )
 
 // PolicyType represents the type of authorization policy
@@ -20,6 +26,23 @@ const (
 	PolicyRoleBased PolicyType = "role-based"
 	// PolicyPermissionBased requires specific permissions
 	PolicyPermissionBased PolicyType = "permission-based"
+	// PolicyExternal requires authentication and defers the decision to an
+	// external authorization service
+	PolicyExternal PolicyType = "external"
+	// PolicyScopeBased requires specific OAuth scopes
+	PolicyScopeBased PolicyType = "scope-based"
+	// PolicyExpression requires a combination of scopes, roles and
+	// permissions, e.g. scope:read:orders AND role:manager
+	PolicyExpression PolicyType = "expression"
+	// PolicyClientCert requires a verified TLS client certificate and, if
+	// roles are given, one of the roles mapped from its attributes
+	PolicyClientCert PolicyType = "client-cert"
+	// PolicySigned requires an HMAC request signature and, if roles are
+	// given, one of the roles of the signing client
+	PolicySigned PolicyType = "signed"
+	// PolicyBasic requires HTTP Basic credentials and, if roles are given,
+	// one of the roles of the user
+	PolicyBasic PolicyType = "basic"
 )
 
 // Policy represents an authorization policy
@@ -27,26 +50,73 @@ type Policy struct {
 	Type        PolicyType
 	Roles       []string // Required roles (for role-based policy)
 	Permissions []string // Required permissions (for permission-based policy)
+	Scopes      []string // Required scopes (for scope-based policy)
 	Logic       string   // "AND" or "OR" for multiple requirements
+
+	// Requirement is the expression of an expression policy
+	Requirement *expr.Requirement
+
+	// Conditions are attribute-based expressions that must all hold
+	Conditions []*expr.Expression
 }
 
 // PolicyEvaluator evaluates authorization policies
 type PolicyEvaluator struct {
 	logger *logger.ComponentLogger
 	cache  *policyCache
+	roles  *RoleHierarchy
+	// location is the time zone of time attributes in conditions
+	location *time.Location
+	now      func() time.Time
 }
 
 // NewPolicyEvaluator creates a new policy evaluator
 func NewPolicyEvaluator(enableCache bool, cacheTTL time.Duration) *PolicyEvaluator {
 	var cache *policyCache
 	if enableCache {
-		cache = newPolicyCache(cacheTTL)
+		cache = newPolicyCache(cacheTTL, defaultDecisionCacheEntries)
 	}
 
 	return &PolicyEvaluator{
-		logger: logger.Get().WithComponent("auth.policy"),
-		cache:  cache,
+		logger:   logger.Get().WithComponent("auth.policy"),
+		cache:    cache,
+		location: time.UTC,
+		now:      time.Now,
+	}
+}
+
+// SetConditionLocation sets the time zone of time attributes in conditions
+func (pe *PolicyEvaluator) SetConditionLocation(location *time.Location) {
+	pe.location = location
+}
+
+// SetRoleHierarchy makes role-based policies honor inherited roles
+func (pe *PolicyEvaluator) SetRoleHierarchy(roles *RoleHierarchy) {
+	pe.roles = roles
+}
+
+// SetCacheSize bounds the number of cached decisions
+func (pe *PolicyEvaluator) SetCacheSize(maxEntries int) {
+	if pe.cache != nil && maxEntries > 0 {
+		pe.cache.maxEntries = maxEntries
+	}
+}
+
+// InvalidateUser drops the cached decisions of userID, e.g. after its roles
+// changed, and returns their number
+func (pe *PolicyEvaluator) InvalidateUser(userID string) int {
+	if pe.cache == nil {
+		return 0
+	}
+	return pe.cache.invalidateUser(userID)
+}
+
+// InvalidateAll drops all cached decisions and returns their number
+func (pe *PolicyEvaluator) InvalidateAll() int {
+	if pe.cache == nil {
+		return 0
 	}
+	return pe.cache.clear()
 }
 
 // Evaluate evaluates a policy against user context
@@ -54,7 +124,9 @@ func (pe *PolicyEvaluator) Evaluate(policy *Policy, user *UserContext) (*Decisio
 	// Check cache if enabled
 	if pe.cache != nil && user != nil {
 		cacheKey := pe.buildCacheKey(policy, user)
-		if decision, found := pe.cache.get(cacheKey); found {
+		decision, found := pe.cache.get(cacheKey)
+		metrics.RecordAuthCacheHit(found)
+		if found {
 			pe.logger.Debug("policy decision from cache", logger.Fields{
 				"policy_type": policy.Type,
 				"user_id":     user.UserID,
@@ -70,7 +142,7 @@ func (pe *PolicyEvaluator) Evaluate(policy *Policy, user *UserContext) (*Decisio
 	// Cache decision if enabled
 	if pe.cache != nil && user != nil {
 		cacheKey := pe.buildCacheKey(policy, user)
-		pe.cache.set(cacheKey, decision)
+		pe.cache.set(cacheKey, user.UserID, decision)
 	}
 
 	pe.logger.Debug("policy evaluated", logger.Fields{
@@ -92,7 +164,7 @@ func (pe *PolicyEvaluator) evaluatePolicy(policy *Policy, user *UserContext) *De
 			Reason:  "public route",
 		}
 
-	case PolicyAuthenticated:
+	case PolicyAuthenticated, PolicyExternal:
 		if user == nil {
 			return &Decision{
 				Allowed: false,
@@ -117,7 +189,7 @@ func (pe *PolicyEvaluator) evaluatePolicy(policy *Policy, user *UserContext) *De
 				},
 			}
 		}
-		return pe.evaluateRoleBasedPolicy(policy, user)
+		return pe.evaluateRoleBasedPolicy(policy, pe.withInheritedRoles(user))
 
 	case PolicyPermissionBased:
 		if user == nil {
@@ -131,6 +203,57 @@ func (pe *PolicyEvaluator) evaluatePolicy(policy *Policy, user *UserContext) *De
 		}
 		return pe.evaluatePermissionBasedPolicy(policy, user)
 
+	case PolicyScopeBased:
+		if user == nil {
+			return &Decision{
+				Allowed: false,
+				Reason:  "authentication required for scope-based access",
+				Details: map[string]interface{}{
+					"required_scopes": policy.Scopes,
+				},
+			}
+		}
+		return pe.evaluateScopeBasedPolicy(policy, user)
+
+	case PolicyExpression:
+		if policy.Requirement == nil {
+			return &Decision{
+				Allowed: false,
+				Reason:  "no expression specified in policy",
+			}
+		}
+		if user == nil {
+			return &Decision{
+				Allowed: false,
+				Reason:  "authentication required for expression-based access",
+				Details: map[string]interface{}{
+					"required": policy.Requirement.String(),
+				},
+			}
+		}
+		if policy.Requirement.Eval(pe.withInheritedRoles(user)) {
+			return &Decision{
+				Allowed: true,
+				Reason:  "user meets the policy expression",
+			}
+		}
+		return &Decision{
+			Allowed: false,
+			Reason:  "policy expression not satisfied",
+			Details: map[string]interface{}{
+				"required": policy.Requirement.String(),
+			},
+		}
+
+	case PolicyClientCert:
+		return pe.evaluateCredentialPolicy(policy, user, authMethodClientCert, "client certificate")
+
+	case PolicySigned:
+		return pe.evaluateCredentialPolicy(policy, user, authMethodSignature, "request signature")
+
+	case PolicyBasic:
+		return pe.evaluateCredentialPolicy(policy, user, authMethodBasic, "basic credentials")
+
 	default:
 		return &Decision{
 			Allowed: false,
@@ -139,6 +262,24 @@ func (pe *PolicyEvaluator) evaluatePolicy(policy *Policy, user *UserContext) *De
 	}
 }
 
+// evaluateCredentialPolicy evaluates policies requiring a particular kind
+// of credential, and one of the policy's roles if it lists any
+func (pe *PolicyEvaluator) evaluateCredentialPolicy(policy *Policy, user *UserContext, authMethod, credential string) *Decision {
+	if user == nil || user.Attributes["auth_method"] != authMethod {
+		return &Decision{
+			Allowed: false,
+			Reason:  credential + " required",
+		}
+	}
+	if len(policy.Roles) == 0 {
+		return &Decision{
+			Allowed: true,
+			Reason:  credential + " verified",
+		}
+	}
+	return pe.evaluateRoleBasedPolicy(policy, pe.withInheritedRoles(user))
+}
+
 // evaluateRoleBasedPolicy evaluates role-based policy
 func (pe *PolicyEvaluator) evaluateRoleBasedPolicy(policy *Policy, user *UserContext) *Decision {
 	if len(policy.Roles) == 0 {
@@ -245,9 +386,79 @@ func (pe *PolicyEvaluator) evaluatePermissionBasedPolicy(policy *Policy, user *U
 	}
 }
 
-// buildCacheKey builds a cache key for policy decision
+// evaluateScopeBasedPolicy evaluates scope-based policy
+func (pe *PolicyEvaluator) evaluateScopeBasedPolicy(policy *Policy, user *UserContext) *Decision {
+	if len(policy.Scopes) == 0 {
+		return &Decision{
+			Allowed: false,
+			Reason:  "no scopes specified in policy",
+		}
+	}
+
+	// Default to OR logic if not specified
+	logic := policy.Logic
+	if logic == "" {
+		logic = "OR"
+	}
+
+	granted := 0
+	for _, scope := range policy.Scopes {
+		if user.HasScope(scope) {
+			granted++
+		}
+	}
+	if (logic == "AND" && granted == len(policy.Scopes)) || (logic != "AND" && granted > 0) {
+		return &Decision{
+			Allowed: true,
+			Reason:  "token has required scope",
+		}
+	}
+
+	return &Decision{
+		Allowed: false,
+		Reason:  "insufficient scope",
+		Details: map[string]interface{}{
+			"required_scopes": policy.Scopes,
+			"token_scopes":    user.Scopes,
+			"logic":           logic,
+		},
+	}
+}
+
+// withInheritedRoles returns user with the roles its roles inherit added
+func (pe *PolicyEvaluator) withInheritedRoles(user *UserContext) *UserContext {
+	if pe.roles == nil {
+		return user
+	}
+	expanded := *user
+	expanded.Roles = pe.roles.Expand(user.Roles)
+	return &expanded
+}
+
+// buildCacheKey builds a cache key for policy decision. The key covers
+// everything the decision depends on: API keys and session tokens may share
+// IDs but not roles, and tokens of the same user may carry different roles
+// and scopes.
 func (pe *PolicyEvaluator) buildCacheKey(policy *Policy, user *UserContext) string {
-	return fmt.Sprintf("%s:%s:%v", policy.Type, user.UserID, policy)
+	parts := []string{
+		string(policy.Type),
+		policy.Logic,
+		strings.Join(policy.Roles, ","),
+		strings.Join(policy.Permissions, ","),
+		strings.Join(policy.Scopes, ","),
+	}
+	if policy.Requirement != nil {
+		parts = append(parts, policy.Requirement.String())
+	}
+	authMethod, _ := user.Attributes["auth_method"].(string)
+	parts = append(parts,
+		authMethod,
+		user.UserID,
+		strings.Join(user.Roles, ","),
+		strings.Join(user.Permissions, ","),
+		strings.Join(user.Scopes, ","),
+	)
+	return hashKey(parts...)
 }
 
 // getUserID safely gets user ID
@@ -265,23 +476,35 @@ type Decision struct {
 	Details map[string]interface{}
 }
 
-// policyCache caches authorization decisions
+// defaultDecisionCacheEntries bounds decision caches that are not
+// configured otherwise
+const defaultDecisionCacheEntries = 10000
+
+// policyCache caches authorization decisions. It holds at most maxEntries
+// decisions and evicts the least recently used one beyond that.
 type policyCache struct {
-	cache map[string]*cacheEntry
-	ttl   time.Duration
-	mu    sync.RWMutex
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
 }
 
 type cacheEntry struct {
+	key       string
+	userID    string
 	decision  *Decision
 	expiresAt time.Time
 }
 
 // newPolicyCache creates a new policy cache
-func newPolicyCache(ttl time.Duration) *policyCache {
+func newPolicyCache(ttl time.Duration, maxEntries int) *policyCache {
 	pc := &policyCache{
-		cache: make(map[string]*cacheEntry),
-		ttl:   ttl,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
 	}
 
 	// Start cleanup goroutine
@@ -292,31 +515,89 @@ func newPolicyCache(ttl time.Duration) *policyCache {
 
 // get retrieves a decision from cache
 func (pc *policyCache) get(key string) (*Decision, bool) {
-	pc.mu.RLock()
-	defer pc.mu.RUnlock()
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
 
-	entry, found := pc.cache[key]
+	elem, found := pc.entries[key]
 	if !found {
 		return nil, false
 	}
 
 	// Check if expired
+	entry := elem.Value.(*cacheEntry)
 	if time.Now().After(entry.expiresAt) {
+		pc.removeElement(elem)
 		return nil, false
 	}
 
+	pc.order.MoveToFront(elem)
 	return entry.decision, true
 }
 
-// set stores a decision in cache
-func (pc *policyCache) set(key string, decision *Decision) {
+// set stores the decision of userID in cache
+func (pc *policyCache) set(key, userID string, decision *Decision) {
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
 
-	pc.cache[key] = &cacheEntry{
+	expiresAt := time.Now().Add(pc.ttl)
+	if elem, found := pc.entries[key]; found {
+		entry := elem.Value.(*cacheEntry)
+		entry.decision = decision
+		entry.expiresAt = expiresAt
+		pc.order.MoveToFront(elem)
+		return
+	}
+
+	pc.entries[key] = pc.order.PushFront(&cacheEntry{
+		key:       key,
+		userID:    userID,
 		decision:  decision,
-		expiresAt: time.Now().Add(pc.ttl),
+		expiresAt: expiresAt,
+	})
+	for pc.maxEntries > 0 && pc.order.Len() > pc.maxEntries {
+		pc.removeElement(pc.order.Back())
+	}
+}
+
+// invalidateUser removes the decisions of userID and returns their number
+func (pc *policyCache) invalidateUser(userID string) int {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	removed := 0
+	for elem := pc.order.Front(); elem != nil; {
+		next := elem.Next()
+		if elem.Value.(*cacheEntry).userID == userID {
+			pc.removeElement(elem)
+			removed++
+		}
+		elem = next
 	}
+	return removed
+}
+
+// clear removes all decisions and returns their number
+func (pc *policyCache) clear() int {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	removed := pc.order.Len()
+	pc.entries = make(map[string]*list.Element)
+	pc.order.Init()
+	return removed
+}
+
+// len returns the number of cached decisions
+func (pc *policyCache) len() int {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	return pc.order.Len()
+}
+
+// removeElement drops an entry. pc.mu is held.
+func (pc *policyCache) removeElement(elem *list.Element) {
+	pc.order.Remove(elem)
+	delete(pc.entries, elem.Value.(*cacheEntry).key)
 }
 
 // cleanup periodically removes expired entries
@@ -327,11 +608,23 @@ func (pc *policyCache) cleanup() {
 	for range ticker.C {
 		pc.mu.Lock()
 		now := time.Now()
-		for key, entry := range pc.cache {
-			if now.After(entry.expiresAt) {
-				delete(pc.cache, key)
+		for elem := pc.order.Front(); elem != nil; {
+			next := elem.Next()
+			if now.After(elem.Value.(*cacheEntry).expiresAt) {
+				pc.removeElement(elem)
 			}
+			elem = next
 		}
 		pc.mu.Unlock()
 	}
 }
+
+// hashKey hashes the parts of a cache key. Parts are length-prefixed so
+// that different splits of the same bytes do not collide.
+func hashKey(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		fmt.Fprintf(h, "%d:%s;", len(part), part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
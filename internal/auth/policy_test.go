@@ -1,8 +1,12 @@
 package auth
 
 import (
+	"slices"
 	"testing"
 	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/expr"
 )
 
 func TestPolicyEvaluator_Evaluate(t *testing.T) {
@@ -202,6 +206,112 @@ func TestPolicyEvaluator_Evaluate(t *testing.T) {
 	})
 }
 
+func TestPolicyEvaluator_ScopesAndExpressions(t *testing.T) {
+	evaluator := NewPolicyEvaluator(false, 5*time.Minute)
+	evaluator.SetRoleHierarchy(NewRoleHierarchy(config.RoleHierarchyConfig{"admin": {"manager"}}))
+
+	requirement := func(source string) *expr.Requirement {
+		compiled, err := expr.CompileRequirement(source)
+		if err != nil {
+			t.Fatalf("Failed to compile %q: %v", source, err)
+		}
+		return compiled
+	}
+
+	tests := []struct {
+		name     string
+		policy   *Policy
+		user     *UserContext
+		expected bool
+	}{
+		{
+			name:     "scope granted",
+			policy:   &Policy{Type: PolicyScopeBased, Scopes: []string{"read:orders", "write:orders"}},
+			user:     &UserContext{UserID: "u", Scopes: []string{"openid", "read:orders"}},
+			expected: true,
+		},
+		{
+			name:     "all scopes required",
+			policy:   &Policy{Type: PolicyScopeBased, Scopes: []string{"read:orders", "write:orders"}, Logic: "AND"},
+			user:     &UserContext{UserID: "u", Scopes: []string{"read:orders"}},
+			expected: false,
+		},
+		{
+			name:     "scope missing",
+			policy:   &Policy{Type: PolicyScopeBased, Scopes: []string{"read:orders"}},
+			user:     &UserContext{UserID: "u", Roles: []string{"read:orders"}},
+			expected: false,
+		},
+		{
+			name:     "scope anonymous",
+			policy:   &Policy{Type: PolicyScopeBased, Scopes: []string{"read:orders"}},
+			expected: false,
+		},
+		{
+			name:     "expression satisfied",
+			policy:   &Policy{Type: PolicyExpression, Requirement: requirement("scope:read:orders AND role:manager")},
+			user:     &UserContext{UserID: "u", Scopes: []string{"read:orders"}, Roles: []string{"manager"}},
+			expected: true,
+		},
+		{
+			name:     "expression with inherited role",
+			policy:   &Policy{Type: PolicyExpression, Requirement: requirement("scope:read:orders AND role:manager")},
+			user:     &UserContext{UserID: "u", Scopes: []string{"read:orders"}, Roles: []string{"admin"}},
+			expected: true,
+		},
+		{
+			name:     "expression with wildcard permission",
+			policy:   &Policy{Type: PolicyExpression, Requirement: requirement("role:auditor OR permission:admin:users:delete")},
+			user:     &UserContext{UserID: "u", Permissions: []string{"admin:**"}},
+			expected: true,
+		},
+		{
+			name:     "expression not satisfied",
+			policy:   &Policy{Type: PolicyExpression, Requirement: requirement("scope:read:orders AND NOT role:guest")},
+			user:     &UserContext{UserID: "u", Scopes: []string{"read:orders"}, Roles: []string{"guest"}},
+			expected: false,
+		},
+		{
+			name:     "expression anonymous",
+			policy:   &Policy{Type: PolicyExpression, Requirement: requirement("NOT role:guest")},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := evaluator.Evaluate(tt.policy, tt.user)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if decision.Allowed != tt.expected {
+				t.Errorf("Expected allowed=%v, got %+v", tt.expected, decision)
+			}
+		})
+	}
+}
+
+func TestClaims_Scopes(t *testing.T) {
+	tests := []struct {
+		name     string
+		extra    map[string]interface{}
+		expected []string
+	}{
+		{"space delimited scope", map[string]interface{}{"scope": "openid read:orders"}, []string{"openid", "read:orders"}},
+		{"scp list", map[string]interface{}{"scp": []interface{}{"read:orders", "write:orders"}}, []string{"read:orders", "write:orders"}},
+		{"no scopes", map[string]interface{}{"sub": "u"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := &Claims{Extra: tt.extra}
+			if got := claims.Scopes(); !slices.Equal(got, tt.expected) {
+				t.Errorf("Scopes() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
 func TestPolicyEvaluator_Cache(t *testing.T) {
 	// Create evaluator with caching enabled
 	evaluator := NewPolicyEvaluator(true, 100*time.Millisecond)
@@ -244,3 +354,78 @@ func TestPolicyEvaluator_Cache(t *testing.T) {
 		t.Error("Expected decision after cache expiry to match original decision")
 	}
 }
+
+func TestPolicyCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newPolicyCache(time.Minute, 2)
+	allow := &Decision{Allowed: true}
+
+	cache.set("a", "user-a", allow)
+	cache.set("b", "user-b", allow)
+	if _, found := cache.get("a"); !found {
+		t.Fatal("expected a to be cached")
+	}
+	cache.set("c", "user-c", allow)
+
+	if _, found := cache.get("b"); found {
+		t.Error("expected the least recently used entry to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, found := cache.get(key); !found {
+			t.Errorf("expected %s to be cached", key)
+		}
+	}
+	if cache.len() != 2 {
+		t.Errorf("expected 2 entries, got %d", cache.len())
+	}
+}
+
+func TestPolicyEvaluator_InvalidateUser(t *testing.T) {
+	evaluator := NewPolicyEvaluator(true, time.Minute)
+	policy := &Policy{Type: PolicyRoleBased, Roles: []string{"admin"}}
+	alice := &UserContext{UserID: "alice", Roles: []string{"admin"}}
+	bob := &UserContext{UserID: "bob", Roles: []string{"admin"}}
+
+	for _, user := range []*UserContext{alice, bob} {
+		if _, err := evaluator.Evaluate(policy, user); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if n := evaluator.cache.len(); n != 2 {
+		t.Fatalf("expected 2 cached decisions, got %d", n)
+	}
+
+	// Tokens carrying other roles do not reuse the cached decision
+	demoted := &UserContext{UserID: "alice", Roles: []string{"viewer"}}
+	if decision, _ := evaluator.Evaluate(policy, demoted); decision.Allowed {
+		t.Error("expected the decision to reflect the token's roles")
+	}
+
+	if n := evaluator.InvalidateUser("alice"); n != 2 {
+		t.Errorf("expected 2 decisions of alice to be invalidated, got %d", n)
+	}
+	if n := evaluator.InvalidateAll(); n != 1 {
+		t.Errorf("expected 1 remaining decision to be invalidated, got %d", n)
+	}
+}
+
+func TestPolicyEvaluator_CacheKeyIsStable(t *testing.T) {
+	evaluator := NewPolicyEvaluator(false, 0)
+	user := &UserContext{UserID: "user123", Scopes: []string{"read:orders"}}
+
+	requirement := func() *expr.Requirement {
+		r, err := expr.CompileRequirement("scope:read:orders")
+		if err != nil {
+			t.Fatalf("CompileRequirement() error = %v", err)
+		}
+		return r
+	}
+	first := evaluator.buildCacheKey(&Policy{Type: PolicyExpression, Requirement: requirement()}, user)
+	second := evaluator.buildCacheKey(&Policy{Type: PolicyExpression, Requirement: requirement()}, user)
+	if first != second {
+		t.Error("expected equal policies to share a cache key")
+	}
+	other := evaluator.buildCacheKey(&Policy{Type: PolicyScopeBased, Scopes: []string{"read:orders"}}, user)
+	if first == other {
+		t.Error("expected different policies to have different cache keys")
+	}
+}
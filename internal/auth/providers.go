@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+// newProviderValidators creates a token validator for each auth provider.
+// Providers share the clock skew tolerance of cfg but nothing else.
+func newProviderValidators(cfg *config.AuthorizationConfig) (map[string]*TokenValidator, error) {
+	validators := make(map[string]*TokenValidator, len(cfg.Providers))
+	for name, provider := range cfg.Providers {
+		providerCfg := &config.AuthorizationConfig{
+			Enabled:             true,
+			JWTSigningAlgorithm: provider.JWTSigningAlgorithm,
+			JWTPublicKeyFile:    provider.JWTPublicKeyFile,
+			JWTSharedSecret:     provider.JWTSharedSecret,
+			ClockSkewTolerance:  cfg.ClockSkewTolerance,
+			RequiredClaims:      provider.RequiredClaims,
+			ExpectedIssuer:      provider.ExpectedIssuer,
+			ExpectedAudiences:   provider.ExpectedAudiences,
+		}
+		validator, err := NewTokenValidator(providerCfg)
+		if err != nil {
+			return nil, fmt.Errorf("auth provider %s: %w", name, err)
+		}
+		validators[name] = validator
+	}
+	return validators, nil
+}
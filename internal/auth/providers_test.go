@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/middleware"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+func TestMiddleware_AuthProviders(t *testing.T) {
+	cfg := &config.AuthorizationConfig{
+		Enabled:             true,
+		CookieName:          "session_token",
+		JWTSigningAlgorithm: "HS256",
+		JWTSharedSecret:     "default-secret-key-for-hmac-tests",
+		ExpectedIssuer:      "https://idp.example.com",
+		ClockSkewTolerance:  5 * time.Second,
+		Providers: map[string]config.AuthProviderConfig{
+			"tenant-a": {
+				JWTSigningAlgorithm: "HS256",
+				JWTSharedSecret:     "tenant-a-secret-key-for-hmac-tests",
+				ExpectedIssuer:      "https://a.idp.example.com",
+				ExpectedAudiences:   []string{"orders"},
+				RequiredClaims:      []string{"tenant"},
+			},
+		},
+	}
+	m, err := NewMiddleware(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create middleware: %v", err)
+	}
+
+	sign := func(secret, issuer string, audience []string, extra map[string]interface{}) string {
+		claims := jwt.MapClaims{
+			"iss":     issuer,
+			"exp":     time.Now().Add(time.Hour).Unix(),
+			"user_id": "user123",
+		}
+		if audience != nil {
+			claims["aud"] = audience
+		}
+		for name, value := range extra {
+			claims[name] = value
+		}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+		return token
+	}
+
+	tenantToken := sign("tenant-a-secret-key-for-hmac-tests", "https://a.idp.example.com", []string{"orders"}, map[string]interface{}{"tenant": "a"})
+	defaultToken := sign(cfg.JWTSharedSecret, "https://idp.example.com", nil, nil)
+
+	tests := []struct {
+		name           string
+		provider       string
+		token          string
+		expectedStatus int
+	}{
+		{"provider token", "tenant-a", tenantToken, http.StatusOK},
+		{"default token on provider route", "tenant-a", defaultToken, http.StatusUnauthorized},
+		{"provider token on default route", "", tenantToken, http.StatusUnauthorized},
+		{"default token", "", defaultToken, http.StatusOK},
+		{"missing required claim", "tenant-a", sign("tenant-a-secret-key-for-hmac-tests", "https://a.idp.example.com", []string{"orders"}, nil), http.StatusUnauthorized},
+		{"wrong audience", "tenant-a", sign("tenant-a-secret-key-for-hmac-tests", "https://a.idp.example.com", []string{"billing"}, map[string]interface{}{"tenant": "a"}), http.StatusUnauthorized},
+	}
+
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match := &router.Match{Route: &router.Route{PathPattern: "/orders", AuthProvider: tt.provider}}
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			req.AddCookie(&http.Cookie{Name: "session_token", Value: tt.token})
+			req = req.WithContext(middleware.WithRouteMatch(req.Context(), match))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestNewMiddleware_InvalidProvider(t *testing.T) {
+	cfg := &config.AuthorizationConfig{
+		Enabled:             true,
+		CookieName:          "session_token",
+		JWTSigningAlgorithm: "HS256",
+		JWTSharedSecret:     "default-secret-key-for-hmac-tests",
+		Providers: map[string]config.AuthProviderConfig{
+			"tenant-b": {JWTSigningAlgorithm: "RS256", JWTPublicKeyFile: "/nonexistent/key.pem", ExpectedIssuer: "https://b.idp.example.com"},
+		},
+	}
+	if _, err := NewMiddleware(cfg); err == nil {
+		t.Error("Expected an error for a provider with a missing key file")
+	}
+}
@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// errReplayStoreFull is returned when the in-memory store holds max_entries
+// unexpired token IDs
+var errReplayStoreFull = errors.New("replay store is full")
+
+// replayStore remembers token IDs until their tokens expire
+type replayStore interface {
+	// claim records id for ttl and reports whether it was not seen before
+	claim(ctx context.Context, id string, ttl time.Duration) (bool, error)
+	close() error
+}
+
+// ReplayGuard accepts each token on one-time-token routes only once, by
+// remembering the token IDs (jti) it has seen until the tokens expire
+type ReplayGuard struct {
+	store      replayStore
+	maxTTL     time.Duration
+	clockSkew  time.Duration
+	failClosed bool
+	now        func() time.Time
+}
+
+// NewReplayGuard creates the replay guard, backed by Redis if configured
+func NewReplayGuard(cfg *config.AuthorizationConfig) *ReplayGuard {
+	var store replayStore
+	if cfg.ReplayProtection.RedisAddr != "" {
+		store = newRedisReplayStore(&cfg.ReplayProtection)
+	} else {
+		store = newMemoryReplayStore(cfg.ReplayProtection.MaxEntries)
+	}
+	return &ReplayGuard{
+		store:      store,
+		maxTTL:     cfg.ReplayProtection.MaxTTL,
+		clockSkew:  cfg.ClockSkewTolerance,
+		failClosed: cfg.ReplayProtection.FailureMode != "fail-open",
+		now:        time.Now,
+	}
+}
+
+// Claim records the token ID of claims and reports whether the token is
+// presented for the first time. IDs are kept as long as the token is
+// accepted, including the clock skew tolerance.
+func (g *ReplayGuard) Claim(ctx context.Context, claims *Claims) (bool, error) {
+	ttl := g.maxTTL
+	if claims.ExpiresAt != nil {
+		ttl = claims.ExpiresAt.Time.Add(g.clockSkew).Sub(g.now())
+	}
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	return g.store.claim(ctx, claims.ID, ttl)
+}
+
+// FailClosed reports whether tokens are rejected when the store cannot be
+// checked
+func (g *ReplayGuard) FailClosed() bool {
+	return g.failClosed
+}
+
+// Close closes the store
+func (g *ReplayGuard) Close() error {
+	return g.store.close()
+}
+
+// checkReplay rejects tokens without a token ID and tokens presented to the
+// one-time-token route before. It writes the error response and returns
+// false if the request is rejected.
+func (m *Middleware) checkReplay(w http.ResponseWriter, r *http.Request, match *router.Match, policy *Policy, claims *Claims, start time.Time) bool {
+	if claims.ID == "" {
+		metrics.RecordAuthAttempt("failure")
+		metrics.RecordAuthFailure("missing_token_id")
+		m.logDecision(r, match, policy, NewUserContext(claims), false, "", "token has no jti", start)
+		m.writeError(w, r, http.StatusUnauthorized, "missing_token_id", "One-time session tokens require a token ID", nil)
+		return false
+	}
+
+	first, err := m.replayGuard.Claim(r.Context(), claims)
+	if err != nil && m.replayGuard.FailClosed() {
+		m.logger.Error("replay check failed, rejecting request", logger.Fields{
+			"route": match.Route.PathPattern,
+			"error": err.Error(),
+		})
+		metrics.RecordAuthAttempt("failure")
+		metrics.RecordAuthFailure("replay_check_unavailable")
+		m.logDecision(r, match, policy, nil, false, "", "replay check unavailable", start)
+		m.writeError(w, r, http.StatusServiceUnavailable, "replay_check_unavailable", "Token reuse cannot be verified right now", nil)
+		return false
+	} else if err != nil {
+		m.logger.Warn("replay check failed, allowing request", logger.Fields{
+			"route": match.Route.PathPattern,
+			"error": err.Error(),
+		})
+		return true
+	}
+
+	if !first {
+		m.logger.Warn("token replay rejected", logger.Fields{
+			"user_id":    claims.UserID,
+			"session_id": maskSessionID(claims.SessionID),
+			"route":      match.Route.PathPattern,
+		})
+		metrics.RecordAuthAttempt("failure")
+		metrics.RecordAuthFailure("replayed_token")
+		m.logDecision(r, match, policy, NewUserContext(claims), false, "", "token replayed", start)
+		m.writeError(w, r, http.StatusUnauthorized, "token_replayed", "Session token has already been used", nil)
+		return false
+	}
+	return true
+}
+
+// memoryReplayStore keeps token IDs in memory, for single-instance gateways
+type memoryReplayStore struct {
+	mu         sync.Mutex
+	expiries   map[string]time.Time
+	maxEntries int
+	now        func() time.Time
+}
+
+func newMemoryReplayStore(maxEntries int) *memoryReplayStore {
+	return &memoryReplayStore{
+		expiries:   make(map[string]time.Time),
+		maxEntries: maxEntries,
+		now:        time.Now,
+	}
+}
+
+func (s *memoryReplayStore) claim(_ context.Context, id string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if expiry, ok := s.expiries[id]; ok && now.Before(expiry) {
+		return false, nil
+	}
+
+	// Expired IDs are dropped once the store fills up. Evicting live IDs
+	// would let their tokens be replayed, so a full store fails instead.
+	if len(s.expiries) >= s.maxEntries {
+		for seen, expiry := range s.expiries {
+			if !now.Before(expiry) {
+				delete(s.expiries, seen)
+			}
+		}
+		if len(s.expiries) >= s.maxEntries {
+			return false, errReplayStoreFull
+		}
+	}
+
+	s.expiries[id] = now.Add(ttl)
+	return true, nil
+}
+
+func (s *memoryReplayStore) close() error {
+	return nil
+}
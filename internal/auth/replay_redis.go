@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+// redisReplayStore keeps token IDs in Redis so every gateway instance
+// rejects a replay. An ID is seen while <prefix><jti> exists.
+type redisReplayStore struct {
+	client  *redis.Client
+	prefix  string
+	timeout time.Duration
+}
+
+// newRedisReplayStore creates a Redis replay store
+func newRedisReplayStore(cfg *config.ReplayProtectionConfig) *redisReplayStore {
+	return &redisReplayStore{
+		client: redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddr,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+		}),
+		prefix:  cfg.RedisKeyPrefix,
+		timeout: cfg.Timeout,
+	}
+}
+
+// claim sets the key of id unless it exists, so concurrent instances agree
+// on the first presentation
+func (s *redisReplayStore) claim(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.client.SetNX(ctx, s.prefix+id, 1, ttl).Result()
+}
+
+// close closes the connection pool
+func (s *redisReplayStore) close() error {
+	return s.client.Close()
+}
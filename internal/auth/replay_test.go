@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/middleware"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+func TestMiddleware_OneTimeToken(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+	m, err := NewMiddleware(&config.AuthorizationConfig{
+		Enabled:             true,
+		CookieName:          "session_token",
+		JWTSigningAlgorithm: "HS256",
+		JWTSharedSecret:     "default-secret-key-for-hmac-tests",
+		ReplayProtection: config.ReplayProtectionConfig{
+			MaxEntries:  100,
+			MaxTTL:      time.Hour,
+			FailureMode: "fail-closed",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create middleware: %v", err)
+	}
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	sign := func(jti string) string {
+		claims := jwt.MapClaims{"exp": time.Now().Add(time.Hour).Unix(), "user_id": "user123"}
+		if jti != "" {
+			claims["jti"] = jti
+		}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("default-secret-key-for-hmac-tests"))
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+		return token
+	}
+	oneTime := &router.Route{PathPattern: "/payments/callback", AuthPolicy: "authenticated", OneTimeToken: true}
+	regular := &router.Route{PathPattern: "/orders", AuthPolicy: "authenticated"}
+
+	tests := []struct {
+		name           string
+		route          *router.Route
+		token          string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{"first use", oneTime, sign("payment-1"), http.StatusOK, ""},
+		{"replay", oneTime, sign("payment-1"), http.StatusUnauthorized, "token_replayed"},
+		{"other token", oneTime, sign("payment-2"), http.StatusOK, ""},
+		{"missing jti", oneTime, sign(""), http.StatusUnauthorized, "missing_token_id"},
+		{"regular route reuses token", regular, sign("payment-1"), http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.route.PathPattern, nil)
+			req = req.WithContext(middleware.WithRouteMatch(req.Context(), &router.Match{Route: tt.route}))
+			req.AddCookie(&http.Cookie{Name: "session_token", Value: tt.token})
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rec.Code, rec.Body.String())
+			}
+			if tt.expectedCode != "" && !bytes.Contains(rec.Body.Bytes(), []byte(tt.expectedCode)) {
+				t.Errorf("Expected error %s, got %s", tt.expectedCode, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestMemoryReplayStore(t *testing.T) {
+	store := newMemoryReplayStore(2)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if first, err := store.claim(ctx, "a", time.Minute); err != nil || !first {
+		t.Fatalf("expected first claim to succeed, got %v, %v", first, err)
+	}
+	if first, _ := store.claim(ctx, "a", time.Minute); first {
+		t.Error("expected repeated claim to be rejected")
+	}
+	if _, err := store.claim(ctx, "b", time.Second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := store.claim(ctx, "c", time.Minute); !errors.Is(err, errReplayStoreFull) {
+		t.Errorf("expected full store error, got %v", err)
+	}
+
+	// Expired IDs make room and may be presented again
+	now = now.Add(2 * time.Second)
+	if first, err := store.claim(ctx, "b", time.Minute); err != nil || !first {
+		t.Errorf("expected expired ID to be accepted, got %v, %v", first, err)
+	}
+	if first, _ := store.claim(ctx, "a", time.Minute); first {
+		t.Error("expected unexpired ID to stay rejected")
+	}
+}
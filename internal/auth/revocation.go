@@ -6,52 +6,69 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/maltehedderich/api-gateway-go/internal/config"
 	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
 )
 
 // RevocationChecker checks if tokens have been revoked
 type RevocationChecker struct {
-	config       *config.AuthorizationConfig
-	logger       *logger.ComponentLogger
-	client       *http.Client
-	cache        *revocationCache
-	enabled      bool
+	config     *config.AuthorizationConfig
+	logger     *logger.ComponentLogger
+	client     *http.Client
+	cache      *revocationCache
+	denylist   *revocationDenylist
+	enabled    bool
+	failClosed bool
 }
 
 // NewRevocationChecker creates a new revocation checker
 func NewRevocationChecker(cfg *config.AuthorizationConfig) *RevocationChecker {
-	enabled := cfg.RevocationListURL != ""
+	var denylist *revocationDenylist
+	if cfg.Revocation.RedisAddr != "" {
+		denylist = newRevocationDenylist(&cfg.Revocation)
+	}
+	enabled := cfg.RevocationListURL != "" || denylist != nil
 
 	var cache *revocationCache
 	if enabled && cfg.RevocationListCache > 0 {
 		cache = newRevocationCache(cfg.RevocationListCache)
 	}
 
-	return &RevocationChecker{
+	rc := &RevocationChecker{
 		config: cfg,
 		logger: logger.Get().WithComponent("auth.revocation"),
 		client: &http.Client{
 			Timeout: 5 * time.Second,
 		},
-		cache:   cache,
-		enabled: enabled,
+		cache:      cache,
+		denylist:   denylist,
+		enabled:    enabled,
+		failClosed: cfg.Revocation.FailureMode == "fail-closed",
+	}
+
+	// Revocations published by other services drop cached results at once
+	if denylist != nil && cache != nil && cfg.Revocation.Channel != "" {
+		denylist.subscribe(cfg.Revocation.Channel, rc.invalidate)
 	}
+	return rc
 }
 
-// IsRevoked checks if a session ID has been revoked
-func (rc *RevocationChecker) IsRevoked(ctx context.Context, sessionID string) (bool, error) {
-	if !rc.enabled {
+// IsRevoked checks if a token has been revoked by its session ID or its
+// token ID (jti)
+func (rc *RevocationChecker) IsRevoked(ctx context.Context, sessionID, tokenID string) (bool, error) {
+	if !rc.enabled || (sessionID == "" && tokenID == "") {
 		// Revocation checking is disabled
 		return false, nil
 	}
 
 	// Check cache first
 	if rc.cache != nil {
-		if revoked, found := rc.cache.get(sessionID); found {
+		if revoked, found := rc.cache.get(sessionID, tokenID); found {
 			rc.logger.Debug("revocation check from cache", logger.Fields{
 				"session_id": maskSessionID(sessionID),
 				"revoked":    revoked,
@@ -60,21 +77,20 @@ func (rc *RevocationChecker) IsRevoked(ctx context.Context, sessionID string) (b
 		}
 	}
 
-	// Check revocation list
-	revoked, err := rc.checkRevocationList(ctx, sessionID)
+	// Check revocation list and denylist
+	revoked, err := rc.check(ctx, sessionID, tokenID)
 	if err != nil {
 		rc.logger.Error("revocation check failed", logger.Fields{
 			"session_id": maskSessionID(sessionID),
 			"error":      err.Error(),
 		})
-		// Fail open - assume not revoked if we can't check
-		// In production, this could be configurable (fail-open vs fail-closed)
+		// The caller decides between fail-open and fail-closed
 		return false, err
 	}
 
 	// Cache result
 	if rc.cache != nil {
-		rc.cache.set(sessionID, revoked)
+		rc.cache.set(sessionID, tokenID, revoked)
 	}
 
 	rc.logger.Debug("revocation check completed", logger.Fields{
@@ -85,6 +101,57 @@ func (rc *RevocationChecker) IsRevoked(ctx context.Context, sessionID string) (b
 	return revoked, nil
 }
 
+// FailClosed reports whether requests are rejected when revocation cannot
+// be checked
+func (rc *RevocationChecker) FailClosed() bool {
+	return rc.failClosed
+}
+
+// Close stops listening for published revocations
+func (rc *RevocationChecker) Close() error {
+	if rc.denylist == nil {
+		return nil
+	}
+	return rc.denylist.close()
+}
+
+// check asks the revocation list service about the session and the Redis
+// denylist about the session and token ID
+func (rc *RevocationChecker) check(ctx context.Context, sessionID, tokenID string) (bool, error) {
+	if rc.config.RevocationListURL != "" && sessionID != "" {
+		start := time.Now()
+		revoked, err := rc.checkRevocationList(ctx, sessionID)
+		metrics.RecordAuthRevocationCheck("list", time.Since(start), err)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+
+	if rc.denylist != nil {
+		start := time.Now()
+		revoked, err := rc.denylist.revoked(ctx, sessionID, tokenID)
+		metrics.RecordAuthRevocationCheck("redis", time.Since(start), err)
+		if err != nil {
+			return false, fmt.Errorf("denylist lookup failed: %w", err)
+		}
+		return revoked, nil
+	}
+	return false, nil
+}
+
+// invalidate drops the cached results of a published revocation, a message
+// of the form "jti:<jti>" or "session:<session_id>"
+func (rc *RevocationChecker) invalidate(message string) {
+	kind, id, ok := strings.Cut(message, ":")
+	if !ok || id == "" || (kind != "jti" && kind != "session") {
+		rc.logger.Warn("ignoring malformed revocation message", logger.Fields{
+			"message": message,
+		})
+		return
+	}
+	rc.cache.invalidate(kind, id)
+}
+
 // checkRevocationList checks the revocation list service
 func (rc *RevocationChecker) checkRevocationList(ctx context.Context, sessionID string) (bool, error) {
 	// Build request URL
@@ -130,10 +197,17 @@ type revocationCache struct {
 }
 
 type revocationEntry struct {
+	sessionID string
+	tokenID   string
 	revoked   bool
 	expiresAt time.Time
 }
 
+// revocationCacheKey identifies a token by its session and token ID
+func revocationCacheKey(sessionID, tokenID string) string {
+	return sessionID + "|" + tokenID
+}
+
 // newRevocationCache creates a new revocation cache
 func newRevocationCache(ttl time.Duration) *revocationCache {
 	rc := &revocationCache{
@@ -148,11 +222,11 @@ func newRevocationCache(ttl time.Duration) *revocationCache {
 }
 
 // get retrieves a revocation status from cache
-func (rc *revocationCache) get(sessionID string) (bool, bool) {
+func (rc *revocationCache) get(sessionID, tokenID string) (bool, bool) {
 	rc.mu.RLock()
 	defer rc.mu.RUnlock()
 
-	entry, found := rc.cache[sessionID]
+	entry, found := rc.cache[revocationCacheKey(sessionID, tokenID)]
 	if !found {
 		return false, false
 	}
@@ -166,16 +240,31 @@ func (rc *revocationCache) get(sessionID string) (bool, bool) {
 }
 
 // set stores a revocation status in cache
-func (rc *revocationCache) set(sessionID string, revoked bool) {
+func (rc *revocationCache) set(sessionID, tokenID string, revoked bool) {
 	rc.mu.Lock()
 	defer rc.mu.Unlock()
 
-	rc.cache[sessionID] = &revocationEntry{
+	rc.cache[revocationCacheKey(sessionID, tokenID)] = &revocationEntry{
+		sessionID: sessionID,
+		tokenID:   tokenID,
 		revoked:   revoked,
 		expiresAt: time.Now().Add(rc.ttl),
 	}
 }
 
+// invalidate removes the entries of a session or token ID, so the next
+// check asks the denylist again
+func (rc *revocationCache) invalidate(kind, id string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	for key, entry := range rc.cache {
+		if (kind == "session" && entry.sessionID == id) || (kind == "jti" && entry.tokenID == id) {
+			delete(rc.cache, key)
+		}
+	}
+}
+
 // cleanup periodically removes expired entries
 func (rc *revocationCache) cleanup() {
 	ticker := time.NewTicker(rc.ttl)
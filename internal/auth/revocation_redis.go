@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+// revocationDenylist looks up revoked tokens in Redis. A token is revoked
+// while <prefix>jti:<jti> or <prefix>session:<session_id> exists.
+type revocationDenylist struct {
+	client  *redis.Client
+	prefix  string
+	timeout time.Duration
+	pubsub  *redis.PubSub
+}
+
+// newRevocationDenylist creates a Redis revocation denylist
+func newRevocationDenylist(cfg *config.RevocationConfig) *revocationDenylist {
+	return &revocationDenylist{
+		client: redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddr,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+		}),
+		prefix:  cfg.RedisKeyPrefix,
+		timeout: cfg.Timeout,
+	}
+}
+
+// revoked reports whether the session or the token ID is on the denylist
+func (d *revocationDenylist) revoked(ctx context.Context, sessionID, tokenID string) (bool, error) {
+	keys := make([]string, 0, 2)
+	if sessionID != "" {
+		keys = append(keys, d.prefix+"session:"+sessionID)
+	}
+	if tokenID != "" {
+		keys = append(keys, d.prefix+"jti:"+tokenID)
+	}
+	if len(keys) == 0 {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	n, err := d.client.Exists(ctx, keys...).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// subscribe passes every message published on channel to handle. The
+// subscription is re-established after connection failures; revocations
+// published meanwhile apply once cached results expire.
+func (d *revocationDenylist) subscribe(channel string, handle func(message string)) {
+	d.pubsub = d.client.Subscribe(context.Background(), channel)
+	messages := d.pubsub.Channel()
+	go func() {
+		for msg := range messages {
+			handle(msg.Payload)
+		}
+	}()
+}
+
+// close ends the subscription and closes the connection pool
+func (d *revocationDenylist) close() error {
+	if d.pubsub != nil {
+		_ = d.pubsub.Close()
+	}
+	return d.client.Close()
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/middleware"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+func TestRevocationChecker_CacheInvalidation(t *testing.T) {
+	var lookups atomic.Int32
+	revoked := map[string]bool{}
+	list := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]bool{"revoked": revoked[r.URL.Query().Get("session_id")]})
+	}))
+	defer list.Close()
+
+	rc := NewRevocationChecker(&config.AuthorizationConfig{
+		RevocationListURL:   list.URL,
+		RevocationListCache: time.Minute,
+	})
+	check := func() bool {
+		t.Helper()
+		result, err := rc.IsRevoked(context.Background(), "session-1", "token-1")
+		if err != nil {
+			t.Fatalf("IsRevoked() error = %v", err)
+		}
+		return result
+	}
+
+	if check() || check() {
+		t.Fatal("expected session to be valid")
+	}
+	if lookups.Load() != 1 {
+		t.Fatalf("expected the second check to be cached, got %d lookups", lookups.Load())
+	}
+
+	// A published revocation of another token leaves the cached result
+	revoked["session-1"] = true
+	rc.invalidate("jti:token-2")
+	rc.invalidate("malformed")
+	if check() {
+		t.Fatal("expected cached result until the token itself is revoked")
+	}
+
+	rc.invalidate("session:session-1")
+	if !check() {
+		t.Error("expected session to be revoked after invalidation")
+	}
+	if lookups.Load() != 2 {
+		t.Errorf("expected a fresh lookup after invalidation, got %d lookups", lookups.Load())
+	}
+}
+
+func TestRevocationChecker_DenylistUnavailable(t *testing.T) {
+	rc := NewRevocationChecker(&config.AuthorizationConfig{
+		Revocation: config.RevocationConfig{
+			RedisAddr:      "127.0.0.1:1",
+			RedisKeyPrefix: "revoked:",
+			Timeout:        100 * time.Millisecond,
+			FailureMode:    "fail-closed",
+		},
+	})
+	defer func() { _ = rc.Close() }()
+
+	if !rc.FailClosed() {
+		t.Error("expected fail-closed checker")
+	}
+	if _, err := rc.IsRevoked(context.Background(), "session-1", "token-1"); err == nil {
+		t.Error("expected an error while Redis is unreachable")
+	}
+	if revoked, err := rc.IsRevoked(context.Background(), "", ""); revoked || err != nil {
+		t.Errorf("expected tokens without identifiers to be unchecked, got %v, %v", revoked, err)
+	}
+}
+
+func TestMiddleware_RevocationFailureMode(t *testing.T) {
+	list := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer list.Close()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp":        time.Now().Add(time.Hour).Unix(),
+		"user_id":    "user123",
+		"session_id": "session-1",
+	}).SignedString([]byte("default-secret-key-for-hmac-tests"))
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+
+	tests := []struct {
+		failureMode    string
+		expectedStatus int
+	}{
+		{"fail-open", http.StatusOK},
+		{"fail-closed", http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.failureMode, func(t *testing.T) {
+			m, err := NewMiddleware(&config.AuthorizationConfig{
+				Enabled:             true,
+				CookieName:          "session_token",
+				JWTSigningAlgorithm: "HS256",
+				JWTSharedSecret:     "default-secret-key-for-hmac-tests",
+				RevocationListURL:   list.URL,
+				Revocation:          config.RevocationConfig{FailureMode: tt.failureMode},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create middleware: %v", err)
+			}
+			handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			match := &router.Match{Route: &router.Route{PathPattern: "/orders"}}
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			req.AddCookie(&http.Cookie{Name: "session_token", Value: token})
+			req = req.WithContext(middleware.WithRouteMatch(req.Context(), match))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"strings"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+// RoleHierarchy resolves the roles a role inherits
+type RoleHierarchy struct {
+	implied map[string][]string
+}
+
+// NewRoleHierarchy creates a role hierarchy from configuration. The
+// configuration must be free of cycles, which config validation ensures.
+func NewRoleHierarchy(cfg config.RoleHierarchyConfig) *RoleHierarchy {
+	h := &RoleHierarchy{implied: make(map[string][]string, len(cfg))}
+
+	// Resolve transitive inheritance once so lookups stay cheap
+	for role := range cfg {
+		seen := map[string]bool{role: true}
+		queue := append([]string(nil), cfg[role]...)
+		for len(queue) > 0 {
+			inherited := queue[0]
+			queue = queue[1:]
+			if seen[inherited] {
+				continue
+			}
+			seen[inherited] = true
+			h.implied[role] = append(h.implied[role], inherited)
+			queue = append(queue, cfg[inherited]...)
+		}
+	}
+
+	return h
+}
+
+// Expand returns roles together with every role they inherit
+func (h *RoleHierarchy) Expand(roles []string) []string {
+	if h == nil || len(h.implied) == 0 {
+		return roles
+	}
+
+	expanded := make([]string, 0, len(roles))
+	seen := make(map[string]bool, len(roles))
+	add := func(role string) {
+		if !seen[role] {
+			seen[role] = true
+			expanded = append(expanded, role)
+		}
+	}
+	for _, role := range roles {
+		add(role)
+		for _, inherited := range h.implied[role] {
+			add(inherited)
+		}
+	}
+	return expanded
+}
+
+// permissionMatches reports whether a granted permission covers the required
+// one. Permissions are ":"-separated segments: "*" in a segment matches
+// exactly one segment, so "orders:*" grants "orders:read" but not
+// "orders:items:write", and a trailing "**" matches one or more segments, so
+// "admin:**" grants both "admin:users" and "admin:users:delete". A bare "*"
+// or "**" grants everything.
+func permissionMatches(granted, required string) bool {
+	if granted == required || granted == "*" || granted == "**" {
+		return true
+	}
+	if !strings.Contains(granted, "*") {
+		return false
+	}
+
+	grantedSegments := strings.Split(granted, ":")
+	requiredSegments := strings.Split(required, ":")
+	for i, segment := range grantedSegments {
+		if segment == "**" && i == len(grantedSegments)-1 {
+			return len(requiredSegments) > i
+		}
+		if i >= len(requiredSegments) || (segment != "*" && segment != requiredSegments[i]) {
+			return false
+		}
+	}
+	return len(grantedSegments) == len(requiredSegments)
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+func TestRoleHierarchy_Expand(t *testing.T) {
+	hierarchy := NewRoleHierarchy(config.RoleHierarchyConfig{
+		"admin":     {"moderator", "billing"},
+		"moderator": {"user"},
+	})
+
+	tests := []struct {
+		name     string
+		roles    []string
+		expected []string
+	}{
+		{"Transitive", []string{"admin"}, []string{"admin", "moderator", "billing", "user"}},
+		{"SingleLevel", []string{"moderator"}, []string{"moderator", "user"}},
+		{"NoInheritance", []string{"guest"}, []string{"guest"}},
+		{"Deduplicated", []string{"moderator", "user"}, []string{"moderator", "user"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := hierarchy.Expand(tt.roles)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("Expand(%v) = %v, want %v", tt.roles, got, tt.expected)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("Expand(%v) = %v, want %v", tt.roles, got, tt.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestPolicyEvaluator_RoleHierarchy(t *testing.T) {
+	evaluator := NewPolicyEvaluator(false, 5*time.Minute)
+	evaluator.SetRoleHierarchy(NewRoleHierarchy(config.RoleHierarchyConfig{
+		"admin":     {"moderator"},
+		"moderator": {"user"},
+	}))
+
+	policy := &Policy{Type: PolicyRoleBased, Roles: []string{"user"}}
+
+	tests := []struct {
+		name     string
+		roles    []string
+		expected bool
+	}{
+		{"InheritedRole", []string{"admin"}, true},
+		{"DirectRole", []string{"user"}, true},
+		{"UnrelatedRole", []string{"guest"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &UserContext{UserID: "user123", Roles: tt.roles}
+			decision, err := evaluator.Evaluate(policy, user)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if decision.Allowed != tt.expected {
+				t.Errorf("Expected allowed=%v for roles %v, got %v", tt.expected, tt.roles, decision.Allowed)
+			}
+			if len(user.Roles) != len(tt.roles) {
+				t.Error("Expected user roles to be left unchanged")
+			}
+		})
+	}
+}
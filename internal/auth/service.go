@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// ServiceIdentityKey is the context key for the identity of a calling service
+const ServiceIdentityKey ContextKey = "auth_service_identity"
+
+// SetServiceIdentity stores the identity of the service that sent a request
+// over the internal listener
+func SetServiceIdentity(ctx context.Context, service string) context.Context {
+	return context.WithValue(ctx, ServiceIdentityKey, service)
+}
+
+// GetServiceIdentity returns the identity of the calling service, or "" for
+// requests that did not arrive over the internal listener
+func GetServiceIdentity(ctx context.Context) string {
+	service, _ := ctx.Value(ServiceIdentityKey).(string)
+	return service
+}
+
+// ServiceIdentityFromCert returns the service identity of a client
+// certificate: its first URI SAN, such as a SPIFFE ID, or else its subject
+// common name
+func ServiceIdentityFromCert(cert *x509.Certificate) string {
+	if len(cert.URIs) > 0 {
+		return cert.URIs[0].String()
+	}
+	return cert.Subject.CommonName
+}
+
+// SetServiceIdentities sets the roles, permissions and scopes granted to
+// services calling over the internal listener
+func (m *Middleware) SetServiceIdentities(services map[string]config.ServiceIdentityConfig) {
+	m.services = services
+}
+
+// authenticateService returns the user for the calling service of r. The
+// service already proved its identity with its client certificate, so any
+// session token or API key is ignored.
+func (m *Middleware) authenticateService(r *http.Request, match *router.Match, service string) *UserContext {
+	grants := m.services[service]
+	user := &UserContext{
+		UserID:      service,
+		Roles:       grants.Roles,
+		Permissions: grants.Permissions,
+		Scopes:      grants.Scopes,
+		Attributes:  map[string]interface{}{"auth_method": authMethodClientCert},
+	}
+	if _, known := m.services[service]; !known {
+		m.logger.Debug("unknown service, authenticated without grants", logger.Fields{
+			"service": service,
+			"path":    r.URL.Path,
+			"route":   match.Route.PathPattern,
+		})
+	}
+	return user
+}
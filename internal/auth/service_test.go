@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/middleware"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+func TestServiceIdentityFromCert(t *testing.T) {
+	spiffeID, _ := url.Parse("spiffe://mesh.example/ns/shop/sa/checkout")
+
+	tests := []struct {
+		name     string
+		cert     *x509.Certificate
+		expected string
+	}{
+		{"uri san", &x509.Certificate{URIs: []*url.URL{spiffeID}, Subject: pkix.Name{CommonName: "checkout"}}, spiffeID.String()},
+		{"common name", &x509.Certificate{Subject: pkix.Name{CommonName: "checkout"}}, "checkout"},
+		{"none", &x509.Certificate{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ServiceIdentityFromCert(tt.cert); got != tt.expected {
+				t.Errorf("ServiceIdentityFromCert() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMiddleware_ServiceIdentity(t *testing.T) {
+	cfg := &config.AuthorizationConfig{
+		Enabled:             true,
+		CookieName:          "session_token",
+		JWTSigningAlgorithm: "HS256",
+		JWTSharedSecret:     "default-secret-key-for-hmac-tests",
+		ClockSkewTolerance:  5 * time.Second,
+	}
+	m, err := NewMiddleware(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create middleware: %v", err)
+	}
+	m.SetServiceIdentities(map[string]config.ServiceIdentityConfig{
+		"checkout": {Roles: []string{"order-writer"}, Scopes: []string{"orders:read"}},
+	})
+
+	tests := []struct {
+		name           string
+		service        string
+		route          *router.Route
+		expectedStatus int
+	}{
+		{"granted role", "checkout", &router.Route{AuthPolicy: "role-based", RequiredRoles: []string{"order-writer"}}, http.StatusOK},
+		{"granted scope", "checkout", &router.Route{AuthPolicy: "scope-based", RequiredScopes: []string{"orders:read"}}, http.StatusOK},
+		{"missing role", "checkout", &router.Route{AuthPolicy: "role-based", RequiredRoles: []string{"admin"}}, http.StatusForbidden},
+		{"unknown service authenticated", "search", &router.Route{AuthPolicy: "authenticated"}, http.StatusOK},
+		{"unknown service without grants", "search", &router.Route{AuthPolicy: "role-based", RequiredRoles: []string{"order-writer"}}, http.StatusForbidden},
+		{"no service needs a token", "", &router.Route{AuthPolicy: "authenticated"}, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var user *UserContext
+			handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				user, _ = GetUserContext(r.Context())
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			tt.route.PathPattern = "/orders"
+			ctx := middleware.WithRouteMatch(context.Background(), &router.Match{Route: tt.route})
+			if tt.service != "" {
+				ctx = SetServiceIdentity(ctx, tt.service)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil).WithContext(ctx)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rec.Code, rec.Body.String())
+			}
+			if rec.Code == http.StatusOK && (user == nil || user.UserID != tt.service) {
+				t.Errorf("Expected user %q, got %+v", tt.service, user)
+			}
+		})
+	}
+}
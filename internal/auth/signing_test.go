@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/middleware"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+func signedRequest(t *testing.T, secret, target, body string, at time.Time) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	digest := sha256.Sum256([]byte(body))
+	signature := Sign(secret, req.Method, req.URL.EscapedPath(), req.URL.Query().Encode(), timestamp, hex.EncodeToString(digest[:]))
+	req.Header.Set("Authorization", SignatureScheme+" KeyId=partner, Signature="+signature)
+	req.Header.Set(SignatureTimestampHeader, timestamp)
+	return req
+}
+
+func TestRequestVerifier_Verify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	verifier := NewRequestVerifier(&config.RequestSigningConfig{
+		Enabled:      true,
+		MaxClockSkew: 5 * time.Minute,
+		MaxBodySize:  64,
+		Clients: map[string]config.SigningClientConfig{
+			"partner": {Secret: config.SecretRef{Value: "partner-secret"}, Roles: []string{"webhook"}},
+		},
+	})
+	verifier.now = func() time.Time { return now }
+
+	tests := []struct {
+		name         string
+		request      func() *http.Request
+		expectedCode string
+	}{
+		{"valid", func() *http.Request {
+			return signedRequest(t, "partner-secret", "/hooks?b=2&a=1", `{"event":"paid"}`, now)
+		}, ""},
+		{"missing signature", func() *http.Request {
+			return httptest.NewRequest(http.MethodPost, "/hooks", nil)
+		}, "missing_signature"},
+		{"wrong secret", func() *http.Request {
+			return signedRequest(t, "other-secret", "/hooks", `{}`, now)
+		}, "invalid_signature"},
+		{"tampered body", func() *http.Request {
+			req := signedRequest(t, "partner-secret", "/hooks", `{"amount":1}`, now)
+			req.Body = io.NopCloser(strings.NewReader(`{"amount":100}`))
+			return req
+		}, "invalid_signature"},
+		{"tampered query", func() *http.Request {
+			req := signedRequest(t, "partner-secret", "/hooks?id=1", `{}`, now)
+			req.URL.RawQuery = "id=2"
+			return req
+		}, "invalid_signature"},
+		{"stale timestamp", func() *http.Request {
+			return signedRequest(t, "partner-secret", "/hooks", `{}`, now.Add(-10*time.Minute))
+		}, "stale_signature"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.request()
+			user, err := verifier.Verify(req)
+			if tt.expectedCode == "" {
+				if err != nil {
+					t.Fatalf("Verify() error = %v", err)
+				}
+				if user.UserID != "partner" || user.Roles[0] != "webhook" {
+					t.Errorf("unexpected user: %+v", user)
+				}
+				if body, _ := io.ReadAll(req.Body); string(body) != `{"event":"paid"}` {
+					t.Errorf("expected the body to be restored, got %q", body)
+				}
+				return
+			}
+			valErr, ok := err.(*ValidationError)
+			if !ok || valErr.Code != tt.expectedCode {
+				t.Errorf("Verify() error = %v, expected code %s", err, tt.expectedCode)
+			}
+		})
+	}
+
+	t.Run("body too large", func(t *testing.T) {
+		req := signedRequest(t, "partner-secret", "/hooks", strings.Repeat("x", 65), now)
+		if _, err := verifier.Verify(req); err != errBodyTooLarge {
+			t.Errorf("Verify() error = %v, expected %v", err, errBodyTooLarge)
+		}
+	})
+}
+
+func TestMiddleware_SignedRoute(t *testing.T) {
+	m, err := NewMiddleware(&config.AuthorizationConfig{
+		Enabled:             true,
+		CookieName:          "session_token",
+		JWTSigningAlgorithm: "HS256",
+		JWTSharedSecret:     "default-secret-key-for-hmac-tests",
+		RequestSigning: config.RequestSigningConfig{
+			Enabled:      true,
+			MaxClockSkew: time.Minute,
+			MaxBodySize:  1024,
+			Clients: map[string]config.SigningClientConfig{
+				"partner": {Secret: config.SecretRef{Value: "partner-secret"}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create middleware: %v", err)
+	}
+	var forwarded *http.Request
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	route := &router.Route{PathPattern: "/hooks", AuthPolicy: "signed"}
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		req = req.WithContext(middleware.WithRouteMatch(req.Context(), &router.Match{Route: route}))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve(signedRequest(t, "partner-secret", "/hooks", `{}`, time.Now())); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if forwarded.Header.Get("Authorization") != "" {
+		t.Error("expected the signature to be removed before forwarding")
+	}
+	if rec := serve(signedRequest(t, "wrong-secret", "/hooks", `{}`, time.Now())); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for a bad signature, got %d", rec.Code)
+	}
+}
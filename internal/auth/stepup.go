@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// ErrorInsufficientAuthenticationLevel is the error code of requests whose
+// token lacks the route's step-up authentication
+const ErrorInsufficientAuthenticationLevel = "insufficient_authentication_level"
+
+// meetsStepUp reports whether the token of user satisfies the step-up
+// requirements. Users without a token, such as API key clients, never do.
+func meetsStepUp(stepUp config.StepUpConfig, user *UserContext) bool {
+	if user == nil || user.Claims == nil {
+		return false
+	}
+	if len(stepUp.ACR) > 0 {
+		acr, _ := user.Claims.Claim("acr")
+		value, ok := acr.(string)
+		if !ok || !slices.Contains(stepUp.ACR, value) {
+			return false
+		}
+	}
+	amr := stringList(user.Claims.Extra["amr"])
+	for _, method := range stepUp.AMR {
+		if !slices.Contains(amr, method) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkStepUp rejects users whose token lacks the route's step-up
+// authentication with a challenge naming the required values (RFC 9470).
+// It writes the error response and returns false if the request is rejected.
+func (m *Middleware) checkStepUp(w http.ResponseWriter, r *http.Request, match *router.Match, policy *Policy, user *UserContext, start time.Time) bool {
+	stepUp := match.Route.StepUp
+	if !stepUp.Enabled() || meetsStepUp(stepUp, user) {
+		return true
+	}
+
+	metrics.RecordAuthAttempt("failure")
+	metrics.RecordAuthFailure(ErrorInsufficientAuthenticationLevel)
+	m.logDecision(r, match, policy, user, false, "", "step-up authentication required", start)
+
+	challenge := `Bearer error="insufficient_user_authentication", error_description="A stronger authentication is required"`
+	details := map[string]interface{}{}
+	if len(stepUp.ACR) > 0 {
+		challenge += fmt.Sprintf(`, acr_values="%s"`, strings.Join(stepUp.ACR, " "))
+		details["required_acr"] = stepUp.ACR
+	}
+	if len(stepUp.AMR) > 0 {
+		details["required_amr"] = stepUp.AMR
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	m.writeError(w, r, http.StatusUnauthorized, ErrorInsufficientAuthenticationLevel, "This resource requires a stronger authentication", details)
+	return false
+}
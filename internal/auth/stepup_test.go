@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/middleware"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+func TestMiddleware_StepUp(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+	m, err := NewMiddleware(&config.AuthorizationConfig{
+		Enabled:             true,
+		CookieName:          "session_token",
+		JWTSigningAlgorithm: "HS256",
+		JWTSharedSecret:     "default-secret-key-for-hmac-tests",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create middleware: %v", err)
+	}
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	sign := func(extra jwt.MapClaims) string {
+		claims := jwt.MapClaims{"exp": time.Now().Add(time.Hour).Unix(), "user_id": "user123"}
+		for name, value := range extra {
+			claims[name] = value
+		}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("default-secret-key-for-hmac-tests"))
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+		return token
+	}
+	route := &router.Route{
+		PathPattern: "/payments/transfer",
+		AuthPolicy:  "authenticated",
+		StepUp:      config.StepUpConfig{ACR: []string{"urn:example:mfa", "urn:example:hwk"}, AMR: []string{"otp"}},
+	}
+
+	tests := []struct {
+		name           string
+		claims         jwt.MapClaims
+		expectedStatus int
+	}{
+		{"step-up satisfied", jwt.MapClaims{"acr": "urn:example:mfa", "amr": []string{"pwd", "otp"}}, http.StatusOK},
+		{"other accepted acr", jwt.MapClaims{"acr": "urn:example:hwk", "amr": "otp"}, http.StatusOK},
+		{"password only", jwt.MapClaims{"acr": "urn:example:pwd", "amr": []string{"pwd"}}, http.StatusUnauthorized},
+		{"missing amr", jwt.MapClaims{"acr": "urn:example:mfa"}, http.StatusUnauthorized},
+		{"no step-up claims", nil, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/payments/transfer", nil)
+			req = req.WithContext(middleware.WithRouteMatch(req.Context(), &router.Match{Route: route}))
+			req.AddCookie(&http.Cookie{Name: "session_token", Value: sign(tt.claims)})
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rec.Code, rec.Body.String())
+			}
+			if tt.expectedStatus == http.StatusOK {
+				return
+			}
+
+			var resp ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid error response: %v", err)
+			}
+			if resp.Error != ErrorInsufficientAuthenticationLevel {
+				t.Errorf("Expected error %s, got %s", ErrorInsufficientAuthenticationLevel, resp.Error)
+			}
+			if acr, _ := resp.Details["required_acr"].([]interface{}); len(acr) != 2 {
+				t.Errorf("Expected required_acr in details, got %v", resp.Details)
+			}
+			challenge := rec.Header().Get("WWW-Authenticate")
+			if !strings.Contains(challenge, `error="insufficient_user_authentication"`) || !strings.Contains(challenge, `acr_values="urn:example:mfa urn:example:hwk"`) {
+				t.Errorf("Unexpected challenge %q", challenge)
+			}
+		})
+	}
+}
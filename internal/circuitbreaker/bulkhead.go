@@ -0,0 +1,416 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+)
+
+// ErrBulkheadFull is returned when a backend has reached its in-flight limit
+var ErrBulkheadFull = errors.New("backend in-flight limit reached")
+
+// OverloadError is returned for requests rejected at a backend's in-flight
+// limit. It matches ErrBulkheadFull and tells the client when to retry.
+type OverloadError struct {
+	Name string
+	// Reason is queue_full, queue_timeout or displaced
+	Reason string
+	// RetryAfter estimates when the backend has room again
+	RetryAfter time.Duration
+}
+
+func (e *OverloadError) Error() string {
+	return fmt.Sprintf("%s: %s (%s)", ErrBulkheadFull, e.Name, e.Reason)
+}
+
+func (e *OverloadError) Unwrap() error {
+	return ErrBulkheadFull
+}
+
+// BulkheadConfig holds bulkhead configuration
+type BulkheadConfig struct {
+	// MaxConcurrent is the number of requests allowed in flight
+	MaxConcurrent int
+	// MaxQueue is the number of requests waiting for a slot; further
+	// requests are rejected unless they displace a lower priority waiter
+	MaxQueue int
+	// QueueTimeout is how long a request waits for a slot
+	QueueTimeout time.Duration
+	// RetryAfter is suggested to rejected clients until the rate at which
+	// the backend completes requests is known; MaxRetryAfter caps the
+	// suggestion derived from that rate
+	RetryAfter    time.Duration
+	MaxRetryAfter time.Duration
+}
+
+// BulkheadStats holds bulkhead statistics
+type BulkheadStats struct {
+	Name     string `json:"name"`
+	Limit    int    `json:"limit"`
+	InFlight int    `json:"in_flight"`
+	Queued   int    `json:"queued"`
+}
+
+// priorityClasses lists the request priority classes, highest first
+var priorityClasses = []string{"critical", "normal", "low"}
+
+// priorityRank returns the index of class in priorityClasses; unknown and
+// empty classes are normal
+func priorityRank(class string) int {
+	for i, c := range priorityClasses {
+		if c == class {
+			return i
+		}
+	}
+	return 1
+}
+
+// waiter is a request queued for a bulkhead slot. done is closed once the
+// waiter is granted a slot or displaced by a higher priority request.
+type waiter struct {
+	done      chan struct{}
+	granted   bool
+	displaced bool
+}
+
+// Bulkhead caps the number of concurrent requests to a backend. Requests
+// over the limit wait in a bounded queue, ordered by priority class, or are
+// rejected immediately if there is no room, so a slow backend cannot
+// accumulate goroutines and memory of its callers.
+type Bulkhead struct {
+	name  string
+	limit int
+
+	queueSize     int
+	queueTimeout  time.Duration
+	retryAfter    time.Duration // used until the drain rate is known
+	maxRetryAfter time.Duration
+
+	mu       sync.Mutex
+	inFlight int
+	queues   [][]*waiter // by priority rank
+	queued   int
+	drain    drainMeter
+	now      func() time.Time
+}
+
+// NewBulkhead creates a new bulkhead for the backend name
+func NewBulkhead(name string, config *BulkheadConfig) *Bulkhead {
+	return &Bulkhead{
+		name:          name,
+		limit:         config.MaxConcurrent,
+		queueSize:     config.MaxQueue,
+		queueTimeout:  config.QueueTimeout,
+		retryAfter:    config.RetryAfter,
+		maxRetryAfter: config.MaxRetryAfter,
+		queues:        make([][]*waiter, len(priorityClasses)),
+		now:           time.Now,
+	}
+}
+
+// Acquire takes a slot, waiting in the queue of the priority class (critical,
+// normal or low) if the backend is at its limit. It returns an
+// *OverloadError if the request is rejected, or ctx.Err() if the caller gave
+// up. Each successful Acquire must be followed by a Release.
+func (b *Bulkhead) Acquire(ctx context.Context, class string) error {
+	rank := priorityRank(class)
+
+	b.mu.Lock()
+	if b.inFlight < b.limit && b.queued == 0 {
+		b.inFlight++
+		b.mu.Unlock()
+		metrics.IncBackendInFlight(b.name)
+		return nil
+	}
+	if b.queued >= b.queueSize && !b.displaceLower(rank) {
+		err := b.overloaded("queue_full")
+		b.mu.Unlock()
+		return err
+	}
+	w := &waiter{done: make(chan struct{})}
+	b.queues[rank] = append(b.queues[rank], w)
+	b.queued++
+	b.recordDepth(rank)
+	b.mu.Unlock()
+
+	timer := time.NewTimer(b.queueTimeout)
+	defer timer.Stop()
+
+	var reason string
+	select {
+	case <-w.done:
+	case <-timer.C:
+		reason = "queue_timeout"
+	case <-ctx.Done():
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case w.granted:
+		metrics.IncBackendInFlight(b.name)
+		return nil
+	case w.displaced:
+		return b.overloaded("displaced")
+	}
+	b.remove(rank, w)
+	if reason == "" {
+		return ctx.Err()
+	}
+	return b.overloaded(reason)
+}
+
+// Release returns a slot and hands it to the highest priority waiter
+func (b *Bulkhead) Release() {
+	metrics.DecBackendInFlight(b.name)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.drain.record(b.now())
+	for rank, queue := range b.queues {
+		if len(queue) == 0 {
+			continue
+		}
+		w := queue[0]
+		b.queues[rank] = queue[1:]
+		b.queued--
+		b.recordDepth(rank)
+		w.granted = true
+		close(w.done)
+		return
+	}
+	b.inFlight--
+}
+
+// Stats returns the bulkhead's statistics
+func (b *Bulkhead) Stats() BulkheadStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return BulkheadStats{
+		Name:     b.name,
+		Limit:    b.limit,
+		InFlight: b.inFlight,
+		Queued:   b.queued,
+	}
+}
+
+// setLimit changes the in-flight limit, handing freed slots to waiters if
+// the limit was raised. Requests in flight keep their slots.
+func (b *Bulkhead) setLimit(limit int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.limit = limit
+	for b.inFlight < b.limit && b.queued > 0 {
+		for rank, queue := range b.queues {
+			if len(queue) == 0 {
+				continue
+			}
+			w := queue[0]
+			b.queues[rank] = queue[1:]
+			b.queued--
+			b.recordDepth(rank)
+			b.inFlight++
+			w.granted = true
+			close(w.done)
+			break
+		}
+	}
+}
+
+// displaceLower rejects the newest waiter of the lowest class below rank to
+// make room in a full queue, reporting whether there was one. b.mu is held.
+func (b *Bulkhead) displaceLower(rank int) bool {
+	for lower := len(b.queues) - 1; lower > rank; lower-- {
+		queue := b.queues[lower]
+		if len(queue) == 0 {
+			continue
+		}
+		w := queue[len(queue)-1]
+		b.queues[lower] = queue[:len(queue)-1]
+		b.queued--
+		b.recordDepth(lower)
+		w.displaced = true
+		close(w.done)
+		return true
+	}
+	return false
+}
+
+// remove takes a waiter that gave up out of its queue. b.mu is held.
+func (b *Bulkhead) remove(rank int, w *waiter) {
+	queue := b.queues[rank]
+	for i, queued := range queue {
+		if queued == w {
+			b.queues[rank] = append(queue[:i], queue[i+1:]...)
+			b.queued--
+			b.recordDepth(rank)
+			return
+		}
+	}
+}
+
+// overloaded records a rejection and returns its error. b.mu is held.
+func (b *Bulkhead) overloaded(reason string) error {
+	metrics.RecordBulkheadRejection(b.name)
+	return &OverloadError{Name: b.name, Reason: reason, RetryAfter: b.retryAfterLocked()}
+}
+
+// retryAfterLocked estimates how long the queued requests, and one more,
+// take to drain at the rate the backend currently completes requests. The
+// configured delay applies until that rate is known. b.mu is held.
+func (b *Bulkhead) retryAfterLocked() time.Duration {
+	rate := b.drain.perSecond(b.now())
+	if rate <= 0 {
+		return b.retryAfter
+	}
+	seconds := math.Ceil(float64(b.queued+1) / rate)
+	retryAfter := time.Duration(seconds) * time.Second
+	if retryAfter < time.Second {
+		retryAfter = time.Second
+	}
+	if b.maxRetryAfter > 0 && retryAfter > b.maxRetryAfter {
+		retryAfter = b.maxRetryAfter
+	}
+	return retryAfter
+}
+
+// recordDepth publishes the queue depth of a priority class. b.mu is held.
+func (b *Bulkhead) recordDepth(rank int) {
+	metrics.SetBulkheadQueueDepth(b.name, priorityClasses[rank], len(b.queues[rank]))
+}
+
+// drainMeter measures the rate at which a backend completes requests as an
+// exponentially weighted average over one-second windows
+type drainMeter struct {
+	windowStart time.Time
+	count       int
+	rate        float64
+}
+
+// record counts a completed request
+func (d *drainMeter) record(now time.Time) {
+	d.roll(now)
+	d.count++
+}
+
+// perSecond returns the completion rate; before the first window has closed
+// it is the rate within that window
+func (d *drainMeter) perSecond(now time.Time) float64 {
+	d.roll(now)
+	if d.rate == 0 && d.count > 0 {
+		if elapsed := now.Sub(d.windowStart).Seconds(); elapsed > 0 {
+			return float64(d.count) / elapsed
+		}
+	}
+	return d.rate
+}
+
+// roll closes the current window once it is a second old
+func (d *drainMeter) roll(now time.Time) {
+	if d.windowStart.IsZero() {
+		d.windowStart = now
+		return
+	}
+	elapsed := now.Sub(d.windowStart)
+	if elapsed < time.Second {
+		return
+	}
+	current := float64(d.count) / elapsed.Seconds()
+	if d.rate == 0 {
+		d.rate = current
+	} else {
+		d.rate = 0.7*d.rate + 0.3*current
+	}
+	d.count = 0
+	d.windowStart = now
+}
+
+// bulkheadKey identifies the bulkhead of a backend with the given limit
+func bulkheadKey(name string, limit int) string {
+	return fmt.Sprintf("%s|%d", name, limit)
+}
+
+// Bulkhead gets or creates the bulkhead of the backend name with the
+// in-flight limit of config, or returns nil if config has no limit. Callers
+// with different limits for the same backend get separate bulkheads; the
+// other settings apply to new bulkheads only.
+func (m *Manager) Bulkhead(name string, config *BulkheadConfig) *Bulkhead {
+	if config.MaxConcurrent <= 0 {
+		return nil
+	}
+
+	key := bulkheadKey(name, config.MaxConcurrent)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if bh, ok := m.bulkheads[key]; ok {
+		return bh
+	}
+	bh := NewBulkhead(name, config)
+	m.bulkheads[key] = bh
+
+	m.logger.Info("bulkhead created", logger.Fields{
+		"name":  name,
+		"limit": config.MaxConcurrent,
+	})
+
+	return bh
+}
+
+// BulkheadStats returns statistics for all bulkheads
+func (m *Manager) BulkheadStats() []BulkheadStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := make([]BulkheadStats, 0, len(m.bulkheads))
+	for _, bh := range m.bulkheads {
+		stats = append(stats, bh.Stats())
+	}
+	return stats
+}
+
+// PruneBulkheads migrates bulkheads whose limit is no longer in use to a new
+// limit of their backend, keeping their requests in flight and queued, and
+// forgets those of backends no longer limited. limits are the in-flight
+// limits in use by backend. It returns how many bulkheads were forgotten;
+// requests holding one still release their slot on it.
+func (m *Manager) PruneBulkheads(limits map[string][]int) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	orphaned := make(map[string]*Bulkhead)
+	for key, bh := range m.bulkheads {
+		if !slices.Contains(limits[bh.name], bh.limit) {
+			orphaned[key] = bh
+		}
+	}
+
+	pruned := 0
+	for key, bh := range orphaned {
+		delete(m.bulkheads, key)
+		migrated := false
+		for _, limit := range limits[bh.name] {
+			newKey := bulkheadKey(bh.name, limit)
+			if _, exists := m.bulkheads[newKey]; !exists {
+				bh.setLimit(limit)
+				m.bulkheads[newKey] = bh
+				migrated = true
+				break
+			}
+		}
+		if !migrated {
+			pruned++
+		}
+	}
+	return pruned
+}
@@ -0,0 +1,193 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestBulkhead(limit, queueSize int) *Bulkhead {
+	return NewBulkhead("http://backend", &BulkheadConfig{
+		MaxConcurrent: limit,
+		MaxQueue:      queueSize,
+		QueueTimeout:  time.Second,
+		RetryAfter:    time.Second,
+		MaxRetryAfter: 30 * time.Second,
+	})
+}
+
+// queueWaiter starts an acquire in class and waits until it is queued
+func queueWaiter(t *testing.T, bh *Bulkhead, class string) <-chan error {
+	t.Helper()
+	bh.mu.Lock()
+	queued := bh.queued
+	bh.mu.Unlock()
+
+	result := make(chan error, 1)
+	go func() { result <- bh.Acquire(context.Background(), class) }()
+	for deadline := time.Now().Add(time.Second); ; {
+		bh.mu.Lock()
+		n := bh.queued
+		bh.mu.Unlock()
+		if n > queued {
+			return result
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("request was not queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBulkheadQueuePriority(t *testing.T) {
+	bh := newTestBulkhead(1, 2)
+	if err := bh.Acquire(context.Background(), "normal"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	low := queueWaiter(t, bh, "low")
+	critical := queueWaiter(t, bh, "critical")
+
+	// The queue is full: a normal request displaces the low priority waiter,
+	// another low priority request is shed
+	normal := make(chan error, 1)
+	go func() { normal <- bh.Acquire(context.Background(), "normal") }()
+	var overload *OverloadError
+	if err := <-low; !errors.As(err, &overload) || overload.Reason != "displaced" {
+		t.Fatalf("expected low priority waiter to be displaced, got %v", err)
+	}
+	if err := bh.Acquire(context.Background(), "low"); !errors.As(err, &overload) || overload.Reason != "queue_full" {
+		t.Fatalf("expected low priority request to be shed, got %v", err)
+	}
+
+	// Freed slots go to the critical waiter first
+	bh.Release()
+	if err := <-critical; err != nil {
+		t.Fatalf("expected critical waiter to get the slot, got %v", err)
+	}
+	select {
+	case err := <-normal:
+		t.Fatalf("normal waiter got a slot before it was free: %v", err)
+	default:
+	}
+	bh.Release()
+	if err := <-normal; err != nil {
+		t.Fatalf("expected normal waiter to get the slot, got %v", err)
+	}
+	bh.Release()
+
+	bh.mu.Lock()
+	defer bh.mu.Unlock()
+	if bh.inFlight != 0 || bh.queued != 0 {
+		t.Errorf("expected an idle bulkhead, got %d in flight and %d queued", bh.inFlight, bh.queued)
+	}
+}
+
+func TestBulkheadQueueTimeout(t *testing.T) {
+	bh := newTestBulkhead(1, 1)
+	bh.queueTimeout = 10 * time.Millisecond
+	if err := bh.Acquire(context.Background(), ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var overload *OverloadError
+	if err := bh.Acquire(context.Background(), ""); !errors.As(err, &overload) || overload.Reason != "queue_timeout" {
+		t.Fatalf("expected queue timeout, got %v", err)
+	}
+	if !errors.Is(overload, ErrBulkheadFull) {
+		t.Error("expected OverloadError to match ErrBulkheadFull")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := bh.Acquire(ctx, ""); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context cancellation, got %v", err)
+	}
+	if bh.queued != 0 {
+		t.Errorf("expected abandoned waiters to leave the queue, got %d queued", bh.queued)
+	}
+}
+
+func TestBulkheadRetryAfter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	bh := newTestBulkhead(1, 10)
+	bh.now = func() time.Time { return now }
+
+	// Without a drain rate the configured delay applies
+	if got := bh.retryAfterLocked(); got != time.Second {
+		t.Errorf("expected fallback of 1s, got %v", got)
+	}
+
+	// Two completions per second for a few windows
+	for i := 0; i < 4; i++ {
+		bh.drain.record(now)
+		bh.drain.record(now)
+		now = now.Add(time.Second)
+	}
+
+	tests := []struct {
+		queued   int
+		expected time.Duration
+	}{
+		{0, time.Second},
+		{5, 3 * time.Second},
+		{9, 5 * time.Second},
+		{100, 30 * time.Second}, // capped
+	}
+	for _, tt := range tests {
+		bh.queued = tt.queued
+		if got := bh.retryAfterLocked(); got != tt.expected {
+			t.Errorf("queued %d: expected Retry-After %v, got %v", tt.queued, tt.expected, got)
+		}
+	}
+}
+
+func TestManagerBulkhead(t *testing.T) {
+	m := NewManager()
+	config := &BulkheadConfig{MaxConcurrent: 1, MaxQueue: 1, QueueTimeout: time.Second}
+
+	if m.Bulkhead("http://orders", &BulkheadConfig{}) != nil {
+		t.Error("expected no bulkhead without a limit")
+	}
+	bh := m.Bulkhead("http://orders", config)
+	if m.Bulkhead("http://orders", config) != bh {
+		t.Error("expected the bulkhead of a backend to be reused")
+	}
+	if m.Bulkhead("http://orders", &BulkheadConfig{MaxConcurrent: 2}) == bh {
+		t.Error("expected a separate bulkhead for another limit")
+	}
+	if len(m.BulkheadStats()) != 2 {
+		t.Errorf("expected 2 bulkheads, got %d", len(m.BulkheadStats()))
+	}
+}
+
+func TestPruneBulkheads(t *testing.T) {
+	m := NewManager()
+	config := &BulkheadConfig{MaxConcurrent: 1, MaxQueue: 1, QueueTimeout: time.Second}
+
+	bh := m.Bulkhead("http://orders", config)
+	m.Bulkhead("http://users", config)
+	if err := bh.Acquire(context.Background(), "normal"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waiter := queueWaiter(t, bh, "normal")
+
+	// Raising the limit keeps the request in flight and admits the waiter;
+	// the bulkhead of a backend no longer limited is forgotten
+	if pruned := m.PruneBulkheads(map[string][]int{"http://orders": {2}}); pruned != 1 {
+		t.Errorf("expected 1 bulkhead to be forgotten, got %d", pruned)
+	}
+	if err := <-waiter; err != nil {
+		t.Fatalf("expected the queued request to be admitted, got %v", err)
+	}
+	if m.Bulkhead("http://orders", &BulkheadConfig{MaxConcurrent: 2}) != bh {
+		t.Fatal("expected the bulkhead to be migrated to the new limit")
+	}
+	if stats := bh.Stats(); stats.Limit != 2 || stats.InFlight != 2 {
+		t.Errorf("expected limit 2 with 2 in flight, got %+v", stats)
+	}
+	if len(m.BulkheadStats()) != 1 {
+		t.Errorf("expected 1 bulkhead left, got %d", len(m.BulkheadStats()))
+	}
+}
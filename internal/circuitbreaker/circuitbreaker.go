@@ -1,9 +1,12 @@
 package circuitbreaker
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/maltehedderich/api-gateway-go/internal/logger"
@@ -49,8 +52,39 @@ type Config struct {
 	SuccessThreshold int
 	// Timeout is how long to wait in open state before trying half-open
 	Timeout time.Duration
-	// MaxRequests is the maximum number of requests allowed in half-open state
+	// MaxRequests is the maximum number of probe requests in flight in
+	// half-open state; further requests are rejected until probes complete
 	MaxRequests int
+
+	// Probe, if set, tests recovery in half-open state instead of user
+	// requests, which are rejected until SuccessThreshold probes in a row
+	// succeeded. Probes start once Timeout elapsed, without waiting for a
+	// request, and are ProbeInterval apart and, if set, bounded by
+	// ProbeTimeout.
+	Probe         Prober
+	ProbeInterval time.Duration
+	ProbeTimeout  time.Duration
+
+	// Classifier decides which errors count as failures; without one,
+	// DefaultClassifier ignores cancelled calls
+	Classifier ErrorClassifier
+
+	// Window enables sliding window mode: instead of counting consecutive
+	// failures, the breaker opens once at least MinimumCalls calls were made
+	// within Window and FailureRateThreshold percent of them failed or
+	// SlowCallRateThreshold percent took SlowCallDuration or longer. A
+	// threshold of 0 is not checked.
+	Window                time.Duration
+	MinimumCalls          int
+	FailureRateThreshold  float64
+	SlowCallRateThreshold float64
+	SlowCallDuration      time.Duration
+}
+
+// Prober checks whether the backend of a breaker recovered, such as by
+// calling its health endpoint
+type Prober interface {
+	Probe(ctx context.Context) error
 }
 
 // DefaultConfig returns default circuit breaker configuration
@@ -65,16 +99,21 @@ func DefaultConfig() *Config {
 
 // CircuitBreaker implements the circuit breaker pattern
 type CircuitBreaker struct {
-	name            string
-	config          *Config
-	state           State
-	failures        int
-	successes       int
-	lastFailureTime time.Time
-	lastStateChange time.Time
+	name             string
+	config           *Config
+	state            State
+	failures         int
+	successes        int
+	lastFailureTime  time.Time
+	lastStateChange  time.Time
 	halfOpenRequests int
-	mu              sync.RWMutex
-	logger          *logger.ComponentLogger
+	generation       uint64 // incremented on every state change
+	disposed         bool
+	lastUsed         atomic.Int64 // unix nanoseconds of the last Manager.Get
+	calls            callWindow
+	onChange         func(Event)
+	mu               sync.RWMutex
+	logger           *logger.ComponentLogger
 }
 
 // New creates a new circuit breaker
@@ -92,68 +131,223 @@ func New(name string, config *Config) *CircuitBreaker {
 	}
 }
 
+// configure replaces the configuration of the breaker if config differs
+func (cb *CircuitBreaker) configure(config *Config) {
+	if config == nil {
+		return
+	}
+
+	cb.mu.RLock()
+	same := cb.config.equal(config)
+	cb.mu.RUnlock()
+	if same {
+		return
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	// Buckets of another window size do not line up
+	if cb.config.Window != config.Window {
+		cb.calls.reset()
+	}
+	updated := *config
+	cb.config = &updated
+}
+
+// equal reports whether c and other configure a breaker alike. Probes and
+// classifiers that cannot be compared, such as funcs, never count as equal,
+// so a breaker always takes them over.
+func (c *Config) equal(other *Config) bool {
+	a, b := *c, *other
+	a.Probe, b.Probe = nil, nil
+	a.Classifier, b.Classifier = nil, nil
+	return a == b && sameValue(c.Probe, other.Probe) && sameValue(c.Classifier, other.Classifier)
+}
+
+// sameValue compares a and b without panicking on uncomparable values
+func sameValue(a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Type() != vb.Type() || !va.Comparable() || !vb.Comparable() {
+		return false
+	}
+	return a == b
+}
+
 // Execute executes a function with circuit breaker protection
 func (cb *CircuitBreaker) Execute(fn func() error) error {
 	// Check if request is allowed
-	if err := cb.beforeRequest(); err != nil {
+	probe, generation, err := cb.beforeRequest()
+	if err != nil {
 		return err
 	}
 
 	// Execute function
-	err := fn()
+	start := time.Now()
+	err = fn()
 
 	// Record result
-	cb.afterRequest(err)
+	cb.afterRequest(err, time.Since(start), probe, generation)
 
 	return err
 }
 
-// beforeRequest checks if the request is allowed
-func (cb *CircuitBreaker) beforeRequest() error {
+// beforeRequest checks if the request is allowed. It reports whether the
+// request probes a half-open breaker and the generation it was admitted in.
+func (cb *CircuitBreaker) beforeRequest() (bool, uint64, error) {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
 	switch cb.state {
 	case StateClosed:
 		// Allow request
-		return nil
+		return false, cb.generation, nil
 
 	case StateOpen:
 		// Check if timeout has elapsed
-		if time.Since(cb.lastStateChange) >= cb.config.Timeout {
-			// Transition to half-open
-			cb.setState(StateHalfOpen)
-			cb.halfOpenRequests = 0
-			return nil
+		if time.Since(cb.lastStateChange) < cb.config.Timeout {
+			return false, 0, ErrCircuitOpen
+		}
+		if cb.config.Probe != nil {
+			// Probes test recovery; requests wait for the result
+			cb.startProbing()
+			return false, 0, ErrCircuitOpen
 		}
-		// Circuit is still open
-		return ErrCircuitOpen
+		// Transition to half-open with this request as the first probe
+		cb.setState(StateHalfOpen, "timeout elapsed")
+		cb.halfOpenRequests = 1
+		return true, cb.generation, nil
 
 	case StateHalfOpen:
-		// Allow limited requests
-		if cb.halfOpenRequests >= cb.config.MaxRequests {
-			return ErrCircuitOpen
+		// Allow limited probes in flight
+		if cb.config.Probe != nil || cb.halfOpenRequests >= cb.config.MaxRequests {
+			return false, 0, ErrCircuitOpen
 		}
 		cb.halfOpenRequests++
-		return nil
+		return true, cb.generation, nil
 
 	default:
-		return ErrCircuitOpen
+		return false, 0, ErrCircuitOpen
 	}
 }
 
-// afterRequest records the result of a request
-func (cb *CircuitBreaker) afterRequest(err error) {
+// afterRequest records the result of a request that took duration. probe
+// and generation are the results of beforeRequest for the request.
+func (cb *CircuitBreaker) afterRequest(err error, duration time.Duration, probe bool, generation uint64) {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
-	if err != nil {
+	// A completed probe frees its slot, unless the state changed meanwhile
+	if probe && generation == cb.generation {
+		cb.halfOpenRequests--
+	}
+
+	failed := err != nil
+	if failed && !cb.classify(err).Failure() {
+		// Neither a success nor a failure of the backend, such as a call
+		// the client cancelled
+		return
+	}
+	if cb.config.Window > 0 {
+		slow := cb.config.SlowCallDuration > 0 && duration >= cb.config.SlowCallDuration
+		if cb.state == StateClosed {
+			cb.calls.record(time.Now(), cb.config.Window, failed, slow)
+		} else if slow && cb.config.SlowCallRateThreshold > 0 {
+			// A slow probe shows the backend has not recovered yet
+			failed = true
+		}
+	}
+	cb.record(failed)
+
+	if cb.state == StateClosed && cb.config.Window > 0 {
+		cb.checkRates()
+	}
+}
+
+// classify returns the class of the error of a request
+func (cb *CircuitBreaker) classify(err error) ErrorClass {
+	if cb.config.Classifier == nil {
+		return DefaultClassifier{}.Classify(err)
+	}
+	return cb.config.Classifier.Classify(err)
+}
+
+// record counts a failed or successful request
+func (cb *CircuitBreaker) record(failed bool) {
+	if failed {
 		cb.onFailure()
 	} else {
 		cb.onSuccess()
 	}
 }
 
+// startProbing switches to half-open and probes the backend in the
+// background; the caller holds mu
+func (cb *CircuitBreaker) startProbing() {
+	cb.setState(StateHalfOpen, "timeout elapsed")
+	go cb.probe(cb.generation, *cb.config)
+}
+
+// probe runs probes until one fails, SuccessThreshold succeeded or the
+// state of the breaker changed otherwise, such as by a reset
+func (cb *CircuitBreaker) probe(generation uint64, config Config) {
+	for {
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if config.ProbeTimeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, config.ProbeTimeout)
+		}
+		start := time.Now()
+		err := config.Probe.Probe(ctx)
+		duration := time.Since(start)
+		cancel()
+
+		cb.mu.Lock()
+		if cb.generation != generation || cb.disposed {
+			cb.mu.Unlock()
+			return
+		}
+		slow := config.Window > 0 && config.SlowCallDuration > 0 && config.SlowCallRateThreshold > 0 && duration >= config.SlowCallDuration
+		cb.record(err != nil || slow)
+		done := cb.generation != generation
+		cb.mu.Unlock()
+		if done {
+			return
+		}
+
+		time.Sleep(config.ProbeInterval)
+	}
+}
+
+// scheduleProbes starts probing after delay, once the open breaker's
+// timeout elapsed; the caller holds mu
+func (cb *CircuitBreaker) scheduleProbes(delay time.Duration) {
+	generation := cb.generation
+	time.AfterFunc(delay, func() {
+		cb.mu.Lock()
+		defer cb.mu.Unlock()
+		if cb.generation == generation && !cb.disposed && cb.state == StateOpen && cb.config.Probe != nil {
+			cb.startProbing()
+		}
+	})
+}
+
+// checkRates opens the breaker if the failure or slow call rate of the
+// sliding window reached its threshold
+func (cb *CircuitBreaker) checkRates() {
+	calls, failures, slow := cb.calls.totals(time.Now(), cb.config.Window)
+	if calls < cb.config.MinimumCalls {
+		return
+	}
+	failureRate, slowRate := rates(calls, failures, slow)
+	if cb.config.FailureRateThreshold > 0 && failureRate >= cb.config.FailureRateThreshold {
+		cb.setState(StateOpen, "failure rate threshold reached")
+	} else if cb.config.SlowCallRateThreshold > 0 && slowRate >= cb.config.SlowCallRateThreshold {
+		cb.setState(StateOpen, "slow call rate threshold reached")
+	}
+}
+
 // onFailure handles a failed request
 func (cb *CircuitBreaker) onFailure() {
 	cb.failures++
@@ -162,13 +356,14 @@ func (cb *CircuitBreaker) onFailure() {
 
 	switch cb.state {
 	case StateClosed:
-		if cb.failures >= cb.config.FailureThreshold {
-			cb.setState(StateOpen)
+		// Sliding windows are checked by rate instead
+		if cb.config.Window == 0 && cb.failures >= cb.config.FailureThreshold {
+			cb.setState(StateOpen, "failure threshold reached")
 		}
 
 	case StateHalfOpen:
 		// Any failure in half-open goes back to open
-		cb.setState(StateOpen)
+		cb.setState(StateOpen, "probe failed")
 	}
 }
 
@@ -184,22 +379,36 @@ func (cb *CircuitBreaker) onSuccess() {
 	case StateHalfOpen:
 		if cb.successes >= cb.config.SuccessThreshold {
 			// Transition to closed
-			cb.setState(StateClosed)
+			cb.setState(StateClosed, "probes succeeded")
 			cb.failures = 0
-			cb.halfOpenRequests = 0
 		}
 	}
 }
 
-// setState changes the circuit breaker state
-func (cb *CircuitBreaker) setState(newState State) {
+// setState changes the circuit breaker state for reason
+func (cb *CircuitBreaker) setState(newState State, reason string) {
+	cb.setStateSince(newState, reason, time.Now())
+}
+
+// setStateSince changes the circuit breaker state for reason as of since
+func (cb *CircuitBreaker) setStateSince(newState State, reason string, since time.Time) {
 	if cb.state == newState {
 		return
 	}
 
 	oldState := cb.state
 	cb.state = newState
-	cb.lastStateChange = time.Now()
+	cb.lastStateChange = since
+	cb.generation++
+	cb.halfOpenRequests = 0
+	cb.calls.reset()
+	if newState == StateHalfOpen {
+		// Only probes count towards closing again
+		cb.successes = 0
+	}
+	if newState == StateOpen && cb.config.Probe != nil && !cb.disposed {
+		cb.scheduleProbes(max(0, cb.config.Timeout-time.Since(since)))
+	}
 
 	// Record metrics
 	metrics.SetCircuitBreakerState(cb.name, int(newState))
@@ -209,9 +418,39 @@ func (cb *CircuitBreaker) setState(newState State) {
 		"name":      cb.name,
 		"old_state": oldState.String(),
 		"new_state": newState.String(),
+		"reason":    reason,
 		"failures":  cb.failures,
 		"successes": cb.successes,
 	})
+
+	if cb.onChange != nil {
+		cb.onChange(Event{
+			Name:     cb.name,
+			From:     oldState,
+			To:       newState,
+			Reason:   reason,
+			Failures: cb.failures,
+			Time:     cb.lastStateChange,
+		})
+	}
+}
+
+// Ready reports whether the breaker would let a request through now, without
+// reserving a half-open slot for it
+func (cb *CircuitBreaker) Ready() bool {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	switch cb.state {
+	case StateClosed:
+		return true
+	case StateOpen:
+		return cb.config.Probe == nil && time.Since(cb.lastStateChange) >= cb.config.Timeout
+	case StateHalfOpen:
+		return cb.config.Probe == nil && cb.halfOpenRequests < cb.config.MaxRequests
+	default:
+		return false
+	}
 }
 
 // GetState returns the current state
@@ -226,7 +465,7 @@ func (cb *CircuitBreaker) GetStats() Stats {
 	cb.mu.RLock()
 	defer cb.mu.RUnlock()
 
-	return Stats{
+	stats := Stats{
 		Name:            cb.name,
 		State:           cb.state,
 		Failures:        cb.failures,
@@ -234,6 +473,12 @@ func (cb *CircuitBreaker) GetStats() Stats {
 		LastFailureTime: cb.lastFailureTime,
 		LastStateChange: cb.lastStateChange,
 	}
+	if cb.config.Window > 0 {
+		calls, failures, slow := cb.calls.totals(time.Now(), cb.config.Window)
+		stats.Calls = calls
+		stats.FailureRate, stats.SlowCallRate = rates(calls, failures, slow)
+	}
+	return stats
 }
 
 // Stats contains circuit breaker statistics
@@ -244,6 +489,11 @@ type Stats struct {
 	Successes       int
 	LastFailureTime time.Time
 	LastStateChange time.Time
+	// Calls made within the sliding window and the percentage of them that
+	// failed or were slow; zero unless the breaker uses a sliding window
+	Calls        int
+	FailureRate  float64
+	SlowCallRate float64
 }
 
 // Reset resets the circuit breaker to closed state
@@ -251,10 +501,10 @@ func (cb *CircuitBreaker) Reset() {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
-	cb.state = StateClosed
+	cb.setState(StateClosed, "reset")
 	cb.failures = 0
 	cb.successes = 0
-	cb.halfOpenRequests = 0
+	cb.calls.reset()
 	cb.lastStateChange = time.Now()
 
 	cb.logger.Info("circuit breaker reset", logger.Fields{
@@ -262,28 +512,53 @@ func (cb *CircuitBreaker) Reset() {
 	})
 }
 
-// Manager manages multiple circuit breakers
+// dispose stops the probes of a breaker that is no longer used
+func (cb *CircuitBreaker) dispose() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.disposed = true
+}
+
+// Manager manages multiple circuit breakers and the bulkheads of the same
+// backends
 type Manager struct {
-	breakers map[string]*CircuitBreaker
-	mu       sync.RWMutex
-	logger   *logger.ComponentLogger
+	breakers  map[string]*CircuitBreaker
+	bulkheads map[string]*Bulkhead // by name and limit
+	mu        sync.RWMutex
+	logger    *logger.ComponentLogger
+
+	history   []Event // ring of the last historySize state changes
+	next      int
+	listeners []Listener
+	eventsMu  sync.Mutex
+
+	restored map[string]Snapshot // by name, applied when the breaker is created
+
+	gc        GCConfig
+	lastSweep time.Time
+	now       func() time.Time
 }
 
 // NewManager creates a new circuit breaker manager
 func NewManager() *Manager {
 	return &Manager{
-		breakers: make(map[string]*CircuitBreaker),
-		logger:   logger.Get().WithComponent("circuitbreaker.manager"),
+		breakers:  make(map[string]*CircuitBreaker),
+		bulkheads: make(map[string]*Bulkhead),
+		logger:    logger.Get().WithComponent("circuitbreaker.manager"),
+		now:       time.Now,
 	}
 }
 
-// Get gets or creates a circuit breaker for a backend
+// Get gets or creates a circuit breaker for a backend. An existing breaker
+// takes over config if it differs, such as after a reload, keeping its state.
 func (m *Manager) Get(name string, config *Config) *CircuitBreaker {
 	m.mu.RLock()
 	cb, exists := m.breakers[name]
 	m.mu.RUnlock()
 
 	if exists {
+		cb.touch(m.now())
+		cb.configure(config)
 		return cb
 	}
 
@@ -292,12 +567,21 @@ func (m *Manager) Get(name string, config *Config) *CircuitBreaker {
 
 	// Double-check after acquiring write lock
 	if cb, exists := m.breakers[name]; exists {
+		cb.touch(m.now())
+		cb.configure(config)
 		return cb
 	}
 
-	// Create new circuit breaker
+	// Create new circuit breaker, evicting others if over the limits
+	m.collect()
 	cb = New(name, config)
+	cb.touch(m.now())
+	cb.onChange = m.record
 	m.breakers[name] = cb
+	if snapshot, ok := m.restored[name]; ok {
+		delete(m.restored, name)
+		cb.restore(snapshot)
+	}
 
 	m.logger.Info("circuit breaker created", logger.Fields{
 		"name": name,
@@ -344,3 +628,28 @@ func (m *Manager) ResetAll() {
 
 	m.logger.Info("all circuit breakers reset")
 }
+
+// Prune disposes the breakers whose name keep rejects, such as breakers of
+// backends no longer referenced by any route after a reload, and returns how
+// many were disposed. A backend that comes back starts with a closed breaker.
+func (m *Manager) Prune(keep func(name string) bool) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pruned := 0
+	for name := range m.breakers {
+		if keep(name) {
+			continue
+		}
+		m.breakers[name].dispose()
+		delete(m.breakers, name)
+		metrics.DeleteCircuitBreakerState(name)
+		pruned++
+
+		m.logger.Info("circuit breaker disposed", logger.Fields{
+			"name": name,
+		})
+	}
+
+	return pruned
+}
@@ -1,9 +1,14 @@
 package circuitbreaker
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"net"
 	"os"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -286,11 +291,10 @@ func TestHalfOpenFailureGoesBackToOpen(t *testing.T) {
 }
 
 func TestHalfOpenMaxRequests(t *testing.T) {
-	t.Skip("Skipping due to timing sensitivity - circuit breaker transitions to closed state")
 	cb := New("test", &Config{
 		FailureThreshold: 2,
-		SuccessThreshold: 3, // Higher than MaxRequests to stay in half-open
-		Timeout:          100 * time.Millisecond,
+		SuccessThreshold: 3, // Higher than MaxRequests: probes are sent in turns
+		Timeout:          20 * time.Millisecond,
 		MaxRequests:      2,
 	})
 
@@ -308,31 +312,330 @@ func TestHalfOpenMaxRequests(t *testing.T) {
 	}
 
 	// Wait for timeout
-	time.Sleep(150 * time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
 
-	// Execute max requests (circuit should stay half-open due to SuccessThreshold > MaxRequests)
+	// Fill the half-open slots with probes in flight
+	done := make(chan struct{})
+	var wg sync.WaitGroup
 	for i := 0; i < 2; i++ {
-		err := cb.Execute(func() error {
-			return nil
-		})
-		if err != nil {
-			t.Errorf("request %d: expected no error, got %v", i, err)
-		}
+		probing := make(chan struct{})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := cb.Execute(func() error {
+				close(probing)
+				<-done
+				return nil
+			}); err != nil {
+				t.Errorf("probe: expected no error, got %v", err)
+			}
+		}()
+		<-probing
+	}
+
+	// Next request should be rejected because max requests are in flight
+	err := cb.Execute(func() error {
+		t.Error("function should not be called when max requests exceeded")
+		return nil
+	})
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
 	}
 
-	// Verify we're still in half-open
+	close(done)
+	wg.Wait()
+
+	// Completed probes free their slots, and the circuit stays half-open
+	// until SuccessThreshold probes succeeded
 	if cb.GetState() != StateHalfOpen {
-		t.Logf("Circuit transitioned to %s after %d successful requests", cb.GetState(), 2)
+		t.Fatalf("expected state %s, got %s", StateHalfOpen, cb.GetState())
+	}
+	if err := cb.Execute(func() error { return nil }); err != nil {
+		t.Errorf("expected no error, got %v", err)
 	}
+	if cb.GetState() != StateClosed {
+		t.Errorf("expected state %s, got %s", StateClosed, cb.GetState())
+	}
+}
+
+// testProber fails while failing is set and counts its probes
+type testProber struct {
+	failing *atomic.Bool
+	probes  *atomic.Int32
+}
+
+func (p testProber) Probe(ctx context.Context) error {
+	p.probes.Add(1)
+	if p.failing.Load() {
+		return errors.New("unhealthy")
+	}
+	return nil
+}
+
+// waitForState waits up to a second for cb to reach state
+func waitForState(t *testing.T, cb *CircuitBreaker, state State) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for cb.GetState() != state {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected state %s, got %s", state, cb.GetState())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	prober := testProber{failing: &atomic.Bool{}, probes: &atomic.Int32{}}
+	prober.failing.Store(true)
 
-	// Next request should be rejected because max requests reached
+	m := NewManager()
+	cb := m.Get("test", &Config{
+		FailureThreshold: 1,
+		SuccessThreshold: 2,
+		Timeout:          20 * time.Millisecond,
+		MaxRequests:      1,
+		Probe:            prober,
+		ProbeInterval:    5 * time.Millisecond,
+		ProbeTimeout:     time.Second,
+	})
+
+	_ = cb.Execute(func() error { return errors.New("test error") })
+	if cb.GetState() != StateOpen {
+		t.Fatalf("expected state %s, got %s", StateOpen, cb.GetState())
+	}
+
+	// Probes start without requests and reopen the circuit on failure
+	deadline := time.Now().Add(time.Second)
+	for prober.probes.Load() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected probes after the timeout elapsed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Requests are rejected until the probes succeeded
+	prober.failing.Store(false)
 	err := cb.Execute(func() error {
-		t.Error("function should not be called when max requests exceeded")
+		if cb.GetState() != StateClosed {
+			t.Error("request let through before the probes succeeded")
+		}
 		return nil
 	})
+	if err != nil && !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if cb.Ready() && cb.GetState() != StateClosed {
+		t.Error("expected probing breaker not to be ready")
+	}
 
-	if !errors.Is(err, ErrCircuitOpen) {
-		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	waitForState(t, cb, StateClosed)
+	if err := cb.Execute(func() error { return nil }); err != nil {
+		t.Errorf("expected no error once closed, got %v", err)
+	}
+
+	reasons := map[string]bool{}
+	for _, event := range m.History("test") {
+		reasons[event.Reason] = true
+	}
+	for _, reason := range []string{"probe failed", "probes succeeded"} {
+		if !reasons[reason] {
+			t.Errorf("expected a state change for %q, got %v", reason, m.History("test"))
+		}
+	}
+}
+
+func TestPruneStopsProbes(t *testing.T) {
+	prober := testProber{failing: &atomic.Bool{}, probes: &atomic.Int32{}}
+	prober.failing.Store(true)
+
+	m := NewManager()
+	cb := m.Get("test", &Config{
+		FailureThreshold: 1,
+		SuccessThreshold: 1,
+		Timeout:          10 * time.Millisecond,
+		MaxRequests:      1,
+		Probe:            prober,
+		ProbeInterval:    5 * time.Millisecond,
+	})
+	_ = cb.Execute(func() error { return errors.New("test error") })
+
+	m.Prune(func(string) bool { return false })
+	time.Sleep(30 * time.Millisecond)
+	if n := prober.probes.Load(); n != 0 {
+		t.Errorf("expected no probes of a pruned breaker, got %d", n)
+	}
+}
+
+func TestCancelledCallsAreNoFailures(t *testing.T) {
+	cb := New("test", &Config{FailureThreshold: 1, SuccessThreshold: 1, Timeout: 20 * time.Millisecond, MaxRequests: 1})
+
+	_ = cb.Execute(func() error { return context.Canceled })
+	if cb.GetState() != StateClosed {
+		t.Fatalf("expected a cancelled call to leave the circuit closed, got %s", cb.GetState())
+	}
+
+	// A cancelled probe neither closes nor reopens the circuit, and frees
+	// its slot for the next probe
+	_ = cb.Execute(func() error { return errors.New("test error") })
+	time.Sleep(30 * time.Millisecond)
+	_ = cb.Execute(func() error { return context.Canceled })
+	if cb.GetState() != StateHalfOpen {
+		t.Fatalf("expected state %s after a cancelled probe, got %s", StateHalfOpen, cb.GetState())
+	}
+	if err := cb.Execute(func() error { return nil }); err != nil {
+		t.Errorf("expected the next probe to be let through, got %v", err)
+	}
+	if cb.GetState() != StateClosed {
+		t.Errorf("expected state %s, got %s", StateClosed, cb.GetState())
+	}
+}
+
+func TestDefaultClassifier(t *testing.T) {
+	tests := []struct {
+		err       error
+		want      ErrorClass
+		failure   bool
+		retryable bool
+	}{
+		{nil, ErrorClassNone, false, false},
+		{context.Canceled, ErrorClassClientCancel, false, false},
+		{fmt.Errorf("attempt: %w", context.DeadlineExceeded), ErrorClassTimeout, true, true},
+		{&net.DNSError{Err: "no such host", Name: "orders"}, ErrorClassConnection, true, true},
+		{io.EOF, ErrorClassConnection, true, true},
+		{errors.New("test error"), ErrorClassOther, true, false},
+	}
+
+	for _, tt := range tests {
+		class := DefaultClassifier{}.Classify(tt.err)
+		if class != tt.want {
+			t.Errorf("Classify(%v) = %s, want %s", tt.err, class, tt.want)
+		}
+		if class.Failure() != tt.failure || class.Retryable() != tt.retryable {
+			t.Errorf("%s: expected failure %t and retryable %t", class, tt.failure, tt.retryable)
+		}
+	}
+}
+
+func TestSlidingWindowFailureRate(t *testing.T) {
+	cb := New("test", &Config{
+		FailureThreshold:     1,
+		SuccessThreshold:     1,
+		Timeout:              time.Minute,
+		MaxRequests:          1,
+		Window:               time.Minute,
+		MinimumCalls:         10,
+		FailureRateThreshold: 50,
+	})
+
+	// Interleaved failures never reach a consecutive threshold, and the
+	// rate is not checked before the minimum number of calls
+	for i := 0; i < 9; i++ {
+		var err error
+		if i%2 == 0 {
+			err = errors.New("failure")
+		}
+		_ = cb.Execute(func() error { return err })
+	}
+	if cb.GetState() != StateClosed {
+		t.Fatalf("expected state closed below minimum calls, got %s", cb.GetState())
+	}
+
+	// The tenth call makes 5 of 10 fail
+	_ = cb.Execute(func() error { return nil })
+	if cb.GetState() != StateOpen {
+		t.Errorf("expected state open at 50%% failures, got %s", cb.GetState())
+	}
+}
+
+func TestSlidingWindowBelowFailureRate(t *testing.T) {
+	cb := New("test", &Config{
+		FailureThreshold:     1,
+		SuccessThreshold:     1,
+		Timeout:              time.Minute,
+		MaxRequests:          1,
+		Window:               time.Minute,
+		MinimumCalls:         10,
+		FailureRateThreshold: 50,
+	})
+
+	for i := 0; i < 20; i++ {
+		var err error
+		if i%4 == 0 {
+			err = errors.New("failure")
+		}
+		_ = cb.Execute(func() error { return err })
+	}
+
+	stats := cb.GetStats()
+	if stats.State != StateClosed {
+		t.Errorf("expected state closed at 25%% failures, got %s", stats.State)
+	}
+	if stats.Calls != 20 || stats.FailureRate != 25 {
+		t.Errorf("expected 20 calls at 25%% failures, got %d at %v%%", stats.Calls, stats.FailureRate)
+	}
+}
+
+func TestSlidingWindowSlowCallRate(t *testing.T) {
+	cb := New("test", &Config{
+		FailureThreshold:      1,
+		SuccessThreshold:      1,
+		Timeout:               10 * time.Millisecond,
+		MaxRequests:           1,
+		Window:                time.Minute,
+		MinimumCalls:          2,
+		SlowCallRateThreshold: 100,
+		SlowCallDuration:      5 * time.Millisecond,
+	})
+
+	slow := func() error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}
+
+	_ = cb.Execute(slow)
+	_ = cb.Execute(func() error { return nil })
+	if cb.GetState() != StateClosed {
+		t.Fatalf("expected state closed with one fast call, got %s", cb.GetState())
+	}
+
+	_ = cb.Execute(slow)
+	_ = cb.Execute(slow)
+	if cb.GetState() != StateClosed {
+		t.Fatalf("expected state closed at 75%% slow calls, got %s", cb.GetState())
+	}
+
+	// A fresh window after a reset fills with slow calls only
+	cb.Reset()
+	_ = cb.Execute(slow)
+	_ = cb.Execute(slow)
+	if cb.GetState() != StateOpen {
+		t.Fatalf("expected state open at 100%% slow calls, got %s", cb.GetState())
+	}
+
+	// A slow probe counts as a failure
+	time.Sleep(15 * time.Millisecond)
+	_ = cb.Execute(slow)
+	if cb.GetState() != StateOpen {
+		t.Errorf("expected state open after a slow probe, got %s", cb.GetState())
+	}
+}
+
+func TestCallWindowExpiry(t *testing.T) {
+	var w callWindow
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	w.record(start, time.Minute, true, false)
+	w.record(start.Add(30*time.Second), time.Minute, false, true)
+
+	if calls, failures, slow := w.totals(start.Add(59*time.Second), time.Minute); calls != 2 || failures != 1 || slow != 1 {
+		t.Errorf("expected 2 calls, 1 failure and 1 slow call, got %d, %d and %d", calls, failures, slow)
+	}
+	// The first call left the window, one bucket at a time
+	if calls, failures, _ := w.totals(start.Add(time.Minute+6*time.Second), time.Minute); calls != 1 || failures != 0 {
+		t.Errorf("expected 1 call without failures, got %d and %d", calls, failures)
+	}
+	if calls, _, _ := w.totals(start.Add(2*time.Minute), time.Minute); calls != 0 {
+		t.Errorf("expected empty window, got %d calls", calls)
 	}
 }
 
@@ -444,6 +747,47 @@ func TestManagerGet(t *testing.T) {
 	}
 }
 
+func TestManagerGetReconfigures(t *testing.T) {
+	m := NewManager()
+
+	cb := m.Get("test", &Config{FailureThreshold: 3, SuccessThreshold: 1, Timeout: time.Minute, MaxRequests: 1})
+	_ = cb.Execute(func() error { return errors.New("failure") })
+
+	// A reload lowers the threshold; the failure already seen still counts
+	if again := m.Get("test", &Config{FailureThreshold: 2, SuccessThreshold: 1, Timeout: time.Minute, MaxRequests: 1}); again != cb {
+		t.Fatal("expected same circuit breaker instance")
+	}
+	_ = cb.Execute(func() error { return errors.New("failure") })
+
+	if cb.GetState() != StateOpen {
+		t.Errorf("expected state open under the new threshold, got %s", cb.GetState())
+	}
+}
+
+func TestManagerGetFuncClassifier(t *testing.T) {
+	m := NewManager()
+
+	// Funcs cannot be compared, so each Get takes the classifier over
+	ignoreAll := ErrorClassifierFunc(func(error) ErrorClass { return ErrorClassClientCancel })
+	config := func(classifier ErrorClassifier) *Config {
+		return &Config{FailureThreshold: 1, SuccessThreshold: 1, Timeout: time.Minute, MaxRequests: 1, Classifier: classifier}
+	}
+	cb := m.Get("test", config(ignoreAll))
+	if again := m.Get("test", config(ignoreAll)); again != cb {
+		t.Fatal("expected same circuit breaker instance")
+	}
+	_ = cb.Execute(func() error { return errors.New("failure") })
+	if cb.GetState() != StateClosed {
+		t.Fatalf("expected ignored failure to keep the breaker closed, got %s", cb.GetState())
+	}
+
+	m.Get("test", config(ErrorClassifierFunc(func(error) ErrorClass { return ErrorClassOther })))
+	_ = cb.Execute(func() error { return errors.New("failure") })
+	if cb.GetState() != StateOpen {
+		t.Errorf("expected the new classifier to open the breaker, got %s", cb.GetState())
+	}
+}
+
 func TestManagerGetStats(t *testing.T) {
 	m := NewManager()
 
@@ -561,3 +905,131 @@ func TestManagerConcurrentAccess(t *testing.T) {
 		t.Error("expected circuit breakers to be created")
 	}
 }
+
+func TestManagerPrune(t *testing.T) {
+	m := NewManager()
+	m.Get("http://orders-v1", nil)
+	m.Get("http://users", nil)
+
+	active := map[string]bool{"http://users": true}
+	if pruned := m.Prune(func(name string) bool { return active[name] }); pruned != 1 {
+		t.Errorf("expected 1 breaker to be pruned, got %d", pruned)
+	}
+
+	stats := m.GetStats()
+	if len(stats) != 1 || stats[0].Name != "http://users" {
+		t.Errorf("expected only the active breaker to remain, got %+v", stats)
+	}
+	if err := m.Reset("http://orders-v1"); err == nil {
+		t.Error("expected the pruned breaker to be gone")
+	}
+}
+
+func TestManagerStateChangeEvents(t *testing.T) {
+	m := NewManager()
+
+	var events []Event
+	m.OnStateChange(func(event Event) {
+		events = append(events, event)
+	})
+
+	cb := m.Get("backend1", &Config{FailureThreshold: 2, SuccessThreshold: 1, Timeout: 10 * time.Millisecond, MaxRequests: 1})
+	testErr := errors.New("test error")
+	for i := 0; i < 2; i++ {
+		_ = cb.Execute(func() error { return testErr })
+	}
+	time.Sleep(20 * time.Millisecond)
+	_ = cb.Execute(func() error { return nil })
+
+	m.Get("backend2", nil).Execute(func() error { return nil })
+
+	want := []struct {
+		from, to State
+		reason   string
+	}{
+		{StateClosed, StateOpen, "failure threshold reached"},
+		{StateOpen, StateHalfOpen, "timeout elapsed"},
+		{StateHalfOpen, StateClosed, "probes succeeded"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %d: %+v", len(want), len(events), events)
+	}
+	for i, w := range want {
+		if events[i].Name != "backend1" || events[i].From != w.from || events[i].To != w.to || events[i].Reason != w.reason {
+			t.Errorf("event %d: expected %s -> %s (%s), got %+v", i, w.from, w.to, w.reason, events[i])
+		}
+	}
+	if events[0].Failures != 2 {
+		t.Errorf("expected 2 failures on opening, got %d", events[0].Failures)
+	}
+
+	if history := m.History(""); len(history) != 3 {
+		t.Errorf("expected 3 events in history, got %d", len(history))
+	}
+	if history := m.History("backend2"); len(history) != 0 {
+		t.Errorf("expected no events of backend2, got %d", len(history))
+	}
+
+	// Resetting an open breaker is a state change too
+	for i := 0; i < 2; i++ {
+		_ = cb.Execute(func() error { return testErr })
+	}
+	if err := m.Reset("backend1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if last := events[len(events)-1]; last.To != StateClosed || last.Reason != "reset" {
+		t.Errorf("expected reset event, got %+v", last)
+	}
+}
+
+func TestManagerHistoryBounded(t *testing.T) {
+	m := NewManager()
+	for i := 0; i < historySize+10; i++ {
+		m.record(Event{Name: "backend1", Failures: i})
+	}
+
+	history := m.History("backend1")
+	if len(history) != historySize {
+		t.Fatalf("expected %d events, got %d", historySize, len(history))
+	}
+	if history[0].Failures != 10 || history[historySize-1].Failures != historySize+9 {
+		t.Errorf("expected the latest events oldest first, got %d..%d", history[0].Failures, history[historySize-1].Failures)
+	}
+}
+
+func TestReady(t *testing.T) {
+	cb := New("test", &Config{FailureThreshold: 1, SuccessThreshold: 1, Timeout: 20 * time.Millisecond, MaxRequests: 1})
+	if !cb.Ready() {
+		t.Error("expected closed breaker to be ready")
+	}
+
+	_ = cb.Execute(func() error { return errors.New("test error") })
+	if cb.Ready() {
+		t.Error("expected open breaker not to be ready")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !cb.Ready() || !cb.Ready() {
+		t.Error("expected breaker to be ready once the timeout elapsed, without reserving a probe")
+	}
+
+	// The half-open slot is taken by the probe in flight
+	probing := make(chan struct{})
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = cb.Execute(func() error {
+			close(probing)
+			<-done
+			return nil
+		})
+	}()
+	<-probing
+	if cb.Ready() {
+		t.Error("expected half-open breaker without free slots not to be ready")
+	}
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,108 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+)
+
+// ErrorClass is the kind of error a call failed with
+type ErrorClass string
+
+const (
+	// ErrorClassNone is the class of calls without error
+	ErrorClassNone ErrorClass = "none"
+	// ErrorClassTimeout is a call that took too long
+	ErrorClassTimeout ErrorClass = "timeout"
+	// ErrorClassConnection is a call that could not reach the backend or
+	// lost its connection, such as refused, reset or DNS failures
+	ErrorClassConnection ErrorClass = "connection"
+	// ErrorClassStatus is a response with a failure status, such as 503
+	ErrorClassStatus ErrorClass = "status"
+	// ErrorClassClientCancel is a call the client gave up on
+	ErrorClassClientCancel ErrorClass = "client_cancel"
+	// ErrorClassRequest is a call that failed because of the request
+	// itself, such as an invalid body, and fails at any backend
+	ErrorClassRequest ErrorClass = "request"
+	// ErrorClassOther is any other error
+	ErrorClassOther ErrorClass = "other"
+)
+
+// Failure reports whether errors of the class count as backend failures.
+// Client cancellations and invalid requests are not the backend's fault.
+func (c ErrorClass) Failure() bool {
+	switch c {
+	case ErrorClassTimeout, ErrorClassConnection, ErrorClassStatus, ErrorClassOther:
+		return true
+	default:
+		return false
+	}
+}
+
+// Retryable reports whether a call that failed with an error of the class
+// may succeed when sent again
+func (c ErrorClass) Retryable() bool {
+	return c == ErrorClassTimeout || c == ErrorClassConnection
+}
+
+// ErrorClassifier decides the class of the errors of calls. Breakers count
+// only errors of failure classes, and callers retrying calls can share the
+// classifier to decide which errors to retry.
+type ErrorClassifier interface {
+	Classify(err error) ErrorClass
+}
+
+// ErrorClassifierFunc adapts a function to an ErrorClassifier
+type ErrorClassifierFunc func(err error) ErrorClass
+
+// Classify returns f(err)
+func (f ErrorClassifierFunc) Classify(err error) ErrorClass {
+	return f(err)
+}
+
+// DefaultClassifier classifies context and network errors. It is used by
+// breakers without a classifier of their own.
+type DefaultClassifier struct{}
+
+// Classify returns the class of err
+func (DefaultClassifier) Classify(err error) ErrorClass {
+	if err == nil {
+		return ErrorClassNone
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return ErrorClassClientCancel
+	}
+
+	// Deadlines, such as per-try timeouts, and other network timeouts
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorClassTimeout
+	}
+
+	// Connection level failures (refused, reset, DNS)
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return ErrorClassConnection
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrorClassConnection
+	}
+
+	// Connections closed by the backend before a response
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrorClassConnection
+	}
+
+	if strings.Contains(err.Error(), "connection refused") || strings.Contains(err.Error(), "no such host") {
+		return ErrorClassConnection
+	}
+
+	return ErrorClassOther
+}
@@ -0,0 +1,86 @@
+package circuitbreaker
+
+import (
+	"fmt"
+	"time"
+)
+
+// historySize is the number of state changes a manager remembers
+const historySize = 100
+
+// Event describes a state change of a circuit breaker
+type Event struct {
+	Name     string    `json:"name"`
+	From     State     `json:"from"`
+	To       State     `json:"to"`
+	Reason   string    `json:"reason"`
+	Failures int       `json:"failures"`
+	Time     time.Time `json:"time"`
+}
+
+// Listener is notified of the state changes of the breakers of a manager.
+// Listeners are called in order while the breaker is locked, so they must
+// return quickly and must not call into the breaker; slow work such as
+// sending a webhook belongs in a goroutine.
+type Listener func(Event)
+
+// MarshalText encodes the state by its name
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a state from its name
+func (s *State) UnmarshalText(text []byte) error {
+	for _, state := range []State{StateClosed, StateOpen, StateHalfOpen} {
+		if state.String() == string(text) {
+			*s = state
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown circuit breaker state: %q", text)
+}
+
+// OnStateChange registers a listener called on every state change of the
+// manager's breakers
+func (m *Manager) OnStateChange(listener Listener) {
+	m.eventsMu.Lock()
+	defer m.eventsMu.Unlock()
+	m.listeners = append(m.listeners, listener)
+}
+
+// record remembers a state change and notifies the listeners
+func (m *Manager) record(event Event) {
+	m.eventsMu.Lock()
+	if len(m.history) < historySize {
+		m.history = append(m.history, event)
+	} else {
+		m.history[m.next] = event
+	}
+	m.next = (m.next + 1) % historySize
+	listeners := m.listeners
+	m.eventsMu.Unlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+// History returns the last state changes of the breaker name, or of all
+// breakers if name is empty, oldest first
+func (m *Manager) History(name string) []Event {
+	m.eventsMu.Lock()
+	defer m.eventsMu.Unlock()
+
+	events := make([]Event, 0, len(m.history))
+	start := 0
+	if len(m.history) == historySize {
+		start = m.next
+	}
+	for i := range m.history {
+		event := m.history[(start+i)%len(m.history)]
+		if name == "" || event.Name == name {
+			events = append(events, event)
+		}
+	}
+	return events
+}
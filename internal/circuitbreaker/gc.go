@@ -0,0 +1,97 @@
+package circuitbreaker
+
+import (
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+)
+
+// sweepsPerIdleTTL is how often per IdleTTL idle breakers are looked for at
+// most, so a burst of new breakers does not scan all breakers each time
+const sweepsPerIdleTTL = 10
+
+// GCConfig bounds the breakers a manager keeps, such as for backends
+// discovered dynamically whose URLs churn
+type GCConfig struct {
+	// IdleTTL evicts breakers no one asked for within it; 0 keeps them
+	IdleTTL time.Duration
+	// MaxBreakers caps the number of breakers; creating one more evicts
+	// the least recently used, closed breakers first. 0 is unlimited.
+	MaxBreakers int
+}
+
+// SetGC sets the limits of the breakers the manager keeps. Breakers are
+// collected as new breakers are created, which is when they can pile up.
+func (m *Manager) SetGC(config GCConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gc = config
+}
+
+// collect evicts idle breakers and makes room for one more breaker within
+// MaxBreakers. m.mu is held.
+func (m *Manager) collect() {
+	now := m.now()
+
+	if m.gc.IdleTTL > 0 && now.Sub(m.lastSweep) >= m.gc.IdleTTL/sweepsPerIdleTTL {
+		m.lastSweep = now
+		for name, cb := range m.breakers {
+			if now.Sub(cb.lastUse()) >= m.gc.IdleTTL {
+				m.evict(name, "idle")
+			}
+		}
+	}
+
+	if m.gc.MaxBreakers <= 0 {
+		return
+	}
+	for len(m.breakers) >= m.gc.MaxBreakers {
+		m.evict(m.leastRecentlyUsed(), "capacity")
+	}
+}
+
+// leastRecentlyUsed returns the name of the breaker to evict for capacity:
+// the least recently used closed breaker or, if none is closed, the least
+// recently used one. m.mu is held and there is at least one breaker.
+func (m *Manager) leastRecentlyUsed() string {
+	var (
+		victim       string
+		victimUse    time.Time
+		victimClosed bool
+	)
+	for name, cb := range m.breakers {
+		used, closed := cb.lastUse(), cb.GetState() == StateClosed
+		switch {
+		case victim == "",
+			closed && !victimClosed,
+			closed == victimClosed && used.Before(victimUse):
+			victim, victimUse, victimClosed = name, used, closed
+		}
+	}
+	return victim
+}
+
+// evict disposes a breaker. A backend asking for it again starts with a
+// closed breaker. m.mu is held.
+func (m *Manager) evict(name, reason string) {
+	m.breakers[name].dispose()
+	delete(m.breakers, name)
+	metrics.DeleteCircuitBreakerState(name)
+	metrics.RecordCircuitBreakerEviction(reason)
+
+	m.logger.Info("circuit breaker evicted", logger.Fields{
+		"name":   name,
+		"reason": reason,
+	})
+}
+
+// touch records that the breaker was asked for at now
+func (cb *CircuitBreaker) touch(now time.Time) {
+	cb.lastUsed.Store(now.UnixNano())
+}
+
+// lastUse returns when the breaker was last asked for
+func (cb *CircuitBreaker) lastUse() time.Time {
+	return time.Unix(0, cb.lastUsed.Load())
+}
@@ -0,0 +1,65 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestManagerEvictsIdleBreakers(t *testing.T) {
+	now := time.Now()
+	m := NewManager()
+	m.now = func() time.Time { return now }
+	m.SetGC(GCConfig{IdleTTL: time.Minute})
+
+	config := &Config{FailureThreshold: 1, SuccessThreshold: 1, Timeout: time.Hour, MaxRequests: 1}
+	gone := m.Get("http://10.0.0.1:8080", config)
+	_ = gone.Execute(func() error { return errors.New("test error") })
+	kept := m.Get("http://10.0.0.2:8080", config)
+
+	now = now.Add(50 * time.Second)
+	m.Get("http://10.0.0.2:8080", config)
+
+	// Creating a breaker collects those idle for the TTL, open or not
+	now = now.Add(20 * time.Second)
+	m.Get("http://10.0.0.3:8080", config)
+
+	if m.Get("http://10.0.0.2:8080", config) != kept {
+		t.Error("expected a breaker in use to be kept")
+	}
+	if cb := m.Get("http://10.0.0.1:8080", config); cb == gone || cb.GetState() != StateClosed {
+		t.Error("expected the idle breaker to be evicted and recreated closed")
+	}
+	if !gone.disposed {
+		t.Error("expected the evicted breaker to be disposed")
+	}
+}
+
+func TestManagerCapsBreakers(t *testing.T) {
+	now := time.Now()
+	m := NewManager()
+	m.now = func() time.Time { return now }
+	m.SetGC(GCConfig{MaxBreakers: 3})
+
+	config := &Config{FailureThreshold: 1, SuccessThreshold: 1, Timeout: time.Hour, MaxRequests: 1}
+	open := m.Get("a", config)
+	_ = open.Execute(func() error { return errors.New("test error") })
+	for _, name := range []string{"b", "c"} {
+		now = now.Add(time.Second)
+		m.Get(name, config)
+	}
+
+	// The least recently used closed breaker makes room, not the open one
+	now = now.Add(time.Second)
+	m.Get("d", config)
+	if len(m.GetStats()) != 3 {
+		t.Fatalf("expected 3 breakers, got %d", len(m.GetStats()))
+	}
+	m.mu.RLock()
+	_, hasA := m.breakers["a"]
+	_, hasB := m.breakers["b"]
+	m.mu.RUnlock()
+	if !hasA || hasB {
+		t.Errorf("expected b to be evicted and a kept, have a %t, b %t", hasA, hasB)
+	}
+}
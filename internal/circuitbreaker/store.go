@@ -0,0 +1,264 @@
+package circuitbreaker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+)
+
+// storeTimeout bounds each store operation
+const storeTimeout = 5 * time.Second
+
+// maxPendingSnapshots caps the state changes waiting to be saved, so a slow
+// store cannot pile them up; excess changes are dropped
+const maxPendingSnapshots = 100
+
+// Snapshot is the persisted state of a breaker that is not closed
+type Snapshot struct {
+	Name     string    `json:"name"`
+	State    State     `json:"state"`
+	Since    time.Time `json:"since"`
+	Failures int       `json:"failures"`
+}
+
+// Store persists the snapshots of open and half-open breakers by name
+type Store interface {
+	// Save replaces the snapshot of a breaker
+	Save(ctx context.Context, snapshot Snapshot) error
+	// Delete removes the snapshot of a breaker that closed
+	Delete(ctx context.Context, name string) error
+	// Load returns all snapshots
+	Load(ctx context.Context) ([]Snapshot, error)
+	// Close releases the resources of the store
+	Close() error
+}
+
+// NewStore creates the configured breaker store
+func NewStore(cfg *config.BreakerStoreConfig) (Store, error) {
+	switch cfg.Type {
+	case "file":
+		return newFileStore(cfg.Path)
+	case "redis":
+		return newRedisStore(cfg)
+	default:
+		return nil, fmt.Errorf("unknown circuit breaker store: %s", cfg.Type)
+	}
+}
+
+// fileStore keeps the snapshots as a JSON object by name in one file, which
+// is replaced on every change
+type fileStore struct {
+	path      string
+	mu        sync.Mutex
+	snapshots map[string]Snapshot
+}
+
+func newFileStore(path string) (*fileStore, error) {
+	s := &fileStore{path: path, snapshots: make(map[string]Snapshot)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read circuit breaker state: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.snapshots); err != nil {
+		return nil, fmt.Errorf("failed to parse circuit breaker state %s: %w", path, err)
+	}
+	return s, nil
+}
+
+func (s *fileStore) Save(ctx context.Context, snapshot Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots[snapshot.Name] = snapshot
+	return s.write()
+}
+
+func (s *fileStore) Delete(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.snapshots[name]; !ok {
+		return nil
+	}
+	delete(s.snapshots, name)
+	return s.write()
+}
+
+func (s *fileStore) Load(ctx context.Context) ([]Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshots := make([]Snapshot, 0, len(s.snapshots))
+	for _, snapshot := range s.snapshots {
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+func (s *fileStore) Close() error {
+	return nil
+}
+
+// write replaces the file through a temporary file, so a crash never leaves
+// it half written. s.mu is held.
+func (s *fileStore) write() error {
+	data, err := json.Marshal(s.snapshots)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Persister saves the state changes of a manager's breakers to a store
+type Persister struct {
+	store  Store
+	logger *logger.ComponentLogger
+
+	mu      sync.Mutex
+	pending chan Snapshot
+	closed  bool
+	done    chan struct{}
+}
+
+// Persist restores the breakers saved in store, then saves every state
+// change of the manager's breakers to it in the background. Breakers saved
+// open are restored open since the time they opened, so they probe their
+// backend once their timeout elapsed; breakers saved half-open are
+// restored open with the timeout elapsed. Snapshots older than maxAge are
+// ignored. Breakers are restored as they are created.
+func (m *Manager) Persist(ctx context.Context, store Store, maxAge time.Duration) (*Persister, error) {
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+	snapshots, err := store.Load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load circuit breaker state: %w", err)
+	}
+
+	p := &Persister{
+		store:   store,
+		logger:  m.logger,
+		pending: make(chan Snapshot, maxPendingSnapshots),
+		done:    make(chan struct{}),
+	}
+	go p.run()
+	m.OnStateChange(p.enqueue)
+
+	m.mu.Lock()
+	if m.restored == nil {
+		m.restored = make(map[string]Snapshot)
+	}
+	restored := 0
+	for _, snapshot := range snapshots {
+		if snapshot.State == StateClosed || time.Since(snapshot.Since) > maxAge {
+			continue
+		}
+		restored++
+		metrics.RecordCircuitBreakerPersistence("restored")
+		if cb, ok := m.breakers[snapshot.Name]; ok {
+			cb.restore(snapshot)
+		} else {
+			m.restored[snapshot.Name] = snapshot
+		}
+	}
+	m.mu.Unlock()
+
+	m.logger.Info("circuit breaker state restored", logger.Fields{
+		"breakers": restored,
+	})
+	return p, nil
+}
+
+// enqueue is a listener queueing state changes to be saved
+func (p *Persister) enqueue(event Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+
+	select {
+	case p.pending <- Snapshot{Name: event.Name, State: event.To, Since: event.Time, Failures: event.Failures}:
+	default:
+		metrics.RecordCircuitBreakerPersistence("dropped")
+	}
+}
+
+// run saves queued state changes in order until the persister is closed
+func (p *Persister) run() {
+	defer close(p.done)
+	for snapshot := range p.pending {
+		ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+		var err error
+		if snapshot.State == StateClosed {
+			err = p.store.Delete(ctx, snapshot.Name)
+		} else {
+			err = p.store.Save(ctx, snapshot)
+		}
+		cancel()
+
+		if err != nil {
+			metrics.RecordCircuitBreakerPersistence("error")
+			p.logger.Warn("failed to save circuit breaker state", logger.Fields{
+				"name":  snapshot.Name,
+				"state": snapshot.State.String(),
+				"error": err.Error(),
+			})
+			continue
+		}
+		metrics.RecordCircuitBreakerPersistence("saved")
+	}
+}
+
+// Close saves the queued state changes and closes the store. Later state
+// changes are not saved.
+func (p *Persister) Close() error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.pending)
+	}
+	p.mu.Unlock()
+
+	<-p.done
+	return p.store.Close()
+}
+
+// restore puts a closed breaker into the state of snapshot
+func (cb *CircuitBreaker) restore(snapshot Snapshot) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state != StateClosed {
+		return
+	}
+	since := snapshot.Since
+	if snapshot.State == StateHalfOpen {
+		// Whether the probes succeeded is unknown; probe again
+		since = time.Now().Add(-cb.config.Timeout)
+	}
+
+	cb.failures = snapshot.Failures
+	cb.setStateSince(StateOpen, "restored", since)
+}
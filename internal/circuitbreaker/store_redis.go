@@ -0,0 +1,79 @@
+package circuitbreaker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+// redisStore keeps snapshots in Redis, shared by all gateway instances. The
+// snapshot of a breaker is the JSON string <prefix><name>, expiring after
+// the maximum age of snapshots.
+type redisStore struct {
+	client *redis.Client
+	prefix string
+	maxAge time.Duration
+}
+
+func newRedisStore(cfg *config.BreakerStoreConfig) (*redisStore, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	return &redisStore{client: client, prefix: cfg.RedisKeyPrefix, maxAge: cfg.MaxAge}, nil
+}
+
+func (s *redisStore) Save(ctx context.Context, snapshot Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.prefix+snapshot.Name, data, s.maxAge).Err()
+}
+
+func (s *redisStore) Delete(ctx context.Context, name string) error {
+	return s.client.Del(ctx, s.prefix+name).Err()
+}
+
+func (s *redisStore) Load(ctx context.Context) ([]Snapshot, error) {
+	var snapshots []Snapshot
+	iter := s.client.Scan(ctx, 0, s.prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		data, err := s.client.Get(ctx, iter.Val()).Bytes()
+		if errors.Is(err, redis.Nil) {
+			// Expired since the scan
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var snapshot Snapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return nil, fmt.Errorf("invalid snapshot %s: %w", iter.Val(), err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+func (s *redisStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,147 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breakers.json")
+	ctx := context.Background()
+
+	store, err := newFileStore(path)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	since := time.Now().Add(-time.Minute).Round(0)
+	if err := store.Save(ctx, Snapshot{Name: "http://orders", State: StateOpen, Since: since, Failures: 5}); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	if err := store.Save(ctx, Snapshot{Name: "http://users", State: StateHalfOpen, Since: since}); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	if err := store.Delete(ctx, "http://users"); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+
+	// A new store reads the file
+	store, err = newFileStore(path)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	snapshots, err := store.Load(ctx)
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	want := Snapshot{Name: "http://orders", State: StateOpen, Since: since, Failures: 5}
+	if len(snapshots) != 1 || !snapshots[0].Since.Equal(since) || snapshots[0].Name != want.Name ||
+		snapshots[0].State != want.State || snapshots[0].Failures != want.Failures {
+		t.Errorf("expected %+v, got %+v", want, snapshots)
+	}
+}
+
+func TestPersistRestoresBreakers(t *testing.T) {
+	store, err := newFileStore(filepath.Join(t.TempDir(), "breakers.json"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	ctx := context.Background()
+	now := time.Now()
+	for _, snapshot := range []Snapshot{
+		{Name: "open", State: StateOpen, Since: now.Add(-10 * time.Second), Failures: 5},
+		{Name: "elapsed", State: StateOpen, Since: now.Add(-2 * time.Minute)},
+		{Name: "half-open", State: StateHalfOpen, Since: now.Add(-time.Second)},
+		{Name: "stale", State: StateOpen, Since: now.Add(-2 * time.Hour)},
+	} {
+		if err := store.Save(ctx, snapshot); err != nil {
+			t.Fatalf("failed to save: %v", err)
+		}
+	}
+
+	m := NewManager()
+	persister, err := m.Persist(ctx, store, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to persist: %v", err)
+	}
+	defer func() { _ = persister.Close() }()
+
+	config := &Config{FailureThreshold: 1, SuccessThreshold: 1, Timeout: time.Minute, MaxRequests: 1}
+	called := false
+	fn := func() error { called = true; return nil }
+
+	// An open breaker keeps rejecting requests for the rest of its timeout
+	cb := m.Get("open", config)
+	if err := cb.Execute(fn); !errors.Is(err, ErrCircuitOpen) || called {
+		t.Errorf("expected restored breaker to reject requests, got %v", err)
+	}
+	if stats := cb.GetStats(); stats.Failures != 5 {
+		t.Errorf("expected 5 failures, got %d", stats.Failures)
+	}
+
+	// Breakers whose timeout elapsed, or that were probing, probe at once
+	for _, name := range []string{"elapsed", "half-open"} {
+		called = false
+		cb := m.Get(name, config)
+		if cb.GetState() != StateOpen {
+			t.Errorf("%s: expected state %s, got %s", name, StateOpen, cb.GetState())
+		}
+		if err := cb.Execute(fn); err != nil || !called {
+			t.Errorf("%s: expected a probe request, got %v", name, err)
+		}
+	}
+
+	if state := m.Get("stale", config).GetState(); state != StateClosed {
+		t.Errorf("expected a stale snapshot to be ignored, got state %s", state)
+	}
+}
+
+func TestPersistSavesStateChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breakers.json")
+	store, err := newFileStore(path)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	m := NewManager()
+	persister, err := m.Persist(context.Background(), store, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to persist: %v", err)
+	}
+
+	config := &Config{FailureThreshold: 1, SuccessThreshold: 1, Timeout: time.Minute, MaxRequests: 1}
+	_ = m.Get("http://orders", config).Execute(func() error { return errors.New("test error") })
+	_ = m.Get("http://users", config).Execute(func() error { return errors.New("test error") })
+	if err := m.Reset("http://users"); err != nil {
+		t.Fatalf("failed to reset: %v", err)
+	}
+	if err := persister.Close(); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+
+	// A restarted manager finds the breaker still open
+	store, err = newFileStore(path)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	snapshots, _ := store.Load(context.Background())
+	if len(snapshots) != 1 || snapshots[0].Name != "http://orders" || snapshots[0].State != StateOpen {
+		t.Fatalf("expected the open breaker to be saved, got %+v", snapshots)
+	}
+
+	restarted := NewManager()
+	persister, err = restarted.Persist(context.Background(), store, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to persist: %v", err)
+	}
+	defer func() { _ = persister.Close() }()
+	if state := restarted.Get("http://orders", config).GetState(); state != StateOpen {
+		t.Errorf("expected state %s after restart, got %s", StateOpen, state)
+	}
+	events := restarted.History("http://orders")
+	if len(events) != 1 || events[0].Reason != "restored" {
+		t.Errorf("expected a restored event, got %+v", events)
+	}
+}
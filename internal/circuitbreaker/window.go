@@ -0,0 +1,70 @@
+package circuitbreaker
+
+import "time"
+
+// windowBuckets is the number of buckets a sliding window is divided into;
+// calls leave the window one bucket at a time
+const windowBuckets = 10
+
+// callWindow counts the calls, failures and slow calls of a sliding time
+// window. It is guarded by the lock of its breaker.
+type callWindow struct {
+	buckets [windowBuckets]callBucket
+}
+
+// callBucket counts the calls of one tenth of the window
+type callBucket struct {
+	index    int64 // number of the bucket since the epoch
+	calls    int
+	failures int
+	slow     int
+}
+
+// bucketIndex returns the number of the bucket of a window of size at now
+func bucketIndex(now time.Time, size time.Duration) int64 {
+	width := max(int64(size/windowBuckets), 1)
+	return now.UnixNano() / width
+}
+
+// record counts a call at now in a window of size
+func (w *callWindow) record(now time.Time, size time.Duration, failed, slow bool) {
+	index := bucketIndex(now, size)
+	bucket := &w.buckets[index%windowBuckets]
+	if bucket.index != index {
+		*bucket = callBucket{index: index}
+	}
+	bucket.calls++
+	if failed {
+		bucket.failures++
+	}
+	if slow {
+		bucket.slow++
+	}
+}
+
+// totals returns the calls, failures and slow calls of the window of size
+// ending at now
+func (w *callWindow) totals(now time.Time, size time.Duration) (calls, failures, slow int) {
+	index := bucketIndex(now, size)
+	for _, bucket := range w.buckets {
+		if bucket.index > index-windowBuckets && bucket.index <= index {
+			calls += bucket.calls
+			failures += bucket.failures
+			slow += bucket.slow
+		}
+	}
+	return calls, failures, slow
+}
+
+// reset forgets all calls
+func (w *callWindow) reset() {
+	w.buckets = [windowBuckets]callBucket{}
+}
+
+// rates returns the percentage of failed and of slow calls among calls
+func rates(calls, failures, slow int) (float64, float64) {
+	if calls == 0 {
+		return 0, 0
+	}
+	return float64(failures) * 100 / float64(calls), float64(slow) * 100 / float64(calls)
+}
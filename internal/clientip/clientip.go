@@ -0,0 +1,200 @@
+// Package clientip determines the address of the client that sent a request.
+// Forwarding headers are only honored when the request arrived from a trusted
+// proxy, and only the header those proxies maintain, so clients cannot spoof
+// their address.
+package clientip
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// contextKey is the context key for resolved client information
+type contextKey struct{}
+
+// Forwarding headers trusted proxies may maintain
+const (
+	HeaderXForwardedFor = "x-forwarded-for"
+	HeaderForwarded     = "forwarded"
+)
+
+// Info describes the client of a request
+type Info struct {
+	// IP is the address of the client, after skipping trusted proxies
+	IP string
+	// Peer is the address of the connection the request arrived on
+	Peer string
+	// TrustedPeer reports whether Peer is a trusted proxy, in which case the
+	// forwarding header it maintains may be extended rather than replaced
+	TrustedPeer bool
+	// Header is the forwarding header trusted proxies maintain
+	Header string
+}
+
+// Trusts reports whether header was maintained by trusted proxies and may be
+// extended
+func (i Info) Trusts(header string) bool {
+	return i.TrustedPeer && i.Header == header
+}
+
+// Resolver resolves client addresses using a list of trusted proxies
+type Resolver struct {
+	trusted []*net.IPNet
+	header  string
+}
+
+// NewResolver creates a resolver trusting the given proxy networks to
+// maintain X-Forwarded-For. With no trusted networks, forwarding headers are
+// ignored.
+func NewResolver(trusted []*net.IPNet) *Resolver {
+	return &Resolver{trusted: trusted, header: HeaderXForwardedFor}
+}
+
+// WithHeader sets the forwarding header the trusted proxies maintain,
+// HeaderXForwardedFor or HeaderForwarded. The other header is ignored, since
+// proxies appending to one pass the other on as the client sent it.
+func (res *Resolver) WithHeader(header string) *Resolver {
+	if header == HeaderForwarded {
+		res.header = HeaderForwarded
+	} else {
+		res.header = HeaderXForwardedFor
+	}
+	return res
+}
+
+// Trusted reports whether ip belongs to a trusted proxy
+func (res *Resolver) Trusted(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range res.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve determines the client of r. The proxy chain is taken from the
+// header the trusted proxies maintain and walked from the right, skipping
+// trusted proxies; the first untrusted address is the client.
+func (res *Resolver) Resolve(r *http.Request) Info {
+	peer := hostOf(r.RemoteAddr)
+	info := Info{IP: peer, Peer: peer, Header: res.header}
+	if !res.Trusted(net.ParseIP(peer)) {
+		return info
+	}
+	info.TrustedPeer = true
+
+	hops := res.forwardedHops(r)
+	if len(hops) == 0 {
+		if realIP := ParseAddr(r.Header.Get("X-Real-IP")); realIP != nil {
+			info.IP = realIP.String()
+		}
+		return info
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := ParseAddr(hops[i])
+		if ip == nil {
+			// Garbage, unknown or obfuscated nodes cannot be attributed;
+			// stop at the last address a trusted proxy vouched for
+			break
+		}
+		info.IP = ip.String()
+		if !res.Trusted(ip) {
+			break
+		}
+	}
+	return info
+}
+
+// forwardedHops returns the client addresses recorded by earlier proxies in
+// the header they maintain
+func (res *Resolver) forwardedHops(r *http.Request) []string {
+	var hops []string
+	if res.header == HeaderForwarded {
+		for _, element := range ParseForwarded(r.Header.Values("Forwarded")) {
+			hops = append(hops, element.For)
+		}
+		return hops
+	}
+
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+// Middleware resolves the client of each request and stores it in the
+// request context
+func (res *Resolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := WithInfo(r.Context(), res.Resolve(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// WithInfo stores client information in ctx
+func WithInfo(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, contextKey{}, info)
+}
+
+// FromContext returns the client information stored in ctx
+func FromContext(ctx context.Context) (Info, bool) {
+	info, ok := ctx.Value(contextKey{}).(Info)
+	return info, ok
+}
+
+// FromRequest returns the client IP of r. Requests that did not pass the
+// middleware fall back to the connection address; headers are never trusted.
+func FromRequest(r *http.Request) string {
+	if info, ok := FromContext(r.Context()); ok {
+		return info.IP
+	}
+	return hostOf(r.RemoteAddr)
+}
+
+// hostOf strips the port from addr, if any, and normalizes the address
+func hostOf(addr string) string {
+	if ip := ParseAddr(addr); ip != nil {
+		return ip.String()
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.Trim(addr, "[]")
+}
+
+// ParseAddr parses an IP address as found in RemoteAddr, forwarding headers
+// and RFC 7239 nodes: it may be bracketed, carry a port or an IPv6 zone, so
+// "[2001:db8::1]:443", "[2001:db8::1]" and "2001:db8::1" are the same
+// address. It returns nil if addr holds no IP address.
+func ParseAddr(addr string) net.IP {
+	addr = strings.TrimSpace(addr)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	} else if strings.HasPrefix(addr, "[") && strings.HasSuffix(addr, "]") {
+		addr = addr[1 : len(addr)-1]
+	}
+	addr, _, _ = strings.Cut(addr, "%")
+	return net.ParseIP(addr)
+}
+
+// Normalize returns the canonical text form of an IP address, so that
+// equivalent spellings of an address, such as upper case or expanded IPv6
+// and IPv4-mapped IPv6, yield the same rate limit keys and log values. The
+// IPv6 zone is dropped. Anything that is not an IP address is returned as is.
+func Normalize(addr string) string {
+	host, _, _ := strings.Cut(addr, "%")
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return addr
+}
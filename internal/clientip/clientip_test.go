@@ -0,0 +1,215 @@
+package clientip
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+func mustNetworks(t *testing.T, cidrs ...string) []*net.IPNet {
+	t.Helper()
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatalf("invalid CIDR %s: %v", cidr, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	trusted := mustNetworks(t, "10.0.0.0/8", "2001:db8::/32")
+
+	tests := []struct {
+		name          string
+		header        string
+		remoteAddr    string
+		forwardedFor  []string
+		forwarded     string
+		realIP        string
+		expectedIP    string
+		expectTrusted bool
+	}{
+		{
+			name:       "direct client",
+			remoteAddr: "203.0.113.7:4000",
+			expectedIP: "203.0.113.7",
+		},
+		{
+			name:         "untrusted peer cannot spoof",
+			remoteAddr:   "203.0.113.7:4000",
+			forwardedFor: []string{"198.51.100.1"},
+			realIP:       "198.51.100.2",
+			expectedIP:   "203.0.113.7",
+		},
+		{
+			name:          "trusted proxy",
+			remoteAddr:    "10.0.0.5:4000",
+			forwardedFor:  []string{"198.51.100.1"},
+			expectedIP:    "198.51.100.1",
+			expectTrusted: true,
+		},
+		{
+			name:          "spoofed entry left of the real client",
+			remoteAddr:    "10.0.0.5:4000",
+			forwardedFor:  []string{"1.2.3.4, 198.51.100.1, 10.1.1.1"},
+			expectedIP:    "198.51.100.1",
+			expectTrusted: true,
+		},
+		{
+			name:          "multiple headers",
+			remoteAddr:    "10.0.0.5:4000",
+			forwardedFor:  []string{"1.2.3.4", "198.51.100.1"},
+			expectedIP:    "198.51.100.1",
+			expectTrusted: true,
+		},
+		{
+			name:          "only trusted hops",
+			remoteAddr:    "10.0.0.5:4000",
+			forwardedFor:  []string{"10.2.2.2, 10.1.1.1"},
+			expectedIP:    "10.2.2.2",
+			expectTrusted: true,
+		},
+		{
+			name:          "garbage stops the walk",
+			remoteAddr:    "10.0.0.5:4000",
+			forwardedFor:  []string{"198.51.100.1, unknown, 10.1.1.1"},
+			expectedIP:    "10.1.1.1",
+			expectTrusted: true,
+		},
+		{
+			name:          "X-Real-IP from trusted proxy",
+			remoteAddr:    "10.0.0.5:4000",
+			realIP:        "198.51.100.9",
+			expectedIP:    "198.51.100.9",
+			expectTrusted: true,
+		},
+		{
+			name:          "Forwarded header of trusted proxies",
+			header:        HeaderForwarded,
+			remoteAddr:    "10.0.0.5:4000",
+			forwarded:     `for=198.51.100.1, for="[2001:db8::9]:80"`,
+			expectedIP:    "198.51.100.1",
+			expectTrusted: true,
+		},
+		{
+			name:          "client Forwarded ignored behind X-Forwarded-For proxies",
+			remoteAddr:    "10.0.0.5:4000",
+			forwardedFor:  []string{"203.0.113.9"},
+			forwarded:     "for=198.51.100.1",
+			expectedIP:    "203.0.113.9",
+			expectTrusted: true,
+		},
+		{
+			name:          "client X-Forwarded-For ignored behind Forwarded proxies",
+			header:        HeaderForwarded,
+			remoteAddr:    "10.0.0.5:4000",
+			forwardedFor:  []string{"198.51.100.1"},
+			forwarded:     "for=203.0.113.9",
+			expectedIP:    "203.0.113.9",
+			expectTrusted: true,
+		},
+		{
+			name:          "obfuscated Forwarded node",
+			header:        HeaderForwarded,
+			remoteAddr:    "10.0.0.5:4000",
+			forwarded:     "for=198.51.100.1, for=_hidden",
+			expectedIP:    "10.0.0.5",
+			expectTrusted: true,
+		},
+		{
+			name:          "IPv6 trusted proxy",
+			remoteAddr:    "[2001:db8::1]:4000",
+			forwardedFor:  []string{"2001:db9::5"},
+			expectedIP:    "2001:db9::5",
+			expectTrusted: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.forwarded != "" {
+				req.Header.Set("Forwarded", tt.forwarded)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			info := NewResolver(trusted).WithHeader(tt.header).Resolve(req)
+			if info.IP != tt.expectedIP {
+				t.Errorf("expected IP %s, got %s", tt.expectedIP, info.IP)
+			}
+			if info.TrustedPeer != tt.expectTrusted {
+				t.Errorf("expected trusted peer %v, got %v", tt.expectTrusted, info.TrustedPeer)
+			}
+		})
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "203.0.113.7:4000"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	if ip := FromRequest(req); ip != "203.0.113.7" {
+		t.Errorf("expected connection address without middleware, got %s", ip)
+	}
+
+	req = req.WithContext(WithInfo(req.Context(), Info{IP: "198.51.100.1"}))
+	if ip := FromRequest(req); ip != "198.51.100.1" {
+		t.Errorf("expected resolved address, got %s", ip)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		addr     string
+		expected string
+	}{
+		{"192.0.2.1", "192.0.2.1"},
+		{"2001:DB8:0:0::1", "2001:db8::1"},
+		{"::ffff:192.0.2.1", "192.0.2.1"},
+		{"fe80::1%eth0", "fe80::1"},
+		{"unix-socket", "unix-socket"},
+	}
+
+	for _, tt := range tests {
+		if got := Normalize(tt.addr); got != tt.expected {
+			t.Errorf("Normalize(%q) = %q, expected %q", tt.addr, got, tt.expected)
+		}
+	}
+}
+
+func TestParseAddr(t *testing.T) {
+	tests := []struct {
+		addr     string
+		expected string
+	}{
+		{"192.0.2.1", "192.0.2.1"},
+		{"192.0.2.1:443", "192.0.2.1"},
+		{"2001:db8::1", "2001:db8::1"},
+		{"[2001:db8::1]", "2001:db8::1"},
+		{"[2001:DB8::1]:443", "2001:db8::1"},
+		{" [fe80::1%eth0]:80 ", "fe80::1"},
+		{"[2001:db8::1", ""},
+		{"unknown", ""},
+		{"_hidden", ""},
+	}
+
+	for _, tt := range tests {
+		got := ""
+		if ip := ParseAddr(tt.addr); ip != nil {
+			got = ip.String()
+		}
+		if got != tt.expected {
+			t.Errorf("ParseAddr(%q) = %q, expected %q", tt.addr, got, tt.expected)
+		}
+	}
+}
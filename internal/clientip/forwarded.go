@@ -0,0 +1,123 @@
+package clientip
+
+import (
+	"net"
+	"strings"
+)
+
+// ForwardedElement is one hop of an RFC 7239 Forwarded header
+type ForwardedElement struct {
+	For   string
+	By    string
+	Host  string
+	Proto string
+}
+
+// ParseForwarded parses the elements of one or more Forwarded header values,
+// in order from the first proxy to the last. Unknown parameters are ignored
+// and malformed pairs are skipped.
+func ParseForwarded(values []string) []ForwardedElement {
+	var elements []ForwardedElement
+	for _, value := range values {
+		for _, part := range splitQuoted(value, ',') {
+			var element ForwardedElement
+			for _, pair := range splitQuoted(part, ';') {
+				name, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+				val = unquote(strings.TrimSpace(val))
+				switch strings.ToLower(strings.TrimSpace(name)) {
+				case "for":
+					element.For = val
+				case "by":
+					element.By = val
+				case "host":
+					element.Host = val
+				case "proto":
+					element.Proto = val
+				}
+			}
+			if element != (ForwardedElement{}) {
+				elements = append(elements, element)
+			}
+		}
+	}
+	return elements
+}
+
+// String formats the element as a Forwarded header element, quoting values
+// that are not RFC 7230 tokens such as IPv6 addresses
+func (e ForwardedElement) String() string {
+	var pairs []string
+	add := func(name, value string) {
+		if value != "" {
+			pairs = append(pairs, name+"="+quoteIfNeeded(value))
+		}
+	}
+	add("for", e.For)
+	add("by", e.By)
+	add("host", e.Host)
+	add("proto", e.Proto)
+	return strings.Join(pairs, ";")
+}
+
+// ForwardedNode formats an address for the for and by parameters. IPv6
+// addresses are bracketed as required by RFC 7239.
+func ForwardedNode(ip string) string {
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		return "[" + ip + "]"
+	}
+	return ip
+}
+
+// splitQuoted splits s at sep outside of quoted strings
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted, start := false, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	s = s[1 : len(s)-1]
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func quoteIfNeeded(s string) string {
+	for i := 0; i < len(s); i++ {
+		if !isTokenChar(s[i]) {
+			return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+		}
+	}
+	return s
+}
+
+// isTokenChar reports whether c may appear in an RFC 7230 token
+func isTokenChar(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
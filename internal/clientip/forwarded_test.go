@@ -0,0 +1,66 @@
+package clientip
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseForwarded(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		expected []ForwardedElement
+	}{
+		{
+			name:     "single element",
+			values:   []string{"for=192.0.2.60;proto=http;by=203.0.113.43"},
+			expected: []ForwardedElement{{For: "192.0.2.60", By: "203.0.113.43", Proto: "http"}},
+		},
+		{
+			name:   "multiple elements and headers",
+			values: []string{"for=192.0.2.43, for=198.51.100.17", "For=unknown;HOST=example.com"},
+			expected: []ForwardedElement{
+				{For: "192.0.2.43"},
+				{For: "198.51.100.17"},
+				{For: "unknown", Host: "example.com"},
+			},
+		},
+		{
+			name:     "quoted IPv6 with separators inside quotes",
+			values:   []string{`for="[2001:db8:cafe::17]:4711";host="a,b;c"`},
+			expected: []ForwardedElement{{For: "[2001:db8:cafe::17]:4711", Host: "a,b;c"}},
+		},
+		{
+			name:     "malformed pairs are skipped",
+			values:   []string{"garbage;for=192.0.2.1"},
+			expected: []ForwardedElement{{For: "192.0.2.1"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseForwarded(tt.values)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ParseForwarded() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestForwardedElement_String(t *testing.T) {
+	element := ForwardedElement{
+		For:   ForwardedNode("2001:db8::1"),
+		By:    "_gateway",
+		Host:  "example.com:8443",
+		Proto: "https",
+	}
+	expected := `for="[2001:db8::1]";by=_gateway;host="example.com:8443";proto=https`
+	if got := element.String(); got != expected {
+		t.Errorf("String() = %s, want %s", got, expected)
+	}
+
+	parsed := ParseForwarded([]string{element.String()})
+	if len(parsed) != 1 || parsed[0] != element {
+		t.Errorf("round trip failed: %+v", parsed)
+	}
+}
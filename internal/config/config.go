@@ -1,44 +1,207 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"golang.org/x/crypto/bcrypt"
 	"gopkg.in/yaml.v3"
+
+	"github.com/maltehedderich/api-gateway-go/internal/expr"
+	"github.com/maltehedderich/api-gateway-go/internal/jsonschema"
 )
 
 // Config represents the complete gateway configuration
 type Config struct {
+	// SchemaVersion is the configuration schema version the file was written
+	// for; 0 means the current version
+	SchemaVersion int `yaml:"schema_version" json:"schema_version"`
+
+	// Environment is dev, staging or prod; prod refuses unsafe settings
+	// unless they are listed in AcknowledgeUnsafe
+	Environment       string   `yaml:"environment" json:"environment"`
+	AcknowledgeUnsafe []string `yaml:"acknowledge_unsafe" json:"acknowledge_unsafe"`
+
 	Server        ServerConfig        `yaml:"server" json:"server"`
 	Logging       LoggingConfig       `yaml:"logging" json:"logging"`
 	Authorization AuthorizationConfig `yaml:"authorization" json:"authorization"`
 	RateLimit     RateLimitConfig     `yaml:"rate_limit" json:"rate_limit"`
 	Security      SecurityConfig      `yaml:"security" json:"security"`
 	Routes        []RouteConfig       `yaml:"routes" json:"routes"`
-	Observability ObservabilityConfig `yaml:"observability" json:"observability"`
+	// PassthroughRoutes tunnel TLS connections by SNI without terminating them
+	PassthroughRoutes []PassthroughRouteConfig `yaml:"passthrough_routes" json:"passthrough_routes"`
+	Proxy             ProxyConfig              `yaml:"proxy" json:"proxy"`
+	Compression       CompressionConfig        `yaml:"compression" json:"compression"`
+	TestTraffic       TestTrafficConfig        `yaml:"test_traffic" json:"test_traffic"`
+	Observability     ObservabilityConfig      `yaml:"observability" json:"observability"`
+	Admin             AdminConfig              `yaml:"admin" json:"admin"`
 }
 
 // ServerConfig contains HTTP server configuration
 type ServerConfig struct {
-	HTTPPort         int           `yaml:"http_port" json:"http_port"`
-	HTTPSPort        int           `yaml:"https_port" json:"https_port"`
-	TLSEnabled       bool          `yaml:"tls_enabled" json:"tls_enabled"`
-	TLSCertFile      string        `yaml:"tls_cert_file" json:"tls_cert_file"`
-	TLSKeyFile       string        `yaml:"tls_key_file" json:"tls_key_file"`
-	ReadTimeout      time.Duration `yaml:"read_timeout" json:"read_timeout"`
-	WriteTimeout     time.Duration `yaml:"write_timeout" json:"write_timeout"`
-	IdleTimeout      time.Duration `yaml:"idle_timeout" json:"idle_timeout"`
-	HandlerTimeout   time.Duration `yaml:"handler_timeout" json:"handler_timeout"`
-	MaxHeaderBytes   int           `yaml:"max_header_bytes" json:"max_header_bytes"`
-	ShutdownTimeout  time.Duration `yaml:"shutdown_timeout" json:"shutdown_timeout"`
-	EnableHTTP2      bool          `yaml:"enable_http2" json:"enable_http2"`
-	TrustedProxies   []string      `yaml:"trusted_proxies" json:"trusted_proxies"`
+	HTTPPort        int           `yaml:"http_port" json:"http_port"`
+	HTTPSPort       int           `yaml:"https_port" json:"https_port"`
+	TLSEnabled      bool          `yaml:"tls_enabled" json:"tls_enabled"`
+	TLSCertFile     string        `yaml:"tls_cert_file" json:"tls_cert_file"`
+	TLSKeyFile      string        `yaml:"tls_key_file" json:"tls_key_file"`
+	ReadTimeout     time.Duration `yaml:"read_timeout" json:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout" json:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout" json:"idle_timeout"`
+	HandlerTimeout  time.Duration `yaml:"handler_timeout" json:"handler_timeout"`
+	MaxHeaderBytes  int           `yaml:"max_header_bytes" json:"max_header_bytes"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" json:"shutdown_timeout"`
+	EnableHTTP2     bool          `yaml:"enable_http2" json:"enable_http2"`
+	TrustedProxies  []string      `yaml:"trusted_proxies" json:"trusted_proxies"` // IPs or CIDRs allowed to set X-Forwarded-For
+	// ForwardedHeader is the header the trusted proxies maintain:
+	// x-forwarded-for (default) or forwarded. Only that header is read,
+	// since proxies appending to one pass the other on as the client sent it.
+	ForwardedHeader string `yaml:"forwarded_header" json:"forwarded_header"`
+
+	// ProxyProtocol accepts PROXY protocol headers from L4 load balancers
+	ProxyProtocol ListenerProxyProtocolConfig `yaml:"proxy_protocol" json:"proxy_protocol"`
+
+	// Internal is the east-west listener for service-to-service traffic
+	Internal InternalListenerConfig `yaml:"internal" json:"internal"`
+}
+
+// ListenerProxyProtocolConfig controls PROXY protocol (v1 and v2) on the
+// listeners. Headers are only honored from AllowedSources, so clients
+// connecting directly cannot spoof their address.
+type ListenerProxyProtocolConfig struct {
+	Listeners      []string      `yaml:"listeners" json:"listeners"`             // http, https
+	AllowedSources []string      `yaml:"allowed_sources" json:"allowed_sources"` // IPs or CIDRs; empty allows all
+	HeaderTimeout  time.Duration `yaml:"header_timeout" json:"header_timeout"`
+}
+
+// Enabled reports whether the listener accepts PROXY protocol headers
+func (c ListenerProxyProtocolConfig) Enabled(listener string) bool {
+	for _, l := range c.Listeners {
+		if l == listener {
+			return true
+		}
+	}
+	return false
+}
+
+// validate validates listener PROXY protocol settings
+func (c ListenerProxyProtocolConfig) validate() error {
+	for _, listener := range c.Listeners {
+		if listener != "http" && listener != "https" {
+			return fmt.Errorf("invalid listener: %s (must be 'http' or 'https')", listener)
+		}
+	}
+	if _, err := ParseNetworks(c.AllowedSources); err != nil {
+		return err
+	}
+	if c.HeaderTimeout < 0 {
+		return fmt.Errorf("header timeout must not be negative")
+	}
+	return nil
+}
+
+// InternalListenerConfig configures the east-west listener, which serves the
+// same routes as the public listeners to other services of the mesh. Callers
+// authenticate with client certificates; their service identity (the first
+// URI SAN, such as a SPIFFE ID, or else the subject common name) is what auth
+// policies and rate limits apply to. Browser-oriented middleware (security
+// headers, HTTPS redirects) and user agent blocking are skipped.
+type InternalListenerConfig struct {
+	Enabled      bool   `yaml:"enabled" json:"enabled"`
+	Port         int    `yaml:"port" json:"port"`
+	ClientCAFile string `yaml:"client_ca_file" json:"client_ca_file"`
+
+	// TLSCertFile and TLSKeyFile default to the server certificate
+	TLSCertFile string `yaml:"tls_cert_file" json:"tls_cert_file"`
+	TLSKeyFile  string `yaml:"tls_key_file" json:"tls_key_file"`
+
+	// Services grants roles, permissions and scopes to service identities.
+	// Services without an entry are authenticated without any.
+	Services map[string]ServiceIdentityConfig `yaml:"services" json:"services"`
+}
+
+// ServiceIdentityConfig lists what a calling service is granted
+type ServiceIdentityConfig struct {
+	Roles       []string `yaml:"roles" json:"roles"`
+	Permissions []string `yaml:"permissions" json:"permissions"`
+	Scopes      []string `yaml:"scopes" json:"scopes"`
+}
+
+// CertFiles returns the certificate and key the listener serves, falling
+// back to the server certificate
+func (c InternalListenerConfig) CertFiles(server ServerConfig) (certFile, keyFile string) {
+	if c.TLSCertFile != "" {
+		return c.TLSCertFile, c.TLSKeyFile
+	}
+	return server.TLSCertFile, server.TLSKeyFile
+}
+
+// validate validates the internal listener settings
+func (c InternalListenerConfig) validate(server ServerConfig) error {
+	if c.Port <= 0 || c.Port > 65535 {
+		return fmt.Errorf("invalid port: %d", c.Port)
+	}
+	if c.Port == server.HTTPPort || c.Port == server.HTTPSPort {
+		return fmt.Errorf("port %d is already used by a public listener", c.Port)
+	}
+	if c.ClientCAFile == "" {
+		return fmt.Errorf("client CA file not specified")
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("tls_cert_file and tls_key_file must be set together")
+	}
+	certFile, keyFile := c.CertFiles(server)
+	if certFile == "" {
+		return fmt.Errorf("no certificate: set tls_cert_file and tls_key_file or the server certificate")
+	}
+	for _, file := range []string{c.ClientCAFile, certFile, keyFile} {
+		if _, err := os.Stat(file); err != nil {
+			return fmt.Errorf("file %s: %w", file, err)
+		}
+	}
+	for name := range c.Services {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("empty service identity")
+		}
+	}
+	return nil
+}
+
+// ParseNetworks parses a list of IP addresses and CIDR ranges
+func ParseNetworks(entries []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address: %s", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR: %s", entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
 }
 
 // LoggingConfig contains logging configuration
@@ -54,312 +217,3583 @@ type LoggingConfig struct {
 
 // AuthorizationConfig contains authorization configuration
 type AuthorizationConfig struct {
-	Enabled              bool          `yaml:"enabled" json:"enabled"`
-	CookieName           string        `yaml:"cookie_name" json:"cookie_name"`
-	JWTSigningAlgorithm  string        `yaml:"jwt_signing_algorithm" json:"jwt_signing_algorithm"`
-	JWTPublicKeyFile     string        `yaml:"jwt_public_key_file" json:"jwt_public_key_file"`
-	JWTSharedSecret      string        `yaml:"jwt_shared_secret" json:"jwt_shared_secret"`
-	ClockSkewTolerance   time.Duration `yaml:"clock_skew_tolerance" json:"clock_skew_tolerance"`
-	RequiredClaims       []string      `yaml:"required_claims" json:"required_claims"`
-	RevocationListURL    string        `yaml:"revocation_list_url" json:"revocation_list_url"`
-	RevocationListCache  time.Duration `yaml:"revocation_list_cache" json:"revocation_list_cache"`
-	CacheAuthDecisions   bool          `yaml:"cache_auth_decisions" json:"cache_auth_decisions"`
-	CacheDecisionTTL     time.Duration `yaml:"cache_decision_ttl" json:"cache_decision_ttl"`
+	Enabled             bool          `yaml:"enabled" json:"enabled"`
+	CookieName          string        `yaml:"cookie_name" json:"cookie_name"`
+	JWTSigningAlgorithm string        `yaml:"jwt_signing_algorithm" json:"jwt_signing_algorithm"`
+	JWTPublicKeyFile    string        `yaml:"jwt_public_key_file" json:"jwt_public_key_file"` // PEM public key or JWKS document
+	JWTSharedSecret     string        `yaml:"jwt_shared_secret" json:"jwt_shared_secret"`
+	ClockSkewTolerance  time.Duration `yaml:"clock_skew_tolerance" json:"clock_skew_tolerance"`
+	RequiredClaims      []string      `yaml:"required_claims" json:"required_claims"`
+	RevocationListURL   string        `yaml:"revocation_list_url" json:"revocation_list_url"`
+	RevocationListCache time.Duration `yaml:"revocation_list_cache" json:"revocation_list_cache"`
+	CacheAuthDecisions  bool          `yaml:"cache_auth_decisions" json:"cache_auth_decisions"`
+	CacheDecisionTTL    time.Duration `yaml:"cache_decision_ttl" json:"cache_decision_ttl"`
+
+	// CacheDecisionMaxEntries bounds the decision cache; the least recently
+	// used decisions are evicted beyond it
+	CacheDecisionMaxEntries int `yaml:"cache_decision_max_entries" json:"cache_decision_max_entries"`
+
+	// IdentifyPublicRequests validates session tokens and API keys presented
+	// to public routes, so rate limiting and logging can tell logged-in
+	// callers from anonymous ones. Missing or invalid credentials never
+	// reject a request to a public route.
+	IdentifyPublicRequests bool `yaml:"identify_public_requests" json:"identify_public_requests"`
+
+	// Revocation adds a Redis denylist to revocation checks and decides
+	// whether requests are rejected when revocation cannot be checked
+	Revocation RevocationConfig `yaml:"revocation" json:"revocation"`
+
+	// ClientCert authenticates clients of client-cert routes by their TLS
+	// client certificate on the HTTPS listener
+	ClientCert ClientCertConfig `yaml:"client_cert" json:"client_cert"`
+
+	// RequestSigning authenticates clients of signed routes by an HMAC
+	// signature over the request
+	RequestSigning RequestSigningConfig `yaml:"request_signing" json:"request_signing"`
+
+	// BasicAuth authenticates clients of basic routes, such as legacy
+	// tooling, by user name and bcrypt-hashed password
+	BasicAuth BasicAuthConfig `yaml:"basic_auth" json:"basic_auth"`
+
+	// EdgeLogin makes the gateway an OIDC relying party for browser apps
+	EdgeLogin EdgeLoginConfig `yaml:"edge_login" json:"edge_login"`
+
+	// Bypass lets trusted sources such as health probes and batch jobs call
+	// routes without credentials
+	Bypass AuthBypassConfig `yaml:"bypass" json:"bypass"`
+
+	// ReplayProtection stores the token IDs presented to one_time_token
+	// routes so each token is accepted there only once
+	ReplayProtection ReplayProtectionConfig `yaml:"replay_protection" json:"replay_protection"`
+
+	// TokenSources are where session tokens are read from, tried in order
+	// with the first token found winning. Empty reads only the cookie_name
+	// cookie. Routes may override them.
+	TokenSources TokenSources `yaml:"token_sources" json:"token_sources"`
+
+	// ExpectedIssuer must match the iss claim and ExpectedAudiences must
+	// include one of the aud values; empty accepts any. Routes may override them.
+	ExpectedIssuer    string   `yaml:"expected_issuer" json:"expected_issuer"`
+	ExpectedAudiences []string `yaml:"expected_audiences" json:"expected_audiences"`
+
+	// ConditionTimezone is the IANA time zone of time attributes in route
+	// conditions, e.g. Europe/Berlin; empty means UTC
+	ConditionTimezone string `yaml:"condition_timezone" json:"condition_timezone"`
+
+	// Enrichment loads additional user attributes after token validation
+	Enrichment EnrichmentConfig `yaml:"enrichment" json:"enrichment"`
+
+	// APIKeys lets clients authenticate with an API key instead of a session token
+	APIKeys APIKeyConfig `yaml:"api_keys" json:"api_keys"`
+
+	// Introspection validates opaque access tokens with the authorization server
+	Introspection IntrospectionConfig `yaml:"introspection" json:"introspection"`
+
+	// ExternalAuthz is the OPA or webhook service deciding routes with the
+	// external auth policy
+	ExternalAuthz ExternalAuthzConfig `yaml:"external_authz" json:"external_authz"`
+
+	// RoleHierarchy lists the roles each role inherits, e.g. admin: [moderator]
+	RoleHierarchy RoleHierarchyConfig `yaml:"role_hierarchy" json:"role_hierarchy"`
+
+	// DecisionLog records allow/deny decisions for audits
+	DecisionLog DecisionLogConfig `yaml:"decision_log" json:"decision_log"`
+
+	// Providers are named token issuers that routes select with
+	// auth_provider instead of the settings above
+	Providers map[string]AuthProviderConfig `yaml:"providers" json:"providers"`
 }
 
-// RateLimitConfig contains rate limiting configuration
-type RateLimitConfig struct {
-	Enabled      bool              `yaml:"enabled" json:"enabled"`
-	Backend      string            `yaml:"backend" json:"backend"` // memory or redis
-	RedisAddr    string            `yaml:"redis_addr" json:"redis_addr"`
-	RedisPassword string           `yaml:"redis_password" json:"redis_password"`
-	RedisDB      int               `yaml:"redis_db" json:"redis_db"`
-	FailureMode  string            `yaml:"failure_mode" json:"failure_mode"` // fail-open or fail-closed
-	GlobalLimits []LimitDefinition `yaml:"global_limits" json:"global_limits"`
+// AuthProviderConfig is a token issuer with its own keys and expectations,
+// e.g. the identity provider of one tenant. Tokens of routes using a provider
+// are validated locally against it only.
+type AuthProviderConfig struct {
+	JWTSigningAlgorithm string   `yaml:"jwt_signing_algorithm" json:"jwt_signing_algorithm"`
+	JWTPublicKeyFile    string   `yaml:"jwt_public_key_file" json:"jwt_public_key_file"` // PEM public key or JWKS document
+	JWTSharedSecret     string   `yaml:"jwt_shared_secret" json:"jwt_shared_secret"`
+	ExpectedIssuer      string   `yaml:"expected_issuer" json:"expected_issuer"`
+	ExpectedAudiences   []string `yaml:"expected_audiences" json:"expected_audiences"`
+	// RequiredClaims must be present in the token; names other than
+	// user_id, session_id, roles and permissions refer to custom claims
+	RequiredClaims []string `yaml:"required_claims" json:"required_claims"`
 }
 
-// LimitDefinition defines a rate limit
-type LimitDefinition struct {
-	Key      string `yaml:"key" json:"key"` // ip, user, route, or composite
-	Limit    int    `yaml:"limit" json:"limit"`
-	Window   string `yaml:"window" json:"window"` // e.g., "1m", "1h"
-	Burst    int    `yaml:"burst" json:"burst"`
+// validate validates auth provider settings
+func (c AuthProviderConfig) validate() error {
+	validAlgos := map[string]bool{"RS256": true, "RS384": true, "RS512": true, "HS256": true, "HS384": true, "HS512": true, "ES256": true, "ES384": true, "ES512": true, "EdDSA": true}
+	if !validAlgos[c.JWTSigningAlgorithm] {
+		return fmt.Errorf("invalid JWT signing algorithm: %s", c.JWTSigningAlgorithm)
+	}
+	if strings.HasPrefix(c.JWTSigningAlgorithm, "HS") {
+		if c.JWTSharedSecret == "" {
+			return fmt.Errorf("%s requires a shared secret", c.JWTSigningAlgorithm)
+		}
+	} else if c.JWTPublicKeyFile == "" {
+		return fmt.Errorf("%s requires a public key file", c.JWTSigningAlgorithm)
+	}
+	if c.ExpectedIssuer == "" {
+		return fmt.Errorf("expected issuer is required")
+	}
+	return validateAudiences(c.ExpectedAudiences)
 }
 
-// RouteConfig defines a route
-type RouteConfig struct {
-	PathPattern    string            `yaml:"path_pattern" json:"path_pattern"`
-	Methods        []string          `yaml:"methods" json:"methods"`
-	BackendURL     string            `yaml:"backend_url" json:"backend_url"`
-	Timeout        time.Duration     `yaml:"timeout" json:"timeout"`
-	AuthPolicy     string            `yaml:"auth_policy" json:"auth_policy"` // public, authenticated, role-based
-	RequiredRoles  []string          `yaml:"required_roles" json:"required_roles"`
-	RateLimits     []LimitDefinition `yaml:"rate_limits" json:"rate_limits"`
-	StripPrefix    string            `yaml:"strip_prefix" json:"strip_prefix"`
+// RevocationConfig configures the Redis denylist of revoked tokens. A token
+// is revoked while the key "<redis_key_prefix>jti:<jti>" or
+// "<redis_key_prefix>session:<session_id>" exists; revokers set the key to
+// expire with the token. Cached results are invalidated at once by
+// publishing "jti:<jti>" or "session:<session_id>" on Channel.
+type RevocationConfig struct {
+	RedisAddr      string `yaml:"redis_addr" json:"redis_addr"` // empty disables the denylist
+	RedisPassword  string `yaml:"redis_password" json:"redis_password"`
+	RedisDB        int    `yaml:"redis_db" json:"redis_db"`
+	RedisKeyPrefix string `yaml:"redis_key_prefix" json:"redis_key_prefix"`
+	Channel        string `yaml:"channel" json:"channel"`
+
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+	// FailureMode applies to the revocation list and the denylist
+	FailureMode string `yaml:"failure_mode" json:"failure_mode"` // fail-open (default) or fail-closed
 }
 
-// SecurityConfig contains security configuration
-type SecurityConfig struct {
-	// TLS Configuration
-	TLSMinVersion         string   `yaml:"tls_min_version" json:"tls_min_version"` // 1.2 or 1.3
-	TLSCipherSuites       []string `yaml:"tls_cipher_suites" json:"tls_cipher_suites"`
-	EnableHTTPSRedirect   bool     `yaml:"enable_https_redirect" json:"enable_https_redirect"`
+// validate validates revocation settings
+func (c RevocationConfig) validate() error {
+	if c.FailureMode != "" && c.FailureMode != "fail-open" && c.FailureMode != "fail-closed" {
+		return fmt.Errorf("invalid failure mode: %s (must be 'fail-open' or 'fail-closed')", c.FailureMode)
+	}
+	if c.RedisAddr == "" {
+		return nil
+	}
+	if c.RedisKeyPrefix == "" {
+		return fmt.Errorf("redis key prefix is required")
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	return nil
+}
 
-	// HSTS (HTTP Strict Transport Security)
-	EnableHSTS            bool `yaml:"enable_hsts" json:"enable_hsts"`
-	HSTSMaxAge            int  `yaml:"hsts_max_age" json:"hsts_max_age"`
-	HSTSIncludeSubdomains bool `yaml:"hsts_include_subdomains" json:"hsts_include_subdomains"`
-	HSTSPreload           bool `yaml:"hsts_preload" json:"hsts_preload"`
+// ReplayProtectionConfig configures the store of token IDs (jti) seen on
+// one_time_token routes. Each ID is kept until its token expires, in memory
+// or, with RedisAddr, in Redis shared by all gateway instances.
+type ReplayProtectionConfig struct {
+	RedisAddr      string        `yaml:"redis_addr" json:"redis_addr"` // empty keeps token IDs in memory
+	RedisPassword  string        `yaml:"redis_password" json:"redis_password"`
+	RedisDB        int           `yaml:"redis_db" json:"redis_db"`
+	RedisKeyPrefix string        `yaml:"redis_key_prefix" json:"redis_key_prefix"`
+	Timeout        time.Duration `yaml:"timeout" json:"timeout"`
+	// MaxEntries bounds the in-memory store; tokens are rejected while it
+	// is full of unexpired IDs
+	MaxEntries int `yaml:"max_entries" json:"max_entries"`
+	// MaxTTL is how long IDs of tokens without an expiry are kept
+	MaxTTL time.Duration `yaml:"max_ttl" json:"max_ttl"`
+	// FailureMode decides whether tokens are accepted when the store
+	// cannot be checked
+	FailureMode string `yaml:"failure_mode" json:"failure_mode"` // fail-closed (default) or fail-open
+}
 
-	// Security Headers
-	ContentSecurityPolicy string `yaml:"content_security_policy" json:"content_security_policy"`
-	FrameOptions          string `yaml:"frame_options" json:"frame_options"` // DENY, SAMEORIGIN
-	ContentTypeNosniff    bool   `yaml:"content_type_nosniff" json:"content_type_nosniff"`
-	XSSProtection         bool   `yaml:"xss_protection" json:"xss_protection"`
-	XSSBlockMode          bool   `yaml:"xss_block_mode" json:"xss_block_mode"`
-	ReferrerPolicy        string `yaml:"referrer_policy" json:"referrer_policy"`
-	PermissionsPolicy     string `yaml:"permissions_policy" json:"permissions_policy"`
+// validate validates replay protection settings
+func (c ReplayProtectionConfig) validate() error {
+	if c.FailureMode != "fail-open" && c.FailureMode != "fail-closed" {
+		return fmt.Errorf("invalid failure mode: %s (must be 'fail-open' or 'fail-closed')", c.FailureMode)
+	}
+	if c.MaxTTL <= 0 {
+		return fmt.Errorf("max ttl must be positive")
+	}
+	if c.RedisAddr == "" {
+		if c.MaxEntries <= 0 {
+			return fmt.Errorf("max entries must be positive")
+		}
+		return nil
+	}
+	if c.RedisKeyPrefix == "" {
+		return fmt.Errorf("redis key prefix is required")
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	return nil
+}
 
-	// Cookie Security
-	EnforceCookieSecurity bool `yaml:"enforce_cookie_security" json:"enforce_cookie_security"`
-	CookieSameSite        string `yaml:"cookie_same_site" json:"cookie_same_site"` // Strict, Lax, None
+// ClientCertConfig configures client certificate authentication. The HTTPS
+// listener asks for certificates issued by the CA in CAFile without requiring
+// them; routes with auth policy client-cert reject requests without one.
+type ClientCertConfig struct {
+	Enabled bool   `yaml:"enabled" json:"enabled"`
+	CAFile  string `yaml:"ca_file" json:"ca_file"`
+	// Mappings grant roles and permissions by certificate attributes; a
+	// certificate gets the grants of every mapping it matches
+	Mappings []ClientCertMapping `yaml:"mappings" json:"mappings"`
+}
 
-	// Input Validation
-	MaxRequestBodySize   int64    `yaml:"max_request_body_size" json:"max_request_body_size"` // bytes
-	MaxURLPathLength     int      `yaml:"max_url_path_length" json:"max_url_path_length"`
-	AllowedMethods       []string `yaml:"allowed_methods" json:"allowed_methods"`
-	BlockedUserAgents    []string `yaml:"blocked_user_agents" json:"blocked_user_agents"`
+// ClientCertMapping grants roles and permissions to certificates whose
+// attribute matches Pattern, a glob such as spiffe://mesh.example/ns/billing/*
+type ClientCertMapping struct {
+	// Attribute is san (any DNS, URI or email SAN), ou or cn
+	Attribute   string   `yaml:"attribute" json:"attribute"`
+	Pattern     string   `yaml:"pattern" json:"pattern"`
+	Roles       []string `yaml:"roles" json:"roles"`
+	Permissions []string `yaml:"permissions" json:"permissions"`
+}
 
-	// Error Disclosure
-	HideInternalErrors   bool `yaml:"hide_internal_errors" json:"hide_internal_errors"`
-	ProductionMode       bool `yaml:"production_mode" json:"production_mode"`
+// validate checks the CA file and the role mappings
+func (c ClientCertConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.CAFile == "" {
+		return fmt.Errorf("ca file is required")
+	}
+	for i, mapping := range c.Mappings {
+		switch mapping.Attribute {
+		case "san", "ou", "cn":
+		default:
+			return fmt.Errorf("mapping %d: invalid attribute: %s (must be 'san', 'ou' or 'cn')", i, mapping.Attribute)
+		}
+		if mapping.Pattern == "" {
+			return fmt.Errorf("mapping %d: pattern is required", i)
+		}
+		if _, err := path.Match(mapping.Pattern, ""); err != nil {
+			return fmt.Errorf("mapping %d: invalid pattern: %w", i, err)
+		}
+	}
+	return nil
 }
 
-// ObservabilityConfig contains observability configuration
-type ObservabilityConfig struct {
-	MetricsEnabled bool   `yaml:"metrics_enabled" json:"metrics_enabled"`
-	MetricsPort    int    `yaml:"metrics_port" json:"metrics_port"`
-	MetricsPath    string `yaml:"metrics_path" json:"metrics_path"`
-	HealthPath     string `yaml:"health_path" json:"health_path"`
-	ReadinessPath  string `yaml:"readiness_path" json:"readiness_path"`
-	LivenessPath   string `yaml:"liveness_path" json:"liveness_path"`
-	TracingEnabled bool   `yaml:"tracing_enabled" json:"tracing_enabled"`
-	TracingEndpoint string `yaml:"tracing_endpoint" json:"tracing_endpoint"`
+// RequestSigningConfig configures HMAC request signing for routes with auth
+// policy signed, e.g. webhooks and partner integrations. Clients sign the
+// method, path, query, timestamp and body digest with a shared secret:
+//
+//	Authorization: GW-HMAC-SHA256 KeyId=<key id>, Signature=<hex signature>
+//	X-Signature-Timestamp: <unix seconds>
+type RequestSigningConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// MaxClockSkew rejects timestamps further from the gateway's clock
+	MaxClockSkew time.Duration `yaml:"max_clock_skew" json:"max_clock_skew"`
+	// MaxBodySize bounds the request bodies read to verify their digest
+	MaxBodySize int64 `yaml:"max_body_size" json:"max_body_size"`
+	// Clients are the signing clients by key ID
+	Clients map[string]SigningClientConfig `yaml:"clients" json:"clients"`
 }
 
-var (
-	globalConfig *Config
-	configMu     sync.RWMutex
-)
+// SigningClientConfig is the shared secret of a signing client and what it
+// is granted
+type SigningClientConfig struct {
+	Secret      SecretRef `yaml:"secret" json:"secret"`
+	Roles       []string  `yaml:"roles" json:"roles"`
+	Permissions []string  `yaml:"permissions" json:"permissions"`
+}
 
-// Load loads configuration from file with environment variable overrides
-func Load(configPath string) (*Config, error) {
-	cfg := &Config{}
+// validate checks the limits and the client secrets
+func (c RequestSigningConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.MaxClockSkew <= 0 {
+		return fmt.Errorf("max clock skew must be positive")
+	}
+	if c.MaxBodySize <= 0 {
+		return fmt.Errorf("max body size must be positive")
+	}
+	if len(c.Clients) == 0 {
+		return fmt.Errorf("at least one client is required")
+	}
+	for keyID, client := range c.Clients {
+		if err := client.Secret.validate(); err != nil {
+			return fmt.Errorf("client %s: secret: %w", keyID, err)
+		}
+	}
+	return nil
+}
+
+// BasicAuthConfig configures HTTP Basic authentication for routes with auth
+// policy basic. Passwords are checked against bcrypt hashes from an
+// htpasswd-style file of user:hash lines, re-read when it changes, or from
+// the hash sources of Users.
+type BasicAuthConfig struct {
+	Enabled      bool   `yaml:"enabled" json:"enabled"`
+	Realm        string `yaml:"realm" json:"realm"`
+	HtpasswdFile string `yaml:"htpasswd_file" json:"htpasswd_file"`
+	// Users grant roles and permissions by user name. A PasswordHash takes
+	// precedence over the htpasswd file.
+	Users map[string]BasicAuthUser `yaml:"users" json:"users"`
+}
+
+// BasicAuthUser is the password hash of a basic auth user and what it is
+// granted
+type BasicAuthUser struct {
+	PasswordHash SecretRef `yaml:"password_hash" json:"password_hash"`
+	Roles        []string  `yaml:"roles" json:"roles"`
+	Permissions  []string  `yaml:"permissions" json:"permissions"`
+}
+
+// validate checks that every user has a password source and that inline
+// hashes are bcrypt hashes
+func (c BasicAuthConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.HtpasswdFile == "" && len(c.Users) == 0 {
+		return fmt.Errorf("htpasswd_file or users is required")
+	}
+	for name, user := range c.Users {
+		if strings.Contains(name, ":") {
+			return fmt.Errorf("user %s: name must not contain ':'", name)
+		}
+		if user.PasswordHash == (SecretRef{}) {
+			if c.HtpasswdFile == "" {
+				return fmt.Errorf("user %s: password_hash is required without htpasswd_file", name)
+			}
+			continue
+		}
+		if err := user.PasswordHash.validate(); err != nil {
+			return fmt.Errorf("user %s: password_hash: %w", name, err)
+		}
+		if user.PasswordHash.Value != "" {
+			if _, err := bcrypt.Cost([]byte(user.PasswordHash.Value)); err != nil {
+				return fmt.Errorf("user %s: password_hash is not a bcrypt hash", name)
+			}
+		}
+	}
+	return nil
+}
+
+// AuthBypassConfig trusts requests from Networks that present no
+// credentials, and requests carrying Header with the pre-shared secret of
+// one of Services, on routes whose auth policy is in Policies. Such requests
+// skip authentication and policy evaluation; every bypass is logged.
+type AuthBypassConfig struct {
+	Enabled  bool     `yaml:"enabled" json:"enabled"`
+	Networks []string `yaml:"networks" json:"networks"` // CIDRs or addresses of the client IP
+	Header   string   `yaml:"header" json:"header"`
+	// Services maps service account names to their pre-shared secrets
+	Services map[string]SecretRef `yaml:"services" json:"services"`
+	// Policies are the route auth policies that may be bypassed
+	Policies []string `yaml:"policies" json:"policies"`
+}
+
+// validate checks the trusted sources and bypassable policies
+func (c AuthBypassConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if len(c.Networks) == 0 && len(c.Services) == 0 {
+		return fmt.Errorf("networks or services is required")
+	}
+	if _, err := ParseNetworks(c.Networks); err != nil {
+		return fmt.Errorf("networks: %w", err)
+	}
+	if len(c.Services) > 0 {
+		if err := validateHeaderName(c.Header); err != nil {
+			return fmt.Errorf("header: %w", err)
+		}
+	}
+	for name, secret := range c.Services {
+		if err := secret.validate(); err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+		if len(secret.Value) > 0 && len(secret.Value) < 16 {
+			return fmt.Errorf("service %s: secret must be at least 16 characters", name)
+		}
+	}
+	for _, policy := range c.Policies {
+		switch policy {
+		case "authenticated", "role-based", "permission-based", "scope-based", "expression", "external", "client-cert", "signed", "basic":
+		default:
+			return fmt.Errorf("invalid policy: %s", policy)
+		}
+	}
+	return nil
+}
+
+// TokenSource is a place a session token is read from: the Authorization
+// bearer token, a cookie, a custom header, or a query parameter. Query
+// parameters are only read on WebSocket handshakes, where browsers cannot
+// set headers.
+type TokenSource struct {
+	Type string `yaml:"type" json:"type"` // bearer, cookie, header or query
+	// Name of the cookie, header or query parameter; a cookie without a
+	// name is the authorization cookie_name cookie
+	Name string `yaml:"name" json:"name"`
+}
+
+// TokenSources is an ordered list of token sources
+type TokenSources []TokenSource
+
+// validate checks the type and name of each source
+func (s TokenSources) validate() error {
+	for i, source := range s {
+		switch source.Type {
+		case "bearer", "cookie":
+		case "header":
+			if err := validateHeaderName(source.Name); err != nil {
+				return fmt.Errorf("source %d: %w", i, err)
+			}
+			if strings.EqualFold(source.Name, "Authorization") {
+				return fmt.Errorf("source %d: use the bearer source for the Authorization header", i)
+			}
+		case "query":
+			if source.Name == "" {
+				return fmt.Errorf("source %d: query parameter name is required", i)
+			}
+		default:
+			return fmt.Errorf("source %d: invalid type: %s (must be bearer, cookie, header or query)", i, source.Type)
+		}
+	}
+	return nil
+}
+
+// EdgeLoginConfig configures the OIDC authorization code flow run by the
+// gateway. Browser navigations that would get a 401 are redirected to the
+// identity provider; the callback exchanges the code (with PKCE) and stores
+// the tokens in an encrypted session cookie, which is refreshed server-side
+// and turned into the authorization cookie_name cookie for the routes.
+type EdgeLoginConfig struct {
+	Enabled bool   `yaml:"enabled" json:"enabled"`
+	Issuer  string `yaml:"issuer" json:"issuer"`
+	// AuthorizationURL and TokenURL are discovered from the issuer's
+	// /.well-known/openid-configuration unless both are set
+	AuthorizationURL string    `yaml:"authorization_url" json:"authorization_url"`
+	TokenURL         string    `yaml:"token_url" json:"token_url"`
+	ClientID         string    `yaml:"client_id" json:"client_id"`
+	ClientSecret     SecretRef `yaml:"client_secret" json:"client_secret"`
+	// RedirectURL is the absolute URL of the callback served by the gateway
+	RedirectURL string   `yaml:"redirect_url" json:"redirect_url"`
+	Scopes      []string `yaml:"scopes" json:"scopes"`
+	// Token is the token forwarded to authorization: access_token or id_token
+	Token              string `yaml:"token" json:"token"`
+	LogoutPath         string `yaml:"logout_path" json:"logout_path"`
+	PostLogoutRedirect string `yaml:"post_logout_redirect" json:"post_logout_redirect"`
+
+	CookieName   string `yaml:"cookie_name" json:"cookie_name"`
+	CookieDomain string `yaml:"cookie_domain" json:"cookie_domain"`
+	CookieSecure bool   `yaml:"cookie_secure" json:"cookie_secure"`
+	// EncryptionKey encrypts the session cookie; at least 32 characters
+	EncryptionKey SecretRef `yaml:"encryption_key" json:"encryption_key"`
+
+	// SessionTTL bounds a session regardless of refreshes
+	SessionTTL time.Duration `yaml:"session_ttl" json:"session_ttl"`
+	// RefreshBefore refreshes tokens expiring within this duration
+	RefreshBefore time.Duration `yaml:"refresh_before" json:"refresh_before"`
+	// Timeout bounds requests to the identity provider
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+}
+
+// validate checks the provider, client and cookie settings
+func (c EdgeLoginConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Issuer == "" && (c.AuthorizationURL == "" || c.TokenURL == "") {
+		return fmt.Errorf("issuer or authorization_url and token_url are required")
+	}
+	if c.ClientID == "" {
+		return fmt.Errorf("client_id is required")
+	}
+	if err := c.ClientSecret.validate(); err != nil {
+		return fmt.Errorf("client_secret: %w", err)
+	}
+	redirect, err := url.Parse(c.RedirectURL)
+	if err != nil || (redirect.Scheme != "http" && redirect.Scheme != "https") || redirect.Host == "" {
+		return fmt.Errorf("redirect_url must be an absolute http(s) URL")
+	}
+	if c.Token != "access_token" && c.Token != "id_token" {
+		return fmt.Errorf("invalid token: %s (must be 'access_token' or 'id_token')", c.Token)
+	}
+	if !strings.HasPrefix(c.LogoutPath, "/") || c.LogoutPath == redirect.Path {
+		return fmt.Errorf("logout_path must be a path other than the callback")
+	}
+	if c.CookieName == "" {
+		return fmt.Errorf("cookie_name is required")
+	}
+	if err := c.EncryptionKey.validate(); err != nil {
+		return fmt.Errorf("encryption_key: %w", err)
+	}
+	if c.EncryptionKey.Value != "" && len(c.EncryptionKey.Value) < 32 {
+		return fmt.Errorf("encryption_key must be at least 32 characters")
+	}
+	if c.SessionTTL <= 0 || c.RefreshBefore < 0 || c.Timeout <= 0 {
+		return fmt.Errorf("session_ttl and timeout must be positive")
+	}
+	return nil
+}
+
+// ExternalAuthzConfig configures the service deciding routes with auth policy
+// external. The gateway POSTs {"input": {...}} with the request method, path,
+// route, headers and the user's claims, and accepts an OPA response
+// ({"result": true} or {"result": {"allow": true}}) or a plain
+// {"allow": true, "reason": "..."}.
+type ExternalAuthzConfig struct {
+	URL         string        `yaml:"url" json:"url"`
+	Timeout     time.Duration `yaml:"timeout" json:"timeout"`
+	FailureMode string        `yaml:"failure_mode" json:"failure_mode"` // fail-open or fail-closed
+	// CacheTTL caches decisions per distinct input; 0 disables caching
+	CacheTTL time.Duration `yaml:"cache_ttl" json:"cache_ttl"`
+	// ExcludeHeaders are never sent, in addition to credential headers
+	ExcludeHeaders []string `yaml:"exclude_headers" json:"exclude_headers"`
+}
+
+// validate validates external authorization settings
+func (c ExternalAuthzConfig) validate() error {
+	if c.URL == "" {
+		return nil
+	}
+	if u, err := url.ParseRequestURI(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid url: %q", c.URL)
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	if c.CacheTTL < 0 {
+		return fmt.Errorf("cache ttl must not be negative")
+	}
+	if c.FailureMode != "fail-open" && c.FailureMode != "fail-closed" {
+		return fmt.Errorf("invalid failure mode: %s (must be 'fail-open' or 'fail-closed')", c.FailureMode)
+	}
+	return nil
+}
+
+// DecisionLogConfig controls the authorization decision log. Entries are
+// JSON lines in the OPA decision log format.
+type DecisionLogConfig struct {
+	Enabled         bool              `yaml:"enabled" json:"enabled"`
+	Output          string            `yaml:"output" json:"output"`                       // stdout, stderr, or file path
+	AllowSampleRate float64           `yaml:"allow_sample_rate" json:"allow_sample_rate"` // fraction of allow decisions logged
+	DenySampleRate  float64           `yaml:"deny_sample_rate" json:"deny_sample_rate"`   // fraction of deny decisions logged
+	Labels          map[string]string `yaml:"labels" json:"labels"`                       // added to every entry
+}
+
+// validate validates decision log settings
+func (c DecisionLogConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Output == "" {
+		return fmt.Errorf("output is required")
+	}
+	if c.AllowSampleRate < 0 || c.AllowSampleRate > 1 || c.DenySampleRate < 0 || c.DenySampleRate > 1 {
+		return fmt.Errorf("sample rates must be between 0 and 1")
+	}
+	return nil
+}
+
+// RoleHierarchyConfig maps a role to the roles it implies. Inheritance is
+// transitive, so admin: [moderator] and moderator: [user] grant admins the
+// user role as well.
+type RoleHierarchyConfig map[string][]string
+
+// EnrichmentConfig controls loading of user attributes (tenant, entitlements)
+// from an external store after token validation, so tokens can stay small
+type EnrichmentConfig struct {
+	Enabled bool   `yaml:"enabled" json:"enabled"`
+	Source  string `yaml:"source" json:"source"` // http, redis, or a registered source
+
+	// HTTP source: GET <url>?user_id=<id> returning a JSON object
+	URL string `yaml:"url" json:"url"`
+
+	// Redis source: HGETALL <key_prefix><user_id>
+	RedisAddr      string `yaml:"redis_addr" json:"redis_addr"`
+	RedisPassword  string `yaml:"redis_password" json:"redis_password"`
+	RedisDB        int    `yaml:"redis_db" json:"redis_db"`
+	RedisKeyPrefix string `yaml:"redis_key_prefix" json:"redis_key_prefix"`
+
+	Timeout     time.Duration `yaml:"timeout" json:"timeout"`
+	CacheTTL    time.Duration `yaml:"cache_ttl" json:"cache_ttl"`       // 0 disables caching
+	FailureMode string        `yaml:"failure_mode" json:"failure_mode"` // fail-open or fail-closed
+}
+
+// IntrospectionConfig validates access tokens through an OAuth2 token
+// introspection endpoint (RFC 7662), authenticating with client
+// credentials. In mode opaque only tokens that are not JWTs are introspected;
+// in mode always every token is, and no JWT key is needed.
+type IntrospectionConfig struct {
+	Enabled      bool   `yaml:"enabled" json:"enabled"`
+	URL          string `yaml:"url" json:"url"`
+	ClientID     string `yaml:"client_id" json:"client_id"`
+	ClientSecret string `yaml:"client_secret" json:"client_secret"`
+	Mode         string `yaml:"mode" json:"mode"` // opaque or always
+
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+	// CacheTTL caches active results, but never past the token's exp; 0
+	// disables caching
+	CacheTTL time.Duration `yaml:"cache_ttl" json:"cache_ttl"`
+}
+
+// IntrospectsAll reports whether every token is introspected rather than
+// validated as a JWT
+func (c IntrospectionConfig) IntrospectsAll() bool {
+	return c.Enabled && c.Mode == "always"
+}
+
+// APIKeyConfig controls API key authentication. Clients send the key in
+// Header; keys are looked up by their SHA-256 hash so plain keys are never
+// stored. Keys can be created, rotated and revoked through the admin API.
+type APIKeyConfig struct {
+	Enabled bool   `yaml:"enabled" json:"enabled"`
+	Header  string `yaml:"header" json:"header"` // defaults to X-Api-Key
+	Store   string `yaml:"store" json:"store"`   // config, redis, or a registered store
+
+	// Config store: keys declared here; admin changes last until restart
+	Keys []APIKeyDefinition `yaml:"keys" json:"keys"`
+
+	// Redis store: records under <key_prefix>key:<id>, hashes under <key_prefix>hash:<sha256>
+	RedisAddr      string `yaml:"redis_addr" json:"redis_addr"`
+	RedisPassword  string `yaml:"redis_password" json:"redis_password"`
+	RedisDB        int    `yaml:"redis_db" json:"redis_db"`
+	RedisKeyPrefix string `yaml:"redis_key_prefix" json:"redis_key_prefix"`
+
+	// RotationGracePeriod keeps the previous key of a rotated key valid so
+	// clients can switch over
+	RotationGracePeriod time.Duration `yaml:"rotation_grace_period" json:"rotation_grace_period"`
+}
+
+// APIKeyDefinition declares an API key for the config store
+type APIKeyDefinition struct {
+	ID          string    `yaml:"id" json:"id"`                 // identifies the caller, like a user ID
+	KeySHA256   string    `yaml:"key_sha256" json:"key_sha256"` // hex SHA-256 of the key
+	Roles       []string  `yaml:"roles" json:"roles"`
+	Permissions []string  `yaml:"permissions" json:"permissions"`
+	Tier        string    `yaml:"tier" json:"tier"` // selects rate_limit.tiers
+	ExpiresAt   time.Time `yaml:"expires_at" json:"expires_at"`
+}
+
+// RateLimitConfig contains rate limiting configuration
+type RateLimitConfig struct {
+	Enabled       bool              `yaml:"enabled" json:"enabled"`
+	Backend       string            `yaml:"backend" json:"backend"` // memory or redis
+	RedisAddr     string            `yaml:"redis_addr" json:"redis_addr"`
+	RedisPassword string            `yaml:"redis_password" json:"redis_password"`
+	RedisDB       int               `yaml:"redis_db" json:"redis_db"`
+	FailureMode   string            `yaml:"failure_mode" json:"failure_mode"` // fail-open or fail-closed
+	GlobalLimits  []LimitDefinition `yaml:"global_limits" json:"global_limits"`
+
+	// Tiers replace the global limits for callers in a tier, such as the
+	// tier of an API key. Callers without a tier of their own fall into the
+	// "authenticated" or "anonymous" tier, if configured.
+	Tiers map[string][]LimitDefinition `yaml:"tiers" json:"tiers"`
+
+	// IPv4PrefixLength and IPv6PrefixLength aggregate client addresses into
+	// networks for "ip" keys, so a client holding a whole IPv6 /64 cannot
+	// escape its limit by rotating addresses
+	IPv4PrefixLength int `yaml:"ipv4_prefix_length" json:"ipv4_prefix_length"`
+	IPv6PrefixLength int `yaml:"ipv6_prefix_length" json:"ipv6_prefix_length"`
+
+	// SessionAbuse tightens the "session" limits of sessions that keep
+	// getting 4xx responses, such as a stolen token used for probing
+	SessionAbuse SessionAbuseConfig `yaml:"session_abuse" json:"session_abuse"`
+
+	// Quota caps the requests of each user or API key over a day or a
+	// month, on top of the short-window limits
+	Quota QuotaConfig `yaml:"quota" json:"quota"`
+
+	// Adaptive tightens the limits of routes whose responses get slow or
+	// fail, and relaxes them again once the route is healthy
+	Adaptive AdaptiveRateLimitConfig `yaml:"adaptive" json:"adaptive"`
+
+	// Degraded keeps limiting requests in local memory while the Redis
+	// backend is unreachable, instead of applying the failure mode
+	Degraded DegradedModeConfig `yaml:"degraded" json:"degraded"`
+}
+
+// DegradedModeConfig configures the fallback of the Redis backend. Once a
+// Redis operation fails, each instance counts requests in its own memory,
+// with limits scaled by LimitFactor since every instance now admits its own
+// share, and probes Redis every CheckInterval. When Redis answers again,
+// the local state is copied to keys Redis does not hold and requests are
+// counted in Redis again.
+type DegradedModeConfig struct {
+	Enabled       bool          `yaml:"enabled" json:"enabled"`
+	LimitFactor   float64       `yaml:"limit_factor" json:"limit_factor"`
+	CheckInterval time.Duration `yaml:"check_interval" json:"check_interval"`
+}
+
+// validate checks the degraded mode settings
+func (d *DegradedModeConfig) validate() error {
+	if !d.Enabled {
+		return nil
+	}
+	if d.LimitFactor <= 0 || d.LimitFactor > 1 {
+		return fmt.Errorf("limit_factor must be greater than 0 and at most 1")
+	}
+	if d.CheckInterval <= 0 {
+		return fmt.Errorf("check_interval must be positive")
+	}
+	return nil
+}
+
+// AdaptiveRateLimitConfig configures AIMD load shedding. Every Interval the
+// p99 latency and 5xx rate of each route's responses are compared with the
+// thresholds: an unhealthy route has the factor applied to its limits cut by
+// DecreaseFactor, down to MinFactor; a healthy one has it raised by
+// IncreaseStep, up to 1. Intervals with fewer than MinSamples responses
+// count as healthy. Factors are kept per gateway instance.
+type AdaptiveRateLimitConfig struct {
+	Enabled            bool          `yaml:"enabled" json:"enabled"`
+	Interval           time.Duration `yaml:"interval" json:"interval"`
+	LatencyThreshold   time.Duration `yaml:"latency_threshold" json:"latency_threshold"`       // p99
+	ErrorRateThreshold float64       `yaml:"error_rate_threshold" json:"error_rate_threshold"` // fraction of 5xx responses
+	MinSamples         int           `yaml:"min_samples" json:"min_samples"`
+	DecreaseFactor     float64       `yaml:"decrease_factor" json:"decrease_factor"`
+	IncreaseStep       float64       `yaml:"increase_step" json:"increase_step"`
+	MinFactor          float64       `yaml:"min_factor" json:"min_factor"`
+}
+
+// validate checks the adaptive rate limit settings
+func (a *AdaptiveRateLimitConfig) validate() error {
+	if !a.Enabled {
+		return nil
+	}
+	if a.Interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	if a.LatencyThreshold <= 0 {
+		return fmt.Errorf("latency_threshold must be positive")
+	}
+	if a.ErrorRateThreshold <= 0 || a.ErrorRateThreshold > 1 {
+		return fmt.Errorf("error_rate_threshold must be greater than 0 and at most 1")
+	}
+	if a.MinSamples <= 0 {
+		return fmt.Errorf("min_samples must be positive")
+	}
+	if a.DecreaseFactor <= 0 || a.DecreaseFactor >= 1 {
+		return fmt.Errorf("decrease_factor must be between 0 and 1")
+	}
+	if a.IncreaseStep <= 0 || a.IncreaseStep > 1 {
+		return fmt.Errorf("increase_step must be greater than 0 and at most 1")
+	}
+	if a.MinFactor <= 0 || a.MinFactor > 1 {
+		return fmt.Errorf("min_factor must be greater than 0 and at most 1")
+	}
+	return nil
+}
+
+// QuotaConfig configures long-window request budgets per authenticated
+// caller. Usage is counted per period, which starts at ResetHour on every
+// day, or on ResetDay of every month, in Timezone. Anonymous callers have
+// no quota.
+type QuotaConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Store is memory, redis, or a store registered with
+	// ratelimit.RegisterQuotaStore, such as DynamoDB
+	Store          string `yaml:"store" json:"store"`
+	RedisAddr      string `yaml:"redis_addr" json:"redis_addr"`
+	RedisPassword  string `yaml:"redis_password" json:"redis_password"`
+	RedisDB        int    `yaml:"redis_db" json:"redis_db"`
+	RedisKeyPrefix string `yaml:"redis_key_prefix" json:"redis_key_prefix"`
+
+	Period    string `yaml:"period" json:"period"`         // daily or monthly
+	ResetHour int    `yaml:"reset_hour" json:"reset_hour"` // 0-23
+	ResetDay  int    `yaml:"reset_day" json:"reset_day"`   // 1-28, monthly periods only
+	Timezone  string `yaml:"timezone" json:"timezone"`     // IANA name, UTC by default
+
+	// Limit is the budget per period; Tiers override it for callers in a
+	// rate limit tier. A budget of 0 leaves the caller unlimited.
+	Limit int64            `yaml:"limit" json:"limit"`
+	Tiers map[string]int64 `yaml:"tiers" json:"tiers"`
+}
+
+// validate checks the quota settings
+func (q *QuotaConfig) validate() error {
+	if !q.Enabled {
+		return nil
+	}
+	if q.Store == "" {
+		return fmt.Errorf("store is required")
+	}
+	if q.Store == "redis" && q.RedisAddr == "" {
+		return fmt.Errorf("redis_addr is required for the redis store")
+	}
+	switch q.Period {
+	case "daily":
+	case "monthly":
+		if q.ResetDay < 1 || q.ResetDay > 28 {
+			return fmt.Errorf("reset_day must be between 1 and 28")
+		}
+	default:
+		return fmt.Errorf("invalid period: %s (must be 'daily' or 'monthly')", q.Period)
+	}
+	if q.ResetHour < 0 || q.ResetHour > 23 {
+		return fmt.Errorf("reset_hour must be between 0 and 23")
+	}
+	if _, err := time.LoadLocation(q.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone: %w", err)
+	}
+	if q.Limit < 0 {
+		return fmt.Errorf("limit must not be negative")
+	}
+	for tier, limit := range q.Tiers {
+		if limit < 0 {
+			return fmt.Errorf("tier %s: limit must not be negative", tier)
+		}
+	}
+	return nil
+}
+
+// SessionAbuseConfig configures the automatic tightening of limits keyed by
+// session. A session that gets ErrorThreshold 4xx responses within Window
+// has the limits keyed by "session" cut to LimitFactor of their configured
+// value for Penalty. Other sessions of the same user are not affected.
+// Error counts are kept per gateway instance.
+type SessionAbuseConfig struct {
+	Enabled        bool          `yaml:"enabled" json:"enabled"`
+	ErrorThreshold int           `yaml:"error_threshold" json:"error_threshold"`
+	Window         time.Duration `yaml:"window" json:"window"`
+	Penalty        time.Duration `yaml:"penalty" json:"penalty"`
+	LimitFactor    float64       `yaml:"limit_factor" json:"limit_factor"` // fraction of the limit kept, e.g. 0.1
+}
+
+// validate checks the session abuse settings
+func (s *SessionAbuseConfig) validate() error {
+	if !s.Enabled {
+		return nil
+	}
+	if s.ErrorThreshold <= 0 {
+		return fmt.Errorf("error_threshold must be positive")
+	}
+	if s.Window <= 0 {
+		return fmt.Errorf("window must be positive")
+	}
+	if s.Penalty <= 0 {
+		return fmt.Errorf("penalty must be positive")
+	}
+	if s.LimitFactor <= 0 || s.LimitFactor > 1 {
+		return fmt.Errorf("limit_factor must be greater than 0 and at most 1")
+	}
+	return nil
+}
+
+// LimitDefinition defines a rate limit
+type LimitDefinition struct {
+	Key    string `yaml:"key" json:"key"` // key template, e.g. ip, user, claim:tenant_id:route or header:X-Api-Key
+	Limit  int    `yaml:"limit" json:"limit"`
+	Window string `yaml:"window" json:"window"` // e.g., "1m", "1h"
+	Burst  int    `yaml:"burst" json:"burst"`
+
+	// Algorithm is token_bucket (default), sliding_window, gcra or
+	// fixed_window. A sliding window never admits more than Limit requests
+	// in any Window; GCRA spaces requests evenly, admitting Burst (default
+	// 1) back to back. Fixed windows count requests per Window in the
+	// memory of each instance, cheaply enough for millions of keys.
+	Algorithm string `yaml:"algorithm" json:"algorithm"`
+
+	// Policy decides what happens to requests over the limit: reject
+	// (default) answers 429 at once, delay holds each request up to MaxDelay
+	// until the limit admits it, and queue holds up to QueueSize requests
+	// per key in FIFO order, each up to MaxDelay, to smooth bursts
+	Policy    string        `yaml:"policy" json:"policy"`
+	MaxDelay  time.Duration `yaml:"max_delay" json:"max_delay"`
+	QueueSize int           `yaml:"queue_size" json:"queue_size"`
+}
+
+// ConcurrencyLimit caps the requests in flight at once per key, such as
+// "user" for each user of a route or "route" for the route as a whole.
+// Counts are kept per gateway instance.
+type ConcurrencyLimit struct {
+	Key string `yaml:"key" json:"key"` // same keys as rate limits
+	Max int    `yaml:"max" json:"max"`
+	// Status answers requests over the limit: 429 (default) blames the
+	// caller, 503 the capacity of the route
+	Status int `yaml:"status" json:"status"`
+}
+
+// validate checks a concurrency limit
+func (c ConcurrencyLimit) validate() error {
+	if err := validateKeyTemplate(c.Key); err != nil {
+		return err
+	}
+	if c.Max <= 0 {
+		return fmt.Errorf("max must be positive")
+	}
+	if c.Status != 0 && c.Status != http.StatusTooManyRequests && c.Status != http.StatusServiceUnavailable {
+		return fmt.Errorf("invalid status: %d (must be 429 or 503)", c.Status)
+	}
+	return nil
+}
+
+// validLimitAlgorithms are the supported rate limiting algorithms
+var validLimitAlgorithms = map[string]bool{
+	"":               true,
+	"token_bucket":   true,
+	"sliding_window": true,
+	"gcra":           true,
+	"fixed_window":   true,
+}
+
+// keyTemplateParts are the parts of rate limit key templates; true marks
+// parts followed by a name, as in header:X-Api-Key
+var keyTemplateParts = map[string]bool{
+	"ip":      false,
+	"user":    false,
+	"session": false,
+	"service": false,
+	"route":   false,
+	"method":  false,
+	"api_key": false,
+	"header":  true,
+	"claim":   true,
+}
+
+// validateKeyTemplate checks a rate limit key template such as
+// claim:tenant_id:route
+func validateKeyTemplate(template string) error {
+	if template == "" {
+		return fmt.Errorf("key is required")
+	}
+	parts := strings.Split(template, ":")
+	for i := 0; i < len(parts); i++ {
+		part := strings.TrimSpace(parts[i])
+		named, ok := keyTemplateParts[part]
+		if !ok {
+			return fmt.Errorf("invalid key %q: unknown part %q", template, part)
+		}
+		if named {
+			i++
+			if i >= len(parts) || strings.TrimSpace(parts[i]) == "" {
+				return fmt.Errorf("invalid key %q: %s requires a name", template, part)
+			}
+		}
+	}
+	return nil
+}
+
+// validate checks the key and algorithm of the limit
+func (l LimitDefinition) validate() error {
+	if err := validateKeyTemplate(l.Key); err != nil {
+		return err
+	}
+	if !validLimitAlgorithms[l.Algorithm] {
+		return fmt.Errorf("invalid algorithm: %s (must be token_bucket, sliding_window, gcra or fixed_window)", l.Algorithm)
+	}
+	switch l.Policy {
+	case "", "reject":
+	case "delay", "queue":
+		if l.MaxDelay <= 0 {
+			return fmt.Errorf("max_delay must be positive for policy %s", l.Policy)
+		}
+	default:
+		return fmt.Errorf("invalid policy: %s (must be reject, delay or queue)", l.Policy)
+	}
+	if l.Policy == "queue" && l.QueueSize <= 0 {
+		return fmt.Errorf("queue_size must be positive for policy queue")
+	}
+	return nil
+}
+
+// capacity returns the most tokens a single request may take from the limit:
+// the burst of token buckets (default Limit) and GCRA (default 1), and Limit
+// for windows
+func (l LimitDefinition) capacity() int {
+	switch l.Algorithm {
+	case "sliding_window", "fixed_window":
+		return l.Limit
+	case "gcra":
+		return max(l.Burst, 1)
+	}
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Limit
+}
+
+// validateCost checks that each limit can admit a request costing cost;
+// any other request would be rejected forever
+func validateCost(cost int, limits []LimitDefinition) error {
+	for i, limit := range limits {
+		if capacity := limit.capacity(); cost > capacity {
+			return fmt.Errorf("limit %d: cost %d exceeds its capacity of %d", i, cost, capacity)
+		}
+	}
+	return nil
+}
+
+// validateLimits checks a list of limit definitions
+func validateLimits(limits []LimitDefinition) error {
+	for i, limit := range limits {
+		if err := limit.validate(); err != nil {
+			return fmt.Errorf("limit %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// RouteConfig defines a route
+type RouteConfig struct {
+	PathPattern   string            `yaml:"path_pattern" json:"path_pattern"`
+	Methods       []string          `yaml:"methods" json:"methods"`
+	BackendURL    string            `yaml:"backend_url" json:"backend_url"`
+	Timeout       time.Duration     `yaml:"timeout" json:"timeout"`         // shorthand for Timeouts.Total
+	AuthPolicy    string            `yaml:"auth_policy" json:"auth_policy"` // public, authenticated, role-based, permission-based, scope-based, expression, external, client-cert, signed, basic
+	RequiredRoles []string          `yaml:"required_roles" json:"required_roles"`
+	RateLimits    []LimitDefinition `yaml:"rate_limits" json:"rate_limits"`
+	// ConcurrencyLimits cap the requests of the route in flight at once,
+	// e.g. per user for long-running exports
+	ConcurrencyLimits []ConcurrencyLimit `yaml:"concurrency_limits" json:"concurrency_limits"`
+	// Cost is the tokens a request of the route takes from its rate limits
+	// and quota (default 1), e.g. 10 for searches. CostHeader names a
+	// response header in which the backend reports the actual cost; any
+	// excess over Cost is charged once the response arrives.
+	Cost        int               `yaml:"cost" json:"cost"`
+	CostHeader  string            `yaml:"cost_header" json:"cost_header"`
+	StripPrefix string            `yaml:"strip_prefix" json:"strip_prefix"`
+	UpstreamTLS UpstreamTLSConfig `yaml:"upstream_tls" json:"upstream_tls"`
+
+	// Permissions (wildcards like orders:* or admin:** allowed) and OAuth
+	// scopes required by the permission-based and scope-based policies
+	RequiredPermissions []string `yaml:"required_permissions" json:"required_permissions"`
+	RequiredScopes      []string `yaml:"required_scopes" json:"required_scopes"`
+	// AuthExpression is the requirement of the expression policy, e.g.
+	// scope:read:orders AND (role:manager OR permission:orders:**)
+	AuthExpression string `yaml:"auth_expression" json:"auth_expression"`
+
+	// Request decompression for backends that cannot handle Content-Encoding,
+	// decoding like compression.decompress_requests; a
+	// MaxDecompressedBodySize of 0 uses the 10 MB default
+	DecompressRequest       bool  `yaml:"decompress_request" json:"decompress_request"`
+	MaxDecompressedBodySize int64 `yaml:"max_decompressed_body_size" json:"max_decompressed_body_size"` // bytes
+
+	// Transport overrides the backend connection pool settings for this route
+	Transport TransportConfig `yaml:"transport" json:"transport"`
+
+	// Upload mode streams large request bodies straight to the backend without
+	// retries, body limits or transformations
+	UploadMode    bool          `yaml:"upload_mode" json:"upload_mode"`
+	UploadTimeout time.Duration `yaml:"upload_timeout" json:"upload_timeout"`
+
+	// MaxRequestBodySize overrides security.max_request_body_size, such as
+	// with a higher limit for upload endpoints; upload mode routes without
+	// one are not limited
+	MaxRequestBodySize int64 `yaml:"max_request_body_size" json:"max_request_body_size"` // bytes
+
+	// Ranges limits the byte ranges clients may request
+	Ranges RangeConfig `yaml:"ranges" json:"ranges"`
+
+	// Header manipulation applied to backend requests and client responses
+	RequestHeaders  HeaderRules `yaml:"request_headers" json:"request_headers"`
+	ResponseHeaders HeaderRules `yaml:"response_headers" json:"response_headers"`
+
+	// CachePolicy controls the caching headers sent to clients and CDNs
+	CachePolicy CachePolicyConfig `yaml:"cache_policy" json:"cache_policy"`
+
+	// JSON body rewrites applied before forwarding and before responding
+	RequestTransform  BodyTransformConfig `yaml:"request_transform" json:"request_transform"`
+	ResponseTransform BodyTransformConfig `yaml:"response_transform" json:"response_transform"`
+
+	// RequestSchema rejects JSON request bodies not matching an inline JSON Schema
+	RequestSchema RequestSchemaConfig `yaml:"request_schema" json:"request_schema"`
+
+	// TokenSources override the authorization token sources for the route
+	TokenSources TokenSources `yaml:"token_sources" json:"token_sources"`
+
+	// OneTimeToken accepts each session token only once, for sensitive
+	// routes such as payment callbacks. Tokens need a jti claim, and API
+	// keys are not accepted.
+	OneTimeToken bool `yaml:"one_time_token" json:"one_time_token"`
+
+	// StepUp requires a stronger authentication, such as MFA, than the
+	// session token may carry
+	StepUp StepUpConfig `yaml:"step_up" json:"step_up"`
+
+	// Timeouts sets distinct connect, response header and total backend timeouts
+	Timeouts RouteTimeoutsConfig `yaml:"timeouts" json:"timeouts"`
+
+	// Retry overrides the default retry behaviour for this route
+	Retry RetryPolicyConfig `yaml:"retry" json:"retry"`
+
+	// CircuitBreaker overrides the proxy circuit breaker defaults. Routes
+	// sharing a backend share its breaker, which uses the settings of the
+	// route that last sent a request.
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker" json:"circuit_breaker"`
+
+	// Fallback answers requests while the circuit breaker of the backend is
+	// open, instead of the generic gateway error
+	Fallback FallbackConfig `yaml:"fallback" json:"fallback"`
+
+	// Protected marks revenue- or security-critical routes (payments, auth)
+	// that fault injection, experiments and dry runs must never target
+	Protected bool `yaml:"protected" json:"protected"`
+
+	// SandboxBackendURL receives requests detected as test traffic instead of BackendURL
+	SandboxBackendURL string `yaml:"sandbox_backend_url" json:"sandbox_backend_url"`
+
+	// Instances are additional scheme://host[:port] addresses serving the same
+	// backend; requests are balanced across BackendURL and these instances,
+	// each with a circuit breaker of its own
+	Instances []string `yaml:"instances" json:"instances"`
+
+	// OutlierDetection temporarily ejects misbehaving instances
+	OutlierDetection OutlierDetectionConfig `yaml:"outlier_detection" json:"outlier_detection"`
+
+	// ProxyProtocol sends a PROXY protocol header (v1 or v2) with the client
+	// address on every backend connection; such connections are not reused
+	ProxyProtocol string `yaml:"proxy_protocol" json:"proxy_protocol"`
+
+	// OwnershipCheck asks an ownership endpoint whether the user may modify
+	// the addressed resource before the request is proxied
+	OwnershipCheck OwnershipCheckConfig `yaml:"ownership_check" json:"ownership_check"`
+
+	// MaxInFlight overrides proxy.max_in_flight_per_backend for this route
+	MaxInFlight int `yaml:"max_in_flight" json:"max_in_flight"`
+
+	// PriorityClass orders requests waiting for a backend slot: critical,
+	// normal (default) or low. Low priority requests are shed first.
+	PriorityClass string `yaml:"priority_class" json:"priority_class"`
+
+	// Conditions are attribute-based access expressions that must all hold
+	// after the auth policy allowed the request, e.g. claims.tenant == path.tenantId
+	Conditions []string `yaml:"conditions" json:"conditions"`
+
+	// ExpectedIssuer and ExpectedAudiences override the authorization
+	// defaults for tokens presented to this route
+	ExpectedIssuer    string   `yaml:"expected_issuer" json:"expected_issuer"`
+	ExpectedAudiences []string `yaml:"expected_audiences" json:"expected_audiences"`
+
+	// AuthProvider validates tokens of this route against a named provider
+	// of authorization.providers; route expectations still override its own
+	AuthProvider string `yaml:"auth_provider" json:"auth_provider"`
+
+	// BackendAuth injects a static credential into every backend request
+	BackendAuth BackendAuthConfig `yaml:"backend_auth" json:"backend_auth"`
+
+	// ForwardClientToken forwards the client's token to the backend even
+	// when the gateway mints identity tokens
+	ForwardClientToken bool `yaml:"forward_client_token" json:"forward_client_token"`
+
+	// Mirror copies a sample of the route's traffic to a shadow backend
+	Mirror MirrorConfig `yaml:"mirror" json:"mirror"`
+
+	// TransportSecurity refuses plaintext and weak TLS for sensitive routes
+	// regardless of the listener defaults
+	TransportSecurity TransportSecurityConfig `yaml:"transport_security" json:"transport_security"`
+
+	// RequestAge rejects requests whose client timestamp is too far from
+	// the gateway's clock
+	RequestAge RequestAgeConfig `yaml:"request_age" json:"request_age"`
+}
+
+// StepUpConfig requires session tokens to carry one of the ACR values in
+// their acr claim and all of the AMR values in their amr claim. Other tokens
+// get a 401 with insufficient_authentication_level and the required values,
+// so clients can run a step-up flow.
+type StepUpConfig struct {
+	ACR []string `yaml:"acr" json:"acr"` // e.g. urn:example:mfa, any one suffices
+	AMR []string `yaml:"amr" json:"amr"` // e.g. mfa, hwk; all are required
+}
+
+// Enabled reports whether the route requires step-up authentication
+func (c StepUpConfig) Enabled() bool {
+	return len(c.ACR) > 0 || len(c.AMR) > 0
+}
+
+// validate checks the required values
+func (c StepUpConfig) validate() error {
+	for _, values := range [][]string{c.ACR, c.AMR} {
+		for _, value := range values {
+			if value == "" || strings.ContainsAny(value, " \"") {
+				return fmt.Errorf("invalid value %q", value)
+			}
+		}
+	}
+	return nil
+}
+
+// DefaultRequestAgeHeaders are the headers carrying the client timestamp
+// unless a route configures its own
+var DefaultRequestAgeHeaders = []string{"X-Request-Timestamp", "Date"}
+
+// RequestAgeConfig rejects requests whose client timestamp deviates from the
+// gateway's clock by more than MaxSkew, bounding how long a captured request
+// stays usable. Headers are tried in order and hold unix seconds or
+// milliseconds, an RFC 3339 time, or an HTTP date like the Date header.
+type RequestAgeConfig struct {
+	MaxSkew time.Duration `yaml:"max_skew" json:"max_skew"` // 0 disables the check
+	Headers []string      `yaml:"headers" json:"headers"`   // default X-Request-Timestamp, Date
+	// AllowMissing accepts requests without a timestamp
+	AllowMissing bool `yaml:"allow_missing" json:"allow_missing"`
+}
+
+// Enabled reports whether the route checks request timestamps
+func (c RequestAgeConfig) Enabled() bool {
+	return c.MaxSkew > 0
+}
+
+// TimestampHeaders returns the headers carrying the client timestamp
+func (c RequestAgeConfig) TimestampHeaders() []string {
+	if len(c.Headers) == 0 {
+		return DefaultRequestAgeHeaders
+	}
+	return c.Headers
+}
+
+// validate validates request age settings
+func (c RequestAgeConfig) validate() error {
+	if c.MaxSkew < 0 {
+		return fmt.Errorf("max_skew must not be negative")
+	}
+	for _, header := range c.Headers {
+		if err := validateHeaderName(header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TransportSecurityConfig hardens a route beyond the listener defaults.
+// RequireTLS answers plaintext requests with PlaintextStatus instead of
+// redirecting them, even when security.enable_https_redirect is off.
+// MinTLSVersion, stricter than security.tls_min_version, rejects connections
+// negotiating an older version with 403 and implies RequireTLS. Requests a
+// trusted proxy terminated TLS for count as TLS by their X-Forwarded-Proto;
+// their TLS version cannot be checked.
+type TransportSecurityConfig struct {
+	RequireTLS      bool   `yaml:"require_tls" json:"require_tls"`
+	PlaintextStatus int    `yaml:"plaintext_status" json:"plaintext_status"` // 426 (default) or 403
+	MinTLSVersion   string `yaml:"min_tls_version" json:"min_tls_version"`   // 1.2 or 1.3
+}
+
+// Enabled reports whether the route restricts its transport
+func (c TransportSecurityConfig) Enabled() bool {
+	return c.RequireTLS || c.MinTLSVersion != ""
+}
+
+// validate validates transport security settings
+func (c TransportSecurityConfig) validate() error {
+	switch c.PlaintextStatus {
+	case 0, http.StatusUpgradeRequired, http.StatusForbidden:
+	default:
+		return fmt.Errorf("invalid plaintext_status: %d (must be 426 or 403)", c.PlaintextStatus)
+	}
+	switch c.MinTLSVersion {
+	case "", "1.2", "1.3":
+	default:
+		return fmt.Errorf("invalid min_tls_version: %s (must be '1.2' or '1.3')", c.MinTLSVersion)
+	}
+	return nil
+}
+
+// OwnershipCheckConfig configures a pre-authorization callout for ownership
+// checks the gateway cannot derive from the token. URL is a template that may
+// reference ${param.name}, ${claim.name} and ${attr.name}; a 2xx answer allows
+// the request, 401, 403 and 404 deny it with 403.
+type OwnershipCheckConfig struct {
+	URL         string        `yaml:"url" json:"url"`
+	Method      string        `yaml:"method" json:"method"`   // HEAD (default) or GET
+	Methods     []string      `yaml:"methods" json:"methods"` // request methods to check; defaults to POST, PUT, PATCH, DELETE
+	Timeout     time.Duration `yaml:"timeout" json:"timeout"`
+	CacheTTL    time.Duration `yaml:"cache_ttl" json:"cache_ttl"`       // 0 disables caching
+	FailureMode string        `yaml:"failure_mode" json:"failure_mode"` // fail-closed (default) or fail-open
+}
+
+// Enabled reports whether an ownership check is configured
+func (c OwnershipCheckConfig) Enabled() bool {
+	return c.URL != ""
+}
+
+// validate validates ownership check settings
+func (c OwnershipCheckConfig) validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if !strings.HasPrefix(c.URL, "http://") && !strings.HasPrefix(c.URL, "https://") {
+		return fmt.Errorf("url must start with http:// or https://")
+	}
+	if err := validateHeaderTemplate(c.URL); err != nil {
+		return fmt.Errorf("url: %w", err)
+	}
+	if c.Method != "" && c.Method != http.MethodHead && c.Method != http.MethodGet {
+		return fmt.Errorf("invalid method: %s (must be HEAD or GET)", c.Method)
+	}
+	if c.Timeout < 0 || c.CacheTTL < 0 {
+		return fmt.Errorf("timeout and cache ttl must not be negative")
+	}
+	if c.FailureMode != "" && c.FailureMode != "fail-open" && c.FailureMode != "fail-closed" {
+		return fmt.Errorf("invalid failure mode: %s (must be 'fail-open' or 'fail-closed')", c.FailureMode)
+	}
+	return nil
+}
+
+// BackendAuthConfig injects a credential the backend expects from the
+// gateway. Type header sets Header to Value; type basic sends HTTP basic
+// auth with Username and Password. Any credential the client sent in the
+// same header is replaced.
+type BackendAuthConfig struct {
+	Type     string    `yaml:"type" json:"type"`     // header or basic
+	Header   string    `yaml:"header" json:"header"` // header name for type header
+	Value    SecretRef `yaml:"value" json:"value"`
+	Username string    `yaml:"username" json:"username"`
+	Password SecretRef `yaml:"password" json:"password"`
+}
+
+// SecretRef is a secret given inline, by environment variable or by file.
+// Exactly one source must be set. Files are re-read when they change, so
+// credentials can be rotated without restarting the gateway.
+type SecretRef struct {
+	Value string `yaml:"value" json:"value"`
+	Env   string `yaml:"env" json:"env"`
+	File  string `yaml:"file" json:"file"`
+}
+
+// Enabled reports whether a backend credential is configured
+func (c BackendAuthConfig) Enabled() bool {
+	return c.Type != ""
+}
+
+// usesHeader reports whether the credential is sent in the named header
+func (c BackendAuthConfig) usesHeader(name string) bool {
+	switch c.Type {
+	case "header":
+		return strings.EqualFold(c.Header, name)
+	case "basic":
+		return strings.EqualFold(name, "Authorization")
+	}
+	return false
+}
+
+// validate validates backend auth settings
+func (c BackendAuthConfig) validate() error {
+	switch c.Type {
+	case "":
+		return nil
+	case "header":
+		if c.Header == "" {
+			return fmt.Errorf("header auth requires a header name")
+		}
+		if err := c.Value.validate(); err != nil {
+			return fmt.Errorf("value: %w", err)
+		}
+	case "basic":
+		if c.Username == "" {
+			return fmt.Errorf("basic auth requires a username")
+		}
+		if err := c.Password.validate(); err != nil {
+			return fmt.Errorf("password: %w", err)
+		}
+	default:
+		return fmt.Errorf("invalid type: %s (must be 'header' or 'basic')", c.Type)
+	}
+	return nil
+}
+
+// validate checks that exactly one source is set and that an environment
+// variable source is present
+func (s SecretRef) validate() error {
+	sources := 0
+	for _, source := range []string{s.Value, s.Env, s.File} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("exactly one of value, env or file is required")
+	}
+	if s.Env != "" && os.Getenv(s.Env) == "" {
+		return fmt.Errorf("environment variable %s is not set", s.Env)
+	}
+	return nil
+}
+
+// MirrorConfig copies a sample of a route's requests to a shadow backend.
+// Shadow responses are discarded and never affect the client. Requests
+// carrying OptInHeader are always mirrored, others with SamplePercent
+// probability.
+type MirrorConfig struct {
+	BackendURL    string        `yaml:"backend_url" json:"backend_url"`
+	SamplePercent float64       `yaml:"sample_percent" json:"sample_percent"` // 0-100
+	OptInHeader   string        `yaml:"opt_in_header" json:"opt_in_header"`
+	Timeout       time.Duration `yaml:"timeout" json:"timeout"` // defaults to 5s
+}
+
+// Enabled reports whether mirroring is configured
+func (c MirrorConfig) Enabled() bool {
+	return c.BackendURL != ""
+}
+
+// validate validates mirror settings
+func (c MirrorConfig) validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if u, err := url.ParseRequestURI(c.BackendURL); err != nil || u.Host == "" {
+		return fmt.Errorf("invalid backend URL: %s", c.BackendURL)
+	}
+	if c.SamplePercent < 0 || c.SamplePercent > 100 {
+		return fmt.Errorf("sample percent must be between 0 and 100")
+	}
+	if c.SamplePercent == 0 && c.OptInHeader == "" {
+		return fmt.Errorf("sample percent or opt-in header is required")
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	return nil
+}
+
+// OutlierDetectionConfig controls ejection of backend instances that fail or
+// respond slowly. Zero values fall back to the proxy defaults.
+type OutlierDetectionConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// ConsecutiveErrors ejects an instance after this many 5xx responses or
+	// connection failures in a row
+	ConsecutiveErrors int `yaml:"consecutive_errors" json:"consecutive_errors"`
+
+	// LatencyThreshold ejects an instance whose latency at LatencyPercentile
+	// exceeds it, once MinRequests samples are available (0 disables)
+	LatencyThreshold  time.Duration `yaml:"latency_threshold" json:"latency_threshold"`
+	LatencyPercentile float64       `yaml:"latency_percentile" json:"latency_percentile"` // e.g. 99
+	MinRequests       int           `yaml:"min_requests" json:"min_requests"`
+
+	// Ejection time doubles with every repeated ejection up to MaxEjectionTime
+	BaseEjectionTime   time.Duration `yaml:"base_ejection_time" json:"base_ejection_time"`
+	MaxEjectionTime    time.Duration `yaml:"max_ejection_time" json:"max_ejection_time"`
+	MaxEjectionPercent int           `yaml:"max_ejection_percent" json:"max_ejection_percent"`
+}
+
+// validate validates outlier detection settings
+func (c OutlierDetectionConfig) validate() error {
+	if c.ConsecutiveErrors < 0 || c.MinRequests < 0 {
+		return fmt.Errorf("consecutive errors and min requests must not be negative")
+	}
+	if c.LatencyThreshold < 0 || c.BaseEjectionTime < 0 || c.MaxEjectionTime < 0 {
+		return fmt.Errorf("latency threshold and ejection times must not be negative")
+	}
+	if c.LatencyPercentile < 0 || c.LatencyPercentile > 100 {
+		return fmt.Errorf("latency percentile must be between 0 and 100")
+	}
+	if c.MaxEjectionPercent < 0 || c.MaxEjectionPercent > 100 {
+		return fmt.Errorf("max ejection percent must be between 0 and 100")
+	}
+	if c.MaxEjectionTime > 0 && c.BaseEjectionTime > c.MaxEjectionTime {
+		return fmt.Errorf("base ejection time must not exceed max ejection time")
+	}
+	return nil
+}
+
+// RouteTimeoutsConfig contains per-phase backend timeouts for a route.
+// Zero values inherit the gateway-wide defaults.
+type RouteTimeoutsConfig struct {
+	Connect        time.Duration `yaml:"connect" json:"connect"`                 // establishing the TCP connection
+	ResponseHeader time.Duration `yaml:"response_header" json:"response_header"` // waiting for response headers after the request is sent
+	Total          time.Duration `yaml:"total" json:"total"`                     // whole exchange including all retries and the response body
+}
+
+// validate validates route timeout settings
+func (c RouteTimeoutsConfig) validate() error {
+	if c.Connect < 0 || c.ResponseHeader < 0 || c.Total < 0 {
+		return fmt.Errorf("timeouts must not be negative")
+	}
+	if c.Total > 0 && (c.Connect > c.Total || c.ResponseHeader > c.Total) {
+		return fmt.Errorf("connect and response header timeouts must not exceed the total timeout")
+	}
+	return nil
+}
+
+// RetryPolicyConfig controls how failed backend requests are retried.
+// Zero values fall back to the proxy defaults; only idempotent methods are
+// retried unless Methods is set.
+type RetryPolicyConfig struct {
+	MaxAttempts   int           `yaml:"max_attempts" json:"max_attempts"`       // total attempts including the first
+	Methods       []string      `yaml:"methods" json:"methods"`                 // methods that may be retried
+	StatusCodes   []int         `yaml:"status_codes" json:"status_codes"`       // backend statuses that trigger a retry, e.g. 502, 503
+	PerTryTimeout time.Duration `yaml:"per_try_timeout" json:"per_try_timeout"` // timeout of a single attempt
+	Backoff       time.Duration `yaml:"backoff" json:"backoff"`                 // base delay, doubled on each retry
+}
+
+// BodyTransformConfig declares rewrites of JSON bodies.
+// Paths use dot notation into nested objects (e.g., "user.internal_id", an
+// optional "$." prefix is accepted). Operations run in the order unwrap,
+// remove, rename, set, wrap. Set values support the same ${param.name} and
+// ${claim.name} references as header rules.
+type BodyTransformConfig struct {
+	Unwrap      string            `yaml:"unwrap" json:"unwrap"` // replace the body with the value at this path
+	Remove      []string          `yaml:"remove" json:"remove"`
+	Rename      map[string]string `yaml:"rename" json:"rename"` // source path -> destination path
+	Set         map[string]string `yaml:"set" json:"set"`
+	Wrap        string            `yaml:"wrap" json:"wrap"`                   // wrap the body in an object under this path
+	MaxBodySize int64             `yaml:"max_body_size" json:"max_body_size"` // bytes, 0 = default
+}
+
+// Enabled reports whether any body transformation is configured
+func (c BodyTransformConfig) Enabled() bool {
+	return c.Unwrap != "" || len(c.Remove) > 0 || len(c.Rename) > 0 || len(c.Set) > 0 || c.Wrap != ""
+}
+
+// RequestSchemaConfig validates JSON request bodies before they are forwarded.
+// Requests whose body does not match get 400 with the JSON pointer of every
+// violation.
+type RequestSchemaConfig struct {
+	Schema      map[string]interface{} `yaml:"schema" json:"schema"`               // inline JSON Schema
+	MaxBodySize int64                  `yaml:"max_body_size" json:"max_body_size"` // bytes, 0 = default
+}
+
+// Enabled reports whether a request schema is configured
+func (c RequestSchemaConfig) Enabled() bool {
+	return len(c.Schema) > 0
+}
+
+func (c RequestSchemaConfig) validate() error {
+	if c.MaxBodySize < 0 {
+		return fmt.Errorf("max body size must not be negative")
+	}
+	if c.Enabled() {
+		if _, err := jsonschema.Compile(c.Schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CachePolicyConfig contains caching headers injected into successful responses.
+// Empty values leave the backend's header untouched.
+type CachePolicyConfig struct {
+	CacheControl     string        `yaml:"cache_control" json:"cache_control"`         // e.g., "no-store", "public, max-age=3600"
+	SurrogateControl string        `yaml:"surrogate_control" json:"surrogate_control"` // e.g., "max-age=86400"
+	Expires          time.Duration `yaml:"expires" json:"expires"`                     // relative to the response time
+	// Override replaces headers set by the backend; otherwise they are only added when missing
+	Override bool `yaml:"override" json:"override"`
+
+	// Vary lists request headers the response depends on in addition to the
+	// backend's own Vary header
+	Vary []string `yaml:"vary" json:"vary"`
+
+	// Store caches responses in the gateway itself
+	Store ResponseCacheConfig `yaml:"store" json:"store"`
+}
+
+// ResponseCacheConfig enables the gateway response cache for a route. GET
+// responses are stored according to their Cache-Control and keyed by URL
+// and the normalized values of the request headers they vary on.
+// Responses to authenticated requests are only cached when SubjectClaim is
+// set, and are then kept apart per user.
+type ResponseCacheConfig struct {
+	Enabled     bool          `yaml:"enabled" json:"enabled"`
+	DefaultTTL  time.Duration `yaml:"default_ttl" json:"default_ttl"`     // for responses without max-age; 0 stores only those with one
+	MaxEntries  int           `yaml:"max_entries" json:"max_entries"`     // defaults to 1000
+	MaxBodySize int64         `yaml:"max_body_size" json:"max_body_size"` // bytes, defaults to 1 MB
+	// SubjectClaim identifies the user, e.g. sub or user_id; Vary on
+	// Authorization or Cookie is keyed by it instead of the raw credential
+	SubjectClaim string `yaml:"subject_claim" json:"subject_claim"`
+	// AllowHeaders keeps per-user response headers such as Set-Cookie in
+	// stored entries; by default they are stripped before storing
+	AllowHeaders []string `yaml:"allow_headers" json:"allow_headers"`
+	// AllowCredentialVary stores responses that vary on Authorization or
+	// Cookie; by default they are not cached
+	AllowCredentialVary bool `yaml:"allow_credential_vary" json:"allow_credential_vary"`
+}
+
+// HeaderRules declares header manipulations for a route.
+// Rules are applied in the order remove, set, add. Values may reference
+// route parameters as ${param.name} and token claims as ${claim.name}.
+type HeaderRules struct {
+	Set    map[string]string `yaml:"set" json:"set"`
+	Add    map[string]string `yaml:"add" json:"add"`
+	Remove []string          `yaml:"remove" json:"remove"`
+}
+
+// RangeConfig contains gateway-side validation of Range requests.
+// Range headers are passed to the backend untouched unless they violate these limits.
+type RangeConfig struct {
+	MaxRanges         int  `yaml:"max_ranges" json:"max_ranges"` // 0 = unlimited
+	RejectOverlapping bool `yaml:"reject_overlapping" json:"reject_overlapping"`
+}
+
+// TransportConfig contains backend connection pool settings.
+// Zero values inherit the gateway-wide defaults.
+type TransportConfig struct {
+	MaxIdleConnsPerHost   int           `yaml:"max_idle_conns_per_host" json:"max_idle_conns_per_host"`
+	DialTimeout           time.Duration `yaml:"dial_timeout" json:"dial_timeout"`
+	TLSHandshakeTimeout   time.Duration `yaml:"tls_handshake_timeout" json:"tls_handshake_timeout"`
+	ResponseHeaderTimeout time.Duration `yaml:"response_header_timeout" json:"response_header_timeout"`
+	IdleConnTimeout       time.Duration `yaml:"idle_conn_timeout" json:"idle_conn_timeout"`
+	ExpectContinueTimeout time.Duration `yaml:"expect_continue_timeout" json:"expect_continue_timeout"`
+
+	// HTTP2 selects the backend protocol: "" for HTTP/1.1, "auto" to
+	// negotiate HTTP/2 over TLS, or "h2c" for cleartext HTTP/2 with prior
+	// knowledge. HTTP/2 multiplexes requests over few connections.
+	HTTP2 string `yaml:"http2" json:"http2"`
+}
+
+// Enabled reports whether any transport option is overridden
+func (c TransportConfig) Enabled() bool {
+	return c != TransportConfig{}
+}
+
+// PassthroughRouteConfig tunnels TLS connections on the HTTPS listener whose
+// SNI matches one of the hostnames straight to the backend, without
+// terminating TLS, for backends requiring end-to-end TLS or speaking other
+// protocols over TLS. No HTTP middleware applies to these connections.
+type PassthroughRouteConfig struct {
+	// SNI lists hostnames, exact or wildcards such as *.db.example.com
+	SNI            []string      `yaml:"sni" json:"sni"`
+	Backend        string        `yaml:"backend" json:"backend"` // host:port
+	ConnectTimeout time.Duration `yaml:"connect_timeout" json:"connect_timeout"`
+}
+
+// validate validates a passthrough route
+func (c PassthroughRouteConfig) validate() error {
+	if len(c.SNI) == 0 {
+		return fmt.Errorf("at least one sni hostname is required")
+	}
+	for _, name := range c.SNI {
+		host := strings.TrimPrefix(name, "*.")
+		if host == "" || strings.ContainsAny(host, "*:/ ") {
+			return fmt.Errorf("invalid sni hostname: %q", name)
+		}
+	}
+	host, port, err := net.SplitHostPort(c.Backend)
+	if n, portErr := strconv.Atoi(port); err != nil || host == "" || portErr != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid backend %q: must be host:port", c.Backend)
+	}
+	if c.ConnectTimeout < 0 {
+		return fmt.Errorf("connect timeout must not be negative")
+	}
+	return nil
+}
+
+// UpstreamTLSConfig contains TLS settings for connections to a route's backend
+type UpstreamTLSConfig struct {
+	CAFile             string `yaml:"ca_file" json:"ca_file"`     // PEM bundle used to verify the backend
+	CertFile           string `yaml:"cert_file" json:"cert_file"` // client certificate for mTLS
+	KeyFile            string `yaml:"key_file" json:"key_file"`   // client key for mTLS
+	ServerName         string `yaml:"server_name" json:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify" json:"insecure_skip_verify"`
+}
+
+// Enabled reports whether any upstream TLS option is configured
+func (c UpstreamTLSConfig) Enabled() bool {
+	return c != UpstreamTLSConfig{}
+}
+
+// ProxyConfig contains backend proxy configuration
+type ProxyConfig struct {
+	// Headers used to tell backends the public URL of a request whose path was
+	// rewritten by the gateway. An empty name disables the header.
+	ForwardedPrefixHeader string `yaml:"forwarded_prefix_header" json:"forwarded_prefix_header"`
+	OriginalURLHeader     string `yaml:"original_url_header" json:"original_url_header"`
+
+	// ForwardedHeaders selects the headers describing the client to backends:
+	// x-forwarded (X-Forwarded-For/-Proto/-Host), rfc7239 (Forwarded) or both.
+	// ForwardedBy is the by= node of Forwarded; empty uses the listener address.
+	ForwardedHeaders string `yaml:"forwarded_headers" json:"forwarded_headers"`
+	ForwardedBy      string `yaml:"forwarded_by" json:"forwarded_by"`
+
+	// Default retry behaviour; a route's retry section overrides it
+	MaxRetries int           `yaml:"max_retries" json:"max_retries"` // retries after the first attempt
+	RetryDelay time.Duration `yaml:"retry_delay" json:"retry_delay"` // base backoff, doubled on each retry
+
+	// Backend connection pooling and timeouts; a route's transport section
+	// overrides them
+	MaxIdleConns        int           `yaml:"max_idle_conns" json:"max_idle_conns"`
+	MaxIdleConnsPerHost int           `yaml:"max_idle_conns_per_host" json:"max_idle_conns_per_host"`
+	IdleConnTimeout     time.Duration `yaml:"idle_conn_timeout" json:"idle_conn_timeout"`
+	DialTimeout         time.Duration `yaml:"dial_timeout" json:"dial_timeout"`
+	TLSHandshakeTimeout time.Duration `yaml:"tls_handshake_timeout" json:"tls_handshake_timeout"`
+	DefaultTimeout      time.Duration `yaml:"default_timeout" json:"default_timeout"` // total timeout for routes without their own
+
+	// ExpectContinueTimeout is how long the body of a request with
+	// "Expect: 100-continue" is held back until the backend answers with
+	// 100 Continue, so rejected uploads are never sent; 0 sends it at once
+	ExpectContinueTimeout time.Duration `yaml:"expect_continue_timeout" json:"expect_continue_timeout"`
+
+	// RetryBudget caps retries across all routes to prevent retry storms
+	RetryBudget RetryBudgetConfig `yaml:"retry_budget" json:"retry_budget"`
+
+	// RequestBuffering keeps request bodies so retries can resend them
+	RequestBuffering RequestBufferingConfig `yaml:"request_buffering" json:"request_buffering"`
+
+	// MaxInFlightPerBackend caps concurrent requests to each backend so a slow
+	// backend cannot tie up the whole gateway; 0 means unlimited. Requests over
+	// the limit wait in the backend's queue, if configured, and are otherwise
+	// rejected with 503. Retry-After is derived from the queue depth and the
+	// rate at which the backend completes requests; BulkheadRetryAfter applies
+	// until that rate is known and BulkheadMaxRetryAfter caps it.
+	MaxInFlightPerBackend int           `yaml:"max_in_flight_per_backend" json:"max_in_flight_per_backend"`
+	BulkheadRetryAfter    time.Duration `yaml:"bulkhead_retry_after" json:"bulkhead_retry_after"`
+	BulkheadMaxRetryAfter time.Duration `yaml:"bulkhead_max_retry_after" json:"bulkhead_max_retry_after"`
+
+	// BulkheadQueue lets requests over the in-flight limit wait for a slot
+	BulkheadQueue BulkheadQueueConfig `yaml:"bulkhead_queue" json:"bulkhead_queue"`
+
+	// IdentityToken replaces the client's token with one minted by the gateway
+	IdentityToken IdentityTokenConfig `yaml:"identity_token" json:"identity_token"`
+
+	// Metadata attaches a signed summary of the gateway's handling to
+	// backend requests
+	Metadata GatewayMetadataConfig `yaml:"metadata" json:"metadata"`
+
+	// CircuitBreaker is the default breaker of each backend; a route's
+	// circuit_breaker section overrides it
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker" json:"circuit_breaker"`
+
+	// CircuitBreakerWebhook notifies operators of breaker state changes
+	CircuitBreakerWebhook BreakerWebhookConfig `yaml:"circuit_breaker_webhook" json:"circuit_breaker_webhook"`
+
+	// CircuitBreakerStore keeps open breakers open across restarts
+	CircuitBreakerStore BreakerStoreConfig `yaml:"circuit_breaker_store" json:"circuit_breaker_store"`
+
+	// CircuitBreakerGC bounds the breakers kept for backends that come and go
+	CircuitBreakerGC BreakerGCConfig `yaml:"circuit_breaker_gc" json:"circuit_breaker_gc"`
+
+	// CDNPurge forwards response cache purges to the CDN in front of the gateway
+	CDNPurge CDNPurgeConfig `yaml:"cdn_purge" json:"cdn_purge"`
+}
+
+// CircuitBreakerConfig controls the circuit breaker of a backend. In
+// consecutive mode the breaker opens after FailureThreshold consecutive
+// failures; in sliding_window mode once at least MinimumCalls calls were made
+// within Window and FailureRateThreshold percent of them failed or
+// SlowCallRateThreshold percent took SlowCallDuration or longer, which suits
+// high-traffic backends better. An open breaker rejects requests for
+// Timeout, then lets probe requests through, up to MaxHalfOpenRequests at a
+// time, and closes after SuccessThreshold of them succeed in a row. With a
+// ProbePath, the gateway probes that path of the backend every ProbeInterval
+// instead and keeps rejecting requests until the backend recovered, so users
+// do not see the errors of probes. Responses with one of
+// FailureStatusCodes count as failures and are retried unless the route's
+// retry section lists its own status codes. Zero values of a route fall back
+// to the proxy defaults.
+type CircuitBreakerConfig struct {
+	Mode                string        `yaml:"mode" json:"mode"` // consecutive or sliding_window
+	FailureThreshold    int           `yaml:"failure_threshold" json:"failure_threshold"`
+	SuccessThreshold    int           `yaml:"success_threshold" json:"success_threshold"`
+	Timeout             time.Duration `yaml:"timeout" json:"timeout"` // time spent open before probing
+	MaxHalfOpenRequests int           `yaml:"max_half_open_requests" json:"max_half_open_requests"`
+
+	ProbePath     string        `yaml:"probe_path" json:"probe_path"` // e.g. /health; answered 2xx when healthy
+	ProbeInterval time.Duration `yaml:"probe_interval" json:"probe_interval"`
+	ProbeTimeout  time.Duration `yaml:"probe_timeout" json:"probe_timeout"`
+
+	Window                time.Duration `yaml:"window" json:"window"`
+	MinimumCalls          int           `yaml:"minimum_calls" json:"minimum_calls"`
+	FailureRateThreshold  float64       `yaml:"failure_rate_threshold" json:"failure_rate_threshold"`     // percent
+	SlowCallRateThreshold float64       `yaml:"slow_call_rate_threshold" json:"slow_call_rate_threshold"` // percent
+	SlowCallDuration      time.Duration `yaml:"slow_call_duration" json:"slow_call_duration"`
+
+	FailureStatusCodes []int `yaml:"failure_status_codes" json:"failure_status_codes"` // e.g. 502, 503, 504
+}
+
+// WithDefaults returns the breaker settings with unset values taken from
+// defaults
+func (c CircuitBreakerConfig) WithDefaults(defaults CircuitBreakerConfig) CircuitBreakerConfig {
+	if c.Mode == "" {
+		c.Mode = defaults.Mode
+	}
+	if c.FailureThreshold == 0 {
+		c.FailureThreshold = defaults.FailureThreshold
+	}
+	if c.SuccessThreshold == 0 {
+		c.SuccessThreshold = defaults.SuccessThreshold
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaults.Timeout
+	}
+	if c.MaxHalfOpenRequests == 0 {
+		c.MaxHalfOpenRequests = defaults.MaxHalfOpenRequests
+	}
+	if c.ProbePath == "" {
+		c.ProbePath = defaults.ProbePath
+	}
+	if c.ProbeInterval == 0 {
+		c.ProbeInterval = defaults.ProbeInterval
+	}
+	if c.ProbeTimeout == 0 {
+		c.ProbeTimeout = defaults.ProbeTimeout
+	}
+	if c.Window == 0 {
+		c.Window = defaults.Window
+	}
+	if c.MinimumCalls == 0 {
+		c.MinimumCalls = defaults.MinimumCalls
+	}
+	if c.FailureRateThreshold == 0 {
+		c.FailureRateThreshold = defaults.FailureRateThreshold
+	}
+	if c.SlowCallRateThreshold == 0 {
+		c.SlowCallRateThreshold = defaults.SlowCallRateThreshold
+	}
+	if c.SlowCallDuration == 0 {
+		c.SlowCallDuration = defaults.SlowCallDuration
+	}
+	if len(c.FailureStatusCodes) == 0 {
+		c.FailureStatusCodes = defaults.FailureStatusCodes
+	}
+	return c
+}
+
+// validateOverride validates the breaker settings of a route on top of
+// defaults
+func (c CircuitBreakerConfig) validateOverride(defaults CircuitBreakerConfig) error {
+	if c.FailureThreshold < 0 || c.SuccessThreshold < 0 || c.MaxHalfOpenRequests < 0 || c.Timeout < 0 ||
+		c.ProbeInterval < 0 || c.ProbeTimeout < 0 || c.Window < 0 || c.MinimumCalls < 0 || c.FailureRateThreshold < 0 || c.SlowCallRateThreshold < 0 || c.SlowCallDuration < 0 {
+		return fmt.Errorf("settings must not be negative")
+	}
+	return c.WithDefaults(defaults).validate()
+}
+
+// validate validates resolved circuit breaker settings
+func (c CircuitBreakerConfig) validate() error {
+	if c.FailureThreshold <= 0 || c.SuccessThreshold <= 0 || c.MaxHalfOpenRequests <= 0 {
+		return fmt.Errorf("thresholds and max half-open requests must be positive")
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	if c.ProbePath != "" {
+		if !strings.HasPrefix(c.ProbePath, "/") {
+			return fmt.Errorf("probe path must start with /")
+		}
+		if c.ProbeInterval <= 0 || c.ProbeTimeout <= 0 {
+			return fmt.Errorf("probe interval and timeout must be positive")
+		}
+	}
+	for _, code := range c.FailureStatusCodes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("invalid failure status code: %d", code)
+		}
+	}
+	switch c.Mode {
+	case "consecutive":
+	case "sliding_window":
+		if c.Window <= 0 || c.MinimumCalls <= 0 || c.SlowCallDuration <= 0 {
+			return fmt.Errorf("window, minimum calls and slow call duration must be positive")
+		}
+		if c.FailureRateThreshold <= 0 || c.FailureRateThreshold > 100 || c.SlowCallRateThreshold <= 0 || c.SlowCallRateThreshold > 100 {
+			return fmt.Errorf("rate thresholds must be greater than 0 and at most 100")
+		}
+	default:
+		return fmt.Errorf("invalid mode: %s (must be consecutive or sliding_window)", c.Mode)
+	}
+	return nil
+}
+
+// BreakerWebhookConfig sends each circuit breaker state change into one of
+// States as a JSON event to URL. Events are sent in the background and are
+// dropped when the webhook does not answer within Timeout.
+type BreakerWebhookConfig struct {
+	URL     string        `yaml:"url" json:"url"`
+	States  []string      `yaml:"states" json:"states"` // closed, open, half-open
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+}
+
+// validate validates breaker webhook settings
+func (c BreakerWebhookConfig) validate() error {
+	if c.URL == "" {
+		return nil
+	}
+	u, err := url.ParseRequestURI(c.URL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid URL: %q", c.URL)
+	}
+	for _, state := range c.States {
+		if state != "closed" && state != "open" && state != "half-open" {
+			return fmt.Errorf("invalid state: %s (must be closed, open or half-open)", state)
+		}
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	return nil
+}
+
+// CDNPurgeConfig forwards purges of the response cache to a CDN, so it does
+// not keep serving what the gateway dropped. Fastly purges by surrogate key
+// the cached responses of ServiceID using the API token Token; CloudFront
+// invalidates the paths of DistributionID, authenticating with AccessKeyID
+// and SecretAccessKey. Purges the provider cannot express, such as a path
+// purge on Fastly, only apply to the gateway.
+type CDNPurgeConfig struct {
+	Provider string        `yaml:"provider" json:"provider"` // fastly or cloudfront; empty disables CDN purges
+	Timeout  time.Duration `yaml:"timeout" json:"timeout"`
+
+	ServiceID string    `yaml:"service_id" json:"service_id"`
+	Token     SecretRef `yaml:"token" json:"token"`
+
+	DistributionID  string    `yaml:"distribution_id" json:"distribution_id"`
+	AccessKeyID     string    `yaml:"access_key_id" json:"access_key_id"`
+	SecretAccessKey SecretRef `yaml:"secret_access_key" json:"secret_access_key"`
+}
+
+// validate validates CDN purge settings
+func (c CDNPurgeConfig) validate() error {
+	switch c.Provider {
+	case "":
+		return nil
+	case "fastly":
+		if c.ServiceID == "" {
+			return fmt.Errorf("service_id is required for fastly")
+		}
+		if err := c.Token.validate(); err != nil {
+			return fmt.Errorf("token: %w", err)
+		}
+	case "cloudfront":
+		if c.DistributionID == "" {
+			return fmt.Errorf("distribution_id is required for cloudfront")
+		}
+		if c.AccessKeyID == "" {
+			return fmt.Errorf("access_key_id is required for cloudfront")
+		}
+		if err := c.SecretAccessKey.validate(); err != nil {
+			return fmt.Errorf("secret_access_key: %w", err)
+		}
+	default:
+		return fmt.Errorf("invalid provider: %s (must be fastly or cloudfront)", c.Provider)
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	return nil
+}
+
+// BreakerStoreConfig persists the state of open and half-open circuit
+// breakers in a file or in Redis, so a restarted gateway, or with Redis any
+// instance of the fleet, keeps them open instead of sending a failing backend
+// a burst of requests. Saved state older than MaxAge is ignored.
+type BreakerStoreConfig struct {
+	Type           string        `yaml:"type" json:"type"` // file or redis; empty disables persistence
+	Path           string        `yaml:"path" json:"path"`
+	RedisAddr      string        `yaml:"redis_addr" json:"redis_addr"`
+	RedisPassword  string        `yaml:"redis_password" json:"redis_password"`
+	RedisDB        int           `yaml:"redis_db" json:"redis_db"`
+	RedisKeyPrefix string        `yaml:"redis_key_prefix" json:"redis_key_prefix"`
+	MaxAge         time.Duration `yaml:"max_age" json:"max_age"`
+}
+
+// validate validates breaker store settings
+func (c BreakerStoreConfig) validate() error {
+	switch c.Type {
+	case "":
+		return nil
+	case "file":
+		if c.Path == "" {
+			return fmt.Errorf("path is required for the file store")
+		}
+	case "redis":
+		if c.RedisAddr == "" {
+			return fmt.Errorf("redis_addr is required for the redis store")
+		}
+		if c.RedisKeyPrefix == "" {
+			return fmt.Errorf("redis key prefix is required")
+		}
+	default:
+		return fmt.Errorf("invalid type: %s (must be file or redis)", c.Type)
+	}
+	if c.MaxAge <= 0 {
+		return fmt.Errorf("max age must be positive")
+	}
+	return nil
+}
+
+// BreakerGCConfig bounds the circuit breakers kept for backend URLs, which
+// otherwise grow with every URL ever seen, such as of discovered instances.
+// Breakers not asked for within IdleTTL are evicted, and once MaxBreakers
+// exist a new breaker evicts the least recently used one, preferring closed
+// breakers. Zero values disable the limit.
+type BreakerGCConfig struct {
+	IdleTTL     time.Duration `yaml:"idle_ttl" json:"idle_ttl"`
+	MaxBreakers int           `yaml:"max_breakers" json:"max_breakers"`
+}
+
+// validate validates breaker garbage collection settings
+func (c BreakerGCConfig) validate() error {
+	if c.IdleTTL < 0 {
+		return fmt.Errorf("idle TTL must not be negative")
+	}
+	if c.MaxBreakers < 0 {
+		return fmt.Errorf("max breakers must not be negative")
+	}
+	return nil
+}
+
+// FallbackConfig controls the response of a route while the circuit breaker
+// of its backend is open. A static fallback returns Body encoded as JSON with
+// Status and Headers; a cache fallback returns the last successful response
+// to the same GET request of the same user, if not older than MaxAge and
+// cacheable as a shared response would be by the response cache; a
+// backend fallback proxies the request to BackendURL, without the route's
+// backend credential or identity token. Without a usable fallback the
+// request fails with 503.
+type FallbackConfig struct {
+	Type       string            `yaml:"type" json:"type"` // static, cache or backend
+	Status     int               `yaml:"status" json:"status"`
+	Body       interface{}       `yaml:"body" json:"body"`
+	Headers    map[string]string `yaml:"headers" json:"headers"`
+	MaxAge     time.Duration     `yaml:"max_age" json:"max_age"` // 0 accepts responses of any age
+	BackendURL string            `yaml:"backend_url" json:"backend_url"`
+
+	// AllowCredentialVary keeps cache fallback responses that vary on
+	// Authorization or Cookie; by default they are not kept
+	AllowCredentialVary bool `yaml:"allow_credential_vary" json:"allow_credential_vary"`
+}
+
+// Enabled reports whether the route has a fallback
+func (c FallbackConfig) Enabled() bool {
+	return c.Type != ""
+}
+
+// validate validates fallback settings
+func (c FallbackConfig) validate() error {
+	switch c.Type {
+	case "":
+		return nil
+	case "static":
+		if c.Status != 0 && (c.Status < 100 || c.Status > 599) {
+			return fmt.Errorf("invalid status: %d", c.Status)
+		}
+		if _, err := json.Marshal(c.Body); err != nil {
+			return fmt.Errorf("body cannot be encoded as JSON: %w", err)
+		}
+		for name := range c.Headers {
+			if name == "" {
+				return fmt.Errorf("header names must not be empty")
+			}
+		}
+	case "cache":
+		if c.MaxAge < 0 {
+			return fmt.Errorf("max age must not be negative")
+		}
+	case "backend":
+		u, err := url.ParseRequestURI(c.BackendURL)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid backend URL: %q", c.BackendURL)
+		}
+	default:
+		return fmt.Errorf("invalid type: %s (must be static, cache or backend)", c.Type)
+	}
+	return nil
+}
+
+// BulkheadQueueConfig controls the wait queue of each backend at its
+// in-flight limit. Waiting requests get free slots by route priority class
+// (critical, normal, low); when the queue is full, a request displaces the
+// newest waiter of a lower class or is shed.
+type BulkheadQueueConfig struct {
+	Size    int           `yaml:"size" json:"size"` // waiting requests per backend; 0 disables queueing
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+}
+
+// validate validates bulkhead queue settings
+func (c BulkheadQueueConfig) validate() error {
+	if c.Size < 0 {
+		return fmt.Errorf("size must not be negative")
+	}
+	if c.Size > 0 && c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	return nil
+}
+
+// priorityClasses are the valid route priority classes, highest first
+var priorityClasses = map[string]bool{
+	"critical": true,
+	"normal":   true,
+	"low":      true,
+}
+
+// GatewayMetadataConfig controls the signed metadata header describing the
+// matched route, authenticated subject, policy result and remaining rate
+// limit. Backends verify it with the pkg/gatewaymeta package.
+type GatewayMetadataConfig struct {
+	Enabled bool   `yaml:"enabled" json:"enabled"`
+	Header  string `yaml:"header" json:"header"`
+	// Secret is the HMAC-SHA256 key shared with backends
+	Secret SecretRef `yaml:"secret" json:"secret"`
+	// InstanceID identifies this gateway; empty uses the hostname
+	InstanceID string `yaml:"instance_id" json:"instance_id"`
+	// TTL is how long backends accept the metadata
+	TTL time.Duration `yaml:"ttl" json:"ttl"`
+}
+
+// validate validates gateway metadata settings
+func (c GatewayMetadataConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if err := validateHeaderName(c.Header); err != nil {
+		return err
+	}
+	if err := c.Secret.validate(); err != nil {
+		return fmt.Errorf("secret: %w", err)
+	}
+	if c.TTL <= 0 {
+		return fmt.Errorf("ttl must be positive")
+	}
+	return nil
+}
+
+// IdentityTokenConfig makes the gateway mint a short-lived JWT describing the
+// authenticated user for backends instead of forwarding the client's token or
+// session cookie, so backends trust the gateway's key rather than the
+// identity provider. Routes with forward_client_token keep the client's token.
+type IdentityTokenConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Header carries the token; Authorization is sent as "Bearer <token>"
+	Header    string `yaml:"header" json:"header"`
+	Algorithm string `yaml:"algorithm" json:"algorithm"`
+	// PrivateKeyFile is a PEM key for RS*, ES* and EdDSA; Secret is the key
+	// for HS*. Both are re-read when they change.
+	PrivateKeyFile string        `yaml:"private_key_file" json:"private_key_file"`
+	Secret         SecretRef     `yaml:"secret" json:"secret"`
+	KeyID          string        `yaml:"key_id" json:"key_id"` // kid header
+	Issuer         string        `yaml:"issuer" json:"issuer"`
+	Audiences      []string      `yaml:"audiences" json:"audiences"`
+	TTL            time.Duration `yaml:"ttl" json:"ttl"`
+}
+
+// validate validates identity token settings
+func (c IdentityTokenConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if err := validateHeaderName(c.Header); err != nil {
+		return err
+	}
+	switch {
+	case strings.HasPrefix(c.Algorithm, "HS"):
+		if err := c.Secret.validate(); err != nil {
+			return fmt.Errorf("secret: %w", err)
+		}
+	case c.Algorithm == "":
+		return fmt.Errorf("algorithm is required")
+	default:
+		if c.PrivateKeyFile == "" {
+			return fmt.Errorf("private key file is required for %s", c.Algorithm)
+		}
+		if _, err := os.Stat(c.PrivateKeyFile); err != nil {
+			return fmt.Errorf("private key file: %w", err)
+		}
+	}
+	validAlgos := map[string]bool{"RS256": true, "RS384": true, "RS512": true, "HS256": true, "HS384": true, "HS512": true, "ES256": true, "ES384": true, "ES512": true, "EdDSA": true}
+	if !validAlgos[c.Algorithm] {
+		return fmt.Errorf("invalid algorithm: %s", c.Algorithm)
+	}
+	if c.TTL <= 0 {
+		return fmt.Errorf("ttl must be positive")
+	}
+	return nil
+}
+
+// RetryBudgetConfig limits retries to a fraction of recent requests.
+// Retries are always allowed up to MinRetriesPerSecond.
+type RetryBudgetConfig struct {
+	Ratio               float64 `yaml:"ratio" json:"ratio"` // e.g., 0.2 allows retries for 20% of requests
+	MinRetriesPerSecond int     `yaml:"min_retries_per_second" json:"min_retries_per_second"`
+}
+
+// RequestBufferingConfig bounds the buffering of request bodies for retries.
+// Bodies up to MemoryLimit are kept in memory, larger ones are spilled to a
+// temporary file. Bodies over MaxSize are streamed and not retried.
+type RequestBufferingConfig struct {
+	MemoryLimit int64  `yaml:"memory_limit" json:"memory_limit"` // bytes
+	MaxSize     int64  `yaml:"max_size" json:"max_size"`         // bytes, 0 disables buffering
+	TempDir     string `yaml:"temp_dir" json:"temp_dir"`         // defaults to the system temp directory
+}
+
+// CompressionConfig contains response compression and request decompression configuration
+type CompressionConfig struct {
+	Enabled      bool     `yaml:"enabled" json:"enabled"`
+	Algorithms   []string `yaml:"algorithms" json:"algorithms"`       // preference order: br, zstd, gzip
+	MinSize      int      `yaml:"min_size" json:"min_size"`           // bytes
+	ContentTypes []string `yaml:"content_types" json:"content_types"` // media types, "text/*" style wildcards allowed
+
+	// Request decompression of gzip, br and zstd bodies before input
+	// validation; a MaxDecompressedBodySize of 0 uses the 10 MB default
+	DecompressRequests      bool  `yaml:"decompress_requests" json:"decompress_requests"`
+	MaxDecompressedBodySize int64 `yaml:"max_decompressed_body_size" json:"max_decompressed_body_size"` // bytes
+}
+
+// TestTrafficConfig controls detection of synthetic and test requests.
+// Detected requests are tagged in logs and recorded in separate metrics so
+// they do not skew production signals.
+type TestTrafficConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Header marks test traffic; any value matches unless HeaderValue is set
+	Header      string `yaml:"header" json:"header"`
+	HeaderValue string `yaml:"header_value" json:"header_value"`
+
+	// Claim marks test traffic when the authenticated token carries it with ClaimValue
+	Claim      string `yaml:"claim" json:"claim"`
+	ClaimValue string `yaml:"claim_value" json:"claim_value"`
+}
+
+// SecurityConfig contains security configuration
+type SecurityConfig struct {
+	// TLS Configuration
+	TLSMinVersion       string   `yaml:"tls_min_version" json:"tls_min_version"` // 1.2 or 1.3
+	TLSCipherSuites     []string `yaml:"tls_cipher_suites" json:"tls_cipher_suites"`
+	EnableHTTPSRedirect bool     `yaml:"enable_https_redirect" json:"enable_https_redirect"`
+
+	// HSTS (HTTP Strict Transport Security)
+	EnableHSTS            bool `yaml:"enable_hsts" json:"enable_hsts"`
+	HSTSMaxAge            int  `yaml:"hsts_max_age" json:"hsts_max_age"`
+	HSTSIncludeSubdomains bool `yaml:"hsts_include_subdomains" json:"hsts_include_subdomains"`
+	HSTSPreload           bool `yaml:"hsts_preload" json:"hsts_preload"`
+
+	// Security Headers
+	ContentSecurityPolicy string `yaml:"content_security_policy" json:"content_security_policy"`
+	FrameOptions          string `yaml:"frame_options" json:"frame_options"` // DENY, SAMEORIGIN
+	ContentTypeNosniff    bool   `yaml:"content_type_nosniff" json:"content_type_nosniff"`
+	XSSProtection         bool   `yaml:"xss_protection" json:"xss_protection"`
+	XSSBlockMode          bool   `yaml:"xss_block_mode" json:"xss_block_mode"`
+	ReferrerPolicy        string `yaml:"referrer_policy" json:"referrer_policy"`
+	PermissionsPolicy     string `yaml:"permissions_policy" json:"permissions_policy"`
+
+	// Cookie Security
+	EnforceCookieSecurity bool   `yaml:"enforce_cookie_security" json:"enforce_cookie_security"`
+	CookieSameSite        string `yaml:"cookie_same_site" json:"cookie_same_site"` // Strict, Lax, None
+
+	// Input Validation
+	MaxRequestBodySize int64    `yaml:"max_request_body_size" json:"max_request_body_size"` // bytes
+	MaxURLPathLength   int      `yaml:"max_url_path_length" json:"max_url_path_length"`
+	AllowedMethods     []string `yaml:"allowed_methods" json:"allowed_methods"`
+	BlockedUserAgents  []string `yaml:"blocked_user_agents" json:"blocked_user_agents"`
+
+	// Error Disclosure
+	HideInternalErrors bool `yaml:"hide_internal_errors" json:"hide_internal_errors"`
+	ProductionMode     bool `yaml:"production_mode" json:"production_mode"`
+
+	// Normalization resolves ambiguous requests before routing and
+	// validation, so that backends interpret them as the gateway checked them
+	Normalization RequestNormalizationConfig `yaml:"normalization" json:"normalization"`
+}
+
+// RequestNormalizationConfig configures how requests that parsers may read
+// differently are normalized
+type RequestNormalizationConfig struct {
+	// DuplicateQueryParams handles query parameters given more than once:
+	// allow forwards them unchanged, first-wins and last-wins keep one value
+	// and reject refuses the request
+	DuplicateQueryParams string `yaml:"duplicate_query_params" json:"duplicate_query_params"`
+	// RepeatableQueryParams may be given more than once regardless of the
+	// policy, e.g. list parameters such as ids
+	RepeatableQueryParams []string `yaml:"repeatable_query_params" json:"repeatable_query_params"`
+	// CanonicalizeHeaders rewrites header names to canonical casing, merging
+	// the values of names that differ only in case
+	CanonicalizeHeaders bool `yaml:"canonicalize_headers" json:"canonicalize_headers"`
+	// HeaderUnderscores handles header names containing underscores, which
+	// some backends read as dashes: allow, drop or reject
+	HeaderUnderscores string `yaml:"header_underscores" json:"header_underscores"`
+}
+
+// validate checks the normalization policies
+func (c RequestNormalizationConfig) validate() error {
+	switch c.DuplicateQueryParams {
+	case "allow", "first-wins", "last-wins", "reject":
+	default:
+		return fmt.Errorf("invalid duplicate query params policy: %s (must be 'allow', 'first-wins', 'last-wins' or 'reject')", c.DuplicateQueryParams)
+	}
+	switch c.HeaderUnderscores {
+	case "allow", "drop", "reject":
+	default:
+		return fmt.Errorf("invalid header underscores policy: %s (must be 'allow', 'drop' or 'reject')", c.HeaderUnderscores)
+	}
+	return nil
+}
+
+// ObservabilityConfig contains observability configuration
+type ObservabilityConfig struct {
+	MetricsEnabled bool   `yaml:"metrics_enabled" json:"metrics_enabled"`
+	MetricsPort    int    `yaml:"metrics_port" json:"metrics_port"`
+	MetricsPath    string `yaml:"metrics_path" json:"metrics_path"`
+	// MetricsOpenMetrics serves OpenMetrics, with created timestamps and
+	// trace exemplars, to scrapers that ask for it; protobuf and the classic
+	// text format are negotiated either way
+	MetricsOpenMetrics bool   `yaml:"metrics_openmetrics" json:"metrics_openmetrics"`
+	HealthPath         string `yaml:"health_path" json:"health_path"`
+	ReadinessPath      string `yaml:"readiness_path" json:"readiness_path"`
+	LivenessPath       string `yaml:"liveness_path" json:"liveness_path"`
+	TracingEnabled     bool   `yaml:"tracing_enabled" json:"tracing_enabled"`
+	TracingEndpoint    string `yaml:"tracing_endpoint" json:"tracing_endpoint"`
+
+	// Effective configuration endpoint (secrets redacted)
+	ConfigEndpointEnabled bool   `yaml:"config_endpoint_enabled" json:"config_endpoint_enabled"`
+	ConfigPath            string `yaml:"config_path" json:"config_path"`
+
+	// ExemptPaths are served without authorization, HTTPS redirects and rate
+	// limiting, in addition to the health and metrics paths, e.g. for a load
+	// balancer probe handled by a backend
+	ExemptPaths []string `yaml:"exempt_paths" json:"exempt_paths"`
+
+	// CertExpiry monitors the expiry of the gateway's and backends' TLS
+	// certificates
+	CertExpiry CertExpiryConfig `yaml:"cert_expiry" json:"cert_expiry"`
+
+	// Diagnostics serves an endpoint identifying the gateway instance and
+	// configuration that answered
+	Diagnostics DiagnosticsConfig `yaml:"diagnostics" json:"diagnostics"`
+}
+
+// DiagnosticsConfig controls the self-identification endpoint at Path. It
+// answers with the instance ID, region, configuration hash and time, and,
+// for requests carrying DebugToken in DebugHeader, the middleware that
+// handled the request, so multi-instance and multi-region setups can tell
+// which gateway and configuration served a request.
+type DiagnosticsConfig struct {
+	Enabled bool   `yaml:"enabled" json:"enabled"`
+	Path    string `yaml:"path" json:"path"`
+	// InstanceID identifies this gateway; empty uses the hostname
+	InstanceID string `yaml:"instance_id" json:"instance_id"`
+	Region     string `yaml:"region" json:"region"`
+	// DebugToken unlocks the middleware trace; empty disables it
+	DebugToken  SecretRef `yaml:"debug_token" json:"debug_token"`
+	DebugHeader string    `yaml:"debug_header" json:"debug_header"`
+}
+
+// validate validates diagnostics endpoint settings
+func (c DiagnosticsConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if !strings.HasPrefix(c.Path, "/") {
+		return fmt.Errorf("path %q must start with /", c.Path)
+	}
+	if c.DebugToken == (SecretRef{}) {
+		return nil
+	}
+	if err := c.DebugToken.validate(); err != nil {
+		return fmt.Errorf("debug token: %w", err)
+	}
+	if len(c.DebugToken.Value) > 0 && len(c.DebugToken.Value) < 16 {
+		return fmt.Errorf("debug token must be at least 16 characters")
+	}
+	if err := validateHeaderName(c.DebugHeader); err != nil {
+		return fmt.Errorf("debug header: %w", err)
+	}
+	return nil
+}
+
+// CertExpiryConfig controls monitoring of TLS certificate expiry: the
+// gateway's server certificate, upstream client certificates, and the
+// certificates presented by https backends
+type CertExpiryConfig struct {
+	Enabled  bool          `yaml:"enabled" json:"enabled"`
+	Interval time.Duration `yaml:"interval" json:"interval"`
+	// Threshold marks health degraded when a certificate expires sooner
+	Threshold time.Duration `yaml:"threshold" json:"threshold"`
+	Timeout   time.Duration `yaml:"timeout" json:"timeout"` // per backend handshake
+}
+
+// validate validates certificate expiry monitoring settings
+func (c CertExpiryConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	if c.Threshold <= 0 {
+		return fmt.Errorf("threshold must be positive")
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	return nil
+}
+
+// IsExemptPath reports whether path bypasses authorization, HTTPS redirects
+// and rate limiting: the health, readiness and liveness paths, the metrics
+// path when metrics are enabled, and the extra exempt paths. Paths must match
+// exactly.
+func (c *ObservabilityConfig) IsExemptPath(path string) bool {
+	switch path {
+	case "":
+		return false
+	case c.HealthPath, c.ReadinessPath, c.LivenessPath:
+		return true
+	}
+	if c.MetricsEnabled && path == c.MetricsPath {
+		return true
+	}
+	if c.Diagnostics.Enabled && path == c.Diagnostics.Path {
+		return true
+	}
+	return slices.Contains(c.ExemptPaths, path)
+}
+
+// validate validates observability settings
+func (c ObservabilityConfig) validate() error {
+	for _, path := range c.ExemptPaths {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("exempt path %q must start with /", path)
+		}
+	}
+	if err := c.CertExpiry.validate(); err != nil {
+		return fmt.Errorf("cert expiry: %w", err)
+	}
+	if err := c.Diagnostics.validate(); err != nil {
+		return fmt.Errorf("diagnostics: %w", err)
+	}
+	return nil
+}
+
+// AdminConfig controls the admin API used by gatewayctl. It is served on its
+// own address, so it is never reachable through the public listeners.
+type AdminConfig struct {
+	Enabled bool   `yaml:"enabled" json:"enabled"`
+	Address string `yaml:"address" json:"address"` // host:port, loopback by default
+	Token   string `yaml:"token" json:"token"`     // bearer token required on every request
+}
+
+// validate validates admin API settings. A token is required unless the API
+// only listens on loopback.
+func (c AdminConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(c.Address)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", c.Address, err)
+	}
+	if c.Token == "" {
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return fmt.Errorf("token is required when listening on %s", c.Address)
+		}
+	}
+	return nil
+}
+
+var (
+	globalConfig *Config
+	configMu     sync.RWMutex
+)
+
+// Load loads configuration from file with environment variable overrides
+func Load(configPath string) (*Config, error) {
+	cfg := &Config{}
+
+	// Set defaults
+	cfg.setDefaults()
+
+	// Load from file if provided, otherwise use the built-in configuration
+	if configPath != "" {
+		if err := loadFromFile(configPath, cfg); err != nil {
+			return nil, fmt.Errorf("failed to load config from file: %w", err)
+		}
+	} else if err := yaml.Unmarshal(defaultYAML, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse built-in config: %w", err)
+	}
+
+	// Apply environment variable overrides
+	if err := applyEnvOverrides(cfg); err != nil {
+		return nil, fmt.Errorf("failed to apply environment overrides: %w", err)
+	}
+
+	// Validate configuration
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	// Set as global config
+	configMu.Lock()
+	globalConfig = cfg
+	configMu.Unlock()
+
+	return cfg, nil
+}
+
+// Get returns the global configuration
+func Get() *Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return globalConfig
+}
+
+// Reload reloads configuration from file
+func Reload(configPath string) error {
+	newConfig, err := Load(configPath)
+	if err != nil {
+		return err
+	}
+
+	configMu.Lock()
+	globalConfig = newConfig
+	configMu.Unlock()
+
+	return nil
+}
+
+// setDefaults sets default values for configuration
+func (c *Config) setDefaults() {
+	c.SchemaVersion = CurrentSchemaVersion
+	c.Environment = EnvironmentDev
+
+	// Server defaults
+	c.Server.HTTPPort = 8080
+	c.Server.HTTPSPort = 8443
+	c.Server.TLSEnabled = false
+	c.Server.ReadTimeout = 30 * time.Second
+	c.Server.WriteTimeout = 30 * time.Second
+	c.Server.IdleTimeout = 120 * time.Second
+	c.Server.HandlerTimeout = 30 * time.Second
+	c.Server.MaxHeaderBytes = 1 << 20 // 1 MB
+	c.Server.ShutdownTimeout = 30 * time.Second
+	c.Server.EnableHTTP2 = true
+	c.Server.ForwardedHeader = "x-forwarded-for"
+	c.Server.ProxyProtocol.HeaderTimeout = 5 * time.Second
+	c.Server.Internal.Port = 8444
+
+	// Logging defaults
+	c.Logging.Level = "info"
+	c.Logging.Format = "json"
+	c.Logging.Output = "stdout"
+	c.Logging.SamplingRate = 1.0
+
+	// Authorization defaults
+	c.Authorization.Enabled = true
+	c.Authorization.CookieName = "session_token"
+	c.Authorization.JWTSigningAlgorithm = "RS256"
+	c.Authorization.ClockSkewTolerance = 5 * time.Second
+	c.Authorization.CacheAuthDecisions = true
+	c.Authorization.CacheDecisionTTL = 5 * time.Minute
+	c.Authorization.CacheDecisionMaxEntries = 10000
+	c.Authorization.RevocationListCache = 30 * time.Second
+	c.Authorization.Revocation.RedisKeyPrefix = "revoked:"
+	c.Authorization.Revocation.Channel = "gateway:revocations"
+	c.Authorization.Revocation.Timeout = 100 * time.Millisecond
+	c.Authorization.Revocation.FailureMode = "fail-open"
+	c.Authorization.ReplayProtection.RedisKeyPrefix = "replay:jti:"
+	c.Authorization.ReplayProtection.Timeout = 100 * time.Millisecond
+	c.Authorization.ReplayProtection.MaxEntries = 100000
+	c.Authorization.ReplayProtection.MaxTTL = 24 * time.Hour
+	c.Authorization.ReplayProtection.FailureMode = "fail-closed"
+	c.Authorization.RequestSigning.MaxClockSkew = 5 * time.Minute
+	c.Authorization.RequestSigning.MaxBodySize = 1 << 20 // 1 MB
+	c.Authorization.BasicAuth.Realm = "api-gateway"
+	c.Authorization.EdgeLogin.Scopes = []string{"openid", "profile", "email"}
+	c.Authorization.EdgeLogin.Token = "access_token"
+	c.Authorization.EdgeLogin.LogoutPath = "/_auth/logout"
+	c.Authorization.EdgeLogin.PostLogoutRedirect = "/"
+	c.Authorization.EdgeLogin.CookieName = "gateway_session"
+	c.Authorization.EdgeLogin.CookieSecure = true
+	c.Authorization.EdgeLogin.SessionTTL = 24 * time.Hour
+	c.Authorization.EdgeLogin.RefreshBefore = time.Minute
+	c.Authorization.Bypass.Header = "X-Gateway-Service-Token"
+	c.Authorization.Bypass.Policies = []string{"authenticated"}
+	c.Authorization.EdgeLogin.Timeout = 5 * time.Second
+
+	// Enrichment defaults
+	c.Authorization.Enrichment.Enabled = false
+	c.Authorization.Enrichment.RedisKeyPrefix = "user:attributes:"
+	c.Authorization.Enrichment.Timeout = 2 * time.Second
+	c.Authorization.Enrichment.CacheTTL = 5 * time.Minute
+	c.Authorization.Enrichment.FailureMode = "fail-open"
+	c.Authorization.Introspection.Mode = "opaque"
+	c.Authorization.Introspection.Timeout = 5 * time.Second
+	c.Authorization.Introspection.CacheTTL = time.Minute
+	c.Authorization.ExternalAuthz.Timeout = 2 * time.Second
+	c.Authorization.ExternalAuthz.FailureMode = "fail-closed"
+	c.Authorization.ExternalAuthz.CacheTTL = 10 * time.Second
+
+	// API key defaults
+	c.Authorization.APIKeys.Header = "X-Api-Key"
+	c.Authorization.APIKeys.Store = "config"
+	c.Authorization.APIKeys.RedisKeyPrefix = "apikeys:"
+	c.Authorization.APIKeys.RotationGracePeriod = 24 * time.Hour
+	c.Authorization.DecisionLog.Output = "stdout"
+	c.Authorization.DecisionLog.AllowSampleRate = 1.0
+	c.Authorization.DecisionLog.DenySampleRate = 1.0
+
+	// Rate limit defaults
+	c.RateLimit.Enabled = true
+	c.RateLimit.Backend = "memory"
+	c.RateLimit.FailureMode = "fail-closed"
+	c.RateLimit.RedisDB = 0
+	c.RateLimit.IPv4PrefixLength = 32
+	c.RateLimit.IPv6PrefixLength = 64
+	c.RateLimit.SessionAbuse.ErrorThreshold = 20
+	c.RateLimit.SessionAbuse.Window = 1 * time.Minute
+	c.RateLimit.SessionAbuse.Penalty = 10 * time.Minute
+	c.RateLimit.SessionAbuse.LimitFactor = 0.1
+	c.RateLimit.Quota.Store = "memory"
+	c.RateLimit.Quota.RedisKeyPrefix = "quota:"
+	c.RateLimit.Quota.Period = "monthly"
+	c.RateLimit.Quota.ResetDay = 1
+	c.RateLimit.Quota.Timezone = "UTC"
+	c.RateLimit.Adaptive.Interval = 10 * time.Second
+	c.RateLimit.Adaptive.LatencyThreshold = 2 * time.Second
+	c.RateLimit.Adaptive.ErrorRateThreshold = 0.1
+	c.RateLimit.Adaptive.MinSamples = 20
+	c.RateLimit.Adaptive.DecreaseFactor = 0.5
+	c.RateLimit.Adaptive.IncreaseStep = 0.1
+	c.RateLimit.Adaptive.MinFactor = 0.1
+	c.RateLimit.Degraded.LimitFactor = 0.5
+	c.RateLimit.Degraded.CheckInterval = 5 * time.Second
+
+	// Proxy defaults
+	c.Proxy.ForwardedPrefixHeader = "X-Forwarded-Prefix"
+	c.Proxy.OriginalURLHeader = "X-Original-URL"
+	c.Proxy.ForwardedHeaders = "x-forwarded"
+	c.Proxy.MaxRetries = 3
+	c.Proxy.RetryDelay = 100 * time.Millisecond
+	c.Proxy.MaxIdleConns = 100
+	c.Proxy.MaxIdleConnsPerHost = 10
+	c.Proxy.IdleConnTimeout = 90 * time.Second
+	c.Proxy.DialTimeout = 30 * time.Second
+	c.Proxy.TLSHandshakeTimeout = 10 * time.Second
+	c.Proxy.DefaultTimeout = 30 * time.Second
+	c.Proxy.ExpectContinueTimeout = time.Second
+	c.Proxy.RetryBudget.Ratio = 0.2
+	c.Proxy.RetryBudget.MinRetriesPerSecond = 10
+	c.Proxy.RequestBuffering.MemoryLimit = 1024 * 1024  // 1 MB
+	c.Proxy.RequestBuffering.MaxSize = 10 * 1024 * 1024 // 10 MB
+	c.Proxy.BulkheadRetryAfter = time.Second
+	c.Proxy.BulkheadMaxRetryAfter = 30 * time.Second
+	c.Proxy.BulkheadQueue.Timeout = time.Second
+	c.Proxy.IdentityToken.Header = "Authorization"
+	c.Proxy.IdentityToken.Algorithm = "RS256"
+	c.Proxy.IdentityToken.Issuer = "api-gateway"
+	c.Proxy.IdentityToken.TTL = time.Minute
+	c.Proxy.Metadata.Header = "X-Gateway-Metadata"
+	c.Proxy.Metadata.TTL = 30 * time.Second
+	c.Proxy.CircuitBreaker.FailureThreshold = 5
+	c.Proxy.CircuitBreaker.SuccessThreshold = 2
+	c.Proxy.CircuitBreaker.Timeout = 60 * time.Second
+	c.Proxy.CircuitBreaker.MaxHalfOpenRequests = 3
+	c.Proxy.CircuitBreaker.ProbeInterval = time.Second
+	c.Proxy.CircuitBreaker.ProbeTimeout = 5 * time.Second
+	c.Proxy.CircuitBreaker.Mode = "consecutive"
+	c.Proxy.CircuitBreaker.Window = time.Minute
+	c.Proxy.CircuitBreaker.MinimumCalls = 20
+	c.Proxy.CircuitBreaker.FailureRateThreshold = 50
+	c.Proxy.CircuitBreaker.SlowCallRateThreshold = 100
+	c.Proxy.CircuitBreaker.SlowCallDuration = 10 * time.Second
+	c.Proxy.CircuitBreaker.FailureStatusCodes = []int{502, 503, 504}
+	c.Proxy.CircuitBreakerWebhook.States = []string{"open"}
+	c.Proxy.CircuitBreakerWebhook.Timeout = 5 * time.Second
+	c.Proxy.CircuitBreakerStore.RedisKeyPrefix = "circuitbreaker:"
+	c.Proxy.CircuitBreakerStore.MaxAge = time.Hour
+	c.Proxy.CircuitBreakerGC.IdleTTL = time.Hour
+	c.Proxy.CircuitBreakerGC.MaxBreakers = 10000
+	c.Proxy.CDNPurge.Timeout = 10 * time.Second
+
+	// Compression defaults
+	c.Compression.Enabled = false
+	c.Compression.Algorithms = []string{"br", "zstd", "gzip"}
+	c.Compression.MinSize = 1024
+	c.Compression.ContentTypes = []string{
+		"text/*",
+		"application/json",
+		"application/*+json",
+		"application/javascript",
+		"application/xml",
+		"application/*+xml",
+		"image/svg+xml",
+	}
+	c.Compression.DecompressRequests = false
+	c.Compression.MaxDecompressedBodySize = 10 << 20 // 10 MB
+
+	// Test traffic defaults
+	c.TestTraffic.Enabled = false
+	c.TestTraffic.Header = "X-Test-Traffic"
+	c.TestTraffic.ClaimValue = "true"
+
+	// Observability defaults
+	c.Observability.MetricsEnabled = true
+	c.Observability.MetricsPort = 9090
+	c.Observability.MetricsPath = "/metrics"
+	c.Observability.MetricsOpenMetrics = true
+	c.Observability.CertExpiry.Interval = time.Hour
+	c.Observability.CertExpiry.Threshold = 14 * 24 * time.Hour
+	c.Observability.CertExpiry.Timeout = 5 * time.Second
+	c.Observability.HealthPath = "/_health"
+	c.Observability.ReadinessPath = "/_health/ready"
+	c.Observability.LivenessPath = "/_health/live"
+	c.Observability.ConfigEndpointEnabled = false
+	c.Observability.ConfigPath = "/_config"
+	c.Observability.Diagnostics.Path = "/.well-known/gateway"
+	c.Observability.Diagnostics.DebugHeader = "X-Gateway-Debug-Token"
+	c.Observability.TracingEnabled = false
+
+	// Admin API defaults
+	c.Admin.Enabled = false
+	c.Admin.Address = "127.0.0.1:9901"
+
+	// Security defaults
+	c.Security.TLSMinVersion = "1.2"
+	c.Security.EnableHTTPSRedirect = false
+	c.Security.EnableHSTS = true
+	c.Security.HSTSMaxAge = 31536000 // 1 year
+	c.Security.HSTSIncludeSubdomains = true
+	c.Security.HSTSPreload = false
+	c.Security.ContentSecurityPolicy = "default-src 'self'"
+	c.Security.FrameOptions = "DENY"
+	c.Security.ContentTypeNosniff = true
+	c.Security.XSSProtection = true
+	c.Security.XSSBlockMode = true
+	c.Security.ReferrerPolicy = "strict-origin-when-cross-origin"
+	c.Security.PermissionsPolicy = "geolocation=(), microphone=(), camera=()"
+	c.Security.EnforceCookieSecurity = true
+	c.Security.CookieSameSite = "Strict"
+	c.Security.MaxRequestBodySize = 10 << 20 // 10 MB
+	c.Security.MaxURLPathLength = 2048
+	c.Security.AllowedMethods = []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS", "HEAD"}
+	c.Security.HideInternalErrors = true
+	c.Security.ProductionMode = false
+	c.Security.Normalization.DuplicateQueryParams = "allow"
+	c.Security.Normalization.HeaderUnderscores = "allow"
+}
+
+// Validate validates the configuration
+func (c *Config) Validate() error {
+	if err := validateSchemaVersion(c.SchemaVersion); err != nil {
+		return err
+	}
+
+	// Validate server config
+	if c.Server.HTTPPort <= 0 || c.Server.HTTPPort > 65535 {
+		return fmt.Errorf("invalid HTTP port: %d", c.Server.HTTPPort)
+	}
+	if c.Server.HTTPSPort <= 0 || c.Server.HTTPSPort > 65535 {
+		return fmt.Errorf("invalid HTTPS port: %d", c.Server.HTTPSPort)
+	}
+	if err := c.Server.ProxyProtocol.validate(); err != nil {
+		return fmt.Errorf("proxy protocol: %w", err)
+	}
+	if _, err := ParseNetworks(c.Server.TrustedProxies); err != nil {
+		return fmt.Errorf("trusted proxies: %w", err)
+	}
+	if c.Server.ForwardedHeader != "x-forwarded-for" && c.Server.ForwardedHeader != "forwarded" {
+		return fmt.Errorf("invalid forwarded header: %s (must be 'x-forwarded-for' or 'forwarded')", c.Server.ForwardedHeader)
+	}
+	if c.Server.Internal.Enabled {
+		if err := c.Server.Internal.validate(c.Server); err != nil {
+			return fmt.Errorf("internal listener: %w", err)
+		}
+	}
+	if c.Server.TLSEnabled {
+		if c.Server.TLSCertFile == "" {
+			return fmt.Errorf("TLS enabled but cert file not specified")
+		}
+		if c.Server.TLSKeyFile == "" {
+			return fmt.Errorf("TLS enabled but key file not specified")
+		}
+		if _, err := os.Stat(c.Server.TLSCertFile); os.IsNotExist(err) {
+			return fmt.Errorf("TLS cert file does not exist: %s", c.Server.TLSCertFile)
+		}
+		if _, err := os.Stat(c.Server.TLSKeyFile); os.IsNotExist(err) {
+			return fmt.Errorf("TLS key file does not exist: %s", c.Server.TLSKeyFile)
+		}
+	}
+	if c.Server.ReadTimeout <= 0 {
+		return fmt.Errorf("read timeout must be positive")
+	}
+	if c.Server.WriteTimeout <= 0 {
+		return fmt.Errorf("write timeout must be positive")
+	}
+
+	if err := c.Observability.validate(); err != nil {
+		return fmt.Errorf("observability: %w", err)
+	}
+
+	// Validate logging config
+	validLevels := map[string]bool{"debug": true, "info": true, "warn": true, "error": true, "fatal": true}
+	if !validLevels[strings.ToLower(c.Logging.Level)] {
+		return fmt.Errorf("invalid log level: %s", c.Logging.Level)
+	}
+	if c.Logging.Format != "json" && c.Logging.Format != "text" {
+		return fmt.Errorf("invalid log format: %s (must be 'json' or 'text')", c.Logging.Format)
+	}
+
+	// Validate authorization config
+	if c.Authorization.Enabled {
+		if c.Authorization.CookieName == "" {
+			return fmt.Errorf("authorization enabled but cookie name not specified")
+		}
+		validAlgos := map[string]bool{"RS256": true, "RS384": true, "RS512": true, "HS256": true, "HS384": true, "HS512": true, "ES256": true, "ES384": true, "ES512": true, "EdDSA": true}
+		if !validAlgos[c.Authorization.JWTSigningAlgorithm] {
+			return fmt.Errorf("invalid JWT signing algorithm: %s", c.Authorization.JWTSigningAlgorithm)
+		}
+		// Require either public key file or shared secret, unless every
+		// token is introspected
+		if c.Authorization.JWTPublicKeyFile == "" && c.Authorization.JWTSharedSecret == "" && !c.Authorization.Introspection.IntrospectsAll() {
+			return fmt.Errorf("authorization enabled but neither public key file nor shared secret specified")
+		}
+		if err := validateAudiences(c.Authorization.ExpectedAudiences); err != nil {
+			return err
+		}
+		if c.Authorization.CacheAuthDecisions && c.Authorization.CacheDecisionMaxEntries <= 0 {
+			return fmt.Errorf("cache_decision_max_entries must be positive when decision caching is enabled")
+		}
+		if _, err := time.LoadLocation(c.Authorization.ConditionTimezone); err != nil {
+			return fmt.Errorf("invalid condition timezone: %w", err)
+		}
+		if err := c.Authorization.Enrichment.validate(); err != nil {
+			return fmt.Errorf("enrichment: %w", err)
+		}
+		if err := c.Authorization.Revocation.validate(); err != nil {
+			return fmt.Errorf("revocation: %w", err)
+		}
+		if err := c.Authorization.ReplayProtection.validate(); err != nil {
+			return fmt.Errorf("replay protection: %w", err)
+		}
+		if err := c.Authorization.ClientCert.validate(); err != nil {
+			return fmt.Errorf("client cert: %w", err)
+		}
+		if err := c.Authorization.RequestSigning.validate(); err != nil {
+			return fmt.Errorf("request signing: %w", err)
+		}
+		if err := c.Authorization.BasicAuth.validate(); err != nil {
+			return fmt.Errorf("basic auth: %w", err)
+		}
+		if err := c.Authorization.EdgeLogin.validate(); err != nil {
+			return fmt.Errorf("edge login: %w", err)
+		}
+		if err := c.Authorization.Bypass.validate(); err != nil {
+			return fmt.Errorf("bypass: %w", err)
+		}
+		if err := c.Authorization.TokenSources.validate(); err != nil {
+			return fmt.Errorf("token sources: %w", err)
+		}
+		if c.Authorization.EdgeLogin.Enabled && c.Authorization.EdgeLogin.CookieName == c.Authorization.CookieName {
+			return fmt.Errorf("edge login: cookie_name must differ from authorization.cookie_name")
+		}
+		if c.Authorization.ClientCert.Enabled && !c.Server.TLSEnabled {
+			return fmt.Errorf("client cert authentication requires server.tls_enabled")
+		}
+		if err := c.Authorization.Introspection.validate(); err != nil {
+			return fmt.Errorf("introspection: %w", err)
+		}
+		if err := c.Authorization.ExternalAuthz.validate(); err != nil {
+			return fmt.Errorf("external authz: %w", err)
+		}
+		if err := c.Authorization.APIKeys.validate(); err != nil {
+			return fmt.Errorf("api keys: %w", err)
+		}
+		for _, key := range c.Authorization.APIKeys.Keys {
+			if _, ok := c.RateLimit.Tiers[key.Tier]; key.Tier != "" && !ok {
+				return fmt.Errorf("api keys: key %s: unknown rate limit tier %q", key.ID, key.Tier)
+			}
+		}
+		if err := c.Authorization.DecisionLog.validate(); err != nil {
+			return fmt.Errorf("decision log: %w", err)
+		}
+		if err := c.Authorization.RoleHierarchy.validate(); err != nil {
+			return fmt.Errorf("role hierarchy: %w", err)
+		}
+		for name, provider := range c.Authorization.Providers {
+			if err := provider.validate(); err != nil {
+				return fmt.Errorf("auth provider %s: %w", name, err)
+			}
+		}
+	}
+
+	// Validate rate limit config
+	if c.RateLimit.Enabled {
+		if c.RateLimit.Backend != "memory" && c.RateLimit.Backend != "redis" {
+			return fmt.Errorf("invalid rate limit backend: %s (must be 'memory' or 'redis')", c.RateLimit.Backend)
+		}
+		if c.RateLimit.Backend == "redis" && c.RateLimit.RedisAddr == "" {
+			return fmt.Errorf("rate limit backend is redis but redis address not specified")
+		}
+		if c.RateLimit.FailureMode != "fail-open" && c.RateLimit.FailureMode != "fail-closed" {
+			return fmt.Errorf("invalid failure mode: %s (must be 'fail-open' or 'fail-closed')", c.RateLimit.FailureMode)
+		}
+		if c.RateLimit.IPv4PrefixLength < 1 || c.RateLimit.IPv4PrefixLength > 32 {
+			return fmt.Errorf("invalid rate limit ipv4_prefix_length: %d (must be between 1 and 32)", c.RateLimit.IPv4PrefixLength)
+		}
+		if c.RateLimit.IPv6PrefixLength < 1 || c.RateLimit.IPv6PrefixLength > 128 {
+			return fmt.Errorf("invalid rate limit ipv6_prefix_length: %d (must be between 1 and 128)", c.RateLimit.IPv6PrefixLength)
+		}
+		if err := c.RateLimit.SessionAbuse.validate(); err != nil {
+			return fmt.Errorf("rate limit session abuse: %w", err)
+		}
+		if err := validateLimits(c.RateLimit.GlobalLimits); err != nil {
+			return fmt.Errorf("rate limit global limits: %w", err)
+		}
+		for tier, limits := range c.RateLimit.Tiers {
+			if err := validateLimits(limits); err != nil {
+				return fmt.Errorf("rate limit tier %s: %w", tier, err)
+			}
+		}
+		if err := c.RateLimit.Quota.validate(); err != nil {
+			return fmt.Errorf("rate limit quota: %w", err)
+		}
+		if err := c.RateLimit.Adaptive.validate(); err != nil {
+			return fmt.Errorf("rate limit adaptive: %w", err)
+		}
+		if err := c.RateLimit.Degraded.validate(); err != nil {
+			return fmt.Errorf("rate limit degraded mode: %w", err)
+		}
+	}
+
+	// Validate routes
+	for i, route := range c.Routes {
+		if route.PathPattern == "" {
+			return fmt.Errorf("route %d: path pattern is required", i)
+		}
+		if len(route.Methods) == 0 {
+			return fmt.Errorf("route %d: at least one HTTP method is required", i)
+		}
+		if route.BackendURL == "" {
+			return fmt.Errorf("route %d: backend URL is required", i)
+		}
+		validAuthPolicies := map[string]bool{"public": true, "authenticated": true, "role-based": true, "permission-based": true, "scope-based": true, "expression": true, "external": true, "client-cert": true, "signed": true, "basic": true}
+		if route.AuthPolicy != "" && !validAuthPolicies[route.AuthPolicy] {
+			return fmt.Errorf("route %d: invalid auth policy: %s", i, route.AuthPolicy)
+		}
+		if route.AuthPolicy == "external" && c.Authorization.Enabled && c.Authorization.ExternalAuthz.URL == "" {
+			return fmt.Errorf("route %d: external auth policy requires authorization.external_authz.url", i)
+		}
+		if route.AuthPolicy == "client-cert" && c.Authorization.Enabled && !c.Authorization.ClientCert.Enabled {
+			return fmt.Errorf("route %d: client-cert auth policy requires authorization.client_cert.enabled", i)
+		}
+		if route.AuthPolicy == "signed" && c.Authorization.Enabled && !c.Authorization.RequestSigning.Enabled {
+			return fmt.Errorf("route %d: signed auth policy requires authorization.request_signing.enabled", i)
+		}
+		if route.AuthPolicy == "basic" && c.Authorization.Enabled && !c.Authorization.BasicAuth.Enabled {
+			return fmt.Errorf("route %d: basic auth policy requires authorization.basic_auth.enabled", i)
+		}
+		if route.AuthPolicy == "role-based" && len(route.RequiredRoles) == 0 {
+			return fmt.Errorf("route %d: role-based auth requires at least one role", i)
+		}
+		if route.AuthPolicy == "permission-based" && len(route.RequiredPermissions) == 0 {
+			return fmt.Errorf("route %d: permission-based auth requires at least one permission", i)
+		}
+		if route.AuthPolicy == "scope-based" && len(route.RequiredScopes) == 0 {
+			return fmt.Errorf("route %d: scope-based auth requires at least one scope", i)
+		}
+		if route.AuthPolicy == "expression" && route.AuthExpression == "" {
+			return fmt.Errorf("route %d: expression auth requires an auth expression", i)
+		}
+		if route.AuthExpression != "" {
+			if route.AuthPolicy != "expression" {
+				return fmt.Errorf("route %d: auth expression requires the expression auth policy", i)
+			}
+			if _, err := expr.CompileRequirement(route.AuthExpression); err != nil {
+				return fmt.Errorf("route %d: invalid auth expression: %w", i, err)
+			}
+		}
+		if err := validateAudiences(route.ExpectedAudiences); err != nil {
+			return fmt.Errorf("route %d: %w", i, err)
+		}
+		if _, ok := c.Authorization.Providers[route.AuthProvider]; route.AuthProvider != "" && !ok {
+			return fmt.Errorf("route %d: unknown auth provider %q", i, route.AuthProvider)
+		}
+		if route.MaxDecompressedBodySize < 0 {
+			return fmt.Errorf("route %d: max decompressed body size must not be negative", i)
+		}
+		if err := route.UpstreamTLS.validate(); err != nil {
+			return fmt.Errorf("route %d: %w", i, err)
+		}
+		if err := route.Transport.validate(); err != nil {
+			return fmt.Errorf("route %d: %w", i, err)
+		}
+		if err := route.RequestHeaders.validate(); err != nil {
+			return fmt.Errorf("route %d: request headers: %w", i, err)
+		}
+		if err := route.ResponseHeaders.validate(); err != nil {
+			return fmt.Errorf("route %d: response headers: %w", i, err)
+		}
+		if err := route.CachePolicy.validate(); err != nil {
+			return fmt.Errorf("route %d: cache policy: %w", i, err)
+		}
+		if err := route.RequestTransform.validate(); err != nil {
+			return fmt.Errorf("route %d: request transform: %w", i, err)
+		}
+		if err := route.ResponseTransform.validate(); err != nil {
+			return fmt.Errorf("route %d: response transform: %w", i, err)
+		}
+		if route.UploadMode && (route.RequestTransform.Enabled() || route.ResponseTransform.Enabled()) {
+			return fmt.Errorf("route %d: upload mode cannot be combined with body transforms", i)
+		}
+		if err := route.RequestSchema.validate(); err != nil {
+			return fmt.Errorf("route %d: request schema: %w", i, err)
+		}
+		if route.UploadMode && route.RequestSchema.Enabled() {
+			return fmt.Errorf("route %d: upload mode cannot be combined with a request schema", i)
+		}
+		if err := route.TokenSources.validate(); err != nil {
+			return fmt.Errorf("route %d: token sources: %w", i, err)
+		}
+		if err := validateLimits(route.RateLimits); err != nil {
+			return fmt.Errorf("route %d: rate limits: %w", i, err)
+		}
+		for j, limit := range route.ConcurrencyLimits {
+			if err := limit.validate(); err != nil {
+				return fmt.Errorf("route %d: concurrency limit %d: %w", i, j, err)
+			}
+		}
+		if route.Cost < 0 {
+			return fmt.Errorf("route %d: cost must not be negative", i)
+		}
+		if c.RateLimit.Enabled && route.Cost > 0 {
+			if err := validateCost(route.Cost, route.RateLimits); err != nil {
+				return fmt.Errorf("route %d: rate limits: %w", i, err)
+			}
+			if err := validateCost(route.Cost, c.RateLimit.GlobalLimits); err != nil {
+				return fmt.Errorf("route %d: rate limit global limits: %w", i, err)
+			}
+			for tier, limits := range c.RateLimit.Tiers {
+				if err := validateCost(route.Cost, limits); err != nil {
+					return fmt.Errorf("route %d: rate limit tier %s: %w", i, tier, err)
+				}
+			}
+		}
+		if route.OneTimeToken {
+			switch route.AuthPolicy {
+			case "public", "client-cert", "signed", "basic":
+				return fmt.Errorf("route %d: one_time_token requires a session token auth policy", i)
+			}
+		}
+		if err := route.StepUp.validate(); err != nil {
+			return fmt.Errorf("route %d: step up: %w", i, err)
+		}
+		if route.StepUp.Enabled() {
+			switch route.AuthPolicy {
+			case "public", "client-cert", "signed", "basic":
+				return fmt.Errorf("route %d: step_up requires a session token auth policy", i)
+			}
+		}
+		if route.Timeout < 0 {
+			return fmt.Errorf("route %d: timeout must not be negative", i)
+		}
+		if route.Timeout > 0 && route.Timeouts.Total > 0 && route.Timeout != route.Timeouts.Total {
+			return fmt.Errorf("route %d: timeout and timeouts.total conflict", i)
+		}
+		if route.Timeouts.Connect > 0 && route.Transport.DialTimeout > 0 && route.Timeouts.Connect != route.Transport.DialTimeout {
+			return fmt.Errorf("route %d: timeouts.connect and transport.dial_timeout conflict", i)
+		}
+		if route.Timeouts.ResponseHeader > 0 && route.Transport.ResponseHeaderTimeout > 0 &&
+			route.Timeouts.ResponseHeader != route.Transport.ResponseHeaderTimeout {
+			return fmt.Errorf("route %d: timeouts.response_header and transport.response_header_timeout conflict", i)
+		}
+		if err := route.Timeouts.validate(); err != nil {
+			return fmt.Errorf("route %d: %w", i, err)
+		}
+		if err := route.Retry.validate(); err != nil {
+			return fmt.Errorf("route %d: retry: %w", i, err)
+		}
+		if err := route.CircuitBreaker.validateOverride(c.Proxy.CircuitBreaker); err != nil {
+			return fmt.Errorf("route %d: circuit breaker: %w", i, err)
+		}
+		if err := route.Fallback.validate(); err != nil {
+			return fmt.Errorf("route %d: fallback: %w", i, err)
+		}
+		if route.Ranges.MaxRanges < 0 {
+			return fmt.Errorf("route %d: max ranges must not be negative", i)
+		}
+		if route.UploadTimeout < 0 {
+			return fmt.Errorf("route %d: upload timeout must not be negative", i)
+		}
+		if route.MaxRequestBodySize < 0 {
+			return fmt.Errorf("route %d: max request body size must not be negative", i)
+		}
+		if route.UploadMode && route.DecompressRequest {
+			return fmt.Errorf("route %d: upload mode cannot be combined with request decompression", i)
+		}
+		for _, instance := range route.Instances {
+			u, err := url.ParseRequestURI(instance)
+			if err != nil || u.Host == "" || (u.Path != "" && u.Path != "/") {
+				return fmt.Errorf("route %d: invalid instance %q (must be scheme://host[:port])", i, instance)
+			}
+		}
+		if err := route.OutlierDetection.validate(); err != nil {
+			return fmt.Errorf("route %d: outlier detection: %w", i, err)
+		}
+		if route.SandboxBackendURL != "" {
+			if _, err := url.ParseRequestURI(route.SandboxBackendURL); err != nil {
+				return fmt.Errorf("route %d: invalid sandbox backend URL: %w", i, err)
+			}
+		}
+		if route.ProxyProtocol != "" && route.ProxyProtocol != "v1" && route.ProxyProtocol != "v2" {
+			return fmt.Errorf("route %d: invalid proxy protocol: %s (must be 'v1' or 'v2')", i, route.ProxyProtocol)
+		}
+		if err := route.OwnershipCheck.validate(); err != nil {
+			return fmt.Errorf("route %d: ownership check: %w", i, err)
+		}
+		if route.MaxInFlight < 0 {
+			return fmt.Errorf("route %d: max in-flight requests must not be negative", i)
+		}
+		if route.PriorityClass != "" && !priorityClasses[route.PriorityClass] {
+			return fmt.Errorf("route %d: invalid priority class: %s (must be 'critical', 'normal' or 'low')", i, route.PriorityClass)
+		}
+		if err := route.BackendAuth.validate(); err != nil {
+			return fmt.Errorf("route %d: backend auth: %w", i, err)
+		}
+		if c.Proxy.IdentityToken.Enabled && !route.ForwardClientToken && route.BackendAuth.usesHeader(c.Proxy.IdentityToken.Header) {
+			return fmt.Errorf("route %d: backend auth would overwrite the identity token header %s", i, c.Proxy.IdentityToken.Header)
+		}
+		if err := route.Mirror.validate(); err != nil {
+			return fmt.Errorf("route %d: mirror: %w", i, err)
+		}
+		if err := route.RequestAge.validate(); err != nil {
+			return fmt.Errorf("route %d: request age: %w", i, err)
+		}
+		if err := route.TransportSecurity.validate(); err != nil {
+			return fmt.Errorf("route %d: transport security: %w", i, err)
+		}
+		if version := route.TransportSecurity.MinTLSVersion; version != "" && version < c.Security.TLSMinVersion {
+			return fmt.Errorf("route %d: transport security: min_tls_version %s is weaker than security.tls_min_version %s", i, version, c.Security.TLSMinVersion)
+		}
+		if route.UploadMode && route.Mirror.Enabled() {
+			return fmt.Errorf("route %d: upload mode cannot be combined with mirroring", i)
+		}
+		if len(route.Conditions) > 0 && route.AuthPolicy == "public" {
+			return fmt.Errorf("route %d: conditions require an authenticated auth policy", i)
+		}
+		for _, condition := range route.Conditions {
+			if err := validateCondition(condition); err != nil {
+				return fmt.Errorf("route %d: condition %q: %w", i, condition, err)
+			}
+		}
+	}
+
+	// Validate proxy config
+	switch c.Proxy.ForwardedHeaders {
+	case "x-forwarded", "rfc7239", "both":
+	default:
+		return fmt.Errorf("invalid forwarded headers mode: %s (must be 'x-forwarded', 'rfc7239' or 'both')", c.Proxy.ForwardedHeaders)
+	}
+	if c.Proxy.MaxRetries < 0 {
+		return fmt.Errorf("proxy max retries must not be negative")
+	}
+	if c.Proxy.RetryDelay < 0 {
+		return fmt.Errorf("proxy retry delay must not be negative")
+	}
+	if c.Proxy.MaxIdleConns < 0 || c.Proxy.MaxIdleConnsPerHost < 0 {
+		return fmt.Errorf("proxy idle connection limits must not be negative")
+	}
+	if c.Proxy.IdleConnTimeout < 0 || c.Proxy.DialTimeout < 0 || c.Proxy.TLSHandshakeTimeout < 0 || c.Proxy.ExpectContinueTimeout < 0 {
+		return fmt.Errorf("proxy connection timeouts must not be negative")
+	}
+	if c.Proxy.DefaultTimeout <= 0 {
+		return fmt.Errorf("proxy default timeout must be positive")
+	}
+	if c.Proxy.RetryBudget.Ratio < 0 {
+		return fmt.Errorf("retry budget ratio must not be negative")
+	}
+	if c.Proxy.RetryBudget.MinRetriesPerSecond < 0 {
+		return fmt.Errorf("retry budget min retries per second must not be negative")
+	}
+	if c.Proxy.RequestBuffering.MemoryLimit < 0 || c.Proxy.RequestBuffering.MaxSize < 0 {
+		return fmt.Errorf("request buffering limits must not be negative")
+	}
+	if c.Proxy.RequestBuffering.MemoryLimit > c.Proxy.RequestBuffering.MaxSize {
+		return fmt.Errorf("request buffering memory limit must not exceed max size")
+	}
+	if c.Proxy.MaxInFlightPerBackend < 0 {
+		return fmt.Errorf("max in-flight requests per backend must not be negative")
+	}
+	if c.Proxy.BulkheadRetryAfter < 0 {
+		return fmt.Errorf("bulkhead retry after must not be negative")
+	}
+	if c.Proxy.BulkheadMaxRetryAfter < c.Proxy.BulkheadRetryAfter {
+		return fmt.Errorf("bulkhead max retry after must not be less than bulkhead retry after")
+	}
+	if err := c.Proxy.BulkheadQueue.validate(); err != nil {
+		return fmt.Errorf("bulkhead queue: %w", err)
+	}
+	if err := c.Proxy.IdentityToken.validate(); err != nil {
+		return fmt.Errorf("identity token: %w", err)
+	}
+	if err := c.Proxy.Metadata.validate(); err != nil {
+		return fmt.Errorf("gateway metadata: %w", err)
+	}
+	if err := c.Proxy.CircuitBreaker.validate(); err != nil {
+		return fmt.Errorf("circuit breaker: %w", err)
+	}
+	if err := c.Proxy.CircuitBreakerWebhook.validate(); err != nil {
+		return fmt.Errorf("circuit breaker webhook: %w", err)
+	}
+	if err := c.Proxy.CircuitBreakerStore.validate(); err != nil {
+		return fmt.Errorf("circuit breaker store: %w", err)
+	}
+	if err := c.Proxy.CircuitBreakerGC.validate(); err != nil {
+		return fmt.Errorf("circuit breaker gc: %w", err)
+	}
+	if err := c.Proxy.CDNPurge.validate(); err != nil {
+		return fmt.Errorf("cdn purge: %w", err)
+	}
 
-	// Set defaults
-	cfg.setDefaults()
+	if c.Security.MaxRequestBodySize < 0 {
+		return fmt.Errorf("max request body size must not be negative")
+	}
+	if err := c.Security.Normalization.validate(); err != nil {
+		return fmt.Errorf("request normalization: %w", err)
+	}
 
-	// Load from file if provided
-	if configPath != "" {
-		if err := loadFromFile(configPath, cfg); err != nil {
-			return nil, fmt.Errorf("failed to load config from file: %w", err)
+	// Validate compression config
+	validAlgorithms := map[string]bool{"gzip": true, "br": true, "zstd": true}
+	for _, algorithm := range c.Compression.Algorithms {
+		if !validAlgorithms[algorithm] {
+			return fmt.Errorf("invalid compression algorithm: %s", algorithm)
 		}
 	}
+	if c.Compression.MinSize < 0 {
+		return fmt.Errorf("compression min size must not be negative")
+	}
+	if c.Compression.MaxDecompressedBodySize < 0 {
+		return fmt.Errorf("compression max decompressed body size must not be negative")
+	}
 
-	// Apply environment variable overrides
-	if err := applyEnvOverrides(cfg); err != nil {
-		return nil, fmt.Errorf("failed to apply environment overrides: %w", err)
+	// Validate test traffic config
+	if c.TestTraffic.Enabled {
+		if c.TestTraffic.Header == "" && c.TestTraffic.Claim == "" {
+			return fmt.Errorf("test traffic detection requires a header or a claim")
+		}
+		if c.TestTraffic.Header != "" {
+			if err := validateHeaderName(c.TestTraffic.Header); err != nil {
+				return fmt.Errorf("test traffic: %w", err)
+			}
+		}
 	}
 
-	// Validate configuration
-	if err := cfg.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid configuration: %w", err)
+	// Validate passthrough routes, which share the HTTPS listener
+	if len(c.PassthroughRoutes) > 0 && !c.Server.TLSEnabled {
+		return fmt.Errorf("passthrough routes require server.tls_enabled")
+	}
+	sniRoutes := make(map[string]int)
+	for i, route := range c.PassthroughRoutes {
+		if err := route.validate(); err != nil {
+			return fmt.Errorf("passthrough route %d: %w", i, err)
+		}
+		for _, name := range route.SNI {
+			name = strings.ToLower(name)
+			if other, ok := sniRoutes[name]; ok {
+				return fmt.Errorf("passthrough route %d: sni %s already used by passthrough route %d", i, name, other)
+			}
+			sniRoutes[name] = i
+		}
 	}
 
-	// Set as global config
-	configMu.Lock()
-	globalConfig = cfg
-	configMu.Unlock()
+	// Validate admin API config
+	if err := c.Admin.validate(); err != nil {
+		return fmt.Errorf("admin: %w", err)
+	}
 
-	return cfg, nil
+	// Check environment interlocks last, once every setting is known valid
+	return c.validateEnvironment()
 }
 
-// Get returns the global configuration
-func Get() *Config {
-	configMu.RLock()
-	defer configMu.RUnlock()
-	return globalConfig
+// validate validates enrichment settings. Sources other than http and redis
+// are registered at runtime and checked when the enricher is created.
+func (c EnrichmentConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	switch c.Source {
+	case "":
+		return fmt.Errorf("source is required")
+	case "http":
+		if _, err := url.ParseRequestURI(c.URL); err != nil {
+			return fmt.Errorf("invalid url: %w", err)
+		}
+	case "redis":
+		if c.RedisAddr == "" {
+			return fmt.Errorf("redis source requires redis_addr")
+		}
+	}
+	if c.Timeout < 0 || c.CacheTTL < 0 {
+		return fmt.Errorf("timeout and cache ttl must not be negative")
+	}
+	if c.FailureMode != "fail-open" && c.FailureMode != "fail-closed" {
+		return fmt.Errorf("invalid failure mode: %s (must be 'fail-open' or 'fail-closed')", c.FailureMode)
+	}
+	return nil
 }
 
-// Reload reloads configuration from file
-func Reload(configPath string) error {
-	newConfig, err := Load(configPath)
-	if err != nil {
-		return err
+// validate validates token introspection settings
+func (c IntrospectionConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if u, err := url.ParseRequestURI(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid url: %q", c.URL)
+	}
+	if c.ClientID == "" || c.ClientSecret == "" {
+		return fmt.Errorf("client_id and client_secret are required")
 	}
+	if c.Mode != "opaque" && c.Mode != "always" {
+		return fmt.Errorf("invalid mode: %s (must be 'opaque' or 'always')", c.Mode)
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	if c.CacheTTL < 0 {
+		return fmt.Errorf("cache ttl must not be negative")
+	}
+	return nil
+}
 
-	configMu.Lock()
-	globalConfig = newConfig
-	configMu.Unlock()
+// validate validates API key settings and the keys of the config store
+func (c APIKeyConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if err := validateHeaderName(c.Header); err != nil {
+		return fmt.Errorf("header: %w", err)
+	}
+	switch c.Store {
+	case "":
+		return fmt.Errorf("store is required")
+	case "redis":
+		if c.RedisAddr == "" {
+			return fmt.Errorf("redis store requires redis_addr")
+		}
+	}
+	if c.RotationGracePeriod < 0 {
+		return fmt.Errorf("rotation grace period must not be negative")
+	}
 
+	ids := make(map[string]bool, len(c.Keys))
+	for i, key := range c.Keys {
+		if key.ID == "" {
+			return fmt.Errorf("key %d: id is required", i)
+		}
+		if ids[key.ID] {
+			return fmt.Errorf("key %d: duplicate id %q", i, key.ID)
+		}
+		ids[key.ID] = true
+		if digest, err := hex.DecodeString(key.KeySHA256); err != nil || len(digest) != sha256.Size {
+			return fmt.Errorf("key %s: key_sha256 must be a hex SHA-256 digest", key.ID)
+		}
+	}
 	return nil
 }
 
-// setDefaults sets default values for configuration
-func (c *Config) setDefaults() {
-	// Server defaults
-	c.Server.HTTPPort = 8080
-	c.Server.HTTPSPort = 8443
-	c.Server.TLSEnabled = false
-	c.Server.ReadTimeout = 30 * time.Second
-	c.Server.WriteTimeout = 30 * time.Second
-	c.Server.IdleTimeout = 120 * time.Second
-	c.Server.HandlerTimeout = 30 * time.Second
-	c.Server.MaxHeaderBytes = 1 << 20 // 1 MB
-	c.Server.ShutdownTimeout = 30 * time.Second
-	c.Server.EnableHTTP2 = true
-
-	// Logging defaults
-	c.Logging.Level = "info"
-	c.Logging.Format = "json"
-	c.Logging.Output = "stdout"
-	c.Logging.SamplingRate = 1.0
+// conditionRoots are the attribute namespaces route conditions may reference
+var conditionRoots = map[string]bool{
+	"claims":  true,
+	"user":    true,
+	"attr":    true,
+	"path":    true,
+	"params":  true,
+	"request": true,
+	"header":  true,
+	"query":   true,
+	"time":    true,
+}
 
-	// Authorization defaults
-	c.Authorization.Enabled = true
-	c.Authorization.CookieName = "session_token"
-	c.Authorization.JWTSigningAlgorithm = "RS256"
-	c.Authorization.ClockSkewTolerance = 5 * time.Second
-	c.Authorization.CacheAuthDecisions = true
-	c.Authorization.CacheDecisionTTL = 5 * time.Minute
-	c.Authorization.RevocationListCache = 30 * time.Second
+// validateCondition checks that a route condition compiles and only
+// references known attribute namespaces
+func validateCondition(condition string) error {
+	compiled, err := expr.Compile(condition)
+	if err != nil {
+		return err
+	}
+	for _, ident := range compiled.Identifiers() {
+		root, name, _ := strings.Cut(ident, ".")
+		if !conditionRoots[root] || name == "" {
+			return fmt.Errorf("unknown attribute %q", ident)
+		}
+	}
+	return nil
+}
 
-	// Rate limit defaults
-	c.RateLimit.Enabled = true
-	c.RateLimit.Backend = "memory"
-	c.RateLimit.FailureMode = "fail-closed"
-	c.RateLimit.RedisDB = 0
+// validate rejects empty role names and inheritance cycles
+func (h RoleHierarchyConfig) validate() error {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(h))
 
-	// Observability defaults
-	c.Observability.MetricsEnabled = true
-	c.Observability.MetricsPort = 9090
-	c.Observability.MetricsPath = "/metrics"
-	c.Observability.HealthPath = "/_health"
-	c.Observability.ReadinessPath = "/_health/ready"
-	c.Observability.LivenessPath = "/_health/live"
-	c.Observability.TracingEnabled = false
+	var visit func(role string) error
+	visit = func(role string) error {
+		switch state[role] {
+		case visiting:
+			return fmt.Errorf("cycle involving role %q", role)
+		case done:
+			return nil
+		}
+		state[role] = visiting
+		for _, inherited := range h[role] {
+			if inherited == "" {
+				return fmt.Errorf("role %q inherits an empty role", role)
+			}
+			if err := visit(inherited); err != nil {
+				return err
+			}
+		}
+		state[role] = done
+		return nil
+	}
 
-	// Security defaults
-	c.Security.TLSMinVersion = "1.2"
-	c.Security.EnableHTTPSRedirect = false
-	c.Security.EnableHSTS = true
-	c.Security.HSTSMaxAge = 31536000 // 1 year
-	c.Security.HSTSIncludeSubdomains = true
-	c.Security.HSTSPreload = false
-	c.Security.ContentSecurityPolicy = "default-src 'self'"
-	c.Security.FrameOptions = "DENY"
-	c.Security.ContentTypeNosniff = true
-	c.Security.XSSProtection = true
-	c.Security.XSSBlockMode = true
-	c.Security.ReferrerPolicy = "strict-origin-when-cross-origin"
-	c.Security.PermissionsPolicy = "geolocation=(), microphone=(), camera=()"
-	c.Security.EnforceCookieSecurity = true
-	c.Security.CookieSameSite = "Strict"
-	c.Security.MaxRequestBodySize = 10 << 20 // 10 MB
-	c.Security.MaxURLPathLength = 2048
-	c.Security.AllowedMethods = []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS", "HEAD"}
-	c.Security.HideInternalErrors = true
-	c.Security.ProductionMode = false
+	for role := range h {
+		if role == "" {
+			return fmt.Errorf("role name must not be empty")
+		}
+		if err := visit(role); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// Validate validates the configuration
-func (c *Config) Validate() error {
-	// Validate server config
-	if c.Server.HTTPPort <= 0 || c.Server.HTTPPort > 65535 {
-		return fmt.Errorf("invalid HTTP port: %d", c.Server.HTTPPort)
+// validate validates retry policy settings
+func (c RetryPolicyConfig) validate() error {
+	if c.MaxAttempts < 0 {
+		return fmt.Errorf("max attempts must not be negative")
 	}
-	if c.Server.HTTPSPort <= 0 || c.Server.HTTPSPort > 65535 {
-		return fmt.Errorf("invalid HTTPS port: %d", c.Server.HTTPSPort)
+	if c.PerTryTimeout < 0 || c.Backoff < 0 {
+		return fmt.Errorf("timeouts must not be negative")
 	}
-	if c.Server.TLSEnabled {
-		if c.Server.TLSCertFile == "" {
-			return fmt.Errorf("TLS enabled but cert file not specified")
-		}
-		if c.Server.TLSKeyFile == "" {
-			return fmt.Errorf("TLS enabled but key file not specified")
-		}
-		if _, err := os.Stat(c.Server.TLSCertFile); os.IsNotExist(err) {
-			return fmt.Errorf("TLS cert file does not exist: %s", c.Server.TLSCertFile)
+	for _, code := range c.StatusCodes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("invalid status code: %d", code)
 		}
-		if _, err := os.Stat(c.Server.TLSKeyFile); os.IsNotExist(err) {
-			return fmt.Errorf("TLS key file does not exist: %s", c.Server.TLSKeyFile)
+	}
+	for _, method := range c.Methods {
+		if method == "" || strings.ToUpper(method) != method {
+			return fmt.Errorf("invalid method: %q", method)
 		}
 	}
-	if c.Server.ReadTimeout <= 0 {
-		return fmt.Errorf("read timeout must be positive")
+	return nil
+}
+
+// validate validates upstream TLS settings
+func (c UpstreamTLSConfig) validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("upstream TLS requires both cert file and key file for mTLS")
 	}
-	if c.Server.WriteTimeout <= 0 {
-		return fmt.Errorf("write timeout must be positive")
+	for _, path := range []string{c.CAFile, c.CertFile, c.KeyFile} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return fmt.Errorf("upstream TLS file does not exist: %s", path)
+		}
 	}
+	return nil
+}
 
-	// Validate logging config
-	validLevels := map[string]bool{"debug": true, "info": true, "warn": true, "error": true, "fatal": true}
-	if !validLevels[strings.ToLower(c.Logging.Level)] {
-		return fmt.Errorf("invalid log level: %s", c.Logging.Level)
+// validate validates transport settings
+func (c TransportConfig) validate() error {
+	if c.MaxIdleConnsPerHost < 0 {
+		return fmt.Errorf("transport max idle conns per host must not be negative")
 	}
-	if c.Logging.Format != "json" && c.Logging.Format != "text" {
-		return fmt.Errorf("invalid log format: %s (must be 'json' or 'text')", c.Logging.Format)
+	if c.DialTimeout < 0 || c.TLSHandshakeTimeout < 0 || c.ResponseHeaderTimeout < 0 || c.IdleConnTimeout < 0 || c.ExpectContinueTimeout < 0 {
+		return fmt.Errorf("transport timeouts must not be negative")
+	}
+	switch c.HTTP2 {
+	case "", "auto", "h2c":
+	default:
+		return fmt.Errorf("invalid transport http2 mode: %s (must be auto or h2c)", c.HTTP2)
 	}
+	return nil
+}
 
-	// Validate authorization config
-	if c.Authorization.Enabled {
-		if c.Authorization.CookieName == "" {
-			return fmt.Errorf("authorization enabled but cookie name not specified")
+// validate validates header names and value templates
+func (h HeaderRules) validate() error {
+	for _, name := range h.Remove {
+		if err := validateHeaderName(name); err != nil {
+			return err
 		}
-		validAlgos := map[string]bool{"RS256": true, "RS384": true, "RS512": true, "HS256": true, "HS384": true, "HS512": true, "ES256": true, "ES384": true, "ES512": true}
-		if !validAlgos[c.Authorization.JWTSigningAlgorithm] {
-			return fmt.Errorf("invalid JWT signing algorithm: %s", c.Authorization.JWTSigningAlgorithm)
+	}
+	for _, rules := range []map[string]string{h.Set, h.Add} {
+		for name, value := range rules {
+			if err := validateHeaderName(name); err != nil {
+				return err
+			}
+			if err := validateHeaderTemplate(value); err != nil {
+				return fmt.Errorf("header %s: %w", name, err)
+			}
 		}
-		// Require either public key file or shared secret
-		if c.Authorization.JWTPublicKeyFile == "" && c.Authorization.JWTSharedSecret == "" {
-			return fmt.Errorf("authorization enabled but neither public key file nor shared secret specified")
+	}
+	return nil
+}
+
+// validateAudiences rejects empty expected audiences
+func validateAudiences(audiences []string) error {
+	for _, audience := range audiences {
+		if strings.TrimSpace(audience) == "" {
+			return fmt.Errorf("expected audiences must not be empty")
 		}
 	}
+	return nil
+}
 
-	// Validate rate limit config
-	if c.RateLimit.Enabled {
-		if c.RateLimit.Backend != "memory" && c.RateLimit.Backend != "redis" {
-			return fmt.Errorf("invalid rate limit backend: %s (must be 'memory' or 'redis')", c.RateLimit.Backend)
+// validate validates cache policy settings
+func (c CachePolicyConfig) validate() error {
+	if c.Expires < 0 {
+		return fmt.Errorf("expires must not be negative")
+	}
+	if strings.ContainsAny(c.CacheControl+c.SurrogateControl, "\r\n") {
+		return fmt.Errorf("header values must not contain newlines")
+	}
+	for _, name := range c.Vary {
+		if err := validateHeaderName(name); err != nil {
+			return fmt.Errorf("vary: %w", err)
 		}
-		if c.RateLimit.Backend == "redis" && c.RateLimit.RedisAddr == "" {
-			return fmt.Errorf("rate limit backend is redis but redis address not specified")
+	}
+	if c.Store.DefaultTTL < 0 || c.Store.MaxEntries < 0 || c.Store.MaxBodySize < 0 {
+		return fmt.Errorf("store settings must not be negative")
+	}
+	for _, name := range c.Store.AllowHeaders {
+		if err := validateHeaderName(name); err != nil {
+			return fmt.Errorf("store: allow headers: %w", err)
 		}
-		if c.RateLimit.FailureMode != "fail-open" && c.RateLimit.FailureMode != "fail-closed" {
-			return fmt.Errorf("invalid failure mode: %s (must be 'fail-open' or 'fail-closed')", c.RateLimit.FailureMode)
+	}
+	if c.Store.Enabled && !c.Store.AllowCredentialVary {
+		for _, name := range c.Vary {
+			switch http.CanonicalHeaderKey(name) {
+			case "Authorization", "Cookie":
+				return fmt.Errorf("store: vary on %s requires allow_credential_vary", name)
+			}
 		}
 	}
+	return nil
+}
 
-	// Validate routes
-	for i, route := range c.Routes {
-		if route.PathPattern == "" {
-			return fmt.Errorf("route %d: path pattern is required", i)
+// validate validates body transform paths and templates
+func (c BodyTransformConfig) validate() error {
+	if c.MaxBodySize < 0 {
+		return fmt.Errorf("max body size must not be negative")
+	}
+
+	paths := append([]string{}, c.Remove...)
+	for from, to := range c.Rename {
+		paths = append(paths, from, to)
+	}
+	for path, value := range c.Set {
+		paths = append(paths, path)
+		if err := validateHeaderTemplate(value); err != nil {
+			return fmt.Errorf("set %s: %w", path, err)
 		}
-		if len(route.Methods) == 0 {
-			return fmt.Errorf("route %d: at least one HTTP method is required", i)
+	}
+	if c.Unwrap != "" {
+		paths = append(paths, c.Unwrap)
+	}
+	if c.Wrap != "" {
+		paths = append(paths, c.Wrap)
+	}
+
+	for _, path := range paths {
+		for _, segment := range strings.Split(strings.TrimPrefix(path, "$."), ".") {
+			if segment == "" {
+				return fmt.Errorf("invalid path: %q", path)
+			}
 		}
-		if route.BackendURL == "" {
-			return fmt.Errorf("route %d: backend URL is required", i)
+	}
+	return nil
+}
+
+// validateHeaderName checks that name is a valid HTTP header field name
+func validateHeaderName(name string) error {
+	if name == "" {
+		return fmt.Errorf("header name must not be empty")
+	}
+	if strings.ContainsAny(name, " \t\r\n:") {
+		return fmt.Errorf("invalid header name: %q", name)
+	}
+	return nil
+}
+
+// validateHeaderTemplate checks that all ${...} references use a known namespace
+func validateHeaderTemplate(value string) error {
+	for {
+		start := strings.Index(value, "${")
+		if start < 0 {
+			return nil
 		}
-		validAuthPolicies := map[string]bool{"public": true, "authenticated": true, "role-based": true, "permission-based": true}
-		if route.AuthPolicy != "" && !validAuthPolicies[route.AuthPolicy] {
-			return fmt.Errorf("route %d: invalid auth policy: %s", i, route.AuthPolicy)
+		end := strings.Index(value[start:], "}")
+		if end < 0 {
+			return fmt.Errorf("unterminated template reference in %q", value)
 		}
-		if route.AuthPolicy == "role-based" && len(route.RequiredRoles) == 0 {
-			return fmt.Errorf("route %d: role-based auth requires at least one role", i)
+		ref := value[start+2 : start+end]
+		if !strings.HasPrefix(ref, "param.") && !strings.HasPrefix(ref, "claim.") && !strings.HasPrefix(ref, "attr.") {
+			return fmt.Errorf("unknown template reference ${%s}: must start with param., claim. or attr.", ref)
 		}
+		value = value[start+end+1:]
 	}
-
-	return nil
 }
 
 // loadFromFile loads configuration from a file (YAML or JSON)
@@ -392,6 +3826,19 @@ func loadFromFile(path string, cfg *Config) error {
 func applyEnvOverrides(cfg *Config) error {
 	prefix := "GATEWAY_"
 
+	// Environment overrides
+	if val := os.Getenv(prefix + "ENVIRONMENT"); val != "" {
+		cfg.Environment = val
+	}
+	if val := os.Getenv(prefix + "ACKNOWLEDGE_UNSAFE"); val != "" {
+		cfg.AcknowledgeUnsafe = nil
+		for _, name := range strings.Split(val, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				cfg.AcknowledgeUnsafe = append(cfg.AcknowledgeUnsafe, name)
+			}
+		}
+	}
+
 	// Server overrides
 	if val := os.Getenv(prefix + "HTTP_PORT"); val != "" {
 		port, err := strconv.Atoi(val)
@@ -452,6 +3899,9 @@ func applyEnvOverrides(cfg *Config) error {
 	if val := os.Getenv(prefix + "JWT_SHARED_SECRET"); val != "" {
 		cfg.Authorization.JWTSharedSecret = val
 	}
+	if val := os.Getenv(prefix + "INTROSPECTION_CLIENT_SECRET"); val != "" {
+		cfg.Authorization.Introspection.ClientSecret = val
+	}
 
 	// Rate limit overrides
 	if val := os.Getenv(prefix + "RATELIMIT_ENABLED"); val != "" {
@@ -471,5 +3921,41 @@ func applyEnvOverrides(cfg *Config) error {
 		cfg.RateLimit.RedisPassword = val
 	}
 
+	// Proxy overrides
+	for name, target := range map[string]*int{
+		"PROXY_MAX_RETRIES":             &cfg.Proxy.MaxRetries,
+		"PROXY_MAX_IDLE_CONNS":          &cfg.Proxy.MaxIdleConns,
+		"PROXY_MAX_IDLE_CONNS_PER_HOST": &cfg.Proxy.MaxIdleConnsPerHost,
+	} {
+		if val := os.Getenv(prefix + name); val != "" {
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*target = n
+		}
+	}
+	for name, target := range map[string]*time.Duration{
+		"PROXY_RETRY_DELAY":             &cfg.Proxy.RetryDelay,
+		"PROXY_IDLE_CONN_TIMEOUT":       &cfg.Proxy.IdleConnTimeout,
+		"PROXY_DIAL_TIMEOUT":            &cfg.Proxy.DialTimeout,
+		"PROXY_TLS_HANDSHAKE_TIMEOUT":   &cfg.Proxy.TLSHandshakeTimeout,
+		"PROXY_DEFAULT_TIMEOUT":         &cfg.Proxy.DefaultTimeout,
+		"PROXY_EXPECT_CONTINUE_TIMEOUT": &cfg.Proxy.ExpectContinueTimeout,
+	} {
+		if val := os.Getenv(prefix + name); val != "" {
+			d, err := time.ParseDuration(val)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*target = d
+		}
+	}
+
+	// Admin API overrides
+	if val := os.Getenv(prefix + "ADMIN_TOKEN"); val != "" {
+		cfg.Admin.Token = val
+	}
+
 	return nil
 }
@@ -1,10 +1,19 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
+	"math"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
 )
 
 func TestLoadConfigFromYAML(t *testing.T) {
@@ -51,6 +60,47 @@ rate_limit:
 	}
 }
 
+func TestLoadBuiltInConfig(t *testing.T) {
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Failed to load built-in config: %v", err)
+	}
+
+	if cfg.SchemaVersion != CurrentSchemaVersion {
+		t.Errorf("Expected schema version %d, got %d", CurrentSchemaVersion, cfg.SchemaVersion)
+	}
+	if cfg.Authorization.Enabled {
+		t.Error("Expected authorization to be disabled without a signing key")
+	}
+	if !cfg.Admin.Enabled || cfg.Admin.Address != "127.0.0.1:9901" {
+		t.Errorf("Expected the admin API on loopback, got %+v", cfg.Admin)
+	}
+	// Settings the built-in file leaves out keep their defaults
+	if cfg.Authorization.CookieName != "session_token" {
+		t.Errorf("Expected default cookie name session_token, got %s", cfg.Authorization.CookieName)
+	}
+}
+
+func TestSchemaVersionValidation(t *testing.T) {
+	tests := []struct {
+		version     int
+		expectError bool
+	}{
+		{0, false},
+		{CurrentSchemaVersion, false},
+		{CurrentSchemaVersion + 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.version), func(t *testing.T) {
+			err := validateSchemaVersion(tt.version)
+			if (err != nil) != tt.expectError {
+				t.Errorf("validateSchemaVersion(%d) error = %v, expectError %v", tt.version, err, tt.expectError)
+			}
+		})
+	}
+}
+
 func TestConfigDefaults(t *testing.T) {
 	cfg := &Config{}
 	cfg.setDefaults()
@@ -73,6 +123,9 @@ func TestConfigDefaults(t *testing.T) {
 	if cfg.RateLimit.Backend != "memory" {
 		t.Errorf("Expected default rate limit backend memory, got %s", cfg.RateLimit.Backend)
 	}
+	if cfg.RateLimit.IPv4PrefixLength != 32 || cfg.RateLimit.IPv6PrefixLength != 64 {
+		t.Errorf("Expected default rate limit prefixes /32 and /64, got /%d and /%d", cfg.RateLimit.IPv4PrefixLength, cfg.RateLimit.IPv6PrefixLength)
+	}
 }
 
 func TestEnvOverrides(t *testing.T) {
@@ -104,6 +157,51 @@ func TestEnvOverrides(t *testing.T) {
 	}
 }
 
+func TestEnvironmentEnvOverrides(t *testing.T) {
+	t.Setenv("GATEWAY_ENVIRONMENT", "prod")
+	t.Setenv("GATEWAY_ACKNOWLEDGE_UNSAFE", "plaintext_listener, rate_limit_fail_open")
+
+	cfg := &Config{}
+	cfg.setDefaults()
+	if err := applyEnvOverrides(cfg); err != nil {
+		t.Fatalf("Failed to apply env overrides: %v", err)
+	}
+
+	if cfg.Environment != EnvironmentProd {
+		t.Errorf("Expected environment prod from env, got %s", cfg.Environment)
+	}
+	if len(cfg.AcknowledgeUnsafe) != 2 || cfg.AcknowledgeUnsafe[1] != InterlockRateLimitFailOpen {
+		t.Errorf("Expected two acknowledged interlocks from env, got %v", cfg.AcknowledgeUnsafe)
+	}
+}
+
+func TestProxyEnvOverrides(t *testing.T) {
+	t.Setenv("GATEWAY_PROXY_MAX_RETRIES", "1")
+	t.Setenv("GATEWAY_PROXY_RETRY_DELAY", "250ms")
+	t.Setenv("GATEWAY_PROXY_MAX_IDLE_CONNS_PER_HOST", "32")
+
+	cfg := &Config{}
+	cfg.setDefaults()
+	if err := applyEnvOverrides(cfg); err != nil {
+		t.Fatalf("Failed to apply env overrides: %v", err)
+	}
+
+	if cfg.Proxy.MaxRetries != 1 {
+		t.Errorf("Expected max retries 1 from env, got %d", cfg.Proxy.MaxRetries)
+	}
+	if cfg.Proxy.RetryDelay != 250*time.Millisecond {
+		t.Errorf("Expected retry delay 250ms from env, got %s", cfg.Proxy.RetryDelay)
+	}
+	if cfg.Proxy.MaxIdleConnsPerHost != 32 {
+		t.Errorf("Expected max idle conns per host 32 from env, got %d", cfg.Proxy.MaxIdleConnsPerHost)
+	}
+
+	t.Setenv("GATEWAY_PROXY_DIAL_TIMEOUT", "soon")
+	if err := applyEnvOverrides(cfg); err == nil {
+		t.Error("Expected error for invalid duration")
+	}
+}
+
 func TestValidation(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -126,6 +224,112 @@ func TestValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "forwarded header",
+			setup: func(c *Config) {
+				c.setDefaults()
+				c.Authorization.JWTSharedSecret = "test-secret"
+				c.Server.ForwardedHeader = "forwarded"
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown forwarded header",
+			setup: func(c *Config) {
+				c.setDefaults()
+				c.Authorization.JWTSharedSecret = "test-secret"
+				c.Server.ForwardedHeader = "x-real-ip"
+			},
+			wantErr: true,
+		},
+		{
+			name: "fastly cdn purge",
+			setup: func(c *Config) {
+				c.setDefaults()
+				c.Authorization.JWTSharedSecret = "test-secret"
+				c.Proxy.CDNPurge.Provider = "fastly"
+				c.Proxy.CDNPurge.ServiceID = "svc"
+				c.Proxy.CDNPurge.Token = SecretRef{Value: "token"}
+			},
+			wantErr: false,
+		},
+		{
+			name: "fastly cdn purge without token",
+			setup: func(c *Config) {
+				c.setDefaults()
+				c.Authorization.JWTSharedSecret = "test-secret"
+				c.Proxy.CDNPurge.Provider = "fastly"
+				c.Proxy.CDNPurge.ServiceID = "svc"
+			},
+			wantErr: true,
+		},
+		{
+			name: "cloudfront cdn purge without distribution",
+			setup: func(c *Config) {
+				c.setDefaults()
+				c.Authorization.JWTSharedSecret = "test-secret"
+				c.Proxy.CDNPurge.Provider = "cloudfront"
+				c.Proxy.CDNPurge.AccessKeyID = "AKID"
+				c.Proxy.CDNPurge.SecretAccessKey = SecretRef{Value: "secret"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown cdn purge provider",
+			setup: func(c *Config) {
+				c.setDefaults()
+				c.Authorization.JWTSharedSecret = "test-secret"
+				c.Proxy.CDNPurge.Provider = "akamai"
+			},
+			wantErr: true,
+		},
+		{
+			name: "bulkhead queue without timeout",
+			setup: func(c *Config) {
+				c.setDefaults()
+				c.Authorization.JWTSharedSecret = "test-secret"
+				c.Proxy.BulkheadQueue = BulkheadQueueConfig{Size: 10}
+			},
+			wantErr: true,
+		},
+		{
+			name: "bulkhead max retry after below retry after",
+			setup: func(c *Config) {
+				c.setDefaults()
+				c.Authorization.JWTSharedSecret = "test-secret"
+				c.Proxy.BulkheadRetryAfter = time.Minute
+			},
+			wantErr: true,
+		},
+		{
+			name: "decision cache without size",
+			setup: func(c *Config) {
+				c.setDefaults()
+				c.Authorization.JWTSharedSecret = "test-secret"
+				c.Authorization.CacheDecisionMaxEntries = 0
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid duplicate query params policy",
+			setup: func(c *Config) {
+				c.setDefaults()
+				c.Authorization.JWTSharedSecret = "test-secret"
+				c.Security.Normalization.DuplicateQueryParams = "merge"
+			},
+			wantErr: true,
+		},
+		{
+			name: "route tls version weaker than listener",
+			setup: func(c *Config) {
+				c.setDefaults()
+				c.Authorization.JWTSharedSecret = "test-secret"
+				c.Security.TLSMinVersion = "1.3"
+				c.Routes = []RouteConfig{{PathPattern: "/api/payments/**", Methods: []string{"POST"}, BackendURL: "http://payments:8080",
+					TransportSecurity: TransportSecurityConfig{MinTLSVersion: "1.2"}}}
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid log level",
 			setup: func(c *Config) {
@@ -152,6 +356,79 @@ func TestValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "introspection of every token without JWT key",
+			setup: func(c *Config) {
+				c.setDefaults()
+				c.Authorization.Introspection = IntrospectionConfig{Enabled: true, URL: "https://auth.example.com/introspect", ClientID: "gateway", ClientSecret: "secret", Mode: "always", Timeout: time.Second}
+			},
+			wantErr: false,
+		},
+		{
+			name: "relative exempt path",
+			setup: func(c *Config) {
+				c.setDefaults()
+				c.Authorization.JWTSharedSecret = "test-secret"
+				c.Observability.ExemptPaths = []string{"healthz"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "rate limit ipv6 prefix out of range",
+			setup: func(c *Config) {
+				c.setDefaults()
+				c.Authorization.JWTSharedSecret = "test-secret"
+				c.RateLimit.IPv6PrefixLength = 129
+			},
+			wantErr: true,
+		},
+		{
+			name: "test traffic without header or claim",
+			setup: func(c *Config) {
+				c.setDefaults()
+				c.TestTraffic.Enabled = true
+				c.TestTraffic.Header = ""
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty expected audience",
+			setup: func(c *Config) {
+				c.setDefaults()
+				c.Authorization.JWTSharedSecret = "test-secret"
+				c.Authorization.ExpectedAudiences = []string{"api", " "}
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative proxy max retries",
+			setup: func(c *Config) {
+				c.setDefaults()
+				c.Proxy.MaxRetries = -1
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero proxy default timeout",
+			setup: func(c *Config) {
+				c.setDefaults()
+				c.Proxy.DefaultTimeout = 0
+			},
+			wantErr: true,
+		},
+		{
+			name: "role hierarchy cycle",
+			setup: func(c *Config) {
+				c.setDefaults()
+				c.Authorization.JWTSharedSecret = "test-secret"
+				c.Authorization.RoleHierarchy = RoleHierarchyConfig{
+					"admin":     {"moderator"},
+					"moderator": {"user"},
+					"user":      {"admin"},
+				}
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -166,6 +443,62 @@ func TestValidation(t *testing.T) {
 	}
 }
 
+func TestEnvironmentInterlocks(t *testing.T) {
+	prod := func() *Config {
+		cfg := &Config{}
+		cfg.setDefaults()
+		cfg.Environment = EnvironmentProd
+		cfg.Server.TLSEnabled = true
+		cfg.Server.TLSCertFile = os.Args[0]
+		cfg.Server.TLSKeyFile = os.Args[0]
+		cfg.Authorization.JWTSigningAlgorithm = "HS256"
+		cfg.Authorization.JWTSharedSecret = strings.Repeat("s", 32)
+		return cfg
+	}
+
+	tests := []struct {
+		name        string
+		modify      func(*Config)
+		expectError bool
+	}{
+		{"safe prod", func(c *Config) {}, false},
+		{"invalid environment", func(c *Config) { c.Environment = "production" }, true},
+		{"unknown acknowledgment", func(c *Config) { c.AcknowledgeUnsafe = []string{"everything"} }, true},
+		{"internal errors exposed", func(c *Config) { c.Security.HideInternalErrors = false }, true},
+		{"short hs256 secret", func(c *Config) { c.Authorization.JWTSharedSecret = "secret" }, true},
+		{"short hs512 secret", func(c *Config) { c.Authorization.JWTSigningAlgorithm = "HS512" }, true},
+		{"tls disabled", func(c *Config) { c.Server.TLSEnabled = false }, true},
+		{"rate limit fail-open", func(c *Config) { c.RateLimit.FailureMode = "fail-open" }, true},
+		{"acknowledged", func(c *Config) {
+			c.Server.TLSEnabled = false
+			c.AcknowledgeUnsafe = []string{InterlockPlaintextListener}
+		}, false},
+		{"unsafe outside prod", func(c *Config) {
+			c.Environment = EnvironmentStaging
+			c.Server.TLSEnabled = false
+			c.Security.HideInternalErrors = false
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := prod()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("Validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+
+	cfg := prod()
+	cfg.Server.TLSEnabled = false
+	cfg.AcknowledgeUnsafe = []string{InterlockPlaintextListener, InterlockRateLimitFailOpen}
+	if got := cfg.UnsafeAcknowledged(); len(got) != 1 || got[0] != InterlockPlaintextListener {
+		t.Errorf("Expected only the tripped interlock to be reported, got: %v", got)
+	}
+}
+
 func TestRouteValidation(t *testing.T) {
 	cfg := &Config{}
 	cfg.setDefaults()
@@ -211,4 +544,1504 @@ func TestRouteValidation(t *testing.T) {
 	if err := cfg.Validate(); err != nil {
 		t.Errorf("Expected no validation error for valid route, got: %v", err)
 	}
+
+	// Add invalid route (conflicting total timeouts)
+	cfg.Routes[0].Timeouts.Total = 10 * time.Second
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected validation error for conflicting timeouts")
+	}
+
+	// Add invalid route (response header timeout exceeds total)
+	cfg.Routes[0].Timeout = 0
+	cfg.Routes[0].Timeouts.ResponseHeader = 20 * time.Second
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected validation error for response header timeout exceeding total")
+	}
+
+	// Add invalid route (external policy without an authorization service)
+	cfg.Routes[0].Timeouts.ResponseHeader = 0
+	cfg.Routes[0].AuthPolicy = "external"
+	cfg.Authorization.Enabled = true
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected validation error for external policy without external_authz url")
+	}
+	cfg.Authorization.ExternalAuthz.URL = "http://opa:8181/v1/data/gateway/allow"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no validation error for external policy, got: %v", err)
+	}
+
+	// Add invalid routes (policies without requirements or bad expressions)
+	cfg.Routes[0].AuthPolicy = "scope-based"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected validation error for scope-based policy without scopes")
+	}
+	cfg.Routes[0].RequiredScopes = []string{"read:orders"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no validation error for scope-based policy, got: %v", err)
+	}
+	cfg.Routes[0].AuthExpression = "scope:read:orders AND role:manager"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected validation error for auth expression without expression policy")
+	}
+	cfg.Routes[0].AuthPolicy = "expression"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no validation error for expression policy, got: %v", err)
+	}
+	cfg.Routes[0].AuthExpression = "scope:read:orders AND"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected validation error for invalid auth expression")
+	}
+	cfg.Routes[0].AuthPolicy = "permission-based"
+	cfg.Routes[0].AuthExpression = ""
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected validation error for permission-based policy without permissions")
+	}
+	cfg.Routes[0].AuthPolicy = "external"
+
+	// Add invalid route (unknown auth provider)
+	cfg.Routes[0].AuthProvider = "tenant-a"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected validation error for unknown auth provider")
+	}
+	cfg.Authorization.Providers = map[string]AuthProviderConfig{
+		"tenant-a": {JWTSigningAlgorithm: "HS256", JWTSharedSecret: "tenant-secret", ExpectedIssuer: "https://a.idp.example.com"},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no validation error for known auth provider, got: %v", err)
+	}
+
+	// Add invalid route (unknown priority class)
+	cfg.Routes[0].PriorityClass = "urgent"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected validation error for unknown priority class")
+	}
+	cfg.Routes[0].PriorityClass = "critical"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no validation error for critical priority class, got: %v", err)
+	}
+}
+
+func TestAuthProviderValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		provider    AuthProviderConfig
+		expectError bool
+	}{
+		{"hmac", AuthProviderConfig{JWTSigningAlgorithm: "HS256", JWTSharedSecret: "secret", ExpectedIssuer: "https://a.idp.example.com"}, false},
+		{"jwks", AuthProviderConfig{JWTSigningAlgorithm: "RS256", JWTPublicKeyFile: "/etc/gateway/a.jwks", ExpectedIssuer: "https://a.idp.example.com", ExpectedAudiences: []string{"api"}}, false},
+		{"invalid algorithm", AuthProviderConfig{JWTSigningAlgorithm: "none", ExpectedIssuer: "https://a.idp.example.com"}, true},
+		{"hmac without secret", AuthProviderConfig{JWTSigningAlgorithm: "HS256", ExpectedIssuer: "https://a.idp.example.com"}, true},
+		{"rsa without key", AuthProviderConfig{JWTSigningAlgorithm: "RS256", JWTSharedSecret: "secret", ExpectedIssuer: "https://a.idp.example.com"}, true},
+		{"missing issuer", AuthProviderConfig{JWTSigningAlgorithm: "HS256", JWTSharedSecret: "secret"}, true},
+		{"empty audience", AuthProviderConfig{JWTSigningAlgorithm: "HS256", JWTSharedSecret: "secret", ExpectedIssuer: "https://a.idp.example.com", ExpectedAudiences: []string{""}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.provider.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestPassthroughRouteValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		routes      []PassthroughRouteConfig
+		expectError bool
+	}{
+		{"valid", []PassthroughRouteConfig{{SNI: []string{"db.example.com", "*.mq.example.com"}, Backend: "db.internal:5432"}}, false},
+		{"missing sni", []PassthroughRouteConfig{{Backend: "db.internal:5432"}}, true},
+		{"invalid wildcard", []PassthroughRouteConfig{{SNI: []string{"db.*.example.com"}, Backend: "db.internal:5432"}}, true},
+		{"backend without port", []PassthroughRouteConfig{{SNI: []string{"db.example.com"}, Backend: "db.internal"}}, true},
+		{"backend url", []PassthroughRouteConfig{{SNI: []string{"db.example.com"}, Backend: "https://db.internal"}}, true},
+		{"duplicate sni", []PassthroughRouteConfig{
+			{SNI: []string{"db.example.com"}, Backend: "db1.internal:5432"},
+			{SNI: []string{"DB.example.com"}, Backend: "db2.internal:5432"},
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.setDefaults()
+			cfg.Authorization.JWTSharedSecret = "test-secret"
+			cfg.Server.TLSEnabled = true
+			cfg.Server.TLSCertFile = os.Args[0]
+			cfg.Server.TLSKeyFile = os.Args[0]
+			cfg.PassthroughRoutes = tt.routes
+			err := cfg.Validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("Validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+
+	cfg := &Config{}
+	cfg.setDefaults()
+	cfg.Authorization.JWTSharedSecret = "test-secret"
+	cfg.PassthroughRoutes = []PassthroughRouteConfig{{SNI: []string{"db.example.com"}, Backend: "db.internal:5432"}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected validation error for passthrough routes without TLS")
+	}
+}
+
+func TestRouteConditionValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		authPolicy  string
+		condition   string
+		expectError bool
+	}{
+		{"valid condition", "authenticated", "claims.tenant == path.tenantId", false},
+		{"syntax error", "authenticated", "claims.tenant ==", true},
+		{"unknown attribute namespace", "authenticated", "session.tenant == 'acme'", true},
+		{"public route", "public", "claims.tenant == path.tenantId", true},
+		{"request attributes", "authenticated", "claims.tenant_id == params.tenantId && request.client_ip in_cidr ['10.0.0.0/8']", false},
+		{"time of day", "authenticated", "time.hour >= 9 && time.weekday != 'sunday'", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.setDefaults()
+			cfg.Authorization.JWTSharedSecret = "test-secret"
+			cfg.Routes = []RouteConfig{
+				{
+					PathPattern: "/tenants/{tenantId}/orders",
+					Methods:     []string{"GET"},
+					BackendURL:  "http://localhost:3000",
+					AuthPolicy:  tt.authPolicy,
+					Conditions:  []string{tt.condition},
+				},
+			}
+
+			err := cfg.Validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("Validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestConditionTimezoneValidation(t *testing.T) {
+	cfg := &Config{}
+	cfg.setDefaults()
+	cfg.Authorization.Enabled = true
+	cfg.Authorization.JWTSharedSecret = "test-secret"
+
+	cfg.Authorization.ConditionTimezone = "Europe/Berlin"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected no validation error for a valid time zone, got: %v", err)
+	}
+	cfg.Authorization.ConditionTimezone = "Mars/Olympus"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected validation error for an unknown time zone")
+	}
+}
+
+func TestOwnershipCheckValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		check       OwnershipCheckConfig
+		expectError bool
+	}{
+		{"disabled", OwnershipCheckConfig{}, false},
+		{"valid", OwnershipCheckConfig{URL: "http://orders/internal/orders/${param.id}/owner", CacheTTL: time.Minute}, false},
+		{"relative url", OwnershipCheckConfig{URL: "/orders/${param.id}/owner"}, true},
+		{"invalid template", OwnershipCheckConfig{URL: "http://orders/${session.id}"}, true},
+		{"invalid method", OwnershipCheckConfig{URL: "http://orders/owner", Method: "POST"}, true},
+		{"invalid failure mode", OwnershipCheckConfig{URL: "http://orders/owner", FailureMode: "ignore"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.check.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestIsExemptPath(t *testing.T) {
+	cfg := &Config{}
+	cfg.setDefaults()
+	cfg.Observability.HealthPath = "/healthz"
+	cfg.Observability.ExemptPaths = []string{"/lb-probe"}
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"/healthz", true},
+		{"/_health", false},
+		{"/_health/ready", true},
+		{"/_health/live", true},
+		{"/metrics", true},
+		{"/lb-probe", true},
+		{"/lb-probe/other", false},
+		{"/api/users", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := cfg.Observability.IsExemptPath(tt.path); got != tt.expected {
+			t.Errorf("IsExemptPath(%q) = %v, expected %v", tt.path, got, tt.expected)
+		}
+	}
+
+	cfg.Observability.MetricsEnabled = false
+	if cfg.Observability.IsExemptPath("/metrics") {
+		t.Error("Expected metrics path not to be exempt when metrics are disabled")
+	}
+}
+
+func TestAdminValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		admin       AdminConfig
+		expectError bool
+	}{
+		{"disabled", AdminConfig{}, false},
+		{"loopback without token", AdminConfig{Enabled: true, Address: "127.0.0.1:9901"}, false},
+		{"localhost without token", AdminConfig{Enabled: true, Address: "localhost:9901"}, false},
+		{"public without token", AdminConfig{Enabled: true, Address: ":9901"}, true},
+		{"public with token", AdminConfig{Enabled: true, Address: "0.0.0.0:9901", Token: "secret"}, false},
+		{"invalid address", AdminConfig{Enabled: true, Address: "9901"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.admin.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestAPIKeyValidation(t *testing.T) {
+	digest := strings.Repeat("ab", 32)
+
+	tests := []struct {
+		name        string
+		apiKeys     APIKeyConfig
+		expectError bool
+	}{
+		{"disabled", APIKeyConfig{}, false},
+		{"config store", APIKeyConfig{Enabled: true, Header: "X-Api-Key", Store: "config", Keys: []APIKeyDefinition{{ID: "ci", KeySHA256: digest}}}, false},
+		{"redis without address", APIKeyConfig{Enabled: true, Header: "X-Api-Key", Store: "redis"}, true},
+		{"redis", APIKeyConfig{Enabled: true, Header: "X-Api-Key", Store: "redis", RedisAddr: "localhost:6379"}, false},
+		{"invalid header", APIKeyConfig{Enabled: true, Header: "X Api Key", Store: "config"}, true},
+		{"negative grace period", APIKeyConfig{Enabled: true, Header: "X-Api-Key", Store: "config", RotationGracePeriod: -time.Hour}, true},
+		{"missing id", APIKeyConfig{Enabled: true, Header: "X-Api-Key", Store: "config", Keys: []APIKeyDefinition{{KeySHA256: digest}}}, true},
+		{"duplicate id", APIKeyConfig{Enabled: true, Header: "X-Api-Key", Store: "config", Keys: []APIKeyDefinition{{ID: "ci", KeySHA256: digest}, {ID: "ci", KeySHA256: digest}}}, true},
+		{"plain key instead of digest", APIKeyConfig{Enabled: true, Header: "X-Api-Key", Store: "config", Keys: []APIKeyDefinition{{ID: "ci", KeySHA256: "secret"}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.apiKeys.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestIntrospectionValidation(t *testing.T) {
+	valid := IntrospectionConfig{Enabled: true, URL: "https://auth.example.com/introspect", ClientID: "gateway", ClientSecret: "secret", Mode: "opaque", Timeout: time.Second}
+
+	tests := []struct {
+		name        string
+		modify      func(*IntrospectionConfig)
+		expectError bool
+	}{
+		{"valid", func(c *IntrospectionConfig) {}, false},
+		{"disabled", func(c *IntrospectionConfig) { *c = IntrospectionConfig{} }, false},
+		{"missing url", func(c *IntrospectionConfig) { c.URL = "" }, true},
+		{"non-http url", func(c *IntrospectionConfig) { c.URL = "ftp://auth.example.com" }, true},
+		{"missing client secret", func(c *IntrospectionConfig) { c.ClientSecret = "" }, true},
+		{"invalid mode", func(c *IntrospectionConfig) { c.Mode = "jwt" }, true},
+		{"zero timeout", func(c *IntrospectionConfig) { c.Timeout = 0 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+			err := cfg.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestExternalAuthzValidation(t *testing.T) {
+	valid := ExternalAuthzConfig{URL: "http://opa:8181/v1/data/gateway/allow", Timeout: time.Second, FailureMode: "fail-closed", CacheTTL: time.Second}
+
+	tests := []struct {
+		name        string
+		modify      func(*ExternalAuthzConfig)
+		expectError bool
+	}{
+		{"valid", func(c *ExternalAuthzConfig) {}, false},
+		{"disabled", func(c *ExternalAuthzConfig) { *c = ExternalAuthzConfig{} }, false},
+		{"non-http url", func(c *ExternalAuthzConfig) { c.URL = "opa:8181" }, true},
+		{"zero timeout", func(c *ExternalAuthzConfig) { c.Timeout = 0 }, true},
+		{"negative cache ttl", func(c *ExternalAuthzConfig) { c.CacheTTL = -time.Second }, true},
+		{"invalid failure mode", func(c *ExternalAuthzConfig) { c.FailureMode = "allow" }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+			err := cfg.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestBackendAuthValidation(t *testing.T) {
+	t.Setenv("TEST_BACKEND_KEY", "key")
+
+	tests := []struct {
+		name        string
+		auth        BackendAuthConfig
+		expectError bool
+	}{
+		{"disabled", BackendAuthConfig{}, false},
+		{"header from env", BackendAuthConfig{Type: "header", Header: "X-API-Key", Value: SecretRef{Env: "TEST_BACKEND_KEY"}}, false},
+		{"header from file", BackendAuthConfig{Type: "header", Header: "X-API-Key", Value: SecretRef{File: "/run/secrets/key"}}, false},
+		{"header without name", BackendAuthConfig{Type: "header", Value: SecretRef{Value: "key"}}, true},
+		{"header without value", BackendAuthConfig{Type: "header", Header: "X-API-Key"}, true},
+		{"two sources", BackendAuthConfig{Type: "header", Header: "X-API-Key", Value: SecretRef{Value: "key", Env: "TEST_BACKEND_KEY"}}, true},
+		{"unset env", BackendAuthConfig{Type: "header", Header: "X-API-Key", Value: SecretRef{Env: "TEST_BACKEND_KEY_UNSET"}}, true},
+		{"basic", BackendAuthConfig{Type: "basic", Username: "gateway", Password: SecretRef{Value: "pw"}}, false},
+		{"basic without username", BackendAuthConfig{Type: "basic", Password: SecretRef{Value: "pw"}}, true},
+		{"unknown type", BackendAuthConfig{Type: "digest"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.auth.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestIdentityTokenValidation(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "identity.pem")
+	if err := os.WriteFile(keyFile, []byte("key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	valid := IdentityTokenConfig{Enabled: true, Header: "Authorization", Algorithm: "RS256", PrivateKeyFile: keyFile, TTL: time.Minute}
+
+	tests := []struct {
+		name        string
+		modify      func(*IdentityTokenConfig)
+		expectError bool
+	}{
+		{"valid", func(c *IdentityTokenConfig) {}, false},
+		{"disabled", func(c *IdentityTokenConfig) { *c = IdentityTokenConfig{} }, false},
+		{"hmac secret", func(c *IdentityTokenConfig) { c.Algorithm = "HS256"; c.Secret = SecretRef{Value: "secret"} }, false},
+		{"hmac without secret", func(c *IdentityTokenConfig) { c.Algorithm = "HS256" }, true},
+		{"missing key file", func(c *IdentityTokenConfig) { c.PrivateKeyFile = keyFile + ".missing" }, true},
+		{"no key file", func(c *IdentityTokenConfig) { c.PrivateKeyFile = "" }, true},
+		{"invalid algorithm", func(c *IdentityTokenConfig) { c.Algorithm = "PS256" }, true},
+		{"invalid header", func(c *IdentityTokenConfig) { c.Header = "X Identity" }, true},
+		{"zero ttl", func(c *IdentityTokenConfig) { c.TTL = 0 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+			err := cfg.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestRevocationValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		revocation  RevocationConfig
+		expectError bool
+	}{
+		{"disabled", RevocationConfig{}, false},
+		{"redis", RevocationConfig{RedisAddr: "redis:6379", RedisKeyPrefix: "revoked:", Timeout: 100 * time.Millisecond, FailureMode: "fail-closed"}, false},
+		{"no key prefix", RevocationConfig{RedisAddr: "redis:6379", Timeout: 100 * time.Millisecond}, true},
+		{"no timeout", RevocationConfig{RedisAddr: "redis:6379", RedisKeyPrefix: "revoked:"}, true},
+		{"invalid failure mode", RevocationConfig{FailureMode: "fail-fast"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.revocation.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestClientCertValidation(t *testing.T) {
+	mapping := ClientCertMapping{Attribute: "san", Pattern: "spiffe://mesh.example/ns/billing/*", Roles: []string{"billing"}}
+
+	tests := []struct {
+		name        string
+		clientCert  ClientCertConfig
+		expectError bool
+	}{
+		{"disabled", ClientCertConfig{}, false},
+		{"valid", ClientCertConfig{Enabled: true, CAFile: "ca.pem", Mappings: []ClientCertMapping{mapping}}, false},
+		{"no ca file", ClientCertConfig{Enabled: true}, true},
+		{"invalid attribute", ClientCertConfig{Enabled: true, CAFile: "ca.pem", Mappings: []ClientCertMapping{{Attribute: "o", Pattern: "acme"}}}, true},
+		{"invalid pattern", ClientCertConfig{Enabled: true, CAFile: "ca.pem", Mappings: []ClientCertMapping{{Attribute: "cn", Pattern: "batch-["}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.clientCert.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestSessionAbuseValidation(t *testing.T) {
+	valid := SessionAbuseConfig{Enabled: true, ErrorThreshold: 20, Window: time.Minute, Penalty: 10 * time.Minute, LimitFactor: 0.1}
+
+	tests := []struct {
+		name        string
+		modify      func(*SessionAbuseConfig)
+		expectError bool
+	}{
+		{"valid", func(s *SessionAbuseConfig) {}, false},
+		{"disabled", func(s *SessionAbuseConfig) { *s = SessionAbuseConfig{} }, false},
+		{"no threshold", func(s *SessionAbuseConfig) { s.ErrorThreshold = 0 }, true},
+		{"no window", func(s *SessionAbuseConfig) { s.Window = 0 }, true},
+		{"no penalty", func(s *SessionAbuseConfig) { s.Penalty = 0 }, true},
+		{"factor zero", func(s *SessionAbuseConfig) { s.LimitFactor = 0 }, true},
+		{"factor above one", func(s *SessionAbuseConfig) { s.LimitFactor = 1.5 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			abuse := valid
+			tt.modify(&abuse)
+			err := abuse.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestBasicAuthValidation(t *testing.T) {
+	hash := SecretRef{Value: "$2y$10$" + strings.Repeat("a", 53)}
+
+	tests := []struct {
+		name        string
+		basic       BasicAuthConfig
+		expectError bool
+	}{
+		{"disabled", BasicAuthConfig{}, false},
+		{"htpasswd file", BasicAuthConfig{Enabled: true, HtpasswdFile: "/etc/gateway/htpasswd"}, false},
+		{"configured hash", BasicAuthConfig{Enabled: true, Users: map[string]BasicAuthUser{"ops": {PasswordHash: hash}}}, false},
+		{"hash from file", BasicAuthConfig{Enabled: true, Users: map[string]BasicAuthUser{"ops": {PasswordHash: SecretRef{File: "/run/secrets/ops"}}}}, false},
+		{"grants for htpasswd user", BasicAuthConfig{Enabled: true, HtpasswdFile: "/etc/gateway/htpasswd", Users: map[string]BasicAuthUser{"ops": {Roles: []string{"ops"}}}}, false},
+		{"no users", BasicAuthConfig{Enabled: true}, true},
+		{"user without hash", BasicAuthConfig{Enabled: true, Users: map[string]BasicAuthUser{"ops": {}}}, true},
+		{"plain password", BasicAuthConfig{Enabled: true, Users: map[string]BasicAuthUser{"ops": {PasswordHash: SecretRef{Value: "secret"}}}}, true},
+		{"colon in name", BasicAuthConfig{Enabled: true, Users: map[string]BasicAuthUser{"o:ps": {PasswordHash: hash}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.basic.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestRequestSigningValidation(t *testing.T) {
+	clients := map[string]SigningClientConfig{"partner": {Secret: SecretRef{Value: "secret"}}}
+
+	tests := []struct {
+		name        string
+		signing     RequestSigningConfig
+		expectError bool
+	}{
+		{"disabled", RequestSigningConfig{}, false},
+		{"valid", RequestSigningConfig{Enabled: true, MaxClockSkew: time.Minute, MaxBodySize: 1024, Clients: clients}, false},
+		{"no clients", RequestSigningConfig{Enabled: true, MaxClockSkew: time.Minute, MaxBodySize: 1024}, true},
+		{"no clock skew", RequestSigningConfig{Enabled: true, MaxBodySize: 1024, Clients: clients}, true},
+		{"no body size", RequestSigningConfig{Enabled: true, MaxClockSkew: time.Minute, Clients: clients}, true},
+		{"client without secret", RequestSigningConfig{Enabled: true, MaxClockSkew: time.Minute, MaxBodySize: 1024, Clients: map[string]SigningClientConfig{"partner": {}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.signing.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestGatewayMetadataValidation(t *testing.T) {
+	valid := GatewayMetadataConfig{Enabled: true, Header: "X-Gateway-Metadata", Secret: SecretRef{Value: "key"}, TTL: 30 * time.Second}
+
+	tests := []struct {
+		name        string
+		modify      func(*GatewayMetadataConfig)
+		expectError bool
+	}{
+		{"valid", func(c *GatewayMetadataConfig) {}, false},
+		{"disabled", func(c *GatewayMetadataConfig) { *c = GatewayMetadataConfig{} }, false},
+		{"no secret", func(c *GatewayMetadataConfig) { c.Secret = SecretRef{} }, true},
+		{"invalid header", func(c *GatewayMetadataConfig) { c.Header = "X Gateway" }, true},
+		{"zero ttl", func(c *GatewayMetadataConfig) { c.TTL = 0 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+			err := cfg.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestEdgeLoginValidation(t *testing.T) {
+	valid := func() EdgeLoginConfig {
+		return EdgeLoginConfig{
+			Enabled:       true,
+			Issuer:        "https://idp.example.com",
+			ClientID:      "gateway",
+			ClientSecret:  SecretRef{File: "/run/secrets/oidc-client"},
+			RedirectURL:   "https://app.example.com/_auth/callback",
+			Token:         "access_token",
+			LogoutPath:    "/_auth/logout",
+			CookieName:    "gateway_session",
+			EncryptionKey: SecretRef{Value: strings.Repeat("k", 32)},
+			SessionTTL:    24 * time.Hour,
+			RefreshBefore: time.Minute,
+			Timeout:       5 * time.Second,
+		}
+	}
+
+	tests := []struct {
+		name        string
+		modify      func(c *EdgeLoginConfig)
+		expectError bool
+	}{
+		{"valid", func(c *EdgeLoginConfig) {}, false},
+		{"disabled", func(c *EdgeLoginConfig) { *c = EdgeLoginConfig{} }, false},
+		{"explicit endpoints", func(c *EdgeLoginConfig) {
+			c.Issuer = ""
+			c.AuthorizationURL = "https://idp.example.com/authorize"
+			c.TokenURL = "https://idp.example.com/token"
+		}, false},
+		{"no issuer", func(c *EdgeLoginConfig) { c.Issuer = "" }, true},
+		{"no client id", func(c *EdgeLoginConfig) { c.ClientID = "" }, true},
+		{"no client secret", func(c *EdgeLoginConfig) { c.ClientSecret = SecretRef{} }, true},
+		{"relative redirect url", func(c *EdgeLoginConfig) { c.RedirectURL = "/_auth/callback" }, true},
+		{"invalid token", func(c *EdgeLoginConfig) { c.Token = "refresh_token" }, true},
+		{"logout on callback", func(c *EdgeLoginConfig) { c.LogoutPath = "/_auth/callback" }, true},
+		{"short encryption key", func(c *EdgeLoginConfig) { c.EncryptionKey = SecretRef{Value: "short"} }, true},
+		{"no session ttl", func(c *EdgeLoginConfig) { c.SessionTTL = 0 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			tt.modify(&c)
+			err := c.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestInternalListenerValidation(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(file, []byte("test"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	server := ServerConfig{HTTPPort: 8080, HTTPSPort: 8443, TLSCertFile: file, TLSKeyFile: file}
+	valid := InternalListenerConfig{Enabled: true, Port: 8444, ClientCAFile: file}
+
+	tests := []struct {
+		name        string
+		modify      func(*InternalListenerConfig, *ServerConfig)
+		expectError bool
+	}{
+		{"server certificate", func(c *InternalListenerConfig, s *ServerConfig) {}, false},
+		{"own certificate", func(c *InternalListenerConfig, s *ServerConfig) {
+			*s = ServerConfig{HTTPPort: 8080, HTTPSPort: 8443}
+			c.TLSCertFile, c.TLSKeyFile = file, file
+		}, false},
+		{"no certificate", func(c *InternalListenerConfig, s *ServerConfig) { *s = ServerConfig{HTTPPort: 8080, HTTPSPort: 8443} }, true},
+		{"cert without key", func(c *InternalListenerConfig, s *ServerConfig) { c.TLSCertFile = file }, true},
+		{"no client CA", func(c *InternalListenerConfig, s *ServerConfig) { c.ClientCAFile = "" }, true},
+		{"missing client CA", func(c *InternalListenerConfig, s *ServerConfig) { c.ClientCAFile = filepath.Join(dir, "missing.pem") }, true},
+		{"public port", func(c *InternalListenerConfig, s *ServerConfig) { c.Port = 8443 }, true},
+		{"invalid port", func(c *InternalListenerConfig, s *ServerConfig) { c.Port = 70000 }, true},
+		{"empty service", func(c *InternalListenerConfig, s *ServerConfig) {
+			c.Services = map[string]ServiceIdentityConfig{" ": {Roles: []string{"reader"}}}
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, srv := valid, server
+			tt.modify(&cfg, &srv)
+			err := cfg.validate(srv)
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestMirrorValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		mirror      MirrorConfig
+		expectError bool
+	}{
+		{"disabled", MirrorConfig{}, false},
+		{"sampled", MirrorConfig{BackendURL: "http://shadow:8080", SamplePercent: 5}, false},
+		{"opt-in only", MirrorConfig{BackendURL: "http://shadow:8080", OptInHeader: "X-Mirror"}, false},
+		{"no sampling", MirrorConfig{BackendURL: "http://shadow:8080"}, true},
+		{"percent over 100", MirrorConfig{BackendURL: "http://shadow:8080", SamplePercent: 150}, true},
+		{"invalid URL", MirrorConfig{BackendURL: "shadow", SamplePercent: 5}, true},
+		{"negative timeout", MirrorConfig{BackendURL: "http://shadow:8080", SamplePercent: 5, Timeout: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.mirror.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestTransportSecurityValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		security    TransportSecurityConfig
+		expectError bool
+	}{
+		{"disabled", TransportSecurityConfig{}, false},
+		{"require tls", TransportSecurityConfig{RequireTLS: true}, false},
+		{"forbidden status", TransportSecurityConfig{RequireTLS: true, PlaintextStatus: 403}, false},
+		{"tls 1.3", TransportSecurityConfig{MinTLSVersion: "1.3"}, false},
+		{"redirect status", TransportSecurityConfig{RequireTLS: true, PlaintextStatus: 301}, true},
+		{"unknown version", TransportSecurityConfig{MinTLSVersion: "1.1"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.security.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestRequestSchemaValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		yaml        string
+		expectError bool
+	}{
+		{"disabled", `{}`, false},
+		{"inline schema", "schema:\n  type: object\n  required: [name]\n  properties:\n    name: {type: string, maxLength: 64}\n", false},
+		{"unknown type", "schema:\n  type: text\n", true},
+		{"invalid pattern", "schema:\n  pattern: \"(\"\n", true},
+		{"negative max body size", "max_body_size: -1\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var schema RequestSchemaConfig
+			if err := yaml.Unmarshal([]byte(tt.yaml), &schema); err != nil {
+				t.Fatalf("invalid test yaml: %v", err)
+			}
+			err := schema.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestAuthBypassValidation(t *testing.T) {
+	secret := SecretRef{Value: "export-secret-0123456789"}
+	tests := []struct {
+		name        string
+		bypass      AuthBypassConfig
+		expectError bool
+	}{
+		{"disabled", AuthBypassConfig{}, false},
+		{"networks", AuthBypassConfig{Enabled: true, Networks: []string{"10.0.0.0/8", "192.168.1.10"}, Policies: []string{"authenticated"}}, false},
+		{"services", AuthBypassConfig{Enabled: true, Header: "X-Gateway-Service-Token", Services: map[string]SecretRef{"batch": secret}}, false},
+		{"no sources", AuthBypassConfig{Enabled: true}, true},
+		{"invalid network", AuthBypassConfig{Enabled: true, Networks: []string{"10.0.0.0/33"}}, true},
+		{"missing header", AuthBypassConfig{Enabled: true, Services: map[string]SecretRef{"batch": secret}}, true},
+		{"short secret", AuthBypassConfig{Enabled: true, Header: "X-Gateway-Service-Token", Services: map[string]SecretRef{"batch": {Value: "short"}}}, true},
+		{"public policy", AuthBypassConfig{Enabled: true, Networks: []string{"10.0.0.0/8"}, Policies: []string{"public"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.bypass.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestTokenSourcesValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		sources     TokenSources
+		expectError bool
+	}{
+		{"empty", nil, false},
+		{"all types", TokenSources{{Type: "bearer"}, {Type: "cookie"}, {Type: "cookie", Name: "legacy"}, {Type: "header", Name: "X-Session-Token"}, {Type: "query", Name: "access_token"}}, false},
+		{"unknown type", TokenSources{{Type: "body"}}, true},
+		{"header without name", TokenSources{{Type: "header"}}, true},
+		{"authorization header", TokenSources{{Type: "header", Name: "authorization"}}, true},
+		{"query without name", TokenSources{{Type: "query"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.sources.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestReplayProtectionValidation(t *testing.T) {
+	valid := ReplayProtectionConfig{MaxEntries: 1000, MaxTTL: time.Hour, FailureMode: "fail-closed"}
+	tests := []struct {
+		name        string
+		modify      func(c *ReplayProtectionConfig)
+		expectError bool
+	}{
+		{"memory", func(c *ReplayProtectionConfig) {}, false},
+		{"redis", func(c *ReplayProtectionConfig) {
+			c.RedisAddr, c.RedisKeyPrefix, c.Timeout, c.MaxEntries = "localhost:6379", "replay:jti:", 100*time.Millisecond, 0
+		}, false},
+		{"invalid failure mode", func(c *ReplayProtectionConfig) { c.FailureMode = "ignore" }, true},
+		{"no max ttl", func(c *ReplayProtectionConfig) { c.MaxTTL = 0 }, true},
+		{"memory without max entries", func(c *ReplayProtectionConfig) { c.MaxEntries = 0 }, true},
+		{"redis without prefix", func(c *ReplayProtectionConfig) { c.RedisAddr, c.Timeout = "localhost:6379", time.Second }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+			err := cfg.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+
+	t.Run("one time token on public route", func(t *testing.T) {
+		cfg := &Config{}
+		cfg.setDefaults()
+		cfg.Authorization.JWTSharedSecret = "secret"
+		cfg.Authorization.JWTSigningAlgorithm = "HS256"
+		cfg.Routes = []RouteConfig{{PathPattern: "/payments/callback", Methods: []string{"POST"}, BackendURL: "http://payments:8080", AuthPolicy: "public", OneTimeToken: true}}
+		if err := cfg.Validate(); err == nil {
+			t.Error("expected one_time_token on a public route to be rejected")
+		}
+		cfg.Routes[0].AuthPolicy = "authenticated"
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestRequestAgeValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		age         RequestAgeConfig
+		expectError bool
+	}{
+		{"disabled", RequestAgeConfig{}, false},
+		{"default headers", RequestAgeConfig{MaxSkew: 5 * time.Minute}, false},
+		{"custom header", RequestAgeConfig{MaxSkew: time.Minute, Headers: []string{"X-Signature-Timestamp"}}, false},
+		{"negative skew", RequestAgeConfig{MaxSkew: -time.Minute}, true},
+		{"invalid header", RequestAgeConfig{MaxSkew: time.Minute, Headers: []string{"X Timestamp"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.age.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestDiagnosticsValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		diagnostics DiagnosticsConfig
+		expectError bool
+	}{
+		{"disabled", DiagnosticsConfig{}, false},
+		{"without debug token", DiagnosticsConfig{Enabled: true, Path: "/.well-known/gateway"}, false},
+		{"debug token", DiagnosticsConfig{Enabled: true, Path: "/.well-known/gateway", DebugToken: SecretRef{File: "/run/secrets/gateway-debug-token"}, DebugHeader: "X-Gateway-Debug-Token"}, false},
+		{"relative path", DiagnosticsConfig{Enabled: true, Path: ".well-known/gateway"}, true},
+		{"short debug token", DiagnosticsConfig{Enabled: true, Path: "/.well-known/gateway", DebugToken: SecretRef{Value: "short"}, DebugHeader: "X-Gateway-Debug-Token"}, true},
+		{"missing debug header", DiagnosticsConfig{Enabled: true, Path: "/.well-known/gateway", DebugToken: SecretRef{File: "/run/secrets/gateway-debug-token"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.diagnostics.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestStepUpValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		stepUp      StepUpConfig
+		expectError bool
+	}{
+		{"disabled", StepUpConfig{}, false},
+		{"acr and amr", StepUpConfig{ACR: []string{"urn:example:mfa"}, AMR: []string{"otp", "hwk"}}, false},
+		{"empty value", StepUpConfig{ACR: []string{""}}, true},
+		{"value with space", StepUpConfig{ACR: []string{"urn:example:mfa urn:example:hwk"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.stepUp.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestQuotaValidation(t *testing.T) {
+	valid := QuotaConfig{Enabled: true, Store: "memory", Period: "monthly", ResetDay: 1, Timezone: "UTC", Limit: 10000}
+
+	tests := []struct {
+		name        string
+		modify      func(*QuotaConfig)
+		expectError bool
+	}{
+		{"valid", func(q *QuotaConfig) {}, false},
+		{"disabled", func(q *QuotaConfig) { *q = QuotaConfig{} }, false},
+		{"daily with reset hour", func(q *QuotaConfig) { q.Period = "daily"; q.ResetDay = 0; q.ResetHour = 6 }, false},
+		{"redis without address", func(q *QuotaConfig) { q.Store = "redis" }, true},
+		{"unknown period", func(q *QuotaConfig) { q.Period = "weekly" }, true},
+		{"reset day beyond 28", func(q *QuotaConfig) { q.ResetDay = 31 }, true},
+		{"reset hour out of range", func(q *QuotaConfig) { q.ResetHour = 24 }, true},
+		{"unknown timezone", func(q *QuotaConfig) { q.Timezone = "Mars/Olympus" }, true},
+		{"negative tier budget", func(q *QuotaConfig) { q.Tiers = map[string]int64{"partner": -1} }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quota := valid
+			tt.modify(&quota)
+			err := quota.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestAdaptiveRateLimitValidation(t *testing.T) {
+	cfg := &Config{}
+	cfg.setDefaults()
+	valid := cfg.RateLimit.Adaptive
+	valid.Enabled = true
+
+	tests := []struct {
+		name        string
+		modify      func(*AdaptiveRateLimitConfig)
+		expectError bool
+	}{
+		{"defaults", func(a *AdaptiveRateLimitConfig) {}, false},
+		{"disabled", func(a *AdaptiveRateLimitConfig) { *a = AdaptiveRateLimitConfig{} }, false},
+		{"zero interval", func(a *AdaptiveRateLimitConfig) { a.Interval = 0 }, true},
+		{"error rate above one", func(a *AdaptiveRateLimitConfig) { a.ErrorRateThreshold = 1.5 }, true},
+		{"decrease factor of one", func(a *AdaptiveRateLimitConfig) { a.DecreaseFactor = 1 }, true},
+		{"zero min factor", func(a *AdaptiveRateLimitConfig) { a.MinFactor = 0 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adaptive := valid
+			tt.modify(&adaptive)
+			err := adaptive.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestDegradedModeValidation(t *testing.T) {
+	cfg := &Config{}
+	cfg.setDefaults()
+	valid := cfg.RateLimit.Degraded
+	valid.Enabled = true
+
+	tests := []struct {
+		name        string
+		modify      func(*DegradedModeConfig)
+		expectError bool
+	}{
+		{"defaults", func(d *DegradedModeConfig) {}, false},
+		{"disabled", func(d *DegradedModeConfig) { *d = DegradedModeConfig{} }, false},
+		{"full limits", func(d *DegradedModeConfig) { d.LimitFactor = 1 }, false},
+		{"zero limit factor", func(d *DegradedModeConfig) { d.LimitFactor = 0 }, true},
+		{"limit factor above one", func(d *DegradedModeConfig) { d.LimitFactor = 2 }, true},
+		{"zero check interval", func(d *DegradedModeConfig) { d.CheckInterval = 0 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			degraded := valid
+			tt.modify(&degraded)
+			err := degraded.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerValidation(t *testing.T) {
+	cfg := &Config{}
+	cfg.setDefaults()
+	defaults := cfg.Proxy.CircuitBreaker
+
+	tests := []struct {
+		name        string
+		route       CircuitBreakerConfig
+		expectError bool
+	}{
+		{"inherits defaults", CircuitBreakerConfig{}, false},
+		{"own thresholds", CircuitBreakerConfig{FailureThreshold: 10, SuccessThreshold: 3, Timeout: 30 * time.Second}, false},
+		{"negative failure threshold", CircuitBreakerConfig{FailureThreshold: -1}, true},
+		{"negative timeout", CircuitBreakerConfig{Timeout: -time.Second}, true},
+		{"success threshold above half-open requests", CircuitBreakerConfig{SuccessThreshold: 5}, false},
+		{"single half-open request", CircuitBreakerConfig{MaxHalfOpenRequests: 1}, false},
+		{"probe path", CircuitBreakerConfig{ProbePath: "/health", ProbeInterval: 2 * time.Second}, false},
+		{"relative probe path", CircuitBreakerConfig{ProbePath: "health"}, true},
+		{"negative probe timeout", CircuitBreakerConfig{ProbePath: "/health", ProbeTimeout: -time.Second}, true},
+		{"sliding window", CircuitBreakerConfig{Mode: "sliding_window", FailureRateThreshold: 25, Window: 30 * time.Second}, false},
+		{"unknown mode", CircuitBreakerConfig{Mode: "adaptive"}, true},
+		{"failure status codes", CircuitBreakerConfig{FailureStatusCodes: []int{500, 502, 503, 504, 429}}, false},
+		{"invalid failure status code", CircuitBreakerConfig{FailureStatusCodes: []int{1000}}, true},
+		{"failure rate above 100", CircuitBreakerConfig{Mode: "sliding_window", FailureRateThreshold: 150}, true},
+		{"negative slow call duration", CircuitBreakerConfig{Mode: "sliding_window", SlowCallDuration: -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.route.validateOverride(defaults)
+			if (err != nil) != tt.expectError {
+				t.Errorf("validateOverride() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+
+	cfg.Proxy.CircuitBreaker.Timeout = 0
+	if err := cfg.Proxy.CircuitBreaker.validate(); err == nil {
+		t.Error("expected error for zero default timeout")
+	}
+}
+
+func TestFallbackValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		fallback    FallbackConfig
+		expectError bool
+	}{
+		{"none", FallbackConfig{}, false},
+		{"static", FallbackConfig{Type: "static", Body: map[string]interface{}{"items": []interface{}{}}}, false},
+		{"static with status", FallbackConfig{Type: "static", Status: 503, Headers: map[string]string{"Retry-After": "30"}}, false},
+		{"static with invalid status", FallbackConfig{Type: "static", Status: 42}, true},
+		{"static with unencodable body", FallbackConfig{Type: "static", Body: math.Inf(1)}, true},
+		{"cache", FallbackConfig{Type: "cache", MaxAge: time.Hour}, false},
+		{"cache with negative max age", FallbackConfig{Type: "cache", MaxAge: -time.Second}, true},
+		{"backend", FallbackConfig{Type: "backend", BackendURL: "http://orders-replica:8080"}, false},
+		{"backend without URL", FallbackConfig{Type: "backend"}, true},
+		{"unknown type", FallbackConfig{Type: "retry"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.fallback.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestBreakerStoreValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		store       BreakerStoreConfig
+		expectError bool
+	}{
+		{"disabled", BreakerStoreConfig{}, false},
+		{"file", BreakerStoreConfig{Type: "file", Path: "/var/lib/gateway/breakers.json", MaxAge: time.Hour}, false},
+		{"file without path", BreakerStoreConfig{Type: "file", MaxAge: time.Hour}, true},
+		{"redis", BreakerStoreConfig{Type: "redis", RedisAddr: "redis:6379", RedisKeyPrefix: "circuitbreaker:", MaxAge: time.Hour}, false},
+		{"redis without address", BreakerStoreConfig{Type: "redis", RedisKeyPrefix: "circuitbreaker:", MaxAge: time.Hour}, true},
+		{"zero max age", BreakerStoreConfig{Type: "file", Path: "breakers.json"}, true},
+		{"unknown type", BreakerStoreConfig{Type: "etcd", MaxAge: time.Hour}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.store.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestBreakerGCValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		gc          BreakerGCConfig
+		expectError bool
+	}{
+		{"disabled", BreakerGCConfig{}, false},
+		{"idle TTL and cap", BreakerGCConfig{IdleTTL: time.Hour, MaxBreakers: 10000}, false},
+		{"negative idle TTL", BreakerGCConfig{IdleTTL: -time.Minute}, true},
+		{"negative cap", BreakerGCConfig{MaxBreakers: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.gc.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimitValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		limit       ConcurrencyLimit
+		expectError bool
+	}{
+		{"per user", ConcurrencyLimit{Key: "user", Max: 2}, false},
+		{"per route with 503", ConcurrencyLimit{Key: "route", Max: 50, Status: 503}, false},
+		{"missing key", ConcurrencyLimit{Max: 2}, true},
+		{"zero max", ConcurrencyLimit{Key: "user"}, true},
+		{"unsupported status", ConcurrencyLimit{Key: "user", Max: 2, Status: 500}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.limit.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestKeyTemplateValidation(t *testing.T) {
+	tests := []struct {
+		template    string
+		expectError bool
+	}{
+		{"ip", false},
+		{"user:route", false},
+		{"ip:route:method", false},
+		{"claim:tenant_id", false},
+		{"header:X-Api-Key:route", false},
+		{"api_key", false},
+		{"", true},
+		{"tenant", true},
+		{"claim", true},
+		{"header::route", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			err := validateKeyTemplate(tt.template)
+			if (err != nil) != tt.expectError {
+				t.Errorf("validateKeyTemplate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestLimitAlgorithmValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		algorithm   string
+		expectError bool
+	}{
+		{"default", "", false},
+		{"token bucket", "token_bucket", false},
+		{"sliding window", "sliding_window", false},
+		{"gcra", "gcra", false},
+		{"fixed window", "fixed_window", false},
+		{"unknown", "leaky_bucket", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateLimits([]LimitDefinition{{Key: "ip", Limit: 10, Window: "1m", Algorithm: tt.algorithm}})
+			if (err != nil) != tt.expectError {
+				t.Errorf("validateLimits() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestLimitPolicyValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		limit       LimitDefinition
+		expectError bool
+	}{
+		{"default", LimitDefinition{}, false},
+		{"reject", LimitDefinition{Policy: "reject"}, false},
+		{"delay", LimitDefinition{Policy: "delay", MaxDelay: 200 * time.Millisecond}, false},
+		{"delay without max delay", LimitDefinition{Policy: "delay"}, true},
+		{"queue", LimitDefinition{Policy: "queue", MaxDelay: time.Second, QueueSize: 10}, false},
+		{"queue without size", LimitDefinition{Policy: "queue", MaxDelay: time.Second}, true},
+		{"unknown", LimitDefinition{Policy: "drop"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit := tt.limit
+			limit.Key, limit.Limit, limit.Window = "ip", 10, "1m"
+			err := validateLimits([]LimitDefinition{limit})
+			if (err != nil) != tt.expectError {
+				t.Errorf("validateLimits() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestRouteCostValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		cost        int
+		route       []LimitDefinition
+		global      []LimitDefinition
+		tiers       map[string][]LimitDefinition
+		expectError bool
+	}{
+		{"default cost", 0, []LimitDefinition{{Key: "ip", Limit: 1, Window: "1s", Algorithm: "gcra"}}, nil, nil, false},
+		{"within limit", 10, []LimitDefinition{{Key: "ip", Limit: 10, Window: "1m"}}, nil, nil, false},
+		{"above limit", 11, []LimitDefinition{{Key: "ip", Limit: 10, Window: "1m"}}, nil, nil, true},
+		{"within burst", 20, []LimitDefinition{{Key: "ip", Limit: 10, Window: "1m", Burst: 20}}, nil, nil, false},
+		{"above burst", 10, []LimitDefinition{{Key: "ip", Limit: 100, Window: "1m", Burst: 5}}, nil, nil, true},
+		{"above sliding window", 11, []LimitDefinition{{Key: "ip", Limit: 10, Window: "1m", Burst: 20, Algorithm: "sliding_window"}}, nil, nil, true},
+		{"above fixed window", 11, []LimitDefinition{{Key: "ip", Limit: 10, Window: "1m", Algorithm: "fixed_window"}}, nil, nil, true},
+		{"within gcra burst", 5, []LimitDefinition{{Key: "ip", Limit: 100, Window: "1m", Burst: 5, Algorithm: "gcra"}}, nil, nil, false},
+		{"above default gcra burst", 2, []LimitDefinition{{Key: "ip", Limit: 100, Window: "1m", Algorithm: "gcra"}}, nil, nil, true},
+		{"above global limit", 11, nil, []LimitDefinition{{Key: "ip", Limit: 10, Window: "1m"}}, nil, true},
+		{"above tier limit", 11, nil, nil, map[string][]LimitDefinition{"free": {{Key: "user", Limit: 10, Window: "1m"}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.setDefaults()
+			cfg.Authorization.JWTSharedSecret = "test-secret"
+			cfg.RateLimit.GlobalLimits = tt.global
+			cfg.RateLimit.Tiers = tt.tiers
+			cfg.Routes = []RouteConfig{{
+				PathPattern: "/api/search",
+				Methods:     []string{"GET"},
+				BackendURL:  "http://localhost:3000",
+				AuthPolicy:  "public",
+				RateLimits:  tt.route,
+				Cost:        tt.cost,
+			}}
+
+			err := cfg.Validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("Validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestCachePolicyValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      CachePolicyConfig
+		expectError bool
+	}{
+		{"empty", CachePolicyConfig{}, false},
+		{"vary and store", CachePolicyConfig{Vary: []string{"Accept-Language"}, Store: ResponseCacheConfig{Enabled: true, SubjectClaim: "sub"}}, false},
+		{"invalid vary name", CachePolicyConfig{Vary: []string{"Accept Language"}}, true},
+		{"store vary on authorization", CachePolicyConfig{Vary: []string{"authorization"}, Store: ResponseCacheConfig{Enabled: true}}, true},
+		{"store vary on authorization allowed", CachePolicyConfig{Vary: []string{"Authorization"}, Store: ResponseCacheConfig{Enabled: true, SubjectClaim: "sub", AllowCredentialVary: true}}, false},
+		{"invalid allow header", CachePolicyConfig{Store: ResponseCacheConfig{Enabled: true, AllowHeaders: []string{"Set Cookie"}}}, true},
+		{"negative max entries", CachePolicyConfig{Store: ResponseCacheConfig{Enabled: true, MaxEntries: -1}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestHeaderRulesValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		rules       HeaderRules
+		expectError bool
+	}{
+		{
+			name: "valid rules",
+			rules: HeaderRules{
+				Set:    map[string]string{"X-Tenant-ID": "${param.tenant}"},
+				Add:    map[string]string{"X-User": "user-${claim.user_id}"},
+				Remove: []string{"Server"},
+			},
+			expectError: false,
+		},
+		{
+			name:        "invalid header name",
+			rules:       HeaderRules{Set: map[string]string{"X Tenant": "a"}},
+			expectError: true,
+		},
+		{
+			name:        "empty remove name",
+			rules:       HeaderRules{Remove: []string{""}},
+			expectError: true,
+		},
+		{
+			name:        "unknown template namespace",
+			rules:       HeaderRules{Set: map[string]string{"X-Env": "${env.HOME}"}},
+			expectError: true,
+		},
+		{
+			name:        "unterminated template",
+			rules:       HeaderRules{Set: map[string]string{"X-Tenant-ID": "${param.tenant"}},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rules.validate()
+			if tt.expectError && err == nil {
+				t.Error("expected validation error, got nil")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestSecretRefResolve(t *testing.T) {
+	t.Setenv("TEST_SECRET", "from-env")
+	empty := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(empty, []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		ref         SecretRef
+		expected    string
+		expectError bool
+	}{
+		{"inline", SecretRef{Value: "inline"}, "inline", false},
+		{"env", SecretRef{Env: "TEST_SECRET"}, "from-env", false},
+		{"unset env", SecretRef{Env: "TEST_SECRET_UNSET"}, "", true},
+		{"missing file", SecretRef{File: filepath.Join(t.TempDir(), "missing")}, "", true},
+		{"empty file", SecretRef{File: empty}, "", true},
+		{"none", SecretRef{}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := tt.ref.Resolve()
+			if (err != nil) != tt.expectError {
+				t.Fatalf("Resolve() error = %v, expectError %v", err, tt.expectError)
+			}
+			if value != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestSecretRefFileRotation(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+
+	path := filepath.Join(t.TempDir(), "api-key")
+	if err := os.WriteFile(path, []byte("first\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ref := SecretRef{File: path}
+
+	value, err := ref.Resolve()
+	if err != nil || value != "first" {
+		t.Fatalf("expected first, got %q (%v)", value, err)
+	}
+
+	if err := os.WriteFile(path, []byte("second\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := ref.Resolve(); value != "second" {
+		t.Errorf("expected rotated value second, got %q", value)
+	}
+
+	// An emptied or removed file keeps the last good secret
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	later = later.Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := ref.Resolve(); value != "second" {
+		t.Errorf("expected last good value second after emptying, got %q", value)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := ref.Resolve(); value != "second" {
+		t.Errorf("expected last good value second after removal, got %q", value)
+	}
+}
+
+func TestExport(t *testing.T) {
+	cfg := &Config{}
+	cfg.setDefaults()
+	cfg.Authorization.JWTSharedSecret = "super-secret"
+	cfg.Authorization.Bypass.Services = map[string]SecretRef{"nightly-export": {Value: "bypass-service-secret"}}
+	cfg.RateLimit.Quota.RedisPassword = "quota-redis-password"
+	cfg.Proxy.CircuitBreakerStore.RedisPassword = "breaker-redis-password"
+	cfg.Routes = []RouteConfig{
+		{
+			PathPattern: "/api/test",
+			Methods:     []string{"GET"},
+			BackendURL:  "http://localhost:3000",
+			Timeout:     15 * time.Second,
+			RequestHeaders: HeaderRules{
+				Set: map[string]string{
+					"Authorization": "Bearer backend-token",
+					"X-Tenant":      "acme",
+				},
+			},
+			BackendAuth: BackendAuthConfig{
+				Type:   "header",
+				Header: "X-API-Key",
+				Value:  SecretRef{Value: "static-backend-key"},
+			},
+		},
+	}
+
+	for _, format := range []string{"yaml", "json"} {
+		t.Run(format, func(t *testing.T) {
+			data, err := cfg.Export(format)
+			if err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+
+			out := string(data)
+			if strings.Contains(out, "super-secret") || strings.Contains(out, "backend-token") || strings.Contains(out, "static-backend-key") || strings.Contains(out, "bypass-service-secret") || strings.Contains(out, "quota-redis-password") ||
+				strings.Contains(out, "breaker-redis-password") {
+				t.Errorf("expected secrets to be redacted, got:\n%s", out)
+			}
+			if !strings.Contains(out, redactedValue) || !strings.Contains(out, "acme") {
+				t.Errorf("expected redacted and plain values in export, got:\n%s", out)
+			}
+
+			// The export must load back into an equivalent configuration
+			exported := &Config{}
+			if format == "yaml" {
+				err = yaml.Unmarshal(data, exported)
+			} else {
+				err = json.Unmarshal(data, exported)
+			}
+			if err != nil {
+				t.Fatalf("failed to parse export: %v", err)
+			}
+			if exported.Routes[0].Timeout != 15*time.Second {
+				t.Errorf("expected route timeout 15s, got %v", exported.Routes[0].Timeout)
+			}
+		})
+	}
+
+	if cfg.Authorization.JWTSharedSecret != "super-secret" ||
+		cfg.Routes[0].RequestHeaders.Set["Authorization"] != "Bearer backend-token" {
+		t.Error("expected Export not to modify the original configuration")
+	}
+
+	if _, err := cfg.Export("xml"); err == nil {
+		t.Error("expected error for unsupported format")
+	}
 }
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Deployment environments
+const (
+	EnvironmentDev     = "dev"
+	EnvironmentStaging = "staging"
+	EnvironmentProd    = "prod"
+)
+
+// Interlocks refuse to start a prod gateway with unsafe settings. Each can be
+// overridden by listing its name in acknowledge_unsafe.
+const (
+	InterlockInternalErrors    = "internal_errors"
+	InterlockWeakJWTSecret     = "weak_jwt_secret"
+	InterlockPlaintextListener = "plaintext_listener"
+	InterlockRateLimitFailOpen = "rate_limit_fail_open"
+)
+
+// minHMACSecretLength is the minimum shared secret length in bytes per HMAC
+// algorithm; RFC 7518 requires keys at least as long as the hash output
+var minHMACSecretLength = map[string]int{
+	"HS256": 32,
+	"HS384": 48,
+	"HS512": 64,
+}
+
+// interlocks maps each interlock to a check returning the reason the
+// configuration is unsafe, or "" if it is not
+var interlocks = map[string]func(c *Config) string{
+	InterlockInternalErrors: func(c *Config) string {
+		if !c.Security.HideInternalErrors {
+			return "security.hide_internal_errors is false, so internal error details reach clients"
+		}
+		return ""
+	},
+	InterlockWeakJWTSecret: func(c *Config) string {
+		minLength, ok := minHMACSecretLength[c.Authorization.JWTSigningAlgorithm]
+		if !c.Authorization.Enabled || !ok || c.Authorization.JWTSharedSecret == "" {
+			return ""
+		}
+		if len(c.Authorization.JWTSharedSecret) < minLength {
+			return fmt.Sprintf("%s shared secret is %d bytes, need at least %d",
+				c.Authorization.JWTSigningAlgorithm, len(c.Authorization.JWTSharedSecret), minLength)
+		}
+		return ""
+	},
+	InterlockPlaintextListener: func(c *Config) string {
+		if !c.Server.TLSEnabled {
+			return "server.tls_enabled is false, so the public listener serves plain HTTP"
+		}
+		return ""
+	},
+	InterlockRateLimitFailOpen: func(c *Config) string {
+		if c.RateLimit.Enabled && c.RateLimit.FailureMode == "fail-open" {
+			return "rate_limit.failure_mode is fail-open, so limits lapse when the backend is down"
+		}
+		return ""
+	},
+}
+
+// validateEnvironment checks the environment and, in prod, the interlocks
+func (c *Config) validateEnvironment() error {
+	switch c.Environment {
+	case EnvironmentDev, EnvironmentStaging, EnvironmentProd:
+	default:
+		return fmt.Errorf("invalid environment: %s (must be 'dev', 'staging' or 'prod')", c.Environment)
+	}
+
+	acknowledged := make(map[string]bool, len(c.AcknowledgeUnsafe))
+	for _, name := range c.AcknowledgeUnsafe {
+		if _, ok := interlocks[name]; !ok {
+			return fmt.Errorf("acknowledge_unsafe: unknown interlock %q", name)
+		}
+		acknowledged[name] = true
+	}
+
+	if c.Environment != EnvironmentProd {
+		return nil
+	}
+
+	var violations []string
+	for _, name := range sortedInterlocks() {
+		if acknowledged[name] {
+			continue
+		}
+		if reason := interlocks[name](c); reason != "" {
+			violations = append(violations, fmt.Sprintf("%s (%s)", reason, name))
+		}
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("unsafe configuration for environment prod: %s; fix it or list the interlock in acknowledge_unsafe",
+			strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+// UnsafeAcknowledged returns the acknowledged interlocks that the
+// configuration actually trips, so they can be reported at startup
+func (c *Config) UnsafeAcknowledged() []string {
+	var tripped []string
+	for _, name := range c.AcknowledgeUnsafe {
+		if check, ok := interlocks[name]; ok && check(c) != "" {
+			tripped = append(tripped, name)
+		}
+	}
+	return tripped
+}
+
+// sortedInterlocks returns the interlock names in a stable order
+func sortedInterlocks() []string {
+	names := make([]string, 0, len(interlocks))
+	for name := range interlocks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redactedValue replaces secrets in exported configuration
+const redactedValue = "[REDACTED]"
+
+// sensitiveHeaders are headers whose configured values are redacted on export
+var sensitiveHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"x-api-key":           true,
+}
+
+// Redacted returns a copy of the configuration with secrets replaced, so the
+// effective configuration can be shown without leaking credentials
+func (c *Config) Redacted() *Config {
+	out := *c
+
+	out.Authorization.JWTSharedSecret = redact(c.Authorization.JWTSharedSecret)
+	out.RateLimit.RedisPassword = redact(c.RateLimit.RedisPassword)
+	out.RateLimit.Quota.RedisPassword = redact(c.RateLimit.Quota.RedisPassword)
+	out.Authorization.Enrichment.RedisPassword = redact(c.Authorization.Enrichment.RedisPassword)
+	out.Authorization.APIKeys.RedisPassword = redact(c.Authorization.APIKeys.RedisPassword)
+	out.Authorization.Revocation.RedisPassword = redact(c.Authorization.Revocation.RedisPassword)
+	out.Authorization.ReplayProtection.RedisPassword = redact(c.Authorization.ReplayProtection.RedisPassword)
+	out.Authorization.Introspection.ClientSecret = redact(c.Authorization.Introspection.ClientSecret)
+	out.Authorization.EdgeLogin.ClientSecret.Value = redact(c.Authorization.EdgeLogin.ClientSecret.Value)
+	out.Authorization.EdgeLogin.EncryptionKey.Value = redact(c.Authorization.EdgeLogin.EncryptionKey.Value)
+	out.Admin.Token = redact(c.Admin.Token)
+	out.Proxy.IdentityToken.Secret.Value = redact(c.Proxy.IdentityToken.Secret.Value)
+	out.Proxy.Metadata.Secret.Value = redact(c.Proxy.Metadata.Secret.Value)
+	out.Proxy.CircuitBreakerStore.RedisPassword = redact(c.Proxy.CircuitBreakerStore.RedisPassword)
+	out.Observability.Diagnostics.DebugToken.Value = redact(c.Observability.Diagnostics.DebugToken.Value)
+
+	if c.Authorization.Providers != nil {
+		out.Authorization.Providers = make(map[string]AuthProviderConfig, len(c.Authorization.Providers))
+		for name, provider := range c.Authorization.Providers {
+			provider.JWTSharedSecret = redact(provider.JWTSharedSecret)
+			out.Authorization.Providers[name] = provider
+		}
+	}
+
+	if c.Authorization.RequestSigning.Clients != nil {
+		out.Authorization.RequestSigning.Clients = make(map[string]SigningClientConfig, len(c.Authorization.RequestSigning.Clients))
+		for keyID, client := range c.Authorization.RequestSigning.Clients {
+			client.Secret.Value = redact(client.Secret.Value)
+			out.Authorization.RequestSigning.Clients[keyID] = client
+		}
+	}
+	if c.Authorization.Bypass.Services != nil {
+		out.Authorization.Bypass.Services = make(map[string]SecretRef, len(c.Authorization.Bypass.Services))
+		for name, secret := range c.Authorization.Bypass.Services {
+			secret.Value = redact(secret.Value)
+			out.Authorization.Bypass.Services[name] = secret
+		}
+	}
+	if c.Authorization.BasicAuth.Users != nil {
+		out.Authorization.BasicAuth.Users = make(map[string]BasicAuthUser, len(c.Authorization.BasicAuth.Users))
+		for name, user := range c.Authorization.BasicAuth.Users {
+			user.PasswordHash.Value = redact(user.PasswordHash.Value)
+			out.Authorization.BasicAuth.Users[name] = user
+		}
+	}
+
+	out.Routes = make([]RouteConfig, len(c.Routes))
+	for i, route := range c.Routes {
+		route.RequestHeaders = route.RequestHeaders.redacted()
+		route.ResponseHeaders = route.ResponseHeaders.redacted()
+		route.BackendAuth.Value.Value = redact(route.BackendAuth.Value.Value)
+		route.BackendAuth.Password.Value = redact(route.BackendAuth.Password.Value)
+		out.Routes[i] = route
+	}
+
+	return &out
+}
+
+// Export encodes the redacted configuration as yaml or json
+func (c *Config) Export(format string) ([]byte, error) {
+	redacted := c.Redacted()
+
+	switch strings.ToLower(format) {
+	case "yaml", "yml":
+		return yaml.Marshal(redacted)
+	case "json":
+		return json.MarshalIndent(redacted, "", "  ")
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s (use yaml or json)", format)
+	}
+}
+
+// Hash returns the SHA-256 of the redacted configuration, identifying the
+// configuration a gateway runs without revealing its secrets
+func (c *Config) Hash() (string, error) {
+	data, err := json.Marshal(c.Redacted())
+	if err != nil {
+		return "", fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// redact replaces a non-empty secret
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+// redacted returns a copy of the rules with values of sensitive headers replaced
+func (h HeaderRules) redacted() HeaderRules {
+	return HeaderRules{
+		Set:    redactHeaderValues(h.Set),
+		Add:    redactHeaderValues(h.Add),
+		Remove: h.Remove,
+	}
+}
+
+// redactHeaderValues copies a header map, redacting sensitive values
+func redactHeaderValues(values map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+
+	out := make(map[string]string, len(values))
+	for name, value := range values {
+		if sensitiveHeaders[strings.ToLower(name)] {
+			value = redact(value)
+		}
+		out[name] = value
+	}
+	return out
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	_ "embed"
+	"fmt"
+	"slices"
+)
+
+// CurrentSchemaVersion is the configuration schema version of this build
+const CurrentSchemaVersion = 1
+
+// SupportedSchemaVersions lists the configuration schema versions this build
+// loads, oldest first. Orchestration tooling reads them from /admin/compat
+// before pushing a configuration to a mixed-version fleet.
+var SupportedSchemaVersions = []int{1}
+
+// defaultYAML is the configuration used when no file is given
+//
+//go:embed default.yaml
+var defaultYAML []byte
+
+// DefaultYAML returns the built-in configuration
+func DefaultYAML() []byte {
+	return slices.Clone(defaultYAML)
+}
+
+// validateSchemaVersion checks that this build understands the schema
+// version of a configuration; 0 means the current version
+func validateSchemaVersion(version int) error {
+	if version == 0 || slices.Contains(SupportedSchemaVersions, version) {
+		return nil
+	}
+	return fmt.Errorf("unsupported config schema version %d (supported: %v)", version, SupportedSchemaVersions)
+}
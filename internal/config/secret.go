@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+)
+
+// secretFiles caches the secret files read so far, shared by all secret
+// references naming the same file
+var secretFiles = struct {
+	mu    sync.Mutex
+	files map[string]*secretFile
+}{files: make(map[string]*secretFile)}
+
+// secretFile is the last good content of a secret file
+type secretFile struct {
+	value   string
+	modTime time.Time
+}
+
+// Resolve returns the current value of the secret. File secrets are cached
+// and re-read whenever their modification time changes, so rotated secrets
+// apply without a restart; if a changed file cannot be read, the last good
+// value is kept. Secret values are never logged.
+func (r SecretRef) Resolve() (string, error) {
+	switch {
+	case r.Value != "":
+		return r.Value, nil
+	case r.Env != "":
+		value := os.Getenv(r.Env)
+		if value == "" {
+			return "", fmt.Errorf("environment variable %s is not set", r.Env)
+		}
+		return value, nil
+	case r.File != "":
+		return readSecretFile(r.File)
+	default:
+		return "", fmt.Errorf("no secret configured")
+	}
+}
+
+// readSecretFile returns the content of a secret file, reloading it if changed
+func readSecretFile(path string) (string, error) {
+	secretFiles.mu.Lock()
+	defer secretFiles.mu.Unlock()
+
+	cached := secretFiles.files[path]
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if cached != nil {
+			// Keep using the last good secret
+			return cached.value, nil
+		}
+		return "", fmt.Errorf("failed to read secret file %s: %w", path, err)
+	}
+	if cached != nil && info.ModTime().Equal(cached.modTime) {
+		return cached.value, nil
+	}
+
+	data, err := os.ReadFile(path)
+	value := strings.TrimRight(string(data), "\r\n")
+	if err == nil && value == "" {
+		err = fmt.Errorf("secret file is empty")
+	}
+	if err != nil {
+		if cached != nil {
+			logger.Get().WithComponent("config").Error("failed to reload secret file, keeping previous", logger.Fields{
+				"file":  path,
+				"error": err.Error(),
+			})
+			return cached.value, nil
+		}
+		return "", fmt.Errorf("failed to read secret file %s: %w", path, err)
+	}
+
+	if cached != nil {
+		logger.Get().WithComponent("config").Info("secret file reloaded", logger.Fields{
+			"file": path,
+		})
+	}
+	secretFiles.files[path] = &secretFile{value: value, modTime: info.ModTime()}
+	return value, nil
+}
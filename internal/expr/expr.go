@@ -0,0 +1,509 @@
+// Package expr implements the small boolean expression language used for
+// attribute-based access conditions, e.g. `claims.tenant == path.tenantId`.
+//
+// Expressions combine comparisons (==, !=, <, <=, >, >=, in, in_cidr) with
+// &&, || and !, which may also be written as and, or and not. Operands are
+// dotted identifiers resolved at evaluation time, string literals in single or
+// double quotes, numbers, true, false and lists such as ["GET", "HEAD"].
+// Identifiers that cannot be resolved make every comparison they take part in
+// false.
+//
+// Ordering operators compare numbers numerically and anything else as
+// strings, so `time.clock >= '09:00'` works on zero-padded times. in_cidr
+// checks an IP address against a network or a list of networks, e.g.
+// `request.client_ip in_cidr ['10.0.0.0/8', '192.168.1.10']`.
+package expr
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// Resolver looks up the value of a dotted identifier such as "claims.sub".
+// Values are strings, numbers, bools or lists as decoded from JSON.
+type Resolver interface {
+	Resolve(name string) (interface{}, bool)
+}
+
+// Expression is a compiled boolean expression
+type Expression struct {
+	source string
+	root   node
+	idents []string
+}
+
+// Compile parses an expression
+func Compile(source string) (*Expression, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+	}
+
+	return &Expression{source: source, root: root, idents: p.idents}, nil
+}
+
+// Eval evaluates the expression against the resolver
+func (e *Expression) Eval(r Resolver) bool {
+	return truthy(e.root.eval(r))
+}
+
+// Identifiers returns the identifiers referenced by the expression
+func (e *Expression) Identifiers() []string {
+	return e.idents
+}
+
+// String returns the expression source
+func (e *Expression) String() string {
+	return e.source
+}
+
+// value is the result of evaluating a node; missing marks an identifier that
+// could not be resolved
+type value struct {
+	v       interface{}
+	missing bool
+}
+
+type node interface {
+	eval(r Resolver) value
+}
+
+type literalNode struct{ v interface{} }
+
+func (n literalNode) eval(Resolver) value { return value{v: n.v} }
+
+type identNode struct{ name string }
+
+func (n identNode) eval(r Resolver) value {
+	v, ok := r.Resolve(n.name)
+	if !ok || v == nil {
+		return value{missing: true}
+	}
+	return value{v: v}
+}
+
+type listNode struct{ items []node }
+
+func (n listNode) eval(r Resolver) value {
+	items := make([]interface{}, 0, len(n.items))
+	for _, item := range n.items {
+		if v := item.eval(r); !v.missing {
+			items = append(items, v.v)
+		}
+	}
+	return value{v: items}
+}
+
+type notNode struct{ operand node }
+
+func (n notNode) eval(r Resolver) value { return value{v: !truthy(n.operand.eval(r))} }
+
+type logicalNode struct {
+	and         bool
+	left, right node
+}
+
+func (n logicalNode) eval(r Resolver) value {
+	left := truthy(n.left.eval(r))
+	if n.and {
+		return value{v: left && truthy(n.right.eval(r))}
+	}
+	return value{v: left || truthy(n.right.eval(r))}
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n compareNode) eval(r Resolver) value {
+	left, right := n.left.eval(r), n.right.eval(r)
+	if left.missing || right.missing {
+		return value{v: false}
+	}
+
+	switch n.op {
+	case "==":
+		return value{v: equal(left.v, right.v)}
+	case "!=":
+		return value{v: !equal(left.v, right.v)}
+	case "<", "<=", ">", ">=":
+		c, ok := compare(left.v, right.v)
+		if !ok {
+			return value{v: false}
+		}
+		switch n.op {
+		case "<":
+			return value{v: c < 0}
+		case "<=":
+			return value{v: c <= 0}
+		case ">":
+			return value{v: c > 0}
+		default:
+			return value{v: c >= 0}
+		}
+	case "in_cidr":
+		return value{v: inNetworks(left.v, right.v)}
+	default: // in
+		for _, item := range toList(right.v) {
+			if equal(left.v, item) {
+				return value{v: true}
+			}
+		}
+		return value{v: false}
+	}
+}
+
+// equal compares scalars by their string form, so the claim 42 equals the
+// path parameter "42". Lists are never equal to anything.
+func equal(a, b interface{}) bool {
+	as, ok := scalarString(a)
+	if !ok {
+		return false
+	}
+	bs, ok := scalarString(b)
+	return ok && as == bs
+}
+
+// compare orders two scalars, numerically if both are numbers or numeric
+// strings and as strings otherwise
+func compare(a, b interface{}) (int, bool) {
+	as, ok := scalarString(a)
+	if !ok {
+		return 0, false
+	}
+	bs, ok := scalarString(b)
+	if !ok {
+		return 0, false
+	}
+	af, aErr := strconv.ParseFloat(as, 64)
+	bf, bErr := strconv.ParseFloat(bs, 64)
+	if aErr == nil && bErr == nil {
+		switch {
+		case af < bf:
+			return -1, true
+		case af > bf:
+			return 1, true
+		}
+		return 0, true
+	}
+	return strings.Compare(as, bs), true
+}
+
+// inNetworks reports whether the address v lies in one of the networks in
+// list, which may be a single network. Bare addresses match themselves.
+func inNetworks(v, list interface{}) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	networks := toList(list)
+	if networks == nil {
+		networks = []interface{}{list}
+	}
+	for _, item := range networks {
+		network, ok := item.(string)
+		if !ok {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(network); err == nil {
+			if prefix.Contains(addr) {
+				return true
+			}
+		} else if other, err := netip.ParseAddr(network); err == nil && other.Unmap() == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func scalarString(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case bool:
+		return strconv.FormatBool(t), true
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), true
+	case int:
+		return strconv.Itoa(t), true
+	case int64:
+		return strconv.FormatInt(t, 10), true
+	}
+	return "", false
+}
+
+func toList(v interface{}) []interface{} {
+	switch t := v.(type) {
+	case []interface{}:
+		return t
+	case []string:
+		items := make([]interface{}, len(t))
+		for i, s := range t {
+			items[i] = s
+		}
+		return items
+	}
+	return nil
+}
+
+// truthy converts a value used as a condition on its own to a bool
+func truthy(v value) bool {
+	if v.missing {
+		return false
+	}
+	switch t := v.v.(type) {
+	case bool:
+		return t
+	case string:
+		return t != ""
+	case float64:
+		return t != 0
+	case int:
+		return t != 0
+	case int64:
+		return t != 0
+	}
+	return len(toList(v.v)) > 0
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// tokenize splits an expression into tokens
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '"' || c == '\'':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(source) && source[j] != c; j++ {
+				if source[j] == '\\' && j+1 < len(source) {
+					j++
+				}
+				sb.WriteByte(source[j])
+			}
+			if j >= len(source) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: sb.String(), pos: i})
+			i = j + 1
+		case strings.HasPrefix(source[i:], "==") || strings.HasPrefix(source[i:], "!=") ||
+			strings.HasPrefix(source[i:], "<=") || strings.HasPrefix(source[i:], ">=") ||
+			strings.HasPrefix(source[i:], "&&") || strings.HasPrefix(source[i:], "||"):
+			tokens = append(tokens, token{kind: tokenOp, text: source[i : i+2], pos: i})
+			i += 2
+		case strings.ContainsRune("!()[],<>", rune(c)):
+			tokens = append(tokens, token{kind: tokenOp, text: string(c), pos: i})
+			i++
+		case isIdentChar(c):
+			j := i
+			for j < len(source) && isIdentChar(source[j]) {
+				j++
+			}
+			text := source[i:j]
+			kind := tokenIdent
+			if _, err := strconv.ParseFloat(text, 64); err == nil {
+				kind = tokenNumber
+			}
+			tokens = append(tokens, token{kind: kind, text: text, pos: i})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(source)}), nil
+}
+
+// isIdentChar reports whether c may appear in an identifier. Hyphens are
+// allowed so header names like header.X-Tenant-ID can be written directly.
+func isIdentChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '.' || c == '-'
+}
+
+// parser is a recursive descent parser:
+//
+//	or      = and { ("||" | "or") and }
+//	and     = unary { ("&&" | "and") unary }
+//	unary   = ("!" | "not") unary | compare
+//	compare = operand [ ("==" | "!=" | "<" | "<=" | ">" | ">=" | "in" | "in_cidr") operand ]
+//	operand = "(" or ")" | "[" [ operand { "," operand } ] "]" | literal | identifier
+type parser struct {
+	tokens []token
+	pos    int
+	idents []string
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is one of the given operators or keywords
+func (p *parser) accept(texts ...string) bool {
+	tok := p.peek()
+	if tok.kind != tokenOp && tok.kind != tokenIdent {
+		return false
+	}
+	for _, text := range texts {
+		if tok.text == text {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		tok := p.peek()
+		return fmt.Errorf("expected %q at position %d", text, tok.pos)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||", "or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&", "and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.accept("!", "not") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *parser) parseCompare() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	op := p.peek().text
+	if !p.accept("==", "!=", "<", "<=", ">", ">=", "in", "in_cidr") {
+		return left, nil
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return compareNode{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenString:
+		return literalNode{v: tok.text}, nil
+	case tokenNumber:
+		f, _ := strconv.ParseFloat(tok.text, 64)
+		return literalNode{v: f}, nil
+	case tokenIdent:
+		switch tok.text {
+		case "true", "false":
+			return literalNode{v: tok.text == "true"}, nil
+		case "and", "or", "not", "in", "in_cidr":
+			return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+		}
+		p.idents = append(p.idents, tok.text)
+		return identNode{name: tok.text}, nil
+	case tokenOp:
+		switch tok.text {
+		case "(":
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return inner, p.expect(")")
+		case "[":
+			var items []node
+			for !p.accept("]") {
+				if len(items) > 0 {
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+				item, err := p.parseOperand()
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+			}
+			return listNode{items: items}, nil
+		}
+	case tokenEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+}
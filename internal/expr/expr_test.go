@@ -0,0 +1,112 @@
+package expr
+
+import "testing"
+
+type mapResolver map[string]interface{}
+
+func (m mapResolver) Resolve(name string) (interface{}, bool) {
+	v, ok := m[name]
+	return v, ok
+}
+
+func TestEval(t *testing.T) {
+	env := mapResolver{
+		"claims.tenant":  "acme",
+		"claims.org_id":  float64(42),
+		"claims.admin":   true,
+		"claims.groups":  []interface{}{"eng", "ops"},
+		"path.tenantId":  "acme",
+		"path.orgId":     "42",
+		"request.method": "GET",
+		"user.roles":     []string{"user", "editor"},
+		"header.X-Env":   "prod",
+		"time.hour":      float64(14),
+		"time.clock":     "14:05",
+		"client.ip":      "10.1.2.3",
+		"client.ipv6":    "::ffff:192.168.1.10",
+	}
+
+	tests := []struct {
+		name     string
+		expr     string
+		expected bool
+	}{
+		{"identifiers equal", "claims.tenant == path.tenantId", true},
+		{"number equals string", "claims.org_id == path.orgId", true},
+		{"not equal", "claims.tenant != 'other'", true},
+		{"string literal", `request.method == "GET"`, true},
+		{"in literal list", `request.method in ["GET", "HEAD"]`, true},
+		{"not in list", `request.method in ["POST"]`, false},
+		{"in claim list", "'ops' in claims.groups", true},
+		{"in string slice", "'editor' in user.roles", true},
+		{"and", "claims.admin && claims.tenant == 'acme'", true},
+		{"or", "claims.tenant == 'other' || claims.admin", true},
+		{"keywords", "not claims.admin or claims.tenant == 'acme'", true},
+		{"not", "!claims.admin", false},
+		{"parentheses", "!(claims.tenant == 'other' || request.method == 'POST')", true},
+		{"hyphenated header", "header.X-Env == 'prod'", true},
+		{"missing equals missing", "claims.missing == path.missing", false},
+		{"missing not equal", "claims.missing != 'acme'", false},
+		{"missing alone", "claims.missing", false},
+		{"bool literal", "claims.admin == true", true},
+		{"number ordering", "time.hour >= 9 && time.hour < 17", true},
+		{"numeric string ordering", "path.orgId > 9", true},
+		{"string ordering", "time.clock >= '09:00' && time.clock <= '13:30'", false},
+		{"missing ordering", "claims.missing < 5", false},
+		{"in cidr", "client.ip in_cidr ['192.168.0.0/16', '10.0.0.0/8']", true},
+		{"single cidr", "client.ip in_cidr '10.1.0.0/16'", true},
+		{"not in cidr", "client.ip in_cidr ['192.168.0.0/16']", false},
+		{"mapped address", "client.ipv6 in_cidr ['192.168.1.10']", true},
+		{"invalid address", "claims.tenant in_cidr ['10.0.0.0/8']", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("Compile(%q) failed: %v", tt.expr, err)
+			}
+			if got := e.Eval(env); got != tt.expected {
+				t.Errorf("Eval(%q) = %v, want %v", tt.expr, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"claims.tenant ==",
+		"claims.tenant == 'acme",
+		"(claims.admin",
+		"claims.tenant = 'acme'",
+		"claims.a claims.b",
+		"[1, 2",
+		"time.hour <",
+		"time.hour => 9",
+	}
+
+	for _, source := range tests {
+		if _, err := Compile(source); err == nil {
+			t.Errorf("Compile(%q) expected error", source)
+		}
+	}
+}
+
+func TestIdentifiers(t *testing.T) {
+	e, err := Compile("claims.tenant == path.tenantId && request.method in ['GET']")
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	expected := []string{"claims.tenant", "path.tenantId", "request.method"}
+	got := e.Identifiers()
+	if len(got) != len(expected) {
+		t.Fatalf("Identifiers() = %v, want %v", got, expected)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Identifiers() = %v, want %v", got, expected)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Subject is what a requirement is checked against: the scopes, roles and
+// permissions of an authenticated user
+type Subject interface {
+	HasScope(scope string) bool
+	HasRole(role string) bool
+	HasPermission(permission string) bool
+}
+
+// requirementKinds maps the prefixes of requirement terms to their kind
+var requirementKinds = map[string]string{
+	"scope":      "scope",
+	"role":       "role",
+	"permission": "permission",
+	"perm":       "permission",
+}
+
+// Requirement is a compiled auth requirement such as
+// `scope:read:orders AND (role:manager OR permission:orders:**)`.
+//
+// Terms are kind:value pairs where kind is scope, role or permission (perm);
+// everything after the first colon is the value. Terms combine with AND, OR
+// and NOT (case-insensitive, or &&, || and !) and parentheses; AND binds
+// tighter than OR.
+type Requirement struct {
+	source string
+	root   requirementNode
+}
+
+// CompileRequirement parses a requirement expression
+func CompileRequirement(source string) (*Requirement, error) {
+	tokens, err := tokenizeRequirement(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &requirementParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+	}
+	return &Requirement{source: source, root: root}, nil
+}
+
+// Eval reports whether subject meets the requirement
+func (r *Requirement) Eval(subject Subject) bool {
+	return r.root.eval(subject)
+}
+
+// String returns the requirement source
+func (r *Requirement) String() string {
+	return r.source
+}
+
+type requirementNode interface {
+	eval(s Subject) bool
+}
+
+type termNode struct{ kind, value string }
+
+func (n termNode) eval(s Subject) bool {
+	switch n.kind {
+	case "scope":
+		return s.HasScope(n.value)
+	case "role":
+		return s.HasRole(n.value)
+	default:
+		return s.HasPermission(n.value)
+	}
+}
+
+type requirementNot struct{ operand requirementNode }
+
+func (n requirementNot) eval(s Subject) bool { return !n.operand.eval(s) }
+
+type requirementLogical struct {
+	and         bool
+	left, right requirementNode
+}
+
+func (n requirementLogical) eval(s Subject) bool {
+	if n.and {
+		return n.left.eval(s) && n.right.eval(s)
+	}
+	return n.left.eval(s) || n.right.eval(s)
+}
+
+// tokenizeRequirement splits a requirement into operators and words
+func tokenizeRequirement(source string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case strings.HasPrefix(source[i:], "&&") || strings.HasPrefix(source[i:], "||"):
+			tokens = append(tokens, token{kind: tokenOp, text: source[i : i+2], pos: i})
+			i += 2
+		case c == '(' || c == ')' || c == '!':
+			tokens = append(tokens, token{kind: tokenOp, text: string(c), pos: i})
+			i++
+		case c == '&' || c == '|':
+			return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+		default:
+			j := i
+			for j < len(source) && !strings.ContainsRune(" \t\n()!&|", rune(source[j])) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: source[i:j], pos: i})
+			i = j
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(source)}), nil
+}
+
+// requirementParser is a recursive descent parser:
+//
+//	or    = and { ("OR" | "||") and }
+//	and   = unary { ("AND" | "&&") unary }
+//	unary = ("NOT" | "!") unary | "(" or ")" | term
+type requirementParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *requirementParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// accept consumes the next token if it is one of the given operators or
+// keywords, ignoring case
+func (p *requirementParser) accept(texts ...string) bool {
+	tok := p.peek()
+	if tok.kind == tokenEOF {
+		return false
+	}
+	for _, text := range texts {
+		if strings.EqualFold(tok.text, text) {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *requirementParser) parseOr() (requirementNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||", "or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = requirementLogical{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *requirementParser) parseAnd() (requirementNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&", "and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = requirementLogical{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *requirementParser) parseUnary() (requirementNode, error) {
+	if p.accept("!", "not") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return requirementNot{operand: operand}, nil
+	}
+	if p.accept("(") {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("expected \")\" at position %d", p.peek().pos)
+		}
+		return inner, nil
+	}
+	return p.parseTerm()
+}
+
+func (p *requirementParser) parseTerm() (requirementNode, error) {
+	tok := p.peek()
+	if tok.kind != tokenIdent {
+		if tok.kind == tokenEOF {
+			return nil, fmt.Errorf("unexpected end of expression")
+		}
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+	}
+	p.pos++
+
+	prefix, value, ok := strings.Cut(tok.text, ":")
+	kind, known := requirementKinds[strings.ToLower(prefix)]
+	if !ok || !known || value == "" {
+		return nil, fmt.Errorf("invalid term %q at position %d (expected scope:, role: or permission:)", tok.text, tok.pos)
+	}
+	return termNode{kind: kind, value: value}, nil
+}
@@ -0,0 +1,73 @@
+package expr
+
+import (
+	"slices"
+	"testing"
+)
+
+type testSubject struct {
+	scopes, roles, permissions []string
+}
+
+func (s testSubject) HasScope(scope string) bool     { return slices.Contains(s.scopes, scope) }
+func (s testSubject) HasRole(role string) bool       { return slices.Contains(s.roles, role) }
+func (s testSubject) HasPermission(perm string) bool { return slices.Contains(s.permissions, perm) }
+
+func TestRequirementEval(t *testing.T) {
+	subject := testSubject{
+		scopes:      []string{"read:orders", "openid"},
+		roles:       []string{"manager"},
+		permissions: []string{"orders:write"},
+	}
+
+	tests := []struct {
+		name     string
+		expr     string
+		expected bool
+	}{
+		{"scope", "scope:read:orders", true},
+		{"missing scope", "scope:write:orders", false},
+		{"and", "scope:read:orders AND role:manager", true},
+		{"and fails", "scope:read:orders AND role:admin", false},
+		{"or", "role:admin OR permission:orders:write", true},
+		{"perm alias", "perm:orders:write", true},
+		{"not", "NOT role:admin", true},
+		{"lowercase keywords", "scope:openid and not role:admin", true},
+		{"symbols", "scope:openid && (role:admin || !role:guest)", true},
+		{"and binds tighter", "role:admin AND scope:openid OR role:manager", true},
+		{"parentheses", "role:admin AND (scope:openid OR role:manager)", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := CompileRequirement(tt.expr)
+			if err != nil {
+				t.Fatalf("CompileRequirement(%q) error = %v", tt.expr, err)
+			}
+			if got := req.Eval(subject); got != tt.expected {
+				t.Errorf("Eval(%q) = %v, want %v", tt.expr, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCompileRequirementErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"manager",
+		"group:admins",
+		"scope:",
+		"role:admin AND",
+		"(role:admin",
+		"role:admin)",
+		"role:admin & scope:openid",
+	}
+
+	for _, source := range tests {
+		t.Run(source, func(t *testing.T) {
+			if _, err := CompileRequirement(source); err == nil {
+				t.Errorf("CompileRequirement(%q) expected error", source)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+)
+
+// Certificate target kinds
+const (
+	CertKindServer  = "server"  // the gateway's listener certificate
+	CertKindClient  = "client"  // a client certificate for upstream mTLS
+	CertKindBackend = "backend" // the certificate an https backend presents
+)
+
+// CertTarget is a certificate to monitor: either a PEM file or the
+// certificate a TLS server presents at Addr
+type CertTarget struct {
+	Kind       string
+	File       string
+	Addr       string // host:port
+	ServerName string
+}
+
+// Name identifies the target in metrics and health output
+func (t CertTarget) Name() string {
+	if t.File != "" {
+		return t.File
+	}
+	if host, _, err := net.SplitHostPort(t.Addr); err == nil && t.ServerName != "" && t.ServerName != host {
+		return t.Addr + " (" + t.ServerName + ")"
+	}
+	return t.Addr
+}
+
+// certStatus is the outcome of the last inspection of a target
+type certStatus struct {
+	notAfter time.Time
+	err      error
+}
+
+// CertMonitor periodically inspects TLS certificates and reports those
+// expiring within a threshold, so expiring internal certificates are
+// replaced before backends start failing handshakes
+type CertMonitor struct {
+	targets   []CertTarget
+	interval  time.Duration
+	threshold time.Duration
+	timeout   time.Duration
+	logger    *logger.ComponentLogger
+	now       func() time.Time
+
+	mu     sync.RWMutex
+	status map[string]certStatus
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewCertMonitor creates a monitor for targets. Certificates expiring within
+// threshold mark the tls_certificates check degraded.
+func NewCertMonitor(targets []CertTarget, interval, threshold, timeout time.Duration) *CertMonitor {
+	return &CertMonitor{
+		targets:   targets,
+		interval:  interval,
+		threshold: threshold,
+		timeout:   timeout,
+		logger:    logger.Get().WithComponent("health.certexpiry"),
+		now:       time.Now,
+		status:    make(map[string]certStatus),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start inspects all targets and keeps re-inspecting them every interval
+// until Stop is called
+func (m *CertMonitor) Start() {
+	go func() {
+		defer close(m.done)
+
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			m.CheckNow()
+			select {
+			case <-ticker.C:
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background inspection
+func (m *CertMonitor) Stop() {
+	close(m.stop)
+	<-m.done
+}
+
+// CheckNow inspects all targets once
+func (m *CertMonitor) CheckNow() {
+	for _, target := range m.targets {
+		notAfter, err := m.inspect(target)
+		if err != nil {
+			m.logger.Warn("failed to inspect TLS certificate", logger.Fields{
+				"target": target.Name(),
+				"kind":   target.Kind,
+				"error":  err.Error(),
+			})
+		} else {
+			days := notAfter.Sub(m.now()).Hours() / 24
+			metrics.SetTLSCertExpiryDays(target.Name(), target.Kind, days)
+			if notAfter.Sub(m.now()) < m.threshold {
+				m.logger.Warn("TLS certificate expires soon", logger.Fields{
+					"target":    target.Name(),
+					"kind":      target.Kind,
+					"not_after": notAfter.UTC().Format(time.RFC3339),
+				})
+			}
+		}
+
+		m.mu.Lock()
+		m.status[target.Name()] = certStatus{notAfter: notAfter, err: err}
+		m.mu.Unlock()
+	}
+}
+
+// inspect returns the expiry of the target's leaf certificate
+func (m *CertMonitor) inspect(target CertTarget) (time.Time, error) {
+	if target.File != "" {
+		return certFileExpiry(target.File)
+	}
+	return m.peerExpiry(target)
+}
+
+// peerExpiry connects to the target and returns the expiry of the
+// certificate it presents. The certificate is captured before verification,
+// so expired or untrusted certificates are still reported and backends
+// requiring client certificates can be inspected without one.
+func (m *CertMonitor) peerExpiry(target CertTarget) (time.Time, error) {
+	var leaf *x509.Certificate
+	tlsConfig := &tls.Config{
+		ServerName:         target.ServerName,
+		InsecureSkipVerify: true, // only the expiry is read, nothing is trusted
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) > 0 {
+				leaf = state.PeerCertificates[0]
+			}
+			return nil
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	dialer := &tls.Dialer{NetDialer: &net.Dialer{}, Config: tlsConfig}
+	conn, err := dialer.DialContext(ctx, "tcp", target.Addr)
+	if conn != nil {
+		_ = conn.Close()
+	}
+	if leaf != nil {
+		return leaf.NotAfter, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Time{}, fmt.Errorf("no certificate presented")
+}
+
+// certFileExpiry returns the expiry of the first certificate in a PEM file
+func certFileExpiry(path string) (time.Time, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return time.Time{}, fmt.Errorf("no certificate found in %s", path)
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid certificate in %s: %w", path, err)
+		}
+		return cert.NotAfter, nil
+	}
+}
+
+// Checker reports the tls_certificates check: degraded while any
+// certificate expires within the threshold or has expired
+func (m *CertMonitor) Checker() Checker {
+	return func() Check {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+
+		now := m.now()
+		var expiring []string
+		for name, status := range m.status {
+			if status.err != nil {
+				continue
+			}
+			if status.notAfter.Sub(now) < m.threshold {
+				expiring = append(expiring, fmt.Sprintf("%s expires %s", name, status.notAfter.UTC().Format(time.RFC3339)))
+			}
+		}
+		if len(expiring) == 0 {
+			return Check{Name: "tls_certificates", Status: StatusHealthy}
+		}
+
+		sort.Strings(expiring)
+		return Check{
+			Name:   "tls_certificates",
+			Status: StatusDegraded,
+			Error:  "certificates expiring soon: " + strings.Join(expiring, "; "),
+		}
+	}
+}
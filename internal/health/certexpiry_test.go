@@ -0,0 +1,93 @@
+package health
+
+import (
+	"bytes"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+)
+
+func TestCertMonitor(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	leaf := server.Certificate()
+	certFile := filepath.Join(t.TempDir(), "tls.crt")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw}), 0600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+
+	targets := []CertTarget{
+		{Kind: CertKindServer, File: certFile},
+		{Kind: CertKindBackend, Addr: strings.TrimPrefix(server.URL, "https://"), ServerName: "example.com"},
+	}
+
+	t.Run("Healthy", func(t *testing.T) {
+		monitor := NewCertMonitor(targets, time.Hour, 24*time.Hour, time.Second)
+		monitor.CheckNow()
+
+		for _, target := range targets {
+			status := monitor.status[target.Name()]
+			if status.err != nil {
+				t.Fatalf("Failed to inspect %s: %v", target.Name(), status.err)
+			}
+			if !status.notAfter.Equal(leaf.NotAfter) {
+				t.Errorf("Expected %s to expire %v, got %v", target.Name(), leaf.NotAfter, status.notAfter)
+			}
+		}
+		if check := monitor.Checker()(); check.Status != StatusHealthy {
+			t.Errorf("Expected healthy, got: %+v", check)
+		}
+	})
+
+	t.Run("ExpiringWithinThreshold", func(t *testing.T) {
+		monitor := NewCertMonitor(targets, time.Hour, 24*time.Hour, time.Second)
+		monitor.CheckNow()
+		monitor.now = func() time.Time { return leaf.NotAfter.Add(-time.Hour) }
+
+		check := monitor.Checker()()
+		if check.Status != StatusDegraded {
+			t.Fatalf("Expected degraded, got: %+v", check)
+		}
+		if !strings.Contains(check.Error, certFile) || !strings.Contains(check.Error, "(example.com)") {
+			t.Errorf("Expected both certificates to be reported, got: %s", check.Error)
+		}
+	})
+
+	t.Run("UnreachableTargetIsNotReported", func(t *testing.T) {
+		monitor := NewCertMonitor([]CertTarget{{Kind: CertKindBackend, Addr: "127.0.0.1:1"}}, time.Hour, 24*time.Hour, time.Second)
+		monitor.CheckNow()
+
+		if monitor.status["127.0.0.1:1"].err == nil {
+			t.Error("Expected inspection error")
+		}
+		if check := monitor.Checker()(); check.Status != StatusHealthy {
+			t.Errorf("Expected healthy, got: %+v", check)
+		}
+	})
+}
+
+func TestReadinessIgnoresAdvisoryChecks(t *testing.T) {
+	m := NewManager()
+	m.RegisterAdvisory("tls_certificates", func() Check {
+		return Check{Name: "tls_certificates", Status: StatusDegraded}
+	})
+
+	rr := httptest.NewRecorder()
+	m.ReadinessHandler().ServeHTTP(rr, httptest.NewRequest("GET", "/_health/ready", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected readiness 200, got %d", rr.Code)
+	}
+	if status := m.Check().Status; status != StatusDegraded {
+		t.Errorf("Expected health degraded, got %s", status)
+	}
+}
@@ -36,14 +36,16 @@ type Checker func() Check
 
 // Manager manages health checks
 type Manager struct {
-	checks map[string]Checker
-	mu     sync.RWMutex
+	checks   map[string]Checker
+	advisory map[string]bool
+	mu       sync.RWMutex
 }
 
 // NewManager creates a new health check manager
 func NewManager() *Manager {
 	return &Manager{
-		checks: make(map[string]Checker),
+		checks:   make(map[string]Checker),
+		advisory: make(map[string]bool),
 	}
 }
 
@@ -52,6 +54,17 @@ func (m *Manager) Register(name string, checker Checker) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.checks[name] = checker
+	delete(m.advisory, name)
+}
+
+// RegisterAdvisory registers a health check that is reported by the health
+// endpoint but does not affect readiness, for problems that taking the
+// gateway out of rotation would not fix
+func (m *Manager) RegisterAdvisory(name string, checker Checker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checks[name] = checker
+	m.advisory[name] = true
 }
 
 // Unregister removes a health check
@@ -59,10 +72,16 @@ func (m *Manager) Unregister(name string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	delete(m.checks, name)
+	delete(m.advisory, name)
 }
 
 // Check runs all health checks
 func (m *Manager) Check() Response {
+	return m.run(true)
+}
+
+// run runs the health checks, skipping advisory ones unless includeAdvisory
+func (m *Manager) run(includeAdvisory bool) Response {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
@@ -70,6 +89,9 @@ func (m *Manager) Check() Response {
 	overallStatus := StatusHealthy
 
 	for name, checker := range m.checks {
+		if m.advisory[name] && !includeAdvisory {
+			continue
+		}
 		check := checker()
 		checks[name] = check
 
@@ -108,7 +130,7 @@ func (m *Manager) LivenessHandler() http.HandlerFunc {
 // Readiness indicates if the application is ready to serve traffic
 func (m *Manager) ReadinessHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		response := m.Check()
+		response := m.run(false)
 
 		w.Header().Set("Content-Type", "application/json")
 
@@ -208,16 +230,28 @@ type Pinger interface {
 	Ping(ctx context.Context) error
 }
 
-// RateLimiterChecker checks rate limiter storage connectivity
+// Degrader is implemented by components that keep working in a degraded
+// mode while the dependency they ping is unavailable
+type Degrader interface {
+	Degraded() bool
+}
+
+// RateLimiterChecker checks rate limiter storage connectivity. A limiter
+// counting requests locally while its storage is unreachable is degraded
+// rather than unhealthy.
 func RateLimiterChecker(limiter Pinger) Checker {
 	return func() Check {
 		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 		defer cancel()
 
 		if err := limiter.Ping(ctx); err != nil {
+			status := StatusUnhealthy
+			if d, ok := limiter.(Degrader); ok && d.Degraded() {
+				status = StatusDegraded
+			}
 			return Check{
 				Name:   "ratelimit",
-				Status: StatusUnhealthy,
+				Status: status,
 				Error:  err.Error(),
 			}
 		}
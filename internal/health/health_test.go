@@ -430,6 +430,15 @@ func (m *mockPinger) Ping(ctx context.Context) error {
 	return nil
 }
 
+type degradedPinger struct {
+	mockPinger
+	degraded bool
+}
+
+func (d *degradedPinger) Degraded() bool {
+	return d.degraded
+}
+
 func TestRateLimiterChecker(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -449,6 +458,18 @@ func TestRateLimiterChecker(t *testing.T) {
 			expectedStatus: StatusUnhealthy,
 			expectError:    true,
 		},
+		{
+			name:           "Failed ping while degraded",
+			pinger:         &degradedPinger{mockPinger: mockPinger{shouldFail: true}, degraded: true},
+			expectedStatus: StatusDegraded,
+			expectError:    true,
+		},
+		{
+			name:           "Failed ping before degrading",
+			pinger:         &degradedPinger{mockPinger: mockPinger{shouldFail: true}},
+			expectedStatus: StatusUnhealthy,
+			expectError:    true,
+		},
 	}
 
 	for _, tt := range tests {
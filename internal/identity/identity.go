@@ -0,0 +1,100 @@
+// Package identity extracts the attributes identifying the caller of a
+// request: the client address resolved from trusted proxies and the claims
+// and attributes of the authenticated user. Rate limiting, logging, caching
+// and header templates all read them from here so they agree on who a
+// caller is.
+package identity
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/maltehedderich/api-gateway-go/internal/auth"
+	"github.com/maltehedderich/api-gateway-go/internal/clientip"
+)
+
+// ClientIP returns the normalized address of the client. Forwarding headers
+// are only honored from trusted proxies; requests that did not pass the
+// clientip middleware use the connection address.
+func ClientIP(r *http.Request) string {
+	return clientip.FromRequest(r)
+}
+
+// Service returns the identity of the calling service for requests on the
+// internal listener, or "" otherwise
+func Service(r *http.Request) string {
+	return auth.GetServiceIdentity(r.Context())
+}
+
+// UserID returns the ID of the authenticated user, or "" for anonymous
+// requests
+func UserID(r *http.Request) string {
+	return Claim(r, "user_id")
+}
+
+// SessionID returns the session of the authenticated user, or "" if the
+// credential carries none
+func SessionID(r *http.Request) string {
+	return Claim(r, "session_id")
+}
+
+// Claim returns a claim of the authenticated user, or "" if unavailable.
+// Lists such as roles are joined with commas.
+func Claim(r *http.Request, name string) string {
+	user, ok := auth.GetUserContext(r.Context())
+	if !ok || user == nil {
+		return ""
+	}
+
+	switch name {
+	case "user_id":
+		return user.UserID
+	case "session_id":
+		return user.SessionID
+	case "roles":
+		return strings.Join(user.Roles, ",")
+	case "permissions":
+		return strings.Join(user.Permissions, ",")
+	}
+
+	if user.Claims == nil {
+		return ""
+	}
+
+	switch name {
+	case "sub":
+		return user.Claims.Subject
+	case "iss":
+		return user.Claims.Issuer
+	case "jti":
+		return user.Claims.ID
+	case "aud":
+		return strings.Join(user.Claims.Audience, ",")
+	}
+
+	return ""
+}
+
+// Attribute returns an enriched user attribute, or "" if unavailable
+func Attribute(r *http.Request, name string) string {
+	user, ok := auth.GetUserContext(r.Context())
+	if !ok || user == nil {
+		return ""
+	}
+
+	switch value := user.Attributes[name].(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case []interface{}:
+		parts := make([]string, 0, len(value))
+		for _, item := range value {
+			parts = append(parts, fmt.Sprint(item))
+		}
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,68 @@
+package identity
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/maltehedderich/api-gateway-go/internal/auth"
+	"github.com/maltehedderich/api-gateway-go/internal/clientip"
+)
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "[2001:DB8::1]:4000"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	if ip := ClientIP(req); ip != "2001:db8::1" {
+		t.Errorf("expected normalized connection address, got %s", ip)
+	}
+
+	req = req.WithContext(clientip.WithInfo(req.Context(), clientip.Info{IP: "198.51.100.1"}))
+	if ip := ClientIP(req); ip != "198.51.100.1" {
+		t.Errorf("expected resolved address, got %s", ip)
+	}
+}
+
+func TestClaimAndAttribute(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	if UserID(req) != "" || Claim(req, "sub") != "" || Attribute(req, "tenant") != "" {
+		t.Fatal("expected empty values for anonymous request")
+	}
+
+	user := &auth.UserContext{
+		UserID: "user123",
+		Roles:  []string{"user", "admin"},
+		Claims: &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{
+			Subject:  "subject-1",
+			Audience: jwt.ClaimStrings{"api", "web"},
+		}},
+		Attributes: map[string]interface{}{
+			"tenant":  "acme",
+			"regions": []interface{}{"eu", "us"},
+			"tier":    2,
+		},
+	}
+	req = req.WithContext(auth.SetUserContext(req.Context(), user))
+
+	tests := []struct {
+		name     string
+		got      string
+		expected string
+	}{
+		{"user id", UserID(req), "user123"},
+		{"roles", Claim(req, "roles"), "user,admin"},
+		{"subject", Claim(req, "sub"), "subject-1"},
+		{"audience", Claim(req, "aud"), "api,web"},
+		{"unknown claim", Claim(req, "unknown"), ""},
+		{"string attribute", Attribute(req, "tenant"), "acme"},
+		{"list attribute", Attribute(req, "regions"), "eu,us"},
+		{"number attribute", Attribute(req, "tier"), "2"},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, tt.got)
+		}
+	}
+}
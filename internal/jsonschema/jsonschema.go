@@ -0,0 +1,758 @@
+// Package jsonschema validates JSON documents against JSON Schema.
+//
+// It implements the validation vocabulary shared by JSON Schema drafts 4
+// to 2020-12 and the OpenAPI schema object: type (with OpenAPI's nullable),
+// enum, const, properties, required, additionalProperties, patternProperties,
+// items, prefixItems, minItems, maxItems, uniqueItems, minLength, maxLength,
+// pattern, format, minimum, maximum, exclusiveMinimum, exclusiveMaximum (as
+// a number or a draft 4 boolean), multipleOf, minProperties, maxProperties,
+// allOf, anyOf, oneOf and not. References are limited to JSON pointers into
+// the same document, e.g. "#/$defs/address". Unknown keywords and formats
+// are ignored.
+//
+// Documents are values as decoded by encoding/json, preferably with
+// UseNumber so that large integers keep their precision.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxErrors caps the violations reported for a single document
+const maxErrors = 25
+
+// Schema is a compiled JSON Schema
+type Schema struct {
+	root *node
+}
+
+// ValidationError describes a value violating the schema
+type ValidationError struct {
+	Pointer string `json:"pointer"` // JSON pointer to the value, "" for the document
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	if e.Pointer == "" {
+		return e.Message
+	}
+	return e.Pointer + ": " + e.Message
+}
+
+// node is a compiled schema or subschema
+type node struct {
+	always *bool // boolean schema
+
+	types    []string
+	nullable bool
+	enum     []interface{}
+	constant interface{}
+	hasConst bool
+
+	properties           map[string]*node
+	required             []string
+	additionalProperties *node
+	patternProperties    []patternNode
+	minProperties        int
+	maxProperties        int
+
+	items       *node
+	prefixItems []*node
+	minItems    int
+	maxItems    int
+	uniqueItems bool
+
+	minLength int
+	maxLength int
+	pattern   *regexp.Regexp
+	format    string
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+	multipleOf       float64
+
+	allOf []*node
+	anyOf []*node
+	oneOf []*node
+	not   *node
+
+	ref *node
+}
+
+type patternNode struct {
+	pattern *regexp.Regexp
+	schema  *node
+}
+
+// compiler compiles a schema document, resolving references lazily so that
+// recursive schemas terminate
+type compiler struct {
+	doc  interface{}
+	refs map[string]*node
+}
+
+// Compile compiles a schema given as a decoded JSON or YAML value
+func Compile(schema interface{}) (*Schema, error) {
+	doc, err := normalize(schema)
+	if err != nil {
+		return nil, err
+	}
+	c := &compiler{doc: doc, refs: make(map[string]*node)}
+	root, err := c.compile(doc, "#")
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{root: root}, nil
+}
+
+// normalize converts a decoded value into the form produced by
+// encoding/json with UseNumber
+func normalize(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return doc, nil
+}
+
+func (c *compiler) compile(value interface{}, location string) (*node, error) {
+	if b, ok := value.(bool); ok {
+		return &node{always: &b}, nil
+	}
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: schema must be an object or a boolean", location)
+	}
+
+	n := &node{minProperties: -1, maxProperties: -1, minItems: -1, maxItems: -1, minLength: -1, maxLength: -1}
+	var err error
+
+	if ref, ok := obj["$ref"].(string); ok {
+		if n.ref, err = c.resolve(ref); err != nil {
+			return nil, fmt.Errorf("%s: %w", location, err)
+		}
+	}
+
+	switch t := obj["type"].(type) {
+	case nil:
+	case string:
+		n.types = []string{t}
+	case []interface{}:
+		for _, item := range t {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s/type: must be a string or an array of strings", location)
+			}
+			n.types = append(n.types, name)
+		}
+	default:
+		return nil, fmt.Errorf("%s/type: must be a string or an array of strings", location)
+	}
+	for _, name := range n.types {
+		switch name {
+		case "null", "boolean", "object", "array", "number", "integer", "string":
+		default:
+			return nil, fmt.Errorf("%s/type: unknown type %q", location, name)
+		}
+	}
+	n.nullable, _ = obj["nullable"].(bool)
+
+	if enum, ok := obj["enum"]; ok {
+		if n.enum, ok = enum.([]interface{}); !ok {
+			return nil, fmt.Errorf("%s/enum: must be an array", location)
+		}
+	}
+	n.constant, n.hasConst = obj["const"]
+
+	if props, ok := obj["properties"]; ok {
+		propObj, ok := props.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s/properties: must be an object", location)
+		}
+		n.properties = make(map[string]*node, len(propObj))
+		for name, sub := range propObj {
+			if n.properties[name], err = c.compile(sub, location+"/properties/"+escape(name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if required, ok := obj["required"]; ok {
+		list, ok := required.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s/required: must be an array of strings", location)
+		}
+		for _, item := range list {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s/required: must be an array of strings", location)
+			}
+			n.required = append(n.required, name)
+		}
+	}
+	if additional, ok := obj["additionalProperties"]; ok {
+		if n.additionalProperties, err = c.compile(additional, location+"/additionalProperties"); err != nil {
+			return nil, err
+		}
+	}
+	if patterns, ok := obj["patternProperties"]; ok {
+		patternObj, ok := patterns.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s/patternProperties: must be an object", location)
+		}
+		keys := make([]string, 0, len(patternObj))
+		for key := range patternObj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			re, err := regexp.Compile(key)
+			if err != nil {
+				return nil, fmt.Errorf("%s/patternProperties: invalid pattern %q: %w", location, key, err)
+			}
+			sub, err := c.compile(patternObj[key], location+"/patternProperties/"+escape(key))
+			if err != nil {
+				return nil, err
+			}
+			n.patternProperties = append(n.patternProperties, patternNode{pattern: re, schema: sub})
+		}
+	}
+
+	switch items := obj["items"].(type) {
+	case nil:
+	case []interface{}:
+		// Draft 4 to 2019-09 tuple form
+		if n.prefixItems, err = c.compileList(items, location+"/items"); err != nil {
+			return nil, err
+		}
+	default:
+		if n.items, err = c.compile(items, location+"/items"); err != nil {
+			return nil, err
+		}
+	}
+	if prefix, ok := obj["prefixItems"]; ok {
+		list, ok := prefix.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s/prefixItems: must be an array", location)
+		}
+		if n.prefixItems, err = c.compileList(list, location+"/prefixItems"); err != nil {
+			return nil, err
+		}
+	}
+	n.uniqueItems, _ = obj["uniqueItems"].(bool)
+
+	for keyword, target := range map[string]*int{
+		"minProperties": &n.minProperties,
+		"maxProperties": &n.maxProperties,
+		"minItems":      &n.minItems,
+		"maxItems":      &n.maxItems,
+		"minLength":     &n.minLength,
+		"maxLength":     &n.maxLength,
+	} {
+		if value, ok := obj[keyword]; ok {
+			count, ok := nonNegativeInt(value)
+			if !ok {
+				return nil, fmt.Errorf("%s/%s: must be a non-negative integer", location, keyword)
+			}
+			*target = count
+		}
+	}
+
+	if pattern, ok := obj["pattern"]; ok {
+		source, ok := pattern.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s/pattern: must be a string", location)
+		}
+		if n.pattern, err = regexp.Compile(source); err != nil {
+			return nil, fmt.Errorf("%s/pattern: %w", location, err)
+		}
+	}
+	n.format, _ = obj["format"].(string)
+
+	for keyword, target := range map[string]**float64{
+		"minimum": &n.minimum,
+		"maximum": &n.maximum,
+	} {
+		if value, ok := obj[keyword]; ok {
+			f, ok := number(value)
+			if !ok {
+				return nil, fmt.Errorf("%s/%s: must be a number", location, keyword)
+			}
+			*target = &f
+		}
+	}
+	for keyword, bound := range map[string]struct {
+		target    **float64
+		inclusive **float64
+	}{
+		"exclusiveMinimum": {&n.exclusiveMinimum, &n.minimum},
+		"exclusiveMaximum": {&n.exclusiveMaximum, &n.maximum},
+	} {
+		switch value := obj[keyword].(type) {
+		case nil:
+		case bool:
+			// Draft 4 and OpenAPI 3.0 make the inclusive bound exclusive
+			if value && *bound.inclusive != nil {
+				*bound.target, *bound.inclusive = *bound.inclusive, nil
+			}
+		default:
+			f, ok := number(value)
+			if !ok {
+				return nil, fmt.Errorf("%s/%s: must be a number or a boolean", location, keyword)
+			}
+			*bound.target = &f
+		}
+	}
+	if value, ok := obj["multipleOf"]; ok {
+		f, ok := number(value)
+		if !ok || f <= 0 {
+			return nil, fmt.Errorf("%s/multipleOf: must be a positive number", location)
+		}
+		n.multipleOf = f
+	}
+
+	for keyword, target := range map[string]*[]*node{
+		"allOf": &n.allOf,
+		"anyOf": &n.anyOf,
+		"oneOf": &n.oneOf,
+	} {
+		if value, ok := obj[keyword]; ok {
+			list, ok := value.([]interface{})
+			if !ok || len(list) == 0 {
+				return nil, fmt.Errorf("%s/%s: must be a non-empty array", location, keyword)
+			}
+			if *target, err = c.compileList(list, location+"/"+keyword); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if not, ok := obj["not"]; ok {
+		if n.not, err = c.compile(not, location+"/not"); err != nil {
+			return nil, err
+		}
+	}
+
+	return n, nil
+}
+
+func (c *compiler) compileList(list []interface{}, location string) ([]*node, error) {
+	nodes := make([]*node, 0, len(list))
+	for i, item := range list {
+		sub, err := c.compile(item, location+"/"+strconv.Itoa(i))
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, sub)
+	}
+	return nodes, nil
+}
+
+// resolve compiles the schema a local reference points to
+func (c *compiler) resolve(ref string) (*node, error) {
+	if n, ok := c.refs[ref]; ok {
+		return n, nil
+	}
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("unsupported reference %q: only references within the schema are supported", ref)
+	}
+
+	target := c.doc
+	if pointer := strings.TrimPrefix(ref, "#"); pointer != "" {
+		if !strings.HasPrefix(pointer, "/") {
+			return nil, fmt.Errorf("unsupported reference %q: only JSON pointers are supported", ref)
+		}
+		for _, token := range strings.Split(pointer[1:], "/") {
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+			switch current := target.(type) {
+			case map[string]interface{}:
+				next, ok := current[token]
+				if !ok {
+					return nil, fmt.Errorf("unresolvable reference %q", ref)
+				}
+				target = next
+			case []interface{}:
+				i, err := strconv.Atoi(token)
+				if err != nil || i < 0 || i >= len(current) {
+					return nil, fmt.Errorf("unresolvable reference %q", ref)
+				}
+				target = current[i]
+			default:
+				return nil, fmt.Errorf("unresolvable reference %q", ref)
+			}
+		}
+	}
+
+	// Register the node before compiling it so recursive references resolve
+	n := &node{}
+	c.refs[ref] = n
+	compiled, err := c.compile(target, ref)
+	if err != nil {
+		return nil, err
+	}
+	*n = *compiled
+	return n, nil
+}
+
+// Validate validates a decoded JSON document and returns its violations
+func (s *Schema) Validate(doc interface{}) []ValidationError {
+	v := &validator{}
+	v.validate(s.root, doc, "")
+	return v.errors
+}
+
+// Valid reports whether the document matches the schema
+func (s *Schema) Valid(doc interface{}) bool {
+	return (&validator{stopOnFirst: true}).matches(s.root, doc)
+}
+
+type validator struct {
+	errors      []ValidationError
+	stopOnFirst bool
+}
+
+func (v *validator) fail(pointer, format string, args ...interface{}) {
+	if len(v.errors) < maxErrors {
+		v.errors = append(v.errors, ValidationError{Pointer: pointer, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+func (v *validator) done() bool {
+	return len(v.errors) >= maxErrors || (v.stopOnFirst && len(v.errors) > 0)
+}
+
+// matches reports whether value matches n without recording violations
+func (v *validator) matches(n *node, value interface{}) bool {
+	sub := &validator{stopOnFirst: true}
+	sub.validate(n, value, "")
+	return len(sub.errors) == 0
+}
+
+func (v *validator) validate(n *node, value interface{}, pointer string) {
+	if n.always != nil {
+		if !*n.always {
+			v.fail(pointer, "no value is allowed here")
+		}
+		return
+	}
+	if n.ref != nil {
+		v.validate(n.ref, value, pointer)
+		if v.done() {
+			return
+		}
+	}
+
+	if value == nil && n.nullable {
+		return
+	}
+	if len(n.types) > 0 && !hasType(n.types, value) {
+		v.fail(pointer, "must be of type %s, got %s", strings.Join(n.types, " or "), typeOf(value))
+		return
+	}
+	if n.enum != nil && !containsValue(n.enum, value) {
+		v.fail(pointer, "must be one of %s", formatValues(n.enum))
+	}
+	if n.hasConst && !equal(n.constant, value) {
+		v.fail(pointer, "must be %s", formatValue(n.constant))
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		v.validateObject(n, value, pointer)
+	case []interface{}:
+		v.validateArray(n, value, pointer)
+	case string:
+		v.validateString(n, value, pointer)
+	case json.Number, float64, int, int64:
+		if f, ok := number(value); ok {
+			v.validateNumber(n, f, pointer)
+		}
+	}
+	if v.done() {
+		return
+	}
+
+	for _, sub := range n.allOf {
+		v.validate(sub, value, pointer)
+	}
+	if len(n.anyOf) > 0 {
+		matched := false
+		for _, sub := range n.anyOf {
+			if v.matches(sub, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(pointer, "must match at least one of the anyOf schemas")
+		}
+	}
+	if len(n.oneOf) > 0 {
+		count := 0
+		for _, sub := range n.oneOf {
+			if v.matches(sub, value) {
+				count++
+			}
+		}
+		if count != 1 {
+			v.fail(pointer, "must match exactly one of the oneOf schemas, matched %d", count)
+		}
+	}
+	if n.not != nil && v.matches(n.not, value) {
+		v.fail(pointer, "must not match the not schema")
+	}
+}
+
+func (v *validator) validateObject(n *node, obj map[string]interface{}, pointer string) {
+	for _, name := range n.required {
+		if _, ok := obj[name]; !ok {
+			v.fail(pointer+"/"+escape(name), "is required")
+		}
+	}
+	if n.minProperties >= 0 && len(obj) < n.minProperties {
+		v.fail(pointer, "must have at least %d properties", n.minProperties)
+	}
+	if n.maxProperties >= 0 && len(obj) > n.maxProperties {
+		v.fail(pointer, "must have at most %d properties", n.maxProperties)
+	}
+
+	// Visit properties in a stable order for reproducible errors
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if v.done() {
+			return
+		}
+		child := pointer + "/" + escape(name)
+		known := false
+		if sub, ok := n.properties[name]; ok {
+			known = true
+			v.validate(sub, obj[name], child)
+		}
+		for _, pp := range n.patternProperties {
+			if pp.pattern.MatchString(name) {
+				known = true
+				v.validate(pp.schema, obj[name], child)
+			}
+		}
+		if !known && n.additionalProperties != nil {
+			if n.additionalProperties.always != nil && !*n.additionalProperties.always {
+				v.fail(child, "is not allowed")
+				continue
+			}
+			v.validate(n.additionalProperties, obj[name], child)
+		}
+	}
+}
+
+func (v *validator) validateArray(n *node, arr []interface{}, pointer string) {
+	if n.minItems >= 0 && len(arr) < n.minItems {
+		v.fail(pointer, "must have at least %d items", n.minItems)
+	}
+	if n.maxItems >= 0 && len(arr) > n.maxItems {
+		v.fail(pointer, "must have at most %d items", n.maxItems)
+	}
+	if n.uniqueItems {
+		for i := 1; i < len(arr); i++ {
+			for j := 0; j < i; j++ {
+				if equal(arr[i], arr[j]) {
+					v.fail(pointer+"/"+strconv.Itoa(i), "duplicates item %d", j)
+				}
+			}
+		}
+	}
+	for i, item := range arr {
+		if v.done() {
+			return
+		}
+		child := pointer + "/" + strconv.Itoa(i)
+		switch {
+		case i < len(n.prefixItems):
+			v.validate(n.prefixItems[i], item, child)
+		case n.items != nil:
+			v.validate(n.items, item, child)
+		}
+	}
+}
+
+func (v *validator) validateString(n *node, s string, pointer string) {
+	length := utf8.RuneCountInString(s)
+	if n.minLength >= 0 && length < n.minLength {
+		v.fail(pointer, "must be at least %d characters long", n.minLength)
+	}
+	if n.maxLength >= 0 && length > n.maxLength {
+		v.fail(pointer, "must be at most %d characters long", n.maxLength)
+	}
+	if n.pattern != nil && !n.pattern.MatchString(s) {
+		v.fail(pointer, "must match pattern %q", n.pattern.String())
+	}
+	if check, ok := formats[n.format]; ok && !check(s) {
+		v.fail(pointer, "must be a valid %s", n.format)
+	}
+}
+
+func (v *validator) validateNumber(n *node, f float64, pointer string) {
+	if n.minimum != nil && f < *n.minimum {
+		v.fail(pointer, "must be >= %s", formatFloat(*n.minimum))
+	}
+	if n.maximum != nil && f > *n.maximum {
+		v.fail(pointer, "must be <= %s", formatFloat(*n.maximum))
+	}
+	if n.exclusiveMinimum != nil && f <= *n.exclusiveMinimum {
+		v.fail(pointer, "must be > %s", formatFloat(*n.exclusiveMinimum))
+	}
+	if n.exclusiveMaximum != nil && f >= *n.exclusiveMaximum {
+		v.fail(pointer, "must be < %s", formatFloat(*n.exclusiveMaximum))
+	}
+	if n.multipleOf > 0 {
+		quotient := f / n.multipleOf
+		if math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+			v.fail(pointer, "must be a multiple of %s", formatFloat(n.multipleOf))
+		}
+	}
+}
+
+// hasType reports whether value is of one of the types
+func hasType(types []string, value interface{}) bool {
+	actual := typeOf(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf returns the JSON Schema type of a decoded value
+func typeOf(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case json.Number, float64, int, int64:
+		if f, ok := number(value); ok && f == math.Trunc(f) && !math.IsInf(f, 0) {
+			return "integer"
+		}
+		return "number"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// number converts a decoded number to float64
+func number(value interface{}) (float64, bool) {
+	switch value := value.(type) {
+	case json.Number:
+		f, err := value.Float64()
+		return f, err == nil
+	case float64:
+		return value, true
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	}
+	return 0, false
+}
+
+// nonNegativeInt converts a decoded number to a non-negative int
+func nonNegativeInt(value interface{}) (int, bool) {
+	f, ok := number(value)
+	if !ok || f < 0 || f != math.Trunc(f) || f > math.MaxInt32 {
+		return 0, false
+	}
+	return int(f), true
+}
+
+// equal compares decoded values, treating numbers by value
+func equal(a, b interface{}) bool {
+	if fa, ok := number(a); ok {
+		fb, ok := number(b)
+		return ok && fa == fb
+	}
+	switch a := a.(type) {
+	case map[string]interface{}:
+		bm, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(bm) {
+			return false
+		}
+		for key, value := range a {
+			other, ok := bm[key]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bl, ok := b.([]interface{})
+		if !ok || len(a) != len(bl) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], bl[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, candidate := range values {
+		if equal(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func formatValues(values []interface{}) string {
+	parts := make([]string, 0, len(values))
+	for _, value := range values {
+		parts = append(parts, formatValue(value))
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// escape escapes a reference token for use in a JSON pointer (RFC 6901)
+func escape(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,166 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func decode(t *testing.T, data string) interface{} {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader([]byte(data)))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		t.Fatalf("invalid test document %s: %v", data, err)
+	}
+	return doc
+}
+
+func TestValidate(t *testing.T) {
+	order := `{
+		"type": "object",
+		"required": ["customer", "items"],
+		"additionalProperties": false,
+		"properties": {
+			"customer": {"type": "string", "format": "email"},
+			"note": {"type": "string", "maxLength": 5, "nullable": true},
+			"priority": {"enum": ["low", "high"]},
+			"items": {
+				"type": "array",
+				"minItems": 1,
+				"items": {"$ref": "#/$defs/item"}
+			}
+		},
+		"$defs": {
+			"item": {
+				"type": "object",
+				"required": ["sku", "qty"],
+				"properties": {
+					"sku": {"type": "string", "pattern": "^[A-Z]{3}-[0-9]+$"},
+					"qty": {"type": "integer", "minimum": 1, "exclusiveMaximum": 100}
+				}
+			}
+		}
+	}`
+
+	tests := []struct {
+		name     string
+		schema   string
+		doc      string
+		expected []ValidationError
+	}{
+		{
+			name:   "valid order",
+			schema: order,
+			doc:    `{"customer": "a@example.com", "note": null, "priority": "low", "items": [{"sku": "ABC-1", "qty": 2}]}`,
+		},
+		{
+			name:   "nested violations",
+			schema: order,
+			doc:    `{"customer": "not-an-email", "items": [{"sku": "abc", "qty": 0}, {"qty": 1.5}]}`,
+			expected: []ValidationError{
+				{Pointer: "/customer", Message: "must be a valid email"},
+				{Pointer: "/items/0/qty", Message: "must be >= 1"},
+				{Pointer: "/items/0/sku", Message: `must match pattern "^[A-Z]{3}-[0-9]+$"`},
+				{Pointer: "/items/1/sku", Message: "is required"},
+				{Pointer: "/items/1/qty", Message: "must be of type integer, got number"},
+			},
+		},
+		{
+			name:   "missing and unknown properties",
+			schema: order,
+			doc:    `{"customer": "a@example.com", "priority": "urgent", "extra/field": 1}`,
+			expected: []ValidationError{
+				{Pointer: "/items", Message: "is required"},
+				{Pointer: "/extra~1field", Message: "is not allowed"},
+				{Pointer: "/priority", Message: `must be one of ["low", "high"]`},
+			},
+		},
+		{
+			name:     "wrong root type",
+			schema:   order,
+			doc:      `[1, 2]`,
+			expected: []ValidationError{{Pointer: "", Message: "must be of type object, got array"}},
+		},
+		{
+			name:     "oneOf",
+			schema:   `{"oneOf": [{"type": "integer"}, {"type": "number", "minimum": 10}]}`,
+			doc:      `12`,
+			expected: []ValidationError{{Pointer: "", Message: "must match exactly one of the oneOf schemas, matched 2"}},
+		},
+		{
+			name:     "draft 4 exclusive minimum",
+			schema:   `{"type": "number", "minimum": 0, "exclusiveMinimum": true}`,
+			doc:      `0`,
+			expected: []ValidationError{{Pointer: "", Message: "must be > 0"}},
+		},
+		{
+			name:   "recursive reference",
+			schema: `{"type": "object", "properties": {"children": {"type": "array", "items": {"$ref": "#"}}, "name": {"type": "string"}}}`,
+			doc:    `{"name": "root", "children": [{"name": "leaf", "children": [{"name": 1}]}]}`,
+			expected: []ValidationError{
+				{Pointer: "/children/0/children/0/name", Message: "must be of type string, got integer"},
+			},
+		},
+		{
+			name:     "unique items",
+			schema:   `{"type": "array", "uniqueItems": true}`,
+			doc:      `[1, 2, 1.0]`,
+			expected: []ValidationError{{Pointer: "/2", Message: "duplicates item 0"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := Compile(decode(t, tt.schema))
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			errs := schema.Validate(decode(t, tt.doc))
+			if !reflect.DeepEqual(errs, tt.expected) {
+				t.Errorf("Validate() = %v, want %v", errs, tt.expected)
+			}
+			if schema.Valid(decode(t, tt.doc)) != (len(tt.expected) == 0) {
+				t.Errorf("Valid() disagrees with Validate()")
+			}
+		})
+	}
+}
+
+func TestCompile_YAMLValues(t *testing.T) {
+	// Schemas from YAML config arrive as native Go values
+	schema, err := Compile(map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"id"},
+		"properties": map[string]interface{}{
+			"id": map[string]interface{}{"type": "integer", "maximum": 10},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	if errs := schema.Validate(decode(t, `{"id": 11}`)); len(errs) != 1 || errs[0].Pointer != "/id" {
+		t.Errorf("expected maximum violation at /id, got %v", errs)
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := map[string]string{
+		"unknown type":      `{"type": "text"}`,
+		"invalid pattern":   `{"pattern": "("}`,
+		"remote reference":  `{"$ref": "https://example.com/schema.json"}`,
+		"missing reference": `{"$ref": "#/$defs/missing"}`,
+		"negative length":   `{"maxLength": -1}`,
+		"empty anyOf":       `{"anyOf": []}`,
+		"non-schema":        `{"properties": {"id": 1}}`,
+	}
+	for name, schema := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Compile(decode(t, schema)); err == nil {
+				t.Errorf("expected error for %s", schema)
+			}
+		})
+	}
+}
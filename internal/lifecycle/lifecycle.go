@@ -0,0 +1,276 @@
+// Package lifecycle starts the gateway's subsystems in dependency order,
+// reports which of them came up, and stops them in reverse order.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+)
+
+// Subsystem is a part of the gateway with its own startup and shutdown
+type Subsystem struct {
+	Name string
+	// DependsOn lists subsystems that must be started first. A subsystem is
+	// skipped when one of its dependencies did not start.
+	DependsOn []string
+	// Required subsystems abort startup when they fail; optional ones are
+	// reported and the gateway runs without them
+	Required bool
+	// Start brings the subsystem up; nil means there is nothing to start
+	Start func(ctx context.Context) error
+	// Stop shuts the subsystem down; nil means there is nothing to stop
+	Stop func(ctx context.Context) error
+}
+
+// Status is the outcome of starting a subsystem
+type Status string
+
+const (
+	StatusStarted Status = "started"
+	StatusFailed  Status = "failed"
+	StatusSkipped Status = "skipped"
+)
+
+// Result describes the startup of one subsystem
+type Result struct {
+	Name     string        `json:"name"`
+	Status   Status        `json:"status"`
+	Required bool          `json:"required"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Report summarizes a startup
+type Report struct {
+	Results []Result `json:"results"`
+}
+
+// Failed reports whether a required subsystem did not start
+func (r *Report) Failed() bool {
+	for _, result := range r.Results {
+		if result.Required && result.Status != StatusStarted {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats the report as one line per subsystem
+func (r *Report) String() string {
+	var b strings.Builder
+	for _, result := range r.Results {
+		kind := "optional"
+		if result.Required {
+			kind = "required"
+		}
+		fmt.Fprintf(&b, "%-16s %-8s %-8s %s", result.Name, result.Status, kind, result.Duration.Round(time.Microsecond))
+		if result.Error != "" {
+			fmt.Fprintf(&b, "  %s", result.Error)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// ExitStartupFailure is the process exit code when a required subsystem
+// fails to start, distinguishing it from configuration errors
+const ExitStartupFailure = 3
+
+// StartupError is returned when a required subsystem fails to start. It
+// carries the report of everything attempted so far.
+type StartupError struct {
+	Subsystem string
+	Err       string
+	Report    *Report
+}
+
+func (e *StartupError) Error() string {
+	return fmt.Sprintf("required subsystem %s did not start: %s", e.Subsystem, e.Err)
+}
+
+// Manager starts and stops subsystems
+type Manager struct {
+	mu         sync.Mutex
+	subsystems []*Subsystem
+	status     map[string]Status
+	started    []*Subsystem
+	results    []Result
+	logger     *logger.ComponentLogger
+}
+
+// NewManager creates an empty lifecycle manager
+func NewManager() *Manager {
+	return &Manager{
+		status: make(map[string]Status),
+		logger: logger.Get().WithComponent("lifecycle"),
+	}
+}
+
+// Register adds a subsystem. It is started by the next call to Start.
+func (m *Manager) Register(subsystem Subsystem) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subsystems = append(m.subsystems, &subsystem)
+}
+
+// Start starts all registered subsystems that have not been started yet, in
+// dependency order. It returns a *StartupError if a required subsystem did
+// not start; subsystems started before the failure keep running so the
+// caller can stop them with Stop.
+func (m *Manager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pending, err := m.order()
+	if err != nil {
+		return err
+	}
+
+	for _, subsystem := range pending {
+		result := m.start(ctx, subsystem)
+		m.status[subsystem.Name] = result.Status
+		m.results = append(m.results, result)
+
+		fields := logger.Fields{
+			"subsystem":   result.Name,
+			"status":      string(result.Status),
+			"required":    result.Required,
+			"duration_ms": result.Duration.Milliseconds(),
+		}
+		switch {
+		case result.Status == StatusStarted:
+			m.started = append(m.started, subsystem)
+			m.logger.Info("subsystem started", fields)
+		case result.Required:
+			fields["error"] = result.Error
+			m.logger.Error("required subsystem did not start", fields)
+			return &StartupError{
+				Subsystem: result.Name,
+				Err:       result.Error,
+				Report:    m.report(),
+			}
+		default:
+			fields["error"] = result.Error
+			m.logger.Warn("optional subsystem did not start, continuing without it", fields)
+		}
+	}
+	return nil
+}
+
+// Report returns the outcome of every subsystem started so far
+func (m *Manager) Report() *Report {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.report()
+}
+
+func (m *Manager) report() *Report {
+	return &Report{Results: append([]Result(nil), m.results...)}
+}
+
+// start starts a single subsystem once its dependencies are up
+func (m *Manager) start(ctx context.Context, subsystem *Subsystem) Result {
+	result := Result{Name: subsystem.Name, Required: subsystem.Required}
+
+	for _, dependency := range subsystem.DependsOn {
+		if m.status[dependency] != StatusStarted {
+			result.Status = StatusSkipped
+			result.Error = fmt.Sprintf("dependency %s is %s", dependency, m.status[dependency])
+			return result
+		}
+	}
+
+	start := time.Now()
+	if subsystem.Start != nil {
+		if err := subsystem.Start(ctx); err != nil {
+			result.Status = StatusFailed
+			result.Error = err.Error()
+			result.Duration = time.Since(start)
+			return result
+		}
+	}
+	result.Status = StatusStarted
+	result.Duration = time.Since(start)
+	return result
+}
+
+// order returns the subsystems not started yet, sorted so that every
+// subsystem comes after its dependencies. Registration order is kept
+// otherwise.
+func (m *Manager) order() ([]*Subsystem, error) {
+	byName := make(map[string]*Subsystem, len(m.subsystems))
+	for _, subsystem := range m.subsystems {
+		byName[subsystem.Name] = subsystem
+	}
+
+	var ordered []*Subsystem
+	visiting := make(map[string]bool)
+	visited := make(map[string]bool)
+
+	var visit func(subsystem *Subsystem) error
+	visit = func(subsystem *Subsystem) error {
+		if visited[subsystem.Name] {
+			return nil
+		}
+		if visiting[subsystem.Name] {
+			return fmt.Errorf("dependency cycle at subsystem %s", subsystem.Name)
+		}
+		visiting[subsystem.Name] = true
+		for _, dependency := range subsystem.DependsOn {
+			dep, ok := byName[dependency]
+			if !ok {
+				return fmt.Errorf("subsystem %s depends on unknown subsystem %s", subsystem.Name, dependency)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		visiting[subsystem.Name] = false
+		visited[subsystem.Name] = true
+		if _, done := m.status[subsystem.Name]; !done {
+			ordered = append(ordered, subsystem)
+		}
+		return nil
+	}
+
+	for _, subsystem := range m.subsystems {
+		if err := visit(subsystem); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// Stop stops the started subsystems in reverse start order, so dependents
+// stop before their dependencies. All subsystems are stopped even if some
+// fail; the errors are joined.
+func (m *Manager) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	for i := len(m.started) - 1; i >= 0; i-- {
+		subsystem := m.started[i]
+		if subsystem.Stop == nil {
+			continue
+		}
+		m.logger.Info("stopping subsystem", logger.Fields{
+			"subsystem": subsystem.Name,
+		})
+		if err := subsystem.Stop(ctx); err != nil {
+			m.logger.Error("subsystem stop error", logger.Fields{
+				"subsystem": subsystem.Name,
+				"error":     err.Error(),
+			})
+			errs = append(errs, fmt.Errorf("%s: %w", subsystem.Name, err))
+		}
+	}
+	m.started = nil
+	return errors.Join(errs...)
+}
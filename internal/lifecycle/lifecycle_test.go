@@ -0,0 +1,193 @@
+package lifecycle
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+)
+
+// recorder records the order in which subsystems start and stop
+type recorder struct {
+	events []string
+}
+
+func (r *recorder) subsystem(name string, required bool, startErr error, dependsOn ...string) Subsystem {
+	return Subsystem{
+		Name:      name,
+		DependsOn: dependsOn,
+		Required:  required,
+		Start: func(context.Context) error {
+			r.events = append(r.events, "start "+name)
+			return startErr
+		},
+		Stop: func(context.Context) error {
+			r.events = append(r.events, "stop "+name)
+			return nil
+		},
+	}
+}
+
+func TestManager(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+	errDown := errors.New("backend down")
+
+	tests := []struct {
+		name           string
+		subsystems     func(r *recorder) []Subsystem
+		expectFailure  string
+		expectEvents   []string
+		expectStatuses []Status
+	}{
+		{
+			name: "dependencies start first and stop last",
+			subsystems: func(r *recorder) []Subsystem {
+				return []Subsystem{
+					r.subsystem("listener", true, nil, "router", "limiter"),
+					r.subsystem("router", true, nil),
+					r.subsystem("limiter", false, nil),
+				}
+			},
+			expectEvents: []string{
+				"start router", "start limiter", "start listener",
+				"stop listener", "stop limiter", "stop router",
+			},
+			expectStatuses: []Status{StatusStarted, StatusStarted, StatusStarted},
+		},
+		{
+			name: "optional failure skips dependents",
+			subsystems: func(r *recorder) []Subsystem {
+				return []Subsystem{
+					r.subsystem("router", true, nil),
+					r.subsystem("limiter", false, errDown),
+					r.subsystem("quota", false, nil, "limiter"),
+				}
+			},
+			expectEvents:   []string{"start router", "start limiter", "stop router"},
+			expectStatuses: []Status{StatusStarted, StatusFailed, StatusSkipped},
+		},
+		{
+			name: "required failure aborts startup",
+			subsystems: func(r *recorder) []Subsystem {
+				return []Subsystem{
+					r.subsystem("router", true, nil),
+					r.subsystem("auth", true, errDown),
+					r.subsystem("listener", true, nil, "router"),
+				}
+			},
+			expectFailure:  "auth",
+			expectEvents:   []string{"start router", "start auth", "stop router"},
+			expectStatuses: []Status{StatusStarted, StatusFailed},
+		},
+		{
+			name: "required subsystem with failed dependency",
+			subsystems: func(r *recorder) []Subsystem {
+				return []Subsystem{
+					r.subsystem("limiter", false, errDown),
+					r.subsystem("listener", true, nil, "limiter"),
+				}
+			},
+			expectFailure:  "listener",
+			expectEvents:   []string{"start limiter"},
+			expectStatuses: []Status{StatusFailed, StatusSkipped},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{}
+			m := NewManager()
+			for _, subsystem := range tt.subsystems(r) {
+				m.Register(subsystem)
+			}
+
+			err := m.Start(context.Background())
+			var startupErr *StartupError
+			if tt.expectFailure == "" {
+				if err != nil {
+					t.Fatalf("Start() error = %v", err)
+				}
+			} else if !errors.As(err, &startupErr) || startupErr.Subsystem != tt.expectFailure {
+				t.Fatalf("expected startup failure of %s, got %v", tt.expectFailure, err)
+			}
+
+			if err := m.Stop(context.Background()); err != nil {
+				t.Fatalf("Stop() error = %v", err)
+			}
+			if !reflect.DeepEqual(r.events, tt.expectEvents) {
+				t.Errorf("expected events %v, got %v", tt.expectEvents, r.events)
+			}
+
+			report := m.Report()
+			var statuses []Status
+			for _, result := range report.Results {
+				statuses = append(statuses, result.Status)
+			}
+			if !reflect.DeepEqual(statuses, tt.expectStatuses) {
+				t.Errorf("expected statuses %v, got %v", tt.expectStatuses, statuses)
+			}
+			if report.Failed() != (tt.expectFailure != "") {
+				t.Errorf("expected Failed() = %v", tt.expectFailure != "")
+			}
+		})
+	}
+}
+
+func TestManager_StartIncremental(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+	r := &recorder{}
+	m := NewManager()
+
+	m.Register(r.subsystem("router", true, nil))
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	m.Register(r.subsystem("listener", true, nil, "router"))
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := m.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	expected := []string{"start router", "start listener", "stop listener", "stop router"}
+	if !reflect.DeepEqual(r.events, expected) {
+		t.Errorf("expected events %v, got %v", expected, r.events)
+	}
+}
+
+func TestManager_InvalidDependencies(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+
+	tests := []struct {
+		name       string
+		subsystems []Subsystem
+	}{
+		{
+			name:       "unknown dependency",
+			subsystems: []Subsystem{{Name: "listener", DependsOn: []string{"router"}}},
+		},
+		{
+			name: "cycle",
+			subsystems: []Subsystem{
+				{Name: "a", DependsOn: []string{"b"}},
+				{Name: "b", DependsOn: []string{"a"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager()
+			for _, subsystem := range tt.subsystems {
+				m.Register(subsystem)
+			}
+			if err := m.Start(context.Background()); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
@@ -118,6 +118,18 @@ func (l *Logger) SetLevel(level Level) {
 	l.level = level
 }
 
+// Levels returns the global log level and the component-specific levels
+func (l *Logger) Levels() (Level, map[string]Level) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	components := make(map[string]Level, len(l.componentLevels))
+	for component, level := range l.componentLevels {
+		components[component] = level
+	}
+	return l.level, components
+}
+
 // SetComponentLevel sets the log level for a specific component
 func (l *Logger) SetComponentLevel(component string, level Level) {
 	l.mu.Lock()
@@ -1,12 +1,14 @@
 package metrics
 
 import (
+	"context"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -63,6 +65,16 @@ var (
 		},
 	)
 
+	httpTestRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "http",
+			Name:      "test_requests_total",
+			Help:      "Total number of HTTP requests detected as test traffic, excluded from the request metrics",
+		},
+		[]string{"method", "route", "status_code"},
+	)
+
 	// Authorization Metrics
 	authAttemptsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -104,6 +116,67 @@ var (
 		[]string{"result"}, // hit, miss
 	)
 
+	authEnrichmentTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "auth",
+			Name:      "enrichment_total",
+			Help:      "Total number of user attribute lookups by result",
+		},
+		[]string{"result"}, // success, cache_hit, error
+	)
+
+	authExternalTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "auth",
+			Name:      "external_decisions_total",
+			Help:      "Total number of external authorization decisions by result",
+		},
+		[]string{"result"}, // allow, deny, cache_hit, error
+	)
+
+	authEdgeLoginTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "auth",
+			Name:      "edge_login_events_total",
+			Help:      "Total number of edge login events by type",
+		},
+		[]string{"event"}, // login_redirect, login_success, login_failure, refresh_success, refresh_failure, logout
+	)
+
+	authBypassTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "auth",
+			Name:      "bypass_total",
+			Help:      "Total number of requests admitted by the trusted-source auth bypass",
+		},
+		[]string{"source", "route"}, // network, service
+	)
+
+	authRevocationCheckDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "gateway",
+			Subsystem: "auth",
+			Name:      "revocation_check_duration_seconds",
+			Help:      "Duration of token revocation checks in seconds",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5},
+		},
+		[]string{"source"}, // list, redis
+	)
+
+	authRevocationErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "auth",
+			Name:      "revocation_check_errors_total",
+			Help:      "Total number of failed token revocation checks",
+		},
+		[]string{"source"}, // list, redis
+	)
+
 	// Rate Limiting Metrics
 	rateLimitChecksTotal = prometheus.NewCounter(
 		prometheus.CounterOpts{
@@ -155,6 +228,64 @@ var (
 		[]string{"error_type"},
 	)
 
+	rateLimitSessionsTightenedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "ratelimit",
+			Name:      "sessions_tightened_total",
+			Help:      "Total number of sessions whose limits were tightened after repeated client errors",
+		},
+	)
+
+	rateLimitShapedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "ratelimit",
+			Name:      "shaped_total",
+			Help:      "Total number of requests over a limit held by its delay or queue policy, by outcome",
+		},
+		[]string{"policy", "outcome"},
+	)
+
+	rateLimitConcurrencyExceededTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "ratelimit",
+			Name:      "concurrency_exceeded_total",
+			Help:      "Total number of requests rejected because too many requests were in flight",
+		},
+		[]string{"key_type", "route"},
+	)
+
+	rateLimitAdaptiveFactor = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "ratelimit",
+			Name:      "adaptive_factor",
+			Help:      "Fraction of the configured rate limits currently applied to each route by adaptive rate limiting",
+		},
+		[]string{"route"},
+	)
+
+	rateLimitDegraded = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "ratelimit",
+			Name:      "degraded",
+			Help:      "Whether rate limits are counted in local memory because the shared backend is unreachable (1) or not (0)",
+		},
+	)
+
+	rateLimitQuotaExceededTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "ratelimit",
+			Name:      "quota_exceeded_total",
+			Help:      "Total number of requests rejected because the caller's quota was used up",
+		},
+		[]string{"tier"},
+	)
+
 	// Backend Service Metrics
 	backendRequestsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -187,6 +318,229 @@ var (
 		[]string{"backend_service", "error_type"}, // timeout, connection_refused, bad_gateway
 	)
 
+	backendRetriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "backend",
+			Name:      "retries_total",
+			Help:      "Total number of backend retries by outcome",
+		},
+		[]string{"backend_service", "outcome"}, // attempted, budget_exhausted
+	)
+
+	backendFallbacksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "backend",
+			Name:      "fallbacks_total",
+			Help:      "Total number of requests to backends with an open circuit breaker by fallback outcome",
+		},
+		[]string{"backend_service", "fallback_type", "outcome"}, // served, unavailable
+	)
+
+	backendOutlierEjectionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "backend",
+			Name:      "outlier_ejections_total",
+			Help:      "Total number of backend instances ejected by outlier detection",
+		},
+		[]string{"backend_service", "instance", "reason"}, // consecutive_errors, latency
+	)
+
+	uploadBytesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "backend",
+			Name:      "upload_bytes_total",
+			Help:      "Total number of request body bytes streamed to backends by upload routes",
+		},
+		[]string{"backend_service"},
+	)
+
+	uploadsInProgress = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "backend",
+			Name:      "uploads_in_progress",
+			Help:      "Number of uploads currently being streamed to backends",
+		},
+		[]string{"backend_service"},
+	)
+
+	ownershipChecksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "backend",
+			Name:      "ownership_checks_total",
+			Help:      "Total number of resource ownership checks by result",
+		},
+		[]string{"result"}, // allowed, denied, cache_hit, error
+	)
+
+	backendInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "backend",
+			Name:      "in_flight_requests",
+			Help:      "Number of requests currently in flight to each backend",
+		},
+		[]string{"backend_service"},
+	)
+
+	backendBulkheadRejectionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "backend",
+			Name:      "bulkhead_rejections_total",
+			Help:      "Total number of requests rejected because a backend reached its in-flight limit",
+		},
+		[]string{"backend_service"},
+	)
+
+	backendBulkheadQueueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "backend",
+			Name:      "bulkhead_queue_depth",
+			Help:      "Number of requests waiting for an in-flight slot of each backend by priority class",
+		},
+		[]string{"backend_service", "priority_class"}, // critical, normal, low
+	)
+
+	backendProtocolTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "backend",
+			Name:      "responses_by_protocol_total",
+			Help:      "Total number of backend responses by negotiated protocol",
+		},
+		[]string{"backend_service", "protocol"}, // HTTP/1.1, HTTP/2.0
+	)
+
+	backendStreamErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "backend",
+			Name:      "stream_errors_total",
+			Help:      "Total number of backend responses that failed after the status was sent",
+		},
+		[]string{"backend_service", "reason"}, // read_error, timeout
+	)
+
+	transportSecurityRejectionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "http",
+			Name:      "transport_security_rejections_total",
+			Help:      "Total number of requests rejected by route transport security requirements",
+		},
+		[]string{"route", "reason"}, // plaintext, tls_version
+	)
+
+	requestAgeRejectionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "http",
+			Name:      "request_age_rejections_total",
+			Help:      "Total number of requests rejected for a missing, invalid or skewed client timestamp",
+		},
+		[]string{"route", "reason"}, // missing, invalid, skew
+	)
+
+	requestClockSkew = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "gateway",
+			Subsystem: "http",
+			Name:      "request_clock_skew_seconds",
+			Help:      "Gateway time minus the client timestamp of requests; negative when the client clock is ahead",
+			Buckets:   []float64{-300, -60, -10, -1, 0, 1, 10, 60, 300},
+		},
+		[]string{"client"},
+	)
+
+	requestSchemaRejectionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "http",
+			Name:      "request_schema_rejections_total",
+			Help:      "Total number of request bodies rejected by route request schemas",
+		},
+		[]string{"route"},
+	)
+
+	responseCacheTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "response_cache",
+			Name:      "requests_total",
+			Help:      "Total number of gateway response cache lookups and stores",
+		},
+		[]string{"route", "result"}, // hit, miss, store, credential_vary
+	)
+
+	cdnPurgesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "response_cache",
+			Name:      "cdn_purges_total",
+			Help:      "Total number of response cache purges forwarded to the CDN by outcome",
+		},
+		[]string{"provider", "outcome"}, // purged, error, unsupported
+	)
+
+	mirrorRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "mirror",
+			Name:      "requests_total",
+			Help:      "Total number of requests mirrored to shadow backends by outcome",
+		},
+		[]string{"backend_service", "outcome"}, // 2xx-5xx, error, timeout, skipped, dropped
+	)
+
+	// Passthrough Metrics
+	passthroughConnectionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "passthrough",
+			Name:      "connections_total",
+			Help:      "Total number of TLS passthrough connections by outcome",
+		},
+		[]string{"backend", "outcome"}, // tunneled, dial_error
+	)
+
+	passthroughActiveConnections = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "passthrough",
+			Name:      "active_connections",
+			Help:      "Number of open TLS passthrough tunnels",
+		},
+		[]string{"backend"},
+	)
+
+	passthroughBytesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "passthrough",
+			Name:      "bytes_total",
+			Help:      "Total bytes tunneled through TLS passthrough routes",
+		},
+		[]string{"backend", "direction"}, // upstream, downstream
+	)
+
+	// TLS Certificate Metrics
+	tlsCertExpiryDays = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "tls",
+			Name:      "cert_expiry_days",
+			Help:      "Days until a monitored TLS certificate expires (negative once expired)",
+		},
+		[]string{"target", "kind"}, // kind: server, client, backend
+	)
+
 	// Circuit Breaker Metrics
 	circuitBreakerState = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -208,6 +562,47 @@ var (
 		[]string{"backend_service", "from_state", "to_state"},
 	)
 
+	circuitBreakerNotificationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "circuitbreaker",
+			Name:      "notifications_total",
+			Help:      "Total number of circuit breaker state changes sent to the webhook by outcome",
+		},
+		[]string{"outcome"}, // sent, error, dropped
+	)
+
+	circuitBreakerPersistenceTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "circuitbreaker",
+			Name:      "persistence_total",
+			Help:      "Total number of circuit breaker states saved to and restored from the store by outcome",
+		},
+		[]string{"outcome"}, // saved, restored, error, dropped
+	)
+
+	circuitBreakerEvictionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "circuitbreaker",
+			Name:      "evictions_total",
+			Help:      "Total number of circuit breakers evicted from the manager by reason",
+		},
+		[]string{"reason"}, // idle, capacity
+	)
+
+	// Reload Metrics
+	reloadOrphanedStateReleasedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "reload",
+			Name:      "orphaned_state_released_total",
+			Help:      "Total number of per-route or per-backend state entries released after a route reload",
+		},
+		[]string{"kind"}, // circuit_breaker, bulkhead, instance_pool, client, response_cache, fallback_cache
+	)
+
 	// Health Check Metrics
 	healthCheckTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -242,12 +637,19 @@ func Init() {
 		prometheus.MustRegister(httpRequestSize)
 		prometheus.MustRegister(httpResponseSize)
 		prometheus.MustRegister(httpActiveRequests)
+		prometheus.MustRegister(httpTestRequestsTotal)
 
 		// Register authorization metrics
 		prometheus.MustRegister(authAttemptsTotal)
 		prometheus.MustRegister(authFailuresTotal)
 		prometheus.MustRegister(authValidationDuration)
 		prometheus.MustRegister(authCacheHitsTotal)
+		prometheus.MustRegister(authEnrichmentTotal)
+		prometheus.MustRegister(authExternalTotal)
+		prometheus.MustRegister(authEdgeLoginTotal)
+		prometheus.MustRegister(authBypassTotal)
+		prometheus.MustRegister(authRevocationCheckDuration)
+		prometheus.MustRegister(authRevocationErrorsTotal)
 
 		// Register rate limiting metrics
 		prometheus.MustRegister(rateLimitChecksTotal)
@@ -255,15 +657,51 @@ func Init() {
 		prometheus.MustRegister(rateLimitUtilization)
 		prometheus.MustRegister(rateLimitCheckDuration)
 		prometheus.MustRegister(rateLimitErrorsTotal)
+		prometheus.MustRegister(rateLimitSessionsTightenedTotal)
+		prometheus.MustRegister(rateLimitQuotaExceededTotal)
+		prometheus.MustRegister(rateLimitShapedTotal)
+		prometheus.MustRegister(rateLimitConcurrencyExceededTotal)
+		prometheus.MustRegister(rateLimitAdaptiveFactor)
+		prometheus.MustRegister(rateLimitDegraded)
 
 		// Register backend metrics
 		prometheus.MustRegister(backendRequestsTotal)
 		prometheus.MustRegister(backendRequestDuration)
 		prometheus.MustRegister(backendErrorsTotal)
+		prometheus.MustRegister(backendRetriesTotal)
+		prometheus.MustRegister(backendFallbacksTotal)
+		prometheus.MustRegister(backendOutlierEjectionsTotal)
+		prometheus.MustRegister(uploadBytesTotal)
+		prometheus.MustRegister(uploadsInProgress)
+		prometheus.MustRegister(backendInFlight)
+		prometheus.MustRegister(ownershipChecksTotal)
+		prometheus.MustRegister(backendBulkheadRejectionsTotal)
+		prometheus.MustRegister(backendBulkheadQueueDepth)
+		prometheus.MustRegister(backendProtocolTotal)
+		prometheus.MustRegister(backendStreamErrorsTotal)
+		prometheus.MustRegister(mirrorRequestsTotal)
+		prometheus.MustRegister(responseCacheTotal)
+		prometheus.MustRegister(cdnPurgesTotal)
+		prometheus.MustRegister(transportSecurityRejectionsTotal)
+		prometheus.MustRegister(requestAgeRejectionsTotal)
+		prometheus.MustRegister(requestClockSkew)
+		prometheus.MustRegister(requestSchemaRejectionsTotal)
+
+		// Register passthrough metrics
+		prometheus.MustRegister(passthroughConnectionsTotal)
+		prometheus.MustRegister(passthroughActiveConnections)
+		prometheus.MustRegister(passthroughBytesTotal)
+
+		// Register TLS certificate metrics
+		prometheus.MustRegister(tlsCertExpiryDays)
 
 		// Register circuit breaker metrics
 		prometheus.MustRegister(circuitBreakerState)
 		prometheus.MustRegister(circuitBreakerTransitionsTotal)
+		prometheus.MustRegister(circuitBreakerNotificationsTotal)
+		prometheus.MustRegister(circuitBreakerPersistenceTotal)
+		prometheus.MustRegister(circuitBreakerEvictionsTotal)
+		prometheus.MustRegister(reloadOrphanedStateReleasedTotal)
 
 		// Register health check metrics
 		prometheus.MustRegister(healthCheckTotal)
@@ -271,19 +709,46 @@ func Init() {
 	})
 }
 
-// Handler returns an HTTP handler for the Prometheus metrics endpoint
-func Handler() http.Handler {
-	return promhttp.Handler()
+// Handler returns an HTTP handler for the Prometheus metrics endpoint. The
+// exposition format follows the Accept header: protobuf, the classic text
+// format, or, with openMetrics, OpenMetrics including _created samples and
+// exemplars.
+func Handler(openMetrics bool) http.Handler {
+	return promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+			EnableOpenMetrics:                   openMetrics,
+			EnableOpenMetricsTextCreatedSamples: openMetrics,
+		}),
+	)
+}
+
+// observe records a value, attaching the trace ID of ctx as an exemplar for
+// sampled traces so that latency buckets link to example traces
+func observe(ctx context.Context, observer prometheus.Observer, value float64) {
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.IsSampled() {
+		if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok {
+			exemplarObserver.ObserveWithExemplar(value, prometheus.Labels{
+				"trace_id": spanCtx.TraceID().String(),
+			})
+			return
+		}
+	}
+	observer.Observe(value)
 }
 
 // HTTP Metrics functions
-func RecordHTTPRequest(method, route, statusCode string, duration time.Duration, requestSize, responseSize int) {
+func RecordHTTPRequest(ctx context.Context, method, route, statusCode string, duration time.Duration, requestSize, responseSize int) {
 	httpRequestsTotal.WithLabelValues(method, route, statusCode).Inc()
-	httpRequestDuration.WithLabelValues(method, route, statusCode).Observe(duration.Seconds())
+	observe(ctx, httpRequestDuration.WithLabelValues(method, route, statusCode), duration.Seconds())
 	httpRequestSize.WithLabelValues(method, route).Observe(float64(requestSize))
 	httpResponseSize.WithLabelValues(method, route, statusCode).Observe(float64(responseSize))
 }
 
+func RecordTestHTTPRequest(method, route, statusCode string) {
+	httpTestRequestsTotal.WithLabelValues(method, route, statusCode).Inc()
+}
+
 func IncActiveRequests() {
 	httpActiveRequests.Inc()
 }
@@ -313,6 +778,29 @@ func RecordAuthCacheHit(hit bool) {
 	}
 }
 
+func RecordAuthEnrichment(result string) {
+	authEnrichmentTotal.WithLabelValues(result).Inc()
+}
+
+func RecordAuthExternal(result string) {
+	authExternalTotal.WithLabelValues(result).Inc()
+}
+
+func RecordAuthEdgeLogin(event string) {
+	authEdgeLoginTotal.WithLabelValues(event).Inc()
+}
+
+func RecordAuthBypass(source, route string) {
+	authBypassTotal.WithLabelValues(source, route).Inc()
+}
+
+func RecordAuthRevocationCheck(source string, duration time.Duration, err error) {
+	authRevocationCheckDuration.WithLabelValues(source).Observe(duration.Seconds())
+	if err != nil {
+		authRevocationErrorsTotal.WithLabelValues(source).Inc()
+	}
+}
+
 // Rate Limiting Metrics functions
 func RecordRateLimitCheck() {
 	rateLimitChecksTotal.Inc()
@@ -334,16 +822,146 @@ func RecordRateLimitError(errorType string) {
 	rateLimitErrorsTotal.WithLabelValues(errorType).Inc()
 }
 
+func RecordRateLimitSessionTightened() {
+	rateLimitSessionsTightenedTotal.Inc()
+}
+
+func RecordRateLimitShaped(policy, outcome string) {
+	rateLimitShapedTotal.WithLabelValues(policy, outcome).Inc()
+}
+
+func RecordRateLimitConcurrencyExceeded(keyType, route string) {
+	rateLimitConcurrencyExceededTotal.WithLabelValues(keyType, route).Inc()
+}
+
+func RecordRateLimitAdaptiveFactor(route string, factor float64) {
+	rateLimitAdaptiveFactor.WithLabelValues(route).Set(factor)
+}
+
+func RecordRateLimitDegraded(degraded bool) {
+	if degraded {
+		rateLimitDegraded.Set(1)
+	} else {
+		rateLimitDegraded.Set(0)
+	}
+}
+
+func RecordRateLimitQuotaExceeded(tier string) {
+	rateLimitQuotaExceededTotal.WithLabelValues(tier).Inc()
+}
+
 // Backend Metrics functions
-func RecordBackendRequest(backendService, statusCode string, duration time.Duration) {
+func RecordBackendRequest(ctx context.Context, backendService, statusCode string, duration time.Duration) {
 	backendRequestsTotal.WithLabelValues(backendService, statusCode).Inc()
-	backendRequestDuration.WithLabelValues(backendService).Observe(duration.Seconds())
+	observe(ctx, backendRequestDuration.WithLabelValues(backendService), duration.Seconds())
 }
 
 func RecordBackendError(backendService, errorType string) {
 	backendErrorsTotal.WithLabelValues(backendService, errorType).Inc()
 }
 
+func RecordBackendRetry(backendService, outcome string) {
+	backendRetriesTotal.WithLabelValues(backendService, outcome).Inc()
+}
+
+func RecordBackendFallback(backendService, fallbackType, outcome string) {
+	backendFallbacksTotal.WithLabelValues(backendService, fallbackType, outcome).Inc()
+}
+
+func RecordOutlierEjection(backendService, instance, reason string) {
+	backendOutlierEjectionsTotal.WithLabelValues(backendService, instance, reason).Inc()
+}
+
+func RecordUploadBytes(backendService string, n int) {
+	uploadBytesTotal.WithLabelValues(backendService).Add(float64(n))
+}
+
+func IncUploadsInProgress(backendService string) {
+	uploadsInProgress.WithLabelValues(backendService).Inc()
+}
+
+func DecUploadsInProgress(backendService string) {
+	uploadsInProgress.WithLabelValues(backendService).Dec()
+}
+
+func RecordOwnershipCheck(result string) {
+	ownershipChecksTotal.WithLabelValues(result).Inc()
+}
+
+func IncBackendInFlight(backendService string) {
+	backendInFlight.WithLabelValues(backendService).Inc()
+}
+
+func DecBackendInFlight(backendService string) {
+	backendInFlight.WithLabelValues(backendService).Dec()
+}
+
+func RecordBulkheadRejection(backendService string) {
+	backendBulkheadRejectionsTotal.WithLabelValues(backendService).Inc()
+}
+
+func SetBulkheadQueueDepth(backendService, priorityClass string, depth int) {
+	backendBulkheadQueueDepth.WithLabelValues(backendService, priorityClass).Set(float64(depth))
+}
+
+func RecordBackendProtocol(backendService, protocol string) {
+	backendProtocolTotal.WithLabelValues(backendService, protocol).Inc()
+}
+
+func RecordBackendStreamError(backendService, reason string) {
+	backendStreamErrorsTotal.WithLabelValues(backendService, reason).Inc()
+}
+
+func RecordTransportSecurityRejection(route, reason string) {
+	transportSecurityRejectionsTotal.WithLabelValues(route, reason).Inc()
+}
+
+func RecordRequestAgeRejection(route, reason string) {
+	requestAgeRejectionsTotal.WithLabelValues(route, reason).Inc()
+}
+
+func RecordRequestClockSkew(client string, skew time.Duration) {
+	requestClockSkew.WithLabelValues(client).Observe(skew.Seconds())
+}
+
+func RecordRequestSchemaRejection(route string) {
+	requestSchemaRejectionsTotal.WithLabelValues(route).Inc()
+}
+
+func RecordResponseCache(route, result string) {
+	responseCacheTotal.WithLabelValues(route, result).Inc()
+}
+
+func RecordCDNPurge(provider, outcome string) {
+	cdnPurgesTotal.WithLabelValues(provider, outcome).Inc()
+}
+
+func RecordMirrorRequest(backendService, outcome string) {
+	mirrorRequestsTotal.WithLabelValues(backendService, outcome).Inc()
+}
+
+// Passthrough Metrics functions
+func RecordPassthroughConnection(backend, outcome string) {
+	passthroughConnectionsTotal.WithLabelValues(backend, outcome).Inc()
+}
+
+func IncPassthroughActive(backend string) {
+	passthroughActiveConnections.WithLabelValues(backend).Inc()
+}
+
+func DecPassthroughActive(backend string) {
+	passthroughActiveConnections.WithLabelValues(backend).Dec()
+}
+
+func RecordPassthroughBytes(backend, direction string, n int64) {
+	passthroughBytesTotal.WithLabelValues(backend, direction).Add(float64(n))
+}
+
+// TLS Certificate Metrics functions
+func SetTLSCertExpiryDays(target, kind string, days float64) {
+	tlsCertExpiryDays.WithLabelValues(target, kind).Set(days)
+}
+
 // Circuit Breaker Metrics functions
 func SetCircuitBreakerState(backendService string, state int) {
 	circuitBreakerState.WithLabelValues(backendService).Set(float64(state))
@@ -353,6 +971,29 @@ func RecordCircuitBreakerTransition(backendService, fromState, toState string) {
 	circuitBreakerTransitionsTotal.WithLabelValues(backendService, fromState, toState).Inc()
 }
 
+func RecordCircuitBreakerNotification(outcome string) {
+	circuitBreakerNotificationsTotal.WithLabelValues(outcome).Inc()
+}
+
+func RecordCircuitBreakerPersistence(outcome string) {
+	circuitBreakerPersistenceTotal.WithLabelValues(outcome).Inc()
+}
+
+func RecordCircuitBreakerEviction(reason string) {
+	circuitBreakerEvictionsTotal.WithLabelValues(reason).Inc()
+}
+
+// DeleteCircuitBreakerState removes the state series of a disposed breaker,
+// so a breaker that was open does not keep reporting it
+func DeleteCircuitBreakerState(backendService string) {
+	circuitBreakerState.DeleteLabelValues(backendService)
+}
+
+// Reload Metrics functions
+func RecordOrphanedStateReleased(kind string, count int) {
+	reloadOrphanedStateReleasedTotal.WithLabelValues(kind).Add(float64(count))
+}
+
 // Health Check Metrics functions
 func RecordHealthCheck(checkName, status string, duration time.Duration) {
 	healthCheckTotal.WithLabelValues(checkName, status).Inc()
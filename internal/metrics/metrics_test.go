@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestHandlerNegotiation(t *testing.T) {
+	Init()
+
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), spanCtx)
+	RecordHTTPRequest(ctx, "GET", "/exemplar", "200", 20*time.Millisecond, 0, 10)
+
+	tests := []struct {
+		name          string
+		openMetrics   bool
+		accept        string
+		expectedType  string
+		expectedTexts []string
+	}{
+		{
+			name:          "classic text by default",
+			openMetrics:   true,
+			expectedType:  "text/plain",
+			expectedTexts: []string{"gateway_http_requests_total"},
+		},
+		{
+			name:         "OpenMetrics with created samples and exemplars",
+			openMetrics:  true,
+			accept:       "application/openmetrics-text;version=1.0.0",
+			expectedType: "application/openmetrics-text",
+			expectedTexts: []string{
+				"gateway_http_requests_created",
+				`trace_id="4bf92f3577b34da6a3ce929d0e0e4736"`,
+				"# EOF",
+			},
+		},
+		{
+			name:         "OpenMetrics disabled",
+			openMetrics:  false,
+			accept:       "application/openmetrics-text;version=1.0.0",
+			expectedType: "text/plain",
+		},
+		{
+			name:         "protobuf",
+			openMetrics:  true,
+			accept:       "application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited",
+			expectedType: "application/vnd.google.protobuf",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+			Handler(tt.openMetrics).ServeHTTP(rr, req)
+
+			if contentType := rr.Header().Get("Content-Type"); !strings.HasPrefix(contentType, tt.expectedType) {
+				t.Errorf("expected content type %s, got %s", tt.expectedType, contentType)
+			}
+			for _, text := range tt.expectedTexts {
+				if !strings.Contains(rr.Body.String(), text) {
+					t.Errorf("expected output to contain %q", text)
+				}
+			}
+		})
+	}
+}
@@ -44,7 +44,13 @@ func Middleware() func(http.Handler) http.Handler {
 			method := r.Method
 			responseSize := wrapped.BytesWritten()
 
-			RecordHTTPRequest(method, route, statusCode, duration, requestSize, responseSize)
+			// Test traffic is kept out of the request metrics used for SLOs
+			if middleware.IsTestTraffic(r.Context()) {
+				RecordTestHTTPRequest(method, route, statusCode)
+				return
+			}
+
+			RecordHTTPRequest(r.Context(), method, route, statusCode, duration, requestSize, responseSize)
 		})
 	}
 }
@@ -94,6 +94,16 @@ const (
 	ContextKeyRouteMatch ContextKey = "route_match"
 	// ContextKeyBackendURL is the context key for backend URL
 	ContextKeyBackendURL ContextKey = "backend_url"
+	// ContextKeyBodyLimitExempt marks requests that bypass the request body size limit
+	ContextKeyBodyLimitExempt ContextKey = "body_limit_exempt"
+	// ContextKeyBodyLimit is the request body size limit of the matched route
+	ContextKeyBodyLimit ContextKey = "body_limit"
+	// ContextKeyRawBody marks requests whose body must be forwarded untouched
+	ContextKeyRawBody ContextKey = "raw_body"
+	// ContextKeyTestTraffic holds the test traffic marker of a request
+	ContextKeyTestTraffic ContextKey = "test_traffic"
+	// ContextKeyAccessLog holds what later handlers report for the access log
+	ContextKeyAccessLog ContextKey = "access_log"
 )
 
 // GetDuration retrieves the request duration from context
@@ -109,6 +119,11 @@ func RouteMatchFromContext(ctx context.Context) interface{} {
 	return ctx.Value(ContextKeyRouteMatch)
 }
 
+// WithRouteMatch stores the route matched for the request in the context
+func WithRouteMatch(ctx context.Context, match interface{}) context.Context {
+	return context.WithValue(ctx, ContextKeyRouteMatch, match)
+}
+
 // BackendURLFromContext retrieves backend URL from context
 func BackendURLFromContext(ctx context.Context) string {
 	if url, ok := ctx.Value(ContextKeyBackendURL).(string); ok {
@@ -117,6 +132,40 @@ func BackendURLFromContext(ctx context.Context) string {
 	return ""
 }
 
+// WithBodyLimitExempt marks the request context as exempt from MaxRequestBodySize
+func WithBodyLimitExempt(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ContextKeyBodyLimitExempt, true)
+}
+
+// BodyLimitExempt reports whether the request body size limit is disabled
+func BodyLimitExempt(ctx context.Context) bool {
+	exempt, _ := ctx.Value(ContextKeyBodyLimitExempt).(bool)
+	return exempt
+}
+
+// WithBodyLimit sets the request body size limit of the matched route,
+// overriding MaxRequestBodySize
+func WithBodyLimit(ctx context.Context, limit int64) context.Context {
+	return context.WithValue(ctx, ContextKeyBodyLimit, limit)
+}
+
+// BodyLimit returns the request body size limit of the matched route
+func BodyLimit(ctx context.Context) (int64, bool) {
+	limit, ok := ctx.Value(ContextKeyBodyLimit).(int64)
+	return limit, ok
+}
+
+// WithRawBody marks the request body to be forwarded without decoding
+func WithRawBody(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ContextKeyRawBody, true)
+}
+
+// RawBody reports whether the request body must be forwarded untouched
+func RawBody(ctx context.Context) bool {
+	raw, _ := ctx.Value(ContextKeyRawBody).(bool)
+	return raw
+}
+
 // Registry manages middleware registration and composition
 type Registry struct {
 	middlewares map[string]Middleware
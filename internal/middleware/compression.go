@@ -0,0 +1,308 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+)
+
+// Compression returns a middleware that compresses responses according to the
+// client's Accept-Encoding and optionally decompresses request bodies, so
+// that input validation and backends always see plain content
+func Compression(cfg *config.CompressionConfig) func(http.Handler) http.Handler {
+	log := logger.Get().WithComponent("middleware.compression")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cfg.DecompressRequests && !RawBody(r.Context()) {
+				if err := DecompressBody(r, cfg.MaxDecompressedBodySize); err != nil {
+					correlationID := logger.GetCorrelationID(r.Context())
+					log.Warn("failed to decode request body", logger.Fields{
+						"correlation_id":   correlationID,
+						"content_encoding": r.Header.Get("Content-Encoding"),
+						"error":            err.Error(),
+					})
+
+					writeErrorResponse(w, http.StatusBadRequest, "invalid_content_encoding",
+						"Request body could not be decoded", correlationID)
+					return
+				}
+			}
+
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), cfg.Algorithms)
+			if encoding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				cfg:            cfg,
+				encoding:       encoding,
+			}
+			defer func() {
+				if err := cw.Close(); err != nil {
+					log.Warn("error finishing compressed response", logger.Fields{
+						"correlation_id": logger.GetCorrelationID(r.Context()),
+						"encoding":       encoding,
+						"error":          err.Error(),
+					})
+				}
+			}()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding selects the best supported encoding from Accept-Encoding.
+// Ties are broken by the order of the configured algorithms.
+func negotiateEncoding(acceptEncoding string, algorithms []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "x-gzip" {
+			coding = "gzip"
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		qualities[coding] = q
+	}
+
+	best := ""
+	bestQ := 0.0
+	for _, algorithm := range algorithms {
+		q, ok := qualities[algorithm]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q > bestQ {
+			best = algorithm
+			bestQ = q
+		}
+	}
+	return best
+}
+
+// encoderPools reuse compressors across responses
+var encoderPools = map[string]*sync.Pool{
+	"gzip": {New: func() interface{} {
+		return gzip.NewWriter(io.Discard)
+	}},
+	"br": {New: func() interface{} {
+		return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression)
+	}},
+	"zstd": {New: func() interface{} {
+		enc, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderConcurrency(1))
+		return enc
+	}},
+}
+
+// resettableEncoder is implemented by all pooled compressors
+type resettableEncoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressWriter compresses the response body once it is known to be eligible.
+// Writes are buffered until MinSize bytes are available so small responses
+// are sent uncompressed.
+type compressWriter struct {
+	http.ResponseWriter
+	cfg      *config.CompressionConfig
+	encoding string
+
+	status  int
+	decided bool
+	buf     []byte
+	encoder resettableEncoder
+}
+
+// WriteHeader records the status code; headers are sent once compression is decided
+func (cw *compressWriter) WriteHeader(statusCode int) {
+	if cw.status != 0 {
+		return
+	}
+	if statusCode < http.StatusOK {
+		// Informational responses are passed through immediately
+		cw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+
+	cw.status = statusCode
+
+	if !cw.eligible() {
+		cw.decide(false)
+		return
+	}
+	if length, err := strconv.Atoi(cw.Header().Get("Content-Length")); err == nil {
+		cw.decide(length >= cw.cfg.MinSize)
+	}
+}
+
+// Write buffers or compresses the response body
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if !cw.decided {
+		cw.buf = append(cw.buf, b...)
+		if len(cw.buf) >= cw.cfg.MinSize {
+			if err := cw.decide(true); err != nil {
+				return 0, err
+			}
+		}
+		return len(b), nil
+	}
+
+	if cw.encoder != nil {
+		return cw.encoder.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush sends buffered data to the client
+func (cw *compressWriter) Flush() {
+	if cw.status != 0 && !cw.decided {
+		_ = cw.decide(len(cw.buf) >= cw.cfg.MinSize)
+	}
+	if cw.encoder != nil {
+		_ = cw.encoder.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Close writes any buffered data and finishes the compressed stream
+func (cw *compressWriter) Close() error {
+	if cw.status == 0 {
+		return nil
+	}
+	if !cw.decided {
+		// Body ended below the size threshold
+		if err := cw.decide(false); err != nil {
+			return err
+		}
+	}
+	if cw.encoder == nil {
+		return nil
+	}
+
+	err := cw.encoder.Close()
+	cw.encoder.Reset(io.Discard)
+	encoderPools[cw.encoding].Put(cw.encoder)
+	cw.encoder = nil
+	return err
+}
+
+// decide sends the response headers and flushes the buffered body
+func (cw *compressWriter) decide(compress bool) error {
+	cw.decided = true
+
+	if compress {
+		h := cw.Header()
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		addVary(h, "Accept-Encoding")
+
+		cw.encoder = encoderPools[cw.encoding].Get().(resettableEncoder)
+		cw.encoder.Reset(cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buffered := cw.buf
+	cw.buf = nil
+	if len(buffered) == 0 {
+		return nil
+	}
+	if cw.encoder != nil {
+		_, err := cw.encoder.Write(buffered)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buffered)
+	return err
+}
+
+// eligible reports whether the response may be compressed
+func (cw *compressWriter) eligible() bool {
+	switch cw.status {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	if strings.Contains(strings.ToLower(h.Get("Cache-Control")), "no-transform") {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	for _, pattern := range cw.cfg.ContentTypes {
+		if matchMediaType(pattern, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchMediaType matches a media type against a pattern such as "text/*" or "application/*+json"
+func matchMediaType(pattern, mediaType string) bool {
+	prefix, suffix, wildcard := strings.Cut(strings.ToLower(pattern), "*")
+	if !wildcard {
+		return prefix == mediaType
+	}
+	return len(mediaType) >= len(prefix)+len(suffix) &&
+		strings.HasPrefix(mediaType, prefix) && strings.HasSuffix(mediaType, suffix)
+}
+
+// addVary adds a value to the Vary header unless it is already present
+func addVary(h http.Header, value string) {
+	for _, existing := range h.Values("Vary") {
+		for _, v := range strings.Split(existing, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), value) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", value)
+}
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultMaxDecompressedBodySize caps inflated request bodies when no limit
+// is configured
+const DefaultMaxDecompressedBodySize = 10 << 20 // 10 MB
+
+var (
+	// ErrRequestBodyTooLarge is returned when a decompressed request body exceeds its limit
+	ErrRequestBodyTooLarge = errors.New("decompressed request body too large")
+	// ErrInvalidRequestEncoding is returned when a compressed request body cannot be decoded
+	ErrInvalidRequestEncoding = errors.New("invalid request content encoding")
+)
+
+// DecompressBody replaces a gzip, brotli or zstd encoded request body with a
+// streaming decoder, so validation and backends see plain content. The
+// inflated size is capped at limit, or DefaultMaxDecompressedBodySize if
+// limit is not positive, to protect against decompression bombs. Unknown or
+// absent encodings are passed through untouched.
+func DecompressBody(r *http.Request, limit int64) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	var decoded io.ReadCloser
+	switch strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRequestEncoding, err)
+		}
+		decoded = gz
+	case "br":
+		decoded = io.NopCloser(brotli.NewReader(r.Body))
+	case "zstd":
+		zr, err := zstd.NewReader(r.Body, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRequestEncoding, err)
+		}
+		decoded = zr.IOReadCloser()
+	default:
+		return nil
+	}
+
+	if limit <= 0 {
+		limit = DefaultMaxDecompressedBodySize
+	}
+	r.Body = &limitedBody{
+		reader:    decoded,
+		closers:   []io.Closer{decoded, r.Body},
+		remaining: limit,
+	}
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+	return nil
+}
+
+// limitedBody reads at most remaining bytes and fails once the limit is exceeded
+type limitedBody struct {
+	reader    io.Reader
+	closers   []io.Closer
+	remaining int64
+}
+
+// Read implements io.Reader
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, ErrRequestBodyTooLarge
+	}
+
+	// Read one byte past the limit so an exact-size body is still accepted
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.reader.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return 0, ErrRequestBodyTooLarge
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("%w: %v", ErrInvalidRequestEncoding, err)
+	}
+	return n, err
+}
+
+// Close implements io.Closer
+func (b *limitedBody) Close() error {
+	var firstErr error
+	for _, c := range b.closers {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -18,6 +18,11 @@ type errorResponseWriter struct {
 }
 
 func (rw *errorResponseWriter) WriteHeader(statusCode int) {
+	if statusCode < http.StatusOK {
+		// Informational responses precede the final status
+		rw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
 	if !rw.written {
 		rw.statusCode = statusCode
 		rw.written = true
@@ -32,6 +37,11 @@ func (rw *errorResponseWriter) Write(b []byte) (int, error) {
 	return rw.ResponseWriter.Write(b)
 }
 
+// Unwrap returns the underlying ResponseWriter for http.ResponseController
+func (rw *errorResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // ErrorHandling returns a middleware that implements error disclosure prevention
 func ErrorHandling(cfg *config.SecurityConfig) func(http.Handler) http.Handler {
 	log := logger.Get().WithComponent("middleware.error_handling")
@@ -48,6 +58,10 @@ func ErrorHandling(cfg *config.SecurityConfig) func(http.Handler) http.Handler {
 			// Recover from panics
 			defer func() {
 				if err := recover(); err != nil {
+					// Deliberate aborts reset the connection
+					if err == http.ErrAbortHandler {
+						panic(err)
+					}
 					correlationID := logger.GetCorrelationID(r.Context())
 
 					// Log panic with stack trace
@@ -61,10 +61,28 @@ func InputValidation(cfg *config.SecurityConfig) func(http.Handler) http.Handler
 				}
 			}
 
-			// Validate request body size
-			if cfg.MaxRequestBodySize > 0 {
-				// Use MaxBytesReader to limit request body size
-				r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxRequestBodySize)
+			// Validate request body size; routes may override the limit
+			maxBodySize := cfg.MaxRequestBodySize
+			if limit, ok := BodyLimit(r.Context()); ok {
+				maxBodySize = limit
+			}
+			if maxBodySize > 0 && !BodyLimitExempt(r.Context()) {
+				// Reject declared oversized bodies before reading them
+				if r.ContentLength > maxBodySize {
+					log.Warn("request body too large", logger.Fields{
+						"correlation_id": correlationID,
+						"content_length": r.ContentLength,
+						"max_size":       maxBodySize,
+						"path":           r.URL.Path,
+					})
+
+					writeErrorResponse(w, http.StatusRequestEntityTooLarge, "payload_too_large",
+						"Request body exceeds maximum size", correlationID)
+					return
+				}
+
+				// Use MaxBytesReader to limit bodies of unknown length
+				r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
 			}
 
 			next.ServeHTTP(w, r)
@@ -1,7 +1,9 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/maltehedderich/api-gateway-go/internal/logger"
@@ -15,7 +17,9 @@ type responseWriter struct {
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
-	rw.statusCode = code
+	if code >= http.StatusOK {
+		rw.statusCode = code
+	}
 	rw.ResponseWriter.WriteHeader(code)
 }
 
@@ -25,6 +29,48 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return size, err
 }
 
+// Unwrap returns the underlying ResponseWriter for http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// BackendAttempt is one try at forwarding a request to a backend
+type BackendAttempt struct {
+	Backend    string `json:"backend"`
+	Status     int    `json:"status,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// accessLogEntry collects what later handlers learn about a request after
+// it was logged as incoming, such as the authenticated caller and the
+// backend attempts
+type accessLogEntry struct {
+	mu            sync.Mutex
+	authenticated bool
+	attempts      []BackendAttempt
+}
+
+// MarkAuthenticated records that the caller of the request was
+// authenticated, so the access log can tell logged-in from anonymous traffic
+func MarkAuthenticated(ctx context.Context) {
+	if entry, ok := ctx.Value(ContextKeyAccessLog).(*accessLogEntry); ok {
+		entry.mu.Lock()
+		entry.authenticated = true
+		entry.mu.Unlock()
+	}
+}
+
+// RecordBackendAttempt adds an attempt to the access log entry of the
+// request, so retried requests show every attempt and not just the outcome
+func RecordBackendAttempt(ctx context.Context, attempt BackendAttempt) {
+	if entry, ok := ctx.Value(ContextKeyAccessLog).(*accessLogEntry); ok {
+		entry.mu.Lock()
+		entry.attempts = append(entry.attempts, attempt)
+		entry.mu.Unlock()
+	}
+}
+
 // Logging returns a middleware that logs HTTP requests and responses
 func Logging() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -40,15 +86,19 @@ func Logging() func(http.Handler) http.Handler {
 			// Get logger with correlation ID
 			log := logger.FromContext(r.Context(), "http")
 
+			// Let later handlers report the caller and backend attempts
+			entry := &accessLogEntry{}
+			r = r.WithContext(context.WithValue(r.Context(), ContextKeyAccessLog, entry))
+
 			// Log request
 			log.Info("incoming request", logger.Fields{
-				"method":      r.Method,
-				"path":        r.URL.Path,
-				"query":       sanitizeQuery(r.URL.RawQuery),
-				"remote_ip":   getClientIP(r),
-				"user_agent":  r.UserAgent(),
-				"protocol":    r.Proto,
-				"host":        r.Host,
+				"method":         r.Method,
+				"path":           r.URL.Path,
+				"query":          sanitizeQuery(r.URL.RawQuery),
+				"remote_ip":      getClientIP(r),
+				"user_agent":     r.UserAgent(),
+				"protocol":       r.Proto,
+				"host":           r.Host,
 				"content_length": r.ContentLength,
 			})
 
@@ -68,13 +118,25 @@ func Logging() func(http.Handler) http.Handler {
 
 			// Log response
 			fields := logger.Fields{
-				"method":         r.Method,
-				"path":           r.URL.Path,
-				"status":         rw.statusCode,
-				"duration_ms":    duration.Milliseconds(),
-				"response_size":  rw.size,
-				"remote_ip":      getClientIP(r),
+				"method":        r.Method,
+				"path":          r.URL.Path,
+				"status":        rw.statusCode,
+				"duration_ms":   duration.Milliseconds(),
+				"response_size": rw.size,
+				"remote_ip":     getClientIP(r),
+			}
+
+			if IsTestTraffic(r.Context()) {
+				fields["test_traffic"] = true
+			}
+			entry.mu.Lock()
+			if entry.authenticated {
+				fields["authenticated"] = true
+			}
+			if len(entry.attempts) > 1 {
+				fields["attempts"] = entry.attempts
 			}
+			entry.mu.Unlock()
 
 			message := "request completed"
 			switch logLevel {
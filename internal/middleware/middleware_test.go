@@ -1,13 +1,23 @@
 package middleware
 
 import (
+	"bytes"
+	"compress/gzip"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/maltehedderich/api-gateway-go/internal/clientip"
 	"github.com/maltehedderich/api-gateway-go/internal/config"
 	"github.com/maltehedderich/api-gateway-go/internal/logger"
 )
@@ -95,6 +105,29 @@ func TestRecovery(t *testing.T) {
 	}
 }
 
+// TestRecoveryAbortHandler tests that deliberate aborts are not recovered
+func TestRecoveryAbortHandler(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+
+	for name, middleware := range map[string]func(http.Handler) http.Handler{
+		"recovery":       Recovery(),
+		"error handling": ErrorHandling(&config.SecurityConfig{}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if err := recover(); err != http.ErrAbortHandler {
+					t.Errorf("expected http.ErrAbortHandler to propagate, got %v", err)
+				}
+			}()
+			middleware(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil))
+		})
+	}
+}
+
 // TestLogging tests the request logging middleware
 func TestLogging(t *testing.T) {
 	// Initialize logger
@@ -238,17 +271,17 @@ func TestCorrelationID(t *testing.T) {
 // TestSecurity tests the security headers middleware
 func TestSecurity(t *testing.T) {
 	tests := []struct {
-		name           string
-		config         *SecurityConfig
+		name            string
+		config          *SecurityConfig
 		expectedHeaders map[string]string
 	}{
 		{
 			name: "HSTS enabled",
 			config: &SecurityConfig{
-				EnableHSTS: true,
-				HSTSMaxAge: 31536000,
+				EnableHSTS:            true,
+				HSTSMaxAge:            31536000,
 				HSTSIncludeSubdomains: true,
-				HSTSPreload: true,
+				HSTSPreload:           true,
 			},
 			expectedHeaders: map[string]string{
 				"Strict-Transport-Security": "max-age=31536000; includeSubDomains; preload",
@@ -351,6 +384,14 @@ func TestHTTPSRedirect(t *testing.T) {
 			path:           "/_health/live",
 			expectRedirect: false,
 		},
+		{
+			name: "Configured exempt path - no redirect",
+			setupRequest: func(r *http.Request) {
+				// No TLS, no X-Forwarded-Proto
+			},
+			path:           "/healthz",
+			expectRedirect: false,
+		},
 		{
 			name: "HTTP request - redirect to HTTPS",
 			setupRequest: func(r *http.Request) {
@@ -377,7 +418,13 @@ func TestHTTPSRedirect(t *testing.T) {
 			})
 
 			// Create middleware chain
-			middleware := HTTPSRedirect()
+			observability := &config.ObservabilityConfig{
+				HealthPath:    "/_health",
+				ReadinessPath: "/_health/ready",
+				LivenessPath:  "/_health/live",
+				ExemptPaths:   []string{"/healthz"},
+			}
+			middleware := HTTPSRedirect(observability.IsExemptPath)
 			wrappedHandler := middleware(handler)
 
 			// Execute request
@@ -503,26 +550,126 @@ func TestInputValidation(t *testing.T) {
 	}
 }
 
+// TestInputValidationBodyLimitExempt tests that exempt requests skip the body size limit
+func TestInputValidationBodyLimitExempt(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", os.Stdout)
+
+	cfg := &config.SecurityConfig{MaxRequestBodySize: 4}
+
+	tests := []struct {
+		name        string
+		exempt      bool
+		expectError bool
+	}{
+		{name: "limited request", exempt: false, expectError: true},
+		{name: "exempt request", exempt: true, expectError: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/upload", strings.NewReader("larger than four bytes"))
+			req.ContentLength = -1 // streamed, so the limit applies while reading
+			if tt.exempt {
+				req = req.WithContext(WithBodyLimitExempt(req.Context()))
+			}
+
+			var readErr error
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, readErr = io.ReadAll(r.Body)
+			})
+
+			InputValidation(cfg)(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+			if tt.expectError && readErr == nil {
+				t.Error("expected body size error, got nil")
+			}
+			if !tt.expectError && readErr != nil {
+				t.Errorf("unexpected error: %v", readErr)
+			}
+		})
+	}
+}
+
+// TestInputValidationBodyLimit tests that oversized bodies are rejected with
+// 413 and that routes override the limit
+func TestInputValidationBodyLimit(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", os.Stdout)
+
+	cfg := &config.SecurityConfig{MaxRequestBodySize: 8}
+
+	tests := []struct {
+		name           string
+		body           string
+		routeLimit     int64
+		expectedStatus int
+	}{
+		{name: "within limit", body: "small", expectedStatus: http.StatusOK},
+		{name: "declared length over limit", body: "larger than eight bytes", expectedStatus: http.StatusRequestEntityTooLarge},
+		{name: "route raises limit", body: "larger than eight bytes", routeLimit: 1024, expectedStatus: http.StatusOK},
+		{name: "route lowers limit", body: "small", routeLimit: 2, expectedStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/upload", strings.NewReader(tt.body))
+			if tt.routeLimit > 0 {
+				req = req.WithContext(WithBodyLimit(req.Context(), tt.routeLimit))
+			}
+
+			called := false
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				w.WriteHeader(http.StatusOK)
+			})
+
+			rr := httptest.NewRecorder()
+			InputValidation(cfg)(handler).ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus == http.StatusRequestEntityTooLarge {
+				if called {
+					t.Error("expected the handler not to be called")
+				}
+				var response map[string]interface{}
+				if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil || response["error"] != "payload_too_large" {
+					t.Errorf("expected payload_too_large error, got %s", rr.Body.String())
+				}
+			}
+		})
+	}
+}
+
 // TestGetClientIP tests the getClientIP utility function
 func TestGetClientIP(t *testing.T) {
 	tests := []struct {
-		name       string
+		name         string
 		setupRequest func(*http.Request)
-		expectedIP string
+		expectedIP   string
 	}{
 		{
-			name: "X-Forwarded-For header",
+			name: "untrusted X-Forwarded-For header",
 			setupRequest: func(r *http.Request) {
+				r.RemoteAddr = "192.168.1.50:12345"
 				r.Header.Set("X-Forwarded-For", "192.168.1.100, 10.0.0.1")
 			},
-			expectedIP: "192.168.1.100",
+			expectedIP: "192.168.1.50",
 		},
 		{
-			name: "X-Real-IP header",
+			name: "untrusted X-Real-IP header",
 			setupRequest: func(r *http.Request) {
+				r.RemoteAddr = "192.168.1.50:12345"
 				r.Header.Set("X-Real-IP", "192.168.1.200")
 			},
-			expectedIP: "192.168.1.200",
+			expectedIP: "192.168.1.50",
+		},
+		{
+			name: "resolved client",
+			setupRequest: func(r *http.Request) {
+				*r = *r.WithContext(clientip.WithInfo(r.Context(), clientip.Info{IP: "192.168.1.100"}))
+			},
+			expectedIP: "192.168.1.100",
 		},
 		{
 			name: "RemoteAddr",
@@ -596,6 +743,18 @@ func TestResponseWriter(t *testing.T) {
 		}
 	})
 
+	t.Run("Informational status before final status", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		rw := NewResponseWriter(rr)
+
+		rw.WriteHeader(http.StatusEarlyHints)
+		rw.WriteHeader(http.StatusAccepted)
+
+		if rw.Status() != http.StatusAccepted {
+			t.Errorf("expected status %d, got %d", http.StatusAccepted, rw.Status())
+		}
+	})
+
 	t.Run("Multiple WriteHeader calls", func(t *testing.T) {
 		rr := httptest.NewRecorder()
 		rw := NewResponseWriter(rr)
@@ -736,50 +895,6 @@ func TestIsUserAgentBlocked(t *testing.T) {
 	}
 }
 
-// TestIsHealthCheckPath tests the health check path helper
-func TestIsHealthCheckPath(t *testing.T) {
-	tests := []struct {
-		name     string
-		path     string
-		expected bool
-	}{
-		{
-			name:     "Health check root",
-			path:     "/_health",
-			expected: true,
-		},
-		{
-			name:     "Health check ready",
-			path:     "/_health/ready",
-			expected: true,
-		},
-		{
-			name:     "Health check live",
-			path:     "/_health/live",
-			expected: true,
-		},
-		{
-			name:     "Not health check",
-			path:     "/api/users",
-			expected: false,
-		},
-		{
-			name:     "Partial match",
-			path:     "/_health/other",
-			expected: false,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := isHealthCheckPath(tt.path)
-			if result != tt.expected {
-				t.Errorf("expected %v, got %v", tt.expected, result)
-			}
-		})
-	}
-}
-
 // TestBuildHSTSHeader tests the HSTS header builder
 func TestBuildHSTSHeader(t *testing.T) {
 	tests := []struct {
@@ -830,3 +945,402 @@ func TestBuildHSTSHeader(t *testing.T) {
 		})
 	}
 }
+
+// TestCompression tests response compression
+func TestCompression(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", os.Stdout)
+
+	largeBody := strings.Repeat(`{"message":"hello gateway"}`, 100)
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"br":   func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		"zstd": func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+	}
+
+	tests := []struct {
+		name             string
+		acceptEncoding   string
+		contentType      string
+		contentEncoding  string
+		body             string
+		expectedEncoding string
+	}{
+		{name: "gzip", acceptEncoding: "gzip", contentType: "application/json", body: largeBody, expectedEncoding: "gzip"},
+		{name: "brotli preferred", acceptEncoding: "gzip, br", contentType: "application/json", body: largeBody, expectedEncoding: "br"},
+		{name: "zstd", acceptEncoding: "zstd;q=1.0, gzip;q=0.5", contentType: "text/plain; charset=utf-8", body: largeBody, expectedEncoding: "zstd"},
+		{name: "below size threshold", acceptEncoding: "gzip", contentType: "application/json", body: `{"ok":true}`},
+		{name: "content type not allowed", acceptEncoding: "gzip", contentType: "image/png", body: largeBody},
+		{name: "already encoded", acceptEncoding: "gzip", contentType: "application/json", contentEncoding: "identity-custom", body: largeBody, expectedEncoding: "identity-custom"},
+		{name: "no accept encoding", contentType: "application/json", body: largeBody},
+		{name: "all encodings refused", acceptEncoding: "gzip;q=0", contentType: "application/json", body: largeBody},
+	}
+
+	cfg := &config.CompressionConfig{
+		Algorithms:   []string{"br", "zstd", "gzip"},
+		MinSize:      1024,
+		ContentTypes: []string{"text/*", "application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				if tt.contentEncoding != "" {
+					w.Header().Set("Content-Encoding", tt.contentEncoding)
+				}
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write([]byte(tt.body))
+			})
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rr := httptest.NewRecorder()
+
+			Compression(cfg)(handler).ServeHTTP(rr, req)
+
+			encoding := rr.Header().Get("Content-Encoding")
+			if encoding != tt.expectedEncoding {
+				t.Fatalf("expected Content-Encoding %q, got %q", tt.expectedEncoding, encoding)
+			}
+
+			decode, ok := decoders[encoding]
+			if !ok {
+				if rr.Body.String() != tt.body {
+					t.Error("expected uncompressed body to be unchanged")
+				}
+				return
+			}
+
+			if rr.Header().Get("Vary") != "Accept-Encoding" {
+				t.Errorf("expected Vary: Accept-Encoding, got %q", rr.Header().Get("Vary"))
+			}
+			reader, err := decode(rr.Body)
+			if err != nil {
+				t.Fatalf("failed to create decoder: %v", err)
+			}
+			decoded, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("failed to decode body: %v", err)
+			}
+			if string(decoded) != tt.body {
+				t.Error("decoded body does not match original")
+			}
+		})
+	}
+}
+
+// TestCompressionDecompressesRequests tests request body decoding
+func TestCompressionDecompressesRequests(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", os.Stdout)
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, _ = gz.Write([]byte("hello gateway"))
+	_ = gz.Close()
+
+	tests := []struct {
+		name           string
+		body           []byte
+		rawBody        bool
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "gzip body decoded", body: compressed.Bytes(), expectedStatus: http.StatusOK, expectedBody: "hello gateway"},
+		{name: "raw body untouched", body: compressed.Bytes(), rawBody: true, expectedStatus: http.StatusOK, expectedBody: compressed.String()},
+		{name: "invalid gzip rejected", body: []byte("not gzip"), expectedStatus: http.StatusBadRequest},
+	}
+
+	cfg := &config.CompressionConfig{DecompressRequests: true, MaxDecompressedBodySize: 1 << 20}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received string
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, _ := io.ReadAll(r.Body)
+				received = string(data)
+			})
+
+			req := httptest.NewRequest("POST", "/", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Encoding", "gzip")
+			if tt.rawBody {
+				req = req.WithContext(WithRawBody(req.Context()))
+			}
+			rr := httptest.NewRecorder()
+
+			Compression(cfg)(handler).ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus == http.StatusOK && received != tt.expectedBody {
+				t.Errorf("expected body %q, got %q", tt.expectedBody, received)
+			}
+		})
+	}
+}
+
+// TestDecompressBody tests the request body decoder shared with the proxy
+func TestDecompressBody(t *testing.T) {
+	payload := []byte(strings.Repeat("hello gateway ", 100))
+	encode := func(encoding string, data []byte) []byte {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+		switch encoding {
+		case "gzip":
+			w = gzip.NewWriter(&buf)
+		case "br":
+			w = brotli.NewWriter(&buf)
+		case "zstd":
+			w, _ = zstd.NewWriter(&buf)
+		}
+		_, _ = w.Write(data)
+		_ = w.Close()
+		return buf.Bytes()
+	}
+	bomb := make([]byte, DefaultMaxDecompressedBodySize+1)
+
+	tests := []struct {
+		name        string
+		encoding    string
+		body        []byte
+		limit       int64
+		expected    []byte
+		expectedErr error
+	}{
+		{name: "gzip", encoding: "gzip", body: encode("gzip", payload), expected: payload},
+		{name: "brotli", encoding: "br", body: encode("br", payload), expected: payload},
+		{name: "zstd", encoding: "zstd", body: encode("zstd", payload), expected: payload},
+		{name: "unknown encoding passed through", encoding: "deflate", body: payload, expected: payload},
+		{name: "exactly at limit", encoding: "gzip", body: encode("gzip", payload), limit: int64(len(payload)), expected: payload},
+		{name: "over limit", encoding: "zstd", body: encode("zstd", payload), limit: int64(len(payload)) - 1, expectedErr: ErrRequestBodyTooLarge},
+		{name: "zero limit uses default", encoding: "gzip", body: encode("gzip", bomb), expectedErr: ErrRequestBodyTooLarge},
+		{name: "invalid gzip", encoding: "gzip", body: []byte("not gzip"), expectedErr: ErrInvalidRequestEncoding},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Encoding", tt.encoding)
+
+			err := DecompressBody(req, tt.limit)
+			var data []byte
+			if err == nil {
+				data, err = io.ReadAll(req.Body)
+			}
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !bytes.Equal(data, tt.expected) {
+				t.Errorf("decoded %d bytes, expected %d", len(data), len(tt.expected))
+			}
+		})
+	}
+}
+
+// TestNegotiateEncoding tests Accept-Encoding negotiation
+func TestNegotiateEncoding(t *testing.T) {
+	algorithms := []string{"br", "zstd", "gzip"}
+
+	tests := []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"x-gzip", "gzip"},
+		{"gzip, br", "br"},
+		{"gzip;q=1.0, br;q=0.5", "gzip"},
+		{"*", "br"},
+		{"*;q=0.5, br;q=0", "zstd"},
+		{"identity", ""},
+		{"deflate", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.acceptEncoding, func(t *testing.T) {
+			if got := negotiateEncoding(tt.acceptEncoding, algorithms); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTestTraffic(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         config.TestTrafficConfig
+		headerValue string
+		mark        bool
+		expected    bool
+	}{
+		{
+			name:        "header with any value",
+			cfg:         config.TestTrafficConfig{Header: "X-Test-Traffic"},
+			headerValue: "1",
+			expected:    true,
+		},
+		{
+			name:     "header missing",
+			cfg:      config.TestTrafficConfig{Header: "X-Test-Traffic"},
+			expected: false,
+		},
+		{
+			name:        "header value mismatch",
+			cfg:         config.TestTrafficConfig{Header: "X-Test-Traffic", HeaderValue: "synthetic"},
+			headerValue: "1",
+			expected:    false,
+		},
+		{
+			name:     "marked later in the chain",
+			cfg:      config.TestTrafficConfig{Claim: "synthetic"},
+			mark:     true,
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var detected bool
+			inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.mark {
+					MarkTestTraffic(r.Context())
+				}
+			})
+			outer := func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					next.ServeHTTP(w, r)
+					detected = IsTestTraffic(r.Context())
+				})
+			}
+			handler := TestTraffic(&tt.cfg)(outer(inner))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.headerValue != "" {
+				req.Header.Set("X-Test-Traffic", tt.headerValue)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if detected != tt.expected {
+				t.Errorf("expected test traffic %v, got %v", tt.expected, detected)
+			}
+		})
+	}
+}
+
+func TestRequestNormalization(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", io.Discard)
+
+	tests := []struct {
+		name           string
+		cfg            config.RequestNormalizationConfig
+		query          string
+		header         http.Header
+		expectedStatus int
+		expectedQuery  string
+		expectedHeader http.Header
+	}{
+		{
+			name:           "duplicates allowed",
+			cfg:            config.RequestNormalizationConfig{DuplicateQueryParams: "allow"},
+			query:          "role=user&role=admin",
+			expectedStatus: http.StatusOK,
+			expectedQuery:  "role=user&role=admin",
+		},
+		{
+			name:           "first wins",
+			cfg:            config.RequestNormalizationConfig{DuplicateQueryParams: "first-wins"},
+			query:          "role=user&%72ole=admin",
+			expectedStatus: http.StatusOK,
+			expectedQuery:  "role=user",
+		},
+		{
+			name:           "last wins keeps repeatable parameters",
+			cfg:            config.RequestNormalizationConfig{DuplicateQueryParams: "last-wins", RepeatableQueryParams: []string{"id"}},
+			query:          "id=1&id=2&role=user&role=admin",
+			expectedStatus: http.StatusOK,
+			expectedQuery:  "id=1&id=2&role=admin",
+		},
+		{
+			name:           "unique parameters untouched",
+			cfg:            config.RequestNormalizationConfig{DuplicateQueryParams: "first-wins"},
+			query:          "b=2&a=1",
+			expectedStatus: http.StatusOK,
+			expectedQuery:  "b=2&a=1",
+		},
+		{
+			name:           "duplicates rejected",
+			cfg:            config.RequestNormalizationConfig{DuplicateQueryParams: "reject"},
+			query:          "role=user&role=admin",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "header casing merged",
+			cfg:            config.RequestNormalizationConfig{CanonicalizeHeaders: true},
+			header:         http.Header{"X-User-Id": {"1"}, "x-user-id": {"2"}},
+			expectedStatus: http.StatusOK,
+			expectedHeader: http.Header{"X-User-Id": {"1", "2"}},
+		},
+		{
+			name:           "underscore headers dropped",
+			cfg:            config.RequestNormalizationConfig{HeaderUnderscores: "drop"},
+			header:         http.Header{"X_user_id": {"1"}, "Accept": {"*/*"}},
+			expectedStatus: http.StatusOK,
+			expectedHeader: http.Header{"Accept": {"*/*"}},
+		},
+		{
+			name:           "underscore headers rejected",
+			cfg:            config.RequestNormalizationConfig{HeaderUnderscores: "reject"},
+			header:         http.Header{"X_user_id": {"1"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen *http.Request
+			handler := RequestNormalization(&tt.cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = r
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/users?"+tt.query, nil)
+			req.Header = tt.header
+			if req.Header == nil {
+				req.Header = http.Header{}
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if seen == nil {
+				return
+			}
+			if tt.query != "" && seen.URL.RawQuery != tt.expectedQuery {
+				t.Errorf("expected query %q, got %q", tt.expectedQuery, seen.URL.RawQuery)
+			}
+			if tt.expectedHeader != nil {
+				if len(seen.Header) != len(tt.expectedHeader) {
+					t.Errorf("expected headers %v, got %v", tt.expectedHeader, seen.Header)
+				}
+				for name, values := range tt.expectedHeader {
+					got := seen.Header[name]
+					slices.Sort(got)
+					if !slices.Equal(got, values) {
+						t.Errorf("expected %s %v, got %v", name, values, got)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+)
+
+// RequestNormalization returns a middleware that resolves duplicate query
+// parameters and header name casing before routing and validation, so that
+// backends cannot read a request differently than the gateway checked it
+func RequestNormalization(cfg *config.RequestNormalizationConfig) func(http.Handler) http.Handler {
+	log := logger.Get().WithComponent("middleware.normalization")
+
+	repeatable := make(map[string]bool, len(cfg.RepeatableQueryParams))
+	for _, name := range cfg.RepeatableQueryParams {
+		repeatable[name] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			correlationID := logger.GetCorrelationID(r.Context())
+
+			if cfg.HeaderUnderscores == "drop" || cfg.HeaderUnderscores == "reject" {
+				if name := underscoreHeader(r.Header); name != "" {
+					if cfg.HeaderUnderscores == "reject" {
+						log.Warn("header name with underscore rejected", logger.Fields{
+							"correlation_id": correlationID,
+							"header":         name,
+							"path":           r.URL.Path,
+						})
+						writeErrorResponse(w, http.StatusBadRequest, "invalid_header",
+							"Header names must not contain underscores", correlationID)
+						return
+					}
+					dropUnderscoreHeaders(r.Header)
+				}
+			}
+
+			if cfg.CanonicalizeHeaders {
+				canonicalizeHeaders(r.Header)
+			}
+
+			if cfg.DuplicateQueryParams != "" && cfg.DuplicateQueryParams != "allow" && r.URL.RawQuery != "" {
+				query, err := url.ParseQuery(r.URL.RawQuery)
+				if err != nil {
+					writeErrorResponse(w, http.StatusBadRequest, "invalid_query",
+						"Malformed query string", correlationID)
+					return
+				}
+				if name := duplicateParam(query, repeatable); name != "" {
+					if cfg.DuplicateQueryParams == "reject" {
+						log.Warn("duplicate query parameter rejected", logger.Fields{
+							"correlation_id": correlationID,
+							"parameter":      name,
+							"path":           r.URL.Path,
+						})
+						writeErrorResponse(w, http.StatusBadRequest, "duplicate_query_parameter",
+							"Query parameter "+name+" must not be repeated", correlationID)
+						return
+					}
+					keepOneValue(query, repeatable, cfg.DuplicateQueryParams == "last-wins")
+					r.URL.RawQuery = query.Encode()
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// duplicateParam returns the name of a parameter given more than once that
+// may not repeat, or "" if there is none. Parameters differing only in their
+// encoding, such as a and %61, count as the same parameter.
+func duplicateParam(query url.Values, repeatable map[string]bool) string {
+	for name, values := range query {
+		if len(values) > 1 && !repeatable[name] {
+			return name
+		}
+	}
+	return ""
+}
+
+// keepOneValue reduces parameters that may not repeat to their first or last
+// value
+func keepOneValue(query url.Values, repeatable map[string]bool, last bool) {
+	for name, values := range query {
+		if len(values) <= 1 || repeatable[name] {
+			continue
+		}
+		if last {
+			query[name] = values[len(values)-1:]
+		} else {
+			query[name] = values[:1]
+		}
+	}
+}
+
+// canonicalizeHeaders rewrites header names to canonical casing, merging the
+// values of names that differ only in case
+func canonicalizeHeaders(header http.Header) {
+	for name, values := range header {
+		canonical := http.CanonicalHeaderKey(name)
+		if canonical == name {
+			continue
+		}
+		delete(header, name)
+		header[canonical] = append(header[canonical], values...)
+	}
+}
+
+// underscoreHeader returns the first header name containing an underscore,
+// or "" if there is none
+func underscoreHeader(header http.Header) string {
+	for name := range header {
+		if strings.Contains(name, "_") {
+			return name
+		}
+	}
+	return ""
+}
+
+// dropUnderscoreHeaders removes header names containing underscores
+func dropUnderscoreHeaders(header http.Header) {
+	for name := range header {
+		if strings.Contains(name, "_") {
+			delete(header, name)
+		}
+	}
+}
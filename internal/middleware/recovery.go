@@ -14,6 +14,10 @@ func Recovery() func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
 				if err := recover(); err != nil {
+					// Deliberate aborts reset the connection
+					if err == http.ErrAbortHandler {
+						panic(err)
+					}
 					// Get stack trace
 					stack := debug.Stack()
 
@@ -23,11 +27,11 @@ func Recovery() func(http.Handler) http.Handler {
 					ctxLogger := compLogger.WithCorrelationID(correlationID)
 
 					ctxLogger.Error("panic recovered", logger.Fields{
-						"error":      fmt.Sprintf("%v", err),
-						"stack":      string(stack),
-						"method":     r.Method,
-						"path":       r.URL.Path,
-						"remote_ip":  getClientIP(r),
+						"error":     fmt.Sprintf("%v", err),
+						"stack":     string(stack),
+						"method":    r.Method,
+						"path":      r.URL.Path,
+						"remote_ip": getClientIP(r),
 					})
 
 					// Send error response
@@ -11,10 +11,10 @@ import (
 // SecurityConfig contains security middleware configuration
 type SecurityConfig struct {
 	// HSTS
-	EnableHSTS       bool
-	HSTSMaxAge       int
+	EnableHSTS            bool
+	HSTSMaxAge            int
 	HSTSIncludeSubdomains bool
-	HSTSPreload      bool
+	HSTSPreload           bool
 
 	// Content Security Policy
 	ContentSecurityPolicy string
@@ -105,8 +105,9 @@ func buildHSTSHeader(cfg *SecurityConfig) string {
 	return strings.Join(parts, "; ")
 }
 
-// HTTPSRedirect returns a middleware that redirects HTTP requests to HTTPS
-func HTTPSRedirect() func(http.Handler) http.Handler {
+// HTTPSRedirect returns a middleware that redirects HTTP requests to HTTPS.
+// Exempt paths, such as health checks, are served over HTTP.
+func HTTPSRedirect(isExempt func(path string) bool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Check if request is already HTTPS
@@ -122,7 +123,7 @@ func HTTPSRedirect() func(http.Handler) http.Handler {
 			}
 
 			// Skip redirect for health check endpoints
-			if isHealthCheckPath(r.URL.Path) {
+			if isExempt != nil && isExempt(r.URL.Path) {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -134,23 +135,6 @@ func HTTPSRedirect() func(http.Handler) http.Handler {
 	}
 }
 
-// isHealthCheckPath checks if the path is a health check endpoint
-func isHealthCheckPath(path string) bool {
-	healthPaths := []string{
-		"/_health",
-		"/_health/ready",
-		"/_health/live",
-	}
-
-	for _, healthPath := range healthPaths {
-		if path == healthPath {
-			return true
-		}
-	}
-
-	return false
-}
-
 // NewSecurityConfigFromConfig creates a SecurityConfig from the main config
 func NewSecurityConfigFromConfig(cfg *config.Config) *SecurityConfig {
 	return &SecurityConfig{
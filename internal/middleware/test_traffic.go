@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+// testTrafficMarker records whether a request was detected as test traffic.
+// It is shared by pointer so that detection further down the chain (e.g. from
+// token claims after authentication) is visible to outer middleware such as
+// logging and metrics.
+type testTrafficMarker struct {
+	detected bool
+}
+
+// TestTraffic returns a middleware that detects synthetic and test requests by
+// header and installs the marker used by later detection stages
+func TestTraffic(cfg *config.TestTrafficConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			marker := &testTrafficMarker{}
+			if cfg.Header != "" {
+				if value := r.Header.Get(cfg.Header); value != "" {
+					marker.detected = cfg.HeaderValue == "" || value == cfg.HeaderValue
+				}
+			}
+
+			ctx := context.WithValue(r.Context(), ContextKeyTestTraffic, marker)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// MarkTestTraffic flags the request as test traffic. It has no effect unless
+// the TestTraffic middleware ran earlier in the chain.
+func MarkTestTraffic(ctx context.Context) {
+	if marker, ok := ctx.Value(ContextKeyTestTraffic).(*testTrafficMarker); ok {
+		marker.detected = true
+	}
+}
+
+// IsTestTraffic reports whether the request was detected as test traffic
+func IsTestTraffic(ctx context.Context) bool {
+	marker, ok := ctx.Value(ContextKeyTestTraffic).(*testTrafficMarker)
+	return ok && marker.detected
+}
@@ -5,34 +5,14 @@ import (
 	"encoding/json"
 	"net"
 	"net/http"
-	"strings"
+
+	"github.com/maltehedderich/api-gateway-go/internal/clientip"
 )
 
-// getClientIP extracts the client IP from the request
+// getClientIP extracts the client IP from the request. Forwarding headers
+// are only honored from trusted proxies (see clientip).
 func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header first
-	forwarded := r.Header.Get("X-Forwarded-For")
-	if forwarded != "" {
-		// X-Forwarded-For can contain multiple IPs, get the first one
-		ips := strings.Split(forwarded, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
-	}
-
-	// Check X-Real-IP header
-	realIP := r.Header.Get("X-Real-IP")
-	if realIP != "" {
-		return realIP
-	}
-
-	// Fall back to RemoteAddr
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-
-	return ip
+	return clientip.FromRequest(r)
 }
 
 // WriteJSON writes a JSON response
@@ -63,6 +43,11 @@ func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
 
 // WriteHeader captures the status code
 func (rw *ResponseWriter) WriteHeader(statusCode int) {
+	if statusCode < http.StatusOK {
+		// Informational responses precede the final status
+		rw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
 	if !rw.wroteHeader {
 		rw.status = statusCode
 		rw.wroteHeader = true
@@ -80,6 +65,11 @@ func (rw *ResponseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Unwrap returns the underlying ResponseWriter for http.ResponseController
+func (rw *ResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // Status returns the status code
 func (rw *ResponseWriter) Status() int {
 	return rw.status
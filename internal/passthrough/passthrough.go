@@ -0,0 +1,331 @@
+// Package passthrough tunnels TLS connections to backends by SNI without
+// terminating TLS. It wraps the HTTPS listener: connections whose ClientHello
+// names a passthrough hostname are tunneled, all others are handed to the
+// HTTPS server with the ClientHello replayed.
+package passthrough
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+)
+
+// defaultConnectTimeout bounds backend dials of routes without a timeout
+const defaultConnectTimeout = 5 * time.Second
+
+// errHelloRead stops the handshake once the ClientHello has been read
+var errHelloRead = errors.New("client hello read")
+
+// route is a compiled passthrough route
+type route struct {
+	backend        string
+	connectTimeout time.Duration
+}
+
+// Listener wraps a TLS listener, tunneling passthrough connections and
+// returning all others from Accept
+type Listener struct {
+	net.Listener
+
+	exact        map[string]*route
+	wildcard     map[string]*route // by suffix, e.g. ".db.example.com"
+	helloTimeout time.Duration
+	logger       *logger.ComponentLogger
+
+	conns     chan net.Conn
+	errs      chan error
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu      sync.Mutex
+	tunnels map[net.Conn]struct{}
+	active  sync.WaitGroup
+}
+
+// NewListener wraps inner. ClientHellos must arrive within helloTimeout.
+func NewListener(inner net.Listener, routes []config.PassthroughRouteConfig, helloTimeout time.Duration) *Listener {
+	l := &Listener{
+		Listener:     inner,
+		exact:        make(map[string]*route),
+		wildcard:     make(map[string]*route),
+		helloTimeout: helloTimeout,
+		logger:       logger.Get().WithComponent("passthrough"),
+		conns:        make(chan net.Conn),
+		errs:         make(chan error, 1),
+		done:         make(chan struct{}),
+		tunnels:      make(map[net.Conn]struct{}),
+	}
+
+	for _, cfg := range routes {
+		r := &route{backend: cfg.Backend, connectTimeout: cfg.ConnectTimeout}
+		if r.connectTimeout <= 0 {
+			r.connectTimeout = defaultConnectTimeout
+		}
+		for _, name := range cfg.SNI {
+			name = strings.ToLower(name)
+			if strings.HasPrefix(name, "*.") {
+				l.wildcard[name[1:]] = r
+			} else {
+				l.exact[name] = r
+			}
+		}
+	}
+
+	go l.acceptLoop()
+	return l
+}
+
+// Accept returns the next connection that is not tunneled
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case err := <-l.errs:
+		return nil, err
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops accepting connections. Open tunnels are left to Shutdown.
+func (l *Listener) Close() error {
+	err := net.ErrClosed
+	l.closeOnce.Do(func() {
+		// Closed under mu so no tunnel is tracked once Shutdown waits
+		l.mu.Lock()
+		close(l.done)
+		l.mu.Unlock()
+		err = l.Listener.Close()
+	})
+	return err
+}
+
+// Shutdown closes the listener and waits for open tunnels to finish, closing
+// them when ctx is done
+func (l *Listener) Shutdown(ctx context.Context) error {
+	_ = l.Close()
+
+	finished := make(chan struct{})
+	go func() {
+		l.active.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		for conn := range l.tunnels {
+			_ = conn.Close()
+		}
+		l.mu.Unlock()
+		<-finished
+		return ctx.Err()
+	}
+}
+
+// acceptLoop accepts connections and dispatches each in its own goroutine,
+// so slow ClientHellos do not block other clients
+func (l *Listener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			// The server decides whether to retry temporary errors
+			select {
+			case l.errs <- err:
+			case <-l.done:
+				return
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		go l.dispatch(conn)
+	}
+}
+
+// dispatch reads the ClientHello of conn and tunnels it or hands it on
+func (l *Listener) dispatch(conn net.Conn) {
+	var peeked bytes.Buffer
+	if l.helloTimeout > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(l.helloTimeout))
+	}
+	serverName, err := readServerName(io.TeeReader(conn, &peeked))
+	_ = conn.SetReadDeadline(time.Time{})
+
+	// Connections that are not TLS or name no passthrough host are served
+	// by the HTTPS server, which reports handshake errors itself
+	if err == nil {
+		if r := l.match(serverName); r != nil {
+			l.tunnel(conn, peeked.Bytes(), serverName, r)
+			return
+		}
+	}
+
+	select {
+	case l.conns <- &replayConn{Conn: conn, replay: peeked.Bytes()}:
+	case <-l.done:
+		_ = conn.Close()
+	}
+}
+
+// match returns the route for a server name, preferring exact names over
+// the most specific wildcard
+func (l *Listener) match(serverName string) *route {
+	serverName = strings.ToLower(strings.TrimSuffix(serverName, "."))
+	if serverName == "" {
+		return nil
+	}
+	if r, ok := l.exact[serverName]; ok {
+		return r
+	}
+	for i := 0; i < len(serverName); i++ {
+		if serverName[i] != '.' {
+			continue
+		}
+		if r, ok := l.wildcard[serverName[i:]]; ok {
+			return r
+		}
+	}
+	return nil
+}
+
+// tunnel copies bytes between conn and the route's backend until either side
+// closes
+func (l *Listener) tunnel(conn net.Conn, hello []byte, serverName string, r *route) {
+	defer conn.Close()
+
+	backend, err := net.DialTimeout("tcp", r.backend, r.connectTimeout)
+	if err != nil {
+		metrics.RecordPassthroughConnection(r.backend, "dial_error")
+		l.logger.Warn("passthrough backend unreachable", logger.Fields{
+			"sni":     serverName,
+			"backend": r.backend,
+			"client":  conn.RemoteAddr().String(),
+			"error":   err.Error(),
+		})
+		return
+	}
+	defer backend.Close()
+
+	if !l.track(conn, backend) {
+		return
+	}
+	defer l.untrack(conn, backend)
+
+	metrics.RecordPassthroughConnection(r.backend, "tunneled")
+	metrics.IncPassthroughActive(r.backend)
+	defer metrics.DecPassthroughActive(r.backend)
+
+	if _, err := backend.Write(hello); err != nil {
+		return
+	}
+	metrics.RecordPassthroughBytes(r.backend, "upstream", int64(len(hello)))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		n, _ := io.Copy(backend, conn)
+		metrics.RecordPassthroughBytes(r.backend, "upstream", n)
+		closeWrite(backend)
+	}()
+	n, _ := io.Copy(conn, backend)
+	metrics.RecordPassthroughBytes(r.backend, "downstream", n)
+	closeWrite(conn)
+	wg.Wait()
+}
+
+// track registers an open tunnel so Shutdown can wait for or close it. It
+// returns false once the listener is closed.
+func (l *Listener) track(conns ...net.Conn) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-l.done:
+		return false
+	default:
+	}
+	l.active.Add(1)
+	for _, conn := range conns {
+		l.tunnels[conn] = struct{}{}
+	}
+	return true
+}
+
+// untrack removes a finished tunnel
+func (l *Listener) untrack(conns ...net.Conn) {
+	l.mu.Lock()
+	for _, conn := range conns {
+		delete(l.tunnels, conn)
+	}
+	l.mu.Unlock()
+	l.active.Done()
+}
+
+// closeWrite half-closes conn so the peer sees EOF while replies still flow
+func closeWrite(conn net.Conn) {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		_ = cw.CloseWrite()
+		return
+	}
+	_ = conn.Close()
+}
+
+// readServerName reads a TLS ClientHello from r and returns its SNI
+func readServerName(r io.Reader) (string, error) {
+	var serverName string
+	err := tls.Server(readOnlyConn{reader: r}, &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName = hello.ServerName
+			return nil, errHelloRead
+		},
+	}).Handshake()
+	if !errors.Is(err, errHelloRead) {
+		return "", err
+	}
+	return serverName, nil
+}
+
+// readOnlyConn feeds the ClientHello to crypto/tls without letting it write
+type readOnlyConn struct {
+	reader io.Reader
+}
+
+func (c readOnlyConn) Read(b []byte) (int, error)         { return c.reader.Read(b) }
+func (c readOnlyConn) Write(b []byte) (int, error)        { return 0, io.ErrClosedPipe }
+func (c readOnlyConn) Close() error                       { return nil }
+func (c readOnlyConn) LocalAddr() net.Addr                { return nil }
+func (c readOnlyConn) RemoteAddr() net.Addr               { return nil }
+func (c readOnlyConn) SetDeadline(t time.Time) error      { return nil }
+func (c readOnlyConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c readOnlyConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// replayConn returns the bytes read while peeking before reading from the
+// connection
+type replayConn struct {
+	net.Conn
+	replay []byte
+}
+
+// Read implements net.Conn
+func (c *replayConn) Read(b []byte) (int, error) {
+	if len(c.replay) > 0 {
+		n := copy(b, c.replay)
+		c.replay = c.replay[n:]
+		return n, nil
+	}
+	return c.Conn.Read(b)
+}
@@ -0,0 +1,161 @@
+package passthrough
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+)
+
+func TestListener(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "backend")
+	}))
+	defer backend.Close()
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	listener := NewListener(inner, []config.PassthroughRouteConfig{
+		{SNI: []string{"db.example.com", "*.mq.example.com"}, Backend: backend.Listener.Addr().String()},
+		{SNI: []string{"down.example.com"}, Backend: "127.0.0.1:1", ConnectTimeout: time.Second},
+	}, time.Second)
+
+	// The gateway's own HTTPS server serves everything else
+	local := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "gateway")
+	}))
+	local.Listener.Close()
+	local.Listener = listener
+	local.StartTLS()
+	defer local.Close()
+
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, inner.Addr().String())
+			},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		host        string
+		expected    string
+		expectError bool
+	}{
+		{"exact sni", "db.example.com", "backend", false},
+		{"wildcard sni", "eu.mq.example.com", "backend", false},
+		{"other sni", "api.example.com", "gateway", false},
+		{"wildcard does not match apex", "mq.example.com", "gateway", false},
+		{"unreachable backend", "down.example.com", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Get("https://" + tt.host + "/")
+			if tt.expectError {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("Expected connection error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != tt.expected {
+				t.Errorf("Expected response from %s, got %q", tt.expected, body)
+			}
+		})
+	}
+
+	t.Run("NonTLSClient", func(t *testing.T) {
+		conn, err := net.Dial("tcp", inner.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		defer conn.Close()
+		_, _ = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: db.example.com\r\n\r\n")
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		reply, _ := io.ReadAll(conn)
+		if strings.Contains(string(reply), "backend") {
+			t.Error("Expected plain HTTP not to reach the passthrough backend")
+		}
+	})
+}
+
+func TestListener_Shutdown(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+
+	// A backend that holds tunnels open
+	backend, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer backend.Close()
+	go func() {
+		for {
+			conn, err := backend.Accept()
+			if err != nil {
+				return
+			}
+			go func() { _, _ = io.Copy(io.Discard, conn) }()
+		}
+	}()
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	listener := NewListener(inner, []config.PassthroughRouteConfig{
+		{SNI: []string{"db.example.com"}, Backend: backend.Addr().String()},
+	}, time.Second)
+
+	go func() {
+		conn, err := tls.Dial("tcp", inner.Addr().String(), &tls.Config{ServerName: "db.example.com", InsecureSkipVerify: true})
+		if err == nil {
+			conn.Close()
+		}
+	}()
+
+	// Wait for the tunnel to open
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		listener.mu.Lock()
+		open := len(listener.tunnels)
+		listener.mu.Unlock()
+		if open > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Tunnel was not opened")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := listener.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected open tunnels to be closed at the deadline, got: %v", err)
+	}
+	if _, err := listener.Accept(); err == nil {
+		t.Error("Expected Accept to fail after shutdown")
+	}
+}
@@ -0,0 +1,38 @@
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+// applyBackendAuth sets the route's backend credential on req. Secrets are
+// resolved on every request, so rotated credential files are picked up
+// without a restart.
+func applyBackendAuth(req *http.Request, auth config.BackendAuthConfig) error {
+	switch auth.Type {
+	case "header":
+		value, err := auth.Value.Resolve()
+		if err != nil {
+			return err
+		}
+		req.Header.Set(auth.Header, value)
+	case "basic":
+		password, err := auth.Password.Resolve()
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth(auth.Username, password)
+	}
+	return nil
+}
+
+// stripBackendAuth removes the credential applyBackendAuth set on req
+func stripBackendAuth(req *http.Request, auth config.BackendAuthConfig) {
+	switch auth.Type {
+	case "header":
+		req.Header.Del(auth.Header)
+	case "basic":
+		req.Header.Del("Authorization")
+	}
+}
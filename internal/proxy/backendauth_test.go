@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+func TestBackendAuth(t *testing.T) {
+	t.Setenv("TEST_BACKEND_API_KEY", "env-key")
+
+	tests := []struct {
+		name           string
+		auth           config.BackendAuthConfig
+		expectedHeader string
+		expectedValue  string
+	}{
+		{
+			name:           "header from env",
+			auth:           config.BackendAuthConfig{Type: "header", Header: "X-API-Key", Value: config.SecretRef{Env: "TEST_BACKEND_API_KEY"}},
+			expectedHeader: "X-API-Key",
+			expectedValue:  "env-key",
+		},
+		{
+			name:           "basic auth replaces client credential",
+			auth:           config.BackendAuthConfig{Type: "basic", Username: "gateway", Password: config.SecretRef{Value: "s3cret"}},
+			expectedHeader: "Authorization",
+			expectedValue:  "Basic Z2F0ZXdheTpzM2NyZXQ=",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received http.Header
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = r.Header.Clone()
+			}))
+			defer backend.Close()
+
+			p := New(nil)
+			match := newTestMatch(backend.URL)
+			match.Route.BackendAuth = tt.auth
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set("Authorization", "Bearer client-token")
+			req.Header.Set("X-API-Key", "client-key")
+			if err := p.Forward(httptest.NewRecorder(), req, match); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := received.Get(tt.expectedHeader); got != tt.expectedValue {
+				t.Errorf("expected %s %q, got %q", tt.expectedHeader, tt.expectedValue, got)
+			}
+		})
+	}
+}
+
+func TestBackendAuthMissingFile(t *testing.T) {
+	p := New(nil)
+	match := newTestMatch("http://127.0.0.1:1")
+	match.Route.BackendAuth = config.BackendAuthConfig{
+		Type:   "header",
+		Header: "X-API-Key",
+		Value:  config.SecretRef{File: filepath.Join(t.TempDir(), "missing")},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	if err := p.Forward(httptest.NewRecorder(), req, match); err == nil {
+		t.Error("expected error for unavailable credential")
+	}
+}
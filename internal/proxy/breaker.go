@@ -0,0 +1,217 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/maltehedderich/api-gateway-go/internal/circuitbreaker"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// errFailureStatus reports a backend response whose status counts as a
+// failure to the circuit breaker
+var errFailureStatus = errors.New("backend responded with a failure status")
+
+// breakerConfigFor resolves the circuit breaker settings of a route against
+// the proxy defaults. With a probe path, client probes the backend at
+// backendURL, the route's backend or one of its instances.
+func (p *Proxy) breakerConfigFor(route *router.Route, client *http.Client, backendURL string) *circuitbreaker.Config {
+	cfg := route.CircuitBreaker.WithDefaults(p.config.CircuitBreaker)
+	breaker := &circuitbreaker.Config{
+		FailureThreshold: cfg.FailureThreshold,
+		SuccessThreshold: cfg.SuccessThreshold,
+		Timeout:          cfg.Timeout,
+		MaxRequests:      cfg.MaxHalfOpenRequests,
+		Classifier:       p.classifier,
+	}
+	if cfg.ProbePath != "" {
+		if u, err := url.Parse(backendURL); err == nil {
+			breaker.Probe = httpProbe{client: client, url: u.Scheme + "://" + u.Host + cfg.ProbePath}
+			breaker.ProbeInterval = cfg.ProbeInterval
+			breaker.ProbeTimeout = cfg.ProbeTimeout
+		}
+	}
+	if cfg.Mode == "sliding_window" {
+		breaker.Window = cfg.Window
+		breaker.MinimumCalls = cfg.MinimumCalls
+		breaker.FailureRateThreshold = cfg.FailureRateThreshold
+		breaker.SlowCallRateThreshold = cfg.SlowCallRateThreshold
+		breaker.SlowCallDuration = cfg.SlowCallDuration
+	}
+	return breaker
+}
+
+// httpProbe checks a backend by its health endpoint, which must answer 2xx.
+// It is a comparable value, so breakers recognize an unchanged probe.
+type httpProbe struct {
+	client *http.Client
+	url    string
+}
+
+// Probe sends a GET request to the health endpoint
+func (h httpProbe) Probe(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("probe responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// failureStatusCodes returns the set of backend statuses that count as
+// failures of a route
+func (p *Proxy) failureStatusCodes(route *router.Route) map[int]bool {
+	codes := route.CircuitBreaker.WithDefaults(p.config.CircuitBreaker).FailureStatusCodes
+	set := make(map[int]bool, len(codes))
+	for _, code := range codes {
+		set[code] = true
+	}
+	return set
+}
+
+// maxBreakerNotificationsInFlight caps concurrent webhook requests so a slow
+// webhook cannot pile up goroutines; excess events are dropped
+const maxBreakerNotificationsInFlight = 10
+
+// breakerWebhook posts circuit breaker state changes to a webhook
+type breakerWebhook struct {
+	cfg      config.BreakerWebhookConfig
+	client   *http.Client
+	logger   *logger.ComponentLogger
+	inFlight chan struct{}
+}
+
+func newBreakerWebhook(cfg config.BreakerWebhookConfig, client *http.Client, log *logger.ComponentLogger) *breakerWebhook {
+	return &breakerWebhook{
+		cfg:      cfg,
+		client:   client,
+		logger:   log,
+		inFlight: make(chan struct{}, maxBreakerNotificationsInFlight),
+	}
+}
+
+// notify is a circuit breaker listener sending events into the configured
+// states to the webhook in the background
+func (h *breakerWebhook) notify(event circuitbreaker.Event) {
+	if !slices.Contains(h.cfg.States, event.To.String()) {
+		return
+	}
+
+	select {
+	case h.inFlight <- struct{}{}:
+	default:
+		metrics.RecordCircuitBreakerNotification("dropped")
+		return
+	}
+
+	go func() {
+		defer func() { <-h.inFlight }()
+
+		if err := h.send(event); err != nil {
+			metrics.RecordCircuitBreakerNotification("error")
+			h.logger.Warn("failed to send circuit breaker event", logger.Fields{
+				"name":      event.Name,
+				"new_state": event.To.String(),
+				"error":     err.Error(),
+			})
+			return
+		}
+		metrics.RecordCircuitBreakerNotification("sent")
+	}()
+}
+
+// send posts event to the webhook
+func (h *breakerWebhook) send(event circuitbreaker.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// instanceBreakers are the circuit breakers of the instances of a route
+type instanceBreakers struct {
+	proxy         *Proxy
+	route         *router.Route
+	client        *http.Client
+	failureStatus map[int]bool
+}
+
+// instanceBreakersFor returns the instance breakers of a route with several
+// backend addresses, sending probes with client
+func (p *Proxy) instanceBreakersFor(route *router.Route, client *http.Client) *instanceBreakers {
+	return &instanceBreakers{
+		proxy:         p,
+		route:         route,
+		client:        client,
+		failureStatus: p.failureStatusCodes(route),
+	}
+}
+
+// breaker returns the breaker of inst, probed at the instance itself
+func (b *instanceBreakers) breaker(inst *backendInstance) *circuitbreaker.CircuitBreaker {
+	name := inst.name()
+	return b.proxy.circuitBreakers.Get(name, b.proxy.breakerConfigFor(b.route, b.client, name))
+}
+
+// ready reports whether the breaker of inst lets requests through
+func (b *instanceBreakers) ready(inst *backendInstance) bool {
+	return b.breaker(inst).Ready()
+}
+
+// do sends an attempt to inst under the protection of its breaker. Errors
+// count as failures of the instance as the classifier decides; failure
+// statuses do, although the response is returned.
+func (b *instanceBreakers) do(inst *backendInstance, attempt func() (*http.Response, error)) (*http.Response, error) {
+	var resp *http.Response
+	var err error
+	breakerErr := b.breaker(inst).Execute(func() error {
+		resp, err = attempt()
+		if err == nil && b.failureStatus[resp.StatusCode] {
+			return errFailureStatus
+		}
+		return err
+	})
+	if errors.Is(breakerErr, circuitbreaker.ErrCircuitOpen) {
+		// Other requests took the half-open slots since the instance was picked
+		return nil, breakerErr
+	}
+	return resp, err
+}
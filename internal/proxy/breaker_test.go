@@ -0,0 +1,292 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/circuitbreaker"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+// closedBackendURL returns the URL of a port nothing listens on
+func closedBackendURL(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+	return "http://" + addr
+}
+
+func TestBreakerConfigFor(t *testing.T) {
+	p := New(DefaultConfig())
+
+	match := newTestMatch("http://orders:8080")
+	match.Route.CircuitBreaker = config.CircuitBreakerConfig{FailureThreshold: 10, Timeout: 5 * time.Second}
+
+	got := p.breakerConfigFor(match.Route, p.client, match.Route.BackendURL)
+	want := circuitbreaker.Config{FailureThreshold: 10, SuccessThreshold: 2, Timeout: 5 * time.Second, MaxRequests: 3, Classifier: errorClassifier{}}
+	if *got != want {
+		t.Errorf("breakerConfigFor() = %+v, want %+v", *got, want)
+	}
+
+	match.Route.CircuitBreaker = config.CircuitBreakerConfig{ProbePath: "/health"}
+	got = p.breakerConfigFor(match.Route, p.client, "http://orders-2:8080/v1")
+	if got.Probe != (httpProbe{client: p.client, url: "http://orders-2:8080/health"}) || got.ProbeInterval != time.Second || got.ProbeTimeout != 5*time.Second {
+		t.Errorf("expected probe of the instance health endpoint, got %+v", *got)
+	}
+
+	match.Route.CircuitBreaker = config.CircuitBreakerConfig{Mode: "sliding_window", FailureRateThreshold: 25}
+	got = p.breakerConfigFor(match.Route, p.client, match.Route.BackendURL)
+	if got.Window != time.Minute || got.MinimumCalls != 20 || got.FailureRateThreshold != 25 || got.SlowCallDuration != 10*time.Second {
+		t.Errorf("expected sliding window with defaults, got %+v", *got)
+	}
+}
+
+func TestCircuitBreakerFailureStatus(t *testing.T) {
+	var calls int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer backend.Close()
+
+	cfg := DefaultConfig()
+	cfg.MaxRetries = 0
+	p := New(cfg)
+
+	match := newTestMatch(backend.URL)
+	match.Route.CircuitBreaker.FailureThreshold = 2
+
+	// 500 is not a failure status by default
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		if err := p.Forward(rr, httptest.NewRequest(http.MethodGet, "/broken", nil), match); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// 503 responses are relayed, but open the breaker
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		if err := p.Forward(rr, httptest.NewRequest(http.MethodGet, "/", nil), match); err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i+1, err)
+		}
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("request %d: expected status 503, got %d", i+1, rr.Code)
+		}
+	}
+
+	err := p.Forward(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), match)
+	if err == nil || !strings.Contains(err.Error(), "circuit breaker open") {
+		t.Errorf("expected open circuit after failure statuses, got %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 5 {
+		t.Errorf("expected 5 backend calls, got %d", got)
+	}
+}
+
+func TestRouteCircuitBreaker(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxRetries = 0
+	p := New(cfg)
+
+	match := newTestMatch(closedBackendURL(t))
+	match.Route.CircuitBreaker.FailureThreshold = 2
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		err := p.Forward(httptest.NewRecorder(), req, match)
+		if err == nil || strings.Contains(err.Error(), "circuit breaker open") {
+			t.Fatalf("request %d: expected backend error, got %v", i+1, err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	err := p.Forward(httptest.NewRecorder(), req, match)
+	if err == nil || !strings.Contains(err.Error(), "circuit breaker open") {
+		t.Errorf("expected open circuit after the route's failure threshold, got %v", err)
+	}
+}
+
+func TestErrorClassifier(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want circuitbreaker.ErrorClass
+	}{
+		{"none", nil, circuitbreaker.ErrorClassNone},
+		{"request body", fmt.Errorf("upload: %w", ErrRequestBodyTooLarge), circuitbreaker.ErrorClassRequest},
+		{"request body over limit", fmt.Errorf("failed to buffer request body: %w", &http.MaxBytesError{Limit: 16}), circuitbreaker.ErrorClassRequest},
+		{"failure status", errFailureStatus, circuitbreaker.ErrorClassStatus},
+		{"client cancel", &url.Error{Op: "Get", URL: "http://orders", Err: context.Canceled}, circuitbreaker.ErrorClassClientCancel},
+		{"per-try timeout", &url.Error{Op: "Get", URL: "http://orders", Err: context.DeadlineExceeded}, circuitbreaker.ErrorClassTimeout},
+		{"connection refused", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, circuitbreaker.ErrorClassConnection},
+		{"closed connection", io.ErrUnexpectedEOF, circuitbreaker.ErrorClassConnection},
+		{"other", errors.New("malformed HTTP response"), circuitbreaker.ErrorClassOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (errorClassifier{}).Classify(tt.err); got != tt.want {
+				t.Errorf("Classify() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientCancelIsNoBreakerFailure(t *testing.T) {
+	entered := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-r.Context().Done()
+	}))
+	defer backend.Close()
+
+	cfg := DefaultConfig()
+	cfg.MaxRetries = 0
+	p := New(cfg)
+
+	match := newTestMatch(backend.URL)
+	match.Route.CircuitBreaker.FailureThreshold = 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-entered
+		cancel()
+	}()
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	if err := p.Forward(httptest.NewRecorder(), req, match); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancelled request, got %v", err)
+	}
+
+	if state := p.CircuitBreakers().Get(backend.URL, nil).GetState(); state != circuitbreaker.StateClosed {
+		t.Errorf("expected a cancelled request to leave the breaker closed, got %s", state)
+	}
+}
+
+func TestBreakerProbe(t *testing.T) {
+	var healthy atomic.Bool
+	var requests, probes int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			atomic.AddInt32(&probes, 1)
+		} else {
+			atomic.AddInt32(&requests, 1)
+		}
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer backend.Close()
+
+	cfg := DefaultConfig()
+	cfg.MaxRetries = 0
+	p := New(cfg)
+
+	match := newTestMatch(backend.URL)
+	match.Route.CircuitBreaker = config.CircuitBreakerConfig{
+		FailureThreshold: 1,
+		SuccessThreshold: 2,
+		Timeout:          20 * time.Millisecond,
+		ProbePath:        "/health",
+		ProbeInterval:    5 * time.Millisecond,
+	}
+
+	if err := p.Forward(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), match); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Requests are rejected while the health endpoint is probed
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&probes) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the health endpoint to be probed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	err := p.Forward(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), match)
+	if err == nil || !strings.Contains(err.Error(), "circuit breaker open") {
+		t.Errorf("expected open circuit while probing, got %v", err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("expected no requests sent while probing, got %d", got-1)
+	}
+
+	healthy.Store(true)
+	breaker := p.CircuitBreakers().Get(backend.URL, nil)
+	deadline = time.Now().Add(time.Second)
+	for breaker.GetState() != circuitbreaker.StateClosed {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected breaker to close after successful probes, got %s", breaker.GetState())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := p.Forward(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), match); err != nil {
+		t.Errorf("unexpected error after recovery: %v", err)
+	}
+}
+
+func TestBreakerWebhook(t *testing.T) {
+	events := make(chan circuitbreaker.Event, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event struct {
+			circuitbreaker.Event
+			From string `json:"from"`
+			To   string `json:"to"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("invalid event: %v", err)
+		}
+		if event.To != "open" || event.From != "closed" {
+			t.Errorf("unexpected transition %s -> %s", event.From, event.To)
+		}
+		events <- event.Event
+	}))
+	defer webhook.Close()
+
+	cfg := DefaultConfig()
+	cfg.MaxRetries = 0
+	cfg.CircuitBreakerWebhook = config.BreakerWebhookConfig{URL: webhook.URL, States: []string{"open"}, Timeout: time.Second}
+	p := New(cfg)
+
+	match := newTestMatch(closedBackendURL(t))
+	match.Route.CircuitBreaker.FailureThreshold = 1
+	_ = p.Forward(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), match)
+
+	select {
+	case event := <-events:
+		if event.Name != match.Route.BackendURL || event.Reason != "failure threshold reached" {
+			t.Errorf("unexpected event %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not notified")
+	}
+
+	// Only the configured states are sent
+	if err := p.CircuitBreakers().Reset(match.Route.BackendURL); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case event := <-events:
+		t.Errorf("unexpected event %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
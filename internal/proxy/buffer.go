@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// bufferedBody holds a request body so it can be read more than once. Small
+// bodies stay in memory, larger ones are spilled to a temporary file.
+type bufferedBody struct {
+	data []byte
+	file *os.File
+	size int64
+}
+
+// needsBuffering reports whether the body of req has to be buffered for the
+// route's retry policy. Bodies that can already be recreated, bodies known to
+// exceed the buffer limit and requests that are never retried are skipped.
+func (p *Proxy) needsBuffering(req *http.Request, route *router.Route) bool {
+	if !p.canBuffer(req) {
+		return false
+	}
+
+	policy := p.retryPolicyFor(route)
+	return policy.maxAttempts > 1 && policy.methods[req.Method]
+}
+
+// canBuffer reports whether req has a body that cannot be recreated yet and
+// is not known to exceed the buffer limit. Bodies of requests expecting 100
+// Continue are left for the backend to ask for.
+func (p *Proxy) canBuffer(req *http.Request) bool {
+	if p.config.BufferMaxSize <= 0 || req.Body == nil || req.Body == http.NoBody || req.GetBody != nil || expectsContinue(req) {
+		return false
+	}
+	return req.ContentLength <= p.config.BufferMaxSize
+}
+
+// bufferRequestBody reads the body of req and sets GetBody so the body can be
+// replayed. Bodies over BufferMaxSize are streamed on without GetBody, which
+// disables retries for the request. The returned body must be closed once the
+// backend exchange is complete.
+func (p *Proxy) bufferRequestBody(req *http.Request) (*bufferedBody, error) {
+	body := req.Body
+	buffered := &bufferedBody{}
+
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, body, p.config.BufferMemoryLimit+1)
+	if err != nil && err != io.EOF {
+		_ = body.Close()
+		return nil, fmt.Errorf("failed to buffer request body: %w", err)
+	}
+
+	if n <= p.config.BufferMemoryLimit {
+		_ = body.Close()
+		buffered.data = buf.Bytes()
+		buffered.size = n
+		buffered.install(req)
+		return buffered, nil
+	}
+
+	// Spill to disk once the body outgrows the memory limit
+	file, err := os.CreateTemp(p.config.BufferTempDir, "gateway-body-*")
+	if err != nil {
+		_ = body.Close()
+		return nil, fmt.Errorf("failed to create request body buffer: %w", err)
+	}
+	buffered.file = file
+
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		_ = body.Close()
+		buffered.Close()
+		return nil, fmt.Errorf("failed to buffer request body: %w", err)
+	}
+	rest, err := io.CopyN(file, body, p.config.BufferMaxSize-n+1)
+	if err != nil && err != io.EOF {
+		_ = body.Close()
+		buffered.Close()
+		return nil, fmt.Errorf("failed to buffer request body: %w", err)
+	}
+	buffered.size = n + rest
+
+	if buffered.size > p.config.BufferMaxSize {
+		// Too large to replay: send what was read followed by the remainder
+		p.logger.Debug("request body exceeds buffer limit, retries disabled", logger.Fields{
+			"max_size": p.config.BufferMaxSize,
+		})
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(buffered.reader(), body), body}
+		return buffered, nil
+	}
+
+	_ = body.Close()
+	buffered.install(req)
+	return buffered, nil
+}
+
+// install makes the buffered body the body of req
+func (b *bufferedBody) install(req *http.Request) {
+	req.Body = io.NopCloser(b.reader())
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(b.reader()), nil
+	}
+	req.ContentLength = b.size
+}
+
+// reader returns a new reader positioned at the start of the body
+func (b *bufferedBody) reader() io.Reader {
+	if b.file != nil {
+		return io.NewSectionReader(b.file, 0, b.size)
+	}
+	return bytes.NewReader(b.data)
+}
+
+// Close removes the temporary file, if any
+func (b *bufferedBody) Close() {
+	if b.file == nil {
+		return
+	}
+	_ = b.file.Close()
+	_ = os.Remove(b.file.Name())
+}
@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+func TestRetryReplaysBufferedBody(t *testing.T) {
+	tests := []struct {
+		name             string
+		bodySize         int
+		memoryLimit      int64
+		maxSize          int64
+		expectedAttempts int
+	}{
+		{
+			name:             "body buffered in memory",
+			bodySize:         100,
+			memoryLimit:      1024,
+			maxSize:          4096,
+			expectedAttempts: 2,
+		},
+		{
+			name:             "body spilled to temp file",
+			bodySize:         2048,
+			memoryLimit:      1024,
+			maxSize:          4096,
+			expectedAttempts: 2,
+		},
+		{
+			name:             "body over max size is not retried",
+			bodySize:         8192,
+			memoryLimit:      1024,
+			maxSize:          4096,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "buffering disabled",
+			bodySize:         100,
+			memoryLimit:      0,
+			maxSize:          0,
+			expectedAttempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := strings.Repeat("x", tt.bodySize)
+
+			var mu sync.Mutex
+			var received []string
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				received = append(received, string(data))
+				attempt := len(received)
+				mu.Unlock()
+
+				if attempt == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer backend.Close()
+
+			tempDir := t.TempDir()
+			cfg := DefaultConfig()
+			cfg.RetryDelay = time.Millisecond
+			cfg.BufferMemoryLimit = tt.memoryLimit
+			cfg.BufferMaxSize = tt.maxSize
+			cfg.BufferTempDir = tempDir
+			p := New(cfg)
+
+			match := newTestMatch(backend.URL)
+			match.Route.Retry = config.RetryPolicyConfig{
+				MaxAttempts: 3,
+				StatusCodes: []int{503},
+				Methods:     []string{"POST"},
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			if err := p.Forward(httptest.NewRecorder(), req, match); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(received) != tt.expectedAttempts {
+				t.Fatalf("expected %d attempts, got %d", tt.expectedAttempts, len(received))
+			}
+			for i, got := range received {
+				if got != body {
+					t.Errorf("attempt %d: expected %d byte body, got %d bytes", i+1, len(body), len(got))
+				}
+			}
+
+			entries, err := os.ReadDir(tempDir)
+			if err != nil {
+				t.Fatalf("failed to read temp dir: %v", err)
+			}
+			if len(entries) != 0 {
+				t.Errorf("expected temp files to be removed, found %d", len(entries))
+			}
+		})
+	}
+}
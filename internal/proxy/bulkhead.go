@@ -0,0 +1,35 @@
+package proxy
+
+import (
+	"github.com/maltehedderich/api-gateway-go/internal/circuitbreaker"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// ErrBulkheadFull is returned when a backend has reached its in-flight limit
+var ErrBulkheadFull = circuitbreaker.ErrBulkheadFull
+
+// OverloadError is returned for requests rejected at a backend's in-flight
+// limit. It matches ErrBulkheadFull and tells the client when to retry.
+type OverloadError = circuitbreaker.OverloadError
+
+// bulkheadLimit returns the in-flight limit of the route's backend; 0 is
+// unlimited
+func (p *Proxy) bulkheadLimit(route *router.Route) int {
+	if route.MaxInFlight > 0 {
+		return route.MaxInFlight
+	}
+	return p.config.MaxInFlightPerBackend
+}
+
+// bulkheadFor returns the bulkhead of the route's backend, held by the
+// manager of its circuit breaker, or nil if requests to it are not limited.
+// Routes with their own limit get a separate bulkhead.
+func (p *Proxy) bulkheadFor(route *router.Route) *circuitbreaker.Bulkhead {
+	return p.circuitBreakers.Bulkhead(route.BackendURL, &circuitbreaker.BulkheadConfig{
+		MaxConcurrent: p.bulkheadLimit(route),
+		MaxQueue:      p.config.BulkheadQueueSize,
+		QueueTimeout:  p.config.BulkheadQueueTimeout,
+		RetryAfter:    p.config.BulkheadRetryAfter,
+		MaxRetryAfter: p.config.BulkheadMaxRetryAfter,
+	})
+}
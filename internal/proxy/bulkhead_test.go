@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForwardBulkhead(t *testing.T) {
+	tests := []struct {
+		name          string
+		backendLimit  int
+		routeLimit    int
+		expectLimited bool
+	}{
+		{name: "backend limit", backendLimit: 1, expectLimited: true},
+		{name: "route overrides backend limit", backendLimit: 5, routeLimit: 1, expectLimited: true},
+		{name: "unlimited", expectLimited: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entered := make(chan struct{}, 1)
+			unblock := make(chan struct{})
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/slow" {
+					entered <- struct{}{}
+					<-unblock
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer backend.Close()
+
+			cfg := DefaultConfig()
+			cfg.MaxInFlightPerBackend = tt.backendLimit
+			p := New(cfg)
+
+			match := newTestMatch(backend.URL)
+			match.Route.MaxInFlight = tt.routeLimit
+
+			done := make(chan error, 1)
+			go func() {
+				req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+				done <- p.Forward(httptest.NewRecorder(), req, match)
+			}()
+			<-entered
+
+			req := httptest.NewRequest(http.MethodGet, "/fast", nil)
+			err := p.Forward(httptest.NewRecorder(), req, match)
+			if tt.expectLimited && !errors.Is(err, ErrBulkheadFull) {
+				t.Errorf("expected ErrBulkheadFull while the backend is at its limit, got %v", err)
+			}
+			if !tt.expectLimited && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			close(unblock)
+			if err := <-done; err != nil {
+				t.Fatalf("unexpected error for slow request: %v", err)
+			}
+
+			// The slot is released once the slow request completes
+			req = httptest.NewRequest(http.MethodGet, "/fast", nil)
+			if err := p.Forward(httptest.NewRecorder(), req, match); err != nil {
+				t.Errorf("expected request to succeed after release, got %v", err)
+			}
+		})
+	}
+}
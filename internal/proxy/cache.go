@@ -0,0 +1,34 @@
+package proxy
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+// applyCachePolicy injects the route caching headers into a backend response.
+// Error responses are left alone so failures are never cached for long.
+func applyCachePolicy(h http.Header, policy config.CachePolicyConfig, statusCode int, now time.Time) {
+	if statusCode >= http.StatusBadRequest {
+		return
+	}
+
+	setCacheHeader(h, "Cache-Control", policy.CacheControl, policy.Override)
+	setCacheHeader(h, "Surrogate-Control", policy.SurrogateControl, policy.Override)
+	if policy.Expires > 0 {
+		expires := now.Add(policy.Expires).UTC().Format(http.TimeFormat)
+		setCacheHeader(h, "Expires", expires, policy.Override)
+	}
+}
+
+// setCacheHeader sets a header, keeping an existing value unless override is set
+func setCacheHeader(h http.Header, name, value string, override bool) {
+	if value == "" {
+		return
+	}
+	if !override && h.Get(name) != "" {
+		return
+	}
+	h.Set(name, value)
+}
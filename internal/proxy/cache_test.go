@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+func TestApplyCachePolicy(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		policy   config.CachePolicyConfig
+		status   int
+		backend  map[string]string
+		expected map[string]string
+	}{
+		{
+			name:     "adds missing headers",
+			policy:   config.CachePolicyConfig{CacheControl: "public, max-age=3600", Expires: time.Hour},
+			status:   http.StatusOK,
+			expected: map[string]string{"Cache-Control": "public, max-age=3600", "Expires": "Mon, 01 Jan 2024 13:00:00 GMT"},
+		},
+		{
+			name:     "keeps backend value without override",
+			policy:   config.CachePolicyConfig{CacheControl: "public, max-age=3600"},
+			status:   http.StatusOK,
+			backend:  map[string]string{"Cache-Control": "private"},
+			expected: map[string]string{"Cache-Control": "private"},
+		},
+		{
+			name:     "overrides backend value",
+			policy:   config.CachePolicyConfig{CacheControl: "no-store", SurrogateControl: "no-store", Override: true},
+			status:   http.StatusOK,
+			backend:  map[string]string{"Cache-Control": "public, max-age=60"},
+			expected: map[string]string{"Cache-Control": "no-store", "Surrogate-Control": "no-store"},
+		},
+		{
+			name:     "skips error responses",
+			policy:   config.CachePolicyConfig{CacheControl: "public, max-age=3600", Override: true},
+			status:   http.StatusInternalServerError,
+			backend:  map[string]string{"Cache-Control": "no-cache"},
+			expected: map[string]string{"Cache-Control": "no-cache"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for name, value := range tt.backend {
+				h.Set(name, value)
+			}
+
+			applyCachePolicy(h, tt.policy, tt.status, now)
+
+			for name, expected := range tt.expected {
+				if got := h.Get(name); got != expected {
+					t.Errorf("expected %s %q, got %q", name, expected, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+)
+
+var (
+	// ErrCDNPurgeDisabled reports that no CDN is configured
+	ErrCDNPurgeDisabled = errors.New("no CDN configured")
+	// ErrCDNPurgeUnsupported reports a purge the CDN cannot express
+	ErrCDNPurgeUnsupported = errors.New("purge not supported by the CDN")
+)
+
+const (
+	fastlyEndpoint     = "https://api.fastly.com"
+	cloudfrontEndpoint = "https://cloudfront.amazonaws.com"
+)
+
+// cdnPurger forwards response cache purges to a CDN
+type cdnPurger interface {
+	purge(ctx context.Context, purge CachePurge) error
+}
+
+// newCDNPurger returns the purger of the configured CDN, or nil if none is
+func newCDNPurger(cfg config.CDNPurgeConfig, client *http.Client) cdnPurger {
+	switch cfg.Provider {
+	case "fastly":
+		return &fastlyPurger{cfg: cfg, client: client, endpoint: fastlyEndpoint}
+	case "cloudfront":
+		return &cloudfrontPurger{cfg: cfg, client: client, endpoint: cloudfrontEndpoint}
+	default:
+		return nil
+	}
+}
+
+// PurgeCDN forwards a purge of the response cache to the configured CDN. It
+// returns ErrCDNPurgeDisabled without a CDN and ErrCDNPurgeUnsupported if
+// the CDN cannot select the purged responses.
+func (p *Proxy) PurgeCDN(ctx context.Context, purge CachePurge) error {
+	if p.cdnPurger == nil {
+		return ErrCDNPurgeDisabled
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.config.CDNPurge.Timeout)
+	defer cancel()
+
+	err := p.cdnPurger.purge(ctx, purge)
+	switch {
+	case errors.Is(err, ErrCDNPurgeUnsupported):
+		metrics.RecordCDNPurge(p.config.CDNPurge.Provider, "unsupported")
+	case err != nil:
+		metrics.RecordCDNPurge(p.config.CDNPurge.Provider, "error")
+	default:
+		metrics.RecordCDNPurge(p.config.CDNPurge.Provider, "purged")
+	}
+	return err
+}
+
+// fastlyPurger purges responses from Fastly by surrogate key. Route and
+// pattern filters are ignored, which purges more than the gateway did but
+// never less.
+type fastlyPurger struct {
+	cfg      config.CDNPurgeConfig
+	client   *http.Client
+	endpoint string
+}
+
+func (f *fastlyPurger) purge(ctx context.Context, purge CachePurge) error {
+	if purge.Key == "" {
+		return ErrCDNPurgeUnsupported
+	}
+	token, err := f.cfg.Token.Resolve()
+	if err != nil {
+		return err
+	}
+
+	target := f.endpoint + "/service/" + url.PathEscape(f.cfg.ServiceID) + "/purge/" + url.PathEscape(purge.Key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Fastly-Key", token)
+	req.Header.Set("Accept", "application/json")
+	return sendCDNPurge(f.client, req)
+}
+
+// cloudfrontPurger invalidates paths in a CloudFront distribution. CloudFront
+// knows no surrogate keys, so purges by key alone are not supported.
+type cloudfrontPurger struct {
+	cfg      config.CDNPurgeConfig
+	client   *http.Client
+	endpoint string
+	now      func() time.Time
+}
+
+// cloudfrontInvalidation is the body of a CloudFront CreateInvalidation call
+type cloudfrontInvalidation struct {
+	XMLName         xml.Name `xml:"http://cloudfront.amazonaws.com/doc/2020-05-31/ InvalidationBatch"`
+	Quantity        int      `xml:"Paths>Quantity"`
+	Paths           []string `xml:"Paths>Items>Path"`
+	CallerReference string   `xml:"CallerReference"`
+}
+
+func (c *cloudfrontPurger) purge(ctx context.Context, purge CachePurge) error {
+	pattern := purge.Pattern
+	if pattern == "" {
+		pattern = purge.Route
+	}
+	if pattern == "" {
+		return ErrCDNPurgeUnsupported
+	}
+	secret, err := c.cfg.SecretAccessKey.Resolve()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if c.now != nil {
+		now = c.now()
+	}
+	body, err := xml.Marshal(cloudfrontInvalidation{
+		Quantity:        1,
+		Paths:           []string{cloudfrontPath(pattern)},
+		CallerReference: strconv.FormatInt(now.UnixNano(), 10),
+	})
+	if err != nil {
+		return err
+	}
+
+	target := c.endpoint + "/2020-05-31/distribution/" + url.PathEscape(c.cfg.DistributionID) + "/invalidation"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/xml")
+	signAWSv4(req, body, c.cfg.AccessKeyID, secret, "us-east-1", "cloudfront", now)
+	return sendCDNPurge(c.client, req)
+}
+
+// cloudfrontPath converts a glob or route pattern into an invalidation
+// path. CloudFront only supports a trailing wildcard, so the path is cut at
+// the first wildcard or parameter.
+func cloudfrontPath(pattern string) string {
+	if i := strings.IndexAny(pattern, "*?[{"); i >= 0 {
+		return pattern[:i] + "*"
+	}
+	return pattern
+}
+
+// sendCDNPurge sends a purge request, failing on non-2xx responses
+func sendCDNPurge(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("CDN responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// signAWSv4 signs req with AWS Signature Version 4, covering the host,
+// Content-Type and X-Amz-* headers
+func signAWSv4(req *http.Request, body []byte, accessKeyID, secret, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	uri := req.URL.EscapedPath()
+	if uri == "" {
+		uri = "/"
+	}
+	bodyHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uri,
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + secret)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
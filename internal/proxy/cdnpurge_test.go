@@ -0,0 +1,161 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+func TestCDNPurge(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         config.CDNPurgeConfig
+		purge       CachePurge
+		expectPath  string
+		expectBody  string
+		unsupported bool
+	}{
+		{
+			name:       "fastly by key",
+			cfg:        config.CDNPurgeConfig{Provider: "fastly", ServiceID: "svc", Token: config.SecretRef{Value: "fastly-token"}},
+			purge:      CachePurge{Key: "product-42", Pattern: "/products/*"},
+			expectPath: "/service/svc/purge/product-42",
+		},
+		{
+			name:        "fastly without key",
+			cfg:         config.CDNPurgeConfig{Provider: "fastly", ServiceID: "svc", Token: config.SecretRef{Value: "fastly-token"}},
+			purge:       CachePurge{Pattern: "/products/*"},
+			unsupported: true,
+		},
+		{
+			name: "cloudfront by route",
+			cfg: config.CDNPurgeConfig{
+				Provider: "cloudfront", DistributionID: "E123",
+				AccessKeyID: "AKID", SecretAccessKey: config.SecretRef{Value: "aws-secret"},
+			},
+			purge:      CachePurge{Route: "/products/{id}/reviews"},
+			expectPath: "/2020-05-31/distribution/E123/invalidation",
+			expectBody: "<Path>/products/*</Path>",
+		},
+		{
+			name: "cloudfront by key only",
+			cfg: config.CDNPurgeConfig{
+				Provider: "cloudfront", DistributionID: "E123",
+				AccessKeyID: "AKID", SecretAccessKey: config.SecretRef{Value: "aws-secret"},
+			},
+			purge:       CachePurge{Key: "product-42"},
+			unsupported: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *http.Request
+			var body string
+			cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, _ := io.ReadAll(r.Body)
+				got, body = r, string(data)
+			}))
+			defer cdn.Close()
+
+			p := New(nil)
+			tt.cfg.Timeout = time.Second
+			p.config.CDNPurge = tt.cfg
+			p.cdnPurger = newCDNPurger(tt.cfg, p.client)
+			switch purger := p.cdnPurger.(type) {
+			case *fastlyPurger:
+				purger.endpoint = cdn.URL
+			case *cloudfrontPurger:
+				purger.endpoint = cdn.URL
+			}
+
+			err := p.PurgeCDN(context.Background(), tt.purge)
+			if tt.unsupported {
+				if !errors.Is(err, ErrCDNPurgeUnsupported) {
+					t.Fatalf("expected ErrCDNPurgeUnsupported, got %v", err)
+				}
+				if got != nil {
+					t.Error("expected no request to the CDN")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got == nil || got.Method != http.MethodPost || got.URL.Path != tt.expectPath {
+				t.Fatalf("unexpected request %v", got)
+			}
+			if !strings.Contains(body, tt.expectBody) {
+				t.Errorf("expected body to contain %q, got %q", tt.expectBody, body)
+			}
+			switch tt.cfg.Provider {
+			case "fastly":
+				if got.Header.Get("Fastly-Key") != "fastly-token" {
+					t.Errorf("expected Fastly-Key header, got %q", got.Header.Get("Fastly-Key"))
+				}
+			case "cloudfront":
+				if !strings.HasPrefix(got.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+					t.Errorf("expected SigV4 authorization, got %q", got.Header.Get("Authorization"))
+				}
+			}
+		})
+	}
+
+	if err := New(nil).PurgeCDN(context.Background(), CachePurge{Key: "k"}); !errors.Is(err, ErrCDNPurgeDisabled) {
+		t.Errorf("expected ErrCDNPurgeDisabled without a CDN, got %v", err)
+	}
+}
+
+func TestCDNPurgeFailure(t *testing.T) {
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer cdn.Close()
+
+	cfg := config.CDNPurgeConfig{Provider: "fastly", ServiceID: "svc", Token: config.SecretRef{Value: "t"}, Timeout: time.Second}
+	p := New(nil)
+	p.config.CDNPurge = cfg
+	p.cdnPurger = &fastlyPurger{cfg: cfg, client: p.client, endpoint: cdn.URL}
+
+	if err := p.PurgeCDN(context.Background(), CachePurge{Key: "k"}); err == nil {
+		t.Error("expected error on 403")
+	}
+}
+
+// TestSignAWSv4 checks the signer against the get-vanilla case of the AWS
+// Signature Version 4 test suite
+func TestSignAWSv4(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	req.Header = http.Header{}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	signAWSv4(req, nil, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service", now)
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != expected {
+		t.Errorf("unexpected authorization\n got: %s\nwant: %s", got, expected)
+	}
+}
+
+func TestCloudfrontPath(t *testing.T) {
+	for pattern, expected := range map[string]string{
+		"/products/42":       "/products/42",
+		"/products/*":        "/products/*",
+		"/products/{id}":     "/products/*",
+		"/products/*/images": "/products/*",
+		"/**":                "/*",
+	} {
+		if got := cloudfrontPath(pattern); got != expected {
+			t.Errorf("cloudfrontPath(%q) = %q, want %q", pattern, got, expected)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package proxy
+
+import (
+	"errors"
+
+	"github.com/maltehedderich/api-gateway-go/internal/circuitbreaker"
+)
+
+// errorClassifier classifies backend errors for retries, circuit breakers and
+// outlier detection alike. Invalid request bodies are the client's fault and
+// failure statuses are backend failures; other errors are classified by
+// circuitbreaker.DefaultClassifier, so requests the client cancelled count
+// against no backend.
+type errorClassifier struct{}
+
+// Classify returns the class of err
+func (errorClassifier) Classify(err error) circuitbreaker.ErrorClass {
+	switch {
+	case isRequestBodyError(err):
+		return circuitbreaker.ErrorClassRequest
+	case errors.Is(err, errFailureStatus):
+		return circuitbreaker.ErrorClassStatus
+	}
+	return circuitbreaker.DefaultClassifier{}.Classify(err)
+}
@@ -0,0 +1,33 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/maltehedderich/api-gateway-go/internal/middleware"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+var (
+	// ErrRequestBodyTooLarge is returned when a request body exceeds a route limit
+	ErrRequestBodyTooLarge = middleware.ErrRequestBodyTooLarge
+	// ErrInvalidRequestEncoding is returned when a compressed request body cannot be decoded
+	ErrInvalidRequestEncoding = middleware.ErrInvalidRequestEncoding
+)
+
+// decompressRequestBody decodes compressed request bodies of routes whose
+// backends cannot handle Content-Encoding, with the same decoder and limits
+// as the compression middleware
+func decompressRequestBody(req *http.Request, route *router.Route) error {
+	if !route.DecompressRequest {
+		return nil
+	}
+	return middleware.DecompressBody(req, route.MaxDecompressedBodySize)
+}
+
+// isRequestBodyError reports whether err was caused by the client request body
+func isRequestBodyError(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.Is(err, ErrRequestBodyTooLarge) || errors.Is(err, ErrInvalidRequestEncoding) ||
+		errors.Is(err, ErrInvalidRequestBody) || errors.As(err, &maxBytesErr)
+}
@@ -0,0 +1,148 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// gzipBytes compresses data with gzip
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		t.Fatalf("failed to compress: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("failed to compress: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// zstdBytes compresses data with zstd
+func zstdBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatalf("failed to compress: %v", err)
+	}
+	defer enc.Close()
+	return enc.EncodeAll(data, nil)
+}
+
+func TestDecompressRequest(t *testing.T) {
+	payload := []byte(strings.Repeat("hello gateway ", 100))
+
+	tests := []struct {
+		name           string
+		decompress     bool
+		maxSize        int64
+		body           []byte
+		encoding       string
+		expectedErr    error
+		expectedBody   []byte
+		expectEncoding string
+	}{
+		{
+			name:         "decompresses gzip body",
+			decompress:   true,
+			body:         gzipBytes(t, payload),
+			encoding:     "gzip",
+			expectedBody: payload,
+		},
+		{
+			name:         "decompresses zstd body",
+			decompress:   true,
+			body:         zstdBytes(t, payload),
+			encoding:     "zstd",
+			expectedBody: payload,
+		},
+		{
+			name:           "passes through when disabled",
+			decompress:     false,
+			body:           gzipBytes(t, payload),
+			encoding:       "gzip",
+			expectedBody:   gzipBytes(t, payload),
+			expectEncoding: "gzip",
+		},
+		{
+			name:         "ignores uncompressed body",
+			decompress:   true,
+			body:         payload,
+			expectedBody: payload,
+		},
+		{
+			name:         "accepts body exactly at limit",
+			decompress:   true,
+			maxSize:      int64(len(payload)),
+			body:         gzipBytes(t, payload),
+			encoding:     "gzip",
+			expectedBody: payload,
+		},
+		{
+			name:        "rejects body over limit",
+			decompress:  true,
+			maxSize:     int64(len(payload)) - 1,
+			body:        gzipBytes(t, payload),
+			encoding:    "gzip",
+			expectedErr: ErrRequestBodyTooLarge,
+		},
+		{
+			name:        "rejects invalid gzip",
+			decompress:  true,
+			body:        []byte("not gzip"),
+			encoding:    "gzip",
+			expectedErr: ErrInvalidRequestEncoding,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var receivedBody []byte
+			var receivedEncoding string
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				receivedBody, _ = io.ReadAll(r.Body)
+				receivedEncoding = r.Header.Get("Content-Encoding")
+			}))
+			defer backend.Close()
+
+			p := New(nil)
+			match := newTestMatch(backend.URL)
+			match.Route.DecompressRequest = tt.decompress
+			match.Route.MaxDecompressedBodySize = tt.maxSize
+
+			req := httptest.NewRequest(http.MethodPost, "/upload", bytes.NewReader(tt.body))
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+
+			err := p.Forward(httptest.NewRecorder(), req, match)
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !bytes.Equal(receivedBody, tt.expectedBody) {
+				t.Errorf("backend received %d bytes, expected %d", len(receivedBody), len(tt.expectedBody))
+			}
+			if receivedEncoding != tt.expectEncoding {
+				t.Errorf("expected Content-Encoding %q, got %q", tt.expectEncoding, receivedEncoding)
+			}
+		})
+	}
+}
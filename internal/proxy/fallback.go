@@ -0,0 +1,342 @@
+package proxy
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/identity"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// FallbackHeader tells clients which fallback answered instead of the
+// backend: static, cache or backend
+const FallbackHeader = "X-Gateway-Fallback"
+
+// lastGoodResponses keeps the last successful response to each GET request
+// of a route, per user, to answer while the backend's circuit breaker is
+// open. Like the response cache, it keeps a variant per value of the
+// request headers a response varies on, and never keeps private responses.
+// Least recently used entries are evicted once defaultCacheMaxEntries is
+// reached.
+type lastGoodResponses struct {
+	allowCredentialVary bool
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List
+	variants map[string]*lastGoodVariants // by primary key
+}
+
+// lastGoodVariants are the request headers the responses to a resource vary
+// on, and how many variants are kept
+type lastGoodVariants struct {
+	vary    []string
+	entries int
+}
+
+// lastGoodEntry is a kept response with the primary key of its resource
+type lastGoodEntry struct {
+	*cachedResponse
+	primary string
+}
+
+// pendingLastGood collects a response body while it is streamed to the client
+type pendingLastGood struct {
+	store   *lastGoodResponses
+	primary string
+	vary    []string
+	entry   *cachedResponse
+
+	body     io.Reader
+	complete bool
+	overflow bool
+}
+
+func newLastGoodResponses(allowCredentialVary bool) *lastGoodResponses {
+	return &lastGoodResponses{
+		allowCredentialVary: allowCredentialVary,
+		entries:             make(map[string]*list.Element),
+		lru:                 list.New(),
+		variants:            make(map[string]*lastGoodVariants),
+	}
+}
+
+// fallbackKey identifies the last good responses of a route
+func fallbackKey(route *router.Route) string {
+	return fmt.Sprintf("%s|%+v", route.PathPattern, route.Fallback)
+}
+
+// lastGoodFor returns the last good responses of a route, or nil if the
+// route has no cache fallback
+func (p *Proxy) lastGoodFor(route *router.Route) *lastGoodResponses {
+	if route.Fallback.Type != "cache" {
+		return nil
+	}
+
+	key := fallbackKey(route)
+
+	p.fallbacksMu.Lock()
+	defer p.fallbacksMu.Unlock()
+
+	if store, ok := p.fallbacks[key]; ok {
+		return store
+	}
+	store := newLastGoodResponses(route.Fallback.AllowCredentialVary)
+	p.fallbacks[key] = store
+	return store
+}
+
+// lastGoodPrimaryKey identifies the requested resource for the requesting
+// user. Requests without a user known to the gateway are told apart by their
+// credentials, as backends may authenticate them without the gateway.
+func lastGoodPrimaryKey(r *http.Request) string {
+	user := identity.UserID(r)
+	if user != "" {
+		return user + "\n" + primaryCacheKey(r)
+	}
+	return "\n" + primaryCacheKey(r) + "\n" + normalizeVaryValue("Authorization", "", r) +
+		"\n" + normalizeVaryValue("Cookie", "", r)
+}
+
+// lastGoodKey extends the primary key with the normalized values of the
+// varied request headers
+func lastGoodKey(primary string, vary []string, r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(primary)
+	b.WriteByte('\n')
+	for _, name := range vary {
+		b.WriteString(name + "=" + normalizeVaryValue(name, identity.UserID(r), r) + "\n")
+	}
+	return b.String()
+}
+
+// prepare decides whether the response about to be sent is kept as the last
+// good one. before and after are the client response headers before and
+// after the backend headers were applied. The returned pending response must
+// wrap the body, or is nil if the response is not kept.
+func (s *lastGoodResponses) prepare(r *http.Request, status int, before, after http.Header) *pendingLastGood {
+	if r.Method != http.MethodGet || r.Header.Get("Range") != "" || status != http.StatusOK {
+		return nil
+	}
+	if parseCacheControl(after).has("no-store", "private") {
+		return nil
+	}
+	vary := varyHeaders(after)
+	if slices.Contains(vary, "*") {
+		return nil
+	}
+	if !s.allowCredentialVary && slices.ContainsFunc(vary, func(name string) bool {
+		return slices.Contains(credentialHeaders, name)
+	}) {
+		return nil
+	}
+
+	header := addedHeaders(before, after)
+	for _, name := range perUserResponseHeaders {
+		header.Del(name)
+	}
+	primary := lastGoodPrimaryKey(r)
+	return &pendingLastGood{
+		store:   s,
+		primary: primary,
+		vary:    vary,
+		entry: &cachedResponse{
+			key:    lastGoodKey(primary, vary, r),
+			status: status,
+			header: header,
+			stored: time.Now(),
+		},
+	}
+}
+
+// wrap returns a reader that records the body as it is streamed
+func (p *pendingLastGood) wrap(body io.Reader) io.Reader {
+	p.body = body
+	return p
+}
+
+func (p *pendingLastGood) Read(b []byte) (int, error) {
+	n, err := p.body.Read(b)
+	if !p.overflow {
+		if len(p.entry.body)+n > defaultCacheMaxBodySize {
+			p.overflow = true
+			p.entry.body = nil
+		} else {
+			p.entry.body = append(p.entry.body, b[:n]...)
+		}
+	}
+	if err == io.EOF {
+		p.complete = true
+	}
+	return n, err
+}
+
+// keep saves the response if its body was received completely
+func (p *pendingLastGood) keep() {
+	if !p.complete || p.overflow {
+		return
+	}
+	s := p.store
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A changed Vary header invalidates the variants kept so far
+	if variants, ok := s.variants[p.primary]; ok && !slices.Equal(variants.vary, p.vary) {
+		for _, element := range s.entries {
+			if element.Value.(*lastGoodEntry).primary == p.primary {
+				s.remove(element)
+			}
+		}
+	}
+	if element, ok := s.entries[p.entry.key]; ok {
+		s.remove(element)
+	}
+	variants, ok := s.variants[p.primary]
+	if !ok {
+		variants = &lastGoodVariants{vary: p.vary}
+		s.variants[p.primary] = variants
+	}
+	variants.entries++
+	s.entries[p.entry.key] = s.lru.PushFront(&lastGoodEntry{cachedResponse: p.entry, primary: p.primary})
+
+	for s.lru.Len() > defaultCacheMaxEntries {
+		s.remove(s.lru.Back())
+	}
+}
+
+// remove deletes an entry, and the variants of its resource with the last
+// one; the caller holds mu
+func (s *lastGoodResponses) remove(element *list.Element) {
+	entry := s.lru.Remove(element).(*lastGoodEntry)
+	delete(s.entries, entry.key)
+	if variants, ok := s.variants[entry.primary]; ok {
+		variants.entries--
+		if variants.entries == 0 {
+			delete(s.variants, entry.primary)
+		}
+	}
+}
+
+// lookup returns the last good response to r, unless it is older than
+// maxAge; a zero maxAge accepts responses of any age
+func (s *lastGoodResponses) lookup(r *http.Request, maxAge time.Duration) *cachedResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	primary := lastGoodPrimaryKey(r)
+	variants, ok := s.variants[primary]
+	if !ok {
+		return nil
+	}
+	element, ok := s.entries[lastGoodKey(primary, variants.vary, r)]
+	if !ok {
+		return nil
+	}
+	entry := element.Value.(*lastGoodEntry)
+	if maxAge > 0 && time.Since(entry.stored) > maxAge {
+		return nil
+	}
+	s.lru.MoveToFront(element)
+	return entry.cachedResponse
+}
+
+// serveFallback answers r with the static or cached fallback of the route
+// while its circuit breaker is open, and reports whether it did
+func (p *Proxy) serveFallback(w http.ResponseWriter, r *http.Request, match *router.Match) bool {
+	fallback := match.Route.Fallback
+	switch fallback.Type {
+	case "static":
+		for name, value := range fallback.Headers {
+			w.Header().Set(name, value)
+		}
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		w.Header().Set(FallbackHeader, "static")
+		status := fallback.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		w.WriteHeader(status)
+		if fallback.Body != nil {
+			// The body was validated to encode with the configuration
+			body, _ := json.Marshal(fallback.Body)
+			_, _ = w.Write(body)
+		}
+
+	case "cache":
+		entry := p.lastGoodFor(match.Route).lookup(r, fallback.MaxAge)
+		if entry == nil {
+			metrics.RecordBackendFallback(match.Route.BackendURL, "cache", "unavailable")
+			return false
+		}
+		for name, values := range entry.header {
+			w.Header()[name] = slices.Clone(values)
+		}
+		w.Header().Set("Age", strconv.Itoa(int(time.Since(entry.stored).Seconds())))
+		w.Header().Set(FallbackHeader, "cache")
+		w.WriteHeader(entry.status)
+		_, _ = w.Write(entry.body)
+
+	default:
+		return false
+	}
+
+	metrics.RecordBackendFallback(match.Route.BackendURL, fallback.Type, "served")
+	return true
+}
+
+// forwardFallback sends the backend request, which the open circuit breaker
+// kept from being sent, to the fallback backend of the route instead. It
+// returns nil if the route has no fallback backend or it failed too. The
+// fallback backend gets neither retries nor a circuit breaker of its own,
+// nor the credentials the gateway added for the route's backend.
+func (p *Proxy) forwardFallback(backendReq *http.Request, r *http.Request, match *router.Match) *http.Response {
+	if match.Route.Fallback.Type != "backend" {
+		return nil
+	}
+
+	fallbackURL, err := url.Parse(match.Route.Fallback.BackendURL)
+	if err != nil {
+		return nil
+	}
+	targetURL := p.buildTargetURL(fallbackURL, r, match)
+
+	fallbackReq := backendReq.Clone(backendReq.Context())
+	fallbackReq.URL = targetURL
+	fallbackReq.Host = targetURL.Host
+	stripBackendAuth(fallbackReq, match.Route.BackendAuth)
+	if p.identityTokens != nil && !match.Route.ForwardClientToken {
+		p.identityTokens.strip(fallbackReq)
+	}
+
+	client, err := p.clientFor(match.Route)
+	var resp *http.Response
+	if err == nil {
+		resp, err = client.Do(fallbackReq)
+	}
+	if err != nil {
+		p.logger.Warn("fallback backend request failed", logger.Fields{
+			"correlation_id": logger.GetCorrelationID(r.Context()),
+			"backend_url":    match.Route.BackendURL,
+			"fallback_url":   match.Route.Fallback.BackendURL,
+			"error":          err.Error(),
+		})
+		metrics.RecordBackendFallback(match.Route.BackendURL, "backend", "unavailable")
+		return nil
+	}
+	metrics.RecordBackendFallback(match.Route.BackendURL, "backend", "served")
+	return resp
+}
@@ -0,0 +1,309 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/auth"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// openBreaker sends a request failing at the closed backend of match, which
+// opens a breaker with a failure threshold of 1
+func openBreaker(t *testing.T, p *Proxy, match *router.Match) {
+	t.Helper()
+	err := p.Forward(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), match)
+	if err == nil || strings.Contains(err.Error(), "circuit breaker open") {
+		t.Fatalf("expected backend error, got %v", err)
+	}
+}
+
+func TestStaticFallback(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxRetries = 0
+	p := New(cfg)
+
+	match := newTestMatch(closedBackendURL(t))
+	match.Route.CircuitBreaker.FailureThreshold = 1
+	match.Route.Fallback = config.FallbackConfig{
+		Type:    "static",
+		Body:    map[string]interface{}{"items": []interface{}{}, "degraded": true},
+		Headers: map[string]string{"Cache-Control": "no-store"},
+	}
+	openBreaker(t, p, match)
+
+	rr := httptest.NewRecorder()
+	if err := p.Forward(rr, httptest.NewRequest(http.MethodGet, "/orders", nil), match); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rr.Code)
+	}
+	if got := rr.Body.String(); got != `{"degraded":true,"items":[]}` {
+		t.Errorf("unexpected body %q", got)
+	}
+	if rr.Header().Get("Content-Type") != "application/json" || rr.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("unexpected headers %v", rr.Header())
+	}
+	if rr.Header().Get(FallbackHeader) != "static" {
+		t.Errorf("expected %s: static, got %q", FallbackHeader, rr.Header().Get(FallbackHeader))
+	}
+}
+
+func TestCacheFallback(t *testing.T) {
+	var failing atomic.Bool
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("X-Version", "7")
+		w.Header().Set("Set-Cookie", "session=abc")
+		if r.URL.Path == "/private" {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		_, _ = w.Write([]byte("orders of " + r.URL.Query().Get("user")))
+	}))
+	defer backend.Close()
+
+	cfg := DefaultConfig()
+	cfg.MaxRetries = 0
+	p := New(cfg)
+
+	match := newTestMatch(backend.URL)
+	match.Route.CircuitBreaker.FailureThreshold = 1
+	match.Route.Fallback = config.FallbackConfig{Type: "cache"}
+
+	request := func(target, user string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		return req.WithContext(auth.SetUserContext(req.Context(), &auth.UserContext{UserID: user}))
+	}
+
+	for _, target := range []string{"/orders?user=alice", "/private"} {
+		if err := p.Forward(httptest.NewRecorder(), request(target, "alice"), match); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	failing.Store(true)
+	if err := p.Forward(httptest.NewRecorder(), request("/", "alice"), match); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	if err := p.Forward(rr, request("/orders?user=alice", "alice"), match); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rr.Body.String() != "orders of alice" || rr.Header().Get("X-Version") != "7" {
+		t.Errorf("expected last good response, got %q with headers %v", rr.Body.String(), rr.Header())
+	}
+	if rr.Header().Get(FallbackHeader) != "cache" || rr.Header().Get("Age") == "" {
+		t.Errorf("expected fallback and age headers, got %v", rr.Header())
+	}
+	if rr.Header().Get("Set-Cookie") != "" {
+		t.Error("per-user header replayed from the last good response")
+	}
+
+	// Other users, other resources and responses marked no-store have no
+	// last good response
+	for _, tc := range []struct{ target, user string }{
+		{"/orders?user=alice", "bob"},
+		{"/orders?user=bob", "alice"},
+		{"/private", "alice"},
+	} {
+		err := p.Forward(httptest.NewRecorder(), request(tc.target, tc.user), match)
+		if err == nil || !strings.Contains(err.Error(), "circuit breaker open") {
+			t.Errorf("%s for %s: expected open circuit, got %v", tc.target, tc.user, err)
+		}
+	}
+}
+
+func TestCacheFallbackAnonymousClients(t *testing.T) {
+	var failing atomic.Bool
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		switch r.URL.Path {
+		case "/account":
+			// The backend authenticates the cookie itself
+			cookie, _ := r.Cookie("session")
+			_, _ = w.Write([]byte("account of " + cookie.Value))
+		case "/private":
+			w.Header().Set("Cache-Control", "private")
+			_, _ = w.Write([]byte("private"))
+		case "/varies":
+			w.Header().Set("Vary", "Cookie")
+			_, _ = w.Write([]byte("varies"))
+		case "/language":
+			w.Header().Set("Vary", "Accept-Language")
+			_, _ = w.Write([]byte("language " + r.Header.Get("Accept-Language")))
+		}
+	}))
+	defer backend.Close()
+
+	cfg := DefaultConfig()
+	cfg.MaxRetries = 0
+	p := New(cfg)
+
+	match := newTestMatch(backend.URL)
+	match.Route.CircuitBreaker.FailureThreshold = 1
+	match.Route.Fallback = config.FallbackConfig{Type: "cache"}
+
+	request := func(target, session, language string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.AddCookie(&http.Cookie{Name: "session", Value: session})
+		req.Header.Set("Accept-Language", language)
+		return req
+	}
+
+	for _, target := range []string{"/account", "/private", "/varies", "/language"} {
+		if err := p.Forward(httptest.NewRecorder(), request(target, "alice", "de"), match); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	failing.Store(true)
+	if err := p.Forward(httptest.NewRecorder(), request("/", "alice", "de"), match); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, tc := range []struct {
+		target, session, language string
+		want                      string
+	}{
+		{"/account", "alice", "de", "account of alice"},
+		{"/language", "alice", "de", "language de"},
+		// Another anonymous client, private responses, responses varying
+		// on credentials and other variants have no last good response
+		{"/account", "bob", "de", ""},
+		{"/private", "alice", "de", ""},
+		{"/varies", "alice", "de", ""},
+		{"/language", "alice", "fr", ""},
+	} {
+		rr := httptest.NewRecorder()
+		err := p.Forward(rr, request(tc.target, tc.session, tc.language), match)
+		if tc.want == "" {
+			if err == nil || !strings.Contains(err.Error(), "circuit breaker open") {
+				t.Errorf("%s for %s: expected open circuit, got %v", tc.target, tc.session, err)
+			}
+			continue
+		}
+		if err != nil || rr.Body.String() != tc.want {
+			t.Errorf("%s for %s: expected %q, got %q (%v)", tc.target, tc.session, tc.want, rr.Body.String(), err)
+		}
+	}
+}
+
+func TestLastGoodResponsesMaxAge(t *testing.T) {
+	store := newLastGoodResponses(false)
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	pending := store.prepare(req, http.StatusOK, http.Header{}, http.Header{})
+	if pending == nil {
+		t.Fatal("expected response to be kept")
+	}
+	_, _ = pending.wrap(strings.NewReader("ok")).Read(make([]byte, 16))
+	_, _ = pending.Read(make([]byte, 16))
+	pending.keep()
+	pending.entry.stored = time.Now().Add(-time.Minute)
+
+	if store.lookup(req, 0) == nil {
+		t.Error("expected response of any age without max age")
+	}
+	if store.lookup(req, 2*time.Minute) == nil {
+		t.Error("expected response younger than max age")
+	}
+	if store.lookup(req, 30*time.Second) != nil {
+		t.Error("expected response older than max age to be ignored")
+	}
+
+	if store.prepare(req, http.StatusNotFound, http.Header{}, http.Header{}) != nil {
+		t.Error("expected unsuccessful response not to be kept")
+	}
+	if store.prepare(httptest.NewRequest(http.MethodPost, "/orders", nil), http.StatusOK, http.Header{}, http.Header{}) != nil {
+		t.Error("expected response to POST not to be kept")
+	}
+}
+
+func TestBackendFallback(t *testing.T) {
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("replica " + r.Method + " " + r.URL.Path))
+	}))
+	defer fallback.Close()
+
+	cfg := DefaultConfig()
+	cfg.MaxRetries = 0
+	p := New(cfg)
+
+	match := newTestMatch(closedBackendURL(t))
+	match.Route.StripPrefix = "/api"
+	match.Route.CircuitBreaker.FailureThreshold = 1
+	match.Route.Fallback = config.FallbackConfig{Type: "backend", BackendURL: fallback.URL}
+	openBreaker(t, p, match)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader(`{"id":1}`))
+	if err := p.Forward(rr, req, match); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := rr.Body.String(); got != "replica POST /orders" {
+		t.Errorf("unexpected body %q", got)
+	}
+	if rr.Header().Get(FallbackHeader) != "backend" {
+		t.Errorf("expected %s: backend, got %q", FallbackHeader, rr.Header().Get(FallbackHeader))
+	}
+
+	// A failing fallback backend leaves the generic error
+	match.Route.Fallback.BackendURL = closedBackendURL(t)
+	err := p.Forward(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/orders", nil), match)
+	if err == nil || !strings.Contains(err.Error(), "circuit breaker open") {
+		t.Errorf("expected open circuit, got %v", err)
+	}
+}
+
+func TestBackendFallbackCredentialsAndTransport(t *testing.T) {
+	var received http.Header
+	fallback := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+	}))
+	defer fallback.Close()
+
+	cfg := DefaultConfig()
+	cfg.MaxRetries = 0
+	cfg.IdentityToken = config.IdentityTokenConfig{
+		Enabled:   true,
+		Header:    "X-Gateway-Identity",
+		Algorithm: "HS256",
+		Secret:    config.SecretRef{Value: "identity-secret"},
+		Issuer:    "api-gateway",
+		TTL:       time.Minute,
+	}
+	p := New(cfg)
+
+	match := newTestMatch(closedBackendURL(t))
+	match.Route.CircuitBreaker.FailureThreshold = 1
+	match.Route.BackendAuth = config.BackendAuthConfig{Type: "header", Header: "X-API-Key", Value: config.SecretRef{Value: "primary-key"}}
+	match.Route.UpstreamTLS = config.UpstreamTLSConfig{CAFile: writeCAFile(t, fallback)}
+	match.Route.Fallback = config.FallbackConfig{Type: "backend", BackendURL: fallback.URL}
+	openBreaker(t, p, match)
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req = req.WithContext(auth.SetUserContext(req.Context(), &auth.UserContext{UserID: "alice"}))
+	if err := p.Forward(httptest.NewRecorder(), req, match); err != nil {
+		t.Fatalf("expected the fallback to be reached over the route's TLS settings, got %v", err)
+	}
+	if received == nil {
+		t.Fatal("fallback backend received no request")
+	}
+	for _, name := range []string{"X-API-Key", "X-Gateway-Identity"} {
+		if value := received.Get(name); value != "" {
+			t.Errorf("expected %s of the primary backend not to reach the fallback, got %q", name, value)
+		}
+	}
+}
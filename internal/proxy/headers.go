@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/identity"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// applyHeaderRules applies route header rules in the order remove, set, add.
+// Templated values that expand to an empty string are skipped so that a
+// missing claim or parameter never produces an empty header.
+func applyHeaderRules(h http.Header, rules config.HeaderRules, r *http.Request, match *router.Match) {
+	for _, name := range rules.Remove {
+		h.Del(name)
+	}
+
+	for name, tmpl := range rules.Set {
+		if value := expandHeaderTemplate(tmpl, r, match); value != "" {
+			h.Set(name, value)
+		} else {
+			h.Del(name)
+		}
+	}
+
+	for name, tmpl := range rules.Add {
+		if value := expandHeaderTemplate(tmpl, r, match); value != "" {
+			h.Add(name, value)
+		}
+	}
+}
+
+// expandHeaderTemplate replaces ${param.name} and ${claim.name} references
+func expandHeaderTemplate(tmpl string, r *http.Request, match *router.Match) string {
+	// Never let request data inject additional header lines
+	return strings.Map(func(c rune) rune {
+		if c == '\r' || c == '\n' {
+			return -1
+		}
+		return c
+	}, expandTemplate(tmpl, r, match, nil))
+}
+
+// expandTemplate replaces template references, passing each resolved value
+// through escape if it is not nil
+func expandTemplate(tmpl string, r *http.Request, match *router.Match, escape func(string) string) string {
+	if !strings.Contains(tmpl, "${") {
+		return tmpl
+	}
+
+	var b strings.Builder
+	rest := tmpl
+	for {
+		start := strings.Index(rest, "${")
+		if start < 0 {
+			b.WriteString(rest)
+			break
+		}
+		end := strings.Index(rest[start:], "}")
+		if end < 0 {
+			b.WriteString(rest)
+			break
+		}
+
+		value := resolveTemplateRef(rest[start+2:start+end], r, match)
+		if escape != nil {
+			value = escape(value)
+		}
+		b.WriteString(rest[:start])
+		b.WriteString(value)
+		rest = rest[start+end+1:]
+	}
+
+	return b.String()
+}
+
+// resolveTemplateRef resolves a single template reference
+func resolveTemplateRef(ref string, r *http.Request, match *router.Match) string {
+	if name, ok := strings.CutPrefix(ref, "param."); ok {
+		return match.Params[name]
+	}
+	if name, ok := strings.CutPrefix(ref, "claim."); ok {
+		return identity.Claim(r, name)
+	}
+	if name, ok := strings.CutPrefix(ref, "attr."); ok {
+		return identity.Attribute(r, name)
+	}
+	return ""
+}
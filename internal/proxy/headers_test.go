@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/maltehedderich/api-gateway-go/internal/auth"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+func TestHeaderRules(t *testing.T) {
+	var received http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.Header().Set("Server", "legacy-app/1.2")
+		w.Header().Set("X-Powered-By", "php")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	p := New(nil)
+	match := newTestMatch(backend.URL)
+	match.Params = map[string]string{"tenant": "acme"}
+	match.Route.RequestHeaders = config.HeaderRules{
+		Set: map[string]string{
+			"X-Tenant-ID": "${param.tenant}",
+			"X-User-ID":   "${claim.user_id}",
+			"X-Missing":   "${claim.session_id}",
+			"X-Plan":      "${attr.plan}",
+		},
+		Add:    map[string]string{"X-Route": "tenant/${param.tenant}"},
+		Remove: []string{"Cookie"},
+	}
+	match.Route.ResponseHeaders = config.HeaderRules{
+		Set:    map[string]string{"X-Tenant-ID": "${param.tenant}"},
+		Remove: []string{"Server", "X-Powered-By"},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/tenants/acme/orders", nil)
+	req.Header.Set("Cookie", "session=secret")
+	req.Header.Set("X-Tenant-ID", "spoofed")
+	req = req.WithContext(auth.SetUserContext(req.Context(), &auth.UserContext{
+		UserID:     "user-1",
+		Attributes: map[string]interface{}{"plan": "enterprise"},
+	}))
+	rr := httptest.NewRecorder()
+
+	if err := p.Forward(rr, req, match); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedRequest := map[string]string{
+		"X-Tenant-ID": "acme",
+		"X-User-ID":   "user-1",
+		"X-Route":     "tenant/acme",
+		"X-Plan":      "enterprise",
+		"X-Missing":   "",
+		"Cookie":      "",
+	}
+	for name, expected := range expectedRequest {
+		if got := received.Get(name); got != expected {
+			t.Errorf("expected request header %s %q, got %q", name, expected, got)
+		}
+	}
+
+	expectedResponse := map[string]string{
+		"X-Tenant-ID":  "acme",
+		"Server":       "",
+		"X-Powered-By": "",
+	}
+	for name, expected := range expectedResponse {
+		if got := rr.Header().Get(name); got != expected {
+			t.Errorf("expected response header %s %q, got %q", name, expected, got)
+		}
+	}
+}
+
+func TestExpandHeaderTemplateStripsNewlines(t *testing.T) {
+	match := newTestMatch("http://backend.internal")
+	match.Params = map[string]string{"id": "1\r\nX-Injected: yes"}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if got := expandHeaderTemplate("${param.id}", req, match); got != "1X-Injected: yes" {
+		t.Errorf("expected newlines to be stripped, got %q", got)
+	}
+}
@@ -0,0 +1,212 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/maltehedderich/api-gateway-go/internal/auth"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// identityClaims are the claims of a gateway identity token
+type identityClaims struct {
+	jwt.RegisteredClaims
+	Roles       []string               `json:"roles,omitempty"`
+	Permissions []string               `json:"permissions,omitempty"`
+	Attributes  map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// identityTokens mints the identity tokens that replace client credentials
+// on backend requests. Keys are resolved on every token, so rotated key
+// files are picked up without a restart.
+type identityTokens struct {
+	cfg          config.IdentityTokenConfig
+	cookieName   string
+	tokenSources []config.TokenSource
+	method       jwt.SigningMethod
+	now          func() time.Time
+
+	mu        sync.Mutex
+	keyPEM    string
+	parsedKey interface{}
+}
+
+func newIdentityTokens(cfg config.IdentityTokenConfig, cookieName string, tokenSources []config.TokenSource) *identityTokens {
+	return &identityTokens{
+		cfg:          cfg,
+		cookieName:   cookieName,
+		tokenSources: tokenSources,
+		method:       jwt.GetSigningMethod(cfg.Algorithm),
+		now:          time.Now,
+	}
+}
+
+// apply removes the client's token from every token source of route and,
+// for authenticated requests, sets a freshly minted identity token
+func (t *identityTokens) apply(req *http.Request, original *http.Request, route *router.Route) error {
+	req.Header.Del("Authorization")
+	t.removeTokenSources(req, route)
+
+	user, ok := auth.GetUserContext(original.Context())
+	if !ok || user == nil {
+		return nil
+	}
+
+	token, err := t.mint(user)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(t.cfg.Header, "Authorization") {
+		token = "Bearer " + token
+	}
+	req.Header.Set(t.cfg.Header, token)
+	return nil
+}
+
+// strip removes the identity token apply set on req
+func (t *identityTokens) strip(req *http.Request) {
+	req.Header.Del(t.cfg.Header)
+}
+
+// mint signs an identity token for user. It never outlives the client's
+// own token.
+func (t *identityTokens) mint(user *auth.UserContext) (string, error) {
+	key, err := t.signingKey()
+	if err != nil {
+		return "", err
+	}
+
+	now := t.now()
+	expiresAt := now.Add(t.cfg.TTL)
+	if user.Claims != nil && user.Claims.ExpiresAt != nil && user.Claims.ExpiresAt.Before(expiresAt) {
+		expiresAt = user.Claims.ExpiresAt.Time
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(t.method, identityClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    t.cfg.Issuer,
+			Subject:   user.UserID,
+			Audience:  t.cfg.Audiences,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			ID:        hex.EncodeToString(id),
+		},
+		Roles:       user.Roles,
+		Permissions: user.Permissions,
+		Attributes:  user.Attributes,
+	})
+	if t.cfg.KeyID != "" {
+		token.Header["kid"] = t.cfg.KeyID
+	}
+	return token.SignedString(key)
+}
+
+// signingKey returns the current signing key, parsing it again after the
+// key file or secret changed
+func (t *identityTokens) signingKey() (interface{}, error) {
+	ref := t.cfg.Secret
+	if !strings.HasPrefix(t.cfg.Algorithm, "HS") {
+		ref = config.SecretRef{File: t.cfg.PrivateKeyFile}
+	}
+	material, err := ref.Resolve()
+	if err != nil {
+		return nil, fmt.Errorf("identity token key unavailable: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.parsedKey != nil && material == t.keyPEM {
+		return t.parsedKey, nil
+	}
+
+	key, err := parseSigningKey(t.cfg.Algorithm, []byte(material))
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity token key: %w", err)
+	}
+	t.keyPEM, t.parsedKey = material, key
+	return key, nil
+}
+
+// parseSigningKey parses the key for algorithm: a secret for HS*, a PEM
+// private key otherwise
+func parseSigningKey(algorithm string, material []byte) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(algorithm, "HS"):
+		return material, nil
+	case strings.HasPrefix(algorithm, "RS"):
+		return jwt.ParseRSAPrivateKeyFromPEM(material)
+	case strings.HasPrefix(algorithm, "ES"):
+		return jwt.ParseECPrivateKeyFromPEM(material)
+	case algorithm == "EdDSA":
+		return jwt.ParseEdPrivateKeyFromPEM(material)
+	}
+	return nil, fmt.Errorf("unsupported algorithm: %s", algorithm)
+}
+
+// removeTokenSources drops the session cookie and the cookies, headers and
+// query parameters of the token sources of route from req
+func (t *identityTokens) removeTokenSources(req *http.Request, route *router.Route) {
+	sources := t.tokenSources
+	if len(route.TokenSources) > 0 {
+		sources = route.TokenSources
+	}
+
+	cookies := map[string]bool{t.cookieName: t.cookieName != ""}
+	var params []string
+	for _, source := range sources {
+		switch source.Type {
+		case "cookie":
+			if source.Name != "" {
+				cookies[source.Name] = true
+			}
+		case "header":
+			req.Header.Del(source.Name)
+		case "query":
+			params = append(params, source.Name)
+		}
+	}
+	removeCookies(req, cookies)
+
+	if len(params) > 0 && req.URL.RawQuery != "" {
+		query := req.URL.Query()
+		removed := false
+		for _, param := range params {
+			if query.Has(param) {
+				query.Del(param)
+				removed = true
+			}
+		}
+		if removed {
+			req.URL.RawQuery = query.Encode()
+		}
+	}
+}
+
+// removeCookies drops the named cookies from req, keeping any other cookies
+func removeCookies(req *http.Request, names map[string]bool) {
+	if req.Header.Get("Cookie") == "" {
+		return
+	}
+
+	cookies := req.Cookies()
+	req.Header.Del("Cookie")
+	for _, cookie := range cookies {
+		if !names[cookie.Name] {
+			req.AddCookie(cookie)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/maltehedderich/api-gateway-go/internal/auth"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+func TestIdentityToken(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "identity.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	secret := strings.Repeat("s", 32)
+	clientExpiry := time.Now().Add(30 * time.Second).Truncate(time.Second)
+
+	tests := []struct {
+		name           string
+		cfg            config.IdentityTokenConfig
+		verifyKey      interface{}
+		forwardClient  bool
+		expectedHeader string
+	}{
+		{
+			name:           "hs256 in authorization",
+			cfg:            config.IdentityTokenConfig{Header: "Authorization", Algorithm: "HS256", Secret: config.SecretRef{Value: secret}},
+			verifyKey:      []byte(secret),
+			expectedHeader: "Authorization",
+		},
+		{
+			name:           "rs256 key file in custom header",
+			cfg:            config.IdentityTokenConfig{Header: "X-Gateway-Identity", Algorithm: "RS256", PrivateKeyFile: keyFile, KeyID: "gw-1"},
+			verifyKey:      &rsaKey.PublicKey,
+			expectedHeader: "X-Gateway-Identity",
+		},
+		{
+			name:          "forward client token",
+			cfg:           config.IdentityTokenConfig{Header: "Authorization", Algorithm: "HS256", Secret: config.SecretRef{Value: secret}},
+			forwardClient: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received http.Header
+			var receivedQuery string
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = r.Header.Clone()
+				receivedQuery = r.URL.RawQuery
+			}))
+			defer backend.Close()
+
+			tt.cfg.Enabled = true
+			tt.cfg.Issuer = "api-gateway"
+			tt.cfg.Audiences = []string{"orders"}
+			tt.cfg.TTL = time.Minute
+			cfg := DefaultConfig()
+			cfg.IdentityToken = tt.cfg
+			cfg.SessionCookieName = "session_token"
+			cfg.SessionTokenSources = []config.TokenSource{{Type: "header", Name: "X-Session-Token"}, {Type: "query", Name: "access_token"}}
+			p := New(cfg)
+			if err := p.CheckIdentityToken(); err != nil {
+				t.Fatalf("unexpected key error: %v", err)
+			}
+
+			match := newTestMatch(backend.URL)
+			match.Route.ForwardClientToken = tt.forwardClient
+
+			req := httptest.NewRequest(http.MethodGet, "/test?access_token=client-token&page=2", nil)
+			req.Header.Set("Authorization", "Bearer client-token")
+			req.Header.Set("X-Session-Token", "client-token")
+			req.Header.Set("Cookie", "session_token=client-session; theme=dark")
+			claims := &auth.Claims{}
+			claims.ExpiresAt = jwt.NewNumericDate(clientExpiry)
+			req = req.WithContext(auth.SetUserContext(req.Context(), &auth.UserContext{
+				UserID: "user-1",
+				Roles:  []string{"admin"},
+				Claims: claims,
+			}))
+			if err := p.Forward(httptest.NewRecorder(), req, match); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.forwardClient {
+				if got := received.Get("Authorization"); got != "Bearer client-token" {
+					t.Errorf("expected client token to be forwarded, got %q", got)
+				}
+				return
+			}
+
+			if strings.Contains(received.Get("Cookie"), "session_token") || !strings.Contains(received.Get("Cookie"), "theme=dark") {
+				t.Errorf("expected only the session cookie to be removed, got %q", received.Get("Cookie"))
+			}
+			if received.Get("X-Session-Token") != "" || receivedQuery != "page=2" {
+				t.Errorf("expected token sources to be removed, got header %q, query %q", received.Get("X-Session-Token"), receivedQuery)
+			}
+			if tt.expectedHeader != "Authorization" && received.Get("Authorization") != "" {
+				t.Errorf("expected client token to be removed, got %q", received.Get("Authorization"))
+			}
+
+			raw := strings.TrimPrefix(received.Get(tt.expectedHeader), "Bearer ")
+			parsed := &identityClaims{}
+			token, err := jwt.ParseWithClaims(raw, parsed, func(*jwt.Token) (interface{}, error) {
+				return tt.verifyKey, nil
+			}, jwt.WithValidMethods([]string{tt.cfg.Algorithm}), jwt.WithAudience("orders"), jwt.WithIssuer("api-gateway"))
+			if err != nil {
+				t.Fatalf("identity token invalid: %v", err)
+			}
+			if parsed.Subject != "user-1" || len(parsed.Roles) != 1 || parsed.Roles[0] != "admin" {
+				t.Errorf("unexpected claims: %+v", parsed)
+			}
+			if !parsed.ExpiresAt.Equal(clientExpiry) {
+				t.Errorf("expected expiry capped at the client token's %v, got %v", clientExpiry, parsed.ExpiresAt.Time)
+			}
+			if kid, _ := token.Header["kid"].(string); kid != tt.cfg.KeyID {
+				t.Errorf("expected kid %q, got %q", tt.cfg.KeyID, kid)
+			}
+		})
+	}
+}
+
+func TestIdentityTokenInvalidKey(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "identity.pem")
+	if err := os.WriteFile(keyFile, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.IdentityToken = config.IdentityTokenConfig{Enabled: true, Header: "Authorization", Algorithm: "RS256", PrivateKeyFile: keyFile, TTL: time.Minute}
+	if err := New(cfg).CheckIdentityToken(); err == nil {
+		t.Error("expected an invalid key to be reported")
+	}
+}
@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// withInformationalResponses forwards informational (1xx) backend responses
+// such as 103 Early Hints to the client. 100 Continue is not forwarded: the
+// server sends it itself once the transport starts reading the request body.
+func withInformationalResponses(ctx context.Context, w http.ResponseWriter) context.Context {
+	var mu sync.Mutex
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code == http.StatusContinue || code == http.StatusSwitchingProtocols {
+				return nil
+			}
+			mu.Lock()
+			defer mu.Unlock()
+
+			// Send only the backend's fields with the interim response and
+			// keep the headers prepared for the final response
+			h := w.Header()
+			prepared := h.Clone()
+			clear(h)
+			for key, values := range header {
+				h[key] = values
+			}
+			w.WriteHeader(code)
+			clear(h)
+			for key, values := range prepared {
+				h[key] = values
+			}
+			return nil
+		},
+	})
+}
+
+// expectsContinue reports whether the client waits for 100 Continue before
+// sending the request body
+func expectsContinue(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Expect"), "100-continue")
+}
@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestInformationalResponses(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+		w.WriteHeader(http.StatusEarlyHints)
+		w.Header().Del("Link")
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html></html>"))
+	}))
+	defer backend.Close()
+
+	p := New(nil)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Header", "gateway")
+		if err := p.Forward(w, r, newTestMatch(backend.URL)); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}))
+	defer gateway.Close()
+
+	var hints []textproto.MIMEHeader
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code == http.StatusEarlyHints {
+				hints = append(hints, header)
+			}
+			return nil
+		},
+	}
+	req, _ := http.NewRequest(http.MethodGet, gateway.URL+"/page", nil)
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if len(hints) != 1 || hints[0].Get("Link") != "</style.css>; rel=preload; as=style" {
+		t.Fatalf("expected one 103 response with the Link header, got %v", hints)
+	}
+	if hints[0].Get("X-Request-Header") != "" {
+		t.Error("headers prepared for the final response leaked into the 103 response")
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Request-Header") != "gateway" {
+		t.Errorf("expected final 200 with gateway headers, got %d %v", resp.StatusCode, resp.Header)
+	}
+	if resp.Header.Get("Link") != "" {
+		t.Error("early hints leaked into the final response")
+	}
+}
+
+// countingReader records whether the body was read
+type countingReader struct {
+	io.Reader
+	read atomic.Bool
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	c.read.Store(true)
+	return c.Reader.Read(p)
+}
+
+func TestExpectContinue(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/reject" {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+	defer backend.Close()
+
+	p := New(nil)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := p.Forward(w, r, newTestMatch(backend.URL)); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}))
+	defer gateway.Close()
+
+	client := &http.Client{Transport: &http.Transport{ExpectContinueTimeout: 5 * time.Second}}
+	defer client.CloseIdleConnections()
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectBodySent bool
+	}{
+		{"backend accepts body", "/upload", http.StatusOK, true},
+		{"backend rejects before body", "/reject", http.StatusRequestEntityTooLarge, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := &countingReader{Reader: strings.NewReader("payload")}
+			req, _ := http.NewRequest(http.MethodPost, gateway.URL+tt.path, body)
+			req.ContentLength = int64(len("payload"))
+			req.Header.Set("Expect", "100-continue")
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			_, _ = io.Copy(io.Discard, resp.Body)
+
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+			if body.read.Load() != tt.expectBodySent {
+				t.Errorf("expected body sent %v, got %v", tt.expectBodySent, body.read.Load())
+			}
+		})
+	}
+}
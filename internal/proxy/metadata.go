@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/auth"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+	"github.com/maltehedderich/api-gateway-go/pkg/gatewaymeta"
+)
+
+// gatewayMetadata signs the metadata header attached to backend requests
+type gatewayMetadata struct {
+	cfg      config.GatewayMetadataConfig
+	instance string
+	now      func() time.Time
+}
+
+func newGatewayMetadata(cfg config.GatewayMetadataConfig) *gatewayMetadata {
+	instance := cfg.InstanceID
+	if instance == "" {
+		instance, _ = os.Hostname()
+	}
+	return &gatewayMetadata{
+		cfg:      cfg,
+		instance: instance,
+		now:      time.Now,
+	}
+}
+
+// apply sets the signed metadata of r on req, replacing any value sent by
+// the client. The remaining rate limit is taken from the rate limit headers
+// already set on the client response.
+func (g *gatewayMetadata) apply(req *http.Request, r *http.Request, match *router.Match, responseHeader http.Header) error {
+	key, err := g.cfg.Secret.Resolve()
+	if err != nil {
+		return err
+	}
+
+	now := g.now()
+	meta := gatewaymeta.Metadata{
+		Route:         match.Route.PathPattern,
+		Policy:        auth.GetPolicyResult(r.Context()),
+		Instance:      g.instance,
+		CorrelationID: logger.GetCorrelationID(r.Context()),
+		IssuedAt:      now.UnixMilli(),
+		ExpiresAt:     now.Add(g.cfg.TTL).UnixMilli(),
+	}
+	if user, ok := auth.GetUserContext(r.Context()); ok && user != nil {
+		meta.Subject = user.UserID
+	}
+	if remaining, err := strconv.Atoi(responseHeader.Get("X-RateLimit-Remaining")); err == nil {
+		meta.RateLimitRemaining = &remaining
+	}
+
+	value, err := gatewaymeta.Sign(meta, []byte(key))
+	if err != nil {
+		return fmt.Errorf("failed to sign gateway metadata: %w", err)
+	}
+	req.Header.Set(g.cfg.Header, value)
+	return nil
+}
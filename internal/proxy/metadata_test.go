@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/auth"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/pkg/gatewaymeta"
+)
+
+func TestGatewayMetadata(t *testing.T) {
+	var received http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+	}))
+	defer backend.Close()
+
+	cfg := DefaultConfig()
+	cfg.Metadata = config.GatewayMetadataConfig{
+		Enabled:    true,
+		Header:     gatewaymeta.DefaultHeader,
+		Secret:     config.SecretRef{Value: "metadata-key"},
+		InstanceID: "gateway-0",
+		TTL:        30 * time.Second,
+	}
+	p := New(cfg)
+	match := newTestMatch(backend.URL)
+
+	tests := []struct {
+		name              string
+		user              *auth.UserContext
+		policy            string
+		rateLimit         string
+		expectedSubject   string
+		expectedRemaining *int
+	}{
+		{name: "anonymous", policy: "public"},
+		{name: "authenticated with rate limit", user: &auth.UserContext{UserID: "user-1"}, policy: "role-based", rateLimit: "7", expectedSubject: "user-1", expectedRemaining: intPtr(7)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set(gatewaymeta.DefaultHeader, "forged")
+			ctx := auth.SetPolicyResult(req.Context(), tt.policy)
+			if tt.user != nil {
+				ctx = auth.SetUserContext(ctx, tt.user)
+			}
+			req = req.WithContext(ctx)
+
+			rec := httptest.NewRecorder()
+			if tt.rateLimit != "" {
+				rec.Header().Set("X-RateLimit-Remaining", tt.rateLimit)
+			}
+			if err := p.Forward(rec, req, match); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			meta, err := gatewaymeta.Parse(received.Get(gatewaymeta.DefaultHeader), time.Now(), []byte("metadata-key"))
+			if err != nil {
+				t.Fatalf("invalid metadata: %v", err)
+			}
+			if meta.Route != match.Route.PathPattern || meta.Instance != "gateway-0" || meta.Policy != tt.policy {
+				t.Errorf("unexpected metadata: %+v", meta)
+			}
+			if meta.Subject != tt.expectedSubject {
+				t.Errorf("expected subject %q, got %q", tt.expectedSubject, meta.Subject)
+			}
+			if (meta.RateLimitRemaining == nil) != (tt.expectedRemaining == nil) ||
+				(meta.RateLimitRemaining != nil && *meta.RateLimitRemaining != *tt.expectedRemaining) {
+				t.Errorf("expected rate limit remaining %v, got %v", tt.expectedRemaining, meta.RateLimitRemaining)
+			}
+		})
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+const (
+	// defaultMirrorTimeout bounds a shadow request when the route sets none
+	defaultMirrorTimeout = 5 * time.Second
+	// maxMirrorsInFlight caps concurrent shadow requests so a slow shadow
+	// backend cannot pile up goroutines; excess mirrors are dropped
+	maxMirrorsInFlight = 100
+)
+
+// shouldMirror reports whether r is sampled for the route's shadow backend
+func (p *Proxy) shouldMirror(r *http.Request, route *router.Route) bool {
+	cfg := route.Mirror
+	if !cfg.Enabled() {
+		return false
+	}
+	if cfg.OptInHeader != "" && r.Header.Get(cfg.OptInHeader) != "" {
+		return true
+	}
+	return cfg.SamplePercent > 0 && rand.Float64()*100 < cfg.SamplePercent
+}
+
+// mirror sends a copy of the backend request to the route's shadow backend in
+// the background. The body must be replayable from memory; bodies spilled to
+// disk or streamed past the buffer limit are not mirrored.
+func (p *Proxy) mirror(req *http.Request, route *router.Route, buffered *bufferedBody) {
+	shadow := route.Mirror.BackendURL
+
+	hasBody := req.Body != nil && req.Body != http.NoBody
+	if hasBody && (req.GetBody == nil || (buffered != nil && buffered.file != nil)) {
+		metrics.RecordMirrorRequest(shadow, "skipped")
+		return
+	}
+
+	select {
+	case p.mirrors <- struct{}{}:
+	default:
+		metrics.RecordMirrorRequest(shadow, "dropped")
+		return
+	}
+
+	shadowURL, err := url.Parse(shadow)
+	if err != nil {
+		<-p.mirrors
+		metrics.RecordMirrorRequest(shadow, "error")
+		return
+	}
+
+	timeout := route.Mirror.Timeout
+	if timeout <= 0 {
+		timeout = defaultMirrorTimeout
+	}
+	// The shadow request outlives the client request
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(req.Context()), timeout)
+
+	mirrorReq := req.Clone(ctx)
+	mirrorReq.URL.Scheme = shadowURL.Scheme
+	mirrorReq.URL.Host = shadowURL.Host
+	mirrorReq.Host = shadowURL.Host
+	mirrorReq.Body = http.NoBody
+	if hasBody {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			<-p.mirrors
+			metrics.RecordMirrorRequest(shadow, "error")
+			return
+		}
+		mirrorReq.Body = body
+	}
+
+	go func() {
+		defer func() { <-p.mirrors }()
+		defer cancel()
+
+		resp, err := p.client.Do(mirrorReq)
+		if err != nil {
+			outcome := "error"
+			if isTimeout(err) {
+				outcome = "timeout"
+			}
+			metrics.RecordMirrorRequest(shadow, outcome)
+			p.logger.Debug("mirrored request failed", logger.Fields{
+				"correlation_id": logger.GetCorrelationID(req.Context()),
+				"mirror_url":     shadow,
+				"error":          err.Error(),
+			})
+			return
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		metrics.RecordMirrorRequest(shadow, strconv.Itoa(resp.StatusCode/100)+"xx")
+	}()
+}
@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+func TestMirror(t *testing.T) {
+	tests := []struct {
+		name           string
+		mirror         config.MirrorConfig
+		optIn          bool
+		expectMirrored bool
+	}{
+		{
+			name:           "all sampled",
+			mirror:         config.MirrorConfig{SamplePercent: 100},
+			expectMirrored: true,
+		},
+		{
+			name:           "opt-in header",
+			mirror:         config.MirrorConfig{OptInHeader: "X-Mirror"},
+			optIn:          true,
+			expectMirrored: true,
+		},
+		{
+			name:           "not opted in",
+			mirror:         config.MirrorConfig{OptInHeader: "X-Mirror"},
+			expectMirrored: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shadowBodies := make(chan string, 1)
+			shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				shadowBodies <- r.Method + " " + r.URL.Path + " " + string(body)
+				w.WriteHeader(http.StatusInternalServerError)
+			}))
+			defer shadow.Close()
+
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				_, _ = w.Write(body)
+			}))
+			defer backend.Close()
+
+			p := New(nil)
+			match := newTestMatch(backend.URL)
+			match.Route.Mirror = tt.mirror
+			match.Route.Mirror.BackendURL = shadow.URL
+
+			req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"id":1}`))
+			if tt.optIn {
+				req.Header.Set("X-Mirror", "1")
+			}
+			rr := httptest.NewRecorder()
+			if err := p.Forward(rr, req, match); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// The shadow backend's failure never reaches the client
+			if rr.Code != http.StatusOK || rr.Body.String() != `{"id":1}` {
+				t.Errorf("unexpected client response %d %q", rr.Code, rr.Body.String())
+			}
+
+			select {
+			case got := <-shadowBodies:
+				if !tt.expectMirrored {
+					t.Fatalf("unexpected mirrored request %q", got)
+				}
+				if got != `POST /orders {"id":1}` {
+					t.Errorf("unexpected mirrored request %q", got)
+				}
+			case <-time.After(500 * time.Millisecond):
+				if tt.expectMirrored {
+					t.Fatal("expected mirrored request")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package proxy
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// Outlier detection defaults, used when a route leaves a setting at zero
+const (
+	defaultOutlierConsecutiveErrors  = 5
+	defaultOutlierLatencyPercentile  = 99
+	defaultOutlierMinRequests        = 20
+	defaultOutlierBaseEjectionTime   = 30 * time.Second
+	defaultOutlierMaxEjectionTime    = 5 * time.Minute
+	defaultOutlierMaxEjectionPercent = 50
+)
+
+// outlierLatencyWindow is the number of recent latencies kept per instance
+const outlierLatencyWindow = 100
+
+// instancePool balances requests across the instances of a backend and, when
+// outlier detection is enabled, temporarily ejects instances that fail or
+// respond slowly. Every instance also has a circuit breaker of its own, so
+// that a failing instance is skipped rather than the whole backend rejected.
+type instancePool struct {
+	backend   string
+	detection config.OutlierDetectionConfig
+	logger    *logger.ComponentLogger
+
+	mu        sync.Mutex
+	instances []*backendInstance
+	next      int
+}
+
+// backendInstance tracks the health of a single backend address
+type backendInstance struct {
+	url *url.URL
+
+	consecutiveErrors int
+	latencies         []time.Duration
+	latencyPos        int
+	ejectedUntil      time.Time
+	ejections         int
+}
+
+// newInstancePool creates a pool of BackendURL and the route's instances
+func newInstancePool(route *router.Route, log *logger.ComponentLogger) (*instancePool, error) {
+	addresses := append([]string{route.BackendURL}, route.Instances...)
+
+	pool := &instancePool{
+		backend:   route.BackendURL,
+		detection: resolveOutlierDetection(route.OutlierDetection),
+		logger:    log,
+		instances: make([]*backendInstance, 0, len(addresses)),
+	}
+	for _, address := range addresses {
+		u, err := url.Parse(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid backend instance %q: %w", address, err)
+		}
+		pool.instances = append(pool.instances, &backendInstance{
+			url: &url.URL{Scheme: u.Scheme, Host: u.Host},
+		})
+	}
+
+	return pool, nil
+}
+
+// resolveOutlierDetection fills unset outlier detection settings with defaults
+func resolveOutlierDetection(cfg config.OutlierDetectionConfig) config.OutlierDetectionConfig {
+	if cfg.ConsecutiveErrors == 0 {
+		cfg.ConsecutiveErrors = defaultOutlierConsecutiveErrors
+	}
+	if cfg.LatencyPercentile == 0 {
+		cfg.LatencyPercentile = defaultOutlierLatencyPercentile
+	}
+	if cfg.MinRequests == 0 {
+		cfg.MinRequests = defaultOutlierMinRequests
+	}
+	if cfg.BaseEjectionTime == 0 {
+		cfg.BaseEjectionTime = defaultOutlierBaseEjectionTime
+	}
+	if cfg.MaxEjectionTime == 0 {
+		cfg.MaxEjectionTime = defaultOutlierMaxEjectionTime
+	}
+	if cfg.MaxEjectionPercent == 0 {
+		cfg.MaxEjectionPercent = defaultOutlierMaxEjectionPercent
+	}
+	return cfg
+}
+
+// pick selects the next available instance in round-robin order, skipping
+// instances that ready rejects, such as those with an open circuit breaker;
+// a nil ready accepts every instance. If every ready instance is ejected,
+// ejections are ignored rather than failing the request. pick returns nil if
+// no instance is ready.
+func (p *instancePool) pick(now time.Time, ready func(*backendInstance) bool) *backendInstance {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := len(p.instances)
+	for _, ignoreEjection := range []bool{false, true} {
+		for i := 0; i < n; i++ {
+			idx := (p.next + i) % n
+			inst := p.instances[idx]
+			if (ignoreEjection || !inst.ejected(now)) && (ready == nil || ready(inst)) {
+				p.next = idx + 1
+				return inst
+			}
+		}
+	}
+	return nil
+}
+
+// report records the outcome of a request sent to inst
+func (p *instancePool) report(inst *backendInstance, failed bool, latency time.Duration, now time.Time) {
+	if !p.detection.Enabled {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Requests sent to ejected instances (all instances ejected) do not count
+	if inst.ejected(now) {
+		return
+	}
+
+	if failed {
+		inst.consecutiveErrors++
+	} else {
+		inst.consecutiveErrors = 0
+	}
+	inst.recordLatency(latency)
+
+	reason := ""
+	switch {
+	case inst.consecutiveErrors >= p.detection.ConsecutiveErrors:
+		reason = "consecutive_errors"
+	case p.detection.LatencyThreshold > 0 && len(inst.latencies) >= p.detection.MinRequests &&
+		percentile(inst.latencies, p.detection.LatencyPercentile) > p.detection.LatencyThreshold:
+		reason = "latency"
+	}
+	if reason != "" && p.canEject(now) {
+		p.eject(inst, reason, now)
+	}
+}
+
+// canEject reports whether another instance may be ejected without exceeding
+// MaxEjectionPercent. At least one instance can always be ejected.
+func (p *instancePool) canEject(now time.Time) bool {
+	ejected := 0
+	for _, inst := range p.instances {
+		if inst.ejected(now) {
+			ejected++
+		}
+	}
+
+	allowed := len(p.instances) * p.detection.MaxEjectionPercent / 100
+	if allowed < 1 {
+		allowed = 1
+	}
+	return ejected < allowed
+}
+
+// eject removes inst from rotation; the ejection time doubles with every
+// ejection and resets once the instance stayed healthy for MaxEjectionTime
+func (p *instancePool) eject(inst *backendInstance, reason string, now time.Time) {
+	if inst.ejections > 0 && now.Sub(inst.ejectedUntil) > p.detection.MaxEjectionTime {
+		inst.ejections = 0
+	}
+	inst.ejections++
+
+	duration := p.detection.BaseEjectionTime
+	for i := 1; i < inst.ejections && duration < p.detection.MaxEjectionTime; i++ {
+		duration *= 2
+	}
+	if duration > p.detection.MaxEjectionTime {
+		duration = p.detection.MaxEjectionTime
+	}
+
+	inst.ejectedUntil = now.Add(duration)
+	inst.consecutiveErrors = 0
+	inst.latencies = inst.latencies[:0]
+	inst.latencyPos = 0
+
+	metrics.RecordOutlierEjection(p.backend, inst.url.Host, reason)
+	p.logger.Warn("backend instance ejected", logger.Fields{
+		"backend_url": p.backend,
+		"instance":    inst.url.Host,
+		"reason":      reason,
+		"duration":    duration.String(),
+		"ejections":   inst.ejections,
+	})
+}
+
+// ejected reports whether the instance is out of rotation
+func (i *backendInstance) ejected(now time.Time) bool {
+	return now.Before(i.ejectedUntil)
+}
+
+// recordLatency adds a latency sample to the instance's sliding window
+func (i *backendInstance) recordLatency(latency time.Duration) {
+	if len(i.latencies) < outlierLatencyWindow {
+		i.latencies = append(i.latencies, latency)
+		return
+	}
+	i.latencies[i.latencyPos] = latency
+	i.latencyPos = (i.latencyPos + 1) % outlierLatencyWindow
+}
+
+// name identifies the instance, and its circuit breaker, as scheme://host
+func (i *backendInstance) name() string {
+	return i.url.String()
+}
+
+// apply points req at the instance
+func (i *backendInstance) apply(req *http.Request) {
+	req.URL.Scheme = i.url.Scheme
+	req.URL.Host = i.url.Host
+	req.Host = i.url.Host
+}
+
+// percentile returns the p-th percentile (0-100) of samples
+func percentile(samples []time.Duration, p float64) time.Duration {
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a] < sorted[b] })
+
+	idx := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
+
+// instanceNames returns the names of the instances of a route with several
+// backend addresses
+func instanceNames(route *router.Route) []string {
+	if len(route.Instances) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(route.Instances)+1)
+	for _, address := range append([]string{route.BackendURL}, route.Instances...) {
+		if u, err := url.Parse(address); err == nil {
+			names = append(names, (&url.URL{Scheme: u.Scheme, Host: u.Host}).String())
+		}
+	}
+	return names
+}
+
+// instancePoolKey identifies the instance pool of a route's backend
+func instancePoolKey(route *router.Route) string {
+	return fmt.Sprintf("%s|%v|%+v", route.BackendURL, route.Instances, route.OutlierDetection)
+}
+
+// instancePoolFor returns the instance pool of a route, or nil if the route
+// has a single backend address
+func (p *Proxy) instancePoolFor(route *router.Route) (*instancePool, error) {
+	if len(route.Instances) == 0 {
+		return nil, nil
+	}
+
+	key := instancePoolKey(route)
+
+	p.poolsMu.Lock()
+	defer p.poolsMu.Unlock()
+
+	if pool, ok := p.pools[key]; ok {
+		return pool, nil
+	}
+
+	pool, err := newInstancePool(route, p.logger)
+	if err != nil {
+		return nil, err
+	}
+	p.pools[key] = pool
+	return pool, nil
+}
+
+// attemptFailed reports whether an attempt counts as an instance failure.
+// Errors count as the classifier decides, so client cancellations and
+// invalid request bodies are not the instance's fault.
+func (p *Proxy) attemptFailed(resp *http.Response, err error) bool {
+	if err != nil {
+		return p.classifier.Classify(err).Failure()
+	}
+	return resp.StatusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,295 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+func newTestPool(t *testing.T, instances int, detection config.OutlierDetectionConfig) *instancePool {
+	t.Helper()
+
+	route := &router.Route{BackendURL: "http://backend-0", OutlierDetection: detection}
+	for i := 1; i < instances; i++ {
+		route.Instances = append(route.Instances, fmt.Sprintf("http://backend-%d", i))
+	}
+
+	pool, err := newInstancePool(route, logger.Get().WithComponent("proxy"))
+	if err != nil {
+		t.Fatalf("failed to create pool: %v", err)
+	}
+	return pool
+}
+
+func TestInstancePoolEjection(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name            string
+		instances       int
+		detection       config.OutlierDetectionConfig
+		report          func(pool *instancePool)
+		expectedEjected int
+	}{
+		{
+			name:      "consecutive errors",
+			instances: 3,
+			detection: config.OutlierDetectionConfig{Enabled: true, ConsecutiveErrors: 3},
+			report: func(pool *instancePool) {
+				for i := 0; i < 3; i++ {
+					pool.report(pool.instances[0], true, time.Millisecond, now)
+				}
+			},
+			expectedEjected: 1,
+		},
+		{
+			name:      "success resets consecutive errors",
+			instances: 3,
+			detection: config.OutlierDetectionConfig{Enabled: true, ConsecutiveErrors: 3},
+			report: func(pool *instancePool) {
+				pool.report(pool.instances[0], true, time.Millisecond, now)
+				pool.report(pool.instances[0], true, time.Millisecond, now)
+				pool.report(pool.instances[0], false, time.Millisecond, now)
+				pool.report(pool.instances[0], true, time.Millisecond, now)
+			},
+			expectedEjected: 0,
+		},
+		{
+			name:      "latency percentile",
+			instances: 3,
+			detection: config.OutlierDetectionConfig{
+				Enabled:          true,
+				LatencyThreshold: 100 * time.Millisecond,
+				MinRequests:      5,
+			},
+			report: func(pool *instancePool) {
+				for i := 0; i < 5; i++ {
+					pool.report(pool.instances[1], false, 200*time.Millisecond, now)
+				}
+			},
+			expectedEjected: 1,
+		},
+		{
+			name:      "max ejection percent",
+			instances: 4,
+			detection: config.OutlierDetectionConfig{Enabled: true, ConsecutiveErrors: 1, MaxEjectionPercent: 50},
+			report: func(pool *instancePool) {
+				for _, inst := range pool.instances {
+					pool.report(inst, true, time.Millisecond, now)
+				}
+			},
+			expectedEjected: 2,
+		},
+		{
+			name:      "disabled",
+			instances: 2,
+			detection: config.OutlierDetectionConfig{ConsecutiveErrors: 1},
+			report: func(pool *instancePool) {
+				pool.report(pool.instances[0], true, time.Millisecond, now)
+			},
+			expectedEjected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := newTestPool(t, tt.instances, tt.detection)
+			tt.report(pool)
+
+			ejected := 0
+			for _, inst := range pool.instances {
+				if inst.ejected(now) {
+					ejected++
+				}
+			}
+			if ejected != tt.expectedEjected {
+				t.Errorf("expected %d ejected instances, got %d", tt.expectedEjected, ejected)
+			}
+		})
+	}
+}
+
+func TestInstancePoolEjectionTime(t *testing.T) {
+	pool := newTestPool(t, 2, config.OutlierDetectionConfig{
+		Enabled:           true,
+		ConsecutiveErrors: 1,
+		BaseEjectionTime:  10 * time.Second,
+		MaxEjectionTime:   25 * time.Second,
+	})
+	inst := pool.instances[0]
+	now := time.Now()
+
+	for _, expected := range []time.Duration{10 * time.Second, 20 * time.Second, 25 * time.Second} {
+		pool.report(inst, true, time.Millisecond, now)
+		if got := inst.ejectedUntil.Sub(now); got != expected {
+			t.Errorf("expected ejection time %v, got %v", expected, got)
+		}
+		now = inst.ejectedUntil
+	}
+
+	// An instance that stayed healthy long enough starts over
+	now = now.Add(time.Minute)
+	pool.report(inst, true, time.Millisecond, now)
+	if got := inst.ejectedUntil.Sub(now); got != 10*time.Second {
+		t.Errorf("expected ejection time to reset to 10s, got %v", got)
+	}
+}
+
+func TestInstancePoolPick(t *testing.T) {
+	pool := newTestPool(t, 3, config.OutlierDetectionConfig{Enabled: true})
+	now := time.Now()
+
+	pool.instances[1].ejectedUntil = now.Add(time.Minute)
+	for i := 0; i < 6; i++ {
+		if inst := pool.pick(now, nil); inst == pool.instances[1] {
+			t.Fatal("expected ejected instance to be skipped")
+		}
+	}
+
+	// With every instance ejected, traffic is still spread across all of them
+	for _, inst := range pool.instances {
+		inst.ejectedUntil = now.Add(time.Minute)
+	}
+	if inst := pool.pick(now, nil); inst == nil {
+		t.Fatal("expected an instance when all are ejected")
+	}
+
+	// Instances that are not ready are skipped even when all are ejected
+	ready := func(inst *backendInstance) bool { return inst == pool.instances[2] }
+	for i := 0; i < 3; i++ {
+		if inst := pool.pick(now, ready); inst != pool.instances[2] {
+			t.Fatal("expected the only ready instance")
+		}
+	}
+	if inst := pool.pick(now, func(*backendInstance) bool { return false }); inst != nil {
+		t.Fatal("expected no instance when none is ready")
+	}
+}
+
+func TestForwardEjectsFailingInstance(t *testing.T) {
+	var goodHits, badHits int32
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&goodHits, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer good.Close()
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&badHits, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer bad.Close()
+
+	cfg := DefaultConfig()
+	cfg.MaxRetries = 0
+	p := New(cfg)
+
+	match := newTestMatch(good.URL)
+	match.Route.Instances = []string{bad.URL}
+	match.Route.OutlierDetection = config.OutlierDetectionConfig{Enabled: true, ConsecutiveErrors: 2}
+
+	for i := 0; i < 10; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if err := p.Forward(httptest.NewRecorder(), req, match); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if got := atomic.LoadInt32(&badHits); got != 2 {
+		t.Errorf("expected failing instance to receive 2 requests before ejection, got %d", got)
+	}
+	if got := atomic.LoadInt32(&goodHits); got != 8 {
+		t.Errorf("expected healthy instance to receive 8 requests, got %d", got)
+	}
+}
+
+func TestForwardSkipsInstanceWithOpenBreaker(t *testing.T) {
+	var goodHits, badHits int32
+	var goodFailing atomic.Bool
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&goodHits, 1)
+		if goodFailing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer good.Close()
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&badHits, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer bad.Close()
+
+	cfg := DefaultConfig()
+	cfg.MaxRetries = 0
+	p := New(cfg)
+
+	match := newTestMatch(good.URL)
+	match.Route.Instances = []string{bad.URL}
+	match.Route.CircuitBreaker.FailureThreshold = 2
+
+	for i := 0; i < 10; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if err := p.Forward(httptest.NewRecorder(), req, match); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if got := atomic.LoadInt32(&badHits); got != 2 {
+		t.Errorf("expected failing instance to receive 2 requests before its breaker opened, got %d", got)
+	}
+	if got := atomic.LoadInt32(&goodHits); got != 8 {
+		t.Errorf("expected healthy instance to receive 8 requests, got %d", got)
+	}
+
+	states := make(map[string]string)
+	for _, stat := range p.CircuitBreakers().GetStats() {
+		states[stat.Name] = stat.State.String()
+	}
+	if states[good.URL] != "closed" || states[bad.URL] != "open" || len(states) != 2 {
+		t.Errorf("expected a breaker per instance, got %v", states)
+	}
+
+	// Once every instance's breaker is open the backend is unavailable
+	goodFailing.Store(true)
+	for i := 0; i < 3; i++ {
+		_ = p.Forward(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), match)
+	}
+	err := p.Forward(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), match)
+	if err == nil || !strings.Contains(err.Error(), "circuit breaker open") {
+		t.Errorf("expected open circuit, got %v", err)
+	}
+}
+
+func TestForwardRetriesOnNextInstance(t *testing.T) {
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer good.Close()
+
+	cfg := DefaultConfig()
+	cfg.MaxRetries = 1
+	cfg.RetryDelay = time.Millisecond
+	p := New(cfg)
+
+	// Requests failing at the closed instance are retried on the healthy one
+	match := newTestMatch(closedBackendURL(t))
+	match.Route.Instances = []string{good.URL}
+	for i := 0; i < 4; i++ {
+		rr := httptest.NewRecorder()
+		if err := p.Forward(rr, httptest.NewRequest(http.MethodGet, "/", nil), match); err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i+1, err)
+		}
+		if rr.Code != http.StatusOK {
+			t.Errorf("request %d: expected status 200, got %d", i+1, rr.Code)
+		}
+	}
+}
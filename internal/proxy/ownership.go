@@ -0,0 +1,204 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/identity"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// ErrOwnershipDenied is returned when the ownership endpoint denies access
+var ErrOwnershipDenied = errors.New("resource ownership check denied")
+
+// ErrOwnershipUnavailable is returned when the ownership endpoint could not
+// give an answer and the route fails closed
+var ErrOwnershipUnavailable = errors.New("resource ownership check unavailable")
+
+// Ownership check defaults, used when a route leaves a setting unset
+const (
+	defaultOwnershipTimeout = 2 * time.Second
+	// ownershipCacheMaxEntries bounds the cache; expired entries are pruned
+	// once it is reached
+	ownershipCacheMaxEntries = 10000
+)
+
+// defaultOwnershipMethods are the request methods checked by default
+var defaultOwnershipMethods = []string{
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// ownershipCache caches ownership answers per user and resource
+type ownershipCache struct {
+	mu      sync.Mutex
+	entries map[string]ownershipEntry
+}
+
+type ownershipEntry struct {
+	allowed   bool
+	expiresAt time.Time
+}
+
+func newOwnershipCache() *ownershipCache {
+	return &ownershipCache{entries: make(map[string]ownershipEntry)}
+}
+
+func (c *ownershipCache) get(key string, now time.Time) (allowed, found bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || now.After(entry.expiresAt) {
+		return false, false
+	}
+	return entry.allowed, true
+}
+
+func (c *ownershipCache) set(key string, allowed bool, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= ownershipCacheMaxEntries {
+		now := time.Now()
+		for k, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= ownershipCacheMaxEntries {
+			return
+		}
+	}
+	c.entries[key] = ownershipEntry{allowed: allowed, expiresAt: expiresAt}
+}
+
+// checkOwnership asks the route's ownership endpoint whether the user may
+// access the requested resource. It returns ErrOwnershipDenied or
+// ErrOwnershipUnavailable if the request must not be proxied.
+func (p *Proxy) checkOwnership(r *http.Request, match *router.Match) error {
+	cfg := match.Route.OwnershipCheck
+	if !cfg.Enabled() || !ownershipChecked(cfg, r.Method) {
+		return nil
+	}
+
+	target := expandTemplate(cfg.URL, r, match, url.PathEscape)
+	userID := identity.UserID(r)
+	key := userID + "|" + target
+
+	if cfg.CacheTTL > 0 {
+		if allowed, found := p.ownershipCache.get(key, time.Now()); found {
+			metrics.RecordOwnershipCheck("cache_hit")
+			if !allowed {
+				return ErrOwnershipDenied
+			}
+			return nil
+		}
+	}
+
+	allowed, err := p.callOwnershipEndpoint(r, cfg, target, userID)
+	if err != nil {
+		metrics.RecordOwnershipCheck("error")
+		fields := logger.Fields{
+			"correlation_id": logger.GetCorrelationID(r.Context()),
+			"backend_url":    match.Route.BackendURL,
+			"error":          err.Error(),
+		}
+		if cfg.FailureMode == "fail-open" {
+			p.logger.Warn("ownership check failed, allowing request", fields)
+			return nil
+		}
+		p.logger.Error("ownership check failed, rejecting request", fields)
+		return fmt.Errorf("%w: %v", ErrOwnershipUnavailable, err)
+	}
+
+	if cfg.CacheTTL > 0 {
+		p.ownershipCache.set(key, allowed, time.Now().Add(cfg.CacheTTL))
+	}
+
+	if !allowed {
+		metrics.RecordOwnershipCheck("denied")
+		p.logger.Info("ownership check denied request", logger.Fields{
+			"correlation_id": logger.GetCorrelationID(r.Context()),
+			"user_id":        userID,
+			"path":           r.URL.Path,
+		})
+		return ErrOwnershipDenied
+	}
+	metrics.RecordOwnershipCheck("allowed")
+	return nil
+}
+
+// callOwnershipEndpoint performs the callout. The user is identified by the
+// X-User-ID header and the original Authorization header.
+func (p *Proxy) callOwnershipEndpoint(r *http.Request, cfg config.OwnershipCheckConfig, target, userID string) (bool, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultOwnershipTimeout
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	method := cfg.Method
+	if method == "" {
+		method = http.MethodHead
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	if userID != "" {
+		req.Header.Set("X-User-ID", userID)
+	}
+	if authorization := r.Header.Get("Authorization"); authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	if correlationID := logger.GetCorrelationID(r.Context()); correlationID != "" {
+		req.Header.Set("X-Correlation-ID", correlationID)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		_ = resp.Body.Close()
+	}()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return true, nil
+	case resp.StatusCode == http.StatusUnauthorized, resp.StatusCode == http.StatusForbidden,
+		resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("ownership endpoint returned status %d", resp.StatusCode)
+}
+
+// ownershipChecked reports whether requests with method need a check
+func ownershipChecked(cfg config.OwnershipCheckConfig, method string) bool {
+	methods := cfg.Methods
+	if len(methods) == 0 {
+		methods = defaultOwnershipMethods
+	}
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
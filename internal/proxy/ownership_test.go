@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/auth"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+func TestOwnershipCheck(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		ownerStatus    int
+		failureMode    string
+		expectedErr    error
+		expectCallout  bool
+		expectBackend  bool
+		expectedUserID string
+	}{
+		{
+			name:          "owner allowed",
+			method:        http.MethodDelete,
+			ownerStatus:   http.StatusNoContent,
+			expectCallout: true,
+			expectBackend: true,
+		},
+		{
+			name:          "not owner denied",
+			method:        http.MethodPut,
+			ownerStatus:   http.StatusNotFound,
+			expectedErr:   ErrOwnershipDenied,
+			expectCallout: true,
+		},
+		{
+			name:          "endpoint error fails closed",
+			method:        http.MethodPost,
+			ownerStatus:   http.StatusInternalServerError,
+			expectedErr:   ErrOwnershipUnavailable,
+			expectCallout: true,
+		},
+		{
+			name:          "endpoint error fails open",
+			method:        http.MethodPost,
+			ownerStatus:   http.StatusInternalServerError,
+			failureMode:   "fail-open",
+			expectCallout: true,
+			expectBackend: true,
+		},
+		{
+			name:          "reads are not checked",
+			method:        http.MethodGet,
+			ownerStatus:   http.StatusForbidden,
+			expectBackend: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calloutPath, calloutUser, calloutMethod atomic.Value
+			owner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calloutPath.Store(r.URL.Path)
+				calloutUser.Store(r.Header.Get("X-User-ID"))
+				calloutMethod.Store(r.Method)
+				w.WriteHeader(tt.ownerStatus)
+			}))
+			defer owner.Close()
+
+			var backendHits int32
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&backendHits, 1)
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer backend.Close()
+
+			p := New(DefaultConfig())
+			match := newTestMatch(backend.URL)
+			match.Params["id"] = "order 42"
+			match.Route.OwnershipCheck = config.OwnershipCheckConfig{
+				URL:         owner.URL + "/orders/${param.id}/owner",
+				FailureMode: tt.failureMode,
+			}
+
+			req := httptest.NewRequest(tt.method, "/orders/42", nil)
+			req = req.WithContext(auth.SetUserContext(req.Context(), &auth.UserContext{UserID: "user-1"}))
+
+			err := p.Forward(httptest.NewRecorder(), req, match)
+			if tt.expectedErr == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.expectedErr != nil && !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+			}
+
+			if got := calloutPath.Load() != nil; got != tt.expectCallout {
+				t.Fatalf("expected callout %v, got %v", tt.expectCallout, got)
+			}
+			if tt.expectCallout {
+				if path := calloutPath.Load().(string); path != "/orders/order 42/owner" {
+					t.Errorf("expected escaped parameter in callout path, got %q", path)
+				}
+				if user := calloutUser.Load().(string); user != "user-1" {
+					t.Errorf("expected X-User-ID user-1, got %q", user)
+				}
+				if method := calloutMethod.Load().(string); method != http.MethodHead {
+					t.Errorf("expected HEAD callout, got %s", method)
+				}
+			}
+			if got := atomic.LoadInt32(&backendHits) > 0; got != tt.expectBackend {
+				t.Errorf("expected backend called %v, got %v", tt.expectBackend, got)
+			}
+		})
+	}
+}
+
+func TestOwnershipCheckCache(t *testing.T) {
+	var callouts int32
+	owner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&callouts, 1)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer owner.Close()
+
+	p := New(DefaultConfig())
+	match := newTestMatch("http://127.0.0.1:1")
+	match.Route.OwnershipCheck = config.OwnershipCheckConfig{
+		URL:      owner.URL + "/owner",
+		CacheTTL: time.Minute,
+	}
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodDelete, "/", nil)
+		if err := p.Forward(httptest.NewRecorder(), req, match); !errors.Is(err, ErrOwnershipDenied) {
+			t.Fatalf("expected ErrOwnershipDenied, got %v", err)
+		}
+	}
+
+	if got := atomic.LoadInt32(&callouts); got != 1 {
+		t.Errorf("expected 1 callout with caching, got %d", got)
+	}
+}
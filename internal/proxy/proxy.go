@@ -2,6 +2,8 @@ package proxy
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -9,6 +11,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
@@ -17,18 +20,41 @@ import (
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/maltehedderich/api-gateway-go/internal/circuitbreaker"
+	"github.com/maltehedderich/api-gateway-go/internal/clientip"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
 	"github.com/maltehedderich/api-gateway-go/internal/logger"
 	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+	"github.com/maltehedderich/api-gateway-go/internal/middleware"
 	"github.com/maltehedderich/api-gateway-go/internal/router"
 	"github.com/maltehedderich/api-gateway-go/internal/tracing"
 )
 
+// ErrBackendTimeout is returned when a backend exceeds one of the route timeouts
+var ErrBackendTimeout = errors.New("backend timeout")
+
 // Proxy handles request forwarding to backend services
 type Proxy struct {
 	client          *http.Client
+	clients         map[string]*http.Client
+	clientsMu       sync.Mutex
 	logger          *logger.ComponentLogger
 	config          *Config
 	circuitBreakers *circuitbreaker.Manager
+	retryBudget     *retryBudget
+	pools           map[string]*instancePool
+	poolsMu         sync.Mutex
+	ownershipCache  *ownershipCache
+	classifier      circuitbreaker.ErrorClassifier
+	identityTokens  *identityTokens
+	metadata        *gatewayMetadata
+	mirrors         chan struct{}
+
+	responseCaches   map[string]*responseCache
+	responseCachesMu sync.Mutex
+	cdnPurger        cdnPurger
+
+	fallbacks   map[string]*lastGoodResponses
+	fallbacksMu sync.Mutex
 }
 
 // Config contains proxy configuration
@@ -36,56 +62,346 @@ type Config struct {
 	MaxIdleConns        int
 	MaxIdleConnsPerHost int
 	IdleConnTimeout     time.Duration
-	DefaultTimeout      time.Duration
-	MaxRetries          int
-	RetryDelay          time.Duration
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+	DefaultTimeout      time.Duration // total timeout for routes without their own
+
+	// ExpectContinueTimeout holds back bodies of requests expecting 100
+	// Continue until the backend asks for them; 0 sends them at once
+	ExpectContinueTimeout time.Duration
+	MaxRetries            int
+	RetryDelay            time.Duration
+
+	// Retry budget shared by all routes
+	RetryBudgetRatio  float64
+	RetryMinPerSecond int
+
+	// Request body buffering for retries; BufferMaxSize 0 disables it
+	BufferMemoryLimit int64
+	BufferMaxSize     int64
+	BufferTempDir     string
+
+	// MaxInFlightPerBackend caps concurrent requests per backend; 0 is unlimited
+	MaxInFlightPerBackend int
+
+	// Requests over the in-flight limit wait in a queue of BulkheadQueueSize
+	// for up to BulkheadQueueTimeout. Rejections carry a Retry-After derived
+	// from the queue depth and drain rate, BulkheadRetryAfter until the rate
+	// is known, capped at BulkheadMaxRetryAfter.
+	BulkheadQueueSize     int
+	BulkheadQueueTimeout  time.Duration
+	BulkheadRetryAfter    time.Duration
+	BulkheadMaxRetryAfter time.Duration
+
+	// ForwardedPrefixHeader carries the path prefix stripped before forwarding
+	ForwardedPrefixHeader string
+	// OriginalURLHeader carries the request URI as received by the gateway
+	OriginalURLHeader string
+
+	// ForwardedHeaders is x-forwarded, rfc7239 or both
+	ForwardedHeaders string
+	// ForwardedBy is the by= node of the Forwarded header; empty uses the
+	// address of the listener that received the request
+	ForwardedBy string
+
+	// IdentityToken replaces client tokens and the session cookie named
+	// SessionCookieName with gateway-minted tokens
+	IdentityToken     config.IdentityTokenConfig
+	SessionCookieName string
+	// SessionTokenSources are the other places client tokens are read from,
+	// also removed when identity tokens are minted
+	SessionTokenSources []config.TokenSource
+
+	// Metadata signs a summary of the gateway's handling for backends
+	Metadata config.GatewayMetadataConfig
+
+	// CircuitBreaker is the default breaker of each backend; routes
+	// override it
+	CircuitBreaker config.CircuitBreakerConfig
+	// CircuitBreakerWebhook receives breaker state changes
+	CircuitBreakerWebhook config.BreakerWebhookConfig
+	// CircuitBreakerGC bounds the breakers kept for changing backends
+	CircuitBreakerGC config.BreakerGCConfig
+
+	// CDNPurge forwards response cache purges to a CDN
+	CDNPurge config.CDNPurgeConfig
+
+	// ErrorClassifier decides which backend errors are retried and count
+	// as breaker and instance failures; nil uses the gateway's own
+	// classification
+	ErrorClassifier circuitbreaker.ErrorClassifier
 }
 
 // DefaultConfig returns default proxy configuration
 func DefaultConfig() *Config {
 	return &Config{
-		MaxIdleConns:        100,
-		MaxIdleConnsPerHost: 10,
-		IdleConnTimeout:     90 * time.Second,
-		DefaultTimeout:      30 * time.Second,
-		MaxRetries:          3,
-		RetryDelay:          100 * time.Millisecond,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		DialTimeout:           30 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		DefaultTimeout:        30 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		MaxRetries:            3,
+		RetryDelay:            100 * time.Millisecond,
+		RetryBudgetRatio:      0.2,
+		RetryMinPerSecond:     10,
+		BufferMemoryLimit:     1024 * 1024,
+		BufferMaxSize:         10 * 1024 * 1024,
+
+		BulkheadQueueTimeout:  time.Second,
+		BulkheadRetryAfter:    time.Second,
+		BulkheadMaxRetryAfter: 30 * time.Second,
+
+		ForwardedPrefixHeader: "X-Forwarded-Prefix",
+		OriginalURLHeader:     "X-Original-URL",
+		ForwardedHeaders:      "x-forwarded",
+
+		CircuitBreaker: config.CircuitBreakerConfig{
+			FailureThreshold:      5,
+			SuccessThreshold:      2,
+			Timeout:               60 * time.Second,
+			MaxHalfOpenRequests:   3,
+			ProbeInterval:         time.Second,
+			ProbeTimeout:          5 * time.Second,
+			Mode:                  "consecutive",
+			Window:                time.Minute,
+			MinimumCalls:          20,
+			FailureRateThreshold:  50,
+			SlowCallRateThreshold: 100,
+			SlowCallDuration:      10 * time.Second,
+			FailureStatusCodes:    []int{502, 503, 504},
+		},
+		CircuitBreakerGC: config.BreakerGCConfig{
+			IdleTTL:     time.Hour,
+			MaxBreakers: 10000,
+		},
 	}
 }
 
+// NewConfigFromConfig creates a proxy Config from the main config
+func NewConfigFromConfig(cfg *config.Config) *Config {
+	proxyCfg := DefaultConfig()
+	proxyCfg.MaxRetries = cfg.Proxy.MaxRetries
+	proxyCfg.RetryDelay = cfg.Proxy.RetryDelay
+	proxyCfg.MaxIdleConns = cfg.Proxy.MaxIdleConns
+	proxyCfg.MaxIdleConnsPerHost = cfg.Proxy.MaxIdleConnsPerHost
+	proxyCfg.IdleConnTimeout = cfg.Proxy.IdleConnTimeout
+	proxyCfg.DialTimeout = cfg.Proxy.DialTimeout
+	proxyCfg.TLSHandshakeTimeout = cfg.Proxy.TLSHandshakeTimeout
+	proxyCfg.DefaultTimeout = cfg.Proxy.DefaultTimeout
+	proxyCfg.ExpectContinueTimeout = cfg.Proxy.ExpectContinueTimeout
+	proxyCfg.ForwardedPrefixHeader = cfg.Proxy.ForwardedPrefixHeader
+	proxyCfg.OriginalURLHeader = cfg.Proxy.OriginalURLHeader
+	proxyCfg.ForwardedHeaders = cfg.Proxy.ForwardedHeaders
+	proxyCfg.ForwardedBy = cfg.Proxy.ForwardedBy
+	proxyCfg.RetryBudgetRatio = cfg.Proxy.RetryBudget.Ratio
+	proxyCfg.RetryMinPerSecond = cfg.Proxy.RetryBudget.MinRetriesPerSecond
+	proxyCfg.BufferMemoryLimit = cfg.Proxy.RequestBuffering.MemoryLimit
+	proxyCfg.BufferMaxSize = cfg.Proxy.RequestBuffering.MaxSize
+	proxyCfg.BufferTempDir = cfg.Proxy.RequestBuffering.TempDir
+	proxyCfg.MaxInFlightPerBackend = cfg.Proxy.MaxInFlightPerBackend
+	proxyCfg.BulkheadQueueSize = cfg.Proxy.BulkheadQueue.Size
+	proxyCfg.BulkheadQueueTimeout = cfg.Proxy.BulkheadQueue.Timeout
+	proxyCfg.BulkheadRetryAfter = cfg.Proxy.BulkheadRetryAfter
+	proxyCfg.BulkheadMaxRetryAfter = cfg.Proxy.BulkheadMaxRetryAfter
+	proxyCfg.IdentityToken = cfg.Proxy.IdentityToken
+	proxyCfg.SessionCookieName = cfg.Authorization.CookieName
+	proxyCfg.SessionTokenSources = cfg.Authorization.TokenSources
+	proxyCfg.Metadata = cfg.Proxy.Metadata
+	proxyCfg.CircuitBreaker = cfg.Proxy.CircuitBreaker
+	proxyCfg.CircuitBreakerWebhook = cfg.Proxy.CircuitBreakerWebhook
+	proxyCfg.CircuitBreakerGC = cfg.Proxy.CircuitBreakerGC
+	proxyCfg.CDNPurge = cfg.Proxy.CDNPurge
+	return proxyCfg
+}
+
 // New creates a new proxy instance
-func New(config *Config) *Proxy {
-	if config == nil {
-		config = DefaultConfig()
+func New(cfg *Config) *Proxy {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+
+	log := logger.Get().WithComponent("proxy")
+	p := &Proxy{
+		client:          newClient(newTransport(cfg, config.TransportConfig{}, nil)),
+		clients:         make(map[string]*http.Client),
+		logger:          log,
+		config:          cfg,
+		circuitBreakers: circuitbreaker.NewManager(),
+		retryBudget:     newRetryBudget(cfg.RetryBudgetRatio, cfg.RetryMinPerSecond),
+		pools:           make(map[string]*instancePool),
+		ownershipCache:  newOwnershipCache(),
+		classifier:      cfg.ErrorClassifier,
+		mirrors:         make(chan struct{}, maxMirrorsInFlight),
+		responseCaches:  make(map[string]*responseCache),
+		fallbacks:       make(map[string]*lastGoodResponses),
+	}
+	if p.classifier == nil {
+		p.classifier = errorClassifier{}
+	}
+	p.circuitBreakers.SetGC(circuitbreaker.GCConfig{
+		IdleTTL:     cfg.CircuitBreakerGC.IdleTTL,
+		MaxBreakers: cfg.CircuitBreakerGC.MaxBreakers,
+	})
+	if cfg.IdentityToken.Enabled {
+		p.identityTokens = newIdentityTokens(cfg.IdentityToken, cfg.SessionCookieName, cfg.SessionTokenSources)
+	}
+	if cfg.Metadata.Enabled {
+		p.metadata = newGatewayMetadata(cfg.Metadata)
+	}
+	if cfg.CircuitBreakerWebhook.URL != "" {
+		p.circuitBreakers.OnStateChange(newBreakerWebhook(cfg.CircuitBreakerWebhook, p.client, log).notify)
+	}
+	p.cdnPurger = newCDNPurger(cfg.CDNPurge, p.client)
+	return p
+}
+
+// CheckIdentityToken verifies that the identity token signing key can be
+// loaded, so a bad key fails startup rather than every request
+func (p *Proxy) CheckIdentityToken() error {
+	if p.identityTokens == nil {
+		return nil
+	}
+	_, err := p.identityTokens.signingKey()
+	return err
+}
+
+// newTransport creates a backend transport with the given TLS configuration.
+// Non-zero values in tuning override the proxy-wide defaults.
+func newTransport(cfg *Config, tuning config.TransportConfig, tlsConfig *tls.Config) *http.Transport {
+	maxIdlePerHost := cfg.MaxIdleConnsPerHost
+	if tuning.MaxIdleConnsPerHost > 0 {
+		maxIdlePerHost = tuning.MaxIdleConnsPerHost
+	}
+	dialTimeout := cfg.DialTimeout
+	if tuning.DialTimeout > 0 {
+		dialTimeout = tuning.DialTimeout
+	}
+	handshakeTimeout := cfg.TLSHandshakeTimeout
+	if tuning.TLSHandshakeTimeout > 0 {
+		handshakeTimeout = tuning.TLSHandshakeTimeout
+	}
+	idleTimeout := cfg.IdleConnTimeout
+	if tuning.IdleConnTimeout > 0 {
+		idleTimeout = tuning.IdleConnTimeout
+	}
+	expectContinueTimeout := cfg.ExpectContinueTimeout
+	if tuning.ExpectContinueTimeout > 0 {
+		expectContinueTimeout = tuning.ExpectContinueTimeout
 	}
 
 	transport := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
 		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
+			Timeout:   dialTimeout,
 			KeepAlive: 30 * time.Second,
 		}).DialContext,
-		MaxIdleConns:        config.MaxIdleConns,
-		MaxIdleConnsPerHost: config.MaxIdleConnsPerHost,
-		IdleConnTimeout:     config.IdleConnTimeout,
-		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   maxIdlePerHost,
+		IdleConnTimeout:       idleTimeout,
+		TLSHandshakeTimeout:   handshakeTimeout,
+		ResponseHeaderTimeout: tuning.ResponseHeaderTimeout,
+		ExpectContinueTimeout: expectContinueTimeout,
+		TLSClientConfig:       tlsConfig,
 	}
 
-	client := &http.Client{
+	switch tuning.HTTP2 {
+	case "auto":
+		// Needed because a custom dialer or TLS config disables HTTP/2
+		transport.ForceAttemptHTTP2 = true
+	case "h2c":
+		protocols := new(http.Protocols)
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+		transport.Protocols = protocols
+	}
+	return transport
+}
+
+// newClient creates a backend HTTP client using the given transport
+func newClient(transport http.RoundTripper) *http.Client {
+	return &http.Client{
 		Transport: transport,
-		Timeout:   config.DefaultTimeout,
 		// Don't follow redirects - let the client handle them
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
 		},
 	}
+}
 
-	return &Proxy{
-		client:          client,
-		logger:          logger.Get().WithComponent("proxy"),
-		config:          config,
-		circuitBreakers: circuitbreaker.NewManager(),
+// clientKey identifies the dedicated client of a route's backend and options
+func clientKey(route *router.Route) string {
+	return fmt.Sprintf("%s|%+v|%+v|%t|%s", route.BackendURL, route.UpstreamTLS, route.Transport, route.UploadMode, route.ProxyProtocol)
+}
+
+// clientFor returns the HTTP client to use for a route.
+// Routes without upstream TLS or transport options share the default client;
+// others get a dedicated client so their certificates never leak into other
+// backends and a slow backend cannot exhaust a pool shared with fast ones.
+func (p *Proxy) clientFor(route *router.Route) (*http.Client, error) {
+	if !route.UpstreamTLS.Enabled() && !route.Transport.Enabled() && !route.UploadMode && route.ProxyProtocol == "" {
+		return p.client, nil
 	}
+
+	key := clientKey(route)
+
+	p.clientsMu.Lock()
+	defer p.clientsMu.Unlock()
+
+	if client, ok := p.clients[key]; ok {
+		return client, nil
+	}
+
+	var tlsConfig *tls.Config
+	if route.UpstreamTLS.Enabled() {
+		var err error
+		tlsConfig, err = buildUpstreamTLSConfig(route.UpstreamTLS, p.logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure upstream TLS: %w", err)
+		}
+	}
+
+	transport := newTransport(p.config, route.Transport, tlsConfig)
+	if version := proxyProtocolVersion(route.ProxyProtocol); version > 0 {
+		transport = withProxyProtocol(transport, version)
+	}
+	client := newClient(transport)
+	p.clients[key] = client
+
+	p.logger.Info("dedicated backend client created", logger.Fields{
+		"backend_url":             route.BackendURL,
+		"mtls":                    route.UpstreamTLS.CertFile != "",
+		"custom_ca":               route.UpstreamTLS.CAFile != "",
+		"server_name":             route.UpstreamTLS.ServerName,
+		"max_idle_conns_per_host": route.Transport.MaxIdleConnsPerHost,
+		"dial_timeout":            route.Transport.DialTimeout.String(),
+		"tls_handshake_timeout":   route.Transport.TLSHandshakeTimeout.String(),
+		"response_header_timeout": route.Transport.ResponseHeaderTimeout.String(),
+		"idle_conn_timeout":       route.Transport.IdleConnTimeout.String(),
+		"http2":                   route.Transport.HTTP2,
+		"upload_mode":             route.UploadMode,
+		"proxy_protocol":          route.ProxyProtocol,
+	})
+
+	return client, nil
+}
+
+// totalTimeout returns the deadline for a backend exchange on route
+func (p *Proxy) totalTimeout(route *router.Route) time.Duration {
+	switch {
+	case route.UploadMode:
+		return uploadTimeout(route)
+	case route.Timeout > 0:
+		return route.Timeout
+	default:
+		return p.config.DefaultTimeout
+	}
+}
+
+// CircuitBreakers returns the manager holding the per-backend circuit breakers
+func (p *Proxy) CircuitBreakers() *circuitbreaker.Manager {
+	return p.circuitBreakers
 }
 
 // Forward forwards a request to the backend service
@@ -111,6 +427,50 @@ func (p *Proxy) Forward(w http.ResponseWriter, r *http.Request, match *router.Ma
 		return fmt.Errorf("invalid backend URL: %w", err)
 	}
 
+	// Enforce route range limits before contacting the backend
+	if err := validateRange(r, match.Route); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "range not satisfiable")
+		return err
+	}
+
+	// Ask the ownership endpoint before touching the resource
+	if err := p.checkOwnership(r, match); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "ownership check failed")
+		return err
+	}
+
+	// Answer from the gateway response cache where possible
+	cache := p.responseCacheFor(match.Route)
+	if cache != nil && !cache.usable(r) {
+		cache = nil
+	}
+	if cache != nil && cache.serve(w, r) {
+		span.SetStatus(codes.Ok, "served from cache")
+		return nil
+	}
+
+	// Queue or reject requests while the backend is at its in-flight limit
+	if bh := p.bulkheadFor(match.Route); bh != nil {
+		if err := bh.Acquire(r.Context(), match.Route.PriorityClass); err != nil {
+			span.SetStatus(codes.Error, "backend in-flight limit reached")
+			return err
+		}
+		defer bh.Release()
+	}
+
+	// Upload routes stream the body untouched with extended deadlines
+	var upload *uploadBody
+	if match.Route.UploadMode {
+		p.extendDeadlines(w, uploadTimeout(match.Route))
+		if r.Body != nil && r.Body != http.NoBody {
+			upload = newUploadBody(r.Body, match.Route.BackendURL)
+			defer upload.finish()
+			r.Body = upload
+		}
+	}
+
 	// Build target URL
 	targetURL := p.buildTargetURL(backendURL, r, match)
 
@@ -122,46 +482,131 @@ func (p *Proxy) Forward(w http.ResponseWriter, r *http.Request, match *router.Ma
 		return fmt.Errorf("failed to create backend request: %w", err)
 	}
 
+	// Describe the gateway's handling of the request to the backend
+	if p.metadata != nil {
+		if err := p.metadata.apply(backendReq, r, match, w.Header()); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "gateway metadata unavailable")
+			return fmt.Errorf("gateway metadata unavailable: %w", err)
+		}
+	}
+
+	// Keep the body so that retries and the shadow backend can resend it
+	mirrored := p.shouldMirror(r, match.Route)
+	var buffered *bufferedBody
+	if p.needsBuffering(backendReq, match.Route) || (mirrored && p.canBuffer(backendReq)) {
+		buffered, err = p.bufferRequestBody(backendReq)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "failed to buffer request body")
+			return err
+		}
+		defer buffered.Close()
+	}
+
+	// Copy sampled requests to the shadow backend
+	if mirrored {
+		p.mirror(backendReq, match.Route, buffered)
+	}
+
+	// Pass the client address to the dialer for PROXY protocol headers
+	if match.Route.ProxyProtocol != "" {
+		ctx = withConnAddrs(ctx, r)
+	}
+
+	// Relay 103 Early Hints and other interim responses of the backend
+	ctx = withInformationalResponses(ctx, w)
+
 	// Inject trace context into backend request headers
 	backendReq = backendReq.WithContext(ctx)
 	tracing.InjectTraceContext(ctx, backendReq)
 
-	// Set timeout if specified in route
-	if match.Route.Timeout > 0 {
-		timeout := time.Duration(match.Route.Timeout) * time.Millisecond
-		timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
-		defer cancel()
-		backendReq = backendReq.WithContext(timeoutCtx)
+	// Bound the whole exchange, including retries and the response body
+	timeoutCtx, cancel := context.WithTimeout(ctx, p.totalTimeout(match.Route))
+	defer cancel()
+	backendReq = backendReq.WithContext(timeoutCtx)
+
+	// Select client for this route
+	client, err := p.clientFor(match.Route)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "upstream TLS configuration error")
+		metrics.RecordBackendError(match.Route.BackendURL, "tls_config")
+		return err
 	}
 
-	// Get circuit breaker for this backend
-	cb := p.circuitBreakers.Get(match.Route.BackendURL, circuitbreaker.DefaultConfig())
+	// Select the instance pool for routes with several backend addresses
+	pool, err := p.instancePoolFor(match.Route)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid backend instance")
+		return err
+	}
 
 	// Execute request with circuit breaker protection
 	var resp *http.Response
+	var bodyErr error
 	backendStart := time.Now()
-	err = cb.Execute(func() error {
+	failureStatus := p.failureStatusCodes(match.Route)
+	send := func() error {
 		var execErr error
-		resp, execErr = p.forwardWithRetry(backendReq)
+		resp, execErr = p.forwardWithRetry(client, backendReq, match.Route, pool)
+		if isRequestBodyError(execErr) {
+			// Client sent a bad body; the classifier does not count it
+			bodyErr = execErr
+		}
+		if execErr == nil && failureStatus[resp.StatusCode] {
+			return errFailureStatus
+		}
 		return execErr
-	})
+	}
+	if pool != nil {
+		// Instances have breakers of their own, consulted for every attempt
+		err = send()
+	} else {
+		err = p.circuitBreakers.Get(match.Route.BackendURL, p.breakerConfigFor(match.Route, client, match.Route.BackendURL)).Execute(send)
+	}
+	if errors.Is(err, errFailureStatus) {
+		// The breaker counted the failure; the client still gets the response
+		err = nil
+	}
 	backendDuration := time.Since(backendStart)
 
+	if bodyErr != nil {
+		span.RecordError(bodyErr)
+		span.SetStatus(codes.Error, "invalid request body")
+		return fmt.Errorf("failed to send request body: %w", bodyErr)
+	}
+
 	// Record backend duration in span
 	span.SetAttributes(attribute.Int64("backend.duration_ms", backendDuration.Milliseconds()))
 
-	// Record backend metrics
-	if err != nil {
+	// Answer from the route's fallback while the breaker is open
+	fallback := false
+	if errors.Is(err, circuitbreaker.ErrCircuitOpen) {
 		span.RecordError(err)
-		if err == circuitbreaker.ErrCircuitOpen {
-			span.SetStatus(codes.Error, "circuit breaker open")
-			span.SetAttributes(attribute.String("error.type", "circuit_open"))
-			metrics.RecordBackendError(match.Route.BackendURL, "circuit_open")
+		span.SetStatus(codes.Error, "circuit breaker open")
+		span.SetAttributes(attribute.String("error.type", "circuit_open"))
+		metrics.RecordBackendError(match.Route.BackendURL, "circuit_open")
+		if p.serveFallback(w, r, match) {
+			return nil
+		}
+		if resp = p.forwardFallback(backendReq, r, match); resp == nil {
 			return fmt.Errorf("circuit breaker open for backend %s", match.Route.BackendURL)
 		}
+		w.Header().Set(FallbackHeader, "backend")
+		fallback = true
+		err = nil
+	}
+
+	// Record backend metrics
+	if err != nil {
+		span.RecordError(err)
 		// Determine error type
 		errorType := "unknown"
-		if err == context.DeadlineExceeded {
+		if p.classifier.Classify(err) == circuitbreaker.ErrorClassClientCancel {
+			errorType = "client_cancel"
+		} else if isTimeout(err) {
 			errorType = "timeout"
 		} else if strings.Contains(err.Error(), "connection refused") {
 			errorType = "connection_refused"
@@ -171,6 +616,9 @@ func (p *Proxy) Forward(w http.ResponseWriter, r *http.Request, match *router.Ma
 		span.SetStatus(codes.Error, errorType)
 		span.SetAttributes(attribute.String("error.type", errorType))
 		metrics.RecordBackendError(match.Route.BackendURL, errorType)
+		if errorType == "timeout" {
+			return fmt.Errorf("%w: %v", ErrBackendTimeout, err)
+		}
 		return fmt.Errorf("backend request failed: %w", err)
 	}
 	defer func() {
@@ -182,8 +630,21 @@ func (p *Proxy) Forward(w http.ResponseWriter, r *http.Request, match *router.Ma
 	}()
 
 	// Record successful backend request
-	statusCode := strconv.Itoa(resp.StatusCode)
-	metrics.RecordBackendRequest(match.Route.BackendURL, statusCode, backendDuration)
+	if !fallback {
+		statusCode := strconv.Itoa(resp.StatusCode)
+		metrics.RecordBackendRequest(ctx, match.Route.BackendURL, statusCode, backendDuration)
+		metrics.RecordBackendProtocol(match.Route.BackendURL, resp.Proto)
+	}
+
+	if upload != nil {
+		p.logger.Info("upload forwarded", logger.Fields{
+			"correlation_id": logger.GetCorrelationID(r.Context()),
+			"backend_url":    match.Route.BackendURL,
+			"bytes":          upload.read,
+			"status":         resp.StatusCode,
+			"duration_ms":    backendDuration.Milliseconds(),
+		})
+	}
 
 	// Record response status in span
 	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(resp.StatusCode))
@@ -202,19 +663,57 @@ func (p *Proxy) Forward(w http.ResponseWriter, r *http.Request, match *router.Ma
 		"content_length": resp.ContentLength,
 	})
 
+	// Rewrite JSON responses before their headers are copied
+	p.transformResponseBody(resp, r, match)
+
 	// Copy response headers
+	// Responses of the fallback backend are neither cached nor kept as last
+	// good responses
+	lastGood := p.lastGoodFor(match.Route)
+	if fallback {
+		cache, lastGood = nil, nil
+	}
+	var before http.Header
+	if cache != nil || lastGood != nil {
+		before = w.Header().Clone()
+	}
 	p.copyResponseHeaders(w, resp)
+	applyCachePolicy(w.Header(), match.Route.CachePolicy, resp.StatusCode, time.Now())
+	applyHeaderRules(w.Header(), match.Route.ResponseHeaders, r, match)
+	addVary(w.Header(), match.Route.CachePolicy.Vary)
+
+	// Announce backend trailers, e.g. grpc-status, so they follow the body
+	trailers := announceTrailers(w, resp)
+
+	// Record cacheable responses while they are streamed; the cache does
+	// not keep trailers
+	var pending *pendingResponse
+	if cache != nil && trailers == nil {
+		if pending = cache.prepare(r, resp.StatusCode, before, w.Header()); pending != nil {
+			resp.Body = io.NopCloser(pending.wrap(resp.Body))
+		}
+	}
+	var kept *pendingLastGood
+	if lastGood != nil && trailers == nil {
+		if kept = lastGood.prepare(r, resp.StatusCode, before, w.Header()); kept != nil {
+			resp.Body = io.NopCloser(kept.wrap(resp.Body))
+		}
+	}
+
+	// Let clients that accept trailers learn about interrupted streams
+	trailer := declareStreamErrorTrailer(w, r)
 
 	// Copy status code
 	w.WriteHeader(resp.StatusCode)
 
 	// Stream response body
-	_, err = io.Copy(w, resp.Body)
-	if err != nil {
-		p.logger.Warn("error streaming response", logger.Fields{
-			"correlation_id": correlationID,
-			"error":          err.Error(),
-		})
+	p.streamResponse(w, r, resp, match.Route.BackendURL, trailer)
+	copyTrailers(w, resp, trailers)
+	if pending != nil {
+		pending.store()
+	}
+	if kept != nil {
+		kept.keep()
 	}
 
 	return nil
@@ -258,13 +757,40 @@ func (p *Proxy) createBackendRequest(r *http.Request, targetURL *url.URL, match
 	if err != nil {
 		return nil, err
 	}
+	backendReq.ContentLength = r.ContentLength
 
 	// Copy headers, excluding hop-by-hop headers
 	p.copyRequestHeaders(backendReq, r)
 
+	// Request trailers are filled in once the client body has been read
+	if len(r.Trailer) > 0 {
+		backendReq.Trailer = r.Trailer
+	}
+
+	// Inflate compressed bodies for backends that cannot handle them.
+	// Upload routes pass bodies through without transformation.
+	if !match.Route.UploadMode {
+		if err := decompressRequestBody(backendReq, match.Route); err != nil {
+			return nil, err
+		}
+	}
+
+	// Reject bodies not matching the route's schema before rewriting them
+	if err := validateRequestSchema(backendReq, match); err != nil {
+		return nil, err
+	}
+
+	// Rewrite JSON bodies according to the route rules
+	if err := transformRequestBody(backendReq, match); err != nil {
+		return nil, err
+	}
+
 	// Add X-Forwarded-* headers
 	p.addForwardedHeaders(backendReq, r)
 
+	// Preserve the public URL for backends behind a rewritten path
+	p.addOriginalURLHeaders(backendReq, r, match)
+
 	// Add correlation ID header
 	correlationID := logger.GetCorrelationID(r.Context())
 	if correlationID != "" {
@@ -274,6 +800,21 @@ func (p *Proxy) createBackendRequest(r *http.Request, targetURL *url.URL, match
 	// Add Via header
 	backendReq.Header.Add("Via", "1.1 gateway")
 
+	// Apply route header rules last so they can override gateway defaults
+	applyHeaderRules(backendReq.Header, match.Route.RequestHeaders, r, match)
+
+	// Identity tokens replace the client's credentials
+	if p.identityTokens != nil && !match.Route.ForwardClientToken {
+		if err := p.identityTokens.apply(backendReq, r, match.Route); err != nil {
+			return nil, fmt.Errorf("identity token unavailable: %w", err)
+		}
+	}
+
+	// The backend credential replaces anything the client or rules set
+	if err := applyBackendAuth(backendReq, match.Route.BackendAuth); err != nil {
+		return nil, fmt.Errorf("backend credential unavailable: %w", err)
+	}
+
 	// Set Host header to backend host
 	backendReq.Host = targetURL.Host
 
@@ -305,72 +846,111 @@ func (p *Proxy) copyRequestHeaders(dst, src *http.Request) {
 			dst.Header.Add(key, value)
 		}
 	}
+
+	// TE is hop-by-hop, but gRPC backends require "TE: trailers" from
+	// clients that accept trailers
+	if acceptsTrailers(src) {
+		dst.Header.Set("Te", "trailers")
+	}
 }
 
-// addForwardedHeaders adds X-Forwarded-* headers
+// addForwardedHeaders adds X-Forwarded-* and/or RFC 7239 Forwarded headers,
+// as configured. A prior proxy chain is only kept when the request came from
+// a trusted proxy and in the header trusted proxies maintain; otherwise it is
+// replaced so clients cannot inject addresses.
 func (p *Proxy) addForwardedHeaders(backendReq, originalReq *http.Request) {
-	// X-Forwarded-For
-	clientIP := p.getClientIP(originalReq)
-	if prior := originalReq.Header.Get("X-Forwarded-For"); prior != "" {
-		clientIP = prior + ", " + clientIP
+	info, ok := clientip.FromContext(originalReq.Context())
+	if !ok {
+		peer := clientip.FromRequest(originalReq)
+		info = clientip.Info{IP: peer, Peer: peer}
 	}
-	backendReq.Header.Set("X-Forwarded-For", clientIP)
 
-	// X-Forwarded-Proto
 	proto := "http"
 	if originalReq.TLS != nil {
 		proto = "https"
 	}
-	backendReq.Header.Set("X-Forwarded-Proto", proto)
 
-	// X-Forwarded-Host
-	backendReq.Header.Set("X-Forwarded-Host", originalReq.Host)
+	mode := p.config.ForwardedHeaders
+	backendReq.Header.Del("X-Forwarded-For")
+	backendReq.Header.Del("X-Forwarded-Proto")
+	backendReq.Header.Del("X-Forwarded-Host")
+	backendReq.Header.Del("Forwarded")
 
-	// X-Real-IP (if not already set)
-	if originalReq.Header.Get("X-Real-IP") == "" {
-		backendReq.Header.Set("X-Real-IP", p.getClientIP(originalReq))
+	if mode != "rfc7239" {
+		forwardedFor := info.Peer
+		if prior := strings.Join(originalReq.Header.Values("X-Forwarded-For"), ", "); prior != "" && info.Trusts(clientip.HeaderXForwardedFor) {
+			forwardedFor = prior + ", " + forwardedFor
+		}
+		backendReq.Header.Set("X-Forwarded-For", forwardedFor)
+		backendReq.Header.Set("X-Forwarded-Proto", proto)
+		backendReq.Header.Set("X-Forwarded-Host", originalReq.Host)
 	}
-}
 
-// getClientIP extracts the client IP from the request
-func (p *Proxy) getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header first
-	forwarded := r.Header.Get("X-Forwarded-For")
-	if forwarded != "" {
-		// X-Forwarded-For can contain multiple IPs, get the first one
-		ips := strings.Split(forwarded, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
+	if mode == "rfc7239" || mode == "both" {
+		element := clientip.ForwardedElement{
+			For:   clientip.ForwardedNode(info.Peer),
+			By:    p.forwardedBy(originalReq),
+			Host:  originalReq.Host,
+			Proto: proto,
+		}
+		forwarded := element.String()
+		if prior := strings.Join(originalReq.Header.Values("Forwarded"), ", "); prior != "" && info.Trusts(clientip.HeaderForwarded) {
+			forwarded = prior + ", " + forwarded
+		}
+		backendReq.Header.Set("Forwarded", forwarded)
+	} else if info.Trusts(clientip.HeaderForwarded) {
+		// Pass a trusted chain through untouched for backends that read it
+		for _, value := range originalReq.Header.Values("Forwarded") {
+			backendReq.Header.Add("Forwarded", value)
 		}
 	}
 
-	// Check X-Real-IP header
-	realIP := r.Header.Get("X-Real-IP")
-	if realIP != "" {
-		return realIP
+	// X-Real-IP always carries the resolved client, never a client-supplied value
+	backendReq.Header.Set("X-Real-IP", info.IP)
+}
+
+// forwardedBy returns the by= node for the Forwarded header
+func (p *Proxy) forwardedBy(r *http.Request) string {
+	if p.config.ForwardedBy != "" {
+		return p.config.ForwardedBy
 	}
+	if local, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		if tcpAddr, ok := local.(*net.TCPAddr); ok {
+			return clientip.ForwardedNode(tcpAddr.IP.String())
+		}
+	}
+	return ""
+}
 
-	// Fall back to RemoteAddr
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
+// addOriginalURLHeaders adds headers that let backends reconstruct the
+// externally visible URL when the gateway has changed the request path.
+// Client-supplied values are always dropped so they cannot be spoofed.
+func (p *Proxy) addOriginalURLHeaders(backendReq, originalReq *http.Request, match *router.Match) {
+	if p.config.OriginalURLHeader != "" {
+		backendReq.Header.Set(p.config.OriginalURLHeader, originalReq.URL.RequestURI())
 	}
 
-	return ip
+	if p.config.ForwardedPrefixHeader != "" {
+		backendReq.Header.Del(p.config.ForwardedPrefixHeader)
+		prefix := strings.TrimSuffix(match.Route.StripPrefix, "/")
+		if prefix != "" && strings.HasPrefix(originalReq.URL.Path, match.Route.StripPrefix) {
+			backendReq.Header.Set(p.config.ForwardedPrefixHeader, prefix)
+		}
+	}
 }
 
 // copyResponseHeaders copies response headers
 func (p *Proxy) copyResponseHeaders(dst http.ResponseWriter, src *http.Response) {
 	// Hop-by-hop headers that should not be forwarded
 	hopHeaders := map[string]bool{
-		"Connection":        true,
-		"Keep-Alive":        true,
-		"Proxy-Authenticate": true,
+		"Connection":          true,
+		"Keep-Alive":          true,
+		"Proxy-Authenticate":  true,
 		"Proxy-Authorization": true,
-		"Te":                true,
-		"Trailer":           true,
-		"Transfer-Encoding": true,
-		"Upgrade":           true,
+		"Te":                  true,
+		"Trailer":             true,
+		"Transfer-Encoding":   true,
+		"Upgrade":             true,
 	}
 
 	for key, values := range src.Header {
@@ -389,72 +969,148 @@ func (p *Proxy) copyResponseHeaders(dst http.ResponseWriter, src *http.Response)
 	dst.Header().Set("X-Gateway-Version", "1.0.0")
 }
 
-// forwardWithRetry forwards the request with retry logic
-func (p *Proxy) forwardWithRetry(req *http.Request) (*http.Response, error) {
-	var resp *http.Response
-	var err error
-
-	for attempt := 0; attempt <= p.config.MaxRetries; attempt++ {
-		if attempt > 0 {
-			// Wait before retrying with exponential backoff
-			delay := p.config.RetryDelay * time.Duration(1<<uint(attempt-1))
-			time.Sleep(delay)
-
-			p.logger.Debug("retrying backend request", logger.Fields{
-				"attempt": attempt,
-				"url":     req.URL.String(),
-				"delay":   delay.String(),
-			})
+// forwardWithRetry forwards the request, retrying according to the route policy.
+// With an instance pool every attempt goes to the next available instance.
+func (p *Proxy) forwardWithRetry(client *http.Client, req *http.Request, route *router.Route, pool *instancePool) (*http.Response, error) {
+	policy := p.retryPolicyFor(route)
+	canRetry := policy.canRetry(req)
+	correlationID := logger.GetCorrelationID(req.Context())
+
+	var breakers *instanceBreakers
+	if pool != nil {
+		breakers = p.instanceBreakersFor(route, client)
+	}
+
+	p.retryBudget.recordRequest()
+
+	for attempt := 1; ; attempt++ {
+		var inst *backendInstance
+		if pool != nil {
+			if inst = pool.pick(time.Now(), breakers.ready); inst == nil {
+				return nil, circuitbreaker.ErrCircuitOpen
+			}
+			inst.apply(req)
 		}
 
-		// Execute request
-		resp, err = p.client.Do(req)
+		attemptStart := time.Now()
+		var resp *http.Response
+		var err error
+		if inst != nil {
+			resp, err = breakers.do(inst, func() (*http.Response, error) {
+				return doAttempt(client, req, policy.perTryTimeout)
+			})
+		} else {
+			resp, err = doAttempt(client, req, policy.perTryTimeout)
+		}
+		attemptDuration := time.Since(attemptStart)
+		if inst != nil {
+			pool.report(inst, p.attemptFailed(resp, err), attemptDuration, time.Now())
+		}
+		recordAttempt(req, attempt, resp, err, attemptDuration)
 
-		// If successful or non-retryable error, return
+		retry := canRetry && attempt < policy.maxAttempts && req.Context().Err() == nil
 		if err == nil {
-			// Don't retry on successful responses (even 4xx/5xx)
-			// The client should handle those
-			return resp, nil
+			retry = retry && policy.statusCodes[resp.StatusCode]
+		} else {
+			retry = retry && p.isRetryable(err)
+		}
+		if retry && !p.retryBudget.withdraw() {
+			metrics.RecordBackendRetry(route.BackendURL, "budget_exhausted")
+			p.logger.Warn("retry budget exhausted, not retrying", logger.Fields{
+				"correlation_id": correlationID,
+				"backend_url":    route.BackendURL,
+				"attempt":        attempt,
+			})
+			retry = false
 		}
 
-		// Check if error is retryable
-		if !p.isRetryable(err) {
-			return nil, err
+		if !retry {
+			if err != nil && attempt > 1 {
+				return nil, fmt.Errorf("max retries exceeded: %w", err)
+			}
+			return resp, err
 		}
 
 		// Log retry
-		correlationID := logger.GetCorrelationID(req.Context())
-		p.logger.Warn("backend request failed, will retry", logger.Fields{
+		fields := logger.Fields{
 			"correlation_id": correlationID,
+			"backend_url":    route.BackendURL,
 			"attempt":        attempt,
-			"error":          err.Error(),
+		}
+		if err != nil {
+			fields["error"] = err.Error()
+		} else {
+			fields["status"] = resp.StatusCode
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		p.logger.Warn("backend request failed, will retry", fields)
+		metrics.RecordBackendRetry(route.BackendURL, "attempted")
+
+		// Wait before retrying with exponential backoff
+		delay := policy.backoff * time.Duration(1<<uint(attempt-1))
+		if err := sleepContext(req.Context(), delay); err != nil {
+			return nil, err
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to replay request body: %w", err)
+			}
+			req.Body = body
+		}
+
+		p.logger.Debug("retrying backend request", logger.Fields{
+			"attempt": attempt + 1,
+			"url":     req.URL.String(),
+			"delay":   delay.String(),
 		})
 	}
+}
 
-	return nil, fmt.Errorf("max retries exceeded: %w", err)
+// recordAttempt adds a backend attempt to the access log entry and as an
+// event to the proxy span, so that intermittent failures hidden by retries
+// stay visible
+func recordAttempt(req *http.Request, attempt int, resp *http.Response, err error, duration time.Duration) {
+	record := middleware.BackendAttempt{
+		Backend:    req.URL.Host,
+		DurationMs: duration.Milliseconds(),
+	}
+	attrs := []attribute.KeyValue{
+		attribute.Int("attempt", attempt),
+		attribute.String("backend.instance", req.URL.Host),
+		attribute.Int64("duration_ms", record.DurationMs),
+	}
+	if err != nil {
+		record.Error = err.Error()
+		attrs = append(attrs, attribute.String("error", record.Error))
+	} else {
+		record.Status = resp.StatusCode
+		attrs = append(attrs, semconv.HTTPStatusCodeKey.Int(resp.StatusCode))
+	}
+
+	middleware.RecordBackendAttempt(req.Context(), record)
+	trace.SpanFromContext(req.Context()).AddEvent("backend attempt", trace.WithAttributes(attrs...))
 }
 
 // isRetryable checks if an error is retryable
 func (p *Proxy) isRetryable(err error) bool {
-	// Network errors are retryable
-	if _, ok := err.(net.Error); ok {
+	// Another instance may take requests rejected by an instance's breaker
+	if errors.Is(err, circuitbreaker.ErrCircuitOpen) {
 		return true
 	}
 
-	// Timeout errors are retryable
-	if err == context.DeadlineExceeded {
-		return true
-	}
-
-	// Connection refused is retryable
-	if strings.Contains(err.Error(), "connection refused") {
-		return true
-	}
+	// Per-try timeouts and connection failures are retryable; the caller
+	// checks the request deadline
+	return p.classifier.Classify(err).Retryable()
+}
 
-	// DNS errors are retryable
-	if strings.Contains(err.Error(), "no such host") {
+// isTimeout reports whether err was caused by a route or transport timeout
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
 		return true
 	}
-
-	return false
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
 }
@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/maltehedderich/api-gateway-go/internal/proxyproto"
+)
+
+// connAddrsKey carries the client connection addresses to the dialer
+type connAddrsKey struct{}
+
+// connAddrs are the addresses of the client connection a request arrived on
+type connAddrs struct {
+	source      *net.TCPAddr
+	destination *net.TCPAddr
+}
+
+// withConnAddrs stores the client connection addresses of r in ctx
+func withConnAddrs(ctx context.Context, r *http.Request) context.Context {
+	addrs := connAddrs{}
+	if src, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		addrs.source = src
+	}
+	if local, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		addrs.destination, _ = local.(*net.TCPAddr)
+	}
+	return context.WithValue(ctx, connAddrsKey{}, addrs)
+}
+
+// proxyProtocolVersion maps a route's proxy_protocol setting to a version
+func proxyProtocolVersion(setting string) int {
+	switch setting {
+	case "v1":
+		return 1
+	case "v2":
+		return 2
+	}
+	return 0
+}
+
+// withProxyProtocol makes transport send a PROXY protocol header carrying the
+// client address on every backend connection. A connection then belongs to a
+// single client, so keep-alives are disabled and HTTP proxies are bypassed.
+func withProxyProtocol(transport *http.Transport, version int) *http.Transport {
+	dial := transport.DialContext
+	transport.Proxy = nil
+	transport.DisableKeepAlives = true
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+
+		addrs, _ := ctx.Value(connAddrsKey{}).(connAddrs)
+		if addrs.destination == nil {
+			// Without the listener address, name the backend as destination
+			addrs.destination, _ = conn.RemoteAddr().(*net.TCPAddr)
+		}
+		if err := proxyproto.WriteHeader(conn, version, addrs.source, addrs.destination); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+	return transport
+}
@@ -0,0 +1,455 @@
+package proxy
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/clientip"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/proxyproto"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+func init() {
+	// Initialize logger for tests
+	logger.Init(logger.InfoLevel, "json", os.Stdout)
+}
+
+// newTestMatch creates a route match pointing at the given backend
+func newTestMatch(backendURL string) *router.Match {
+	return &router.Match{
+		Route: &router.Route{
+			PathPattern: "/**",
+			BackendURL:  backendURL,
+		},
+		Params: map[string]string{},
+	}
+}
+
+func TestOriginalURLHeaders(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		stripPrefix    string
+		clientPrefix   string
+		expectedPrefix string
+		expectedURL    string
+		expectedPath   string
+	}{
+		{
+			name:           "prefix stripped",
+			path:           "/api/users/42?expand=true",
+			stripPrefix:    "/api",
+			expectedPrefix: "/api",
+			expectedURL:    "/api/users/42?expand=true",
+			expectedPath:   "/users/42",
+		},
+		{
+			name:           "trailing slash in prefix",
+			path:           "/api/users",
+			stripPrefix:    "/api/",
+			expectedPrefix: "/api",
+			expectedURL:    "/api/users",
+			expectedPath:   "/users",
+		},
+		{
+			name:           "no prefix configured",
+			path:           "/users",
+			expectedPrefix: "",
+			expectedURL:    "/users",
+			expectedPath:   "/users",
+		},
+		{
+			name:           "client supplied prefix is dropped",
+			path:           "/users",
+			clientPrefix:   "/evil",
+			expectedPrefix: "",
+			expectedURL:    "/users",
+			expectedPath:   "/users",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received *http.Request
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = r
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer backend.Close()
+
+			p := New(nil)
+			match := newTestMatch(backend.URL)
+			match.Route.StripPrefix = tt.stripPrefix
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.clientPrefix != "" {
+				req.Header.Set("X-Forwarded-Prefix", tt.clientPrefix)
+			}
+			rr := httptest.NewRecorder()
+
+			if err := p.Forward(rr, req, match); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if received == nil {
+				t.Fatal("backend did not receive request")
+			}
+			if got := received.Header.Get("X-Forwarded-Prefix"); got != tt.expectedPrefix {
+				t.Errorf("expected X-Forwarded-Prefix %q, got %q", tt.expectedPrefix, got)
+			}
+			if got := received.Header.Get("X-Original-URL"); got != tt.expectedURL {
+				t.Errorf("expected X-Original-URL %q, got %q", tt.expectedURL, got)
+			}
+			if received.URL.Path != tt.expectedPath {
+				t.Errorf("expected backend path %q, got %q", tt.expectedPath, received.URL.Path)
+			}
+		})
+	}
+}
+
+func TestOriginalURLHeadersDisabled(t *testing.T) {
+	var received *http.Request
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+	}))
+	defer backend.Close()
+
+	cfg := DefaultConfig()
+	cfg.ForwardedPrefixHeader = ""
+	cfg.OriginalURLHeader = ""
+	p := New(cfg)
+
+	match := newTestMatch(backend.URL)
+	match.Route.StripPrefix = "/api"
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+	if err := p.Forward(httptest.NewRecorder(), req, match); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := received.Header.Get("X-Forwarded-Prefix"); got != "" {
+		t.Errorf("expected no X-Forwarded-Prefix, got %q", got)
+	}
+	if got := received.Header.Get("X-Original-URL"); got != "" {
+		t.Errorf("expected no X-Original-URL, got %q", got)
+	}
+}
+
+func TestRouteTransportTuning(t *testing.T) {
+	p := New(nil)
+
+	shared := newTestMatch("http://fast.internal")
+	client, err := p.clientFor(shared.Route)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if client != p.client {
+		t.Error("expected route without overrides to use the shared client")
+	}
+
+	tuned := newTestMatch("http://legacy.internal")
+	tuned.Route.Transport = config.TransportConfig{
+		MaxIdleConnsPerHost:   2,
+		DialTimeout:           time.Second,
+		ResponseHeaderTimeout: 5 * time.Second,
+	}
+	client, err = p.clientFor(tuned.Route)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if client == p.client {
+		t.Fatal("expected route with transport overrides to get a dedicated client")
+	}
+
+	transport := client.Transport.(*http.Transport)
+	if transport.MaxIdleConnsPerHost != 2 {
+		t.Errorf("expected MaxIdleConnsPerHost 2, got %d", transport.MaxIdleConnsPerHost)
+	}
+	if transport.ResponseHeaderTimeout != 5*time.Second {
+		t.Errorf("expected ResponseHeaderTimeout 5s, got %v", transport.ResponseHeaderTimeout)
+	}
+	if transport.TLSHandshakeTimeout != p.config.TLSHandshakeTimeout {
+		t.Errorf("expected unset TLS handshake timeout to inherit default, got %v", transport.TLSHandshakeTimeout)
+	}
+
+	again, _ := p.clientFor(tuned.Route)
+	if again != client {
+		t.Error("expected dedicated client to be reused")
+	}
+}
+
+func TestResponseHeaderTimeout(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer backend.Close()
+
+	cfg := DefaultConfig()
+	cfg.MaxRetries = 0
+	p := New(cfg)
+
+	match := newTestMatch(backend.URL)
+	match.Route.Transport.ResponseHeaderTimeout = 50 * time.Millisecond
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if err := p.Forward(httptest.NewRecorder(), req, match); !errors.Is(err, ErrBackendTimeout) {
+		t.Errorf("expected backend timeout error, got %v", err)
+	}
+}
+
+func TestRouteTotalTimeout(t *testing.T) {
+	tests := []struct {
+		name         string
+		routeTimeout time.Duration
+		expectedErr  error
+	}{
+		{
+			name:         "route timeout longer than the default",
+			routeTimeout: time.Second,
+			expectedErr:  nil,
+		},
+		{
+			name:         "route timeout exceeded",
+			routeTimeout: 20 * time.Millisecond,
+			expectedErr:  ErrBackendTimeout,
+		},
+		{
+			name:        "default timeout exceeded",
+			expectedErr: ErrBackendTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(100 * time.Millisecond):
+				case <-r.Context().Done():
+				}
+			}))
+			defer backend.Close()
+
+			cfg := DefaultConfig()
+			cfg.DefaultTimeout = 50 * time.Millisecond
+			cfg.MaxRetries = 0
+			p := New(cfg)
+
+			match := newTestMatch(backend.URL)
+			match.Route.Timeout = tt.routeTimeout
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			err := p.Forward(httptest.NewRecorder(), req, match)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("expected error %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestProxyProtocolUpstream(t *testing.T) {
+	for _, version := range []string{"v1", "v2"} {
+		t.Run(version, func(t *testing.T) {
+			backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(r.RemoteAddr))
+			}))
+			backend.Listener = &proxyproto.Listener{Listener: backend.Listener}
+			backend.Start()
+			defer backend.Close()
+
+			p := New(DefaultConfig())
+			match := newTestMatch(backend.URL)
+			match.Route.ProxyProtocol = version
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "203.0.113.7:51234"
+			rr := httptest.NewRecorder()
+			if err := p.Forward(rr, req, match); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := rr.Body.String(); got != "203.0.113.7:51234" {
+				t.Errorf("expected backend to see client address 203.0.113.7:51234, got %q", got)
+			}
+		})
+	}
+}
+
+func TestUpstreamHTTP2(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		expected string
+	}{
+		{name: "default", mode: "", expected: "HTTP/1.1"},
+		{name: "h2c", mode: "h2c", expected: "HTTP/2.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(r.Proto))
+			}))
+			backend.Config.Protocols = new(http.Protocols)
+			backend.Config.Protocols.SetHTTP1(true)
+			backend.Config.Protocols.SetUnencryptedHTTP2(true)
+			backend.Start()
+			defer backend.Close()
+
+			p := New(DefaultConfig())
+			match := newTestMatch(backend.URL)
+			match.Route.Transport.HTTP2 = tt.mode
+
+			rr := httptest.NewRecorder()
+			if err := p.Forward(rr, httptest.NewRequest(http.MethodGet, "/", nil), match); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := rr.Body.String(); got != tt.expected {
+				t.Errorf("expected backend to see %s, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestForwardedHeaders(t *testing.T) {
+	_, trusted, _ := net.ParseCIDR("10.0.0.0/8")
+
+	tests := []struct {
+		name              string
+		mode              string
+		header            string
+		remoteAddr        string
+		forwardedFor      string
+		forwarded         string
+		realIP            string
+		expectedXFF       string
+		expectedForwarded string
+		expectedRealIP    string
+	}{
+		{
+			name:              "spoofed headers from client are replaced",
+			mode:              "x-forwarded",
+			remoteAddr:        "203.0.113.7:4000",
+			forwardedFor:      "1.2.3.4",
+			forwarded:         "for=1.2.3.4",
+			realIP:            "1.2.3.4",
+			expectedXFF:       "203.0.113.7",
+			expectedForwarded: "",
+			expectedRealIP:    "203.0.113.7",
+		},
+		{
+			name:           "trusted proxy chain is extended",
+			mode:           "x-forwarded",
+			remoteAddr:     "10.0.0.5:4000",
+			forwardedFor:   "198.51.100.1",
+			expectedXFF:    "198.51.100.1, 10.0.0.5",
+			expectedRealIP: "198.51.100.1",
+		},
+		{
+			name:              "rfc7239 only",
+			mode:              "rfc7239",
+			remoteAddr:        "203.0.113.7:4000",
+			forwardedFor:      "1.2.3.4",
+			expectedXFF:       "",
+			expectedForwarded: "for=203.0.113.7;by=gw1;host=example.com;proto=http",
+			expectedRealIP:    "203.0.113.7",
+		},
+		{
+			name:              "client Forwarded behind X-Forwarded-For proxies is replaced",
+			mode:              "both",
+			remoteAddr:        "10.0.0.5:4000",
+			forwardedFor:      "198.51.100.1",
+			forwarded:         "for=1.2.3.4",
+			expectedXFF:       "198.51.100.1, 10.0.0.5",
+			expectedForwarded: "for=10.0.0.5;by=gw1;host=example.com;proto=http",
+			expectedRealIP:    "198.51.100.1",
+		},
+		{
+			name:              "both with trusted Forwarded chain",
+			mode:              "both",
+			header:            clientip.HeaderForwarded,
+			remoteAddr:        "10.0.0.5:4000",
+			forwarded:         `for="[2001:db8::7]:4711";proto=https`,
+			expectedXFF:       "10.0.0.5",
+			expectedForwarded: `for="[2001:db8::7]:4711";proto=https, for=10.0.0.5;by=gw1;host=example.com;proto=http`,
+			expectedRealIP:    "2001:db8::7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Seen-XFF", r.Header.Get("X-Forwarded-For"))
+				w.Header().Set("X-Seen-Forwarded", r.Header.Get("Forwarded"))
+				w.Header().Set("X-Seen-Real-IP", r.Header.Get("X-Real-IP"))
+			}))
+			defer backend.Close()
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			if tt.forwarded != "" {
+				req.Header.Set("Forwarded", tt.forwarded)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			resolver := clientip.NewResolver([]*net.IPNet{trusted}).WithHeader(tt.header)
+			req = req.WithContext(clientip.WithInfo(req.Context(), resolver.Resolve(req)))
+
+			cfg := DefaultConfig()
+			cfg.ForwardedHeaders = tt.mode
+			cfg.ForwardedBy = "gw1"
+
+			rr := httptest.NewRecorder()
+			if err := New(cfg).Forward(rr, req, newTestMatch(backend.URL)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := rr.Header().Get("X-Seen-XFF"); got != tt.expectedXFF {
+				t.Errorf("expected X-Forwarded-For %q, got %q", tt.expectedXFF, got)
+			}
+			if got := rr.Header().Get("X-Seen-Forwarded"); got != tt.expectedForwarded {
+				t.Errorf("expected Forwarded %q, got %q", tt.expectedForwarded, got)
+			}
+			if got := rr.Header().Get("X-Seen-Real-IP"); got != tt.expectedRealIP {
+				t.Errorf("expected X-Real-IP %q, got %q", tt.expectedRealIP, got)
+			}
+		})
+	}
+}
+
+func TestNewConfigFromConfig(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Proxy.MaxRetries = 1
+	cfg.Proxy.RetryDelay = 250 * time.Millisecond
+	cfg.Proxy.IdleConnTimeout = time.Minute
+	cfg.Proxy.DefaultTimeout = 5 * time.Second
+	cfg.Proxy.CircuitBreaker.FailureThreshold = 7
+
+	proxyCfg := NewConfigFromConfig(cfg)
+	if proxyCfg.MaxRetries != 1 || proxyCfg.RetryDelay != 250*time.Millisecond || proxyCfg.DefaultTimeout != 5*time.Second {
+		t.Errorf("retry and timeout settings not taken from config: %+v", proxyCfg)
+	}
+	if proxyCfg.CircuitBreaker.FailureThreshold != 7 {
+		t.Errorf("expected circuit breaker failure threshold 7, got %d", proxyCfg.CircuitBreaker.FailureThreshold)
+	}
+
+	transport := newTransport(proxyCfg, config.TransportConfig{}, nil)
+	if transport.IdleConnTimeout != time.Minute {
+		t.Errorf("expected idle conn timeout 1m, got %s", transport.IdleConnTimeout)
+	}
+	transport = newTransport(proxyCfg, config.TransportConfig{IdleConnTimeout: 5 * time.Second}, nil)
+	if transport.IdleConnTimeout != 5*time.Second {
+		t.Errorf("expected route idle conn timeout 5s, got %s", transport.IdleConnTimeout)
+	}
+}
@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// ErrRangeNotSatisfiable is returned when a Range header violates the route range limits
+var ErrRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// byteRange is an explicit first-last byte range from a Range header
+type byteRange struct {
+	first int64
+	last  int64 // -1 for open ended ranges such as "500-"
+}
+
+// validateRange enforces the route range limits on a request.
+// Malformed or non-byte Range headers are left for the backend to ignore,
+// as permitted by RFC 9110.
+func validateRange(r *http.Request, route *router.Route) error {
+	if route.Ranges.MaxRanges == 0 && !route.Ranges.RejectOverlapping {
+		return nil
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return nil
+	}
+
+	header := r.Header.Get("Range")
+	if header == "" {
+		return nil
+	}
+
+	unit, set, ok := strings.Cut(header, "=")
+	if !ok || !strings.EqualFold(strings.TrimSpace(unit), "bytes") {
+		return nil
+	}
+
+	specs := strings.Split(set, ",")
+	if route.Ranges.MaxRanges > 0 && len(specs) > route.Ranges.MaxRanges {
+		return fmt.Errorf("%w: %d ranges requested, at most %d allowed",
+			ErrRangeNotSatisfiable, len(specs), route.Ranges.MaxRanges)
+	}
+
+	if route.Ranges.RejectOverlapping {
+		ranges, ok := parseByteRanges(specs)
+		if ok && rangesOverlap(ranges) {
+			return fmt.Errorf("%w: overlapping ranges requested", ErrRangeNotSatisfiable)
+		}
+	}
+
+	return nil
+}
+
+// parseByteRanges parses explicit byte range specs.
+// Suffix ranges ("-500") are skipped because their position depends on the
+// representation length, which the gateway does not know.
+func parseByteRanges(specs []string) ([]byteRange, bool) {
+	ranges := make([]byteRange, 0, len(specs))
+	for _, spec := range specs {
+		first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+		if !ok {
+			return nil, false
+		}
+		if first == "" {
+			continue
+		}
+
+		start, err := strconv.ParseInt(first, 10, 64)
+		if err != nil || start < 0 {
+			return nil, false
+		}
+
+		end := int64(-1)
+		if last != "" {
+			end, err = strconv.ParseInt(last, 10, 64)
+			if err != nil || end < start {
+				return nil, false
+			}
+		}
+
+		ranges = append(ranges, byteRange{first: start, last: end})
+	}
+	return ranges, true
+}
+
+// rangesOverlap reports whether any two ranges share a byte
+func rangesOverlap(ranges []byteRange) bool {
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].first < ranges[j].first
+	})
+
+	for i := 1; i < len(ranges); i++ {
+		prev := ranges[i-1]
+		if prev.last == -1 || prev.last >= ranges[i].first {
+			return true
+		}
+	}
+	return false
+}
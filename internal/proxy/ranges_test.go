@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+func TestRangePassThrough(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 10))
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "data.bin", modTime, bytes.NewReader(content))
+	}))
+	defer backend.Close()
+
+	p := New(nil)
+	match := newTestMatch(backend.URL)
+	match.Route.Ranges = config.RangeConfig{MaxRanges: 2, RejectOverlapping: true}
+
+	req := httptest.NewRequest(http.MethodGet, "/data.bin", nil)
+	req.Header.Set("Range", "bytes=10-19")
+	req.Header.Set("If-Range", modTime.Format(http.TimeFormat))
+	rr := httptest.NewRecorder()
+
+	if err := p.Forward(rr, req, match); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rr.Code != http.StatusPartialContent {
+		t.Fatalf("expected status %d, got %d", http.StatusPartialContent, rr.Code)
+	}
+	if got := rr.Header().Get("Content-Range"); got != "bytes 10-19/100" {
+		t.Errorf("expected Content-Range %q, got %q", "bytes 10-19/100", got)
+	}
+	if got := rr.Body.String(); got != string(content[10:20]) {
+		t.Errorf("expected body %q, got %q", content[10:20], got)
+	}
+}
+
+func TestValidateRange(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		rangeHeader string
+		ranges      config.RangeConfig
+		expectError bool
+	}{
+		{
+			name:        "no limits configured",
+			rangeHeader: "bytes=0-1,2-3,4-5",
+			expectError: false,
+		},
+		{
+			name:        "within range count",
+			rangeHeader: "bytes=0-99,200-299",
+			ranges:      config.RangeConfig{MaxRanges: 2},
+			expectError: false,
+		},
+		{
+			name:        "too many ranges",
+			rangeHeader: "bytes=0-1,2-3,4-5",
+			ranges:      config.RangeConfig{MaxRanges: 2},
+			expectError: true,
+		},
+		{
+			name:        "overlapping ranges",
+			rangeHeader: "bytes=0-99,50-149",
+			ranges:      config.RangeConfig{RejectOverlapping: true},
+			expectError: true,
+		},
+		{
+			name:        "open ended range overlaps later range",
+			rangeHeader: "bytes=100-,200-299",
+			ranges:      config.RangeConfig{RejectOverlapping: true},
+			expectError: true,
+		},
+		{
+			name:        "disjoint ranges with suffix range",
+			rangeHeader: "bytes=0-99,200-299,-50",
+			ranges:      config.RangeConfig{RejectOverlapping: true},
+			expectError: false,
+		},
+		{
+			name:        "non-byte unit passed through",
+			rangeHeader: "items=0-1,2-3,4-5",
+			ranges:      config.RangeConfig{MaxRanges: 1},
+			expectError: false,
+		},
+		{
+			name:        "ignored for POST",
+			method:      http.MethodPost,
+			rangeHeader: "bytes=0-1,2-3",
+			ranges:      config.RangeConfig{MaxRanges: 1},
+			expectError: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, "/data.bin", nil)
+			req.Header.Set("Range", tt.rangeHeader)
+
+			match := newTestMatch("http://backend.internal")
+			match.Route.Ranges = tt.ranges
+
+			err := validateRange(req, match.Route)
+			if tt.expectError && !errors.Is(err, ErrRangeNotSatisfiable) {
+				t.Errorf("expected ErrRangeNotSatisfiable, got %v", err)
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// ReleaseOrphanedState is a router reload hook. It releases the circuit
+// breakers, bulkheads, instance pools, dedicated clients, response caches
+// and last good responses of cache fallbacks that no route refers to
+// anymore, so state of a backend URL that changed does not linger under the
+// old URL. A bulkhead whose backend is still routed but with a new in-flight
+// limit is migrated to the new limit, keeping its requests in flight and
+// queued. State still referenced is kept as is.
+func (p *Proxy) ReleaseOrphanedState(routes []*router.Route) {
+	backends := make(map[string]bool)
+	clients := make(map[string]bool)
+	pools := make(map[string]bool)
+	caches := make(map[string]bool)
+	fallbacks := make(map[string]bool)
+	bulkheads := make(map[string][]int)
+	for _, route := range routes {
+		backends[route.BackendURL] = true
+		for _, name := range instanceNames(route) {
+			backends[name] = true
+		}
+		clients[clientKey(route)] = true
+		pools[instancePoolKey(route)] = true
+		caches[responseCacheKey(route)] = true
+		fallbacks[fallbackKey(route)] = true
+		if limit := p.bulkheadLimit(route); limit > 0 {
+			bulkheads[route.BackendURL] = append(bulkheads[route.BackendURL], limit)
+		}
+	}
+
+	released := map[string]int{
+		"circuit_breaker": p.circuitBreakers.Prune(func(name string) bool { return backends[name] }),
+		"client":          p.releaseClients(clients),
+		"instance_pool":   p.releasePools(pools),
+		"response_cache":  p.releaseResponseCaches(caches),
+		"fallback_cache":  p.releaseFallbacks(fallbacks),
+		"bulkhead":        p.circuitBreakers.PruneBulkheads(bulkheads),
+	}
+
+	total := 0
+	for kind, count := range released {
+		if count > 0 {
+			metrics.RecordOrphanedStateReleased(kind, count)
+			total += count
+		}
+	}
+	if total > 0 {
+		p.logger.Info("released state orphaned by route reload", logger.Fields{
+			"circuit_breakers": released["circuit_breaker"],
+			"clients":          released["client"],
+			"instance_pools":   released["instance_pool"],
+			"response_caches":  released["response_cache"],
+			"fallback_caches":  released["fallback_cache"],
+			"bulkheads":        released["bulkhead"],
+		})
+	}
+}
+
+// releaseClients closes the idle connections of dedicated clients that are
+// not in keep and forgets them. Requests using them run to completion.
+func (p *Proxy) releaseClients(keep map[string]bool) int {
+	p.clientsMu.Lock()
+	defer p.clientsMu.Unlock()
+
+	released := 0
+	for key, client := range p.clients {
+		if keep[key] {
+			continue
+		}
+		client.CloseIdleConnections()
+		delete(p.clients, key)
+		released++
+	}
+	return released
+}
+
+// releasePools forgets instance pools that are not in keep
+func (p *Proxy) releasePools(keep map[string]bool) int {
+	p.poolsMu.Lock()
+	defer p.poolsMu.Unlock()
+
+	released := 0
+	for key := range p.pools {
+		if !keep[key] {
+			delete(p.pools, key)
+			released++
+		}
+	}
+	return released
+}
+
+// releaseResponseCaches forgets response caches that are not in keep
+func (p *Proxy) releaseResponseCaches(keep map[string]bool) int {
+	p.responseCachesMu.Lock()
+	defer p.responseCachesMu.Unlock()
+
+	released := 0
+	for key := range p.responseCaches {
+		if !keep[key] {
+			delete(p.responseCaches, key)
+			released++
+		}
+	}
+	return released
+}
+
+// releaseFallbacks forgets last good responses that are not in keep
+func (p *Proxy) releaseFallbacks(keep map[string]bool) int {
+	p.fallbacksMu.Lock()
+	defer p.fallbacksMu.Unlock()
+
+	released := 0
+	for key := range p.fallbacks {
+		if !keep[key] {
+			delete(p.fallbacks, key)
+			released++
+		}
+	}
+	return released
+}
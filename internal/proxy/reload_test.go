@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/maltehedderich/api-gateway-go/internal/circuitbreaker"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+func TestReleaseOrphanedState(t *testing.T) {
+	p := New(DefaultConfig())
+
+	orders := &router.Route{PathPattern: "/orders", BackendURL: "http://orders-v1", MaxInFlight: 2}
+	users := &router.Route{PathPattern: "/users", BackendURL: "http://users", Instances: []string{"http://users-2:8080/"}}
+	users.CachePolicy.Store = config.ResponseCacheConfig{Enabled: true}
+	p.circuitBreakers.Get(orders.BackendURL, circuitbreaker.DefaultConfig())
+	p.circuitBreakers.Get(users.BackendURL, circuitbreaker.DefaultConfig())
+	p.circuitBreakers.Get("http://users-2:8080", circuitbreaker.DefaultConfig())
+	ordersBulkhead := p.bulkheadFor(orders)
+	usersCache := p.responseCacheFor(users)
+
+	// The orders backend moves to a new URL
+	moved := *orders
+	moved.BackendURL = "http://orders-v2"
+	p.ReleaseOrphanedState([]*router.Route{&moved, users})
+
+	breakers := make(map[string]bool)
+	for _, stats := range p.circuitBreakers.GetStats() {
+		breakers[stats.Name] = true
+	}
+	if breakers["http://orders-v1"] {
+		t.Error("expected the breaker of the old backend URL to be disposed")
+	}
+	if !breakers["http://users"] || !breakers["http://users-2:8080"] {
+		t.Error("expected the breakers of the instances of an unchanged backend to be kept")
+	}
+	if n := len(p.circuitBreakers.BulkheadStats()); n != 0 {
+		t.Errorf("expected the bulkhead of the old backend URL to be released, %d left", n)
+	}
+	if p.bulkheadFor(orders) == ordersBulkhead {
+		t.Error("expected a new bulkhead once the old backend URL is routed again")
+	}
+	if p.responseCacheFor(users) != usersCache {
+		t.Error("expected the response cache of an unchanged route to be kept")
+	}
+
+	// Removing the last cached route releases its cache
+	p.ReleaseOrphanedState([]*router.Route{&moved})
+	if len(p.responseCaches) != 0 {
+		t.Errorf("expected the response cache of a removed route to be released, %d left", len(p.responseCaches))
+	}
+}
+
+func TestReleaseOrphanedState_MigratesBulkhead(t *testing.T) {
+	p := New(DefaultConfig())
+
+	route := &router.Route{PathPattern: "/orders", BackendURL: "http://orders", MaxInFlight: 1}
+	bh := p.bulkheadFor(route)
+	if err := bh.Acquire(context.Background(), "normal"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Raising the limit keeps the request in flight
+	raised := *route
+	raised.MaxInFlight = 2
+	p.ReleaseOrphanedState([]*router.Route{&raised})
+
+	if p.bulkheadFor(&raised) != bh {
+		t.Fatal("expected the bulkhead to be migrated to the new limit")
+	}
+	if stats := bh.Stats(); stats.Limit != 2 || stats.InFlight != 1 {
+		t.Errorf("expected limit 2 with 1 in flight, got %+v", stats)
+	}
+}
@@ -0,0 +1,545 @@
+package proxy
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/auth"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/identity"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+const (
+	defaultCacheMaxEntries  = 1000
+	defaultCacheMaxBodySize = 1024 * 1024
+)
+
+// perUserResponseHeaders carry state of the user the response was generated
+// for and are stripped from stored entries unless a route allows them
+var perUserResponseHeaders = []string{
+	"Set-Cookie",
+	"Set-Cookie2",
+	"Authentication-Info",
+	"Proxy-Authentication-Info",
+}
+
+// credentialHeaders are request headers identifying the user; responses
+// varying on them are only stored when a route allows it
+var credentialHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// responseCache stores the backend responses of one route. Entries are found
+// in two steps, as described in RFC 9111: the URL selects the list of
+// request headers the stored responses vary on, and the normalized values
+// of those headers select the variant. Least recently used entries are
+// evicted once MaxEntries is reached.
+type responseCache struct {
+	cfg   config.ResponseCacheConfig
+	route string
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List
+	variants map[string][]string // primary key -> canonical Vary header names
+}
+
+// cachedResponse is a stored response
+type cachedResponse struct {
+	key     string
+	path    string
+	status  int
+	header  http.Header
+	body    []byte
+	stored  time.Time
+	expires time.Time
+	// surrogateKeys are the tags of the backend's Surrogate-Key header
+	surrogateKeys []string
+}
+
+// CachePurge selects cached responses to purge. Each set field must match:
+// Key one of the surrogate keys the backend tagged the response with, Route
+// the path pattern of the route that cached it and Pattern, a path.Match
+// glob such as /products/*, the request path.
+type CachePurge struct {
+	Key     string `json:"key,omitempty"`
+	Route   string `json:"route,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// Validate checks that the purge selects something and that its pattern is
+// well-formed
+func (c CachePurge) Validate() error {
+	if c.Key == "" && c.Route == "" && c.Pattern == "" {
+		return fmt.Errorf("one of key, route or pattern is required")
+	}
+	if _, err := path.Match(c.Pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern: %q", c.Pattern)
+	}
+	return nil
+}
+
+// matches reports whether a stored response is selected by the purge
+func (c CachePurge) matches(entry *cachedResponse) bool {
+	if c.Key != "" && !slices.Contains(entry.surrogateKeys, c.Key) {
+		return false
+	}
+	if c.Pattern != "" {
+		if ok, _ := path.Match(c.Pattern, entry.path); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// pendingResponse collects a response body while it is streamed to the client
+type pendingResponse struct {
+	cache   *responseCache
+	primary string
+	vary    []string
+	entry   *cachedResponse
+	max     int64
+
+	body     io.Reader
+	complete bool
+	overflow bool
+}
+
+func newResponseCache(route string, cfg config.ResponseCacheConfig) *responseCache {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = defaultCacheMaxEntries
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = defaultCacheMaxBodySize
+	}
+	return &responseCache{
+		cfg:      cfg,
+		route:    route,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		variants: make(map[string][]string),
+	}
+}
+
+// responseCacheKey identifies the response cache of a route
+func responseCacheKey(route *router.Route) string {
+	return fmt.Sprintf("%s|%+v", route.PathPattern, route.CachePolicy.Store)
+}
+
+// responseCacheFor returns the response cache of a route, or nil if disabled
+func (p *Proxy) responseCacheFor(route *router.Route) *responseCache {
+	if !route.CachePolicy.Store.Enabled {
+		return nil
+	}
+
+	key := responseCacheKey(route)
+
+	p.responseCachesMu.Lock()
+	defer p.responseCachesMu.Unlock()
+
+	if cache, ok := p.responseCaches[key]; ok {
+		return cache
+	}
+	cache := newResponseCache(route.PathPattern, route.CachePolicy.Store)
+	p.responseCaches[key] = cache
+	return cache
+}
+
+// PurgeResponseCache removes the cached responses selected by purge from
+// the response caches of all routes and returns how many it removed
+func (p *Proxy) PurgeResponseCache(purge CachePurge) int {
+	p.responseCachesMu.Lock()
+	caches := make([]*responseCache, 0, len(p.responseCaches))
+	for _, cache := range p.responseCaches {
+		if purge.Route == "" || cache.route == purge.Route {
+			caches = append(caches, cache)
+		}
+	}
+	p.responseCachesMu.Unlock()
+
+	purged := 0
+	for _, cache := range caches {
+		purged += cache.purge(purge)
+	}
+	return purged
+}
+
+// purge removes the entries selected by purge and returns their number
+func (c *responseCache) purge(purge CachePurge) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	purged := 0
+	for _, element := range c.entries {
+		if purge.matches(element.Value.(*cachedResponse)) {
+			c.remove(element)
+			purged++
+		}
+	}
+	return purged
+}
+
+// usable reports whether r may be answered from or stored in the cache.
+// Authenticated requests need a subject to keep users apart.
+func (c *responseCache) usable(r *http.Request) bool {
+	if r.Method != http.MethodGet || r.Header.Get("Range") != "" {
+		return false
+	}
+	if _, ok := auth.GetUserContext(r.Context()); ok {
+		return c.subject(r) != ""
+	}
+	return true
+}
+
+// subject returns the configured claim of the authenticated user
+func (c *responseCache) subject(r *http.Request) string {
+	if c.cfg.SubjectClaim == "" {
+		return ""
+	}
+	return identity.Claim(r, c.cfg.SubjectClaim)
+}
+
+// serve writes a stored response for r and reports whether it did
+func (c *responseCache) serve(w http.ResponseWriter, r *http.Request) bool {
+	if parseCacheControl(r.Header).has("no-cache", "no-store") {
+		return false
+	}
+
+	primary := primaryCacheKey(r)
+
+	c.mu.Lock()
+	vary, ok := c.variants[primary]
+	var entry *cachedResponse
+	if ok {
+		key := c.variantKey(primary, vary, r)
+		if element, found := c.entries[key]; found {
+			entry = element.Value.(*cachedResponse)
+			if time.Now().After(entry.expires) {
+				c.remove(element)
+				entry = nil
+			} else {
+				c.lru.MoveToFront(element)
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	if entry == nil {
+		metrics.RecordResponseCache(c.route, "miss")
+		return false
+	}
+	metrics.RecordResponseCache(c.route, "hit")
+
+	for name, values := range entry.header {
+		w.Header()[name] = slices.Clone(values)
+	}
+	w.Header().Set("Age", strconv.Itoa(int(time.Since(entry.stored).Seconds())))
+	w.Header().Set("X-Cache", "HIT")
+	w.WriteHeader(entry.status)
+	_, _ = w.Write(entry.body)
+	return true
+}
+
+// prepare decides whether the response about to be sent can be stored.
+// before and after are the client response headers before and after the
+// backend headers were applied; only the difference is stored so that
+// headers of other middleware are not replayed. The returned pending
+// response must wrap the body, or is nil if the response is not stored.
+func (c *responseCache) prepare(r *http.Request, status int, before, after http.Header) *pendingResponse {
+	if status != http.StatusOK {
+		return nil
+	}
+
+	directives := parseCacheControl(after)
+	if directives.has("no-store", "no-cache", "private") {
+		return nil
+	}
+	ttl := c.cfg.DefaultTTL
+	if age, ok := directives.seconds("s-maxage"); ok {
+		ttl = age
+	} else if age, ok := directives.seconds("max-age"); ok {
+		ttl = age
+	}
+	if ttl <= 0 {
+		return nil
+	}
+
+	vary := varyHeaders(after)
+	if slices.Contains(vary, "*") {
+		return nil
+	}
+	if !c.cfg.AllowCredentialVary && slices.ContainsFunc(vary, func(name string) bool {
+		return slices.Contains(credentialHeaders, name)
+	}) {
+		metrics.RecordResponseCache(c.route, "credential_vary")
+		return nil
+	}
+
+	header := addedHeaders(before, after)
+	c.stripPerUserHeaders(header)
+
+	primary := primaryCacheKey(r)
+	now := time.Now()
+	return &pendingResponse{
+		cache:   c,
+		primary: primary,
+		vary:    vary,
+		max:     c.cfg.MaxBodySize,
+		entry: &cachedResponse{
+			key:           c.variantKey(primary, vary, r),
+			path:          r.URL.Path,
+			status:        status,
+			header:        header,
+			stored:        now,
+			expires:       now.Add(ttl),
+			surrogateKeys: strings.Fields(strings.Join(after.Values("Surrogate-Key"), " ")),
+		},
+	}
+}
+
+// addedHeaders returns the headers of after that differ from before
+func addedHeaders(before, after http.Header) http.Header {
+	header := make(http.Header)
+	for name, values := range after {
+		if !slices.Equal(before[name], values) {
+			header[name] = slices.Clone(values)
+		}
+	}
+	return header
+}
+
+// stripPerUserHeaders removes the per-user headers the route does not allow
+// from a response about to be stored
+func (c *responseCache) stripPerUserHeaders(header http.Header) {
+	for _, name := range perUserResponseHeaders {
+		if !slices.ContainsFunc(c.cfg.AllowHeaders, func(allowed string) bool {
+			return http.CanonicalHeaderKey(allowed) == name
+		}) {
+			header.Del(name)
+		}
+	}
+}
+
+// wrap returns a reader that records the body as it is streamed
+func (p *pendingResponse) wrap(body io.Reader) io.Reader {
+	p.body = body
+	return p
+}
+
+func (p *pendingResponse) Read(b []byte) (int, error) {
+	n, err := p.body.Read(b)
+	if !p.overflow {
+		if int64(len(p.entry.body)+n) > p.max {
+			p.overflow = true
+			p.entry.body = nil
+		} else {
+			p.entry.body = append(p.entry.body, b[:n]...)
+		}
+	}
+	if err == io.EOF {
+		p.complete = true
+	}
+	return n, err
+}
+
+// store saves the response if its body was received completely
+func (p *pendingResponse) store() {
+	if !p.complete || p.overflow {
+		return
+	}
+	c := p.cache
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// A changed Vary header invalidates the variants stored so far
+	if vary, ok := c.variants[p.primary]; ok && !slices.Equal(vary, p.vary) {
+		for key, element := range c.entries {
+			if strings.HasPrefix(key, p.primary+"\n") {
+				c.remove(element)
+			}
+		}
+	}
+	c.variants[p.primary] = p.vary
+
+	if element, ok := c.entries[p.entry.key]; ok {
+		c.remove(element)
+	}
+	c.entries[p.entry.key] = c.lru.PushFront(p.entry)
+	metrics.RecordResponseCache(c.route, "store")
+
+	for c.lru.Len() > c.cfg.MaxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove deletes an entry; the caller holds mu
+func (c *responseCache) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*cachedResponse)
+	delete(c.entries, entry.key)
+}
+
+// primaryCacheKey identifies the requested resource
+func primaryCacheKey(r *http.Request) string {
+	return r.Method + " " + r.Host + r.URL.RequestURI()
+}
+
+// variantKey extends the primary key with the normalized values of the
+// varied request headers and, for authenticated requests, the subject
+func (c *responseCache) variantKey(primary string, vary []string, r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(primary)
+	b.WriteByte('\n')
+	if subject := c.subject(r); subject != "" {
+		b.WriteString("subject=" + subject + "\n")
+	}
+	for _, name := range vary {
+		b.WriteString(name + "=" + normalizeVaryValue(name, c.subject(r), r) + "\n")
+	}
+	return b.String()
+}
+
+// normalizeVaryValue reduces a request header to a canonical form so that
+// equivalent requests share an entry. Credentials are replaced by subject,
+// the authenticated user if known, or a hash, so they never end up in keys.
+func normalizeVaryValue(name, subject string, r *http.Request) string {
+	values := r.Header.Values(name)
+	switch name {
+	case "Accept-Encoding":
+		codings := weightedTokens(values)
+		sort.Strings(codings)
+		return strings.Join(slices.Compact(codings), ",")
+	case "Accept-Language":
+		return strings.Join(weightedTokens(values), ",")
+	case "Authorization", "Cookie":
+		if subject != "" {
+			return "subject:" + subject
+		}
+		if len(values) == 0 {
+			return ""
+		}
+		sum := sha256.Sum256([]byte(strings.Join(values, "\n")))
+		return "sha256:" + hex.EncodeToString(sum[:])
+	default:
+		parts := make([]string, 0, len(values))
+		for _, value := range values {
+			for _, part := range strings.Split(value, ",") {
+				if part = strings.TrimSpace(part); part != "" {
+					parts = append(parts, part)
+				}
+			}
+		}
+		return strings.Join(parts, ",")
+	}
+}
+
+// weightedTokens parses a header like Accept-Language into lowercase tokens
+// ordered by descending quality, dropping those with q=0
+func weightedTokens(values []string) []string {
+	type weighted struct {
+		token string
+		q     float64
+	}
+	var tokens []weighted
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			token, params, _ := strings.Cut(part, ";")
+			token = strings.ToLower(strings.TrimSpace(token))
+			if token == "" {
+				continue
+			}
+			q := 1.0
+			if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+			if q > 0 {
+				tokens = append(tokens, weighted{token, q})
+			}
+		}
+	}
+	sort.SliceStable(tokens, func(i, j int) bool { return tokens[i].q > tokens[j].q })
+
+	out := make([]string, len(tokens))
+	for i, t := range tokens {
+		out[i] = t.token
+	}
+	return out
+}
+
+// varyHeaders returns the canonical, sorted header names of the Vary header
+func varyHeaders(h http.Header) []string {
+	var names []string
+	for _, value := range h.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return slices.Compact(names)
+}
+
+// addVary adds names to the Vary header unless already present
+func addVary(h http.Header, names []string) {
+	present := varyHeaders(h)
+	for _, name := range names {
+		if canonical := http.CanonicalHeaderKey(name); !slices.Contains(present, canonical) {
+			h.Add("Vary", canonical)
+			present = append(present, canonical)
+		}
+	}
+}
+
+// cacheDirectives are parsed Cache-Control directives
+type cacheDirectives map[string]string
+
+// parseCacheControl parses the Cache-Control header of h
+func parseCacheControl(h http.Header) cacheDirectives {
+	directives := make(cacheDirectives)
+	for _, value := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name != "" {
+				directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+			}
+		}
+	}
+	return directives
+}
+
+// has reports whether any of the directives is present
+func (d cacheDirectives) has(names ...string) bool {
+	for _, name := range names {
+		if _, ok := d[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// seconds returns a delta-seconds directive as a duration
+func (d cacheDirectives) seconds(name string) (time.Duration, bool) {
+	arg, ok := d[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
@@ -0,0 +1,243 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/auth"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+// cacheRequest describes a request to a cached route
+type cacheRequest struct {
+	path      string
+	header    map[string]string
+	user      string
+	expectHit bool
+	// expectHeader lists response headers to check; empty means absent
+	expectHeader map[string]string
+}
+
+func TestResponseCache(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        config.CachePolicyConfig
+		backendHeader map[string]string
+		requests      []cacheRequest
+	}{
+		{
+			name:          "normalized Accept-Language",
+			policy:        config.CachePolicyConfig{Store: config.ResponseCacheConfig{Enabled: true}},
+			backendHeader: map[string]string{"Cache-Control": "max-age=60", "Vary": "Accept-Language"},
+			requests: []cacheRequest{
+				{path: "/items", header: map[string]string{"Accept-Language": "en-US, en;q=0.8"}},
+				{path: "/items", header: map[string]string{"Accept-Language": "EN-us,en;q=0.8, fr;q=0"}, expectHit: true},
+				{path: "/items", header: map[string]string{"Accept-Language": "de"}},
+				{path: "/other", header: map[string]string{"Accept-Language": "de"}},
+			},
+		},
+		{
+			name: "route vary with normalized Accept-Encoding",
+			policy: config.CachePolicyConfig{
+				CacheControl: "public, max-age=60",
+				Vary:         []string{"accept-encoding"},
+				Store:        config.ResponseCacheConfig{Enabled: true},
+			},
+			requests: []cacheRequest{
+				{path: "/items", header: map[string]string{"Accept-Encoding": "gzip, br"}},
+				{path: "/items", header: map[string]string{"Accept-Encoding": "br,gzip"}, expectHit: true},
+				{path: "/items"},
+			},
+		},
+		{
+			name:          "authenticated without subject claim is not cached",
+			policy:        config.CachePolicyConfig{Store: config.ResponseCacheConfig{Enabled: true}},
+			backendHeader: map[string]string{"Cache-Control": "max-age=60"},
+			requests: []cacheRequest{
+				{path: "/me", user: "alice"},
+				{path: "/me", user: "alice"},
+			},
+		},
+		{
+			name:          "authenticated responses are kept apart per subject",
+			policy:        config.CachePolicyConfig{Store: config.ResponseCacheConfig{Enabled: true, SubjectClaim: "user_id", AllowCredentialVary: true}},
+			backendHeader: map[string]string{"Cache-Control": "max-age=60", "Vary": "Authorization"},
+			requests: []cacheRequest{
+				{path: "/me", user: "alice", header: map[string]string{"Authorization": "Bearer a1"}},
+				{path: "/me", user: "bob", header: map[string]string{"Authorization": "Bearer b1"}},
+				{path: "/me", user: "alice", header: map[string]string{"Authorization": "Bearer a2"}, expectHit: true},
+			},
+		},
+		{
+			name:          "vary on credentials is not stored by default",
+			policy:        config.CachePolicyConfig{Store: config.ResponseCacheConfig{Enabled: true, SubjectClaim: "user_id"}},
+			backendHeader: map[string]string{"Cache-Control": "max-age=60", "Vary": "Cookie"},
+			requests: []cacheRequest{
+				{path: "/me", user: "alice", header: map[string]string{"Cookie": "session=a"}},
+				{path: "/me", user: "alice", header: map[string]string{"Cookie": "session=a"}},
+			},
+		},
+		{
+			name:          "private responses are not stored",
+			policy:        config.CachePolicyConfig{Store: config.ResponseCacheConfig{Enabled: true}},
+			backendHeader: map[string]string{"Cache-Control": "private, max-age=60"},
+			requests:      []cacheRequest{{path: "/items"}, {path: "/items"}},
+		},
+		{
+			name:          "cookies are stripped from stored responses",
+			policy:        config.CachePolicyConfig{Store: config.ResponseCacheConfig{Enabled: true}},
+			backendHeader: map[string]string{"Cache-Control": "max-age=60", "Set-Cookie": "session=1"},
+			requests: []cacheRequest{
+				{path: "/items", expectHeader: map[string]string{"Set-Cookie": "session=1"}},
+				{path: "/items", expectHit: true, expectHeader: map[string]string{"Set-Cookie": ""}},
+			},
+		},
+		{
+			name:          "allowed per-user headers are kept",
+			policy:        config.CachePolicyConfig{Store: config.ResponseCacheConfig{Enabled: true, AllowHeaders: []string{"set-cookie"}}},
+			backendHeader: map[string]string{"Cache-Control": "max-age=60", "Set-Cookie": "region=eu"},
+			requests: []cacheRequest{
+				{path: "/items"},
+				{path: "/items", expectHit: true, expectHeader: map[string]string{"Set-Cookie": "region=eu"}},
+			},
+		},
+		{
+			name:          "vary star is not stored",
+			policy:        config.CachePolicyConfig{Store: config.ResponseCacheConfig{Enabled: true}},
+			backendHeader: map[string]string{"Cache-Control": "max-age=60", "Vary": "*"},
+			requests:      []cacheRequest{{path: "/items"}, {path: "/items"}},
+		},
+		{
+			name:          "default ttl and eviction",
+			policy:        config.CachePolicyConfig{Store: config.ResponseCacheConfig{Enabled: true, DefaultTTL: time.Minute, MaxEntries: 1}},
+			backendHeader: map[string]string{},
+			requests: []cacheRequest{
+				{path: "/a"},
+				{path: "/a", expectHit: true},
+				{path: "/b"},
+				{path: "/a"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				for name, value := range tt.backendHeader {
+					w.Header().Set(name, value)
+				}
+				_, _ = w.Write([]byte("body of " + r.URL.Path))
+			}))
+			defer backend.Close()
+
+			p := New(nil)
+			match := newTestMatch(backend.URL)
+			match.Route.CachePolicy = tt.policy
+
+			for i, cr := range tt.requests {
+				req := httptest.NewRequest(http.MethodGet, cr.path, nil)
+				for name, value := range cr.header {
+					req.Header.Set(name, value)
+				}
+				if cr.user != "" {
+					req = req.WithContext(auth.SetUserContext(req.Context(), &auth.UserContext{UserID: cr.user}))
+				}
+				rr := httptest.NewRecorder()
+				before := calls.Load()
+				if err := p.Forward(rr, req, match); err != nil {
+					t.Fatalf("request %d: unexpected error: %v", i, err)
+				}
+
+				hit := calls.Load() == before
+				if hit != cr.expectHit {
+					t.Errorf("request %d: expected hit %v, got %v", i, cr.expectHit, hit)
+				}
+				if hit && rr.Header().Get("X-Cache") != "HIT" {
+					t.Errorf("request %d: expected X-Cache HIT", i)
+				}
+				for name, value := range cr.expectHeader {
+					if got := rr.Header().Get(name); got != value {
+						t.Errorf("request %d: expected %s %q, got %q", i, name, value, got)
+					}
+				}
+				if rr.Body.String() != "body of "+cr.path {
+					t.Errorf("request %d: unexpected body %q", i, rr.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestPurgeResponseCache(t *testing.T) {
+	paths := []string{"/products/1", "/products/2", "/orders/1"}
+
+	tests := []struct {
+		name   string
+		purge  CachePurge
+		purged []string
+	}{
+		{name: "surrogate key", purge: CachePurge{Key: "product-1"}, purged: []string{"/products/1"}},
+		{name: "shared surrogate key", purge: CachePurge{Key: "products"}, purged: []string{"/products/1", "/products/2"}},
+		{name: "pattern", purge: CachePurge{Pattern: "/*/1"}, purged: []string{"/products/1", "/orders/1"}},
+		{name: "route", purge: CachePurge{Route: "/**"}, purged: paths},
+		{name: "other route", purge: CachePurge{Route: "/other/**"}},
+		{name: "key and pattern", purge: CachePurge{Key: "products", Pattern: "/products/2"}, purged: []string{"/products/2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.Header().Set("Cache-Control", "max-age=60")
+				if id, ok := strings.CutPrefix(r.URL.Path, "/products/"); ok {
+					w.Header().Set("Surrogate-Key", "products product-"+id)
+				}
+				_, _ = w.Write([]byte("body of " + r.URL.Path))
+			}))
+			defer backend.Close()
+
+			p := New(nil)
+			match := newTestMatch(backend.URL)
+			match.Route.CachePolicy = config.CachePolicyConfig{Store: config.ResponseCacheConfig{Enabled: true}}
+
+			forward := func(path string) bool {
+				rr := httptest.NewRecorder()
+				before := calls.Load()
+				if err := p.Forward(rr, httptest.NewRequest(http.MethodGet, path, nil), match); err != nil {
+					t.Fatalf("%s: unexpected error: %v", path, err)
+				}
+				return calls.Load() == before
+			}
+			for _, path := range paths {
+				forward(path)
+			}
+
+			if n := p.PurgeResponseCache(tt.purge); n != len(tt.purged) {
+				t.Errorf("expected %d purged, got %d", len(tt.purged), n)
+			}
+			for _, path := range paths {
+				if hit := forward(path); hit == slices.Contains(tt.purged, path) {
+					t.Errorf("%s: expected hit %v after purge", path, !hit)
+				}
+			}
+		})
+	}
+}
+
+func TestAddVary(t *testing.T) {
+	h := http.Header{"Vary": []string{"accept-encoding"}}
+	addVary(h, []string{"Accept-Encoding", "accept-language"})
+
+	got := varyHeaders(h)
+	if len(got) != 2 || got[0] != "Accept-Encoding" || got[1] != "Accept-Language" {
+		t.Errorf("unexpected vary headers %v", got)
+	}
+}
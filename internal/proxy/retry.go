@@ -0,0 +1,203 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// defaultRetryMethods are the idempotent methods retried when a route does not
+// configure its own list (RFC 9110, section 9.2.2)
+var defaultRetryMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodOptions,
+	http.MethodPut,
+	http.MethodDelete,
+	http.MethodTrace,
+}
+
+// retryPolicy is the effective retry configuration for a request
+type retryPolicy struct {
+	maxAttempts   int
+	methods       map[string]bool
+	statusCodes   map[int]bool
+	perTryTimeout time.Duration
+	backoff       time.Duration
+}
+
+// retryPolicyFor resolves the retry policy of a route against the proxy defaults
+func (p *Proxy) retryPolicyFor(route *router.Route) retryPolicy {
+	cfg := route.Retry
+
+	policy := retryPolicy{
+		maxAttempts:   p.config.MaxRetries + 1,
+		methods:       make(map[string]bool),
+		statusCodes:   make(map[int]bool),
+		perTryTimeout: cfg.PerTryTimeout,
+		backoff:       p.config.RetryDelay,
+	}
+	if cfg.MaxAttempts > 0 {
+		policy.maxAttempts = cfg.MaxAttempts
+	}
+	if cfg.Backoff > 0 {
+		policy.backoff = cfg.Backoff
+	}
+	if route.UploadMode {
+		// Streamed bodies cannot be replayed
+		policy.maxAttempts = 1
+	}
+
+	methods := cfg.Methods
+	if len(methods) == 0 {
+		methods = defaultRetryMethods
+	}
+	for _, method := range methods {
+		policy.methods[strings.ToUpper(method)] = true
+	}
+	// Without codes of its own, a route retries the statuses its circuit
+	// breaker counts as failures
+	statusCodes := cfg.StatusCodes
+	if len(statusCodes) == 0 {
+		statusCodes = route.CircuitBreaker.WithDefaults(p.config.CircuitBreaker).FailureStatusCodes
+	}
+	for _, code := range statusCodes {
+		policy.statusCodes[code] = true
+	}
+
+	return policy
+}
+
+// canRetry reports whether req may be sent more than once
+func (rp retryPolicy) canRetry(req *http.Request) bool {
+	if rp.maxAttempts <= 1 || !rp.methods[req.Method] {
+		return false
+	}
+	// A body can only be resent if it can be recreated
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// retryBudget limits retries to a ratio of the requests seen in a sliding
+// window, so that a failing backend does not multiply its own load
+type retryBudget struct {
+	ratio        float64
+	minPerSecond int
+
+	mu       sync.Mutex
+	buckets  [retryBudgetWindow]retryBudgetBucket
+	lastTick int64
+}
+
+// retryBudgetWindow is the number of one second buckets in the budget window
+const retryBudgetWindow = 10
+
+// retryBudgetBucket counts requests and retries within one second
+type retryBudgetBucket struct {
+	requests int
+	retries  int
+}
+
+// newRetryBudget creates a retry budget
+func newRetryBudget(ratio float64, minPerSecond int) *retryBudget {
+	return &retryBudget{
+		ratio:        ratio,
+		minPerSecond: minPerSecond,
+	}
+}
+
+// recordRequest records an original (non-retry) request
+func (b *retryBudget) recordRequest() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.current(time.Now()).requests++
+}
+
+// withdraw reserves a retry if the budget allows it
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bucket := b.current(time.Now())
+
+	var requests, retries int
+	for _, bucket := range b.buckets {
+		requests += bucket.requests
+		retries += bucket.retries
+	}
+
+	allowed := int(float64(requests) * b.ratio)
+	if floor := b.minPerSecond * retryBudgetWindow; allowed < floor {
+		allowed = floor
+	}
+	if retries >= allowed {
+		return false
+	}
+
+	bucket.retries++
+	return true
+}
+
+// current returns the bucket for now, clearing buckets that left the window
+func (b *retryBudget) current(now time.Time) *retryBudgetBucket {
+	tick := now.Unix()
+	if elapsed := tick - b.lastTick; elapsed > 0 {
+		if elapsed > retryBudgetWindow {
+			elapsed = retryBudgetWindow
+		}
+		for i := int64(1); i <= elapsed; i++ {
+			b.buckets[(b.lastTick+i)%retryBudgetWindow] = retryBudgetBucket{}
+		}
+		b.lastTick = tick
+	}
+	return &b.buckets[tick%retryBudgetWindow]
+}
+
+// doAttempt sends a single attempt, bounded by the per-try timeout
+func doAttempt(client *http.Client, req *http.Request, perTryTimeout time.Duration) (*http.Response, error) {
+	if perTryTimeout <= 0 {
+		return client.Do(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), perTryTimeout)
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// Keep the attempt context alive until the body has been consumed
+	resp.Body = cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose releases a context when the response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close implements io.Closer
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
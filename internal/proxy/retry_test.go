@@ -0,0 +1,247 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/middleware"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name             string
+		method           string
+		policy           config.RetryPolicyConfig
+		failures         int32
+		failStatus       int
+		expectedStatus   int
+		expectedAttempts int32
+	}{
+		{
+			name:             "retries configured status codes",
+			method:           http.MethodGet,
+			policy:           config.RetryPolicyConfig{MaxAttempts: 3, StatusCodes: []int{503}},
+			failures:         2,
+			failStatus:       http.StatusServiceUnavailable,
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "returns last response when attempts exhausted",
+			method:           http.MethodGet,
+			policy:           config.RetryPolicyConfig{MaxAttempts: 2, StatusCodes: []int{503}},
+			failures:         5,
+			failStatus:       http.StatusServiceUnavailable,
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedAttempts: 2,
+		},
+		{
+			name:             "ignores status codes not configured",
+			method:           http.MethodGet,
+			policy:           config.RetryPolicyConfig{MaxAttempts: 3, StatusCodes: []int{503}},
+			failures:         1,
+			failStatus:       http.StatusInternalServerError,
+			expectedStatus:   http.StatusInternalServerError,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "retries circuit breaker failure statuses by default",
+			method:           http.MethodGet,
+			failures:         1,
+			failStatus:       http.StatusBadGateway,
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 2,
+		},
+		{
+			name:             "does not retry other 5xx by default",
+			method:           http.MethodGet,
+			failures:         1,
+			failStatus:       http.StatusInternalServerError,
+			expectedStatus:   http.StatusInternalServerError,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "does not retry non-idempotent methods by default",
+			method:           http.MethodPost,
+			policy:           config.RetryPolicyConfig{MaxAttempts: 3, StatusCodes: []int{503}},
+			failures:         1,
+			failStatus:       http.StatusServiceUnavailable,
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "retries explicitly allowed methods",
+			method:           http.MethodPost,
+			policy:           config.RetryPolicyConfig{MaxAttempts: 3, StatusCodes: []int{503}, Methods: []string{"POST"}},
+			failures:         1,
+			failStatus:       http.StatusServiceUnavailable,
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int32
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&attempts, 1) <= tt.failures {
+					w.WriteHeader(tt.failStatus)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer backend.Close()
+
+			cfg := DefaultConfig()
+			cfg.RetryDelay = time.Millisecond
+			p := New(cfg)
+
+			match := newTestMatch(backend.URL)
+			match.Route.Retry = tt.policy
+
+			req := httptest.NewRequest(tt.method, "/", nil)
+			rr := httptest.NewRecorder()
+			if err := p.Forward(rr, req, match); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if got := atomic.LoadInt32(&attempts); got != tt.expectedAttempts {
+				t.Errorf("expected %d attempts, got %d", tt.expectedAttempts, got)
+			}
+		})
+	}
+}
+
+func TestRetryAttemptHistory(t *testing.T) {
+	var logs bytes.Buffer
+	logger.Init(logger.InfoLevel, "json", &logs)
+
+	var attempts int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	cfg := DefaultConfig()
+	cfg.RetryDelay = time.Millisecond
+	p := New(cfg)
+	match := newTestMatch(backend.URL)
+	match.Route.Retry = config.RetryPolicyConfig{MaxAttempts: 3, StatusCodes: []int{503}}
+
+	handler := middleware.Logging()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := p.Forward(w, r, match); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var entry struct {
+		Fields struct {
+			Attempts []middleware.BackendAttempt `json:"attempts"`
+		} `json:"fields"`
+	}
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if strings.Contains(line, `"request completed"`) {
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatalf("invalid access log entry: %v", err)
+			}
+		}
+	}
+
+	got := entry.Fields.Attempts
+	if len(got) != 2 || got[0].Status != http.StatusServiceUnavailable || got[1].Status != http.StatusOK {
+		t.Fatalf("expected attempts 503 then 200, got %+v", got)
+	}
+	if got[0].Backend != strings.TrimPrefix(backend.URL, "http://") {
+		t.Errorf("expected backend %s, got %s", backend.URL, got[0].Backend)
+	}
+}
+
+func TestRetryPerTryTimeout(t *testing.T) {
+	var attempts int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	cfg := DefaultConfig()
+	cfg.RetryDelay = time.Millisecond
+	p := New(cfg)
+
+	match := newTestMatch(backend.URL)
+	match.Route.Retry = config.RetryPolicyConfig{MaxAttempts: 2, PerTryTimeout: 50 * time.Millisecond}
+
+	rr := httptest.NewRecorder()
+	if err := p.Forward(rr, httptest.NewRequest(http.MethodGet, "/", nil), match); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestRetryPolicyRequiresReplayableBody(t *testing.T) {
+	p := New(nil)
+	match := newTestMatch("http://backend.internal")
+	match.Route.Retry = config.RetryPolicyConfig{Methods: []string{"POST"}}
+	policy := p.retryPolicyFor(match.Route)
+
+	req, _ := http.NewRequest(http.MethodPost, "http://backend.internal", strings.NewReader("data"))
+	if !policy.canRetry(req) {
+		t.Error("expected request with GetBody to be retryable")
+	}
+
+	req.GetBody = nil
+	if policy.canRetry(req) {
+		t.Error("expected request without GetBody not to be retryable")
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	t.Run("minimum retries per second", func(t *testing.T) {
+		budget := newRetryBudget(0, 1)
+		for i := 0; i < retryBudgetWindow; i++ {
+			if !budget.withdraw() {
+				t.Fatalf("expected retry %d to be allowed", i)
+			}
+		}
+		if budget.withdraw() {
+			t.Error("expected retry to be rejected once the floor is used")
+		}
+	})
+
+	t.Run("ratio of requests", func(t *testing.T) {
+		budget := newRetryBudget(0.5, 0)
+		for i := 0; i < 4; i++ {
+			budget.recordRequest()
+		}
+		if !budget.withdraw() || !budget.withdraw() {
+			t.Fatal("expected two retries to be allowed")
+		}
+		if budget.withdraw() {
+			t.Error("expected third retry to be rejected")
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/maltehedderich/api-gateway-go/internal/jsonschema"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// ErrSchemaValidation is returned when a request body does not match the
+// route's request schema
+var ErrSchemaValidation = errors.New("request body does not match schema")
+
+// SchemaError lists the violations of a route's request schema
+type SchemaError struct {
+	Violations []jsonschema.ValidationError
+}
+
+func (e *SchemaError) Error() string {
+	if len(e.Violations) == 0 {
+		return ErrSchemaValidation.Error()
+	}
+	return fmt.Sprintf("%s: %s", ErrSchemaValidation, e.Violations[0])
+}
+
+func (e *SchemaError) Unwrap() error {
+	return ErrSchemaValidation
+}
+
+// validateRequestSchema checks a request body against the route's schema.
+// The body is buffered and replaced so it can still be forwarded.
+func validateRequestSchema(req *http.Request, match *router.Match) error {
+	schema := match.Route.RequestSchema
+	if schema == nil {
+		return nil
+	}
+
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+		switch req.Method {
+		case http.MethodGet, http.MethodHead, http.MethodDelete, http.MethodOptions:
+			return nil
+		}
+		return schemaRejected(match, jsonschema.ValidationError{Message: "request body is required"})
+	}
+	if !isTransformable(req.Header) {
+		return schemaRejected(match, jsonschema.ValidationError{Message: "request body must be uncompressed JSON"})
+	}
+
+	limit := match.Route.MaxRequestSchemaBodySize
+	if limit <= 0 {
+		limit = defaultMaxTransformBodySize
+	}
+	data, err := io.ReadAll(io.LimitReader(req.Body, limit+1))
+	_ = req.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read request body: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return ErrRequestBodyTooLarge
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRequestBody, err)
+	}
+	if dec.More() {
+		return fmt.Errorf("%w: unexpected data after JSON value", ErrInvalidRequestBody)
+	}
+	if violations := schema.Validate(doc); len(violations) > 0 {
+		return schemaRejected(match, violations...)
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	req.ContentLength = int64(len(data))
+	req.Header.Set("Content-Length", strconv.Itoa(len(data)))
+	return nil
+}
+
+// schemaRejected records a rejected request and returns its error
+func schemaRejected(match *router.Match, violations ...jsonschema.ValidationError) error {
+	metrics.RecordRequestSchemaRejection(match.Route.PathPattern)
+	return &SchemaError{Violations: violations}
+}
@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/maltehedderich/api-gateway-go/internal/jsonschema"
+)
+
+func TestRequestSchema(t *testing.T) {
+	var receivedBody string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		receivedBody = string(data)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer backend.Close()
+
+	schema, err := jsonschema.Compile(map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"name"},
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{"type": "string", "minLength": 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	p := New(nil)
+	match := newTestMatch(backend.URL)
+	match.Route.RequestSchema = schema
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		expectedErr error
+		pointer     string
+	}{
+		{"valid body forwarded", http.MethodPost, "application/json", `{"name":"widget"}`, nil, ""},
+		{"violation", http.MethodPost, "application/json", `{"name":""}`, ErrSchemaValidation, "/name"},
+		{"missing body", http.MethodPost, "application/json", "", ErrSchemaValidation, ""},
+		{"not JSON", http.MethodPost, "text/plain", "name=widget", ErrSchemaValidation, ""},
+		{"malformed JSON", http.MethodPost, "application/json", `{"name":`, ErrInvalidRequestBody, ""},
+		{"bodyless GET", http.MethodGet, "", "", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receivedBody = ""
+			req := httptest.NewRequest(tt.method, "/widgets", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			err := p.Forward(httptest.NewRecorder(), req, match)
+			if tt.expectedErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if receivedBody != tt.body {
+					t.Errorf("expected backend body %q, got %q", tt.body, receivedBody)
+				}
+				return
+			}
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+			}
+			var schemaErr *SchemaError
+			if errors.As(err, &schemaErr) && schemaErr.Violations[0].Pointer != tt.pointer {
+				t.Errorf("expected violation at %q, got %v", tt.pointer, schemaErr.Violations)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+)
+
+// StreamErrorTrailer is the trailer reporting a backend failure after the
+// response status was already sent
+const StreamErrorTrailer = "X-Gateway-Stream-Error"
+
+// backendBody records read errors so that backend failures can be told apart
+// from clients that went away
+type backendBody struct {
+	io.Reader
+	err error
+}
+
+func (b *backendBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+// declareStreamErrorTrailer announces the StreamErrorTrailer before the
+// status is written, if the client accepts trailers and the body length is
+// not fixed. It reports whether the trailer can be used.
+func declareStreamErrorTrailer(w http.ResponseWriter, r *http.Request) bool {
+	if !acceptsTrailers(r) || w.Header().Get("Content-Length") != "" {
+		return false
+	}
+	w.Header().Add("Trailer", StreamErrorTrailer)
+	return true
+}
+
+// announceTrailers declares the trailers of the backend response before the
+// status is written so they can follow the body. It returns the announced
+// trailer names.
+func announceTrailers(w http.ResponseWriter, resp *http.Response) map[string]bool {
+	if len(resp.Trailer) == 0 {
+		return nil
+	}
+	announced := make(map[string]bool, len(resp.Trailer))
+	for key := range resp.Trailer {
+		w.Header().Add("Trailer", key)
+		announced[key] = true
+	}
+	// Trailers need a chunked (or HTTP/2) body
+	w.Header().Del("Content-Length")
+	return announced
+}
+
+// copyTrailers copies the trailers of the backend response once its body was
+// read. Trailers the backend did not announce are sent with
+// http.TrailerPrefix, which HTTP/2 clients accept.
+func copyTrailers(w http.ResponseWriter, resp *http.Response, announced map[string]bool) {
+	for key, values := range resp.Trailer {
+		name := key
+		if !announced[key] {
+			name = http.TrailerPrefix + key
+		}
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+}
+
+// streamResponse copies the backend response body to the client. Once the
+// status line is out, a backend failure can no longer become an error
+// response; instead the failure is reported in the declared trailer, or the
+// connection or stream is reset so clients never mistake a truncated body
+// for a complete one.
+func (p *Proxy) streamResponse(w http.ResponseWriter, r *http.Request, resp *http.Response, backendService string, trailer bool) {
+	body := &backendBody{Reader: resp.Body}
+	written, err := io.Copy(w, body)
+	if err == nil {
+		return
+	}
+
+	correlationID := logger.GetCorrelationID(r.Context())
+	if body.err == nil {
+		// Writing to the client failed; there is nobody left to tell
+		p.logger.Warn("error streaming response", logger.Fields{
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
+	reason := "read_error"
+	if isTimeout(body.err) {
+		reason = "timeout"
+	}
+	metrics.RecordBackendStreamError(backendService, reason)
+
+	p.logger.Error("backend response stream interrupted", logger.Fields{
+		"correlation_id": correlationID,
+		"backend_url":    backendService,
+		"bytes_written":  written,
+		"reason":         reason,
+		"trailer":        trailer,
+		"error":          body.err.Error(),
+	})
+
+	if trailer {
+		w.Header().Set(StreamErrorTrailer, reason)
+		return
+	}
+	panic(http.ErrAbortHandler)
+}
+
+// acceptsTrailers reports whether the client announced trailer support
+func acceptsTrailers(r *http.Request) bool {
+	for _, value := range r.Header.Values("TE") {
+		for _, coding := range strings.Split(value, ",") {
+			name, _, _ := strings.Cut(coding, ";")
+			if strings.EqualFold(strings.TrimSpace(name), "trailers") {
+				return true
+			}
+		}
+	}
+	return false
+}
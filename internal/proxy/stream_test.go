@@ -0,0 +1,147 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStreamErrors(t *testing.T) {
+	// The backend sends part of a chunked body and then drops the connection
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}))
+	defer backend.Close()
+	backend.Config.ErrorLog = nil
+
+	p := New(nil)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := p.Forward(w, r, newTestMatch(backend.URL)); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}))
+	defer gateway.Close()
+
+	tests := []struct {
+		name          string
+		te            string
+		expectFailure bool
+		expectTrailer string
+	}{
+		{
+			name:          "connection reset without trailer support",
+			expectFailure: true,
+		},
+		{
+			name:          "trailer when accepted",
+			te:            "trailers",
+			expectTrailer: "read_error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, gateway.URL+"/stream", nil)
+			if tt.te != "" {
+				req.Header.Set("TE", tt.te)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				if !tt.expectFailure {
+					t.Fatalf("request failed: %v", err)
+				}
+				return
+			}
+			defer resp.Body.Close()
+
+			// A reset may also surface while reading the body, but a
+			// truncated body must never look complete
+			body, err := io.ReadAll(resp.Body)
+			if (err != nil) != tt.expectFailure {
+				t.Fatalf("expected failure %v, got %v (body %q)", tt.expectFailure, err, body)
+			}
+			if string(body) != "partial" {
+				t.Errorf("expected partial body, got %q", body)
+			}
+			if got := resp.Trailer.Get(StreamErrorTrailer); got != tt.expectTrailer {
+				t.Errorf("expected trailer %q, got %q", tt.expectTrailer, got)
+			}
+		})
+	}
+}
+
+func TestAcceptsTrailers(t *testing.T) {
+	tests := []struct {
+		te       string
+		expected bool
+	}{
+		{"", false},
+		{"trailers", true},
+		{"gzip;q=0.5, Trailers", true},
+		{"deflate", false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.te != "" {
+			req.Header.Set("TE", tt.te)
+		}
+		if got := acceptsTrailers(req); got != tt.expected {
+			t.Errorf("acceptsTrailers(%q) = %v, want %v", tt.te, got, tt.expected)
+		}
+	}
+}
+
+func TestTrailerPropagation(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Trailer.Get("X-Checksum") != "abc" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Te") != "trailers" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Header().Set("Content-Type", "application/grpc")
+		_, _ = w.Write(body)
+		w.Header().Set("Grpc-Status", "0")
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", "done")
+	}))
+	defer backend.Close()
+
+	p := New(nil)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := p.Forward(w, r, newTestMatch(backend.URL)); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}))
+	defer gateway.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, gateway.URL+"/rpc", io.NopCloser(strings.NewReader("payload")))
+	req.ContentLength = -1
+	req.Header.Set("TE", "trailers")
+	req.Trailer = http.Header{"X-Checksum": {"abc"}}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "payload" {
+		t.Fatalf("expected echoed payload, got %d %q", resp.StatusCode, body)
+	}
+	if got := resp.Trailer.Get("Grpc-Status"); got != "0" {
+		t.Errorf("expected Grpc-Status trailer 0, got %q", got)
+	}
+	if got := resp.Trailer.Get("Grpc-Message"); got != "done" {
+		t.Errorf("expected undeclared Grpc-Message trailer, got %q", got)
+	}
+}
@@ -0,0 +1,207 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+)
+
+// tlsFiles holds upstream TLS material loaded from disk.
+// Files are re-read whenever their modification time changes, so rotated
+// certificates and CA bundles are picked up without restarting the gateway.
+type tlsFiles struct {
+	cfg    config.UpstreamTLSConfig
+	logger *logger.ComponentLogger
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	certMod  time.Time
+	roots    *x509.CertPool
+	rootsMod time.Time
+}
+
+// buildUpstreamTLSConfig creates the client TLS configuration for a backend
+func buildUpstreamTLSConfig(cfg config.UpstreamTLSConfig, log *logger.ComponentLogger) (*tls.Config, error) {
+	files := &tlsFiles{
+		cfg:    cfg,
+		logger: log,
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: cfg.ServerName,
+	}
+
+	if cfg.CertFile != "" {
+		if _, err := files.clientCertificate(nil); err != nil {
+			return nil, err
+		}
+		tlsConfig.GetClientCertificate = files.clientCertificate
+	}
+
+	if cfg.InsecureSkipVerify {
+		log.Warn("UPSTREAM TLS VERIFICATION DISABLED - backend certificates will not be checked; never use this in production", logger.Fields{
+			"server_name": cfg.ServerName,
+			"ca_file":     cfg.CAFile,
+		})
+		tlsConfig.InsecureSkipVerify = true
+		return tlsConfig, nil
+	}
+
+	if cfg.CAFile != "" {
+		if _, err := files.rootCAs(); err != nil {
+			return nil, err
+		}
+		// Verification is done in VerifyConnection against the current
+		// CA bundle, because RootCAs cannot be swapped after the config is in use.
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyConnection = files.verifyConnection
+	}
+
+	return tlsConfig, nil
+}
+
+// clientCertificate returns the client certificate, reloading it if changed
+func (f *tlsFiles) clientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	modTime, err := latestModTime(f.cfg.CertFile, f.cfg.KeyFile)
+	if err != nil {
+		if f.cert != nil {
+			// Keep serving the last good certificate
+			return f.cert, nil
+		}
+		return nil, err
+	}
+
+	if f.cert != nil && modTime.Equal(f.certMod) {
+		return f.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(f.cfg.CertFile, f.cfg.KeyFile)
+	if err != nil {
+		if f.cert != nil {
+			f.logger.Error("failed to reload upstream client certificate, keeping previous", logger.Fields{
+				"cert_file": f.cfg.CertFile,
+				"error":     err.Error(),
+			})
+			return f.cert, nil
+		}
+		return nil, fmt.Errorf("failed to load upstream client certificate: %w", err)
+	}
+
+	if f.cert != nil {
+		f.logger.Info("upstream client certificate reloaded", logger.Fields{
+			"cert_file": f.cfg.CertFile,
+		})
+	}
+
+	f.cert = &cert
+	f.certMod = modTime
+	return f.cert, nil
+}
+
+// rootCAs returns the CA pool, reloading it if the bundle changed
+func (f *tlsFiles) rootCAs() (*x509.CertPool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	modTime, err := latestModTime(f.cfg.CAFile)
+	if err != nil {
+		if f.roots != nil {
+			return f.roots, nil
+		}
+		return nil, err
+	}
+
+	if f.roots != nil && modTime.Equal(f.rootsMod) {
+		return f.roots, nil
+	}
+
+	pool, err := loadCertPool(f.cfg.CAFile)
+	if err != nil {
+		if f.roots != nil {
+			f.logger.Error("failed to reload upstream CA bundle, keeping previous", logger.Fields{
+				"ca_file": f.cfg.CAFile,
+				"error":   err.Error(),
+			})
+			return f.roots, nil
+		}
+		return nil, err
+	}
+
+	if f.roots != nil {
+		f.logger.Info("upstream CA bundle reloaded", logger.Fields{
+			"ca_file": f.cfg.CAFile,
+		})
+	}
+
+	f.roots = pool
+	f.rootsMod = modTime
+	return f.roots, nil
+}
+
+// verifyConnection verifies the backend certificate chain against the CA bundle
+func (f *tlsFiles) verifyConnection(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("backend presented no certificate")
+	}
+
+	roots, err := f.rootCAs()
+	if err != nil {
+		return err
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		DNSName:       cs.ServerName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	if _, err := cs.PeerCertificates[0].Verify(opts); err != nil {
+		return fmt.Errorf("backend certificate verification failed: %w", err)
+	}
+
+	return nil
+}
+
+// loadCertPool loads a PEM encoded CA bundle
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upstream CA file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in upstream CA file: %s", path)
+	}
+
+	return pool, nil
+}
+
+// latestModTime returns the most recent modification time of the given files
+func latestModTime(paths ...string) (time.Time, error) {
+	var latest time.Time
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
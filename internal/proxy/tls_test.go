@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+// writeCAFile writes the test server certificate as a PEM CA bundle
+func writeCAFile(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("failed to write CA file: %v", err)
+	}
+	return path
+}
+
+func TestUpstreamTLS(t *testing.T) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	caFile := writeCAFile(t, backend)
+
+	tests := []struct {
+		name        string
+		tlsConfig   config.UpstreamTLSConfig
+		expectError bool
+	}{
+		{
+			name:        "custom CA",
+			tlsConfig:   config.UpstreamTLSConfig{CAFile: caFile},
+			expectError: false,
+		},
+		{
+			name:        "custom CA with matching SNI override",
+			tlsConfig:   config.UpstreamTLSConfig{CAFile: caFile, ServerName: "example.com"},
+			expectError: false,
+		},
+		{
+			name:        "custom CA with mismatched SNI override",
+			tlsConfig:   config.UpstreamTLSConfig{CAFile: caFile, ServerName: "other.internal"},
+			expectError: true,
+		},
+		{
+			name:        "insecure skip verify",
+			tlsConfig:   config.UpstreamTLSConfig{InsecureSkipVerify: true},
+			expectError: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.MaxRetries = 0
+			p := New(cfg)
+
+			match := newTestMatch(backend.URL)
+			match.Route.UpstreamTLS = tt.tlsConfig
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			err := p.Forward(httptest.NewRecorder(), req, match)
+
+			if tt.expectError && err == nil {
+				t.Error("expected error, got nil")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestUpstreamTLSInvalidCA(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, []byte("not a certificate"), 0600); err != nil {
+		t.Fatalf("failed to write CA file: %v", err)
+	}
+
+	_, err := buildUpstreamTLSConfig(config.UpstreamTLSConfig{CAFile: caFile}, New(nil).logger)
+	if err == nil {
+		t.Error("expected error for invalid CA bundle")
+	}
+}
+
+func TestUpstreamTLSKeepsPreviousCAOnBadReload(t *testing.T) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	caFile := writeCAFile(t, backend)
+	files := &tlsFiles{
+		cfg:    config.UpstreamTLSConfig{CAFile: caFile},
+		logger: New(nil).logger,
+	}
+
+	first, err := files.rootCAs()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Corrupt the bundle; the previously loaded pool must remain in use
+	if err := os.WriteFile(caFile, []byte("garbage"), 0600); err != nil {
+		t.Fatalf("failed to overwrite CA file: %v", err)
+	}
+	files.rootsMod = files.rootsMod.Add(-1)
+
+	second, err := files.rootCAs()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if second != first {
+		t.Error("expected previous CA pool to be kept after failed reload")
+	}
+}
@@ -0,0 +1,230 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// defaultMaxTransformBodySize caps bodies buffered for transformation when a
+// route does not set its own limit
+const defaultMaxTransformBodySize = 10 << 20 // 10 MB
+
+// ErrInvalidRequestBody is returned when a request body cannot be transformed
+var ErrInvalidRequestBody = errors.New("invalid JSON request body")
+
+// transformRequestBody rewrites a JSON request body using the route rules
+func transformRequestBody(req *http.Request, match *router.Match) error {
+	cfg := match.Route.RequestTransform
+	if !cfg.Enabled() || !isTransformable(req.Header) || req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+
+	limit := transformLimit(cfg)
+	data, err := io.ReadAll(io.LimitReader(req.Body, limit+1))
+	_ = req.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read request body: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return ErrRequestBodyTooLarge
+	}
+
+	out, err := transformJSON(data, cfg, req, match)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRequestBody, err)
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(out))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(out)), nil
+	}
+	req.ContentLength = int64(len(out))
+	req.Header.Set("Content-Length", strconv.Itoa(len(out)))
+
+	return nil
+}
+
+// transformResponseBody rewrites a JSON backend response using the route rules.
+// Responses that are too large or not valid JSON are passed through unchanged.
+func (p *Proxy) transformResponseBody(resp *http.Response, r *http.Request, match *router.Match) {
+	cfg := match.Route.ResponseTransform
+	if !cfg.Enabled() || !isTransformable(resp.Header) || r.Method == http.MethodHead {
+		return
+	}
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return
+	}
+
+	original := resp.Body
+	limit := transformLimit(cfg)
+	data, err := io.ReadAll(io.LimitReader(original, limit+1))
+
+	if err == nil && int64(len(data)) <= limit {
+		out, transformErr := transformJSON(data, cfg, r, match)
+		if transformErr == nil {
+			resp.Body = readCloser{Reader: bytes.NewReader(out), Closer: original}
+			resp.ContentLength = int64(len(out))
+			resp.Header.Set("Content-Length", strconv.Itoa(len(out)))
+			return
+		}
+		err = transformErr
+	}
+
+	reason := "body exceeds transform limit"
+	if err != nil {
+		reason = err.Error()
+	}
+	p.logger.Warn("response transform skipped", logger.Fields{
+		"correlation_id": logger.GetCorrelationID(r.Context()),
+		"backend_url":    match.Route.BackendURL,
+		"reason":         reason,
+	})
+
+	// Replay what was read followed by the rest of the body
+	resp.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(data), original), Closer: original}
+}
+
+// readCloser combines a reader with the closer of the original body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// isTransformable reports whether a message carries an uncompressed JSON body
+func isTransformable(h http.Header) bool {
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// transformLimit returns the maximum body size buffered for a transform
+func transformLimit(cfg config.BodyTransformConfig) int64 {
+	if cfg.MaxBodySize > 0 {
+		return cfg.MaxBodySize
+	}
+	return defaultMaxTransformBodySize
+}
+
+// transformJSON decodes data, applies the transform and re-encodes it
+func transformJSON(data []byte, cfg config.BodyTransformConfig, r *http.Request, match *router.Match) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+
+	if cfg.Unwrap != "" {
+		if value, ok := getPath(doc, splitPath(cfg.Unwrap)); ok {
+			doc = value
+		}
+	}
+	for _, path := range cfg.Remove {
+		deletePath(doc, splitPath(path))
+	}
+	for from, to := range cfg.Rename {
+		if value, ok := deletePath(doc, splitPath(from)); ok {
+			doc = setPath(doc, splitPath(to), value)
+		}
+	}
+	for path, tmpl := range cfg.Set {
+		doc = setPath(doc, splitPath(path), expandHeaderTemplate(tmpl, r, match))
+	}
+	if cfg.Wrap != "" {
+		doc = setPath(map[string]interface{}{}, splitPath(cfg.Wrap), doc)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// splitPath splits a dotted path into its segments
+func splitPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "$."), ".")
+}
+
+// getPath returns the value at path within nested objects
+func getPath(doc interface{}, path []string) (interface{}, bool) {
+	current := doc
+	for _, segment := range path {
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = obj[segment]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// deletePath removes the value at path and returns it
+func deletePath(doc interface{}, path []string) (interface{}, bool) {
+	parent, ok := getPath(doc, path[:len(path)-1])
+	if !ok {
+		return nil, false
+	}
+	obj, ok := parent.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	value, ok := obj[path[len(path)-1]]
+	if ok {
+		delete(obj, path[len(path)-1])
+	}
+	return value, ok
+}
+
+// setPath sets the value at path, creating intermediate objects as needed.
+// Existing non-object values along the path are never overwritten.
+func setPath(doc interface{}, path []string, value interface{}) interface{} {
+	obj, ok := doc.(map[string]interface{})
+	if !ok {
+		return doc
+	}
+
+	current := obj
+	for _, segment := range path[:len(path)-1] {
+		next, exists := current[segment]
+		if !exists {
+			child := map[string]interface{}{}
+			current[segment] = child
+			current = child
+			continue
+		}
+		child, ok := next.(map[string]interface{})
+		if !ok {
+			return doc
+		}
+		current = child
+	}
+
+	current[path[len(path)-1]] = value
+	return doc
+}
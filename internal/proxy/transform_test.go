@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+func TestTransformJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.BodyTransformConfig
+		input    string
+		expected string
+	}{
+		{
+			name:     "remove internal fields",
+			cfg:      config.BodyTransformConfig{Remove: []string{"internal_id", "$.user.password_hash", "missing.field"}},
+			input:    `{"id":1,"internal_id":"x","user":{"name":"a","password_hash":"h"}}`,
+			expected: `{"id":1,"user":{"name":"a"}}`,
+		},
+		{
+			name:     "rename nested field",
+			cfg:      config.BodyTransformConfig{Rename: map[string]string{"user.name": "user_name"}},
+			input:    `{"user":{"name":"a"}}`,
+			expected: `{"user":{},"user_name":"a"}`,
+		},
+		{
+			name:     "wrap in envelope",
+			cfg:      config.BodyTransformConfig{Wrap: "data"},
+			input:    `[1,2,3]`,
+			expected: `{"data":[1,2,3]}`,
+		},
+		{
+			name:     "unwrap envelope",
+			cfg:      config.BodyTransformConfig{Unwrap: "data.items"},
+			input:    `{"data":{"items":[{"id":12345678901234567890}]}}`,
+			expected: `[{"id":12345678901234567890}]`,
+		},
+		{
+			name:     "set templated field",
+			cfg:      config.BodyTransformConfig{Set: map[string]string{"meta.tenant": "${param.tenant}"}},
+			input:    `{"a":"<b>"}`,
+			expected: `{"a":"<b>","meta":{"tenant":"acme"}}`,
+		},
+		{
+			name:     "set never overwrites scalar parent",
+			cfg:      config.BodyTransformConfig{Set: map[string]string{"a.b": "x"}},
+			input:    `{"a":1}`,
+			expected: `{"a":1}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match := newTestMatch("http://backend.internal")
+			match.Params = map[string]string{"tenant": "acme"}
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+
+			out, err := transformJSON([]byte(tt.input), tt.cfg, req, match)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(out) != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, out)
+			}
+		})
+	}
+}
+
+func TestBodyTransformForward(t *testing.T) {
+	var receivedBody string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		receivedBody = string(data)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":7,"secret":"s"}`))
+	}))
+	defer backend.Close()
+
+	p := New(nil)
+	match := newTestMatch(backend.URL)
+	match.Route.RequestTransform = config.BodyTransformConfig{Rename: map[string]string{"userName": "user_name"}}
+	match.Route.ResponseTransform = config.BodyTransformConfig{Remove: []string{"secret"}, Wrap: "data"}
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"userName":"a"}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	rr := httptest.NewRecorder()
+
+	if err := p.Forward(rr, req, match); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if receivedBody != `{"user_name":"a"}` {
+		t.Errorf("unexpected backend body: %s", receivedBody)
+	}
+	if got := rr.Body.String(); got != `{"data":{"id":7}}` {
+		t.Errorf("unexpected response body: %s", got)
+	}
+	if got := rr.Header().Get("Content-Length"); got != "17" {
+		t.Errorf("expected Content-Length 17, got %q", got)
+	}
+}
+
+func TestBodyTransformRejectsInvalidJSON(t *testing.T) {
+	p := New(nil)
+	match := newTestMatch("http://backend.internal")
+	match.Route.RequestTransform = config.BodyTransformConfig{Remove: []string{"a"}}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"a":`))
+	req.Header.Set("Content-Type", "application/json")
+
+	err := p.Forward(httptest.NewRecorder(), req, match)
+	if !errors.Is(err, ErrInvalidRequestBody) {
+		t.Errorf("expected ErrInvalidRequestBody, got %v", err)
+	}
+}
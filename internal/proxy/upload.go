@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// defaultUploadTimeout bounds upload requests when a route does not set its own timeout
+const defaultUploadTimeout = time.Hour
+
+// uploadTimeout returns the deadline applied to an upload route
+func uploadTimeout(route *router.Route) time.Duration {
+	if route.UploadTimeout > 0 {
+		return route.UploadTimeout
+	}
+	return defaultUploadTimeout
+}
+
+// extendDeadlines pushes the connection read and write deadlines out so that
+// the server-wide timeouts do not cut off long running uploads
+func (p *Proxy) extendDeadlines(w http.ResponseWriter, timeout time.Duration) {
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(timeout)
+
+	if err := rc.SetReadDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		p.logger.Warn("failed to extend read deadline for upload", logger.Fields{
+			"error": err.Error(),
+		})
+	}
+	if err := rc.SetWriteDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		p.logger.Warn("failed to extend write deadline for upload", logger.Fields{
+			"error": err.Error(),
+		})
+	}
+}
+
+// uploadBody streams a request body to the backend while reporting progress
+type uploadBody struct {
+	body    io.ReadCloser
+	backend string
+	read    int64
+}
+
+// newUploadBody wraps body and marks the upload as in progress
+func newUploadBody(body io.ReadCloser, backend string) *uploadBody {
+	metrics.IncUploadsInProgress(backend)
+	return &uploadBody{
+		body:    body,
+		backend: backend,
+	}
+}
+
+// Read implements io.Reader
+func (b *uploadBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 {
+		b.read += int64(n)
+		metrics.RecordUploadBytes(b.backend, n)
+	}
+	return n, err
+}
+
+// Close implements io.Closer
+func (b *uploadBody) Close() error {
+	return b.body.Close()
+}
+
+// finish marks the upload as completed
+func (b *uploadBody) finish() {
+	metrics.DecUploadsInProgress(b.backend)
+}
@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUploadModeStreamsBodyUntouched(t *testing.T) {
+	payload := gzipBytes(t, bytes.Repeat([]byte("upload"), 1<<20))
+
+	var receivedBody []byte
+	var receivedLength int64
+	var receivedEncoding string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedBody, _ = io.ReadAll(r.Body)
+		receivedLength = r.ContentLength
+		receivedEncoding = r.Header.Get("Content-Encoding")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer backend.Close()
+
+	p := New(nil)
+	match := newTestMatch(backend.URL)
+	match.Route.UploadMode = true
+	match.Route.DecompressRequest = true
+
+	req := httptest.NewRequest(http.MethodPut, "/files/archive.gz", bytes.NewReader(payload))
+	req.Header.Set("Content-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+
+	if err := p.Forward(rr, req, match); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rr.Code != http.StatusCreated {
+		t.Errorf("expected status %d, got %d", http.StatusCreated, rr.Code)
+	}
+	if !bytes.Equal(receivedBody, payload) {
+		t.Errorf("backend received %d bytes, expected %d", len(receivedBody), len(payload))
+	}
+	if receivedLength != int64(len(payload)) {
+		t.Errorf("expected Content-Length %d, got %d", len(payload), receivedLength)
+	}
+	if receivedEncoding != "gzip" {
+		t.Errorf("expected Content-Encoding to be preserved, got %q", receivedEncoding)
+	}
+}
+
+func TestUploadModeDisablesRetries(t *testing.T) {
+	var attempts int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		// Drop the connection without a response
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			_ = conn.Close()
+		}
+	}))
+	defer backend.Close()
+
+	cfg := DefaultConfig()
+	cfg.RetryDelay = time.Millisecond
+	p := New(cfg)
+
+	match := newTestMatch(backend.URL)
+	match.Route.UploadMode = true
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", bytes.NewReader([]byte("data")))
+	if err := p.Forward(httptest.NewRecorder(), req, match); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	if got := atomic.LoadInt32(&attempts); got != 1 {
+		t.Errorf("expected 1 attempt, got %d", got)
+	}
+}
+
+func TestUploadModeIgnoresClientTimeout(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	cfg := DefaultConfig()
+	cfg.DefaultTimeout = 20 * time.Millisecond
+	cfg.MaxRetries = 0
+	p := New(cfg)
+
+	match := newTestMatch(backend.URL)
+	match.Route.Timeout = 20 * time.Millisecond
+	match.Route.UploadMode = true
+	match.Route.UploadTimeout = 5 * time.Second
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", bytes.NewReader([]byte("data")))
+	if err := p.Forward(httptest.NewRecorder(), req, match); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
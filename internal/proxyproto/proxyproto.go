@@ -0,0 +1,293 @@
+// Package proxyproto implements the HAProxy PROXY protocol (versions 1 and 2),
+// which L4 load balancers use to pass the original client address to the
+// servers behind them.
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// v2Signature starts every version 2 header
+var v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// v1MaxLength is the maximum length of a version 1 header including CRLF
+const v1MaxLength = 107
+
+// ErrInvalidHeader is returned for malformed PROXY protocol headers
+var ErrInvalidHeader = errors.New("invalid PROXY protocol header")
+
+// Header is a decoded PROXY protocol header. Source and Destination are nil
+// for connections the sender did not proxy (UNKNOWN or LOCAL).
+type Header struct {
+	Version     int
+	Source      *net.TCPAddr
+	Destination *net.TCPAddr
+}
+
+// ReadHeader reads a PROXY protocol header from r if one is present. It
+// returns nil without consuming any data when the stream does not start with
+// a header.
+func ReadHeader(r *bufio.Reader) (*Header, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch first[0] {
+	case 'P':
+		prefix, err := r.Peek(6)
+		if err != nil || string(prefix) != "PROXY " {
+			return nil, nil
+		}
+		return readV1(r)
+	case '\r':
+		prefix, err := r.Peek(len(v2Signature))
+		if err != nil || !bytes.Equal(prefix, v2Signature) {
+			return nil, nil
+		}
+		return readV2(r)
+	}
+	return nil, nil
+}
+
+// readV1 parses a text header such as "PROXY TCP4 1.2.3.4 5.6.7.8 4000 443\r\n"
+func readV1(r *bufio.Reader) (*Header, error) {
+	var line []byte
+	for len(line) < v1MaxLength {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("%w: missing CRLF", ErrInvalidHeader)
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	header := &Header{Version: 1}
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return header, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidHeader, line)
+	}
+
+	src, err := parseV1Addr(fields[2], fields[4])
+	if err != nil {
+		return nil, err
+	}
+	dst, err := parseV1Addr(fields[3], fields[5])
+	if err != nil {
+		return nil, err
+	}
+	header.Source, header.Destination = src, dst
+	return header, nil
+}
+
+func parseV1Addr(ip, port string) (*net.TCPAddr, error) {
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil {
+		return nil, fmt.Errorf("%w: invalid address %q", ErrInvalidHeader, ip)
+	}
+	parsedPort, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid port %q", ErrInvalidHeader, port)
+	}
+	return &net.TCPAddr{IP: parsedIP, Port: int(parsedPort)}, nil
+}
+
+// readV2 parses a binary header
+func readV2(r *bufio.Reader) (*Header, error) {
+	fixed := make([]byte, 16)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+	}
+	if fixed[12]>>4 != 2 {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidHeader, fixed[12]>>4)
+	}
+	command := fixed[12] & 0x0f
+	family := fixed[13]
+	payload := make([]byte, binary.BigEndian.Uint16(fixed[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+	}
+
+	header := &Header{Version: 2}
+	if command == 0 { // LOCAL, e.g. load balancer health checks
+		return header, nil
+	}
+	if command != 1 {
+		return nil, fmt.Errorf("%w: unsupported command %d", ErrInvalidHeader, command)
+	}
+
+	var ipLen int
+	switch family {
+	case 0x11: // TCP over IPv4
+		ipLen = net.IPv4len
+	case 0x21: // TCP over IPv6
+		ipLen = net.IPv6len
+	default: // UDP and unix sockets carry no usable TCP address
+		return header, nil
+	}
+	if len(payload) < 2*ipLen+4 {
+		return nil, fmt.Errorf("%w: address block too short", ErrInvalidHeader)
+	}
+
+	header.Source = &net.TCPAddr{
+		IP:   net.IP(append([]byte(nil), payload[:ipLen]...)),
+		Port: int(binary.BigEndian.Uint16(payload[2*ipLen:])),
+	}
+	header.Destination = &net.TCPAddr{
+		IP:   net.IP(append([]byte(nil), payload[ipLen:2*ipLen]...)),
+		Port: int(binary.BigEndian.Uint16(payload[2*ipLen+2:])),
+	}
+	return header, nil
+}
+
+// WriteHeader writes a PROXY protocol header for a connection from src to
+// dst. When either address is unknown the header tells the receiver to use
+// the connection's own addresses (UNKNOWN for v1, LOCAL for v2).
+func WriteHeader(w io.Writer, version int, src, dst *net.TCPAddr) error {
+	switch version {
+	case 1:
+		_, err := io.WriteString(w, formatV1(src, dst))
+		return err
+	case 2:
+		_, err := w.Write(formatV2(src, dst))
+		return err
+	}
+	return fmt.Errorf("unsupported PROXY protocol version: %d", version)
+}
+
+func formatV1(src, dst *net.TCPAddr) string {
+	if src == nil || dst == nil {
+		return "PROXY UNKNOWN\r\n"
+	}
+	family := "TCP4"
+	if src.IP.To4() == nil || dst.IP.To4() == nil {
+		family = "TCP6"
+	}
+	return fmt.Sprintf("PROXY %s %s %s %d %d\r\n", family, src.IP, dst.IP, src.Port, dst.Port)
+}
+
+func formatV2(src, dst *net.TCPAddr) []byte {
+	buf := append([]byte(nil), v2Signature...)
+	if src == nil || dst == nil {
+		return append(buf, 0x20, 0x00, 0x00, 0x00) // LOCAL
+	}
+
+	srcIP, dstIP := src.IP.To4(), dst.IP.To4()
+	family := byte(0x11)
+	if srcIP == nil || dstIP == nil {
+		srcIP, dstIP = src.IP.To16(), dst.IP.To16()
+		family = 0x21
+	}
+
+	buf = append(buf, 0x21, family)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(2*len(srcIP)+4))
+	buf = append(buf, srcIP...)
+	buf = append(buf, dstIP...)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(src.Port))
+	return binary.BigEndian.AppendUint16(buf, uint16(dst.Port))
+}
+
+// Listener accepts connections that may start with a PROXY protocol header.
+// Headers are only honored from allowed sources; other connections are
+// passed through unchanged, so a forged header makes the request invalid
+// instead of spoofing the client address.
+type Listener struct {
+	net.Listener
+
+	// Allowed lists the networks of load balancers allowed to send headers.
+	// An empty list allows every source.
+	Allowed []*net.IPNet
+
+	// HeaderTimeout bounds the time to wait for the header
+	HeaderTimeout time.Duration
+}
+
+// Accept implements net.Listener. The header is read lazily by the returned
+// connection so a slow client cannot block the accept loop.
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.allowed(conn.RemoteAddr()) {
+		return conn, nil
+	}
+	return &Conn{Conn: conn, reader: bufio.NewReader(conn), timeout: l.HeaderTimeout}, nil
+}
+
+func (l *Listener) allowed(addr net.Addr) bool {
+	if len(l.Allowed) == 0 {
+		return true
+	}
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, network := range l.Allowed {
+		if network.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn is a connection whose remote address is taken from its PROXY
+// protocol header, if it sent one
+type Conn struct {
+	net.Conn
+
+	reader  *bufio.Reader
+	timeout time.Duration
+
+	once   sync.Once
+	header *Header
+	err    error
+}
+
+// init reads the header on first use
+func (c *Conn) init() {
+	c.once.Do(func() {
+		if c.timeout > 0 {
+			_ = c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+			defer func() { _ = c.Conn.SetReadDeadline(time.Time{}) }()
+		}
+		c.header, c.err = ReadHeader(c.reader)
+	})
+}
+
+// Read implements net.Conn
+func (c *Conn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the client address from the header, or the address of
+// the peer if the connection was not proxied
+func (c *Conn) RemoteAddr() net.Addr {
+	c.init()
+	if c.header != nil && c.header.Source != nil {
+		return c.header.Source
+	}
+	return c.Conn.RemoteAddr()
+}
@@ -0,0 +1,143 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestHeaderRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		src     *net.TCPAddr
+		dst     *net.TCPAddr
+	}{
+		{
+			name:    "v1 IPv4",
+			version: 1,
+			src:     &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 51234},
+			dst:     &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 443},
+		},
+		{
+			name:    "v1 IPv6",
+			version: 1,
+			src:     &net.TCPAddr{IP: net.ParseIP("2001:db8::7"), Port: 51234},
+			dst:     &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 443},
+		},
+		{
+			name:    "v2 IPv4",
+			version: 2,
+			src:     &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 51234},
+			dst:     &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 443},
+		},
+		{
+			name:    "v2 IPv6",
+			version: 2,
+			src:     &net.TCPAddr{IP: net.ParseIP("2001:db8::7"), Port: 51234},
+			dst:     &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 443},
+		},
+		{name: "v1 unknown", version: 1},
+		{name: "v2 local", version: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteHeader(&buf, tt.version, tt.src, tt.dst); err != nil {
+				t.Fatalf("WriteHeader failed: %v", err)
+			}
+			buf.WriteString("GET / HTTP/1.1\r\n")
+
+			r := bufio.NewReader(&buf)
+			header, err := ReadHeader(r)
+			if err != nil {
+				t.Fatalf("ReadHeader failed: %v", err)
+			}
+			if header == nil || header.Version != tt.version {
+				t.Fatalf("expected version %d header, got %+v", tt.version, header)
+			}
+			if tt.src == nil {
+				if header.Source != nil {
+					t.Errorf("expected no source address, got %v", header.Source)
+				}
+			} else if header.Source.String() != tt.src.String() || header.Destination.String() != tt.dst.String() {
+				t.Errorf("expected %v -> %v, got %v -> %v", tt.src, tt.dst, header.Source, header.Destination)
+			}
+
+			rest, _ := io.ReadAll(r)
+			if string(rest) != "GET / HTTP/1.1\r\n" {
+				t.Errorf("expected request data to follow the header, got %q", rest)
+			}
+		})
+	}
+}
+
+func TestReadHeaderWithoutHeader(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("PUT / HTTP/1.1\r\n"))
+	header, err := ReadHeader(r)
+	if err != nil || header != nil {
+		t.Fatalf("expected no header, got %+v, %v", header, err)
+	}
+	rest, _ := io.ReadAll(r)
+	if string(rest) != "PUT / HTTP/1.1\r\n" {
+		t.Errorf("expected data to be left unread, got %q", rest)
+	}
+}
+
+func TestReadHeaderInvalid(t *testing.T) {
+	for _, input := range []string{
+		"PROXY TCP4 not-an-ip 10.0.0.1 1 2\r\n",
+		"PROXY TCP4 1.2.3.4 10.0.0.1 1\r\n",
+		"PROXY TCP4 1.2.3.4 10.0.0.1 1 2\n",
+	} {
+		if _, err := ReadHeader(bufio.NewReader(strings.NewReader(input))); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
+
+func TestListener(t *testing.T) {
+	tests := []struct {
+		name         string
+		allowed      string
+		expectedAddr string
+	}{
+		{name: "allowed source", allowed: "127.0.0.0/8", expectedAddr: "203.0.113.7:51234"},
+		{name: "untrusted source", allowed: "192.0.2.0/24", expectedAddr: "127.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("failed to listen: %v", err)
+			}
+			_, network, _ := net.ParseCIDR(tt.allowed)
+			listener := &Listener{Listener: inner, Allowed: []*net.IPNet{network}}
+			defer listener.Close()
+
+			go func() {
+				conn, err := net.Dial("tcp", inner.Addr().String())
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				_, _ = io.WriteString(conn, "PROXY TCP4 203.0.113.7 10.0.0.1 51234 443\r\nping")
+			}()
+
+			conn, err := listener.Accept()
+			if err != nil {
+				t.Fatalf("accept failed: %v", err)
+			}
+			defer conn.Close()
+
+			if addr := conn.RemoteAddr().String(); !strings.HasPrefix(addr, tt.expectedAddr) {
+				t.Errorf("expected remote address %s, got %s", tt.expectedAddr, addr)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+// abuseTracker counts the 4xx responses of each session and flags sessions
+// exceeding the configured threshold, whose "session" limits are then
+// tightened for the penalty period
+type abuseTracker struct {
+	mu       sync.Mutex
+	config   config.SessionAbuseConfig
+	sessions map[string]*sessionErrors
+	now      func() time.Time
+}
+
+// sessionErrors is the error count of a session in its current window
+type sessionErrors struct {
+	count          int
+	windowStart    time.Time
+	tightenedUntil time.Time
+}
+
+// newAbuseTracker creates a tracker for the given settings
+func newAbuseTracker(cfg config.SessionAbuseConfig) *abuseTracker {
+	return &abuseTracker{
+		config:   cfg,
+		sessions: make(map[string]*sessionErrors),
+		now:      time.Now,
+	}
+}
+
+// record counts a response to a session. It returns true if the response
+// made the session exceed the threshold.
+func (t *abuseTracker) record(sessionID string, status int) bool {
+	if status < 400 || status >= 500 {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	entry, ok := t.sessions[sessionID]
+	if !ok || now.Sub(entry.windowStart) >= t.config.Window {
+		if !ok {
+			entry = &sessionErrors{}
+			t.sessions[sessionID] = entry
+		}
+		entry.count = 0
+		entry.windowStart = now
+	}
+
+	entry.count++
+	if entry.count < t.config.ErrorThreshold {
+		return false
+	}
+	entry.count = 0
+	entry.windowStart = now
+	entry.tightenedUntil = now.Add(t.config.Penalty)
+	return true
+}
+
+// tightened reports whether the limits of a session are tightened
+func (t *abuseTracker) tightened(sessionID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.sessions[sessionID]
+	return ok && t.now().Before(entry.tightenedUntil)
+}
+
+// tighten returns limit cut to the configured fraction, keeping at least
+// one request
+func (t *abuseTracker) tighten(limit int) int {
+	return max(1, int(float64(limit)*t.config.LimitFactor))
+}
+
+// cleanup removes sessions whose window and penalty have both ended
+func (t *abuseTracker) cleanup() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	for sessionID, entry := range t.sessions {
+		if now.Sub(entry.windowStart) >= t.config.Window && !now.Before(entry.tightenedUntil) {
+			delete(t.sessions, sessionID)
+		}
+	}
+}
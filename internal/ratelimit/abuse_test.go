@@ -0,0 +1,142 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/auth"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+)
+
+func TestAbuseTracker(t *testing.T) {
+	now := time.Now()
+	tracker := newAbuseTracker(config.SessionAbuseConfig{
+		Enabled:        true,
+		ErrorThreshold: 3,
+		Window:         time.Minute,
+		Penalty:        10 * time.Minute,
+		LimitFactor:    0.1,
+	})
+	tracker.now = func() time.Time { return now }
+
+	// Successful and server error responses do not count
+	for _, status := range []int{200, 204, 302, 500, 503} {
+		tracker.record("s1", status)
+	}
+	if tracker.tightened("s1") {
+		t.Fatal("expected non-4xx responses not to tighten the session")
+	}
+
+	// Errors spread over several windows do not add up
+	tracker.record("s1", 404)
+	tracker.record("s1", 403)
+	now = now.Add(2 * time.Minute)
+	if tracker.record("s1", 401) || tracker.tightened("s1") {
+		t.Fatal("expected errors of an expired window to be forgotten")
+	}
+
+	tracker.record("s1", 404)
+	if !tracker.record("s1", 400) {
+		t.Fatal("expected the third error within the window to tighten the session")
+	}
+	if !tracker.tightened("s1") {
+		t.Error("expected session s1 to be tightened")
+	}
+	if tracker.tightened("s2") {
+		t.Error("expected other sessions not to be tightened")
+	}
+
+	now = now.Add(11 * time.Minute)
+	if tracker.tightened("s1") {
+		t.Error("expected the penalty to end")
+	}
+	tracker.cleanup()
+	if len(tracker.sessions) != 0 {
+		t.Errorf("expected cleanup to forget idle sessions, %d left", len(tracker.sessions))
+	}
+}
+
+func TestAbuseTracker_Tighten(t *testing.T) {
+	tracker := newAbuseTracker(config.SessionAbuseConfig{LimitFactor: 0.1})
+
+	tests := []struct {
+		limit, expected int
+	}{
+		{100, 10},
+		{15, 1},
+		{5, 1},
+	}
+	for _, tt := range tests {
+		if got := tracker.tighten(tt.limit); got != tt.expected {
+			t.Errorf("tighten(%d) = %d, expected %d", tt.limit, got, tt.expected)
+		}
+	}
+}
+
+func TestMiddleware_SessionAbuse(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+
+	cfg := &config.Config{
+		RateLimit: config.RateLimitConfig{
+			Enabled:     true,
+			Backend:     "memory",
+			FailureMode: "fail-closed",
+			GlobalLimits: []config.LimitDefinition{
+				{Key: "session", Limit: 100, Window: "1h"},
+			},
+			SessionAbuse: config.SessionAbuseConfig{
+				Enabled:        true,
+				ErrorThreshold: 2,
+				Window:         time.Minute,
+				Penalty:        time.Hour,
+				LimitFactor:    0.02,
+			},
+		},
+	}
+	limiter, err := NewLimiter(&cfg.RateLimit)
+	if err != nil {
+		t.Fatalf("NewLimiter() error = %v", err)
+	}
+	defer limiter.Close()
+
+	status := http.StatusNotFound
+	handler := Middleware(limiter, cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+
+	request := func(sessionID string) *httptest.ResponseRecorder {
+		user := &auth.UserContext{UserID: "user1", SessionID: sessionID}
+		req := httptest.NewRequest("GET", "/probe", nil)
+		req = req.WithContext(auth.SetUserContext(context.Background(), user))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Two 404s tighten the probing session to 2 requests an hour
+	request("probing")
+	request("probing")
+
+	status = http.StatusOK
+	if rec := request("probing"); rec.Header().Get("X-RateLimit-Limit") != "2" {
+		t.Errorf("expected tightened limit 2, got %s", rec.Header().Get("X-RateLimit-Limit"))
+	}
+	request("probing")
+	if rec := request("probing"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected probing session to be throttled, got %d", rec.Code)
+	}
+
+	// The user's other session keeps its full limit
+	rec := request("laptop")
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected other session to pass, got %d", rec.Code)
+	}
+	if rec.Header().Get("X-RateLimit-Limit") != "100" {
+		t.Errorf("expected full limit 100 for other session, got %s", rec.Header().Get("X-RateLimit-Limit"))
+	}
+}
@@ -0,0 +1,158 @@
+package ratelimit
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+)
+
+// maxAdaptiveSamples bounds the latencies kept per route and interval; later
+// responses replace random earlier ones
+const maxAdaptiveSamples = 1024
+
+// adaptiveController scales the limits of each route by a factor that is
+// cut multiplicatively while the route's responses are slow or failing and
+// raised additively while they are healthy
+type adaptiveController struct {
+	config config.AdaptiveRateLimitConfig
+
+	mu     sync.Mutex
+	routes map[string]*adaptiveRoute
+}
+
+// adaptiveRoute holds the factor of a route and the responses seen in the
+// current interval
+type adaptiveRoute struct {
+	factor    float64
+	latencies []time.Duration
+	requests  int
+	errors    int
+}
+
+func newAdaptiveController(cfg config.AdaptiveRateLimitConfig) *adaptiveController {
+	return &adaptiveController{
+		config: cfg,
+		routes: make(map[string]*adaptiveRoute),
+	}
+}
+
+// record counts a response of route
+func (a *adaptiveController) record(route string, latency time.Duration, status int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state, ok := a.routes[route]
+	if !ok {
+		state = &adaptiveRoute{factor: 1}
+		a.routes[route] = state
+	}
+	state.requests++
+	if status >= 500 {
+		state.errors++
+	}
+	if len(state.latencies) < maxAdaptiveSamples {
+		state.latencies = append(state.latencies, latency)
+	} else {
+		// Reservoir sampling keeps an unbiased sample of the interval
+		if i := rand.IntN(state.requests); i < maxAdaptiveSamples {
+			state.latencies[i] = latency
+		}
+	}
+}
+
+// factor returns the fraction of its limits currently applied to route
+func (a *adaptiveController) factor(route string) float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if state, ok := a.routes[route]; ok {
+		return state.factor
+	}
+	return 1
+}
+
+// scale returns limit with its limit and burst scaled by the factor of
+// route, never below one request
+func (a *adaptiveController) scale(route string, limit config.LimitDefinition) config.LimitDefinition {
+	factor := a.factor(route)
+	if factor >= 1 {
+		return limit
+	}
+	limit.Limit = scaleLimit(limit.Limit, factor)
+	if limit.Burst > 0 {
+		limit.Burst = scaleLimit(limit.Burst, factor)
+	}
+	return limit
+}
+
+// evaluate adjusts the factor of every route from the responses of the
+// interval that just ended and starts a new interval
+func (a *adaptiveController) evaluate() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for route, state := range a.routes {
+		previous := state.factor
+		p99, errorRate := state.p99(), 0.0
+		if state.requests > 0 {
+			errorRate = float64(state.errors) / float64(state.requests)
+		}
+
+		unhealthy := state.requests >= a.config.MinSamples &&
+			(p99 > a.config.LatencyThreshold || errorRate > a.config.ErrorRateThreshold)
+		if unhealthy {
+			state.factor = math.Max(a.config.MinFactor, state.factor*a.config.DecreaseFactor)
+		} else {
+			state.factor = math.Min(1, state.factor+a.config.IncreaseStep)
+		}
+		state.latencies = state.latencies[:0]
+		state.requests, state.errors = 0, 0
+
+		if state.factor != previous {
+			a.logChange(route, previous, state.factor, p99, errorRate)
+		}
+		metrics.RecordRateLimitAdaptiveFactor(route, state.factor)
+
+		// Routes back at full limits are forgotten until they see traffic
+		if state.factor >= 1 {
+			delete(a.routes, route)
+		}
+	}
+}
+
+func (a *adaptiveController) logChange(route string, previous, factor float64, p99 time.Duration, errorRate float64) {
+	fields := logger.Fields{
+		"route":      route,
+		"factor":     factor,
+		"previous":   previous,
+		"p99":        p99.String(),
+		"error_rate": errorRate,
+	}
+	log := logger.Get().WithComponent("ratelimit")
+	if factor < previous {
+		log.Warn("rate limits tightened for unhealthy route", fields)
+	} else {
+		log.Info("rate limits relaxed for recovering route", fields)
+	}
+}
+
+// p99 returns the 99th percentile latency of the interval
+func (s *adaptiveRoute) p99() time.Duration {
+	if len(s.latencies) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(s.latencies)
+	slices.Sort(sorted)
+	return sorted[int(math.Ceil(0.99*float64(len(sorted))))-1]
+}
+
+// scaleLimit scales a limit by factor, keeping at least one request
+func scaleLimit(limit int, factor float64) int {
+	return max(1, int(math.Ceil(float64(limit)*factor)))
+}
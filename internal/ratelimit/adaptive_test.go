@@ -0,0 +1,173 @@
+package ratelimit
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+)
+
+func testAdaptiveConfig() config.AdaptiveRateLimitConfig {
+	return config.AdaptiveRateLimitConfig{
+		Enabled:            true,
+		Interval:           time.Second,
+		LatencyThreshold:   500 * time.Millisecond,
+		ErrorRateThreshold: 0.1,
+		MinSamples:         10,
+		DecreaseFactor:     0.5,
+		IncreaseStep:       0.25,
+		MinFactor:          0.2,
+	}
+}
+
+func TestAdaptiveController(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+
+	tests := []struct {
+		name      string
+		responses func(a *adaptiveController)
+		expected  float64
+	}{
+		{
+			name: "healthy route keeps full limits",
+			responses: func(a *adaptiveController) {
+				for i := 0; i < 100; i++ {
+					a.record("/orders", 50*time.Millisecond, http.StatusOK)
+				}
+			},
+			expected: 1,
+		},
+		{
+			name: "errors cut the factor",
+			responses: func(a *adaptiveController) {
+				for i := 0; i < 100; i++ {
+					status := http.StatusOK
+					if i%5 == 0 {
+						status = http.StatusBadGateway
+					}
+					a.record("/orders", 50*time.Millisecond, status)
+				}
+			},
+			expected: 0.5,
+		},
+		{
+			name: "slow p99 cuts the factor",
+			responses: func(a *adaptiveController) {
+				for i := 0; i < 100; i++ {
+					latency := 50 * time.Millisecond
+					if i < 2 {
+						latency = 2 * time.Second
+					}
+					a.record("/orders", latency, http.StatusOK)
+				}
+			},
+			expected: 0.5,
+		},
+		{
+			name: "too few samples count as healthy",
+			responses: func(a *adaptiveController) {
+				for i := 0; i < 5; i++ {
+					a.record("/orders", 50*time.Millisecond, http.StatusServiceUnavailable)
+				}
+			},
+			expected: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAdaptiveController(testAdaptiveConfig())
+			tt.responses(a)
+			a.evaluate()
+			if factor := a.factor("/orders"); factor != tt.expected {
+				t.Errorf("expected factor %v, got %v", tt.expected, factor)
+			}
+		})
+	}
+}
+
+func TestAdaptiveController_Recovery(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+	a := newAdaptiveController(testAdaptiveConfig())
+
+	failingInterval := func() {
+		for i := 0; i < 20; i++ {
+			a.record("/orders", time.Millisecond, http.StatusInternalServerError)
+		}
+		a.evaluate()
+	}
+
+	// Multiplicative decrease stops at the minimum factor
+	for _, expected := range []float64{0.5, 0.25, 0.2} {
+		failingInterval()
+		if factor := a.factor("/orders"); factor != expected {
+			t.Fatalf("expected factor %v, got %v", expected, factor)
+		}
+	}
+
+	// Additive increase back to full limits
+	for _, expected := range []float64{0.45, 0.7, 0.95, 1} {
+		a.evaluate()
+		if factor := a.factor("/orders"); factor < expected-1e-9 || factor > expected+1e-9 {
+			t.Fatalf("expected factor %v, got %v", expected, factor)
+		}
+	}
+	if len(a.routes) != 0 {
+		t.Errorf("expected recovered route to be forgotten, got %v", a.routes)
+	}
+
+	// Scaled limits never drop below one request
+	failingInterval()
+	failingInterval()
+	failingInterval()
+	scaled := a.scale("/orders", config.LimitDefinition{Limit: 3, Burst: 2, Window: "1m"})
+	if scaled.Limit != 1 || scaled.Burst != 1 {
+		t.Errorf("expected limits scaled to 1, got %+v", scaled)
+	}
+}
+
+func TestMiddleware_AdaptiveRateLimit(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+
+	cfg := &config.Config{
+		RateLimit: config.RateLimitConfig{
+			Enabled:     true,
+			Backend:     "memory",
+			FailureMode: "fail-closed",
+			Adaptive:    testAdaptiveConfig(),
+		},
+		Routes: []config.RouteConfig{
+			{PathPattern: "/search", Methods: []string{"GET"}, RateLimits: []config.LimitDefinition{{Key: "route", Limit: 100, Window: "1h"}}},
+		},
+	}
+	cfg.RateLimit.Adaptive.Interval = time.Hour // evaluated by the test
+	limiter, err := NewLimiter(&cfg.RateLimit)
+	if err != nil {
+		t.Fatalf("NewLimiter() error = %v", err)
+	}
+	defer limiter.Close()
+
+	status := http.StatusServiceUnavailable
+	handler := withRouteMatch(t, cfg.Routes, Middleware(limiter, cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	})))
+	request := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/search", nil))
+		return rec
+	}
+
+	for i := 0; i < 10; i++ {
+		request()
+	}
+	limiter.adaptive.evaluate()
+
+	status = http.StatusOK
+	if rec := request(); rec.Header().Get("X-RateLimit-Limit") != "50" {
+		t.Errorf("expected limit halved to 50 after failures, got %s", rec.Header().Get("X-RateLimit-Limit"))
+	}
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// concurrencyTracker counts the requests in flight per key, so limits on
+// simultaneous requests can be enforced alongside limits per window
+type concurrencyTracker struct {
+	mu       sync.Mutex
+	inFlight map[string]int
+}
+
+func newConcurrencyTracker() *concurrencyTracker {
+	return &concurrencyTracker{inFlight: make(map[string]int)}
+}
+
+// acquire counts a request for key unless max requests are in flight
+func (c *concurrencyTracker) acquire(key string, max int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.inFlight[key] >= max {
+		return false
+	}
+	c.inFlight[key]++
+	return true
+}
+
+// release ends a request counted for key
+func (c *concurrencyTracker) release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.inFlight[key] <= 1 {
+		delete(c.inFlight, key)
+		return
+	}
+	c.inFlight[key]--
+}
+
+// AcquireConcurrency counts the request against the concurrency limits of
+// its route. It returns a function ending the request, or the limit that was
+// exceeded. Requests without a key for a limit, such as anonymous requests
+// to a limit keyed by user, are not limited by it.
+func (l *Limiter) AcquireConcurrency(r *http.Request, route *router.Route) (func(), *config.ConcurrencyLimit) {
+	acquired := make([]string, 0, len(route.ConcurrencyLimits))
+	release := func() {
+		for _, key := range acquired {
+			l.concurrency.release(key)
+		}
+	}
+
+	for i := range route.ConcurrencyLimits {
+		limit := &route.ConcurrencyLimits[i]
+		key, ok := NewKeyGenerator(limit.Key).WithIPPrefixes(l.ipv4Prefix, l.ipv6Prefix).GenerateKey(r)
+		if !ok {
+			continue
+		}
+		key = route.PathPattern + "|" + limit.Key + "|" + key
+		if !l.concurrency.acquire(key, limit.Max) {
+			release()
+			return nil, limit
+		}
+		acquired = append(acquired, key)
+	}
+	return release, nil
+}
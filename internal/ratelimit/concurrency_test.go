@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/maltehedderich/api-gateway-go/internal/auth"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+)
+
+func TestConcurrencyTracker(t *testing.T) {
+	c := newConcurrencyTracker()
+
+	if !c.acquire("export", 2) || !c.acquire("export", 2) {
+		t.Fatal("expected two requests to be admitted")
+	}
+	if c.acquire("export", 2) {
+		t.Error("expected third request to be rejected")
+	}
+	c.release("export")
+	if !c.acquire("export", 2) {
+		t.Error("expected a request to be admitted after a release")
+	}
+	c.release("export")
+	c.release("export")
+	if len(c.inFlight) != 0 {
+		t.Errorf("expected idle keys to be forgotten, got %v", c.inFlight)
+	}
+}
+
+func TestMiddleware_ConcurrencyLimits(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+
+	cfg := &config.Config{
+		RateLimit: config.RateLimitConfig{Enabled: true, Backend: "memory", FailureMode: "fail-closed"},
+		Routes: []config.RouteConfig{
+			{
+				PathPattern: "/exports",
+				Methods:     []string{"POST"},
+				ConcurrencyLimits: []config.ConcurrencyLimit{
+					{Key: "user", Max: 1},
+					{Key: "route", Max: 2, Status: http.StatusServiceUnavailable},
+				},
+			},
+		},
+	}
+	limiter, err := NewLimiter(&cfg.RateLimit)
+	if err != nil {
+		t.Fatalf("NewLimiter() error = %v", err)
+	}
+	defer limiter.Close()
+
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	handler := withRouteMatch(t, cfg.Routes, Middleware(limiter, cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-finish
+		w.WriteHeader(http.StatusOK)
+	})))
+	request := func(userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/exports", nil)
+		req = req.WithContext(auth.SetUserContext(req.Context(), &auth.UserContext{UserID: userID}))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// alice and bob each start an export that keeps running
+	var wg sync.WaitGroup
+	for _, user := range []string{"alice", "bob"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if rec := request(user); rec.Code != http.StatusOK {
+				t.Errorf("expected running export of %s to succeed, got %d", user, rec.Code)
+			}
+		}()
+		<-started
+	}
+
+	if rec := request("alice"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 for a second export of alice, got %d", rec.Code)
+	}
+	if rec := request("carol"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 while the route is at capacity, got %d", rec.Code)
+	}
+
+	close(finish)
+	wg.Wait()
+
+	// Finished requests free their slots
+	finish = make(chan struct{})
+	go func() { <-started; close(finish) }()
+	if rec := request("alice"); rec.Code != http.StatusOK {
+		t.Errorf("expected export to succeed after the others finished, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+)
+
+func TestLimiter_AllowN(t *testing.T) {
+	for _, algorithm := range []string{"token_bucket", "sliding_window", "gcra"} {
+		t.Run(algorithm, func(t *testing.T) {
+			limiter, err := NewLimiter(&config.RateLimitConfig{Backend: "memory", FailureMode: "fail-closed"})
+			if err != nil {
+				t.Fatalf("NewLimiter() error = %v", err)
+			}
+			defer limiter.Close()
+
+			limit := &config.LimitDefinition{Key: "ip", Limit: 10, Window: "1h", Burst: 10, Algorithm: algorithm}
+			req := httptest.NewRequest("GET", "/search", nil)
+
+			steps := []struct {
+				cost     int
+				expected bool
+			}{
+				{4, true},
+				{4, true},
+				{4, false}, // only 2 tokens left
+				{2, true},
+				{1, false},
+			}
+			for i, step := range steps {
+				result, err := limiter.AllowN(context.Background(), req, limit, step.cost)
+				if err != nil {
+					t.Fatalf("AllowN() error = %v", err)
+				}
+				if result.Allowed != step.expected {
+					t.Errorf("step %d costing %d: expected allowed = %v, got %+v", i, step.cost, step.expected, result)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiter_Charge(t *testing.T) {
+	for _, algorithm := range []string{"token_bucket", "sliding_window", "gcra"} {
+		t.Run(algorithm, func(t *testing.T) {
+			limiter, err := NewLimiter(&config.RateLimitConfig{Backend: "memory", FailureMode: "fail-closed"})
+			if err != nil {
+				t.Fatalf("NewLimiter() error = %v", err)
+			}
+			defer limiter.Close()
+
+			limit := &config.LimitDefinition{Key: "ip", Limit: 10, Window: "1h", Burst: 10, Algorithm: algorithm}
+			req := httptest.NewRequest("GET", "/search", nil)
+
+			if err := limiter.Charge(context.Background(), req, limit, 6); err != nil {
+				t.Fatalf("Charge() error = %v", err)
+			}
+			if result, _ := limiter.AllowN(context.Background(), req, limit, 4); !result.Allowed {
+				t.Errorf("expected the 4 tokens left after charging 6 to be available, got %+v", result)
+			}
+
+			// Charges beyond what is left empty the limit without debt
+			if err := limiter.Charge(context.Background(), req, limit, 100); err != nil {
+				t.Fatalf("Charge() error = %v", err)
+			}
+			result, _ := limiter.Allow(context.Background(), req, limit)
+			if result.Allowed {
+				t.Errorf("expected charged limit to be used up, got %+v", result)
+			}
+			if result.RetryAfter > time.Hour {
+				t.Errorf("expected retry within the window, got %v", result.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestMiddleware_RouteCost(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+
+	cfg := &config.Config{
+		RateLimit: config.RateLimitConfig{
+			Enabled:      true,
+			Backend:      "memory",
+			FailureMode:  "fail-closed",
+			GlobalLimits: []config.LimitDefinition{{Key: "ip", Limit: 10, Window: "1h"}},
+		},
+		Routes: []config.RouteConfig{
+			{PathPattern: "/search", Methods: []string{"GET"}, BackendURL: "http://search", Cost: 4},
+			{PathPattern: "/reports/{id}", Methods: []string{"GET"}, BackendURL: "http://reports", CostHeader: "X-Request-Cost"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		expected []int
+	}{
+		// 4 tokens per search: the third finds only 2 left
+		{"weighted route", "/search", []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}},
+		// each report takes 1 token up front and 5 once the backend reports
+		// its cost: the third finds none left
+		{"reported cost", "/reports/1", []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter, err := NewLimiter(&cfg.RateLimit)
+			if err != nil {
+				t.Fatalf("NewLimiter() error = %v", err)
+			}
+			defer limiter.Close()
+
+			handler := withRouteMatch(t, cfg.Routes, Middleware(limiter, cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Request-Cost", "5")
+				w.WriteHeader(http.StatusOK)
+			})))
+
+			for i, expected := range tt.expected {
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+				if rec.Code != expected {
+					t.Errorf("request %d: expected status %d, got %d", i, expected, rec.Code)
+				}
+			}
+		})
+	}
+}
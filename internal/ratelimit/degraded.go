@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+)
+
+// degradedPingTimeout bounds the probes of the storage backend
+const degradedPingTimeout = 2 * time.Second
+
+// degradedMode counts requests in local memory while the storage backend is
+// unreachable. Every instance then admits its own share of a limit, so the
+// local limits are scaled down by factor.
+type degradedMode struct {
+	local  *MemoryStorage
+	factor float64
+	active atomic.Bool
+}
+
+// newDegradedMode creates the local fallback of a limiter
+func newDegradedMode(cfg config.DegradedModeConfig) *degradedMode {
+	return &degradedMode{
+		local:  NewMemoryStorage(),
+		factor: cfg.LimitFactor,
+	}
+}
+
+// scale returns limitDef with its limit and burst scaled down, keeping at
+// least one request per window
+func (d *degradedMode) scale(limitDef *config.LimitDefinition) *config.LimitDefinition {
+	scaled := *limitDef
+	scaled.Limit = max(1, int(float64(limitDef.Limit)*d.factor))
+	if scaled.Burst > 0 {
+		scaled.Burst = max(1, int(float64(limitDef.Burst)*d.factor))
+	}
+	return &scaled
+}
+
+// backend returns the storage holding the limit state and the limit applying
+// there: the local memory with scaled limits while degraded
+func (l *Limiter) backend(limitDef *config.LimitDefinition) (Storage, *config.LimitDefinition) {
+	if l.degraded == nil || !l.degraded.active.Load() {
+		return l.storage, limitDef
+	}
+	return l.degraded.local, l.degraded.scale(limitDef)
+}
+
+// degrade switches to the local memory after storage failed with err, and
+// reports whether the request should be checked again there. Requests
+// canceled by their client do not tell anything about the storage.
+func (l *Limiter) degrade(storage Storage, err error) bool {
+	if l.degraded == nil || storage != l.storage || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if l.degraded.active.CompareAndSwap(false, true) {
+		logger.Get().WithComponent("ratelimit").Warn("rate limit storage unreachable, limiting requests locally", logger.Fields{
+			"error":        err.Error(),
+			"limit_factor": l.degraded.factor,
+		})
+		metrics.RecordRateLimitDegraded(true)
+	}
+	return true
+}
+
+// Degraded reports whether requests are limited locally because the storage
+// backend is unreachable
+func (l *Limiter) Degraded() bool {
+	return l.degraded != nil && l.degraded.active.Load()
+}
+
+// degradedLoop probes the storage backend every interval, switching to the
+// local memory when it stops answering and back once it answers again
+func (l *Limiter) degradedLoop(interval time.Duration) {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.probeStorage()
+		case <-l.stopCh:
+			return
+		}
+	}
+}
+
+// probeStorage pings the storage backend and switches between it and the
+// local memory as needed
+func (l *Limiter) probeStorage() {
+	ctx, cancel := context.WithTimeout(context.Background(), degradedPingTimeout)
+	defer cancel()
+
+	err := l.storage.Ping(ctx)
+	if err != nil {
+		l.degrade(l.storage, err)
+		return
+	}
+	if !l.degraded.active.Load() {
+		return
+	}
+
+	restored, err := l.resync(ctx)
+	if err != nil {
+		logger.Get().WithComponent("ratelimit").Warn("failed to resync local rate limit state", logger.Fields{
+			"error":    err.Error(),
+			"restored": restored,
+		})
+		return
+	}
+	l.degraded.active.Store(false)
+	metrics.RecordRateLimitDegraded(false)
+	logger.Get().WithComponent("ratelimit").Info("rate limit storage recovered", logger.Fields{
+		"restored": restored,
+	})
+}
+
+// resync copies the local limit state to the storage backend, for keys the
+// backend holds no state of, and drops it locally. It returns the number of
+// keys restored.
+func (l *Limiter) resync(ctx context.Context) (int, error) {
+	entries, err := l.degraded.local.Entries(ctx, "", math.MaxInt)
+	if err != nil {
+		return 0, err
+	}
+
+	restorer, canRestore := l.storage.(RestorableStorage)
+	restored := 0
+	for _, entry := range entries {
+		if canRestore {
+			if err := restorer.Restore(ctx, entry); err != nil {
+				return restored, err
+			}
+			restored++
+		}
+		_ = l.degraded.local.Delete(ctx, entry.Key)
+	}
+	return restored, nil
+}
@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+// unreachableStorage is a memory storage standing in for a Redis server
+// that can go down
+type unreachableStorage struct {
+	*MemoryStorage
+	down atomic.Bool
+}
+
+var errUnreachable = errors.New("connection refused")
+
+func (s *unreachableStorage) Get(ctx context.Context, key string) (*BucketState, bool, error) {
+	if s.down.Load() {
+		return nil, false, errUnreachable
+	}
+	return s.MemoryStorage.Get(ctx, key)
+}
+
+func (s *unreachableStorage) Set(ctx context.Context, key string, state *BucketState, ttl time.Duration) error {
+	if s.down.Load() {
+		return errUnreachable
+	}
+	return s.MemoryStorage.Set(ctx, key, state, ttl)
+}
+
+func (s *unreachableStorage) Ping(ctx context.Context) error {
+	if s.down.Load() {
+		return errUnreachable
+	}
+	return nil
+}
+
+func (s *unreachableStorage) Restore(ctx context.Context, entry Entry) error {
+	if _, ok, _ := s.MemoryStorage.Get(ctx, entry.Key); ok {
+		return nil
+	}
+	return s.MemoryStorage.Set(ctx, entry.Key, &entry.State, time.Until(entry.ExpiresAt))
+}
+
+func TestLimiter_Degraded(t *testing.T) {
+	for _, algorithm := range []string{"token_bucket", "sliding_window", "gcra"} {
+		t.Run(algorithm, func(t *testing.T) {
+			storage := &unreachableStorage{MemoryStorage: NewMemoryStorage()}
+			limiter := &Limiter{
+				storage:     storage,
+				failureMode: "fail-closed",
+				degraded:    newDegradedMode(config.DegradedModeConfig{Enabled: true, LimitFactor: 0.5}),
+				stopCh:      make(chan struct{}),
+			}
+			defer limiter.Close()
+
+			limit := &config.LimitDefinition{Key: "ip", Limit: 10, Window: "1h", Burst: 10, Algorithm: algorithm}
+			req := httptest.NewRequest("GET", "/", nil)
+			storage.down.Store(true)
+
+			// The local limit is half of the configured one
+			for i := 0; i < 6; i++ {
+				result, err := limiter.Allow(context.Background(), req, limit)
+				if err != nil {
+					t.Fatalf("Allow() error = %v", err)
+				}
+				if result.Allowed != (i < 5) {
+					t.Errorf("request %d: Allowed = %v, want %v", i+1, result.Allowed, i < 5)
+				}
+			}
+			if !limiter.Degraded() {
+				t.Fatal("Degraded() = false while storage is down")
+			}
+
+			// Redis is still down, so the limiter stays degraded
+			limiter.probeStorage()
+			if !limiter.Degraded() {
+				t.Fatal("Degraded() = false after failed probe")
+			}
+
+			storage.down.Store(false)
+			limiter.probeStorage()
+			if limiter.Degraded() {
+				t.Fatal("Degraded() = true after storage recovered")
+			}
+			if _, ok, _ := storage.MemoryStorage.Get(context.Background(), "ratelimit:ip:192.0.2.1"); !ok {
+				t.Error("local state was not restored to the storage")
+			}
+			if entries, _ := limiter.degraded.local.Entries(context.Background(), "", maxInspectedKeys); len(entries) != 0 {
+				t.Errorf("local state has %d entries after resync, want 0", len(entries))
+			}
+
+			// The requests admitted while degraded still count
+			result, err := limiter.AllowN(context.Background(), req, limit, 6)
+			if err != nil {
+				t.Fatalf("AllowN() error = %v", err)
+			}
+			if result.Allowed {
+				t.Error("request over the restored limit was allowed")
+			}
+		})
+	}
+}
+
+func TestLimiter_DegradedProbe(t *testing.T) {
+	storage := &unreachableStorage{MemoryStorage: NewMemoryStorage()}
+	limiter := &Limiter{
+		storage:     storage,
+		failureMode: "fail-closed",
+		degraded:    newDegradedMode(config.DegradedModeConfig{Enabled: true, LimitFactor: 0.5}),
+		stopCh:      make(chan struct{}),
+	}
+	defer limiter.Close()
+
+	limiter.probeStorage()
+	if limiter.Degraded() {
+		t.Fatal("Degraded() = true while storage is up")
+	}
+
+	// A failed probe degrades the limiter before any request fails
+	storage.down.Store(true)
+	limiter.probeStorage()
+	if !limiter.Degraded() {
+		t.Fatal("Degraded() = false after failed probe")
+	}
+
+	// Without degraded mode the failure mode applies
+	limiter.degraded = nil
+	req := httptest.NewRequest("GET", "/", nil)
+	_, err := limiter.Allow(context.Background(), req, &config.LimitDefinition{Key: "ip", Limit: 10, Window: "1h"})
+	if !errors.Is(err, errUnreachable) {
+		t.Errorf("Allow() error = %v, want %v", err, errUnreachable)
+	}
+}
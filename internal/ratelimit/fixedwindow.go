@@ -0,0 +1,148 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// fixedWindowShards is the number of independently locked shards of the
+// fixed window counters, so requests of different keys rarely contend
+const fixedWindowShards = 64
+
+// fixedWindowRollover is how often counters of past windows are dropped
+const fixedWindowRollover = 10 * time.Second
+
+// FixedWindowCounters counts requests per key in fixed windows aligned to
+// the clock, such as each whole minute. A counter is a window number and a
+// count, cheap enough for limits over millions of keys such as per-IP abuse
+// protection, at the cost of admitting up to twice the limit around the
+// boundary of two windows. The zero value is ready to use.
+type FixedWindowCounters struct {
+	shards [fixedWindowShards]fixedWindowShard
+}
+
+// fixedWindowShard holds the counters of the keys hashed to it
+type fixedWindowShard struct {
+	mu       sync.Mutex
+	counters map[fixedWindowKey]fixedWindowCounter
+}
+
+// fixedWindowKey tells apart limits of the same key with different windows
+type fixedWindowKey struct {
+	key    string
+	window time.Duration
+}
+
+// fixedWindowCounter is the count of requests in window number index
+type fixedWindowCounter struct {
+	index int64
+	count int
+}
+
+// shard returns the shard of key, picked by its FNV-1a hash
+func (c *FixedWindowCounters) shard(key string) *fixedWindowShard {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return &c.shards[hash%fixedWindowShards]
+}
+
+// windowIndex returns the number of the window of the given length at now
+func windowIndex(now time.Time, window time.Duration) int64 {
+	return now.UnixNano() / int64(window)
+}
+
+// windowEnd returns when the window number index ends
+func windowEnd(index int64, window time.Duration) time.Time {
+	return time.Unix(0, (index+1)*int64(window))
+}
+
+// AllowN reports whether n more requests of key fit into limit in the
+// current window and counts them if so. It also returns the requests left
+// in the window and when the window ends.
+func (c *FixedWindowCounters) AllowN(key string, limit int, window time.Duration, n int, now time.Time) (bool, int, time.Time) {
+	shard := c.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	k := fixedWindowKey{key: key, window: window}
+	index := windowIndex(now, window)
+	counter := shard.counters[k]
+	if counter.index != index {
+		counter = fixedWindowCounter{index: index}
+	}
+
+	allowed := counter.count+n <= limit
+	if allowed {
+		counter.count += n
+		if shard.counters == nil {
+			shard.counters = make(map[fixedWindowKey]fixedWindowCounter)
+		}
+		shard.counters[k] = counter
+	}
+	return allowed, max(0, limit-counter.count), windowEnd(index, window)
+}
+
+// Charge counts up to n more requests of key in the current window, but no
+// more than limit has left.
+func (c *FixedWindowCounters) Charge(key string, limit int, window time.Duration, n int, now time.Time) {
+	shard := c.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	k := fixedWindowKey{key: key, window: window}
+	index := windowIndex(now, window)
+	counter := shard.counters[k]
+	if counter.index != index {
+		counter = fixedWindowCounter{index: index}
+	}
+	if n = min(n, limit-counter.count); n <= 0 {
+		return
+	}
+	counter.count += n
+	if shard.counters == nil {
+		shard.counters = make(map[fixedWindowKey]fixedWindowCounter)
+	}
+	shard.counters[k] = counter
+}
+
+// Reset drops the counters of key, so its limits start over
+func (c *FixedWindowCounters) Reset(key string) {
+	shard := c.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	for k := range shard.counters {
+		if k.key == key {
+			delete(shard.counters, k)
+		}
+	}
+}
+
+// Rollover drops the counters of windows that ended before now
+func (c *FixedWindowCounters) Rollover(now time.Time) {
+	for i := range c.shards {
+		shard := &c.shards[i]
+		shard.mu.Lock()
+		for k, counter := range shard.counters {
+			if counter.index < windowIndex(now, k.window) {
+				delete(shard.counters, k)
+			}
+		}
+		shard.mu.Unlock()
+	}
+}
+
+// Len returns the number of counters held
+func (c *FixedWindowCounters) Len() int {
+	total := 0
+	for i := range c.shards {
+		shard := &c.shards[i]
+		shard.mu.Lock()
+		total += len(shard.counters)
+		shard.mu.Unlock()
+	}
+	return total
+}
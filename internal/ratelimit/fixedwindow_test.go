@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+func TestFixedWindowCounters_AllowN(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		requests []time.Duration // offsets from the start of a window
+		costs    []int
+		expected []bool
+	}{
+		{
+			name:     "limit applies within a window",
+			requests: []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second},
+			costs:    []int{1, 1, 1, 1},
+			expected: []bool{true, true, true, false},
+		},
+		{
+			name:     "next window starts over",
+			requests: []time.Duration{0, 0, 0, 59 * time.Second, time.Minute},
+			costs:    []int{1, 1, 1, 1, 1},
+			expected: []bool{true, true, true, false, true},
+		},
+		{
+			name:     "costly requests fit only while enough is left",
+			requests: []time.Duration{0, 0, 0},
+			costs:    []int{2, 2, 1},
+			expected: []bool{true, false, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var counters FixedWindowCounters
+			for i, offset := range tt.requests {
+				allowed, _, _ := counters.AllowN("ratelimit:ip:192.0.2.1", 3, time.Minute, tt.costs[i], start.Add(offset))
+				if allowed != tt.expected[i] {
+					t.Errorf("request %d: allowed = %v, want %v", i+1, allowed, tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFixedWindowCounters_Remaining(t *testing.T) {
+	var counters FixedWindowCounters
+	now := time.Date(2024, 1, 1, 12, 0, 30, 0, time.UTC)
+
+	_, remaining, reset := counters.AllowN("key", 10, time.Minute, 4, now)
+	if remaining != 6 {
+		t.Errorf("remaining = %d, want 6", remaining)
+	}
+	if want := time.Date(2024, 1, 1, 12, 1, 0, 0, time.UTC); !reset.Equal(want) {
+		t.Errorf("reset = %v, want %v", reset, want)
+	}
+
+	// Limits of the same key with another window are counted apart
+	if _, remaining, _ := counters.AllowN("key", 100, time.Hour, 1, now); remaining != 99 {
+		t.Errorf("remaining of hourly limit = %d, want 99", remaining)
+	}
+
+	counters.Charge("key", 10, time.Minute, 20, now)
+	if allowed, _, _ := counters.AllowN("key", 10, time.Minute, 1, now); allowed {
+		t.Error("request allowed after the window was charged up to its limit")
+	}
+
+	counters.Reset("key")
+	if counters.Len() != 0 {
+		t.Errorf("Len() = %d after reset, want 0", counters.Len())
+	}
+}
+
+func TestFixedWindowCounters_Rollover(t *testing.T) {
+	var counters FixedWindowCounters
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 1000; i++ {
+		counters.AllowN(fmt.Sprintf("ratelimit:ip:10.0.%d.%d", i/256, i%256), 10, time.Minute, 1, now)
+	}
+	counters.AllowN("ratelimit:user:alice", 10, time.Hour, 1, now)
+
+	counters.Rollover(now.Add(59 * time.Second))
+	if counters.Len() != 1001 {
+		t.Fatalf("Len() = %d before the window ended, want 1001", counters.Len())
+	}
+
+	// Only the hourly counter is still in its window
+	counters.Rollover(now.Add(time.Minute))
+	if counters.Len() != 1 {
+		t.Errorf("Len() = %d after rollover, want 1", counters.Len())
+	}
+}
+
+func TestLimiter_FixedWindow(t *testing.T) {
+	limiter, err := NewLimiter(&config.RateLimitConfig{Backend: "memory", FailureMode: "fail-closed"})
+	if err != nil {
+		t.Fatalf("NewLimiter() error = %v", err)
+	}
+	defer limiter.Close()
+
+	limit := &config.LimitDefinition{Key: "ip", Limit: 5, Window: "24h", Algorithm: "fixed_window"}
+	req := httptest.NewRequest("GET", "/", nil)
+
+	result, err := limiter.AllowN(context.Background(), req, limit, 5)
+	if err != nil {
+		t.Fatalf("AllowN() error = %v", err)
+	}
+	if !result.Allowed || result.Remaining != 0 {
+		t.Errorf("result = %+v, want allowed with none remaining", result)
+	}
+
+	result, err = limiter.Allow(context.Background(), req, limit)
+	if err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	if result.Allowed || result.RetryAfter <= 0 {
+		t.Errorf("result = %+v, want rejected with a retry time", result)
+	}
+
+	// Fixed windows are not kept in the storage backend
+	if entries, _ := limiter.storage.(*MemoryStorage).Entries(context.Background(), "", maxInspectedKeys); len(entries) != 0 {
+		t.Errorf("storage holds %d entries, want 0", len(entries))
+	}
+
+	if err := limiter.Reset(context.Background(), "ratelimit:ip:192.0.2.1"); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+	if result, _ := limiter.Allow(context.Background(), req, limit); !result.Allowed {
+		t.Error("request rejected after reset")
+	}
+}
@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// GCRA implements the generic cell rate algorithm. Requests are spaced an
+// emission interval of Window/Limit apart, and at most Burst requests are
+// admitted back to back. With a burst of 1 traffic is smoothed evenly across
+// the window. The only state is the theoretical arrival time (TAT) of the
+// next request.
+type GCRA struct {
+	// Interval is the emission interval between two requests
+	Interval time.Duration
+	// Burst is the number of requests that may be admitted back to back
+	Burst int
+	// TAT is the theoretical arrival time of the next request
+	TAT time.Time
+}
+
+// NewGCRA creates a GCRA limiter admitting limit requests per window, from a
+// saved theoretical arrival time. A burst below 1 is taken as 1.
+func NewGCRA(limit int, window time.Duration, burst int, tat time.Time) *GCRA {
+	if burst < 1 {
+		burst = 1
+	}
+	return &GCRA{
+		Interval: window / time.Duration(limit),
+		Burst:    burst,
+		TAT:      tat,
+	}
+}
+
+// Allow reports whether a request at now is allowed, and advances the
+// theoretical arrival time if so.
+func (g *GCRA) Allow(now time.Time) bool {
+	return g.AllowN(now, 1)
+}
+
+// AllowN reports whether a request costing n at now is allowed, and advances
+// the theoretical arrival time by n intervals if so.
+func (g *GCRA) AllowN(now time.Time, n int) bool {
+	if now.Before(g.RetryAtN(now, n)) {
+		return false
+	}
+	g.TAT = g.arrival(now).Add(g.Interval * time.Duration(n))
+	return true
+}
+
+// Charge advances the theoretical arrival time by up to n intervals, no
+// further than leaving no request to be admitted at now.
+func (g *GCRA) Charge(now time.Time, n int) {
+	tat := g.arrival(now).Add(g.Interval * time.Duration(n))
+	if limit := now.Add(g.tolerance() + g.Interval); tat.After(limit) {
+		tat = limit
+	}
+	if tat.After(g.TAT) {
+		g.TAT = tat
+	}
+}
+
+// Remaining returns the number of requests that may be admitted back to back
+// at now.
+func (g *GCRA) Remaining(now time.Time) int {
+	free := now.Add(g.tolerance() + g.Interval).Sub(g.arrival(now))
+	remaining := int(math.Floor(float64(free) / float64(g.Interval)))
+	if remaining > g.Burst {
+		return g.Burst
+	}
+	return remaining
+}
+
+// Reset returns the time when the full burst is available again.
+func (g *GCRA) Reset(now time.Time) time.Time {
+	return g.arrival(now)
+}
+
+// RetryAt returns the time when the next request will be allowed.
+func (g *GCRA) RetryAt(now time.Time) time.Time {
+	return g.RetryAtN(now, 1)
+}
+
+// RetryAtN returns the time when the next request costing n will be allowed.
+// Requests costing more than Burst are never allowed.
+func (g *GCRA) RetryAtN(now time.Time, n int) time.Time {
+	return g.arrival(now).Add(g.Interval*time.Duration(n-1) - g.tolerance())
+}
+
+// arrival returns the theoretical arrival time, which is never in the past
+func (g *GCRA) arrival(now time.Time) time.Time {
+	if g.TAT.After(now) {
+		return g.TAT
+	}
+	return now
+}
+
+// tolerance is how far ahead of its theoretical arrival time a request may be
+func (g *GCRA) tolerance() time.Duration {
+	return g.Interval * time.Duration(g.Burst-1)
+}
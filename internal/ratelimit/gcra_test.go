@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGCRA_Allow(t *testing.T) {
+	tests := []struct {
+		name     string
+		burst    int
+		requests []time.Duration // offsets from the start
+		expected []bool
+	}{
+		{
+			name:     "requests are spaced evenly without burst",
+			burst:    0,
+			requests: []time.Duration{0, time.Second, 6 * time.Second, 11 * time.Second, 12 * time.Second},
+			expected: []bool{true, false, true, true, false},
+		},
+		{
+			name:     "burst admits requests back to back",
+			burst:    3,
+			requests: []time.Duration{0, 0, 0, 0, 5 * time.Second, 5 * time.Second},
+			expected: []bool{true, true, true, false, true, false},
+		},
+		{
+			name:     "idle time restores the burst but no more",
+			burst:    2,
+			requests: []time.Duration{0, 0, time.Hour, time.Hour, time.Hour},
+			expected: []bool{true, true, true, true, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			// 12 requests per minute: one every 5 seconds
+			g := NewGCRA(12, time.Minute, tt.burst, time.Time{})
+			for i, offset := range tt.requests {
+				if got := g.Allow(start.Add(offset)); got != tt.expected[i] {
+					t.Errorf("request %d at %v: expected %v, got %v", i, offset, tt.expected[i], got)
+				}
+			}
+		})
+	}
+}
+
+func TestGCRA_Metadata(t *testing.T) {
+	now := time.Now()
+	g := NewGCRA(12, time.Minute, 3, time.Time{})
+
+	if remaining := g.Remaining(now); remaining != 3 {
+		t.Errorf("expected full burst of 3, got %d", remaining)
+	}
+	g.Allow(now)
+	g.Allow(now)
+	if remaining := g.Remaining(now); remaining != 1 {
+		t.Errorf("expected 1 remaining, got %d", remaining)
+	}
+	g.Allow(now)
+	if remaining := g.Remaining(now); remaining != 0 {
+		t.Errorf("expected 0 remaining, got %d", remaining)
+	}
+	if retryAt := g.RetryAt(now); !retryAt.Equal(now.Add(5 * time.Second)) {
+		t.Errorf("expected retry after one interval, got %v", retryAt.Sub(now))
+	}
+	if reset := g.Reset(now); !reset.Equal(now.Add(15 * time.Second)) {
+		t.Errorf("expected reset after three intervals, got %v", reset.Sub(now))
+	}
+}
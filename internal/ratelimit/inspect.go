@@ -0,0 +1,164 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxInspectedKeys bounds the keys returned by a single inspection
+const maxInspectedKeys = 1000
+
+// ErrInspectionUnsupported is returned when the storage backend cannot list
+// or delete rate limit state
+var ErrInspectionUnsupported = errors.New("rate limit storage does not support inspection")
+
+// KeyState describes the rate limit state of a key in admin API responses.
+// Token buckets report their tokens as of LastRefill; sliding window logs
+// the requests in their window; GCRA limits their theoretical arrival time.
+type KeyState struct {
+	Key        string    `json:"key"`
+	Algorithm  string    `json:"algorithm,omitempty"`
+	Tokens     *float64  `json:"tokens,omitempty"`
+	LastRefill time.Time `json:"last_refill,omitzero"`
+	Requests   int       `json:"requests,omitempty"`
+	TAT        time.Time `json:"tat,omitzero"`
+	// ResetAt is when the state expires and the key's limits are reset
+	ResetAt      time.Time `json:"reset_at,omitzero"`
+	BlockedUntil time.Time `json:"blocked_until,omitzero"`
+}
+
+// newKeyState describes a stored entry, telling the algorithm apart by the
+// state it keeps
+func newKeyState(entry Entry) KeyState {
+	state := KeyState{Key: entry.Key, ResetAt: entry.ExpiresAt}
+	switch {
+	case len(entry.State.Log) > 0:
+		state.Algorithm = "sliding_window"
+		state.Requests = len(entry.State.Log)
+	case !entry.State.TAT.IsZero():
+		state.Algorithm = "gcra"
+		state.TAT = entry.State.TAT
+	case !entry.State.LastRefill.IsZero():
+		state.Algorithm = "token_bucket"
+		tokens := entry.State.Tokens
+		state.Tokens = &tokens
+		state.LastRefill = entry.State.LastRefill
+	}
+	return state
+}
+
+// blockList holds keys blocked through the admin API. Blocks live in the
+// memory of each instance only and are lost on restart.
+type blockList struct {
+	mu     sync.Mutex
+	blocks map[string]time.Time
+}
+
+// block rejects requests of key until the given time
+func (b *blockList) block(key string, until time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.blocks == nil {
+		b.blocks = make(map[string]time.Time)
+	}
+	b.blocks[key] = until
+}
+
+// unblock lifts the block of key and reports whether one was active
+func (b *blockList) unblock(key string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	until, ok := b.blocks[key]
+	delete(b.blocks, key)
+	return ok && time.Now().Before(until)
+}
+
+// blockedUntil returns when the block of key ends, or the zero time if key
+// is not blocked
+func (b *blockList) blockedUntil(key string) time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	until, ok := b.blocks[key]
+	if !ok {
+		return time.Time{}
+	}
+	if !time.Now().Before(until) {
+		delete(b.blocks, key)
+		return time.Time{}
+	}
+	return until
+}
+
+// active returns the active blocks of keys starting with prefix
+func (b *blockList) active(prefix string) map[string]time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	active := make(map[string]time.Time)
+	for key, until := range b.blocks {
+		if !now.Before(until) {
+			delete(b.blocks, key)
+			continue
+		}
+		if strings.HasPrefix(key, prefix) {
+			active[key] = until
+		}
+	}
+	return active
+}
+
+// Keys returns the state of the rate limit keys starting with prefix, such
+// as ratelimit:ip: or ratelimit:user:alice, including blocked keys without state, ordered by key
+func (l *Limiter) Keys(ctx context.Context, prefix string) ([]KeyState, error) {
+	storage, ok := l.storage.(InspectableStorage)
+	if !ok {
+		return nil, ErrInspectionUnsupported
+	}
+	entries, err := storage.Entries(ctx, prefix, maxInspectedKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := l.blocks.active(prefix)
+	states := make([]KeyState, 0, len(entries)+len(blocks))
+	for _, entry := range entries {
+		state := newKeyState(entry)
+		state.BlockedUntil = blocks[entry.Key]
+		delete(blocks, entry.Key)
+		states = append(states, state)
+	}
+	for key, until := range blocks {
+		states = append(states, KeyState{Key: key, BlockedUntil: until})
+	}
+
+	sort.Slice(states, func(i, j int) bool { return states[i].Key < states[j].Key })
+	return states, nil
+}
+
+// Reset deletes the state of key, including its fixed window counters on
+// this instance, so its limits start over
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	storage, ok := l.storage.(InspectableStorage)
+	if !ok {
+		return ErrInspectionUnsupported
+	}
+	l.windows.Reset(key)
+	return storage.Delete(ctx, key)
+}
+
+// Block rejects requests of key for duration on this instance and returns
+// when the block ends
+func (l *Limiter) Block(key string, duration time.Duration) time.Time {
+	until := time.Now().Add(duration)
+	l.blocks.block(key, until)
+	return until
+}
+
+// Unblock lifts the block of key and reports whether one was active
+func (l *Limiter) Unblock(key string) bool {
+	return l.blocks.unblock(key)
+}
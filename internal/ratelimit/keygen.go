@@ -1,32 +1,68 @@
 package ratelimit
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
+	"net/netip"
 	"strings"
 
 	"github.com/maltehedderich/api-gateway-go/internal/auth"
+	"github.com/maltehedderich/api-gateway-go/internal/identity"
 )
 
 // KeyGenerator generates rate limit keys from HTTP requests.
 // Keys are used to identify unique rate limit counters.
 type KeyGenerator struct {
 	keyTemplate string
+	ipv4Prefix  int
+	ipv6Prefix  int
 }
 
 // NewKeyGenerator creates a new key generator with the specified template.
 // Supported templates:
 //   - "ip" - rate limit by client IP address
 //   - "user" - rate limit by authenticated user ID
+//   - "session" - rate limit by session of the authenticated user, so one
+//     session can be throttled without affecting the user's other sessions
 //   - "route" - rate limit by request path
 //   - "user:route" - composite key by user and route
 //   - "ip:route" - composite key by IP and route
+//   - "service" - rate limit by calling service (internal listener only)
+//   - "method" - rate limit by HTTP method
+//   - "api_key" - rate limit by authenticated API key ID
+//   - "header:<name>" - rate limit by a request header; values are hashed so
+//     credentials such as raw API keys never end up in storage keys
+//   - "claim:<name>" - rate limit by a token claim, such as tenant_id
+//
+// Parts can be composed freely with ":", e.g. "claim:tenant_id:route" or
+// "ip:route:method". A request lacking any part of the template gets no key.
+//
+// IP keys use the full client address unless WithIPPrefixes is used. On the
+// internal listener they use the calling service instead, since the
+// addresses of service instances come and go.
 func NewKeyGenerator(keyTemplate string) *KeyGenerator {
 	return &KeyGenerator{
 		keyTemplate: keyTemplate,
+		ipv4Prefix:  32,
+		ipv6Prefix:  128,
 	}
 }
 
+// WithIPPrefixes aggregates client addresses into networks of the given
+// prefix lengths, e.g. 24 and 64, so all addresses of a network share one
+// counter. Lengths outside the address size are ignored.
+func (kg *KeyGenerator) WithIPPrefixes(ipv4, ipv6 int) *KeyGenerator {
+	if ipv4 > 0 && ipv4 <= 32 {
+		kg.ipv4Prefix = ipv4
+	}
+	if ipv6 > 0 && ipv6 <= 128 {
+		kg.ipv6Prefix = ipv6
+	}
+	return kg
+}
+
 // GenerateKey generates a rate limit key from the HTTP request.
 // Returns the key string and a boolean indicating if the key could be generated.
 // If the key cannot be generated (e.g., user template but no auth), returns false.
@@ -34,27 +70,81 @@ func (kg *KeyGenerator) GenerateKey(r *http.Request) (string, bool) {
 	parts := strings.Split(kg.keyTemplate, ":")
 	keyParts := make([]string, 0, len(parts))
 
-	for _, part := range parts {
-		switch strings.TrimSpace(part) {
+	for i := 0; i < len(parts); i++ {
+		switch strings.TrimSpace(parts[i]) {
 		case "ip":
-			ip := kg.getClientIP(r)
+			if service := identity.Service(r); service != "" {
+				keyParts = append(keyParts, fmt.Sprintf("service:%s", service))
+				continue
+			}
+			ip := identity.ClientIP(r)
 			if ip == "" {
 				return "", false
 			}
-			keyParts = append(keyParts, fmt.Sprintf("ip:%s", ip))
+			keyParts = append(keyParts, fmt.Sprintf("ip:%s", kg.clientNetwork(ip)))
 
 		case "user":
-			userID := kg.getUserID(r)
+			userID := identity.UserID(r)
 			if userID == "" {
 				// No authenticated user - cannot generate user-based key
 				return "", false
 			}
 			keyParts = append(keyParts, fmt.Sprintf("user:%s", userID))
 
+		case "session":
+			sessionID := identity.SessionID(r)
+			if sessionID == "" {
+				// Credential without a session - cannot generate session-based key
+				return "", false
+			}
+			keyParts = append(keyParts, fmt.Sprintf("session:%s", sessionID))
+
+		case "service":
+			service := identity.Service(r)
+			if service == "" {
+				return "", false
+			}
+			keyParts = append(keyParts, fmt.Sprintf("service:%s", service))
+
 		case "route":
 			route := kg.getRoute(r)
 			keyParts = append(keyParts, fmt.Sprintf("route:%s", route))
 
+		case "method":
+			keyParts = append(keyParts, fmt.Sprintf("method:%s", r.Method))
+
+		case "api_key":
+			keyID := apiKeyID(r)
+			if keyID == "" {
+				return "", false
+			}
+			keyParts = append(keyParts, fmt.Sprintf("api_key:%s", keyID))
+
+		case "header":
+			i++
+			if i >= len(parts) || strings.TrimSpace(parts[i]) == "" {
+				return "", false
+			}
+			name := http.CanonicalHeaderKey(strings.TrimSpace(parts[i]))
+			value := r.Header.Get(name)
+			if value == "" {
+				return "", false
+			}
+			sum := sha256.Sum256([]byte(value))
+			keyParts = append(keyParts, fmt.Sprintf("header:%s=%s", name, hex.EncodeToString(sum[:16])))
+
+		case "claim":
+			i++
+			if i >= len(parts) || strings.TrimSpace(parts[i]) == "" {
+				return "", false
+			}
+			name := strings.TrimSpace(parts[i])
+			value := claimValue(r, name)
+			if value == "" {
+				return "", false
+			}
+			keyParts = append(keyParts, fmt.Sprintf("claim:%s=%s", name, value))
+
 		default:
 			// Unknown template part - skip
 			continue
@@ -70,41 +160,80 @@ func (kg *KeyGenerator) GenerateKey(r *http.Request) (string, bool) {
 	return key, true
 }
 
-// getClientIP extracts the client IP address from the request.
-// It checks X-Forwarded-For, X-Real-IP headers before falling back to RemoteAddr.
-func (kg *KeyGenerator) getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header (may contain multiple IPs)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// Take the first IP (original client)
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
+// apiKeyID returns the ID of the API key that authenticated the request, or
+// "" for other requests
+func apiKeyID(r *http.Request) string {
+	if identity.Attribute(r, "auth_method") != "api_key" {
+		return ""
 	}
+	return identity.UserID(r)
+}
 
-	// Check X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return strings.TrimSpace(xri)
+// claimValue returns a claim of the authenticated user's token, including
+// custom claims such as tenant_id, or "" if unavailable
+func claimValue(r *http.Request, name string) string {
+	if value := identity.Claim(r, name); value != "" {
+		return value
+	}
+	user, ok := auth.GetUserContext(r.Context())
+	if !ok || user == nil || user.Claims == nil {
+		return ""
 	}
+	value, ok := user.Claims.Claim(name)
+	if !ok || value == nil {
+		return ""
+	}
+	switch value := value.(type) {
+	case string:
+		return value
+	case []interface{}:
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(value)
+	}
+}
 
-	// Fall back to RemoteAddr
-	// RemoteAddr format is "IP:port", we only want the IP
-	addr := r.RemoteAddr
-	if idx := strings.LastIndex(addr, ":"); idx != -1 {
-		return addr[:idx]
+// clientNetwork returns the network of a client address at the configured
+// prefix length, such as "2001:db8:1:2::/64". Addresses kept in full are
+// returned without a prefix length, so keys stay stable when aggregation is
+// not in use.
+func (kg *KeyGenerator) clientNetwork(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
 	}
+	addr = addr.Unmap()
 
-	return addr
+	bits := kg.ipv6Prefix
+	if addr.Is4() {
+		bits = kg.ipv4Prefix
+	}
+	if bits >= addr.BitLen() {
+		return addr.String()
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return addr.String()
+	}
+	return prefix.String()
 }
 
-// getUserID extracts the user ID from the request context.
-// Returns empty string if no authenticated user is present.
-func (kg *KeyGenerator) getUserID(r *http.Request) string {
-	userCtx, ok := auth.GetUserContext(r.Context())
-	if !ok {
-		return ""
+// usesSession reports whether a key template includes the session
+func usesSession(keyTemplate string) bool {
+	parts := strings.Split(keyTemplate, ":")
+	for i := 0; i < len(parts); i++ {
+		switch strings.TrimSpace(parts[i]) {
+		case "session":
+			return true
+		case "header", "claim":
+			i++ // skip the name, which may be "session"
+		}
 	}
-	return userCtx.UserID
+	return false
 }
 
 // getRoute extracts the request path (route) from the request.
@@ -2,12 +2,22 @@ package ratelimit
 
 import (
 	"context"
+	"net"
+	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/maltehedderich/api-gateway-go/internal/auth"
+	"github.com/maltehedderich/api-gateway-go/internal/clientip"
 )
 
+// resolveClient runs req through a client IP resolver trusting 10.0.0.0/8
+func resolveClient(req *http.Request) *http.Request {
+	_, trusted, _ := net.ParseCIDR("10.0.0.0/8")
+	info := clientip.NewResolver([]*net.IPNet{trusted}).Resolve(req)
+	return req.WithContext(clientip.WithInfo(req.Context(), info))
+}
+
 func TestKeyGenerator_GenerateKey_IP(t *testing.T) {
 	kg := NewKeyGenerator("ip")
 
@@ -32,14 +42,21 @@ func TestKeyGenerator_GenerateKey_XForwardedFor(t *testing.T) {
 	req.RemoteAddr = "10.0.0.1:12345"
 	req.Header.Set("X-Forwarded-For", "203.0.113.1, 198.51.100.1")
 
+	// Without a resolved client the header is not trusted
 	key, ok := kg.GenerateKey(req)
 	if !ok {
 		t.Fatal("expected key generation to succeed")
 	}
+	if expectedKey := "ratelimit:ip:10.0.0.1"; key != expectedKey {
+		t.Errorf("expected key %s, got %s", expectedKey, key)
+	}
 
-	// Should use first IP from X-Forwarded-For
-	expectedKey := "ratelimit:ip:203.0.113.1"
-	if key != expectedKey {
+	// Should use the last untrusted IP from X-Forwarded-For
+	key, ok = kg.GenerateKey(resolveClient(req))
+	if !ok {
+		t.Fatal("expected key generation to succeed")
+	}
+	if expectedKey := "ratelimit:ip:198.51.100.1"; key != expectedKey {
 		t.Errorf("expected key %s, got %s", expectedKey, key)
 	}
 }
@@ -51,7 +68,7 @@ func TestKeyGenerator_GenerateKey_XRealIP(t *testing.T) {
 	req.RemoteAddr = "10.0.0.1:12345"
 	req.Header.Set("X-Real-IP", "203.0.113.5")
 
-	key, ok := kg.GenerateKey(req)
+	key, ok := kg.GenerateKey(resolveClient(req))
 	if !ok {
 		t.Fatal("expected key generation to succeed")
 	}
@@ -99,6 +116,60 @@ func TestKeyGenerator_GenerateKey_UserNoAuth(t *testing.T) {
 	}
 }
 
+func TestKeyGenerator_GenerateKey_Session(t *testing.T) {
+	kg := NewKeyGenerator("session")
+
+	userCtx := &auth.UserContext{UserID: "user123", SessionID: "session456"}
+	req := httptest.NewRequest("GET", "/test", nil)
+	req = req.WithContext(auth.SetUserContext(context.Background(), userCtx))
+
+	key, ok := kg.GenerateKey(req)
+	if !ok {
+		t.Fatal("expected key generation to succeed")
+	}
+	if expectedKey := "ratelimit:session:session456"; key != expectedKey {
+		t.Errorf("expected key %s, got %s", expectedKey, key)
+	}
+
+	// Credentials without a session, such as API keys, get no session key
+	req = req.WithContext(auth.SetUserContext(context.Background(), &auth.UserContext{UserID: "user123"}))
+	if key, ok := kg.GenerateKey(req); ok {
+		t.Errorf("expected key generation to fail without a session, got key: %s", key)
+	}
+}
+
+func TestKeyGenerator_GenerateKey_Service(t *testing.T) {
+	service := "spiffe://mesh.example/ns/shop/sa/checkout"
+
+	tests := []struct {
+		name        string
+		template    string
+		service     string
+		expectedKey string
+		expectedOK  bool
+	}{
+		{"service", "service", service, "ratelimit:service:" + service, true},
+		{"no service", "service", "", "", false},
+		{"ip keyed by service", "ip:route", service, "ratelimit:service:" + service + ":route:/test", true},
+		{"ip without service", "ip", "", "ratelimit:ip:192.168.1.100", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.RemoteAddr = "192.168.1.100:12345"
+			if tt.service != "" {
+				req = req.WithContext(auth.SetServiceIdentity(req.Context(), tt.service))
+			}
+
+			key, ok := NewKeyGenerator(tt.template).GenerateKey(req)
+			if ok != tt.expectedOK || key != tt.expectedKey {
+				t.Errorf("GenerateKey() = %q, %v; expected %q, %v", key, ok, tt.expectedKey, tt.expectedOK)
+			}
+		})
+	}
+}
+
 func TestKeyGenerator_GenerateKey_Route(t *testing.T) {
 	kg := NewKeyGenerator("route")
 
@@ -156,6 +227,66 @@ func TestKeyGenerator_GenerateKey_Composite_IPRoute(t *testing.T) {
 	}
 }
 
+func TestKeyGenerator_GenerateKey_Templates(t *testing.T) {
+	tenantUser := &auth.UserContext{
+		UserID: "user1",
+		Claims: &auth.Claims{Extra: map[string]interface{}{"tenant_id": "acme", "groups": []interface{}{"a", "b"}}},
+	}
+	apiKeyUser := &auth.UserContext{UserID: "reporting", Attributes: map[string]interface{}{"auth_method": "api_key"}}
+
+	tests := []struct {
+		name        string
+		template    string
+		user        *auth.UserContext
+		headers     map[string]string
+		expectedKey string
+		expectOK    bool
+	}{
+		{"claim", "claim:tenant_id", tenantUser, nil, "ratelimit:claim:tenant_id=acme", true},
+		{"list claim", "claim:groups", tenantUser, nil, "ratelimit:claim:groups=a,b", true},
+		{"standard claim", "claim:user_id", tenantUser, nil, "ratelimit:claim:user_id=user1", true},
+		{"claim and route", "claim:tenant_id:route:method", tenantUser, nil, "ratelimit:claim:tenant_id=acme:route:/api/orders:method:POST", true},
+		{"missing claim", "claim:region", tenantUser, nil, "", false},
+		{"claim without name", "claim", tenantUser, nil, "", false},
+		{"anonymous claim", "claim:tenant_id", nil, nil, "", false},
+		{"api key", "api_key", apiKeyUser, nil, "ratelimit:api_key:reporting", true},
+		{"user is no api key", "api_key", tenantUser, nil, "", false},
+		{
+			name:        "header value is hashed",
+			template:    "header:x-api-key",
+			headers:     map[string]string{"X-Api-Key": "gk_secret"},
+			expectedKey: "ratelimit:header:X-Api-Key=d38efb589afe2585c06ba058b0a992e0",
+			expectOK:    true,
+		},
+		{"missing header", "header:X-Api-Key", nil, nil, "", false},
+		{"header named session", "header:session:method", nil, map[string]string{"Session": "s1"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/orders", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			if tt.user != nil {
+				req = req.WithContext(auth.SetUserContext(req.Context(), tt.user))
+			}
+
+			key, ok := NewKeyGenerator(tt.template).GenerateKey(req)
+			if ok != tt.expectOK {
+				t.Fatalf("expected ok %v, got %v (key %q)", tt.expectOK, ok, key)
+			}
+			if tt.expectedKey != "" && key != tt.expectedKey {
+				t.Errorf("expected key %s, got %s", tt.expectedKey, key)
+			}
+		})
+	}
+
+	if usesSession("header:session:method") || !usesSession("claim:tenant_id:session") {
+		t.Error("expected usesSession to skip header and claim names")
+	}
+}
+
 func TestKeyGenerator_GenerateKey_InvalidTemplate(t *testing.T) {
 	kg := NewKeyGenerator("invalid")
 
@@ -201,7 +332,17 @@ func TestKeyGenerator_GetClientIP_RemoteAddr(t *testing.T) {
 		{
 			name:       "IPv6 with port",
 			remoteAddr: "[2001:db8::1]:8080",
-			expectedIP: "[2001:db8::1]",
+			expectedIP: "2001:db8::1",
+		},
+		{
+			name:       "IPv6 in non-canonical form",
+			remoteAddr: "[2001:DB8:0::1]:8080",
+			expectedIP: "2001:db8::1",
+		},
+		{
+			name:       "IPv4-mapped IPv6",
+			remoteAddr: "[::ffff:192.168.1.100]:8080",
+			expectedIP: "192.168.1.100",
 		},
 	}
 
@@ -210,9 +351,42 @@ func TestKeyGenerator_GetClientIP_RemoteAddr(t *testing.T) {
 			req := httptest.NewRequest("GET", "/test", nil)
 			req.RemoteAddr = tt.remoteAddr
 
-			ip := kg.getClientIP(req)
-			if ip != tt.expectedIP {
-				t.Errorf("expected IP %s, got %s", tt.expectedIP, ip)
+			key, ok := kg.GenerateKey(req)
+			if !ok || key != "ratelimit:ip:"+tt.expectedIP {
+				t.Errorf("expected key for IP %s, got %s", tt.expectedIP, key)
+			}
+		})
+	}
+}
+
+func TestKeyGenerator_GenerateKey_IPPrefixes(t *testing.T) {
+	kg := NewKeyGenerator("ip").WithIPPrefixes(24, 64)
+
+	tests := []struct {
+		name        string
+		remoteAddr  string
+		realIP      string
+		expectedKey string
+	}{
+		{"IPv4 network", "192.168.1.100:1234", "", "ratelimit:ip:192.168.1.0/24"},
+		{"IPv4-mapped IPv6", "[::ffff:192.168.1.7]:1234", "", "ratelimit:ip:192.168.1.0/24"},
+		{"IPv6 network", "[2001:db8:1:2:aaaa::1]:1234", "", "ratelimit:ip:2001:db8:1:2::/64"},
+		{"other address in same IPv6 network", "[2001:db8:1:2:bbbb::99]:1234", "", "ratelimit:ip:2001:db8:1:2::/64"},
+		{"bracketed X-Real-IP", "10.0.0.1:1234", "[2001:DB8:1:2::5]", "ratelimit:ip:2001:db8:1:2::/64"},
+		{"X-Real-IP with zone", "10.0.0.1:1234", "fe80::1%eth0", "ratelimit:ip:fe80::/64"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			key, ok := kg.GenerateKey(resolveClient(req))
+			if !ok || key != tt.expectedKey {
+				t.Errorf("expected key %s, got %s", tt.expectedKey, key)
 			}
 		})
 	}
@@ -2,11 +2,16 @@ package ratelimit
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/identity"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
 )
 
 // Limiter is the main rate limiting component that coordinates
@@ -14,6 +19,28 @@ import (
 type Limiter struct {
 	storage     Storage
 	failureMode string // "fail-open" or "fail-closed"
+	ipv4Prefix  int
+	ipv6Prefix  int
+
+	// abuse tightens the session limits of abusive sessions; nil if disabled
+	abuse *abuseTracker
+	// quotas counts requests against daily or monthly budgets; nil if disabled
+	quotas *QuotaManager
+	// adaptive scales limits of unhealthy routes down; nil if disabled
+	adaptive *adaptiveController
+	// concurrency counts requests in flight for concurrency limits
+	concurrency *concurrencyTracker
+	// blocks holds keys blocked through the admin API
+	blocks blockList
+	// queues holds requests waiting under the queue policy
+	queues shapingQueues
+	// windows counts requests of fixed window limits
+	windows FixedWindowCounters
+	// degraded counts requests locally while the storage is unreachable;
+	// nil if disabled
+	degraded *degradedMode
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
 }
 
 // NewLimiter creates a new rate limiter with the specified configuration.
@@ -38,17 +65,59 @@ func NewLimiter(cfg *config.RateLimitConfig) (*Limiter, error) {
 		return nil, fmt.Errorf("unsupported storage backend: %s", cfg.Backend)
 	}
 
-	return &Limiter{
+	l := &Limiter{
 		storage:     storage,
 		failureMode: cfg.FailureMode,
-	}, nil
+		ipv4Prefix:  cfg.IPv4PrefixLength,
+		ipv6Prefix:  cfg.IPv6PrefixLength,
+		concurrency: newConcurrencyTracker(),
+		stopCh:      make(chan struct{}),
+	}
+
+	l.wg.Add(1)
+	go l.rolloverLoop()
+
+	if cfg.Quota.Enabled {
+		l.quotas, err = NewQuotaManager(&cfg.Quota)
+		if err != nil {
+			_ = storage.Close()
+			return nil, err
+		}
+	}
+
+	// Only a shared backend can become unreachable
+	if cfg.Degraded.Enabled && cfg.Backend == "redis" {
+		l.degraded = newDegradedMode(cfg.Degraded)
+		l.wg.Add(1)
+		go l.degradedLoop(cfg.Degraded.CheckInterval)
+	}
+
+	if cfg.Adaptive.Enabled {
+		l.adaptive = newAdaptiveController(cfg.Adaptive)
+		l.wg.Add(1)
+		go l.adaptiveLoop(cfg.Adaptive.Interval)
+	}
+
+	if cfg.SessionAbuse.Enabled {
+		l.abuse = newAbuseTracker(cfg.SessionAbuse)
+		l.wg.Add(1)
+		go l.cleanupLoop()
+	}
+
+	return l, nil
 }
 
 // Allow checks if a request is allowed based on the rate limit.
 // It returns a Result indicating whether the request is allowed and rate limit metadata.
 func (l *Limiter) Allow(ctx context.Context, r *http.Request, limitDef *config.LimitDefinition) (*Result, error) {
+	return l.AllowN(ctx, r, limitDef, 1)
+}
+
+// AllowN checks if a request costing n tokens, such as a request of an
+// expensive route, is allowed based on the rate limit.
+func (l *Limiter) AllowN(ctx context.Context, r *http.Request, limitDef *config.LimitDefinition, n int) (*Result, error) {
 	// Generate rate limit key
-	keyGen := NewKeyGenerator(limitDef.Key)
+	keyGen := NewKeyGenerator(limitDef.Key).WithIPPrefixes(l.ipv4Prefix, l.ipv6Prefix)
 	key, ok := keyGen.GenerateKey(r)
 	if !ok {
 		// Could not generate key (e.g., user-based limit but no auth)
@@ -61,76 +130,268 @@ func (l *Limiter) Allow(ctx context.Context, r *http.Request, limitDef *config.L
 		}, nil
 	}
 
+	// Keys blocked through the admin API are rejected until the block ends
+	if until := l.blocks.blockedUntil(key); !until.IsZero() {
+		return newResult(false, limitDef.Limit, 0, until, until, time.Now()), nil
+	}
+
+	// Abusive sessions get a fraction of their session limits
+	limitDef = l.tighten(r, limitDef)
+
 	// Parse window duration
 	window, err := time.ParseDuration(limitDef.Window)
 	if err != nil {
 		return nil, fmt.Errorf("invalid window duration: %w", err)
 	}
 
-	// Calculate refill rate (tokens per second)
-	refillRate := float64(limitDef.Limit) / window.Seconds()
+	// Fixed windows are counted in the memory of this instance, whatever
+	// the backend
+	if limitDef.Algorithm == "fixed_window" {
+		now := time.Now()
+		allowed, remaining, reset := l.windows.AllowN(key, limitDef.Limit, window, n, now)
+		return newResult(allowed, limitDef.Limit, remaining, reset, reset, now), nil
+	}
 
-	// Determine burst capacity (use Burst if set, otherwise use Limit)
-	capacity := limitDef.Burst
-	if capacity == 0 {
-		capacity = limitDef.Limit
+	storage, applied := l.backend(limitDef)
+	result, err := l.check(ctx, storage, key, applied, window, n)
+	if err != nil && l.degrade(storage, err) {
+		storage, applied = l.backend(limitDef)
+		result, err = l.check(ctx, storage, key, applied, window, n)
+	}
+	if err != nil {
+		return l.storageFailure(limitDef, window, err)
+	}
+	return result, nil
+}
+
+// check checks a request costing n against the limit state of key in storage
+func (l *Limiter) check(ctx context.Context, storage Storage, key string, limitDef *config.LimitDefinition, window time.Duration, n int) (*Result, error) {
+	switch limitDef.Algorithm {
+	case "sliding_window":
+		return allowSlidingWindow(ctx, storage, key, limitDef, window, n)
+	case "gcra":
+		return allowGCRA(ctx, storage, key, limitDef, window, n)
+	}
+
+	capacity, refillRate := tokenBucketParams(limitDef, window)
+
+	// Take the tokens in one step where the backend supports it, so
+	// concurrent gateway instances cannot both spend the last token
+	if atomic, ok := storage.(AtomicStorage); ok {
+		allowed, tokens, err := atomic.TakeTokens(ctx, key, capacity, refillRate, n, window*2)
+		if err != nil {
+			return nil, err
+		}
+		bucket := NewTokenBucketFromState(capacity, refillRate, tokens, time.Now())
+		reset := bucket.Reset()
+		return newResult(allowed, limitDef.Limit, bucket.Remaining(), reset, reset, time.Now()), nil
 	}
 
 	// Get or create token bucket
-	bucket, err := l.getBucket(ctx, key, capacity, refillRate, window)
+	bucket, err := getBucket(ctx, storage, key, capacity, refillRate, window)
 	if err != nil {
-		// Storage error - apply failure mode
-		if l.failureMode == "fail-open" {
-			// Allow request on storage failure
-			return &Result{
-				Allowed:   true,
-				Limit:     limitDef.Limit,
-				Remaining: limitDef.Limit,
-				Reset:     time.Now(),
-			}, nil
-		}
-		// fail-closed: reject request on storage failure
-		return &Result{
-			Allowed:    false,
-			Limit:      limitDef.Limit,
-			Remaining:  0,
-			Reset:      time.Now().Add(window),
-			RetryAfter: window,
-		}, err
+		return nil, err
 	}
 
-	// Check if request is allowed (consumes 1 token)
-	allowed := bucket.Allow(1)
+	// Check if request is allowed (consumes n tokens)
+	allowed := bucket.Allow(n)
 	remaining := bucket.Remaining()
 	reset := bucket.Reset()
 
 	// Save updated bucket state
 	state := bucket.GetState()
-	_ = l.storage.Set(ctx, key, &state, window*2)
+	_ = storage.Set(ctx, key, &state, window*2)
 	// Ignore storage error - the request decision has already been made
 	// and we don't want to fail the request due to storage issues
 
+	return newResult(allowed, limitDef.Limit, remaining, reset, reset, time.Now()), nil
+}
+
+// Charge takes up to n more tokens from the limit of an admitted request,
+// such as the part of its cost reported by the backend, but no more than
+// the limit has left. Requests without a key for the limit are not charged.
+func (l *Limiter) Charge(ctx context.Context, r *http.Request, limitDef *config.LimitDefinition, n int) error {
+	key, ok := NewKeyGenerator(limitDef.Key).WithIPPrefixes(l.ipv4Prefix, l.ipv6Prefix).GenerateKey(r)
+	if !ok || n <= 0 {
+		return nil
+	}
+	limitDef = l.tighten(r, limitDef)
+	window, err := time.ParseDuration(limitDef.Window)
+	if err != nil {
+		return fmt.Errorf("invalid window duration: %w", err)
+	}
+
+	if limitDef.Algorithm == "fixed_window" {
+		l.windows.Charge(key, limitDef.Limit, window, n, time.Now())
+		return nil
+	}
+
+	storage, applied := l.backend(limitDef)
+	err = charge(ctx, storage, key, applied, window, n)
+	if err != nil && l.degrade(storage, err) {
+		storage, applied = l.backend(limitDef)
+		err = charge(ctx, storage, key, applied, window, n)
+	}
+	return err
+}
+
+// charge takes up to n tokens from the limit state of key in storage
+func charge(ctx context.Context, storage Storage, key string, limitDef *config.LimitDefinition, window time.Duration, n int) error {
+	if limitDef.Algorithm == "" || limitDef.Algorithm == "token_bucket" {
+		capacity, refillRate := tokenBucketParams(limitDef, window)
+		if atomic, ok := storage.(AtomicStorage); ok {
+			taken, tokens, err := atomic.TakeTokens(ctx, key, capacity, refillRate, n, window*2)
+			if err == nil && !taken && tokens >= 1 {
+				_, _, err = atomic.TakeTokens(ctx, key, capacity, refillRate, int(tokens), window*2)
+			}
+			return err
+		}
+		bucket, err := getBucket(ctx, storage, key, capacity, refillRate, window)
+		if err != nil {
+			return err
+		}
+		bucket.Allow(min(n, bucket.Remaining()))
+		state := bucket.GetState()
+		return storage.Set(ctx, key, &state, window*2)
+	}
+
+	state, _, err := storage.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to get rate limit state: %w", err)
+	}
+	if state == nil {
+		state = &BucketState{}
+	}
+	now := time.Now()
+	ttl := window
+	if limitDef.Algorithm == "gcra" {
+		gcra := NewGCRA(limitDef.Limit, window, limitDef.Burst, state.TAT)
+		gcra.Charge(now, n)
+		state.TAT = gcra.TAT
+		ttl += gcra.TAT.Sub(now)
+	} else {
+		log := NewSlidingWindowLog(limitDef.Limit, window, state.Log)
+		log.Charge(now, n)
+		state.Log = log.Log
+	}
+	return storage.Set(ctx, key, state, ttl)
+}
+
+// tighten returns the limit applying to the request: abusive sessions get a
+// fraction of their session limits
+func (l *Limiter) tighten(r *http.Request, limitDef *config.LimitDefinition) *config.LimitDefinition {
+	if l.abuse == nil || !usesSession(limitDef.Key) || !l.abuse.tightened(identity.SessionID(r)) {
+		return limitDef
+	}
+	tightened := *limitDef
+	tightened.Limit = l.abuse.tighten(limitDef.Limit)
+	if tightened.Burst > 0 {
+		tightened.Burst = l.abuse.tighten(limitDef.Burst)
+	}
+	return &tightened
+}
+
+// tokenBucketParams returns the capacity and refill rate per second of the
+// token bucket of a limit. The capacity is the burst if set, otherwise the
+// limit.
+func tokenBucketParams(limitDef *config.LimitDefinition, window time.Duration) (int, float64) {
+	capacity := limitDef.Burst
+	if capacity == 0 {
+		capacity = limitDef.Limit
+	}
+	return capacity, float64(limitDef.Limit) / window.Seconds()
+}
+
+// allowSlidingWindow checks a request against a sliding window log limit.
+func allowSlidingWindow(ctx context.Context, storage Storage, key string, limitDef *config.LimitDefinition, window time.Duration, n int) (*Result, error) {
+	state, _, err := storage.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sliding window state: %w", err)
+	}
+	if state == nil {
+		state = &BucketState{}
+	}
+
+	now := time.Now()
+	log := NewSlidingWindowLog(limitDef.Limit, window, state.Log)
+	allowed := log.AllowN(now, n)
+	if allowed {
+		state.Log = log.Log
+		if err := storage.Set(ctx, key, state, window); err != nil {
+			return nil, fmt.Errorf("failed to save sliding window state: %w", err)
+		}
+	}
+
+	return newResult(allowed, limitDef.Limit, log.Remaining(now), log.Reset(now), log.RetryAtN(now, n), now), nil
+}
+
+// allowGCRA checks a request against a GCRA limit.
+func allowGCRA(ctx context.Context, storage Storage, key string, limitDef *config.LimitDefinition, window time.Duration, n int) (*Result, error) {
+	state, _, err := storage.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GCRA state: %w", err)
+	}
+	if state == nil {
+		state = &BucketState{}
+	}
+
+	now := time.Now()
+	gcra := NewGCRA(limitDef.Limit, window, limitDef.Burst, state.TAT)
+	allowed := gcra.AllowN(now, n)
+	if allowed {
+		state.TAT = gcra.TAT
+		if err := storage.Set(ctx, key, state, gcra.TAT.Sub(now)+window); err != nil {
+			return nil, fmt.Errorf("failed to save GCRA state: %w", err)
+		}
+	}
+
+	return newResult(allowed, limitDef.Limit, gcra.Remaining(now), gcra.Reset(now), gcra.RetryAtN(now, n), now), nil
+}
+
+// newResult builds the result of a rate limit check, with the time to wait
+// for the next allowed request if this one was rejected.
+func newResult(allowed bool, limit, remaining int, reset, retryAt, now time.Time) *Result {
 	result := &Result{
 		Allowed:   allowed,
-		Limit:     limitDef.Limit,
+		Limit:     limit,
 		Remaining: remaining,
 		Reset:     reset,
 	}
-
 	if !allowed {
-		result.RetryAfter = time.Until(reset)
+		result.RetryAfter = retryAt.Sub(now)
 		if result.RetryAfter < 0 {
 			result.RetryAfter = 0
 		}
 	}
+	return result
+}
 
-	return result, nil
+// storageFailure applies the failure mode to a request whose limit state
+// could not be read or written.
+func (l *Limiter) storageFailure(limitDef *config.LimitDefinition, window time.Duration, err error) (*Result, error) {
+	if l.failureMode == "fail-open" {
+		// Allow request on storage failure
+		return &Result{
+			Allowed:   true,
+			Limit:     limitDef.Limit,
+			Remaining: limitDef.Limit,
+			Reset:     time.Now(),
+		}, nil
+	}
+	// fail-closed: reject request on storage failure
+	return &Result{
+		Allowed:    false,
+		Limit:      limitDef.Limit,
+		Remaining:  0,
+		Reset:      time.Now().Add(window),
+		RetryAfter: window,
+	}, err
 }
 
 // getBucket retrieves or creates a token bucket for the given key.
-func (l *Limiter) getBucket(ctx context.Context, key string, capacity int, refillRate float64, window time.Duration) (*TokenBucket, error) {
+func getBucket(ctx context.Context, storage Storage, key string, capacity int, refillRate float64, window time.Duration) (*TokenBucket, error) {
 	// Try to get existing bucket state
-	state, exists, err := l.storage.Get(ctx, key)
+	state, exists, err := storage.Get(ctx, key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get bucket state: %w", err)
 	}
@@ -145,15 +406,108 @@ func (l *Limiter) getBucket(ctx context.Context, key string, capacity int, refil
 
 	// Save initial state
 	initialState := bucket.GetState()
-	if err := l.storage.Set(ctx, key, &initialState, window*2); err != nil {
+	if err := storage.Set(ctx, key, &initialState, window*2); err != nil {
 		return nil, fmt.Errorf("failed to save bucket state: %w", err)
 	}
 
 	return bucket, nil
 }
 
+// recordResponse counts the response status of a request towards the abuse
+// threshold of its session
+func (l *Limiter) recordResponse(r *http.Request, status int) {
+	sessionID := identity.SessionID(r)
+	if !l.abuse.record(sessionID, status) {
+		return
+	}
+
+	logger.Get().WithComponent("ratelimit").Warn("session limits tightened after repeated client errors", logger.Fields{
+		"user_id":    identity.UserID(r),
+		"session_id": maskID(sessionID),
+		"penalty":    l.abuse.config.Penalty.String(),
+	})
+	metrics.RecordRateLimitSessionTightened()
+}
+
+// tracksSessions reports whether responses must be recorded for r
+func (l *Limiter) tracksSessions(r *http.Request) bool {
+	return l.abuse != nil && identity.SessionID(r) != ""
+}
+
+// cleanupLoop periodically forgets sessions without recent errors
+func (l *Limiter) cleanupLoop() {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.abuse.cleanup()
+		case <-l.stopCh:
+			return
+		}
+	}
+}
+
+// rolloverLoop periodically drops fixed window counters of past windows
+func (l *Limiter) rolloverLoop() {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(fixedWindowRollover)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.windows.Rollover(time.Now())
+		case <-l.stopCh:
+			return
+		}
+	}
+}
+
+// adaptiveLoop adjusts the adaptive factors of routes every interval
+func (l *Limiter) adaptiveLoop(interval time.Duration) {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.adaptive.evaluate()
+		case <-l.stopCh:
+			return
+		}
+	}
+}
+
+// maskID masks an identifier for logging (shows only last 4 characters)
+func maskID(id string) string {
+	if len(id) <= 4 {
+		return "****"
+	}
+	return "****" + id[len(id)-4:]
+}
+
+// Quotas returns the quota manager, or nil if quotas are disabled.
+func (l *Limiter) Quotas() *QuotaManager {
+	return l.quotas
+}
+
 // Close closes the limiter and releases resources.
 func (l *Limiter) Close() error {
+	close(l.stopCh)
+	l.wg.Wait()
+	if l.degraded != nil {
+		_ = l.degraded.local.Close()
+	}
+	if l.quotas != nil {
+		return errors.Join(l.storage.Close(), l.quotas.Close())
+	}
 	return l.storage.Close()
 }
 
@@ -2,6 +2,9 @@ package ratelimit
 
 import (
 	"context"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -55,6 +58,7 @@ func (ms *MemoryStorage) Get(ctx context.Context, key string) (*BucketState, boo
 
 	// Return a copy of the state
 	stateCopy := *entry.state
+	stateCopy.Log = slices.Clone(stateCopy.Log)
 	return &stateCopy, true, nil
 }
 
@@ -65,6 +69,7 @@ func (ms *MemoryStorage) Set(ctx context.Context, key string, state *BucketState
 
 	// Create a copy of the state
 	stateCopy := *state
+	stateCopy.Log = slices.Clone(stateCopy.Log)
 
 	ms.buckets[key] = &bucketEntry{
 		state:  &stateCopy,
@@ -74,6 +79,38 @@ func (ms *MemoryStorage) Set(ctx context.Context, key string, state *BucketState
 	return nil
 }
 
+// Entries returns up to limit unexpired states whose keys start with prefix,
+// ordered by key.
+func (ms *MemoryStorage) Entries(ctx context.Context, prefix string, limit int) ([]Entry, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	now := time.Now()
+	entries := make([]Entry, 0)
+	for key, entry := range ms.buckets {
+		if strings.HasPrefix(key, prefix) && !now.After(entry.expiry) {
+			state := *entry.state
+			state.Log = slices.Clone(state.Log)
+			entries = append(entries, Entry{Key: key, State: state, ExpiresAt: entry.expiry})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// Delete removes the state of key.
+func (ms *MemoryStorage) Delete(ctx context.Context, key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	delete(ms.buckets, key)
+	return nil
+}
+
 // Close stops the cleanup goroutine and releases resources.
 func (ms *MemoryStorage) Close() error {
 	close(ms.stopCh)
@@ -62,6 +62,37 @@ func TestMemoryStorage_GetSet(t *testing.T) {
 	}
 }
 
+func TestMemoryStorage_EntriesDelete(t *testing.T) {
+	ms := NewMemoryStorage()
+	defer func() { _ = ms.Close() }()
+
+	ctx := context.Background()
+	for _, key := range []string{"ratelimit:user:bob", "ratelimit:ip:10.0.0.1", "ratelimit:user:alice"} {
+		if err := ms.Set(ctx, key, &BucketState{Tokens: 1, LastRefill: time.Now()}, time.Minute); err != nil {
+			t.Fatalf("unexpected error setting key: %v", err)
+		}
+	}
+	_ = ms.Set(ctx, "ratelimit:user:expired", &BucketState{}, -time.Second)
+
+	entries, err := ms.Entries(ctx, "ratelimit:user:", 10)
+	if err != nil {
+		t.Fatalf("unexpected error listing entries: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Key != "ratelimit:user:alice" || entries[1].Key != "ratelimit:user:bob" {
+		t.Errorf("expected unexpired user keys in order, got %+v", entries)
+	}
+	if entries, _ := ms.Entries(ctx, "", 1); len(entries) != 1 {
+		t.Errorf("expected entries bounded by the limit, got %d", len(entries))
+	}
+
+	if err := ms.Delete(ctx, "ratelimit:user:alice"); err != nil {
+		t.Fatalf("unexpected error deleting key: %v", err)
+	}
+	if _, exists, _ := ms.Get(ctx, "ratelimit:user:alice"); exists {
+		t.Error("expected deleted key to not exist")
+	}
+}
+
 func TestMemoryStorage_TTL(t *testing.T) {
 	ms := NewMemoryStorage()
 	defer func() { _ = ms.Close() }()
@@ -1,15 +1,20 @@
 package ratelimit
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/maltehedderich/api-gateway-go/internal/auth"
 	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/identity"
 	"github.com/maltehedderich/api-gateway-go/internal/logger"
 	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+	"github.com/maltehedderich/api-gateway-go/internal/middleware"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
 )
 
 // Middleware creates a rate limiting middleware.
@@ -24,18 +29,37 @@ func Middleware(limiter *Limiter, cfg *config.Config) func(http.Handler) http.Ha
 				return
 			}
 
+			// Health checks and metrics scrapes must never be throttled
+			if cfg.Observability.IsExemptPath(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			log := logger.Get().WithComponent("ratelimit")
 
 			// Find applicable rate limits for this route
-			limits := getApplicableLimits(r, cfg)
+			route := matchedRoute(r)
+			limits := getApplicableLimits(r, cfg, route)
+			cost := requestCost(route)
 
 			// Check each limit
-			for _, limitDef := range limits {
+			for i, limitDef := range limits {
+				// Unhealthy routes get a fraction of their limits
+				if limiter.adaptive != nil && route != nil {
+					limitDef = limiter.adaptive.scale(route.PathPattern, limitDef)
+					limits[i] = limitDef
+				}
+
 				checkStart := time.Now()
-				result, err := limiter.Allow(r.Context(), r, &limitDef)
+				result, err := limiter.AllowN(r.Context(), r, &limitDef, cost)
 				metrics.RecordRateLimitCheckDuration(time.Since(checkStart))
 				metrics.RecordRateLimitCheck()
 
+				// Soft limits hold requests over the limit until admitted
+				if err == nil && !result.Allowed {
+					result, err = limiter.Shape(r.Context(), r, &limitDef, cost, result)
+				}
+
 				if err != nil {
 					log.Error("rate limit check failed", logger.Fields{
 						"error": err.Error(),
@@ -78,56 +102,148 @@ func Middleware(limiter *Limiter, cfg *config.Config) func(http.Handler) http.Ha
 				}
 			}
 
-			// All limits passed, continue to next handler
-			next.ServeHTTP(w, r)
+			// Within the short-window limits, the request counts against the
+			// caller's daily or monthly quota
+			if limiter.quotas != nil && !checkQuota(w, r, limiter.quotas, cfg, int64(cost)) {
+				return
+			}
+
+			// Long-running requests are also capped by how many may be in
+			// flight at once
+			if route != nil && len(route.ConcurrencyLimits) > 0 {
+				release, exceeded := limiter.AcquireConcurrency(r, route)
+				if exceeded != nil {
+					log.Warn("concurrency limit exceeded", logger.Fields{
+						"key":    exceeded.Key,
+						"max":    exceeded.Max,
+						"path":   r.URL.Path,
+						"method": r.Method,
+					})
+					metrics.RecordRateLimitConcurrencyExceeded(exceeded.Key, route.PathPattern)
+					writeConcurrencyError(w, r, exceeded)
+					return
+				}
+				defer release()
+			}
+
+			// All limits passed, continue to next handler. Responses to
+			// sessions are counted so abusive sessions get tightened limits,
+			// responses of routes feed adaptive rate limiting, and backends
+			// may report a higher cost than charged up front.
+			adapt := limiter.adaptive != nil && route != nil
+			reportsCost := route != nil && route.CostHeader != ""
+			if !limiter.tracksSessions(r) && !adapt && !reportsCost {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rw := middleware.NewResponseWriter(w)
+			start := time.Now()
+			next.ServeHTTP(rw, r)
+			if adapt {
+				limiter.adaptive.record(route.PathPattern, time.Since(start), rw.Status())
+			}
+			if limiter.tracksSessions(r) {
+				limiter.recordResponse(r, rw.Status())
+			}
+			if reportsCost {
+				chargeReportedCost(r, rw.Header().Get(route.CostHeader), limiter, limits, cost)
+			}
 		})
 	}
 }
 
+// Tiers applying to callers without a tier of their own
+const (
+	TierAuthenticated = "authenticated"
+	TierAnonymous     = "anonymous"
+)
+
 // getApplicableLimits returns the rate limits that apply to the request.
-// It checks both global limits and route-specific limits.
-func getApplicableLimits(r *http.Request, cfg *config.Config) []config.LimitDefinition {
+// It checks both global limits and the limits of the matched route, if any.
+// Callers in a configured tier, such as API keys, get the tier's limits
+// instead of the global ones.
+func getApplicableLimits(r *http.Request, cfg *config.Config, route *router.Route) []config.LimitDefinition {
 	limits := make([]config.LimitDefinition, 0)
 
-	// Add global limits
-	limits = append(limits, cfg.RateLimit.GlobalLimits...)
+	// Add global or tier limits
+	if tierLimits, ok := cfg.RateLimit.Tiers[callerTier(r)]; ok {
+		limits = append(limits, tierLimits...)
+	} else {
+		limits = append(limits, cfg.RateLimit.GlobalLimits...)
+	}
 
-	// Find matching route and add route-specific limits
-	for _, route := range cfg.Routes {
-		if routeMatches(r, &route) {
-			limits = append(limits, route.RateLimits...)
-			break
-		}
+	// Add route-specific limits
+	if route != nil {
+		limits = append(limits, route.RateLimits...)
 	}
 
 	return limits
 }
 
-// routeMatches checks if a request matches a route configuration.
-// This is a simple prefix match - in production, use the router's matching logic.
-func routeMatches(r *http.Request, route *config.RouteConfig) bool {
-	// Check if path matches (simple prefix match for now)
-	pathMatches := r.URL.Path == route.PathPattern ||
-		(len(route.PathPattern) > 0 && route.PathPattern[len(route.PathPattern)-1] == '*' &&
-			len(r.URL.Path) >= len(route.PathPattern)-1 &&
-			r.URL.Path[:len(route.PathPattern)-1] == route.PathPattern[:len(route.PathPattern)-1])
-
-	if !pathMatches {
-		return false
+// requestCost returns the tokens a request of route takes up front
+func requestCost(route *router.Route) int {
+	if route == nil || route.Cost == 0 {
+		return 1
 	}
+	return route.Cost
+}
 
-	// Check if method matches
-	if len(route.Methods) == 0 {
-		return true
+// chargeReportedCost charges the rate limits and quota of an admitted request
+// for the cost its backend reported beyond the cost taken up front
+func chargeReportedCost(r *http.Request, reported string, limiter *Limiter, limits []config.LimitDefinition, cost int) {
+	total, err := strconv.Atoi(reported)
+	if err != nil || total <= cost {
+		return
 	}
+	extra := total - cost
 
-	for _, method := range route.Methods {
-		if method == r.Method {
-			return true
+	// The client may be gone already; the cost was incurred regardless
+	ctx := context.WithoutCancel(r.Context())
+	log := logger.Get().WithComponent("ratelimit")
+	for i := range limits {
+		if err := limiter.Charge(ctx, r, &limits[i], extra); err != nil {
+			log.Error("failed to charge reported cost", logger.Fields{
+				"error": err.Error(),
+				"key":   limits[i].Key,
+				"cost":  total,
+			})
+			metrics.RecordRateLimitError("charge_failed")
 		}
 	}
 
-	return false
+	subject, tier := QuotaSubject(r), callerTier(r)
+	if limiter.quotas == nil || subject == "" || limiter.quotas.Limit(tier) == 0 {
+		return
+	}
+	if _, err := limiter.quotas.Adjust(ctx, subject, tier, nil, int64(extra)); err != nil {
+		log.Error("failed to charge reported cost to quota", logger.Fields{
+			"error":   err.Error(),
+			"subject": subject,
+			"cost":    total,
+		})
+		metrics.RecordRateLimitError("charge_failed")
+	}
+}
+
+// matchedRoute returns the route the router matched for the request, or nil
+// if the request matched no route
+func matchedRoute(r *http.Request) *router.Route {
+	if match, ok := middleware.RouteMatchFromContext(r.Context()).(*router.Match); ok && match != nil {
+		return match.Route
+	}
+	return nil
+}
+
+// callerTier returns the rate limit tier of the caller: its own tier, such as
+// the tier of its API key, or else the authenticated or anonymous tier
+func callerTier(r *http.Request) string {
+	if tier := identity.Attribute(r, auth.RateLimitTierAttribute); tier != "" {
+		return tier
+	}
+	if user, ok := auth.GetUserContext(r.Context()); ok && user != nil {
+		return TierAuthenticated
+	}
+	return TierAnonymous
 }
 
 // addRateLimitHeaders adds rate limit headers to the response.
@@ -142,6 +258,108 @@ func addRateLimitHeaders(w http.ResponseWriter, result *Result) {
 	}
 }
 
+// checkQuota counts the request, costing cost, against the quota of its
+// caller and adds quota headers. It writes the error response and returns
+// false if the quota is used up, or cannot be checked while failing closed.
+func checkQuota(w http.ResponseWriter, r *http.Request, quotas *QuotaManager, cfg *config.Config, cost int64) bool {
+	subject := QuotaSubject(r)
+	if subject == "" {
+		return true
+	}
+	tier := callerTier(r)
+
+	quota, allowed, err := quotas.Consume(r.Context(), subject, tier, cost)
+	if err != nil {
+		logger.Get().WithComponent("ratelimit").Error("quota check failed", logger.Fields{
+			"error":   err.Error(),
+			"subject": subject,
+			"path":    r.URL.Path,
+		})
+		metrics.RecordRateLimitError("quota_check_failed")
+		if cfg.RateLimit.FailureMode == "fail-closed" {
+			writeQuotaError(w, r, nil)
+			return false
+		}
+		return true
+	}
+	if quota == nil {
+		return true
+	}
+
+	w.Header().Set("X-Quota-Limit", strconv.FormatInt(quota.Limit, 10))
+	w.Header().Set("X-Quota-Remaining", strconv.FormatInt(quota.Remaining, 10))
+	w.Header().Set("X-Quota-Reset", strconv.FormatInt(quota.Reset.Unix(), 10))
+	if allowed {
+		return true
+	}
+
+	logger.Get().WithComponent("ratelimit").Warn("quota exceeded", logger.Fields{
+		"subject": subject,
+		"tier":    tier,
+		"limit":   quota.Limit,
+		"path":    r.URL.Path,
+		"method":  r.Method,
+	})
+	metrics.RecordRateLimitQuotaExceeded(tier)
+	writeQuotaError(w, r, quota)
+	return false
+}
+
+// writeQuotaError writes a 429 Too Many Requests response for a used up
+// quota. The quota is nil if it could not be checked.
+func writeQuotaError(w http.ResponseWriter, r *http.Request, quota *Quota) {
+	errorResp := map[string]interface{}{
+		"error":          "quota_exceeded",
+		"message":        "Request quota exceeded for this period",
+		"correlation_id": r.Header.Get("X-Correlation-ID"),
+		"timestamp":      time.Now().UTC().Format(time.RFC3339),
+		"path":           r.URL.Path,
+	}
+	if quota != nil {
+		retryAfter := int(time.Until(quota.Reset).Seconds())
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		errorResp["details"] = map[string]interface{}{
+			"limit":    quota.Limit,
+			"reset_at": quota.Reset.UTC().Format(time.RFC3339),
+		}
+		errorResp["retry_after"] = retryAfter
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	if err := json.NewEncoder(w).Encode(errorResp); err != nil {
+		_, _ = fmt.Fprintf(w, "Quota exceeded\n")
+	}
+}
+
+// writeConcurrencyError rejects a request over a concurrency limit with the
+// limit's status, 429 unless configured otherwise
+func writeConcurrencyError(w http.ResponseWriter, r *http.Request, limit *config.ConcurrencyLimit) {
+	status := limit.Status
+	if status == 0 {
+		status = http.StatusTooManyRequests
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", "1")
+	w.WriteHeader(status)
+
+	errorResp := map[string]interface{}{
+		"error":          "concurrency_limit_exceeded",
+		"message":        "Too many simultaneous requests for this resource",
+		"correlation_id": r.Header.Get("X-Correlation-ID"),
+		"timestamp":      time.Now().UTC().Format(time.RFC3339),
+		"path":           r.URL.Path,
+		"details": map[string]interface{}{
+			"max_concurrent": limit.Max,
+		},
+		"retry_after": 1,
+	}
+	if err := json.NewEncoder(w).Encode(errorResp); err != nil {
+		_, _ = fmt.Fprintf(w, "Concurrency limit exceeded\n")
+	}
+}
+
 // writeRateLimitError writes a 429 Too Many Requests error response.
 func writeRateLimitError(w http.ResponseWriter, r *http.Request, limit *config.LimitDefinition, result *Result) {
 	w.Header().Set("Content-Type", "application/json")
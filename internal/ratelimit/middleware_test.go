@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/maltehedderich/api-gateway-go/internal/auth"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/middleware"
+	"github.com/maltehedderich/api-gateway-go/internal/router"
+)
+
+// withRouteMatch stores the route matching each request in its context, as
+// the server does ahead of rate limiting
+func withRouteMatch(t *testing.T, routes []config.RouteConfig, next http.Handler) http.Handler {
+	t.Helper()
+	rt := router.New()
+	if err := rt.LoadRoutes(routes); err != nil {
+		t.Fatalf("LoadRoutes() error = %v", err)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if match, err := rt.Match(r); err == nil {
+			r = r.WithContext(middleware.WithRouteMatch(r.Context(), match))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestGetApplicableLimits_Tiers(t *testing.T) {
+	global := config.LimitDefinition{Key: "ip", Limit: 100, Window: "1m"}
+	tiers := map[string][]config.LimitDefinition{
+		"partner":         {{Key: "user", Limit: 1000, Window: "1m"}},
+		TierAuthenticated: {{Key: "user", Limit: 200, Window: "1m"}},
+		TierAnonymous:     {{Key: "ip", Limit: 20, Window: "1m"}},
+	}
+
+	tests := []struct {
+		name     string
+		user     *auth.UserContext
+		tiers    map[string][]config.LimitDefinition
+		expected int
+	}{
+		{"own tier", &auth.UserContext{UserID: "k1", Attributes: map[string]interface{}{auth.RateLimitTierAttribute: "partner"}}, tiers, 1000},
+		{"authenticated caller", &auth.UserContext{UserID: "user123"}, tiers, 200},
+		{"anonymous caller", nil, tiers, 20},
+		{"no tier configured", nil, nil, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{RateLimit: config.RateLimitConfig{
+				GlobalLimits: []config.LimitDefinition{global},
+				Tiers:        tt.tiers,
+			}}
+			req := httptest.NewRequest(http.MethodGet, "/catalog", nil)
+			if tt.user != nil {
+				req = req.WithContext(auth.SetUserContext(req.Context(), tt.user))
+			}
+
+			limits := getApplicableLimits(req, cfg, nil)
+			if len(limits) != 1 || limits[0].Limit != tt.expected {
+				t.Errorf("expected limit %d, got %v", tt.expected, limits)
+			}
+		})
+	}
+}
+
+func TestMiddleware_RouteLimits(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+
+	cfg := &config.Config{
+		RateLimit: config.RateLimitConfig{Enabled: true, Backend: "memory", FailureMode: "fail-closed"},
+		Routes: []config.RouteConfig{
+			{PathPattern: "/users/{id}", Methods: []string{"GET"}, BackendURL: "http://users", RateLimits: []config.LimitDefinition{{Key: "ip", Limit: 1, Window: "1h"}}},
+			{PathPattern: "/files/**", Methods: []string{"GET"}, BackendURL: "http://files", RateLimits: []config.LimitDefinition{{Key: "method", Limit: 2, Window: "1h"}}},
+			{PathPattern: "/status", Methods: []string{"GET"}, BackendURL: "http://status"},
+		},
+	}
+	limiter, err := NewLimiter(&cfg.RateLimit)
+	if err != nil {
+		t.Fatalf("NewLimiter() error = %v", err)
+	}
+	defer limiter.Close()
+
+	handler := withRouteMatch(t, cfg.Routes, Middleware(limiter, cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		expected int
+		limit    string
+	}{
+		{"templated route", "GET", "/users/42", http.StatusOK, "1"},
+		{"templated route exhausted", "GET", "/users/43", http.StatusTooManyRequests, "1"},
+		{"method not routed", "POST", "/users/42", http.StatusOK, ""},
+		{"wildcard route", "GET", "/files/a/b.txt", http.StatusOK, "2"},
+		{"wildcard route again", "GET", "/files/c.txt", http.StatusOK, "2"},
+		{"wildcard route exhausted", "GET", "/files/d.txt", http.StatusTooManyRequests, "2"},
+		{"route without limits", "GET", "/status", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.expected {
+				t.Errorf("expected status %d, got %d", tt.expected, rec.Code)
+			}
+			if got := rec.Header().Get("X-RateLimit-Limit"); got != tt.limit {
+				t.Errorf("expected X-RateLimit-Limit %q, got %q", tt.limit, got)
+			}
+		})
+	}
+}
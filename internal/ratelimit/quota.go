@@ -0,0 +1,285 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/auth"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+// quotaRetention keeps usage of a finished period around for a while, so
+// late requests and admin lookups near the reset still see it
+const quotaRetention = time.Hour
+
+// QuotaStore persists the usage of quotas. Keys name a subject and period.
+type QuotaStore interface {
+	// Add adds n, which may be negative, to the usage of key and returns
+	// the new usage. Unknown keys start at 0 and expire after ttl.
+	Add(ctx context.Context, key string, n int64, ttl time.Duration) (int64, error)
+	// Get returns the usage of key, or 0 if unknown
+	Get(ctx context.Context, key string) (int64, error)
+	// Set replaces the usage of key
+	Set(ctx context.Context, key string, used int64, ttl time.Duration) error
+	// Close releases the resources of the store
+	Close() error
+}
+
+// QuotaStoreFactory creates a QuotaStore from configuration
+type QuotaStoreFactory func(cfg *config.QuotaConfig) (QuotaStore, error)
+
+var (
+	quotaStores = map[string]QuotaStoreFactory{
+		"memory": newMemoryQuotaStore,
+		"redis":  newRedisQuotaStore,
+	}
+	quotaStoresMu sync.RWMutex
+)
+
+// RegisterQuotaStore makes a quota store available by name, so stores such
+// as DynamoDB can be plugged in without changing this package
+func RegisterQuotaStore(name string, factory QuotaStoreFactory) {
+	quotaStoresMu.Lock()
+	defer quotaStoresMu.Unlock()
+
+	quotaStores[name] = factory
+}
+
+// Quota is the state of a subject's quota in the current period
+type Quota struct {
+	Subject   string    `json:"subject"`
+	Limit     int64     `json:"limit"` // 0 if unlimited
+	Used      int64     `json:"used"`
+	Remaining int64     `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// QuotaManager counts requests against daily or monthly budgets
+type QuotaManager struct {
+	config   *config.QuotaConfig
+	store    QuotaStore
+	location *time.Location
+	now      func() time.Time
+}
+
+// NewQuotaManager creates a quota manager for the configured store
+func NewQuotaManager(cfg *config.QuotaConfig) (*QuotaManager, error) {
+	quotaStoresMu.RLock()
+	factory, ok := quotaStores[cfg.Store]
+	quotaStoresMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown quota store: %s", cfg.Store)
+	}
+
+	location, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quota timezone: %w", err)
+	}
+	store, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create quota store: %w", err)
+	}
+
+	return &QuotaManager{
+		config:   cfg,
+		store:    store,
+		location: location,
+		now:      time.Now,
+	}, nil
+}
+
+// QuotaSubject returns the subject whose quota a request counts against: the
+// API key or the user, or "" for anonymous requests
+func QuotaSubject(r *http.Request) string {
+	user, ok := auth.GetUserContext(r.Context())
+	if !ok || user == nil || user.UserID == "" {
+		return ""
+	}
+	if method, _ := user.Attributes["auth_method"].(string); method == "api_key" {
+		return "key:" + user.UserID
+	}
+	return "user:" + user.UserID
+}
+
+// Limit returns the budget per period of callers in tier, or 0 if unlimited
+func (m *QuotaManager) Limit(tier string) int64 {
+	if limit, ok := m.config.Tiers[tier]; ok {
+		return limit
+	}
+	return m.config.Limit
+}
+
+// Consume counts a request of subject costing n against its quota. It
+// reports whether the request is within the quota; rejected requests are not
+// counted. Subjects with an unlimited budget are not counted either and get
+// a nil quota.
+func (m *QuotaManager) Consume(ctx context.Context, subject, tier string, n int64) (*Quota, bool, error) {
+	limit := m.Limit(tier)
+	if limit == 0 {
+		return nil, true, nil
+	}
+
+	now := m.now()
+	start, end := m.period(now)
+	key := quotaKey(subject, start)
+	ttl := end.Sub(now) + quotaRetention
+
+	used, err := m.store.Add(ctx, key, n, ttl)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to count quota usage: %w", err)
+	}
+	if used > limit {
+		// Give the request back; a failure only overcounts a rejected request
+		if restored, err := m.store.Add(ctx, key, -n, ttl); err == nil {
+			used = restored
+		}
+		return newQuota(subject, limit, used, end), false, nil
+	}
+	return newQuota(subject, limit, used, end), true, nil
+}
+
+// Get returns the quota of subject in the current period
+func (m *QuotaManager) Get(ctx context.Context, subject, tier string) (*Quota, error) {
+	start, end := m.period(m.now())
+	used, err := m.store.Get(ctx, quotaKey(subject, start))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quota usage: %w", err)
+	}
+	return newQuota(subject, m.Limit(tier), used, end), nil
+}
+
+// Adjust changes the usage of subject in the current period: it is set to
+// used if given, and then changed by delta. A negative delta grants extra
+// requests for the rest of the period.
+func (m *QuotaManager) Adjust(ctx context.Context, subject, tier string, used *int64, delta int64) (*Quota, error) {
+	now := m.now()
+	start, end := m.period(now)
+	key := quotaKey(subject, start)
+	ttl := end.Sub(now) + quotaRetention
+
+	if used != nil {
+		if err := m.store.Set(ctx, key, *used, ttl); err != nil {
+			return nil, fmt.Errorf("failed to set quota usage: %w", err)
+		}
+	}
+	if delta != 0 {
+		if _, err := m.store.Add(ctx, key, delta, ttl); err != nil {
+			return nil, fmt.Errorf("failed to adjust quota usage: %w", err)
+		}
+	}
+	return m.Get(ctx, subject, tier)
+}
+
+// Close closes the quota store
+func (m *QuotaManager) Close() error {
+	return m.store.Close()
+}
+
+// period returns the start and end of the quota period containing now
+func (m *QuotaManager) period(now time.Time) (time.Time, time.Time) {
+	now = now.In(m.location)
+	hour := m.config.ResetHour
+
+	if m.config.Period == "daily" {
+		start := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, m.location)
+		if now.Before(start) {
+			start = start.AddDate(0, 0, -1)
+		}
+		return start, start.AddDate(0, 0, 1)
+	}
+
+	start := time.Date(now.Year(), now.Month(), m.config.ResetDay, hour, 0, 0, 0, m.location)
+	if now.Before(start) {
+		start = start.AddDate(0, -1, 0)
+	}
+	return start, start.AddDate(0, 1, 0)
+}
+
+// quotaKey names the usage counter of subject in the period starting at start
+func quotaKey(subject string, start time.Time) string {
+	return subject + ":" + start.Format("2006010215")
+}
+
+func newQuota(subject string, limit, used int64, reset time.Time) *Quota {
+	remaining := limit - used
+	if remaining < 0 || limit == 0 {
+		remaining = 0
+	}
+	return &Quota{
+		Subject:   subject,
+		Limit:     limit,
+		Used:      used,
+		Remaining: remaining,
+		Reset:     reset,
+	}
+}
+
+// memoryQuotaStore keeps quota usage in memory, per gateway instance
+type memoryQuotaStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryQuotaEntry
+	now     func() time.Time
+}
+
+type memoryQuotaEntry struct {
+	used   int64
+	expiry time.Time
+}
+
+func newMemoryQuotaStore(*config.QuotaConfig) (QuotaStore, error) {
+	return &memoryQuotaStore{
+		entries: make(map[string]memoryQuotaEntry),
+		now:     time.Now,
+	}, nil
+}
+
+func (s *memoryQuotaStore) Add(ctx context.Context, key string, n int64, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	entry, ok := s.entries[key]
+	if !ok || !now.Before(entry.expiry) {
+		// A new period started; drop the counters of finished ones
+		s.removeExpired(now)
+		entry = memoryQuotaEntry{expiry: now.Add(ttl)}
+	}
+	entry.used += n
+	s.entries[key] = entry
+	return entry.used, nil
+}
+
+func (s *memoryQuotaStore) Get(ctx context.Context, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok || !s.now().Before(entry.expiry) {
+		return 0, nil
+	}
+	return entry.used, nil
+}
+
+func (s *memoryQuotaStore) Set(ctx context.Context, key string, used int64, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[key] = memoryQuotaEntry{used: used, expiry: s.now().Add(ttl)}
+	return nil
+}
+
+func (s *memoryQuotaStore) Close() error {
+	return nil
+}
+
+// removeExpired drops expired counters; the caller holds the lock
+func (s *memoryQuotaStore) removeExpired(now time.Time) {
+	for key, entry := range s.entries {
+		if !now.Before(entry.expiry) {
+			delete(s.entries, key)
+		}
+	}
+}
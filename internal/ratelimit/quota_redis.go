@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+// redisQuotaStore keeps quota usage in Redis, shared by all gateway
+// instances. The usage of a key is the integer <prefix><key>.
+type redisQuotaStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func newRedisQuotaStore(cfg *config.QuotaConfig) (QuotaStore, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	return &redisQuotaStore{client: client, prefix: cfg.RedisKeyPrefix}, nil
+}
+
+// Add increments the usage and sets its expiry in one transaction, so a
+// counter never outlives its period
+func (s *redisQuotaStore) Add(ctx context.Context, key string, n int64, ttl time.Duration) (int64, error) {
+	var incr *redis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.IncrBy(ctx, s.prefix+key, n)
+		pipe.Expire(ctx, s.prefix+key, ttl)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (s *redisQuotaStore) Get(ctx context.Context, key string) (int64, error) {
+	used, err := s.client.Get(ctx, s.prefix+key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return used, err
+}
+
+func (s *redisQuotaStore) Set(ctx context.Context, key string, used int64, ttl time.Duration) error {
+	return s.client.Set(ctx, s.prefix+key, used, ttl).Err()
+}
+
+func (s *redisQuotaStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,184 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/auth"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+)
+
+func TestQuotaManager_Period(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		config        config.QuotaConfig
+		now           time.Time
+		expectedStart time.Time
+		expectedEnd   time.Time
+	}{
+		{
+			name:          "daily at midnight",
+			config:        config.QuotaConfig{Period: "daily", Timezone: "UTC"},
+			now:           time.Date(2026, 3, 14, 15, 30, 0, 0, time.UTC),
+			expectedStart: time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC),
+			expectedEnd:   time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "daily before the reset hour",
+			config:        config.QuotaConfig{Period: "daily", ResetHour: 6, Timezone: "UTC"},
+			now:           time.Date(2026, 3, 14, 5, 0, 0, 0, time.UTC),
+			expectedStart: time.Date(2026, 3, 13, 6, 0, 0, 0, time.UTC),
+			expectedEnd:   time.Date(2026, 3, 14, 6, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "monthly on the reset day",
+			config:        config.QuotaConfig{Period: "monthly", ResetDay: 15, Timezone: "UTC"},
+			now:           time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC),
+			expectedStart: time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC),
+			expectedEnd:   time.Date(2026, 4, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "monthly before the reset day",
+			config:        config.QuotaConfig{Period: "monthly", ResetDay: 15, Timezone: "UTC"},
+			now:           time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC),
+			expectedStart: time.Date(2025, 12, 15, 0, 0, 0, 0, time.UTC),
+			expectedEnd:   time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "monthly in a time zone",
+			config:        config.QuotaConfig{Period: "monthly", ResetDay: 1, Timezone: "Europe/Berlin"},
+			now:           time.Date(2026, 3, 31, 23, 30, 0, 0, time.UTC), // already April 1st in Berlin
+			expectedStart: time.Date(2026, 4, 1, 0, 0, 0, 0, berlin),
+			expectedEnd:   time.Date(2026, 5, 1, 0, 0, 0, 0, berlin),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Store = "memory"
+			m, err := NewQuotaManager(&tt.config)
+			if err != nil {
+				t.Fatalf("NewQuotaManager() error = %v", err)
+			}
+			start, end := m.period(tt.now)
+			if !start.Equal(tt.expectedStart) || !end.Equal(tt.expectedEnd) {
+				t.Errorf("expected period %v to %v, got %v to %v", tt.expectedStart, tt.expectedEnd, start, end)
+			}
+		})
+	}
+}
+
+func TestQuotaManager_Consume(t *testing.T) {
+	m, err := NewQuotaManager(&config.QuotaConfig{
+		Store:    "memory",
+		Period:   "daily",
+		Timezone: "UTC",
+		Limit:    2,
+		Tiers:    map[string]int64{"partner": 3, "internal": 0},
+	})
+	if err != nil {
+		t.Fatalf("NewQuotaManager() error = %v", err)
+	}
+	ctx := context.Background()
+
+	for i, expected := range []bool{true, true, false, false} {
+		quota, allowed, err := m.Consume(ctx, "user:alice", TierAuthenticated, 1)
+		if err != nil {
+			t.Fatalf("Consume() error = %v", err)
+		}
+		if allowed != expected {
+			t.Errorf("request %d: expected allowed %v, got %v", i, expected, allowed)
+		}
+		if quota.Used > 2 {
+			t.Errorf("request %d: rejected requests must not be counted, used %d", i, quota.Used)
+		}
+	}
+
+	// Tiers get their own budget, and a zero budget is unlimited
+	if quota, _, _ := m.Consume(ctx, "key:partner", "partner", 1); quota.Limit != 3 || quota.Remaining != 2 {
+		t.Errorf("expected partner budget of 3 with 2 remaining, got %+v", quota)
+	}
+	if quota, allowed, _ := m.Consume(ctx, "key:internal", "internal", 1); quota != nil || !allowed {
+		t.Errorf("expected unlimited tier to pass uncounted, got %+v, %v", quota, allowed)
+	}
+
+	// Granting extra requests lets alice continue
+	if _, err := m.Adjust(ctx, "user:alice", TierAuthenticated, nil, -1); err != nil {
+		t.Fatalf("Adjust() error = %v", err)
+	}
+	if _, allowed, _ := m.Consume(ctx, "user:alice", TierAuthenticated, 1); !allowed {
+		t.Error("expected request to pass after granting extra quota")
+	}
+
+	// A new period starts from zero
+	m.now = func() time.Time { return time.Now().Add(24 * time.Hour) }
+	if quota, allowed, _ := m.Consume(ctx, "user:alice", TierAuthenticated, 1); !allowed || quota.Used != 1 {
+		t.Errorf("expected a fresh quota in the next period, got %+v", quota)
+	}
+}
+
+func TestMiddleware_Quota(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+
+	cfg := &config.Config{
+		RateLimit: config.RateLimitConfig{
+			Enabled:     true,
+			Backend:     "memory",
+			FailureMode: "fail-closed",
+			Quota: config.QuotaConfig{
+				Enabled:  true,
+				Store:    "memory",
+				Period:   "monthly",
+				ResetDay: 1,
+				Timezone: "UTC",
+				Limit:    1,
+			},
+		},
+	}
+	limiter, err := NewLimiter(&cfg.RateLimit)
+	if err != nil {
+		t.Fatalf("NewLimiter() error = %v", err)
+	}
+	defer limiter.Close()
+
+	handler := Middleware(limiter, cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	request := func(user *auth.UserContext) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/reports", nil)
+		if user != nil {
+			req = req.WithContext(auth.SetUserContext(req.Context(), user))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	key := &auth.UserContext{UserID: "billing-export", Attributes: map[string]interface{}{"auth_method": "api_key"}}
+
+	rec := request(key)
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Quota-Remaining") != "0" || rec.Header().Get("X-Quota-Limit") != "1" {
+		t.Errorf("expected first request within quota, got %d with headers %v", rec.Code, rec.Header())
+	}
+	rec = request(key)
+	if rec.Code != http.StatusTooManyRequests || !bytes.Contains(rec.Body.Bytes(), []byte("quota_exceeded")) {
+		t.Errorf("expected 429 quota_exceeded, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After until the quota resets")
+	}
+
+	// Anonymous callers have no quota
+	if rec := request(nil); rec.Code != http.StatusOK || rec.Header().Get("X-Quota-Limit") != "" {
+		t.Errorf("expected anonymous request without quota, got %d with headers %v", rec.Code, rec.Header())
+	}
+}
@@ -4,13 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
 // RedisStorage implements rate limit storage using Redis.
-// It uses Redis strings with JSON-encoded bucket state.
+// It uses Redis strings with JSON-encoded bucket state, except for token
+// buckets, which are hashes updated atomically by a Lua script.
 // TTL is used for automatic cleanup of old entries.
 // This is suitable for distributed deployments with multiple gateway instances.
 type RedisStorage struct {
@@ -78,6 +82,220 @@ func (rs *RedisStorage) Set(ctx context.Context, key string, state *BucketState,
 	return nil
 }
 
+// tokenBucketScript refills and takes from a token bucket stored as a hash
+// of tokens and last refill time in milliseconds. It uses the Redis clock so
+// gateway instances with skewed clocks share one refill rate.
+//
+// KEYS[1]: bucket key
+// ARGV: capacity, refill rate per second, tokens requested, TTL in ms
+// Returns: {allowed (0 or 1), tokens left as a string}
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local requested = tonumber(ARGV[3])
+local ttl = tonumber(ARGV[4])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+  tokens = capacity
+  ts = now
+end
+
+tokens = math.min(capacity, tokens + math.max(0, now - ts) / 1000 * rate)
+
+local allowed = 0
+if tokens >= requested then
+  tokens = tokens - requested
+  allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], ttl)
+return {allowed, tostring(tokens)}
+`)
+
+// TakeTokens runs the token bucket script by its SHA. If Redis does not know
+// the script yet, it is loaded and run in a single pipelined round trip.
+func (rs *RedisStorage) TakeTokens(ctx context.Context, key string, capacity int, refillRate float64, n int, ttl time.Duration) (bool, float64, error) {
+	keys := []string{key}
+	args := []interface{}{capacity, refillRate, n, ttl.Milliseconds()}
+
+	reply, err := tokenBucketScript.EvalSha(ctx, rs.client, keys, args...).Result()
+	if redis.HasErrorPrefix(err, "NOSCRIPT") {
+		pipe := rs.client.Pipeline()
+		tokenBucketScript.Load(ctx, pipe)
+		cmd := tokenBucketScript.EvalSha(ctx, pipe, keys, args...)
+		_, _ = pipe.Exec(ctx) // the script result carries any error
+		reply, err = cmd.Result()
+	}
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to run token bucket script: %w", err)
+	}
+
+	return parseTokenBucketReply(reply)
+}
+
+// parseTokenBucketReply reads the {allowed, tokens} reply of the token
+// bucket script
+func parseTokenBucketReply(reply interface{}) (bool, float64, error) {
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 2 {
+		return false, 0, fmt.Errorf("unexpected token bucket script reply: %v", reply)
+	}
+	allowed, ok := values[0].(int64)
+	if !ok {
+		return false, 0, fmt.Errorf("unexpected token bucket script reply: %v", reply)
+	}
+	tokens, ok := values[1].(string)
+	if !ok {
+		return false, 0, fmt.Errorf("unexpected token bucket script reply: %v", reply)
+	}
+	remaining, err := strconv.ParseFloat(tokens, 64)
+	if err != nil {
+		return false, 0, fmt.Errorf("invalid token count in script reply: %w", err)
+	}
+	return allowed == 1, remaining, nil
+}
+
+// globEscaper escapes the glob characters of Redis MATCH patterns
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+// Entries scans Redis for up to limit states whose keys start with prefix,
+// ordered by key. Keys holding anything but rate limit state are skipped.
+func (rs *RedisStorage) Entries(ctx context.Context, prefix string, limit int) ([]Entry, error) {
+	match := globEscaper.Replace(prefix) + "*"
+	entries := make([]Entry, 0)
+
+	var cursor uint64
+	for {
+		keys, next, err := rs.client.Scan(ctx, cursor, match, 100).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan Redis keys: %w", err)
+		}
+		for _, key := range keys {
+			entry, ok, err := rs.entry(ctx, key)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				entries = append(entries, entry)
+			}
+		}
+		cursor = next
+		if cursor == 0 || len(entries) >= limit {
+			break
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// entry reads the state of key, a JSON string or a token bucket hash, and
+// reports whether key holds rate limit state
+func (rs *RedisStorage) entry(ctx context.Context, key string) (Entry, bool, error) {
+	entry := Entry{Key: key}
+
+	keyType, err := rs.client.Type(ctx, key).Result()
+	if err != nil {
+		return entry, false, fmt.Errorf("failed to get type of Redis key: %w", err)
+	}
+	switch keyType {
+	case "hash":
+		values, err := rs.client.HMGet(ctx, key, "tokens", "ts").Result()
+		if err != nil {
+			return entry, false, fmt.Errorf("failed to get token bucket from Redis: %w", err)
+		}
+		tokens, _ := values[0].(string)
+		ts, _ := values[1].(string)
+		millis, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return entry, false, nil
+		}
+		if entry.State.Tokens, err = strconv.ParseFloat(tokens, 64); err != nil {
+			return entry, false, nil
+		}
+		entry.State.LastRefill = time.UnixMilli(millis)
+	case "string":
+		state, ok, err := rs.Get(ctx, key)
+		if err != nil || !ok {
+			return entry, false, nil
+		}
+		entry.State = *state
+	default:
+		return entry, false, nil
+	}
+
+	ttl, err := rs.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return entry, false, fmt.Errorf("failed to get TTL of Redis key: %w", err)
+	}
+	if ttl > 0 {
+		entry.ExpiresAt = time.Now().Add(ttl)
+	}
+	return entry, true, nil
+}
+
+// restoreTokenBucketScript stores a token bucket hash unless the key exists.
+//
+// KEYS[1]: bucket key
+// ARGV: tokens, last refill time in ms, TTL in ms
+// Returns: 1 if stored, 0 if the key existed
+var restoreTokenBucketScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+  return 0
+end
+redis.call('HSET', KEYS[1], 'tokens', ARGV[1], 'ts', ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 1
+`)
+
+// Restore stores the state of entry in Redis until it expires, unless Redis
+// holds the key already. Token buckets are stored as the hashes the token
+// bucket script uses.
+func (rs *RedisStorage) Restore(ctx context.Context, entry Entry) error {
+	ttl := time.Until(entry.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	state := entry.State
+	if len(state.Log) == 0 && state.TAT.IsZero() {
+		tokens := strconv.FormatFloat(state.Tokens, 'f', -1, 64)
+		keys := []string{entry.Key}
+		args := []interface{}{tokens, state.LastRefill.UnixMilli(), ttl.Milliseconds()}
+		if err := restoreTokenBucketScript.Run(ctx, rs.client, keys, args...).Err(); err != nil {
+			return fmt.Errorf("failed to restore token bucket in Redis: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(&state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal bucket state: %w", err)
+	}
+	if err := rs.client.SetNX(ctx, entry.Key, data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to restore key in Redis: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the state of key from Redis.
+func (rs *RedisStorage) Delete(ctx context.Context, key string) error {
+	if err := rs.client.Del(ctx, key).Err(); err != nil {
+		return fmt.Errorf("failed to delete key from Redis: %w", err)
+	}
+	return nil
+}
+
 // Close closes the Redis connection.
 func (rs *RedisStorage) Close() error {
 	return rs.client.Close()
@@ -0,0 +1,271 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+// atomicStorage is a memory storage taking tokens under one lock, standing
+// in for the Redis token bucket script
+type atomicStorage struct {
+	*MemoryStorage
+	mu    sync.Mutex
+	calls int
+	err   error
+}
+
+func (s *atomicStorage) TakeTokens(ctx context.Context, key string, capacity int, refillRate float64, n int, ttl time.Duration) (bool, float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.err != nil {
+		return false, 0, s.err
+	}
+
+	bucket := NewTokenBucket(capacity, refillRate)
+	if state, ok, _ := s.Get(ctx, key); ok {
+		bucket = NewTokenBucketFromState(capacity, refillRate, state.Tokens, state.LastRefill)
+	}
+	allowed := bucket.Allow(n)
+	state := bucket.GetState()
+	return allowed, state.Tokens, s.Set(ctx, key, &state, ttl)
+}
+
+func TestLimiter_AtomicStorage(t *testing.T) {
+	storage := &atomicStorage{MemoryStorage: NewMemoryStorage()}
+	limiter := &Limiter{storage: storage, failureMode: "fail-closed", stopCh: make(chan struct{})}
+	defer limiter.Close()
+
+	limit := &config.LimitDefinition{Key: "ip", Limit: 10, Window: "1h"}
+	req := httptest.NewRequest("GET", "/", nil)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	admitted := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := limiter.Allow(context.Background(), req, limit)
+			if err != nil {
+				t.Errorf("Allow() error = %v", err)
+				return
+			}
+			if result.Allowed {
+				mu.Lock()
+				admitted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if admitted != 10 {
+		t.Errorf("expected exactly 10 concurrent requests admitted, got %d", admitted)
+	}
+	if storage.calls != 50 {
+		t.Errorf("expected every check to use the atomic operation, got %d calls", storage.calls)
+	}
+
+	storage.err = errors.New("connection refused")
+	result, err := limiter.Allow(context.Background(), req, limit)
+	if err == nil || result.Allowed {
+		t.Errorf("expected fail-closed rejection on storage error, got %+v, %v", result, err)
+	}
+}
+
+func TestParseTokenBucketReply(t *testing.T) {
+	tests := []struct {
+		name          string
+		reply         interface{}
+		expectAllowed bool
+		expectTokens  float64
+		expectError   bool
+	}{
+		{"allowed", []interface{}{int64(1), "4.5"}, true, 4.5, false},
+		{"rejected", []interface{}{int64(0), "0.25"}, false, 0.25, false},
+		{"not a list", "OK", false, 0, true},
+		{"short list", []interface{}{int64(1)}, false, 0, true},
+		{"invalid tokens", []interface{}{int64(1), "many"}, false, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, tokens, err := parseTokenBucketReply(tt.reply)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("parseTokenBucketReply() error = %v, expectError %v", err, tt.expectError)
+			}
+			if allowed != tt.expectAllowed || tokens != tt.expectTokens {
+				t.Errorf("expected %v, %v, got %v, %v", tt.expectAllowed, tt.expectTokens, allowed, tokens)
+			}
+		})
+	}
+}
+
+// newTestRedisStorage returns a Redis storage backed by an in-process Redis
+func newTestRedisStorage(t *testing.T) (*RedisStorage, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	storage, err := NewRedisStorage(RedisConfig{Addr: mr.Addr()})
+	if err != nil {
+		t.Fatalf("NewRedisStorage() error = %v", err)
+	}
+	t.Cleanup(func() { _ = storage.Close() })
+	return storage, mr
+}
+
+func TestRedisStorage_TakeTokens(t *testing.T) {
+	storage, mr := newTestRedisStorage(t)
+	ctx := context.Background()
+	take := func(n int) (bool, float64) {
+		t.Helper()
+		allowed, tokens, err := storage.TakeTokens(ctx, "rl:ip:1", 3, 0.001, n, time.Minute)
+		if err != nil {
+			t.Fatalf("TakeTokens() error = %v", err)
+		}
+		return allowed, tokens
+	}
+
+	// The first call loads the script after NOSCRIPT
+	if allowed, tokens := take(2); !allowed || tokens < 0.99 || tokens > 1.01 {
+		t.Errorf("expected 2 tokens taken leaving 1, got %v, %v", allowed, tokens)
+	}
+	if allowed, _ := take(2); allowed {
+		t.Error("expected request for more tokens than left to be rejected")
+	}
+	if allowed, _ := take(1); !allowed {
+		t.Error("expected the last token to be taken")
+	}
+
+	if mr.Type("rl:ip:1") != "hash" {
+		t.Fatalf("expected token bucket hash, got %s", mr.Type("rl:ip:1"))
+	}
+	if ttl := mr.TTL("rl:ip:1"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("expected TTL up to a minute, got %v", ttl)
+	}
+
+	// A flushed script cache is reloaded in the same round trip
+	if err := storage.client.ScriptFlush(ctx).Err(); err != nil {
+		t.Fatalf("SCRIPT FLUSH error = %v", err)
+	}
+	if allowed, _ := take(1); allowed {
+		t.Error("expected empty bucket to reject after script reload")
+	}
+	exists, err := tokenBucketScript.Exists(ctx, storage.client).Result()
+	if err != nil || len(exists) != 1 || !exists[0] {
+		t.Errorf("expected script to be loaded again, got %v, %v", exists, err)
+	}
+
+	// A key of another type makes the script fail
+	mr.Set("rl:ip:2", "not a hash")
+	if _, _, err := storage.TakeTokens(ctx, "rl:ip:2", 3, 1, 1, time.Minute); err == nil {
+		t.Error("expected script error for a key holding a string")
+	}
+}
+
+func TestRedisStorage_EntriesAndRestore(t *testing.T) {
+	storage, mr := newTestRedisStorage(t)
+	ctx := context.Background()
+
+	window := &BucketState{Log: []time.Time{time.Now().Truncate(time.Millisecond)}}
+	if err := storage.Set(ctx, "rl:ip:a", window, time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if _, _, err := storage.TakeTokens(ctx, "rl:ip:b", 5, 1, 2, time.Minute); err != nil {
+		t.Fatalf("TakeTokens() error = %v", err)
+	}
+	// Keys holding anything but rate limit state are skipped
+	mr.Set("rl:ip:garbage", "not json")
+	if _, err := mr.Lpush("rl:ip:list", "x"); err != nil {
+		t.Fatalf("LPUSH error = %v", err)
+	}
+	mr.HSet("rl:ip:other-hash", "field", "value")
+
+	entries, err := storage.Entries(ctx, "rl:ip:", 10)
+	if err != nil {
+		t.Fatalf("Entries() error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].Key != "rl:ip:a" || entries[1].Key != "rl:ip:b" {
+		t.Fatalf("expected the window and token bucket entries, got %+v", entries)
+	}
+	if len(entries[0].State.Log) != 1 || !entries[0].State.Log[0].Equal(window.Log[0]) {
+		t.Errorf("expected window log %v, got %v", window.Log, entries[0].State.Log)
+	}
+	if tokens := entries[1].State.Tokens; tokens < 2.99 || tokens > 3.01 || entries[1].State.LastRefill.IsZero() {
+		t.Errorf("expected token bucket with 3 tokens, got %+v", entries[1].State)
+	}
+	for _, entry := range entries {
+		if entry.ExpiresAt.IsZero() {
+			t.Errorf("%s: expected expiry", entry.Key)
+		}
+	}
+
+	// Restored entries become the same kind of key, unless the key exists
+	mr.FlushAll()
+	mr.Set("rl:ip:b", "newer")
+	for _, entry := range entries {
+		if err := storage.Restore(ctx, entry); err != nil {
+			t.Fatalf("Restore(%s) error = %v", entry.Key, err)
+		}
+	}
+	if mr.Type("rl:ip:a") != "string" {
+		t.Errorf("expected restored window as string, got %s", mr.Type("rl:ip:a"))
+	}
+	if value, _ := mr.Get("rl:ip:b"); value != "newer" {
+		t.Errorf("expected existing key to be kept, got %q", value)
+	}
+
+	entries[1].Key = "rl:ip:c"
+	if err := storage.Restore(ctx, entries[1]); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	restored, err := storage.Entries(ctx, "rl:ip:c", 10)
+	if err != nil || len(restored) != 1 || restored[0].State.Tokens != entries[1].State.Tokens {
+		t.Errorf("expected restored token bucket %+v, got %+v, %v", entries[1].State, restored, err)
+	}
+	if mr.TTL("rl:ip:c") <= 0 {
+		t.Error("expected restored token bucket to expire")
+	}
+}
+
+func TestLimiter_RedisStorage(t *testing.T) {
+	storage, _ := newTestRedisStorage(t)
+	limiter := &Limiter{storage: storage, failureMode: "fail-closed", stopCh: make(chan struct{})}
+	defer limiter.Close()
+
+	limit := &config.LimitDefinition{Key: "ip", Limit: 10, Window: "1h"}
+	req := httptest.NewRequest("GET", "/", nil)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	admitted := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := limiter.Allow(context.Background(), req, limit)
+			if err != nil {
+				t.Errorf("Allow() error = %v", err)
+				return
+			}
+			if result.Allowed {
+				mu.Lock()
+				admitted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if admitted != 10 {
+		t.Errorf("expected exactly 10 concurrent requests admitted, got %d", admitted)
+	}
+}
@@ -0,0 +1,144 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+)
+
+// shapingQueues holds the FIFO queues of rate limit keys whose limits use
+// the queue policy. Only the request at the head of a queue waits for the
+// limit; the others wait for their turn.
+type shapingQueues struct {
+	mu     sync.Mutex
+	queues map[string]*shapingQueue
+}
+
+// shapingQueue is the queue of a single key
+type shapingQueue struct {
+	waiting int // requests in the queue, including its head
+	// head holds a token while no request is at the head of the queue
+	head chan struct{}
+}
+
+// enter adds a request to the queue of key, or reports false if size
+// requests are queued already
+func (q *shapingQueues) enter(key string, size int) (*shapingQueue, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.queues == nil {
+		q.queues = make(map[string]*shapingQueue)
+	}
+	queue, ok := q.queues[key]
+	if !ok {
+		queue = &shapingQueue{head: make(chan struct{}, 1)}
+		queue.head <- struct{}{}
+		q.queues[key] = queue
+	}
+	if queue.waiting >= size {
+		return nil, false
+	}
+	queue.waiting++
+	return queue, true
+}
+
+// leave removes a request from the queue of key, dropping empty queues
+func (q *shapingQueues) leave(key string, queue *shapingQueue) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	queue.waiting--
+	if queue.waiting == 0 {
+		delete(q.queues, key)
+	}
+}
+
+// Shape applies the policy of limitDef to a request costing n that the limit
+// rejected with result. Under the delay and queue policies the request
+// waits, up to MaxDelay, until the limit admits it; the returned result is
+// that of the last check and still rejects the request if it could not be
+// admitted in time. Requests under the reject policy are returned their
+// result as is.
+func (l *Limiter) Shape(ctx context.Context, r *http.Request, limitDef *config.LimitDefinition, n int, result *Result) (*Result, error) {
+	if result.Allowed {
+		return result, nil
+	}
+	deadline := time.Now().Add(limitDef.MaxDelay)
+
+	switch limitDef.Policy {
+	case "delay":
+		result, err := l.waitAdmitted(ctx, r, limitDef, n, result, deadline)
+		recordShaped(limitDef.Policy, result, err)
+		return result, err
+	case "queue":
+		key, ok := NewKeyGenerator(limitDef.Key).WithIPPrefixes(l.ipv4Prefix, l.ipv6Prefix).GenerateKey(r)
+		if !ok {
+			return result, nil
+		}
+		queue, ok := l.queues.enter(key, limitDef.QueueSize)
+		if !ok {
+			metrics.RecordRateLimitShaped(limitDef.Policy, "queue_full")
+			return result, nil
+		}
+		defer l.queues.leave(key, queue)
+
+		timer := time.NewTimer(limitDef.MaxDelay)
+		defer timer.Stop()
+		select {
+		case <-queue.head:
+			defer func() { queue.head <- struct{}{} }()
+		case <-timer.C:
+			metrics.RecordRateLimitShaped(limitDef.Policy, "rejected")
+			return result, nil
+		case <-ctx.Done():
+			metrics.RecordRateLimitShaped(limitDef.Policy, "rejected")
+			return result, nil
+		}
+
+		result, err := l.waitAdmitted(ctx, r, limitDef, n, result, deadline)
+		recordShaped(limitDef.Policy, result, err)
+		return result, err
+	}
+	return result, nil
+}
+
+// waitAdmitted checks the limit again whenever it may admit the request,
+// until it does or waiting longer would pass deadline
+func (l *Limiter) waitAdmitted(ctx context.Context, r *http.Request, limitDef *config.LimitDefinition, n int, result *Result, deadline time.Time) (*Result, error) {
+	for !result.Allowed {
+		wait := max(result.RetryAfter, time.Millisecond)
+		if time.Now().Add(wait).After(deadline) {
+			return result, nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return result, nil
+		}
+
+		next, err := l.AllowN(ctx, r, limitDef, n)
+		if err != nil {
+			return nil, err
+		}
+		result = next
+	}
+	return result, nil
+}
+
+// recordShaped counts the outcome of a request held by a shaping policy
+func recordShaped(policy string, result *Result, err error) {
+	switch {
+	case err != nil:
+		metrics.RecordRateLimitShaped(policy, "error")
+	case result.Allowed:
+		metrics.RecordRateLimitShaped(policy, "admitted")
+	default:
+		metrics.RecordRateLimitShaped(policy, "rejected")
+	}
+}
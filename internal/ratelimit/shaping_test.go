@@ -0,0 +1,126 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+)
+
+func TestShapingQueues(t *testing.T) {
+	var queues shapingQueues
+
+	first, ok := queues.enter("ip:1", 2)
+	if !ok {
+		t.Fatal("expected first request to enter the queue")
+	}
+	second, ok := queues.enter("ip:1", 2)
+	if !ok {
+		t.Fatal("expected second request to enter the queue")
+	}
+	if _, ok := queues.enter("ip:1", 2); ok {
+		t.Error("expected full queue to turn away a third request")
+	}
+	if _, ok := queues.enter("ip:2", 2); !ok {
+		t.Error("expected queues of other keys to be independent")
+	}
+
+	queues.leave("ip:1", first)
+	queues.leave("ip:1", second)
+	if _, ok := queues.queues["ip:1"]; ok {
+		t.Error("expected empty queue to be dropped")
+	}
+}
+
+func TestLimiter_Shape(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+
+	tests := []struct {
+		name     string
+		limit    config.LimitDefinition
+		expected bool
+	}{
+		{"reject", config.LimitDefinition{Policy: "reject"}, false},
+		{"delay within max delay", config.LimitDefinition{Policy: "delay", MaxDelay: time.Second}, true},
+		{"delay beyond max delay", config.LimitDefinition{Policy: "delay", MaxDelay: 10 * time.Millisecond}, false},
+		{"queue", config.LimitDefinition{Policy: "queue", MaxDelay: time.Second, QueueSize: 1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter, err := NewLimiter(&config.RateLimitConfig{Backend: "memory", FailureMode: "fail-closed"})
+			if err != nil {
+				t.Fatalf("NewLimiter() error = %v", err)
+			}
+			defer limiter.Close()
+
+			limit := tt.limit
+			limit.Key, limit.Limit, limit.Window = "ip", 1, "100ms"
+			req := httptest.NewRequest("GET", "/", nil)
+
+			if result, _ := limiter.Allow(context.Background(), req, &limit); !result.Allowed {
+				t.Fatal("expected first request to be allowed")
+			}
+			result, err := limiter.Allow(context.Background(), req, &limit)
+			if err != nil || result.Allowed {
+				t.Fatalf("expected second request over the limit, got %+v, %v", result, err)
+			}
+
+			result, err = limiter.Shape(context.Background(), req, &limit, 1, result)
+			if err != nil {
+				t.Fatalf("Shape() error = %v", err)
+			}
+			if result.Allowed != tt.expected {
+				t.Errorf("expected allowed = %v after shaping, got %+v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestMiddleware_QueuePolicy(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+
+	cfg := &config.Config{RateLimit: config.RateLimitConfig{
+		Enabled:     true,
+		Backend:     "memory",
+		FailureMode: "fail-closed",
+		GlobalLimits: []config.LimitDefinition{
+			{Key: "ip", Limit: 1, Window: "50ms", Policy: "queue", MaxDelay: 2 * time.Second, QueueSize: 3},
+		},
+	}}
+	limiter, err := NewLimiter(&cfg.RateLimit)
+	if err != nil {
+		t.Fatalf("NewLimiter() error = %v", err)
+	}
+	defer limiter.Close()
+
+	handler := Middleware(limiter, cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// One request is admitted at once and three wait their turn in the queue
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("expected queued request to be admitted, got %d", rec.Code)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected queued requests to be spaced by the limit, all done after %v", elapsed)
+	}
+}
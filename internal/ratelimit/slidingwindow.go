@@ -0,0 +1,101 @@
+package ratelimit
+
+import "time"
+
+// SlidingWindowLog implements the sliding window log rate limiting algorithm.
+// The time of every admitted request is logged, and a request is allowed only
+// if fewer than Limit requests were admitted within the preceding Window.
+// Unlike the token bucket, no window ever admits more than Limit requests.
+type SlidingWindowLog struct {
+	// Limit is the maximum number of requests within a window
+	Limit int
+	// Window is the length of the sliding window
+	Window time.Duration
+	// Log holds the times of admitted requests within the window, oldest first
+	Log []time.Time
+}
+
+// NewSlidingWindowLog creates a sliding window log from saved request times.
+func NewSlidingWindowLog(limit int, window time.Duration, log []time.Time) *SlidingWindowLog {
+	return &SlidingWindowLog{
+		Limit:  limit,
+		Window: window,
+		Log:    log,
+	}
+}
+
+// Allow reports whether a request at now is allowed, and logs it if so.
+func (sw *SlidingWindowLog) Allow(now time.Time) bool {
+	return sw.AllowN(now, 1)
+}
+
+// AllowN reports whether a request costing n at now is allowed, and logs it
+// n times if so.
+func (sw *SlidingWindowLog) AllowN(now time.Time, n int) bool {
+	sw.prune(now)
+	if len(sw.Log)+n > sw.Limit {
+		return false
+	}
+	sw.log(now, n)
+	return true
+}
+
+// Charge logs up to n more requests at now, as many as the window still
+// allows.
+func (sw *SlidingWindowLog) Charge(now time.Time, n int) {
+	sw.log(now, min(n, sw.Remaining(now)))
+}
+
+// log appends n requests at now
+func (sw *SlidingWindowLog) log(now time.Time, n int) {
+	for i := 0; i < n; i++ {
+		sw.Log = append(sw.Log, now)
+	}
+}
+
+// Remaining returns the number of requests still allowed in the window.
+func (sw *SlidingWindowLog) Remaining(now time.Time) int {
+	sw.prune(now)
+	if remaining := sw.Limit - len(sw.Log); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// Reset returns the time when the window holds no logged requests anymore.
+func (sw *SlidingWindowLog) Reset(now time.Time) time.Time {
+	sw.prune(now)
+	if len(sw.Log) == 0 {
+		return now
+	}
+	return sw.Log[len(sw.Log)-1].Add(sw.Window)
+}
+
+// RetryAt returns the time when the next request will be allowed.
+func (sw *SlidingWindowLog) RetryAt(now time.Time) time.Time {
+	return sw.RetryAtN(now, 1)
+}
+
+// RetryAtN returns the time when the next request costing n will be allowed.
+// Requests costing more than Limit are never allowed; for them the time the
+// window is empty is returned.
+func (sw *SlidingWindowLog) RetryAtN(now time.Time, n int) time.Time {
+	sw.prune(now)
+	if len(sw.Log)+n <= sw.Limit {
+		return now
+	}
+	if n > sw.Limit {
+		return sw.Reset(now)
+	}
+	return sw.Log[len(sw.Log)+n-1-sw.Limit].Add(sw.Window)
+}
+
+// prune drops requests that have left the window
+func (sw *SlidingWindowLog) prune(now time.Time) {
+	start := now.Add(-sw.Window)
+	i := 0
+	for i < len(sw.Log) && !sw.Log[i].After(start) {
+		i++
+	}
+	sw.Log = sw.Log[i:]
+}
@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+)
+
+func TestSlidingWindowLog_Allow(t *testing.T) {
+	start := time.Now()
+	sw := NewSlidingWindowLog(3, time.Minute, nil)
+
+	steps := []struct {
+		at       time.Duration
+		expected bool
+	}{
+		{0, true},
+		{10 * time.Second, true},
+		{50 * time.Second, true},
+		{59 * time.Second, false},   // three requests within the last minute
+		{60 * time.Second, true},    // the first request left the window
+		{65 * time.Second, false},   // the second is still in it
+		{70*time.Second + 1, true},  // now the second left it as well
+		{109 * time.Second, false},  // requests at 50s, 60s and 70s remain
+		{110*time.Second + 1, true}, // the request at 50s left
+		{240 * time.Second, true},   // the window is empty again
+	}
+
+	for i, step := range steps {
+		if got := sw.Allow(start.Add(step.at)); got != step.expected {
+			t.Errorf("step %d at %v: expected %v, got %v", i, step.at, step.expected, got)
+		}
+	}
+}
+
+func TestSlidingWindowLog_Metadata(t *testing.T) {
+	now := time.Now()
+	sw := NewSlidingWindowLog(2, time.Minute, []time.Time{
+		now.Add(-90 * time.Second), // outside the window
+		now.Add(-40 * time.Second),
+		now.Add(-20 * time.Second),
+	})
+
+	if remaining := sw.Remaining(now); remaining != 0 {
+		t.Errorf("expected 0 remaining, got %d", remaining)
+	}
+	if retryAt := sw.RetryAt(now); !retryAt.Equal(now.Add(20 * time.Second)) {
+		t.Errorf("expected retry when the oldest request leaves the window, got %v", retryAt.Sub(now))
+	}
+	if reset := sw.Reset(now); !reset.Equal(now.Add(40 * time.Second)) {
+		t.Errorf("expected reset when the newest request leaves the window, got %v", reset.Sub(now))
+	}
+	if len(sw.Log) != 2 {
+		t.Errorf("expected requests outside the window to be pruned, got %d entries", len(sw.Log))
+	}
+}
+
+func TestLimiter_Algorithms(t *testing.T) {
+	for _, algorithm := range []string{"token_bucket", "sliding_window", "gcra"} {
+		t.Run(algorithm, func(t *testing.T) {
+			limiter, err := NewLimiter(&config.RateLimitConfig{Backend: "memory", FailureMode: "fail-closed"})
+			if err != nil {
+				t.Fatalf("NewLimiter() error = %v", err)
+			}
+			defer limiter.Close()
+
+			limit := &config.LimitDefinition{Key: "ip", Limit: 2, Window: "1m", Burst: 2, Algorithm: algorithm}
+			req := httptest.NewRequest("GET", "/billing", nil)
+			for i, expected := range []bool{true, true, false} {
+				result, err := limiter.Allow(context.Background(), req, limit)
+				if err != nil {
+					t.Fatalf("Allow() error = %v", err)
+				}
+				if result.Allowed != expected {
+					t.Errorf("request %d: expected allowed %v, got %v", i, expected, result.Allowed)
+				}
+				if !expected && result.RetryAfter <= 0 {
+					t.Errorf("request %d: expected a retry after, got %v", i, result.RetryAfter)
+				}
+			}
+		})
+	}
+}
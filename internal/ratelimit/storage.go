@@ -25,6 +25,45 @@ type Storage interface {
 	Ping(ctx context.Context) error
 }
 
+// AtomicStorage is implemented by storage backends that take tokens from a
+// token bucket in a single atomic operation, so concurrent gateway instances
+// never admit more requests than the bucket holds. The limiter prefers it
+// over Get and Set for token bucket limits.
+type AtomicStorage interface {
+	// TakeTokens refills the bucket of key, takes n tokens if available and
+	// returns whether they were taken and the tokens left. A missing bucket
+	// starts full.
+	TakeTokens(ctx context.Context, key string, capacity int, refillRate float64, n int, ttl time.Duration) (bool, float64, error)
+}
+
+// InspectableStorage is implemented by storage backends that can list and
+// delete rate limit state, so operators can inspect and reset keys through
+// the admin API.
+type InspectableStorage interface {
+	// Entries returns up to limit stored states whose keys start with prefix.
+	Entries(ctx context.Context, prefix string, limit int) ([]Entry, error)
+
+	// Delete removes the state of key, resetting its limits.
+	Delete(ctx context.Context, key string) error
+}
+
+// RestorableStorage is implemented by storage backends that can take over
+// limit state kept elsewhere, such as the local state of a limiter while the
+// backend was unreachable.
+type RestorableStorage interface {
+	// Restore stores the state of entry until it expires, unless the
+	// backend holds state of its key already.
+	Restore(ctx context.Context, entry Entry) error
+}
+
+// Entry is a rate limit state held by a storage backend.
+type Entry struct {
+	Key   string
+	State BucketState
+	// ExpiresAt is when the state is dropped, resetting the key's limits
+	ExpiresAt time.Time
+}
+
 // Limit represents a rate limit configuration.
 type Limit struct {
 	// Key is the rate limit key (used for storage)
@@ -105,6 +105,11 @@ type BucketState struct {
 	RefillRate float64
 	Tokens     float64
 	LastRefill time.Time
+
+	// Log holds the admitted request times of a sliding window limit
+	Log []time.Time `json:",omitempty"`
+	// TAT is the theoretical arrival time of a GCRA limit
+	TAT time.Time
 }
 
 // GetState returns the current state of the token bucket.
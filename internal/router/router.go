@@ -1,36 +1,112 @@
 package router
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/expr"
+	"github.com/maltehedderich/api-gateway-go/internal/jsonschema"
 	"github.com/maltehedderich/api-gateway-go/internal/logger"
 )
 
 // Router handles request routing to backend services
 type Router struct {
-	routes  []*Route
-	mu      sync.RWMutex
-	logger  *logger.ComponentLogger
+	routes []*Route
+	mu     sync.RWMutex
+	logger *logger.ComponentLogger
+
+	hooksMu     sync.Mutex
+	reloadHooks []ReloadHook
 }
 
+// ReloadHook is called with the new routes after a reload, so components
+// holding per-route or per-backend state can migrate it to the new routes
+// and release what no route refers to anymore
+type ReloadHook func(routes []*Route)
+
 // Route represents a configured route with compiled pattern
 type Route struct {
-	PathPattern    string
-	CompiledRegex  *regexp.Regexp
-	Methods        map[string]bool
-	BackendURL     string
-	Timeout        int64 // timeout in milliseconds
-	AuthPolicy     string
-	RequiredRoles  []string
-	RateLimits     []config.LimitDefinition
-	StripPrefix    string
-	Priority       int // Lower number = higher priority
-	ParamNames     []string
+	PathPattern   string
+	CompiledRegex *regexp.Regexp
+	Methods       map[string]bool
+	BackendURL    string
+	Timeout       time.Duration // total backend timeout
+	AuthPolicy    string
+	RequiredRoles []string
+	// RequiredPermissions and RequiredScopes back the permission-based and
+	// scope-based policies; AuthExpression the expression policy
+	RequiredPermissions []string
+	RequiredScopes      []string
+	AuthExpression      *expr.Requirement
+	RateLimits          []config.LimitDefinition
+	ConcurrencyLimits   []config.ConcurrencyLimit
+	// Cost is the tokens a request takes from rate limits and quotas;
+	// CostHeader the response header reporting the actual cost
+	Cost        int
+	CostHeader  string
+	StripPrefix string
+	UpstreamTLS config.UpstreamTLSConfig
+	// DecompressRequest inflates gzip request bodies before forwarding
+	DecompressRequest       bool
+	MaxDecompressedBodySize int64
+	Transport               config.TransportConfig
+	// UploadMode streams request bodies untouched with extended deadlines
+	UploadMode    bool
+	UploadTimeout time.Duration
+	// MaxRequestBodySize overrides the global request body limit; 0 keeps it
+	MaxRequestBodySize int64
+	Ranges             config.RangeConfig
+	// Header manipulation rules for backend requests and client responses
+	RequestHeaders  config.HeaderRules
+	ResponseHeaders config.HeaderRules
+	CachePolicy     config.CachePolicyConfig
+	// JSON body rewrites for requests and responses
+	RequestTransform  config.BodyTransformConfig
+	ResponseTransform config.BodyTransformConfig
+	// RequestSchema validates JSON request bodies; nil when not configured
+	RequestSchema            *jsonschema.Schema
+	MaxRequestSchemaBodySize int64
+	Retry                    config.RetryPolicyConfig
+	CircuitBreaker           config.CircuitBreakerConfig
+	Fallback                 config.FallbackConfig
+	Protected                bool
+	SandboxBackendURL        string
+	MaxInFlight              int
+	PriorityClass            string // critical, normal or low; empty is normal
+	OwnershipCheck           config.OwnershipCheckConfig
+	ProxyProtocol            string // v1 or v2 to send the client address to the backend
+	BackendAuth              config.BackendAuthConfig
+	Mirror                   config.MirrorConfig
+	// ForwardClientToken keeps the client's token instead of an identity token
+	ForwardClientToken bool
+	// TokenSources override the authorization token sources; nil uses them
+	TokenSources []config.TokenSource
+	// OneTimeToken rejects session tokens whose jti was seen before
+	OneTimeToken bool
+	// StepUp requires acr and amr values of session tokens
+	StepUp config.StepUpConfig
+	// Conditions are attribute-based access expressions evaluated by auth
+	Conditions []*expr.Expression
+	// Token issuer and audiences overriding the authorization defaults
+	ExpectedIssuer    string
+	ExpectedAudiences []string
+	// AuthProvider names the provider validating the route's tokens
+	AuthProvider string
+	// TransportSecurity refuses plaintext and weak TLS for the route
+	TransportSecurity config.TransportSecurityConfig
+	// RequestAge rejects requests with skewed client timestamps
+	RequestAge config.RequestAgeConfig
+	// Instances are additional addresses serving BackendURL
+	Instances        []string
+	OutlierDetection config.OutlierDetectionConfig
+	Priority         int // Lower number = higher priority
+	ParamNames       []string
 }
 
 // Match represents a successful route match with extracted parameters
@@ -52,15 +128,16 @@ func (r *Router) LoadRoutes(routes []config.RouteConfig) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	r.routes = make([]*Route, 0, len(routes))
-
+	// Compile all routes first so a failed reload keeps the current ones
+	compiled := make([]*Route, 0, len(routes))
 	for i, routeConfig := range routes {
 		route, err := r.compileRoute(routeConfig, i)
 		if err != nil {
 			return fmt.Errorf("failed to compile route %d (%s): %w", i, routeConfig.PathPattern, err)
 		}
-		r.routes = append(r.routes, route)
+		compiled = append(compiled, route)
 	}
+	r.routes = compiled
 
 	// Sort routes by priority (lower number = higher priority)
 	// Routes with exact matches should have higher priority
@@ -92,21 +169,103 @@ func (r *Router) compileRoute(cfg config.RouteConfig, index int) (*Route, error)
 	// Calculate priority based on pattern specificity
 	priority := r.calculatePriority(cfg.PathPattern)
 
-	// Convert timeout to milliseconds
-	timeoutMs := int64(cfg.Timeout.Milliseconds())
+	// Resolve per-phase timeouts; connect and response header timeouts are
+	// enforced by the route's transport, the total timeout by the proxy
+	timeout := cfg.Timeout
+	if cfg.Timeouts.Total > 0 {
+		timeout = cfg.Timeouts.Total
+	}
+	transport := cfg.Transport
+	if cfg.Timeouts.Connect > 0 {
+		transport.DialTimeout = cfg.Timeouts.Connect
+	}
+	if cfg.Timeouts.ResponseHeader > 0 {
+		transport.ResponseHeaderTimeout = cfg.Timeouts.ResponseHeader
+	}
+
+	conditions := make([]*expr.Expression, 0, len(cfg.Conditions))
+	for _, condition := range cfg.Conditions {
+		compiled, err := expr.Compile(condition)
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition %q: %w", condition, err)
+		}
+		conditions = append(conditions, compiled)
+	}
+
+	var requirement *expr.Requirement
+	if cfg.AuthExpression != "" {
+		var err error
+		requirement, err = expr.CompileRequirement(cfg.AuthExpression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid auth expression %q: %w", cfg.AuthExpression, err)
+		}
+	}
+
+	var requestSchema *jsonschema.Schema
+	if cfg.RequestSchema.Enabled() {
+		var err error
+		requestSchema, err = jsonschema.Compile(cfg.RequestSchema.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("invalid request schema: %w", err)
+		}
+	}
 
 	route := &Route{
-		PathPattern:    cfg.PathPattern,
-		CompiledRegex:  compiledRegex,
-		Methods:        methods,
-		BackendURL:     cfg.BackendURL,
-		Timeout:        timeoutMs,
-		AuthPolicy:     cfg.AuthPolicy,
-		RequiredRoles:  cfg.RequiredRoles,
-		RateLimits:     cfg.RateLimits,
-		StripPrefix:    cfg.StripPrefix,
-		Priority:       priority,
-		ParamNames:     paramNames,
+		PathPattern:              cfg.PathPattern,
+		CompiledRegex:            compiledRegex,
+		Methods:                  methods,
+		BackendURL:               cfg.BackendURL,
+		Timeout:                  timeout,
+		AuthPolicy:               cfg.AuthPolicy,
+		RequiredRoles:            cfg.RequiredRoles,
+		RequiredPermissions:      cfg.RequiredPermissions,
+		RequiredScopes:           cfg.RequiredScopes,
+		AuthExpression:           requirement,
+		RateLimits:               cfg.RateLimits,
+		ConcurrencyLimits:        cfg.ConcurrencyLimits,
+		Cost:                     cfg.Cost,
+		CostHeader:               cfg.CostHeader,
+		StripPrefix:              cfg.StripPrefix,
+		UpstreamTLS:              cfg.UpstreamTLS,
+		DecompressRequest:        cfg.DecompressRequest,
+		MaxDecompressedBodySize:  cfg.MaxDecompressedBodySize,
+		Transport:                transport,
+		UploadMode:               cfg.UploadMode,
+		UploadTimeout:            cfg.UploadTimeout,
+		MaxRequestBodySize:       cfg.MaxRequestBodySize,
+		Ranges:                   cfg.Ranges,
+		RequestHeaders:           cfg.RequestHeaders,
+		ResponseHeaders:          cfg.ResponseHeaders,
+		CachePolicy:              cfg.CachePolicy,
+		RequestTransform:         cfg.RequestTransform,
+		ResponseTransform:        cfg.ResponseTransform,
+		RequestSchema:            requestSchema,
+		MaxRequestSchemaBodySize: cfg.RequestSchema.MaxBodySize,
+		Retry:                    cfg.Retry,
+		CircuitBreaker:           cfg.CircuitBreaker,
+		Fallback:                 cfg.Fallback,
+		Protected:                cfg.Protected,
+		SandboxBackendURL:        cfg.SandboxBackendURL,
+		Instances:                cfg.Instances,
+		OutlierDetection:         cfg.OutlierDetection,
+		MaxInFlight:              cfg.MaxInFlight,
+		PriorityClass:            cfg.PriorityClass,
+		OwnershipCheck:           cfg.OwnershipCheck,
+		ProxyProtocol:            cfg.ProxyProtocol,
+		BackendAuth:              cfg.BackendAuth,
+		ForwardClientToken:       cfg.ForwardClientToken,
+		TokenSources:             cfg.TokenSources,
+		OneTimeToken:             cfg.OneTimeToken,
+		StepUp:                   cfg.StepUp,
+		Mirror:                   cfg.Mirror,
+		Conditions:               conditions,
+		ExpectedIssuer:           cfg.ExpectedIssuer,
+		ExpectedAudiences:        cfg.ExpectedAudiences,
+		AuthProvider:             cfg.AuthProvider,
+		TransportSecurity:        cfg.TransportSecurity,
+		RequestAge:               cfg.RequestAge,
+		Priority:                 priority,
+		ParamNames:               paramNames,
 	}
 
 	return route, nil
@@ -218,11 +377,11 @@ func (r *Router) Match(req *http.Request) (*Match, error) {
 		}
 
 		r.logger.Debug("route matched", logger.Fields{
-			"path":         path,
-			"method":       method,
-			"pattern":      route.PathPattern,
-			"backend_url":  route.BackendURL,
-			"params":       params,
+			"path":        path,
+			"method":      method,
+			"pattern":     route.PathPattern,
+			"backend_url": route.BackendURL,
+			"params":      params,
 		})
 
 		return &Match{
@@ -235,6 +394,23 @@ func (r *Router) Match(req *http.Request) (*Match, error) {
 	return nil, fmt.Errorf("no route found for %s %s", method, path)
 }
 
+// ErrProtectedRoute is returned when a request targets a protected route
+var ErrProtectedRoute = errors.New("route is protected")
+
+// GuardExperiment reports whether fault injection, experiments or dry runs may
+// target req. It returns ErrProtectedRoute when req matches a protected route.
+// Requests that match no route are not guarded.
+func (r *Router) GuardExperiment(req *http.Request) error {
+	match, err := r.Match(req)
+	if err != nil {
+		return nil
+	}
+	if match.Route.Protected {
+		return fmt.Errorf("%w: %s", ErrProtectedRoute, match.Route.PathPattern)
+	}
+	return nil
+}
+
 // GetRoutes returns all registered routes (for testing/debugging)
 func (r *Router) GetRoutes() []*Route {
 	r.mu.RLock()
@@ -245,7 +421,28 @@ func (r *Router) GetRoutes() []*Route {
 	return routes
 }
 
-// Reload reloads routes from configuration
+// OnReload registers a hook called after every successful reload
+func (r *Router) OnReload(hook ReloadHook) {
+	r.hooksMu.Lock()
+	defer r.hooksMu.Unlock()
+	r.reloadHooks = append(r.reloadHooks, hook)
+}
+
+// Reload reloads routes from configuration and runs the reload hooks. If a
+// route fails to compile, the current routes stay in place.
 func (r *Router) Reload(routes []config.RouteConfig) error {
-	return r.LoadRoutes(routes)
+	if err := r.LoadRoutes(routes); err != nil {
+		return err
+	}
+
+	r.hooksMu.Lock()
+	hooks := make([]ReloadHook, len(r.reloadHooks))
+	copy(hooks, r.reloadHooks)
+	r.hooksMu.Unlock()
+
+	loaded := r.GetRoutes()
+	for _, hook := range hooks {
+		hook(loaded)
+	}
+	return nil
 }
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"errors"
 	"net/http"
 	"os"
 	"testing"
@@ -182,28 +183,28 @@ func TestRouterMatch(t *testing.T) {
 	}
 
 	tests := []struct {
-		name           string
-		method         string
-		path           string
-		expectMatch    bool
+		name            string
+		method          string
+		path            string
+		expectMatch     bool
 		expectedBackend string
-		expectedParams map[string]string
+		expectedParams  map[string]string
 	}{
 		{
-			name:           "exact match GET",
-			method:         "GET",
-			path:           "/api/v1/users",
-			expectMatch:    true,
+			name:            "exact match GET",
+			method:          "GET",
+			path:            "/api/v1/users",
+			expectMatch:     true,
 			expectedBackend: "http://localhost:3001",
-			expectedParams: map[string]string{},
+			expectedParams:  map[string]string{},
 		},
 		{
-			name:           "exact match POST",
-			method:         "POST",
-			path:           "/api/v1/users",
-			expectMatch:    true,
+			name:            "exact match POST",
+			method:          "POST",
+			path:            "/api/v1/users",
+			expectMatch:     true,
 			expectedBackend: "http://localhost:3001",
-			expectedParams: map[string]string{},
+			expectedParams:  map[string]string{},
 		},
 		{
 			name:        "exact match wrong method",
@@ -212,28 +213,28 @@ func TestRouterMatch(t *testing.T) {
 			expectMatch: false,
 		},
 		{
-			name:           "parameter match",
-			method:         "GET",
-			path:           "/api/v1/users/123",
-			expectMatch:    true,
+			name:            "parameter match",
+			method:          "GET",
+			path:            "/api/v1/users/123",
+			expectMatch:     true,
 			expectedBackend: "http://localhost:3001",
-			expectedParams: map[string]string{"id": "123"},
+			expectedParams:  map[string]string{"id": "123"},
 		},
 		{
-			name:           "multiple parameters",
-			method:         "GET",
-			path:           "/api/v1/orders/456/items/789",
-			expectMatch:    true,
+			name:            "multiple parameters",
+			method:          "GET",
+			path:            "/api/v1/orders/456/items/789",
+			expectMatch:     true,
 			expectedBackend: "http://localhost:3002",
-			expectedParams: map[string]string{"orderId": "456", "itemId": "789"},
+			expectedParams:  map[string]string{"orderId": "456", "itemId": "789"},
 		},
 		{
-			name:           "wildcard match",
-			method:         "GET",
-			path:           "/api/v1/public/docs/readme.html",
-			expectMatch:    true,
+			name:            "wildcard match",
+			method:          "GET",
+			path:            "/api/v1/public/docs/readme.html",
+			expectMatch:     true,
 			expectedBackend: "http://localhost:3003",
-			expectedParams: map[string]string{},
+			expectedParams:  map[string]string{},
 		},
 		{
 			name:        "no match",
@@ -345,3 +346,144 @@ func TestRouterPriority(t *testing.T) {
 		t.Errorf("expected wildcard match, got %s", match.Route.BackendURL)
 	}
 }
+
+func TestGuardExperiment(t *testing.T) {
+	r := New()
+
+	routes := []config.RouteConfig{
+		{
+			PathPattern: "/api/v1/payments/**",
+			Methods:     []string{"GET", "POST"},
+			BackendURL:  "http://payments",
+			Timeout:     10 * time.Second,
+			Protected:   true,
+		},
+		{
+			PathPattern: "/api/v1/users/**",
+			Methods:     []string{"GET"},
+			BackendURL:  "http://users",
+			Timeout:     10 * time.Second,
+		},
+	}
+	if err := r.LoadRoutes(routes); err != nil {
+		t.Fatalf("failed to load routes: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		protected bool
+	}{
+		{name: "protected route", method: "POST", path: "/api/v1/payments/charge", protected: true},
+		{name: "unprotected route", method: "GET", path: "/api/v1/users/123", protected: false},
+		{name: "no matching route", method: "GET", path: "/other", protected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			err := r.GuardExperiment(req)
+			if got := errors.Is(err, ErrProtectedRoute); got != tt.protected {
+				t.Errorf("expected protected=%v, got error %v", tt.protected, err)
+			}
+		})
+	}
+}
+
+func TestCompileRouteTimeouts(t *testing.T) {
+	r := New()
+
+	tests := []struct {
+		name                   string
+		cfg                    config.RouteConfig
+		expectedTotal          time.Duration
+		expectedDial           time.Duration
+		expectedResponseHeader time.Duration
+	}{
+		{
+			name:          "timeout shorthand",
+			cfg:           config.RouteConfig{Timeout: 10 * time.Second},
+			expectedTotal: 10 * time.Second,
+		},
+		{
+			name: "per-phase timeouts",
+			cfg: config.RouteConfig{Timeouts: config.RouteTimeoutsConfig{
+				Connect:        time.Second,
+				ResponseHeader: 5 * time.Second,
+				Total:          30 * time.Second,
+			}},
+			expectedTotal:          30 * time.Second,
+			expectedDial:           time.Second,
+			expectedResponseHeader: 5 * time.Second,
+		},
+		{
+			name: "transport timeouts kept",
+			cfg: config.RouteConfig{Transport: config.TransportConfig{
+				DialTimeout:           2 * time.Second,
+				ResponseHeaderTimeout: 3 * time.Second,
+			}},
+			expectedDial:           2 * time.Second,
+			expectedResponseHeader: 3 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.PathPattern = "/api/v1/users"
+			tt.cfg.Methods = []string{"GET"}
+			tt.cfg.BackendURL = "http://users"
+
+			route, err := r.compileRoute(tt.cfg, 0)
+			if err != nil {
+				t.Fatalf("failed to compile route: %v", err)
+			}
+			if route.Timeout != tt.expectedTotal {
+				t.Errorf("expected total timeout %v, got %v", tt.expectedTotal, route.Timeout)
+			}
+			if route.Transport.DialTimeout != tt.expectedDial {
+				t.Errorf("expected dial timeout %v, got %v", tt.expectedDial, route.Transport.DialTimeout)
+			}
+			if route.Transport.ResponseHeaderTimeout != tt.expectedResponseHeader {
+				t.Errorf("expected response header timeout %v, got %v", tt.expectedResponseHeader, route.Transport.ResponseHeaderTimeout)
+			}
+		})
+	}
+}
+
+func TestRouterReloadHooks(t *testing.T) {
+	r := New()
+	if err := r.LoadRoutes([]config.RouteConfig{
+		{PathPattern: "/orders", Methods: []string{"GET"}, BackendURL: "http://orders-v1"},
+	}); err != nil {
+		t.Fatalf("LoadRoutes() error = %v", err)
+	}
+
+	var reloaded []*Route
+	r.OnReload(func(routes []*Route) { reloaded = routes })
+
+	if err := r.Reload([]config.RouteConfig{
+		{PathPattern: "/orders", Methods: []string{"GET"}, BackendURL: "http://orders-v2"},
+	}); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if len(reloaded) != 1 || reloaded[0].BackendURL != "http://orders-v2" {
+		t.Fatalf("expected the hook to get the new routes, got %+v", reloaded)
+	}
+
+	// A route that fails to compile keeps the current routes and skips hooks
+	reloaded = nil
+	err := r.Reload([]config.RouteConfig{
+		{PathPattern: "/orders", Methods: []string{"GET"}, BackendURL: "http://orders-v3"},
+		{PathPattern: "/broken", Methods: []string{"GET"}, BackendURL: "http://broken", AuthExpression: "scope:read AND"},
+	})
+	if err == nil {
+		t.Fatal("expected an invalid route to fail the reload")
+	}
+	if reloaded != nil {
+		t.Error("expected hooks not to run for a failed reload")
+	}
+	if routes := r.GetRoutes(); len(routes) != 1 || routes[0].BackendURL != "http://orders-v2" {
+		t.Errorf("expected the previous routes to stay in place, got %+v", routes)
+	}
+}
@@ -0,0 +1,757 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/auth"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/health"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/proxy"
+	"github.com/maltehedderich/api-gateway-go/internal/ratelimit"
+)
+
+// drainCheck is the health check registered while the instance is draining
+const drainCheck = "drain"
+
+// RouteInfo describes a route in admin API responses
+type RouteInfo struct {
+	PathPattern string   `json:"path_pattern"`
+	Methods     []string `json:"methods"`
+	BackendURL  string   `json:"backend_url"`
+	AuthPolicy  string   `json:"auth_policy,omitempty"`
+	Priority    int      `json:"priority"`
+	Protected   bool     `json:"protected,omitempty"`
+}
+
+// BreakerInfo describes a circuit breaker in admin API responses
+type BreakerInfo struct {
+	Name            string    `json:"name"`
+	State           string    `json:"state"`
+	Failures        int       `json:"failures"`
+	Successes       int       `json:"successes"`
+	LastFailureTime time.Time `json:"last_failure_time,omitempty"`
+	LastStateChange time.Time `json:"last_state_change"`
+	// Sliding window breakers report the calls in their window and the
+	// percentage of them that failed or were slow
+	Calls        int     `json:"calls,omitempty"`
+	FailureRate  float64 `json:"failure_rate,omitempty"`
+	SlowCallRate float64 `json:"slow_call_rate,omitempty"`
+}
+
+// BreakerEvent describes a circuit breaker state change in admin API
+// responses
+type BreakerEvent struct {
+	Name     string    `json:"name"`
+	From     string    `json:"from"`
+	To       string    `json:"to"`
+	Reason   string    `json:"reason"`
+	Failures int       `json:"failures"`
+	Time     time.Time `json:"time"`
+}
+
+// LogLevels describes the log levels in admin API responses
+type LogLevels struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components,omitempty"`
+}
+
+// APIKeyInfo describes an API key in admin API responses. Key hashes are
+// never returned; Key holds the plain key only right after it was issued.
+type APIKeyInfo struct {
+	ID            string    `json:"id"`
+	Key           string    `json:"key,omitempty"`
+	Roles         []string  `json:"roles,omitempty"`
+	Permissions   []string  `json:"permissions,omitempty"`
+	Tier          string    `json:"tier,omitempty"`
+	ExpiresAt     time.Time `json:"expires_at,omitzero"`
+	Revoked       bool      `json:"revoked,omitempty"`
+	CreatedAt     time.Time `json:"created_at,omitzero"`
+	ActiveSecrets int       `json:"active_secrets"`
+}
+
+// CompatInfo describes what a gateway build supports, so orchestration
+// tooling can check a configuration against a mixed-version fleet
+type CompatInfo struct {
+	Version                 string `json:"version"`
+	OS                      string `json:"os"`
+	Arch                    string `json:"arch"`
+	ConfigSchemaVersion     int    `json:"config_schema_version"`
+	SupportedSchemaVersions []int  `json:"supported_schema_versions"`
+	LoadedSchemaVersion     int    `json:"loaded_schema_version"`
+}
+
+// adminHandler returns the admin API handler used by gatewayctl
+func (s *Server) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /admin/compat", s.handleCompat)
+	mux.HandleFunc("GET /admin/routes", s.handleListRoutes)
+	mux.HandleFunc("GET /admin/routes/test", s.handleTestRoute)
+	mux.HandleFunc("GET /admin/circuit-breakers", s.handleListBreakers)
+	mux.HandleFunc("POST /admin/circuit-breakers/reset", s.handleResetBreakers)
+	mux.HandleFunc("GET /admin/circuit-breakers/history", s.handleBreakerHistory)
+	mux.HandleFunc("GET /admin/log-level", s.handleGetLogLevel)
+	mux.HandleFunc("PUT /admin/log-level", s.handleSetLogLevel)
+	mux.HandleFunc("POST /admin/drain", s.handleDrain)
+	mux.HandleFunc("DELETE /admin/drain", s.handleUndrain)
+	mux.HandleFunc("GET /admin/bans", s.handleListBans)
+	mux.HandleFunc("POST /admin/bans", s.handleAddBan)
+	mux.HandleFunc("DELETE /admin/bans", s.handleRemoveBan)
+	mux.HandleFunc("GET /admin/api-keys", s.handleListAPIKeys)
+	mux.HandleFunc("POST /admin/api-keys", s.handleCreateAPIKey)
+	mux.HandleFunc("POST /admin/api-keys/{id}/rotate", s.handleRotateAPIKey)
+	mux.HandleFunc("POST /admin/api-keys/{id}/revoke", s.handleRevokeAPIKey)
+	mux.HandleFunc("DELETE /admin/auth/decisions", s.handleInvalidateDecisions)
+	mux.HandleFunc("DELETE /admin/cache", s.handlePurgeCache)
+	mux.HandleFunc("GET /admin/quotas/{subject}", s.handleGetQuota)
+	mux.HandleFunc("PATCH /admin/quotas/{subject}", s.handleAdjustQuota)
+	mux.HandleFunc("GET /admin/rate-limits", s.handleListRateLimits)
+	mux.HandleFunc("DELETE /admin/rate-limits", s.handleResetRateLimit)
+	mux.HandleFunc("POST /admin/rate-limits/blocks", s.handleBlockRateLimitKey)
+	mux.HandleFunc("DELETE /admin/rate-limits/blocks", s.handleUnblockRateLimitKey)
+
+	token := s.config.Admin.Token
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" {
+			provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				writeAdminError(w, http.StatusUnauthorized, "unauthorized", "A valid admin token is required")
+				return
+			}
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// handleCompat reports the gateway version and the configuration schema
+// versions it loads
+func (s *Server) handleCompat(w http.ResponseWriter, r *http.Request) {
+	loaded := s.config.SchemaVersion
+	if loaded == 0 {
+		loaded = config.CurrentSchemaVersion
+	}
+	writeAdminJSON(w, http.StatusOK, CompatInfo{
+		Version:                 s.version,
+		OS:                      runtime.GOOS,
+		Arch:                    runtime.GOARCH,
+		ConfigSchemaVersion:     config.CurrentSchemaVersion,
+		SupportedSchemaVersions: config.SupportedSchemaVersions,
+		LoadedSchemaVersion:     loaded,
+	})
+}
+
+// handleListRoutes lists the loaded routes in matching order
+func (s *Server) handleListRoutes(w http.ResponseWriter, r *http.Request) {
+	routes := s.router.GetRoutes()
+	infos := make([]RouteInfo, 0, len(routes))
+	for _, route := range routes {
+		methods := make([]string, 0, len(route.Methods))
+		for method := range route.Methods {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+
+		infos = append(infos, RouteInfo{
+			PathPattern: route.PathPattern,
+			Methods:     methods,
+			BackendURL:  route.BackendURL,
+			AuthPolicy:  route.AuthPolicy,
+			Priority:    route.Priority,
+			Protected:   route.Protected,
+		})
+	}
+	writeAdminJSON(w, http.StatusOK, infos)
+}
+
+// handleTestRoute reports which route a request would match, given by the
+// method and path query parameters
+func (s *Server) handleTestRoute(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Query().Get("method")
+	if method == "" {
+		method = http.MethodGet
+	}
+	path := r.URL.Query().Get("path")
+	if !strings.HasPrefix(path, "/") {
+		writeAdminError(w, http.StatusBadRequest, "invalid_request", "path must start with /")
+		return
+	}
+
+	req, err := http.NewRequest(strings.ToUpper(method), path, nil)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	match, err := s.router.Match(req)
+	if err != nil {
+		writeAdminJSON(w, http.StatusOK, map[string]interface{}{
+			"matched": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, map[string]interface{}{
+		"matched":      true,
+		"path_pattern": match.Route.PathPattern,
+		"backend_url":  match.Route.BackendURL,
+		"auth_policy":  match.Route.AuthPolicy,
+		"params":       match.Params,
+	})
+}
+
+// handleListBreakers lists the circuit breakers created so far
+func (s *Server) handleListBreakers(w http.ResponseWriter, r *http.Request) {
+	stats := s.proxy.CircuitBreakers().GetStats()
+	infos := make([]BreakerInfo, 0, len(stats))
+	for _, stat := range stats {
+		infos = append(infos, BreakerInfo{
+			Name:            stat.Name,
+			State:           stat.State.String(),
+			Failures:        stat.Failures,
+			Successes:       stat.Successes,
+			LastFailureTime: stat.LastFailureTime,
+			LastStateChange: stat.LastStateChange,
+			Calls:           stat.Calls,
+			FailureRate:     stat.FailureRate,
+			SlowCallRate:    stat.SlowCallRate,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	writeAdminJSON(w, http.StatusOK, infos)
+}
+
+// handleResetBreakers closes the breaker given by the name query parameter,
+// or every breaker if no name is given
+func (s *Server) handleResetBreakers(w http.ResponseWriter, r *http.Request) {
+	breakers := s.proxy.CircuitBreakers()
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		breakers.ResetAll()
+	} else if err := breakers.Reset(name); err != nil {
+		writeAdminError(w, http.StatusNotFound, "not_found", err.Error())
+		return
+	}
+
+	s.logger.Info("circuit breakers reset via admin API", logger.Fields{
+		"name": name,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleBreakerHistory lists the last state changes of the breaker given by
+// the name query parameter, or of every breaker, oldest first
+func (s *Server) handleBreakerHistory(w http.ResponseWriter, r *http.Request) {
+	history := s.proxy.CircuitBreakers().History(r.URL.Query().Get("name"))
+	events := make([]BreakerEvent, 0, len(history))
+	for _, event := range history {
+		events = append(events, BreakerEvent{
+			Name:     event.Name,
+			From:     event.From.String(),
+			To:       event.To.String(),
+			Reason:   event.Reason,
+			Failures: event.Failures,
+			Time:     event.Time,
+		})
+	}
+	writeAdminJSON(w, http.StatusOK, events)
+}
+
+// handleGetLogLevel returns the current log levels
+func (s *Server) handleGetLogLevel(w http.ResponseWriter, r *http.Request) {
+	level, components := logger.Get().Levels()
+	levels := LogLevels{Level: strings.ToLower(level.String())}
+	if len(components) > 0 {
+		levels.Components = make(map[string]string, len(components))
+		for component, componentLevel := range components {
+			levels.Components[component] = strings.ToLower(componentLevel.String())
+		}
+	}
+	writeAdminJSON(w, http.StatusOK, levels)
+}
+
+// handleSetLogLevel changes the global log level, or the level of a single
+// component if one is given
+func (s *Server) handleSetLogLevel(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Level     string `json:"level"`
+		Component string `json:"component"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAdminError(w, http.StatusBadRequest, "invalid_request", "request body must be JSON")
+		return
+	}
+	level, err := logger.ParseLevel(req.Level)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	if req.Component == "" {
+		logger.Get().SetLevel(level)
+	} else {
+		logger.Get().SetComponentLevel(req.Component, level)
+	}
+	s.logger.Info("log level changed via admin API", logger.Fields{
+		"level":     level.String(),
+		"component": req.Component,
+	})
+	s.handleGetLogLevel(w, r)
+}
+
+// handleDrain fails the readiness probe so load balancers stop sending new
+// traffic, while in-flight and direct requests are still served
+func (s *Server) handleDrain(w http.ResponseWriter, r *http.Request) {
+	s.healthManager.Register(drainCheck, func() health.Check {
+		return health.Check{
+			Name:   drainCheck,
+			Status: health.StatusUnhealthy,
+			Error:  "instance is draining",
+		}
+	})
+	s.logger.Warn("instance draining via admin API")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleUndrain makes the instance ready again
+func (s *Server) handleUndrain(w http.ResponseWriter, r *http.Request) {
+	s.healthManager.Unregister(drainCheck)
+	s.logger.Info("instance drain cancelled via admin API")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListBans lists the active bans
+func (s *Server) handleListBans(w http.ResponseWriter, r *http.Request) {
+	writeAdminJSON(w, http.StatusOK, s.bans.list())
+}
+
+// handleAddBan bans an IP address or CIDR, optionally for a duration
+func (s *Server) handleAddBan(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Target   string `json:"target"`
+		Reason   string `json:"reason"`
+		Duration string `json:"duration"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAdminError(w, http.StatusBadRequest, "invalid_request", "request body must be JSON")
+		return
+	}
+
+	var duration time.Duration
+	if req.Duration != "" {
+		var err error
+		duration, err = time.ParseDuration(req.Duration)
+		if err != nil || duration < 0 {
+			writeAdminError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("invalid duration: %q", req.Duration))
+			return
+		}
+	}
+
+	ban, err := s.bans.add(req.Target, req.Reason, duration)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	s.logger.Warn("client banned via admin API", logger.Fields{
+		"target":   ban.Target,
+		"reason":   ban.Reason,
+		"duration": duration.String(),
+	})
+	writeAdminJSON(w, http.StatusCreated, ban)
+}
+
+// handleRemoveBan lifts the ban given by the target query parameter
+func (s *Server) handleRemoveBan(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if !s.bans.remove(target) {
+		writeAdminError(w, http.StatusNotFound, "not_found", fmt.Sprintf("no ban for %q", target))
+		return
+	}
+	s.logger.Info("client ban lifted via admin API", logger.Fields{
+		"target": target,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleInvalidateDecisions drops the cached authorization decisions of the
+// user given by the user_id query parameter, or all cached decisions if no
+// user is given, e.g. after roles were changed
+func (s *Server) handleInvalidateDecisions(w http.ResponseWriter, r *http.Request) {
+	if s.authMiddleware == nil {
+		writeAdminError(w, http.StatusNotFound, "not_found", "authorization is not enabled")
+		return
+	}
+	userID := r.URL.Query().Get("user_id")
+	removed := s.authMiddleware.InvalidateDecisions(userID)
+	writeAdminJSON(w, http.StatusOK, map[string]interface{}{
+		"user_id":     userID,
+		"invalidated": removed,
+	})
+}
+
+// handlePurgeCache removes cached responses selected by the key, route and
+// pattern query parameters from the response cache and the configured CDN,
+// e.g. after the backend changed the content behind a surrogate key
+func (s *Server) handlePurgeCache(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	purge := proxy.CachePurge{
+		Key:     query.Get("key"),
+		Route:   query.Get("route"),
+		Pattern: query.Get("pattern"),
+	}
+	if err := purge.Validate(); err != nil {
+		writeAdminError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	purged := s.proxy.PurgeResponseCache(purge)
+
+	cdn := "purged"
+	switch err := s.proxy.PurgeCDN(r.Context(), purge); {
+	case errors.Is(err, proxy.ErrCDNPurgeDisabled):
+		cdn = "disabled"
+	case errors.Is(err, proxy.ErrCDNPurgeUnsupported):
+		cdn = "unsupported"
+	case err != nil:
+		s.logger.Error("CDN purge failed", logger.Fields{
+			"key":     purge.Key,
+			"route":   purge.Route,
+			"pattern": purge.Pattern,
+			"error":   err.Error(),
+		})
+		writeAdminError(w, http.StatusBadGateway, "cdn_purge_failed",
+			fmt.Sprintf("purged %d cached responses, but the CDN purge failed: %v", purged, err))
+		return
+	}
+
+	s.logger.Info("response cache purged via admin API", logger.Fields{
+		"key":     purge.Key,
+		"route":   purge.Route,
+		"pattern": purge.Pattern,
+		"purged":  purged,
+		"cdn":     cdn,
+	})
+	writeAdminJSON(w, http.StatusOK, map[string]interface{}{
+		"purged": purged,
+		"cdn":    cdn,
+	})
+}
+
+// quotaManager returns the quota manager, writing a 404 if quotas are not
+// enabled
+func (s *Server) quotaManager(w http.ResponseWriter) *ratelimit.QuotaManager {
+	if s.rateLimiter == nil || s.rateLimiter.Quotas() == nil {
+		writeAdminError(w, http.StatusNotFound, "not_found", "quotas are not enabled")
+		return nil
+	}
+	return s.rateLimiter.Quotas()
+}
+
+// handleGetQuota returns the quota of a subject, such as key:<id> or
+// user:<id>, in the current period. The tier query parameter selects the
+// budget of a rate limit tier.
+func (s *Server) handleGetQuota(w http.ResponseWriter, r *http.Request) {
+	quotas := s.quotaManager(w)
+	if quotas == nil {
+		return
+	}
+	quota, err := quotas.Get(r.Context(), r.PathValue("subject"), r.URL.Query().Get("tier"))
+	if err != nil {
+		writeAdminError(w, http.StatusServiceUnavailable, "quota_store_unavailable", err.Error())
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, quota)
+}
+
+// handleAdjustQuota sets or changes the usage of a subject in the current
+// period; a negative add grants extra requests
+func (s *Server) handleAdjustQuota(w http.ResponseWriter, r *http.Request) {
+	quotas := s.quotaManager(w)
+	if quotas == nil {
+		return
+	}
+	var req struct {
+		Tier string `json:"tier"`
+		Used *int64 `json:"used"`
+		Add  int64  `json:"add"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAdminError(w, http.StatusBadRequest, "invalid_request", "request body must be JSON")
+		return
+	}
+	if req.Used == nil && req.Add == 0 {
+		writeAdminError(w, http.StatusBadRequest, "invalid_request", "used or add is required")
+		return
+	}
+	if req.Used != nil && *req.Used < 0 {
+		writeAdminError(w, http.StatusBadRequest, "invalid_request", "used must not be negative")
+		return
+	}
+
+	subject := r.PathValue("subject")
+	quota, err := quotas.Adjust(r.Context(), subject, req.Tier, req.Used, req.Add)
+	if err != nil {
+		writeAdminError(w, http.StatusServiceUnavailable, "quota_store_unavailable", err.Error())
+		return
+	}
+	s.logger.Info("quota adjusted via admin API", logger.Fields{
+		"subject": subject,
+		"used":    quota.Used,
+		"add":     req.Add,
+	})
+	writeAdminJSON(w, http.StatusOK, quota)
+}
+
+// limiter returns the rate limiter, writing a 404 if rate limiting is not
+// enabled
+func (s *Server) limiter(w http.ResponseWriter) *ratelimit.Limiter {
+	if s.rateLimiter == nil {
+		writeAdminError(w, http.StatusNotFound, "not_found", "rate limiting is not enabled")
+		return nil
+	}
+	return s.rateLimiter
+}
+
+// writeRateLimitStoreError reports a failure of the rate limit storage
+func writeRateLimitStoreError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ratelimit.ErrInspectionUnsupported) {
+		writeAdminError(w, http.StatusNotImplemented, "not_supported", err.Error())
+		return
+	}
+	writeAdminError(w, http.StatusServiceUnavailable, "rate_limit_store_unavailable", err.Error())
+}
+
+// handleListRateLimits lists the state of the rate limit keys starting with
+// the prefix query parameter, such as ratelimit:user:alice, or of all keys
+func (s *Server) handleListRateLimits(w http.ResponseWriter, r *http.Request) {
+	limiter := s.limiter(w)
+	if limiter == nil {
+		return
+	}
+	keys, err := limiter.Keys(r.Context(), r.URL.Query().Get("prefix"))
+	if err != nil {
+		writeRateLimitStoreError(w, err)
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, keys)
+}
+
+// handleResetRateLimit deletes the state of the key given by the key query
+// parameter, so its limits start over
+func (s *Server) handleResetRateLimit(w http.ResponseWriter, r *http.Request) {
+	limiter := s.limiter(w)
+	if limiter == nil {
+		return
+	}
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		writeAdminError(w, http.StatusBadRequest, "invalid_request", "key is required")
+		return
+	}
+	if err := limiter.Reset(r.Context(), key); err != nil {
+		writeRateLimitStoreError(w, err)
+		return
+	}
+	s.logger.Info("rate limit key reset via admin API", logger.Fields{
+		"key": key,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleBlockRateLimitKey rejects the requests of a rate limit key on this
+// instance for a duration
+func (s *Server) handleBlockRateLimitKey(w http.ResponseWriter, r *http.Request) {
+	limiter := s.limiter(w)
+	if limiter == nil {
+		return
+	}
+	var req struct {
+		Key      string `json:"key"`
+		Duration string `json:"duration"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAdminError(w, http.StatusBadRequest, "invalid_request", "request body must be JSON")
+		return
+	}
+	if req.Key == "" {
+		writeAdminError(w, http.StatusBadRequest, "invalid_request", "key is required")
+		return
+	}
+	duration, err := time.ParseDuration(req.Duration)
+	if err != nil || duration <= 0 {
+		writeAdminError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("invalid duration: %q", req.Duration))
+		return
+	}
+
+	until := limiter.Block(req.Key, duration)
+	s.logger.Warn("rate limit key blocked via admin API", logger.Fields{
+		"key":      req.Key,
+		"duration": duration.String(),
+	})
+	writeAdminJSON(w, http.StatusCreated, ratelimit.KeyState{Key: req.Key, BlockedUntil: until})
+}
+
+// handleUnblockRateLimitKey lifts the block of the key given by the key query
+// parameter
+func (s *Server) handleUnblockRateLimitKey(w http.ResponseWriter, r *http.Request) {
+	limiter := s.limiter(w)
+	if limiter == nil {
+		return
+	}
+	key := r.URL.Query().Get("key")
+	if !limiter.Unblock(key) {
+		writeAdminError(w, http.StatusNotFound, "not_found", fmt.Sprintf("no block for %q", key))
+		return
+	}
+	s.logger.Info("rate limit key unblocked via admin API", logger.Fields{
+		"key": key,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiKeyManager returns the API key manager, writing a 404 if API keys are
+// not enabled
+func (s *Server) apiKeyManager(w http.ResponseWriter) *auth.APIKeyManager {
+	if s.authMiddleware == nil || s.authMiddleware.APIKeys() == nil {
+		writeAdminError(w, http.StatusNotFound, "not_found", "API key authentication is not enabled")
+		return nil
+	}
+	return s.authMiddleware.APIKeys()
+}
+
+// handleListAPIKeys lists the API keys without their key material
+func (s *Server) handleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	manager := s.apiKeyManager(w)
+	if manager == nil {
+		return
+	}
+	keys, err := manager.List(r.Context())
+	if err != nil {
+		writeAdminError(w, http.StatusServiceUnavailable, "api_key_store_unavailable", err.Error())
+		return
+	}
+	infos := make([]APIKeyInfo, 0, len(keys))
+	for _, key := range keys {
+		infos = append(infos, newAPIKeyInfo(key, ""))
+	}
+	writeAdminJSON(w, http.StatusOK, infos)
+}
+
+// handleCreateAPIKey issues a new API key. The plain key is only returned in
+// this response.
+func (s *Server) handleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	manager := s.apiKeyManager(w)
+	if manager == nil {
+		return
+	}
+	var req struct {
+		ID          string    `json:"id"`
+		Roles       []string  `json:"roles"`
+		Permissions []string  `json:"permissions"`
+		Tier        string    `json:"tier"`
+		ExpiresAt   time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAdminError(w, http.StatusBadRequest, "invalid_request", "request body must be JSON")
+		return
+	}
+	if req.ID == "" {
+		writeAdminError(w, http.StatusBadRequest, "invalid_request", "id is required")
+		return
+	}
+	if req.Tier != "" {
+		if _, ok := s.config.RateLimit.Tiers[req.Tier]; !ok {
+			writeAdminError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("unknown rate limit tier: %q", req.Tier))
+			return
+		}
+	}
+
+	plain, key, err := manager.Create(r.Context(), &auth.APIKey{
+		ID:          req.ID,
+		Roles:       req.Roles,
+		Permissions: req.Permissions,
+		Tier:        req.Tier,
+		ExpiresAt:   req.ExpiresAt,
+	})
+	if err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+	writeAdminJSON(w, http.StatusCreated, newAPIKeyInfo(key, plain))
+}
+
+// handleRotateAPIKey issues a new key for an API key; the previous key stays
+// valid for the rotation grace period
+func (s *Server) handleRotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	manager := s.apiKeyManager(w)
+	if manager == nil {
+		return
+	}
+	plain, key, err := manager.Rotate(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, newAPIKeyInfo(key, plain))
+}
+
+// handleRevokeAPIKey revokes an API key immediately
+func (s *Server) handleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	manager := s.apiKeyManager(w)
+	if manager == nil {
+		return
+	}
+	key, err := manager.Revoke(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, newAPIKeyInfo(key, ""))
+}
+
+func newAPIKeyInfo(key *auth.APIKey, plain string) APIKeyInfo {
+	return APIKeyInfo{
+		ID:            key.ID,
+		Key:           plain,
+		Roles:         key.Roles,
+		Permissions:   key.Permissions,
+		Tier:          key.Tier,
+		ExpiresAt:     key.ExpiresAt,
+		Revoked:       key.Revoked,
+		CreatedAt:     key.CreatedAt,
+		ActiveSecrets: key.ActiveSecrets(time.Now()),
+	}
+}
+
+// writeAPIKeyError maps API key manager errors to admin API errors
+func writeAPIKeyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrAPIKeyNotFound):
+		writeAdminError(w, http.StatusNotFound, "not_found", err.Error())
+	case errors.Is(err, auth.ErrAPIKeyExists), errors.Is(err, auth.ErrAPIKeyRevoked):
+		writeAdminError(w, http.StatusConflict, "conflict", err.Error())
+	default:
+		writeAdminError(w, http.StatusServiceUnavailable, "api_key_store_unavailable", err.Error())
+	}
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeAdminError(w http.ResponseWriter, status int, code, message string) {
+	writeAdminJSON(w, status, map[string]string{
+		"error":   code,
+		"message": message,
+	})
+}
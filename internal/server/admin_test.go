@@ -0,0 +1,427 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/circuitbreaker"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/health"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/ratelimit"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+
+	cfg := &config.Config{
+		Admin: config.AdminConfig{Enabled: true, Address: "127.0.0.1:0", Token: "secret"},
+		Routes: []config.RouteConfig{
+			{PathPattern: "/api/users/{id}", Methods: []string{"GET"}, BackendURL: "http://users:8080", AuthPolicy: "public"},
+		},
+	}
+	srv, err := New(cfg, health.NewManager())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return srv
+}
+
+func adminRequest(t *testing.T, handler http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAdminRequiresToken(t *testing.T) {
+	s := newTestServer(t)
+
+	rr := httptest.NewRecorder()
+	s.adminHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/routes", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without token, got %d", rr.Code)
+	}
+}
+
+func TestAdminRoutes(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.adminHandler()
+
+	rr := adminRequest(t, handler, http.MethodGet, "/admin/routes", "")
+	var routes []RouteInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &routes); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if len(routes) != 1 || routes[0].PathPattern != "/api/users/{id}" {
+		t.Errorf("unexpected routes: %+v", routes)
+	}
+
+	rr = adminRequest(t, handler, http.MethodGet, "/admin/routes/test?method=GET&path=/api/users/42", "")
+	var result map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if result["matched"] != true || result["params"].(map[string]interface{})["id"] != "42" {
+		t.Errorf("unexpected route test result: %v", result)
+	}
+}
+
+func TestAdminCompat(t *testing.T) {
+	s := newTestServer(t)
+	s.SetVersion("1.2.3")
+
+	rr := adminRequest(t, s.adminHandler(), http.MethodGet, "/admin/compat", "")
+	var info CompatInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if info.Version != "1.2.3" || info.LoadedSchemaVersion != config.CurrentSchemaVersion || len(info.SupportedSchemaVersions) == 0 {
+		t.Errorf("unexpected compat info: %+v", info)
+	}
+}
+
+func TestAdminBreakerHistory(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.adminHandler()
+
+	breakers := s.proxy.CircuitBreakers()
+	cb := breakers.Get("http://users:8080", &circuitbreaker.Config{FailureThreshold: 1, SuccessThreshold: 1, Timeout: time.Minute, MaxRequests: 1})
+	_ = cb.Execute(func() error { return errors.New("connection refused") })
+	if rr := adminRequest(t, handler, http.MethodPost, "/admin/circuit-breakers/reset", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rr.Code)
+	}
+
+	rr := adminRequest(t, handler, http.MethodGet, "/admin/circuit-breakers/history?name=http://users:8080", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var events []BreakerEvent
+	if err := json.Unmarshal(rr.Body.Bytes(), &events); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %+v", events)
+	}
+	if events[0].From != "closed" || events[0].To != "open" || events[0].Reason != "failure threshold reached" {
+		t.Errorf("unexpected opening event %+v", events[0])
+	}
+	if events[1].To != "closed" || events[1].Reason != "reset" {
+		t.Errorf("unexpected reset event %+v", events[1])
+	}
+
+	rr = adminRequest(t, handler, http.MethodGet, "/admin/circuit-breakers/history?name=http://orders:8080", "")
+	if strings.TrimSpace(rr.Body.String()) != "[]" {
+		t.Errorf("expected no events of unknown breaker, got %s", rr.Body.String())
+	}
+}
+
+func TestAdminDrain(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.adminHandler()
+
+	if rr := adminRequest(t, handler, http.MethodPost, "/admin/drain", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rr.Code)
+	}
+	if status := s.healthManager.Check().Status; status != health.StatusUnhealthy {
+		t.Errorf("expected draining instance to be unready, got %s", status)
+	}
+
+	adminRequest(t, handler, http.MethodDelete, "/admin/drain", "")
+	if status := s.healthManager.Check().Status; status != health.StatusHealthy {
+		t.Errorf("expected instance to be ready after undrain, got %s", status)
+	}
+}
+
+func TestAdminBans(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.adminHandler()
+	protected := s.banMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/users/42", nil)
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		protected.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	rr := adminRequest(t, handler, http.MethodPost, "/admin/bans", `{"target": "203.0.113.0/24", "reason": "abuse"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if code := request("203.0.113.9:4000"); code != http.StatusForbidden {
+		t.Errorf("expected banned client to get 403, got %d", code)
+	}
+	if code := request("198.51.100.1:4000"); code != http.StatusOK {
+		t.Errorf("expected other client to pass, got %d", code)
+	}
+
+	if rr := adminRequest(t, handler, http.MethodPost, "/admin/bans", `{"target": "not-an-ip"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid target, got %d", rr.Code)
+	}
+
+	if rr := adminRequest(t, handler, http.MethodDelete, "/admin/bans?target=203.0.113.0/24", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rr.Code)
+	}
+	if code := request("203.0.113.9:4000"); code != http.StatusOK {
+		t.Errorf("expected client to pass after unban, got %d", code)
+	}
+}
+
+func TestAdminAPIKeys(t *testing.T) {
+	if rr := adminRequest(t, newTestServer(t).adminHandler(), http.MethodGet, "/admin/api-keys", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without API keys, got %d", rr.Code)
+	}
+
+	cfg := &config.Config{
+		Admin: config.AdminConfig{Enabled: true, Address: "127.0.0.1:0", Token: "secret"},
+		Authorization: config.AuthorizationConfig{
+			Enabled:             true,
+			JWTSigningAlgorithm: "HS256",
+			JWTSharedSecret:     "test-secret",
+			APIKeys:             config.APIKeyConfig{Enabled: true, Header: "X-Api-Key", Store: "config", RotationGracePeriod: time.Hour},
+		},
+		RateLimit: config.RateLimitConfig{
+			Tiers: map[string][]config.LimitDefinition{"partner": {{Key: "user", Limit: 1000, Window: "1m"}}},
+		},
+	}
+	s, err := New(cfg, health.NewManager())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	handler := s.adminHandler()
+
+	rr := adminRequest(t, handler, http.MethodPost, "/admin/api-keys", `{"id": "ci", "roles": ["deployer"], "tier": "partner"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created APIKeyInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if created.Key == "" || created.ActiveSecrets != 1 {
+		t.Errorf("expected the new key in the response, got %+v", created)
+	}
+
+	if rr := adminRequest(t, handler, http.MethodPost, "/admin/api-keys", `{"id": "ci"}`); rr.Code != http.StatusConflict {
+		t.Errorf("expected 409 for duplicate id, got %d", rr.Code)
+	}
+	if rr := adminRequest(t, handler, http.MethodPost, "/admin/api-keys", `{"id": "other", "tier": "gold"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown tier, got %d", rr.Code)
+	}
+
+	rr = adminRequest(t, handler, http.MethodPost, "/admin/api-keys/ci/rotate", "")
+	var rotated APIKeyInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &rotated); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if rotated.Key == "" || rotated.Key == created.Key || rotated.ActiveSecrets != 2 {
+		t.Errorf("unexpected rotation result: %+v", rotated)
+	}
+
+	if rr := adminRequest(t, handler, http.MethodPost, "/admin/api-keys/ci/revoke", ""); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if rr := adminRequest(t, handler, http.MethodPost, "/admin/api-keys/unknown/revoke", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown key, got %d", rr.Code)
+	}
+
+	rr = adminRequest(t, handler, http.MethodGet, "/admin/api-keys", "")
+	if strings.Contains(rr.Body.String(), created.Key) || strings.Contains(rr.Body.String(), "hash") {
+		t.Errorf("listing must not expose key material: %s", rr.Body.String())
+	}
+	var keys []APIKeyInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &keys); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if len(keys) != 1 || !keys[0].Revoked {
+		t.Errorf("expected one revoked key, got %+v", keys)
+	}
+}
+
+func TestAdminQuotas(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.adminHandler()
+	if rr := adminRequest(t, handler, http.MethodGet, "/admin/quotas/key:ci", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without quotas, got %d", rr.Code)
+	}
+
+	limiter, err := ratelimit.NewLimiter(&config.RateLimitConfig{
+		Backend:     "memory",
+		FailureMode: "fail-closed",
+		Quota: config.QuotaConfig{
+			Enabled:  true,
+			Store:    "memory",
+			Period:   "daily",
+			Timezone: "UTC",
+			Limit:    100,
+			Tiers:    map[string]int64{"partner": 1000},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewLimiter() error = %v", err)
+	}
+	defer limiter.Close()
+	s.rateLimiter = limiter
+
+	rr := adminRequest(t, handler, http.MethodPatch, "/admin/quotas/key:ci", `{"used": 40, "add": 2}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var quota ratelimit.Quota
+	if err := json.Unmarshal(rr.Body.Bytes(), &quota); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if quota.Used != 42 || quota.Remaining != 58 {
+		t.Errorf("expected 42 used and 58 remaining, got %+v", quota)
+	}
+
+	rr = adminRequest(t, handler, http.MethodGet, "/admin/quotas/key:ci?tier=partner", "")
+	if err := json.Unmarshal(rr.Body.Bytes(), &quota); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if quota.Limit != 1000 || quota.Used != 42 {
+		t.Errorf("expected partner budget with 42 used, got %+v", quota)
+	}
+
+	if rr := adminRequest(t, handler, http.MethodPatch, "/admin/quotas/key:ci", `{}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without an adjustment, got %d", rr.Code)
+	}
+}
+
+func TestAdminRateLimits(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.adminHandler()
+	if rr := adminRequest(t, handler, http.MethodGet, "/admin/rate-limits", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without rate limiting, got %d", rr.Code)
+	}
+
+	limiter, err := ratelimit.NewLimiter(&config.RateLimitConfig{Backend: "memory", FailureMode: "fail-closed"})
+	if err != nil {
+		t.Fatalf("NewLimiter() error = %v", err)
+	}
+	defer limiter.Close()
+	s.rateLimiter = limiter
+
+	limit := &config.LimitDefinition{Key: "ip", Limit: 5, Window: "1m"}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	if _, err := limiter.Allow(req.Context(), req, limit); err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+
+	keys := func() []ratelimit.KeyState {
+		rr := adminRequest(t, handler, http.MethodGet, "/admin/rate-limits?prefix=ratelimit:ip:", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var keys []ratelimit.KeyState
+		if err := json.Unmarshal(rr.Body.Bytes(), &keys); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		return keys
+	}
+	if got := keys(); len(got) != 1 || got[0].Key != "ratelimit:ip:192.0.2.1" || got[0].Tokens == nil || int(*got[0].Tokens) != 4 {
+		t.Errorf("expected ratelimit:ip:192.0.2.1 with 4 tokens, got %+v", got)
+	}
+
+	if rr := adminRequest(t, handler, http.MethodPost, "/admin/rate-limits/blocks", `{"key": "ratelimit:ip:192.0.2.1", "duration": "10m"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if result, _ := limiter.Allow(req.Context(), req, limit); result.Allowed {
+		t.Error("expected blocked key to be rejected")
+	}
+	if rr := adminRequest(t, handler, http.MethodPost, "/admin/rate-limits/blocks", `{"key": "ratelimit:ip:192.0.2.1"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a duration, got %d", rr.Code)
+	}
+
+	if rr := adminRequest(t, handler, http.MethodDelete, "/admin/rate-limits?key=ratelimit:ip:192.0.2.1", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := keys(); len(got) != 1 || got[0].Tokens != nil || got[0].BlockedUntil.IsZero() {
+		t.Errorf("expected only the block to remain after the reset, got %+v", got)
+	}
+
+	if rr := adminRequest(t, handler, http.MethodDelete, "/admin/rate-limits/blocks?key=ratelimit:ip:192.0.2.1", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if result, _ := limiter.Allow(req.Context(), req, limit); !result.Allowed || result.Remaining != 4 {
+		t.Errorf("expected a full bucket after reset and unblock, got %+v", result)
+	}
+	if rr := adminRequest(t, handler, http.MethodDelete, "/admin/rate-limits/blocks?key=ratelimit:ip:192.0.2.1", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a key without block, got %d", rr.Code)
+	}
+}
+
+func TestAdminInvalidateDecisions(t *testing.T) {
+	if rr := adminRequest(t, newTestServer(t).adminHandler(), http.MethodDelete, "/admin/auth/decisions", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without authorization, got %d", rr.Code)
+	}
+
+	cfg := &config.Config{
+		Admin: config.AdminConfig{Enabled: true, Address: "127.0.0.1:0", Token: "secret"},
+		Authorization: config.AuthorizationConfig{
+			Enabled:             true,
+			JWTSigningAlgorithm: "HS256",
+			JWTSharedSecret:     "test-secret",
+			CacheAuthDecisions:  true,
+			CacheDecisionTTL:    time.Minute,
+		},
+	}
+	s, err := New(cfg, health.NewManager())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	rr := adminRequest(t, s.adminHandler(), http.MethodDelete, "/admin/auth/decisions?user_id=alice", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var result struct {
+		UserID      string `json:"user_id"`
+		Invalidated int    `json:"invalidated"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if result.UserID != "alice" || result.Invalidated != 0 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestAdminPurgeCache(t *testing.T) {
+	handler := newTestServer(t).adminHandler()
+
+	for _, target := range []string{"/admin/cache", "/admin/cache?pattern=%5B"} {
+		if rr := adminRequest(t, handler, http.MethodDelete, target, ""); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", target, rr.Code)
+		}
+	}
+
+	rr := adminRequest(t, handler, http.MethodDelete, "/admin/cache?key=product-42", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var result struct {
+		Purged int    `json:"purged"`
+		CDN    string `json:"cdn"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if result.Purged != 0 || result.CDN != "disabled" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/clientip"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+)
+
+// Ban blocks a client address or network from the gateway
+type Ban struct {
+	Target    string    `json:"target"` // IP address or CIDR
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"` // zero for permanent bans
+	network   *net.IPNet
+}
+
+// banList holds the bans managed through the admin API. Bans live in memory
+// only and are lost on restart.
+type banList struct {
+	mu   sync.RWMutex
+	bans map[string]*Ban
+}
+
+func newBanList() *banList {
+	return &banList{bans: make(map[string]*Ban)}
+}
+
+// add bans target, an IP address or CIDR, for duration (zero for permanent)
+func (b *banList) add(target, reason string, duration time.Duration) (*Ban, error) {
+	network, err := parseBanTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	ban := &Ban{
+		Target:    network.String(),
+		Reason:    reason,
+		CreatedAt: time.Now().UTC(),
+		network:   network,
+	}
+	if duration > 0 {
+		ban.ExpiresAt = ban.CreatedAt.Add(duration)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bans[ban.Target] = ban
+	return ban, nil
+}
+
+// remove lifts the ban on target and reports whether one existed
+func (b *banList) remove(target string) bool {
+	network, err := parseBanTarget(target)
+	if err != nil {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.bans[network.String()]
+	delete(b.bans, network.String())
+	return ok
+}
+
+// list returns the active bans ordered by target
+func (b *banList) list() []*Ban {
+	now := time.Now()
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	bans := make([]*Ban, 0, len(b.bans))
+	for _, ban := range b.bans {
+		if ban.active(now) {
+			bans = append(bans, ban)
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Target < bans[j].Target })
+	return bans
+}
+
+// banned returns the ban matching ip, if any
+func (b *banList) banned(ip net.IP) *Ban {
+	now := time.Now()
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, ban := range b.bans {
+		if ban.active(now) && ban.network.Contains(ip) {
+			return ban
+		}
+	}
+	return nil
+}
+
+func (ban *Ban) active(now time.Time) bool {
+	return ban.ExpiresAt.IsZero() || now.Before(ban.ExpiresAt)
+}
+
+// parseBanTarget parses an IP address or CIDR into a network
+func parseBanTarget(target string) (*net.IPNet, error) {
+	if _, network, err := net.ParseCIDR(target); err == nil {
+		return network, nil
+	}
+	ip := net.ParseIP(target)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address or CIDR: %q", target)
+	}
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// banMiddleware rejects requests from banned clients
+func (s *Server) banMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := clientip.FromRequest(r)
+		ip := net.ParseIP(host)
+		if ip == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if ban := s.bans.banned(ip); ban != nil {
+			correlationID := logger.GetCorrelationID(r.Context())
+			s.logger.Info("request from banned client rejected", logger.Fields{
+				"correlation_id": correlationID,
+				"client_ip":      host,
+				"ban":            ban.Target,
+			})
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":          "forbidden",
+				"message":        "Access denied",
+				"correlation_id": correlationID,
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/clientip"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+)
+
+// hopTraceKey is the context key of the middleware trace of a diagnostics
+// request
+type hopTraceKey struct{}
+
+// hopTrace records the middleware a request passed through, in order
+type hopTrace struct {
+	mu   sync.Mutex
+	hops []string
+}
+
+func (t *hopTrace) add(name string) {
+	t.mu.Lock()
+	t.hops = append(t.hops, name)
+	t.mu.Unlock()
+}
+
+func (t *hopTrace) list() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.hops...)
+}
+
+// hop wraps a middleware so that traced requests record passing through it
+func (s *Server) hop(name string, next http.Handler) http.Handler {
+	if !s.config.Observability.Diagnostics.Enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if trace, ok := r.Context().Value(hopTraceKey{}).(*hopTrace); ok {
+			trace.add(name)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// traceDiagnostics starts a middleware trace for requests to the
+// diagnostics endpoint that carry the debug token. It runs before every
+// other middleware.
+func (s *Server) traceDiagnostics(next http.Handler) http.Handler {
+	cfg := s.config.Observability.Diagnostics
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == cfg.Path && s.debugAuthorized(r) {
+			r = r.WithContext(context.WithValue(r.Context(), hopTraceKey{}, &hopTrace{}))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// debugAuthorized reports whether r carries the diagnostics debug token
+func (s *Server) debugAuthorized(r *http.Request) bool {
+	cfg := s.config.Observability.Diagnostics
+	presented := r.Header.Get(cfg.DebugHeader)
+	if presented == "" || cfg.DebugToken == (config.SecretRef{}) {
+		return false
+	}
+	token, err := cfg.DebugToken.Resolve()
+	if err != nil {
+		s.logger.Error("failed to resolve diagnostics debug token", logger.Fields{
+			"error": err.Error(),
+		})
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+		s.logger.Warn("invalid diagnostics debug token presented", logger.Fields{
+			"client_ip": clientip.FromRequest(r),
+		})
+		return false
+	}
+	return true
+}
+
+// diagnosticsHandler identifies the gateway instance and configuration
+// serving the request, and lists the middleware it passed through when the
+// debug token was presented
+func (s *Server) diagnosticsHandler() http.HandlerFunc {
+	cfg := s.config.Observability.Diagnostics
+	instance := cfg.InstanceID
+	if instance == "" {
+		instance, _ = os.Hostname()
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		configHash, err := s.config.Hash()
+		if err != nil {
+			s.logger.Error("failed to hash configuration", logger.Fields{
+				"error": err.Error(),
+			})
+		}
+		body := map[string]interface{}{
+			"instance_id":    instance,
+			"region":         cfg.Region,
+			"version":        s.version,
+			"config_hash":    configHash,
+			"time":           time.Now().UTC().Format(time.RFC3339Nano),
+			"correlation_id": logger.GetCorrelationID(r.Context()),
+		}
+		if trace, ok := r.Context().Value(hopTraceKey{}).(*hopTrace); ok {
+			body["middleware"] = trace.list()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(w).Encode(body)
+	}
+}
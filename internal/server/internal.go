@@ -0,0 +1,68 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/maltehedderich/api-gateway-go/internal/auth"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+)
+
+// buildInternalTLSConfig creates the TLS configuration of the internal
+// listener, which requires client certificates issued by the client CA
+func (s *Server) buildInternalTLSConfig() (*tls.Config, error) {
+	pool, err := loadClientCAs(s.config.Server.Internal.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := s.buildTLSConfig()
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	tlsConfig.ClientCAs = pool
+	return tlsConfig, nil
+}
+
+// loadClientCAs reads the CA certificates client certificates are verified
+// against
+func loadClientCAs(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in client CA file %s", caFile)
+	}
+	return pool, nil
+}
+
+// serviceIdentity stores the service identity of the verified client
+// certificate of each request on the internal listener
+func (s *Server) serviceIdentity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var service string
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+			service = auth.ServiceIdentityFromCert(r.TLS.VerifiedChains[0][0])
+		}
+		if service == "" {
+			correlationID := logger.GetCorrelationID(r.Context())
+			s.logger.Warn("internal request without service identity rejected", logger.Fields{
+				"correlation_id": correlationID,
+				"path":           r.URL.Path,
+			})
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":          "unauthorized",
+				"message":        "Client certificate with a service identity required",
+				"correlation_id": correlationID,
+			})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(auth.SetServiceIdentity(r.Context(), service)))
+	})
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/auth"
+	"github.com/maltehedderich/api-gateway-go/internal/clientip"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+)
+
+// Timestamps above this many unix seconds are taken as milliseconds
+const maxUnixSeconds = 1e11
+
+// clientAuthMethods are the auth methods of configured clients, whose IDs
+// are few enough to label clock skew metrics with
+var clientAuthMethods = map[string]bool{
+	"signature":   true,
+	"api_key":     true,
+	"client_cert": true,
+	"basic":       true,
+	"bypass":      true,
+}
+
+// hasRequestAgeRoutes reports whether any route checks request timestamps
+func hasRequestAgeRoutes(routes []config.RouteConfig) bool {
+	for _, route := range routes {
+		if route.RequestAge.Enabled() {
+			return true
+		}
+	}
+	return false
+}
+
+// requestAge rejects requests to routes with a request age limit whose
+// client timestamp is missing, unreadable or too far from the gateway's
+// clock. It runs after authorization so skew is recorded per client.
+func (s *Server) requestAge(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match, err := s.router.Match(r)
+		if err != nil || !match.Route.RequestAge.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		policy := match.Route.RequestAge
+		route := match.Route.PathPattern
+
+		header, value := "", ""
+		for _, name := range policy.TimestampHeaders() {
+			if value = r.Header.Get(name); value != "" {
+				header = name
+				break
+			}
+		}
+		if value == "" {
+			if policy.AllowMissing {
+				next.ServeHTTP(w, r)
+				return
+			}
+			s.rejectRequestAge(w, r, route, "missing", "missing_request_timestamp", "A request timestamp is required")
+			return
+		}
+
+		timestamp, ok := parseRequestTimestamp(value)
+		if !ok {
+			s.rejectRequestAge(w, r, route, "invalid", "invalid_request_timestamp", "Invalid request timestamp in "+header)
+			return
+		}
+
+		skew := time.Since(timestamp)
+		metrics.RecordRequestClockSkew(skewClient(r), skew)
+		if skew > policy.MaxSkew || skew < -policy.MaxSkew {
+			s.rejectRequestAge(w, r, route, "skew", "request_timestamp_out_of_range",
+				"Request timestamp is outside the allowed window of "+policy.MaxSkew.String())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// parseRequestTimestamp reads unix seconds or milliseconds, an RFC 3339
+// time, or an HTTP date
+func parseRequestTimestamp(value string) (time.Time, bool) {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if n > maxUnixSeconds {
+			return time.UnixMilli(n), true
+		}
+		return time.Unix(n, 0), true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// skewClient labels clock skew by configured client, or as user or
+// anonymous for everyone else
+func skewClient(r *http.Request) string {
+	user, ok := auth.GetUserContext(r.Context())
+	if !ok || user == nil {
+		return "anonymous"
+	}
+	if method, _ := user.Attributes["auth_method"].(string); clientAuthMethods[method] {
+		return user.UserID
+	}
+	return "user"
+}
+
+// rejectRequestAge answers a request whose client timestamp is not accepted
+func (s *Server) rejectRequestAge(w http.ResponseWriter, r *http.Request, route, reason, errorCode, message string) {
+	metrics.RecordRequestAgeRejection(route, reason)
+	s.logger.Warn("request rejected by request age check", logger.Fields{
+		"method":    r.Method,
+		"path":      r.URL.Path,
+		"route":     route,
+		"reason":    reason,
+		"client_ip": clientip.FromRequest(r),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":       errorCode,
+		"message":     message,
+		"server_time": time.Now().UTC().Format(time.RFC3339),
+	})
+}
@@ -4,19 +4,29 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 
 	"github.com/maltehedderich/api-gateway-go/internal/auth"
+	"github.com/maltehedderich/api-gateway-go/internal/circuitbreaker"
+	"github.com/maltehedderich/api-gateway-go/internal/clientip"
 	"github.com/maltehedderich/api-gateway-go/internal/config"
 	"github.com/maltehedderich/api-gateway-go/internal/health"
+	"github.com/maltehedderich/api-gateway-go/internal/lifecycle"
 	"github.com/maltehedderich/api-gateway-go/internal/logger"
 	"github.com/maltehedderich/api-gateway-go/internal/metrics"
 	"github.com/maltehedderich/api-gateway-go/internal/middleware"
+	"github.com/maltehedderich/api-gateway-go/internal/passthrough"
 	"github.com/maltehedderich/api-gateway-go/internal/proxy"
+	"github.com/maltehedderich/api-gateway-go/internal/proxyproto"
 	"github.com/maltehedderich/api-gateway-go/internal/ratelimit"
 	"github.com/maltehedderich/api-gateway-go/internal/router"
 	"github.com/maltehedderich/api-gateway-go/internal/tracing"
@@ -24,86 +34,263 @@ import (
 
 // Server represents the API Gateway server
 type Server struct {
-	config        *config.Config
-	httpServer    *http.Server
-	httpsServer   *http.Server
-	healthManager *health.Manager
-	router        *router.Router
-	proxy         *proxy.Proxy
-	rateLimiter   *ratelimit.Limiter
+	config         *config.Config
+	httpServer     *http.Server
+	httpsServer    *http.Server
+	internalServer *http.Server
+	adminServer    *http.Server
+	healthManager  *health.Manager
+	router         *router.Router
+	proxy          *proxy.Proxy
+	rateLimiter    *ratelimit.Limiter
 	authMiddleware *auth.Middleware
-	logger        *logger.ComponentLogger
+	edgeLogin      *auth.EdgeLogin
+	bans           *banList
+	clientIP       *clientip.Resolver
+	passthrough    *passthrough.Listener
+	lifecycle      *lifecycle.Manager
+	logger         *logger.ComponentLogger
+	version        string
 }
 
-// New creates a new server instance
-func New(cfg *config.Config, healthMgr *health.Manager) *Server {
-	log := logger.Get().WithComponent("server")
+// New creates a new server instance. Subsystems are initialized in
+// dependency order; if a required one fails, the subsystems already started
+// are stopped again and a *lifecycle.StartupError is returned.
+func New(cfg *config.Config, healthMgr *health.Manager) (*Server, error) {
+	s := &Server{
+		config:        cfg,
+		healthManager: healthMgr,
+		bans:          newBanList(),
+		lifecycle:     lifecycle.NewManager(),
+		logger:        logger.Get().WithComponent("server"),
+	}
 
-	// Create router
-	rtr := router.New()
+	// Tracing is initialized by the caller but flushed last on shutdown, so
+	// it is registered first
+	if cfg.Observability.TracingEnabled {
+		s.lifecycle.Register(lifecycle.Subsystem{
+			Name: "tracing",
+			Stop: tracing.Shutdown,
+		})
+	}
 
-	// Load routes from configuration
-	if err := rtr.LoadRoutes(cfg.Routes); err != nil {
-		log.Error("failed to load routes", logger.Fields{
-			"error": err.Error(),
+	s.lifecycle.Register(lifecycle.Subsystem{
+		Name:     "router",
+		Required: true,
+		Start: func(context.Context) error {
+			s.router = router.New()
+			return s.router.LoadRoutes(cfg.Routes)
+		},
+	})
+
+	s.lifecycle.Register(lifecycle.Subsystem{
+		Name:     "proxy",
+		Required: true,
+		Start: func(context.Context) error {
+			s.proxy = proxy.New(proxy.NewConfigFromConfig(cfg))
+			// Backend state of routes removed or changed by a reload is released
+			s.router.OnReload(s.proxy.ReleaseOrphanedState)
+			return s.proxy.CheckIdentityToken()
+		},
+	})
+
+	// Without the store breakers start closed, as they did before
+	if cfg.Proxy.CircuitBreakerStore.Type != "" {
+		var persister *circuitbreaker.Persister
+		s.lifecycle.Register(lifecycle.Subsystem{
+			Name:      "circuitbreaker_store",
+			DependsOn: []string{"proxy"},
+			Start: func(ctx context.Context) error {
+				store, err := circuitbreaker.NewStore(&cfg.Proxy.CircuitBreakerStore)
+				if err != nil {
+					return err
+				}
+				persister, err = s.proxy.CircuitBreakers().Persist(ctx, store, cfg.Proxy.CircuitBreakerStore.MaxAge)
+				if err != nil {
+					_ = store.Close()
+					return err
+				}
+				return nil
+			},
+			Stop: func(context.Context) error {
+				return persister.Close()
+			},
 		})
 	}
 
-	// Create proxy with default configuration
-	prx := proxy.New(nil)
+	// Client addresses are taken from forwarding headers of trusted proxies only
+	s.lifecycle.Register(lifecycle.Subsystem{
+		Name:     "clientip",
+		Required: true,
+		Start: func(context.Context) error {
+			trustedProxies, err := config.ParseNetworks(cfg.Server.TrustedProxies)
+			if err != nil {
+				return fmt.Errorf("invalid trusted proxies: %w", err)
+			}
+			s.clientIP = clientip.NewResolver(trustedProxies).WithHeader(cfg.Server.ForwardedHeader)
+			return nil
+		},
+	})
 
-	// Create rate limiter
-	var rateLimiter *ratelimit.Limiter
+	// The gateway keeps serving without rate limiting if the limiter
+	// backend is unavailable
 	if cfg.RateLimit.Enabled {
-		limiter, err := ratelimit.NewLimiter(&cfg.RateLimit)
-		if err != nil {
-			log.Error("failed to create rate limiter", logger.Fields{
-				"error": err.Error(),
-			})
-		} else {
-			rateLimiter = limiter
-			log.Info("rate limiter initialized", logger.Fields{
-				"backend": cfg.RateLimit.Backend,
-			})
+		s.lifecycle.Register(lifecycle.Subsystem{
+			Name: "ratelimit",
+			Start: func(context.Context) error {
+				limiter, err := ratelimit.NewLimiter(&cfg.RateLimit)
+				if err != nil {
+					return err
+				}
+				s.rateLimiter = limiter
+				// A limiter falling back to local limits keeps the gateway serving
+				if cfg.RateLimit.Degraded.Enabled {
+					healthMgr.RegisterAdvisory("ratelimit", health.RateLimiterChecker(limiter))
+				} else {
+					healthMgr.Register("ratelimit", health.RateLimiterChecker(limiter))
+				}
+				return nil
+			},
+			Stop: func(context.Context) error {
+				return s.rateLimiter.Close()
+			},
+		})
+	}
 
-			// Register rate limiter health check
-			if rateLimiter != nil {
-				healthMgr.Register("ratelimit", health.RateLimiterChecker(rateLimiter))
-			}
+	// Serving protected routes without authorization is never acceptable
+	if cfg.Authorization.Enabled {
+		s.lifecycle.Register(lifecycle.Subsystem{
+			Name:     "auth",
+			Required: true,
+			Start: func(context.Context) error {
+				middleware, err := auth.NewMiddleware(&cfg.Authorization)
+				if err != nil {
+					return err
+				}
+				middleware.SetExemptPaths(cfg.Observability.IsExemptPath)
+				middleware.SetServiceIdentities(cfg.Server.Internal.Services)
+				s.authMiddleware = middleware
+				if cfg.Authorization.EdgeLogin.Enabled {
+					edgeLogin, err := auth.NewEdgeLogin(&cfg.Authorization.EdgeLogin, cfg.Authorization.CookieName)
+					if err != nil {
+						return err
+					}
+					s.edgeLogin = edgeLogin
+				}
+				return nil
+			},
+			Stop: func(context.Context) error {
+				return s.authMiddleware.Close()
+			},
+		})
+	}
+
+	// Expiring certificates are reported, not fatal
+	if cfg.Observability.CertExpiry.Enabled {
+		var monitor *health.CertMonitor
+		s.lifecycle.Register(lifecycle.Subsystem{
+			Name: "certexpiry",
+			Start: func(context.Context) error {
+				expiry := cfg.Observability.CertExpiry
+				monitor = health.NewCertMonitor(certTargets(cfg), expiry.Interval, expiry.Threshold, expiry.Timeout)
+				monitor.Start()
+				healthMgr.RegisterAdvisory("tls_certificates", monitor.Checker())
+				return nil
+			},
+			Stop: func(context.Context) error {
+				monitor.Stop()
+				return nil
+			},
+		})
+	}
+
+	if err := s.lifecycle.Start(context.Background()); err != nil {
+		s.stopAfterFailedStart()
+		return nil, err
+	}
+	return s, nil
+}
+
+// certTargets lists the certificates whose expiry is monitored: the server
+// certificate, upstream client certificates and those of https backends
+func certTargets(cfg *config.Config) []health.CertTarget {
+	var targets []health.CertTarget
+	seen := make(map[string]bool)
+	add := func(target health.CertTarget) {
+		key := target.Kind + "|" + target.Name()
+		if !seen[key] {
+			seen[key] = true
+			targets = append(targets, target)
 		}
 	}
 
-	// Create auth middleware
-	var authMw *auth.Middleware
-	if cfg.Authorization.Enabled {
-		middleware, err := auth.NewMiddleware(&cfg.Authorization)
-		if err != nil {
-			log.Error("failed to create auth middleware", logger.Fields{
-				"error": err.Error(),
-			})
-		} else {
-			authMw = middleware
-			log.Info("authorization middleware initialized", logger.Fields{
-				"algorithm": cfg.Authorization.JWTSigningAlgorithm,
-			})
+	if cfg.Server.TLSEnabled {
+		add(health.CertTarget{Kind: health.CertKindServer, File: cfg.Server.TLSCertFile})
+	}
+	for _, route := range cfg.Routes {
+		if route.UpstreamTLS.CertFile != "" {
+			add(health.CertTarget{Kind: health.CertKindClient, File: route.UpstreamTLS.CertFile})
+		}
+
+		backend, err := url.Parse(route.BackendURL)
+		if err != nil || backend.Scheme != "https" {
+			continue
+		}
+		port := backend.Port()
+		if port == "" {
+			port = "443"
+		}
+		serverName := route.UpstreamTLS.ServerName
+		if serverName == "" {
+			serverName = backend.Hostname()
 		}
+		add(health.CertTarget{
+			Kind:       health.CertKindBackend,
+			Addr:       net.JoinHostPort(backend.Hostname(), port),
+			ServerName: serverName,
+		})
 	}
+	return targets
+}
 
-	return &Server{
-		config:        cfg,
-		healthManager: healthMgr,
-		router:        rtr,
-		proxy:         prx,
-		rateLimiter:   rateLimiter,
-		authMiddleware: authMw,
-		logger:        log,
+// StartupReport returns the outcome of every subsystem started so far
+func (s *Server) StartupReport() *lifecycle.Report {
+	return s.lifecycle.Report()
+}
+
+// routeFeatures are the route settings enforced by middleware that is only
+// added to the chain if a route used them at startup
+var routeFeatures = []struct {
+	name string
+	used func([]config.RouteConfig) bool
+}{
+	{"request_age", hasRequestAgeRoutes},
+	{"max_request_body_size and upload_mode", hasRouteBodyLimits},
+	{"transport_security", hasTransportSecurityRoutes},
+}
+
+// ReloadRoutes replaces the routes and releases the backend state, such as
+// circuit breakers and bulkheads, that only the old routes referred to.
+// Routes using a feature no route used at startup are refused, since its
+// middleware is missing until a restart.
+func (s *Server) ReloadRoutes(routes []config.RouteConfig) error {
+	for _, feature := range routeFeatures {
+		if feature.used(routes) && !feature.used(s.config.Routes) {
+			return fmt.Errorf("routes using %s need a restart, no route used it at startup", feature.name)
+		}
 	}
+	return s.router.Reload(routes)
 }
 
-// Start starts the server
+// SetVersion sets the gateway version reported by the admin API
+func (s *Server) SetVersion(version string) {
+	s.version = version
+}
+
+// Start starts the listeners and blocks until the server shuts down
 func (s *Server) Start() error {
 	// Create main router
-	router := s.setupRouter()
+	router := s.setupRouter(false)
 
 	// Setup HTTP server
 	s.httpServer = &http.Server{
@@ -118,6 +305,16 @@ func (s *Server) Start() error {
 	// Setup HTTPS server if TLS is enabled
 	if s.config.Server.TLSEnabled {
 		tlsConfig := s.buildTLSConfig()
+		if s.config.Authorization.Enabled && s.config.Authorization.ClientCert.Enabled {
+			// Certificates are optional on the listener; client-cert routes
+			// reject requests without one
+			pool, err := loadClientCAs(s.config.Authorization.ClientCert.CAFile)
+			if err != nil {
+				return fmt.Errorf("client cert authentication: %w", err)
+			}
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+			tlsConfig.ClientCAs = pool
+		}
 
 		s.httpsServer = &http.Server{
 			Addr:           fmt.Sprintf(":%d", s.config.Server.HTTPSPort),
@@ -130,32 +327,148 @@ func (s *Server) Start() error {
 		}
 	}
 
-	// Start servers in goroutines
-	errChan := make(chan error, 2)
-
-	// Start HTTP server
-	go func() {
-		s.logger.Info("starting HTTP server", logger.Fields{
-			"port": s.config.Server.HTTPPort,
-		})
-		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			errChan <- fmt.Errorf("HTTP server error: %w", err)
+	// Setup the east-west listener for service-to-service traffic
+	if s.config.Server.Internal.Enabled {
+		tlsConfig, err := s.buildInternalTLSConfig()
+		if err != nil {
+			return fmt.Errorf("internal listener: %w", err)
+		}
+		s.internalServer = &http.Server{
+			Addr:           fmt.Sprintf(":%d", s.config.Server.Internal.Port),
+			Handler:        s.setupRouter(true),
+			ReadTimeout:    s.config.Server.ReadTimeout,
+			WriteTimeout:   s.config.Server.WriteTimeout,
+			IdleTimeout:    s.config.Server.IdleTimeout,
+			MaxHeaderBytes: s.config.Server.MaxHeaderBytes,
+			TLSConfig:      tlsConfig,
 		}
-	}()
+	}
 
-	// Start HTTPS server if enabled
-	if s.config.Server.TLSEnabled {
-		go func() {
-			s.logger.Info("starting HTTPS server", logger.Fields{
-				"port": s.config.Server.HTTPSPort,
+	// Listeners report serve errors after startup here
+	errChan := make(chan error, 4)
+	dependencies := []string{"router", "proxy", "clientip"}
+
+	s.lifecycle.Register(lifecycle.Subsystem{
+		Name:      "http",
+		DependsOn: dependencies,
+		Required:  true,
+		Start: func(context.Context) error {
+			s.logger.Info("starting HTTP server", logger.Fields{
+				"port": s.config.Server.HTTPPort,
 			})
-			if err := s.httpsServer.ListenAndServeTLS(
-				s.config.Server.TLSCertFile,
-				s.config.Server.TLSKeyFile,
-			); err != nil && err != http.ErrServerClosed {
-				errChan <- fmt.Errorf("HTTPS server error: %w", err)
+			listener, err := s.listen("http", s.httpServer.Addr)
+			if err != nil {
+				return err
 			}
-		}()
+			go func() {
+				if err := s.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+					errChan <- fmt.Errorf("HTTP server error: %w", err)
+				}
+			}()
+			return nil
+		},
+		Stop: s.httpServer.Shutdown,
+	})
+
+	if s.config.Server.TLSEnabled {
+		s.lifecycle.Register(lifecycle.Subsystem{
+			Name:      "https",
+			DependsOn: dependencies,
+			Required:  true,
+			Start: func(context.Context) error {
+				s.logger.Info("starting HTTPS server", logger.Fields{
+					"port": s.config.Server.HTTPSPort,
+				})
+				listener, err := s.listen("https", s.httpsServer.Addr)
+				if err != nil {
+					return err
+				}
+				if len(s.config.PassthroughRoutes) > 0 {
+					s.passthrough = passthrough.NewListener(listener, s.config.PassthroughRoutes, s.config.Server.ReadTimeout)
+					listener = s.passthrough
+					s.logger.Info("tunneling TLS passthrough routes", logger.Fields{
+						"routes": len(s.config.PassthroughRoutes),
+					})
+				}
+				go func() {
+					if err := s.httpsServer.ServeTLS(
+						listener,
+						s.config.Server.TLSCertFile,
+						s.config.Server.TLSKeyFile,
+					); err != nil && err != http.ErrServerClosed {
+						errChan <- fmt.Errorf("HTTPS server error: %w", err)
+					}
+				}()
+				return nil
+			},
+			Stop: func(ctx context.Context) error {
+				err := s.httpsServer.Shutdown(ctx)
+				if s.passthrough != nil {
+					err = errors.Join(err, s.passthrough.Shutdown(ctx))
+				}
+				return err
+			},
+		})
+	}
+
+	if s.internalServer != nil {
+		s.lifecycle.Register(lifecycle.Subsystem{
+			Name:      "internal",
+			DependsOn: dependencies,
+			Required:  true,
+			Start: func(context.Context) error {
+				s.logger.Info("starting internal listener", logger.Fields{
+					"port":     s.config.Server.Internal.Port,
+					"services": len(s.config.Server.Internal.Services),
+				})
+				listener, err := net.Listen("tcp", s.internalServer.Addr)
+				if err != nil {
+					return err
+				}
+				certFile, keyFile := s.config.Server.Internal.CertFiles(s.config.Server)
+				go func() {
+					if err := s.internalServer.ServeTLS(listener, certFile, keyFile); err != nil && err != http.ErrServerClosed {
+						errChan <- fmt.Errorf("internal listener error: %w", err)
+					}
+				}()
+				return nil
+			},
+			Stop: s.internalServer.Shutdown,
+		})
+	}
+
+	// The gateway serves traffic without the admin API if it cannot bind
+	if s.config.Admin.Enabled {
+		s.adminServer = &http.Server{
+			Addr:              s.config.Admin.Address,
+			Handler:           s.adminHandler(),
+			ReadHeaderTimeout: s.config.Server.ReadTimeout,
+		}
+		s.lifecycle.Register(lifecycle.Subsystem{
+			Name:      "admin",
+			DependsOn: []string{"router", "proxy"},
+			Start: func(context.Context) error {
+				s.logger.Info("starting admin API", logger.Fields{
+					"address": s.config.Admin.Address,
+				})
+				listener, err := net.Listen("tcp", s.adminServer.Addr)
+				if err != nil {
+					return err
+				}
+				go func() {
+					if err := s.adminServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+						errChan <- fmt.Errorf("admin API error: %w", err)
+					}
+				}()
+				return nil
+			},
+			Stop: s.adminServer.Shutdown,
+		})
+	}
+
+	if err := s.lifecycle.Start(context.Background()); err != nil {
+		s.stopAfterFailedStart()
+		return err
 	}
 
 	// Setup graceful shutdown
@@ -166,8 +479,52 @@ func (s *Server) Start() error {
 	return err
 }
 
-// setupRouter sets up the HTTP router with middleware
-func (s *Server) setupRouter() http.Handler {
+// stopAfterFailedStart stops the subsystems that did start before a required
+// one failed
+func (s *Server) stopAfterFailedStart() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.Server.ShutdownTimeout)
+	defer cancel()
+	if err := s.lifecycle.Stop(ctx); err != nil {
+		s.logger.Error("cleanup after failed startup", logger.Fields{
+			"error": err.Error(),
+		})
+	}
+}
+
+// listen opens the listener for addr, accepting PROXY protocol headers if
+// configured for it
+func (s *Server) listen(name, addr string) (net.Listener, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := s.config.Server.ProxyProtocol
+	if !cfg.Enabled(name) {
+		return listener, nil
+	}
+
+	allowed, err := config.ParseNetworks(cfg.AllowedSources)
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	s.logger.Info("accepting PROXY protocol headers", logger.Fields{
+		"listener":        name,
+		"allowed_sources": cfg.AllowedSources,
+	})
+	return &proxyproto.Listener{
+		Listener:      listener,
+		Allowed:       allowed,
+		HeaderTimeout: cfg.HeaderTimeout,
+	}, nil
+}
+
+// setupRouter sets up the HTTP router with middleware. The internal profile
+// serves service-to-service traffic: it identifies callers by their client
+// certificate and skips the browser-oriented security headers, user agent
+// blocking and HTTPS redirect.
+func (s *Server) setupRouter(internal bool) http.Handler {
 	mux := http.NewServeMux()
 
 	// Health check endpoints
@@ -182,7 +539,17 @@ func (s *Server) setupRouter() http.Handler {
 	// Metrics endpoint
 	if s.config.Observability.MetricsEnabled {
 		metricsPath := s.config.Observability.MetricsPath
-		mux.Handle(metricsPath, metrics.Handler())
+		mux.Handle(metricsPath, metrics.Handler(s.config.Observability.MetricsOpenMetrics))
+	}
+
+	// Effective configuration endpoint
+	if s.config.Observability.ConfigEndpointEnabled {
+		mux.HandleFunc(s.config.Observability.ConfigPath, s.configHandler())
+	}
+
+	// Gateway self-identification endpoint
+	if s.config.Observability.Diagnostics.Enabled {
+		mux.HandleFunc(s.config.Observability.Diagnostics.Path, s.diagnosticsHandler())
 	}
 
 	// Default handler for all other routes
@@ -196,47 +563,216 @@ func (s *Server) setupRouter() http.Handler {
 	//        Security Headers -> RateLimit -> Auth -> Input Validation -> HTTPS Redirect -> Handler
 
 	// Security headers middleware (applied to all responses)
-	securityCfg := middleware.NewSecurityConfigFromConfig(s.config)
-	handler = middleware.Security(securityCfg)(handler)
+	if !internal {
+		securityCfg := middleware.NewSecurityConfigFromConfig(s.config)
+		handler = s.hop("security_headers", middleware.Security(securityCfg)(handler))
+	}
 
 	// Rate limiting middleware (before auth, after logging)
 	if s.rateLimiter != nil {
-		handler = ratelimit.Middleware(s.rateLimiter, s.config)(handler)
+		handler = s.hop("rate_limit", ratelimit.Middleware(s.rateLimiter, s.config)(handler))
+	}
+
+	// Request age checks (run once the client has been identified)
+	if hasRequestAgeRoutes(s.config.Routes) {
+		handler = s.hop("request_age", s.requestAge(handler))
+	}
+
+	// Test traffic claim detection (runs once the token has been validated)
+	if s.config.TestTraffic.Enabled && s.config.TestTraffic.Claim != "" && s.authMiddleware != nil {
+		handler = s.hop("test_traffic_claims", s.testTrafficClaims(handler))
 	}
 
 	// Authorization middleware (after logging, before rate limiting)
 	if s.authMiddleware != nil {
-		handler = s.authMiddleware.Handler(handler)
+		handler = s.hop("auth", s.authMiddleware.Handler(handler))
+	}
+
+	// Edge login turns browser sessions into tokens before authorization;
+	// services on the internal listener never log in interactively
+	if s.edgeLogin != nil && !internal {
+		handler = s.hop("edge_login", s.edgeLogin.Handler(handler))
+	}
+
+	// Input validation middleware; services are not blocked by user agent
+	validationCfg := s.config.Security
+	if internal {
+		validationCfg.BlockedUserAgents = nil
+	}
+	handler = s.hop("input_validation", middleware.InputValidation(&validationCfg)(handler))
+
+	// Compression middleware (decodes request bodies before input validation)
+	if s.config.Compression.Enabled {
+		handler = s.hop("compression", middleware.Compression(&s.config.Compression)(handler))
+	}
+
+	// Routes override the global body size limit; upload routes bypass it
+	// and request decoding
+	if hasRouteBodyLimits(s.config.Routes) {
+		handler = s.hop("route_body_limits", s.routeBodyLimits(handler))
 	}
 
-	// Input validation middleware
-	handler = middleware.InputValidation(&s.config.Security)(handler)
+	// Later middleware, such as authorization and rate limiting, apply the
+	// settings of the matched route
+	handler = s.hop("route_match", s.routeMatch(handler))
 
-	handler = middleware.Logging()(handler)
+	// Reject banned clients (bans are managed through the admin API)
+	handler = s.hop("bans", s.banMiddleware(handler))
+
+	handler = s.hop("logging", middleware.Logging()(handler))
 
 	// Metrics middleware (after logging, before tracing)
 	if s.config.Observability.MetricsEnabled {
-		handler = metrics.Middleware()(handler)
+		handler = s.hop("metrics", metrics.Middleware()(handler))
 	}
 
 	// Tracing middleware (after metrics, before correlation ID)
 	if s.config.Observability.TracingEnabled {
-		handler = tracing.Middleware()(handler)
+		handler = s.hop("tracing", tracing.Middleware()(handler))
+	}
+
+	// Test traffic detection (before metrics and logging so they can exclude it)
+	if s.config.TestTraffic.Enabled {
+		handler = s.hop("test_traffic", middleware.TestTraffic(&s.config.TestTraffic)(handler))
 	}
 
-	handler = middleware.CorrelationID()(handler)
+	// Identify the calling service before auth and rate limiting use it
+	if internal {
+		handler = s.hop("service_identity", s.serviceIdentity(handler))
+	}
+
+	// Resolve the client address before anything logs or keys on it
+	handler = s.hop("client_ip", s.clientIP.Middleware(handler))
+
+	// Resolve ambiguous query parameters and header names before routing
+	handler = s.hop("normalization", middleware.RequestNormalization(&s.config.Security.Normalization)(handler))
+
+	handler = s.hop("correlation_id", middleware.CorrelationID()(handler))
 
 	// Error handling middleware (replaces basic recovery)
-	handler = middleware.ErrorHandling(&s.config.Security)(handler)
+	handler = s.hop("error_handling", middleware.ErrorHandling(&s.config.Security)(handler))
 
 	// HTTPS redirect middleware (only on HTTP server if TLS enabled)
-	if s.config.Server.TLSEnabled && s.config.Security.EnableHTTPSRedirect {
-		handler = middleware.HTTPSRedirect()(handler)
+	if !internal && s.config.Server.TLSEnabled && s.config.Security.EnableHTTPSRedirect {
+		handler = s.hop("https_redirect", middleware.HTTPSRedirect(s.config.Observability.IsExemptPath)(handler))
+	}
+
+	// Routes requiring TLS refuse plaintext instead of being redirected
+	if hasTransportSecurityRoutes(s.config.Routes) {
+		handler = s.hop("transport_security", s.transportSecurity(handler))
+	}
+
+	// Diagnostics requests with the debug token record the middleware above
+	if s.config.Observability.Diagnostics.Enabled {
+		handler = s.traceDiagnostics(handler)
 	}
 
 	return handler
 }
 
+// hasRouteBodyLimits reports whether any route is configured in upload mode
+// or with a request body size limit of its own
+func hasRouteBodyLimits(routes []config.RouteConfig) bool {
+	for _, route := range routes {
+		if route.UploadMode || route.MaxRequestBodySize > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// routeMatch stores the route matching the request in its context
+func (s *Server) routeMatch(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if match, err := s.router.Match(r); err == nil {
+			r = r.WithContext(middleware.WithRouteMatch(r.Context(), match))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routeBodyLimits applies the request body size limit of the route stored by
+// routeMatch.
+// Requests for upload mode routes are exempt from request decoding and,
+// unless the route has a limit, from the body size limit, so large uploads
+// are streamed to the backend untouched.
+func (s *Server) routeBodyLimits(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match, ok := middleware.RouteMatchFromContext(r.Context()).(*router.Match)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		switch {
+		case match.Route.MaxRequestBodySize > 0:
+			ctx = middleware.WithBodyLimit(ctx, match.Route.MaxRequestBodySize)
+		case match.Route.UploadMode:
+			ctx = middleware.WithBodyLimitExempt(ctx)
+		}
+		if match.Route.UploadMode {
+			ctx = middleware.WithRawBody(ctx)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// testTrafficClaims marks requests whose token carries the configured test
+// traffic claim
+func (s *Server) testTrafficClaims(next http.Handler) http.Handler {
+	cfg := s.config.TestTraffic
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, ok := auth.GetUserContext(r.Context()); ok && user.Claims != nil {
+			if value, ok := user.Claims.Claim(cfg.Claim); ok && fmt.Sprint(value) == cfg.ClaimValue {
+				middleware.MarkTestTraffic(r.Context())
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sandboxMatch redirects test traffic to the route's sandbox backend, if any
+func sandboxMatch(r *http.Request, match *router.Match) *router.Match {
+	if match.Route.SandboxBackendURL == "" || !middleware.IsTestTraffic(r.Context()) {
+		return match
+	}
+
+	route := *match.Route
+	route.BackendURL = route.SandboxBackendURL
+	return &router.Match{Route: &route, Params: match.Params}
+}
+
+// configHandler serves the effective configuration with secrets redacted.
+// The format is selected with ?format=yaml|json (default json).
+func (s *Server) configHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "json"
+		}
+
+		data, err := s.config.Export(format)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":          "invalid_format",
+				"message":        err.Error(),
+				"correlation_id": logger.GetCorrelationID(r.Context()),
+			})
+			return
+		}
+
+		contentType := "application/json"
+		if format != "json" {
+			contentType = "application/yaml"
+		}
+		w.Header().Set("Content-Type", contentType)
+		_, _ = w.Write(data)
+	}
+}
+
 // defaultHandler returns the default handler for non-health routes
 func (s *Server) defaultHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -268,6 +804,8 @@ func (s *Server) defaultHandler() http.HandlerFunc {
 			return
 		}
 
+		match = sandboxMatch(r, match)
+
 		// Forward request to backend
 		if err := s.proxy.Forward(w, r, match); err != nil {
 			s.logger.Error("proxy forward error", logger.Fields{
@@ -282,17 +820,67 @@ func (s *Server) defaultHandler() http.HandlerFunc {
 
 			// Determine appropriate status code based on error
 			statusCode := http.StatusBadGateway
-			if err.Error() == "circuit breaker open for backend "+match.Route.BackendURL {
+			errorCode := "gateway_error"
+			message := "Failed to forward request to backend service"
+			var maxBytesErr *http.MaxBytesError
+			switch {
+			case err.Error() == "circuit breaker open for backend "+match.Route.BackendURL:
+				statusCode = http.StatusServiceUnavailable
+			case errors.Is(err, proxy.ErrBulkheadFull):
 				statusCode = http.StatusServiceUnavailable
+				errorCode = "backend_overloaded"
+				message = "Backend service is handling too many requests"
+				retryAfter := s.config.Proxy.BulkheadRetryAfter
+				var overload *proxy.OverloadError
+				if errors.As(err, &overload) {
+					retryAfter = overload.RetryAfter
+				}
+				w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(retryAfter.Seconds())))))
+			case errors.Is(err, proxy.ErrOwnershipDenied):
+				statusCode = http.StatusForbidden
+				errorCode = "forbidden"
+				message = "Access to this resource is not allowed"
+			case errors.Is(err, proxy.ErrOwnershipUnavailable):
+				statusCode = http.StatusServiceUnavailable
+				errorCode = "ownership_check_unavailable"
+				message = "Resource ownership could not be verified"
+			case errors.Is(err, proxy.ErrBackendTimeout):
+				statusCode = http.StatusGatewayTimeout
+				errorCode = "gateway_timeout"
+				message = "Backend service did not respond in time"
+			case errors.Is(err, proxy.ErrRequestBodyTooLarge), errors.As(err, &maxBytesErr):
+				statusCode = http.StatusRequestEntityTooLarge
+				errorCode = "payload_too_large"
+				message = "Request body exceeds maximum size"
+			case errors.Is(err, proxy.ErrInvalidRequestEncoding):
+				statusCode = http.StatusBadRequest
+				errorCode = "invalid_content_encoding"
+				message = "Request body could not be decoded"
+			case errors.Is(err, proxy.ErrSchemaValidation):
+				statusCode = http.StatusBadRequest
+				errorCode = "schema_validation_failed"
+				message = "Request body does not match the schema for this route"
+			case errors.Is(err, proxy.ErrInvalidRequestBody):
+				statusCode = http.StatusBadRequest
+				errorCode = "invalid_request_body"
+				message = "Request body is not valid JSON"
+			case errors.Is(err, proxy.ErrRangeNotSatisfiable):
+				statusCode = http.StatusRequestedRangeNotSatisfiable
+				errorCode = "range_not_satisfiable"
+				message = "Requested ranges exceed the limits for this route"
 			}
 
 			w.WriteHeader(statusCode)
 
 			errorResp := map[string]interface{}{
-				"error":          "gateway_error",
-				"message":        "Failed to forward request to backend service",
+				"error":          errorCode,
+				"message":        message,
 				"correlation_id": correlationID,
 			}
+			var schemaErr *proxy.SchemaError
+			if errors.As(err, &schemaErr) {
+				errorResp["details"] = schemaErr.Violations
+			}
 
 			_ = json.NewEncoder(w).Encode(errorResp)
 		}
@@ -313,44 +901,12 @@ func (s *Server) handleShutdown(errChan chan error) {
 	ctx, cancel := context.WithTimeout(context.Background(), s.config.Server.ShutdownTimeout)
 	defer cancel()
 
-	// Shutdown HTTP server
-	if s.httpServer != nil {
-		s.logger.Info("shutting down HTTP server")
-		if err := s.httpServer.Shutdown(ctx); err != nil {
-			s.logger.Error("HTTP server shutdown error", logger.Fields{
-				"error": err.Error(),
-			})
-		}
-	}
-
-	// Shutdown HTTPS server
-	if s.httpsServer != nil {
-		s.logger.Info("shutting down HTTPS server")
-		if err := s.httpsServer.Shutdown(ctx); err != nil {
-			s.logger.Error("HTTPS server shutdown error", logger.Fields{
-				"error": err.Error(),
-			})
-		}
-	}
-
-	// Cleanup rate limiter
-	if s.rateLimiter != nil {
-		s.logger.Info("closing rate limiter")
-		if err := s.rateLimiter.Close(); err != nil {
-			s.logger.Error("rate limiter close error", logger.Fields{
-				"error": err.Error(),
-			})
-		}
-	}
-
-	// Shutdown tracing
-	if s.config.Observability.TracingEnabled {
-		s.logger.Info("shutting down tracing")
-		if err := tracing.Shutdown(ctx); err != nil {
-			s.logger.Error("tracing shutdown error", logger.Fields{
-				"error": err.Error(),
-			})
-		}
+	// Subsystems stop in reverse start order: listeners first, so in-flight
+	// requests can still use the rate limiter, then the rest
+	if err := s.lifecycle.Stop(ctx); err != nil {
+		s.logger.Error("shutdown completed with errors", logger.Fields{
+			"error": err.Error(),
+		})
 	}
 
 	s.logger.Info("server shutdown complete")
@@ -361,34 +917,9 @@ func (s *Server) handleShutdown(errChan chan error) {
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.logger.Info("initiating server shutdown")
 
-	// Shutdown HTTP server
-	if s.httpServer != nil {
-		if err := s.httpServer.Shutdown(ctx); err != nil {
-			return fmt.Errorf("failed to shutdown HTTP server: %w", err)
-		}
-	}
-
-	// Shutdown HTTPS server
-	if s.httpsServer != nil {
-		if err := s.httpsServer.Shutdown(ctx); err != nil {
-			return fmt.Errorf("failed to shutdown HTTPS server: %w", err)
-		}
-	}
-
-	// Cleanup rate limiter
-	if s.rateLimiter != nil {
-		if err := s.rateLimiter.Close(); err != nil {
-			return fmt.Errorf("failed to close rate limiter: %w", err)
-		}
-	}
-
-	// Shutdown tracing
-	if s.config.Observability.TracingEnabled {
-		if err := tracing.Shutdown(ctx); err != nil {
-			return fmt.Errorf("failed to shutdown tracing: %w", err)
-		}
+	if err := s.lifecycle.Stop(ctx); err != nil {
+		return fmt.Errorf("failed to shutdown server: %w", err)
 	}
-
 	return nil
 }
 
@@ -441,16 +972,16 @@ func buildCipherSuites(suiteNames []string) []uint16 {
 
 	// Map of cipher suite names to their constants
 	suiteMap := map[string]uint16{
-		"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":       tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-		"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":       tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-		"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384":     tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-		"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256":     tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
-		"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305":        tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
-		"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305":      tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
-		"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA":          tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
-		"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA":          tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
-		"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA":        tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
-		"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA":        tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+		"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":   tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":   tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384": tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256": tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305":    tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305":  tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+		"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA":      tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+		"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA":      tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+		"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA":    tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+		"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA":    tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
 	}
 
 	suites := make([]uint16, 0, len(suiteNames))
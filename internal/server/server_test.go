@@ -0,0 +1,459 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maltehedderich/api-gateway-go/internal/clientip"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/health"
+	"github.com/maltehedderich/api-gateway-go/internal/lifecycle"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+)
+
+func TestNewStartupReport(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+
+	srv := newTestServer(t)
+	report := srv.StartupReport()
+	if report.Failed() {
+		t.Fatalf("unexpected failed startup:\n%s", report)
+	}
+
+	var names []string
+	for _, result := range report.Results {
+		names = append(names, result.Name)
+	}
+	expected := []string{"router", "proxy", "clientip"}
+	if len(names) != len(expected) {
+		t.Fatalf("expected subsystems %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("expected subsystems %v, got %v", expected, names)
+		}
+	}
+}
+
+func TestNewRequiredSubsystemFails(t *testing.T) {
+	logger.Init(logger.InfoLevel, "json", &bytes.Buffer{})
+
+	cfg := &config.Config{
+		Authorization: config.AuthorizationConfig{
+			Enabled:             true,
+			JWTSigningAlgorithm: "RS256",
+		},
+	}
+	srv, err := New(cfg, health.NewManager())
+	if srv != nil {
+		t.Error("expected no server")
+	}
+
+	var startupErr *lifecycle.StartupError
+	if !errors.As(err, &startupErr) {
+		t.Fatalf("expected startup error, got %v", err)
+	}
+	if startupErr.Subsystem != "auth" {
+		t.Errorf("expected auth to fail, got %s", startupErr.Subsystem)
+	}
+	if !startupErr.Report.Failed() {
+		t.Error("expected report to show the failure")
+	}
+}
+
+func TestCertTargets(t *testing.T) {
+	cfg := &config.Config{
+		Server: config.ServerConfig{TLSEnabled: true, TLSCertFile: "/etc/gateway/tls.crt"},
+		Routes: []config.RouteConfig{
+			{BackendURL: "https://users.internal"},
+			{BackendURL: "https://users.internal:443/v2"},
+			{BackendURL: "https://10.0.0.5:8443", UpstreamTLS: config.UpstreamTLSConfig{ServerName: "orders.internal", CertFile: "/etc/gateway/client.crt"}},
+			{BackendURL: "http://plain.internal"},
+		},
+	}
+
+	var names []string
+	for _, target := range certTargets(cfg) {
+		names = append(names, target.Kind+" "+target.Name())
+	}
+	expected := []string{
+		"server /etc/gateway/tls.crt",
+		"backend users.internal:443",
+		"client /etc/gateway/client.crt",
+		"backend 10.0.0.5:8443 (orders.internal)",
+	}
+	if strings.Join(names, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("Expected targets %v, got %v", expected, names)
+	}
+}
+
+func TestSetupRouterInternalProfile(t *testing.T) {
+	srv := newTestServer(t)
+	srv.config.Observability.HealthPath = "/health"
+	srv.config.Observability.ReadinessPath = "/ready"
+	srv.config.Observability.LivenessPath = "/live"
+	srv.config.Security.EnableHSTS = true
+	srv.config.Security.HSTSMaxAge = 31536000
+	srv.config.Security.BlockedUserAgents = []string{"curl"}
+
+	checkout := &x509.Certificate{Subject: pkix.Name{CommonName: "checkout"}}
+	tests := []struct {
+		name           string
+		internal       bool
+		userAgent      string
+		peer           *x509.Certificate
+		expectedStatus int
+		expectHSTS     bool
+	}{
+		{"edge sets security headers", false, "browser", nil, http.StatusOK, true},
+		{"edge blocks user agent", false, "curl/8.5.0", nil, http.StatusForbidden, false},
+		{"internal skips security headers", true, "browser", checkout, http.StatusOK, false},
+		{"internal allows user agent", true, "curl/8.5.0", checkout, http.StatusOK, false},
+		{"internal requires client certificate", true, "browser", nil, http.StatusUnauthorized, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/health", nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			if tt.peer != nil {
+				req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{tt.peer}}}
+			}
+
+			rr := httptest.NewRecorder()
+			srv.setupRouter(tt.internal).ServeHTTP(rr, req)
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if hsts := rr.Header().Get("Strict-Transport-Security") != ""; hsts != tt.expectHSTS {
+				t.Errorf("Expected HSTS %v, got %v", tt.expectHSTS, hsts)
+			}
+		})
+	}
+}
+
+func TestRequestSchemaErrorResponse(t *testing.T) {
+	srv := newTestServer(t)
+	routes := []config.RouteConfig{
+		{PathPattern: "/api/orders", Methods: []string{"POST"}, BackendURL: "http://orders:8080", AuthPolicy: "public",
+			RequestSchema: config.RequestSchemaConfig{Schema: map[string]interface{}{
+				"type":     "object",
+				"required": []interface{}{"sku"},
+				"properties": map[string]interface{}{
+					"qty": map[string]interface{}{"type": "integer", "minimum": 1},
+				},
+			}}},
+	}
+	if err := srv.router.LoadRoutes(routes); err != nil {
+		t.Fatalf("LoadRoutes() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader(`{"qty":0}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	srv.defaultHandler().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var body struct {
+		Error   string `json:"error"`
+		Details []struct {
+			Pointer string `json:"pointer"`
+			Message string `json:"message"`
+		} `json:"details"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid error response: %v", err)
+	}
+	if body.Error != "schema_validation_failed" || len(body.Details) != 2 {
+		t.Fatalf("unexpected error response: %s", rr.Body.String())
+	}
+	if body.Details[0].Pointer != "/sku" || body.Details[1].Pointer != "/qty" {
+		t.Errorf("expected violations at /sku and /qty, got %+v", body.Details)
+	}
+}
+
+func TestRequestBodyLimit(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	srv := newTestServer(t)
+	srv.config.Observability.HealthPath = "/health"
+	srv.config.Observability.ReadinessPath = "/ready"
+	srv.config.Observability.LivenessPath = "/live"
+	srv.config.Security.MaxRequestBodySize = 16
+	srv.config.Routes = []config.RouteConfig{
+		{PathPattern: "/api/notes", Methods: []string{"POST"}, BackendURL: backend.URL, AuthPolicy: "public", Timeout: 5 * time.Second},
+		{PathPattern: "/api/avatars", Methods: []string{"POST"}, BackendURL: backend.URL, AuthPolicy: "public",
+			UploadMode: true, MaxRequestBodySize: 64},
+		{PathPattern: "/api/videos", Methods: []string{"POST"}, BackendURL: backend.URL, AuthPolicy: "public",
+			UploadMode: true},
+	}
+	if err := srv.router.LoadRoutes(srv.config.Routes); err != nil {
+		t.Fatalf("LoadRoutes() error = %v", err)
+	}
+	handler := srv.setupRouter(false)
+
+	tests := []struct {
+		name           string
+		path           string
+		size           int
+		streamed       bool
+		expectedStatus int
+	}{
+		{"within global limit", "/api/notes", 16, false, http.StatusOK},
+		{"declared over global limit", "/api/notes", 17, false, http.StatusRequestEntityTooLarge},
+		{"streamed over global limit", "/api/notes", 17, true, http.StatusRequestEntityTooLarge},
+		{"within route limit", "/api/avatars", 64, true, http.StatusOK},
+		{"streamed over route limit", "/api/avatars", 65, true, http.StatusRequestEntityTooLarge},
+		{"upload route without limit", "/api/videos", 1024, true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(strings.Repeat("x", tt.size)))
+			if tt.streamed {
+				req.ContentLength = -1
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedStatus == http.StatusRequestEntityTooLarge && !strings.Contains(rr.Body.String(), `"payload_too_large"`) {
+				t.Errorf("Expected payload_too_large error, got %s", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestTransportSecurity(t *testing.T) {
+	srv := newTestServer(t)
+	routes := []config.RouteConfig{
+		{PathPattern: "/api/payments/**", Methods: []string{"POST"}, BackendURL: "http://payments:8080", AuthPolicy: "public",
+			TransportSecurity: config.TransportSecurityConfig{RequireTLS: true}},
+		{PathPattern: "/api/cards/**", Methods: []string{"POST"}, BackendURL: "http://cards:8080", AuthPolicy: "public",
+			TransportSecurity: config.TransportSecurityConfig{PlaintextStatus: http.StatusForbidden, MinTLSVersion: "1.3"}},
+		{PathPattern: "/api/users/{id}", Methods: []string{"POST"}, BackendURL: "http://users:8080", AuthPolicy: "public"},
+	}
+	if err := srv.router.LoadRoutes(routes); err != nil {
+		t.Fatalf("LoadRoutes() error = %v", err)
+	}
+	trusted, _ := config.ParseNetworks([]string{"10.0.0.0/8"})
+	srv.clientIP = clientip.NewResolver(trusted)
+
+	handler := srv.transportSecurity(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name           string
+		path           string
+		tlsVersion     uint16
+		remoteAddr     string
+		forwardedProto string
+		expectedStatus int
+	}{
+		{"plaintext refused with upgrade", "/api/payments/charge", 0, "", "", http.StatusUpgradeRequired},
+		{"tls allowed", "/api/payments/charge", tls.VersionTLS12, "", "", http.StatusOK},
+		{"tls terminated by trusted proxy", "/api/payments/charge", 0, "10.0.0.5:4711", "https", http.StatusOK},
+		{"forwarded proto from untrusted peer", "/api/payments/charge", 0, "203.0.113.7:4711", "https", http.StatusUpgradeRequired},
+		{"plaintext refused with configured status", "/api/cards/add", 0, "", "", http.StatusForbidden},
+		{"tls version below route minimum", "/api/cards/add", tls.VersionTLS12, "", "", http.StatusForbidden},
+		{"tls version at route minimum", "/api/cards/add", tls.VersionTLS13, "", "", http.StatusOK},
+		{"unrestricted route", "/api/users/1", 0, "", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			if tt.tlsVersion != 0 {
+				req.TLS = &tls.ConnectionState{Version: tt.tlsVersion}
+			}
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+			if tt.forwardedProto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.forwardedProto)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedStatus == http.StatusUpgradeRequired && rr.Header().Get("Upgrade") == "" {
+				t.Error("Expected Upgrade header on 426 response")
+			}
+		})
+	}
+}
+
+func TestDiagnosticsEndpoint(t *testing.T) {
+	srv := newTestServer(t)
+	srv.SetVersion("1.2.3")
+	srv.config.Observability.HealthPath = "/health"
+	srv.config.Observability.ReadinessPath = "/ready"
+	srv.config.Observability.LivenessPath = "/live"
+	srv.config.Observability.Diagnostics = config.DiagnosticsConfig{
+		Enabled:     true,
+		Path:        "/.well-known/gateway",
+		InstanceID:  "gw-eu-1",
+		Region:      "eu-central-1",
+		DebugToken:  config.SecretRef{Value: "debug-token-0123456789"},
+		DebugHeader: "X-Gateway-Debug-Token",
+	}
+	handler := srv.setupRouter(false)
+	expectedHash, err := srv.config.Hash()
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		debugToken string
+		expectHops bool
+	}{
+		{"identification only", "", false},
+		{"wrong debug token", "guessed-token", false},
+		{"middleware trace", "debug-token-0123456789", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/.well-known/gateway", nil)
+			if tt.debugToken != "" {
+				req.Header.Set("X-Gateway-Debug-Token", tt.debugToken)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+			}
+
+			var body struct {
+				InstanceID string   `json:"instance_id"`
+				Region     string   `json:"region"`
+				Version    string   `json:"version"`
+				ConfigHash string   `json:"config_hash"`
+				Time       string   `json:"time"`
+				Middleware []string `json:"middleware"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			if body.InstanceID != "gw-eu-1" || body.Region != "eu-central-1" || body.Version != "1.2.3" || body.ConfigHash != expectedHash {
+				t.Errorf("unexpected identification: %+v", body)
+			}
+			if _, err := time.Parse(time.RFC3339Nano, body.Time); err != nil {
+				t.Errorf("invalid time %q: %v", body.Time, err)
+			}
+			if !tt.expectHops {
+				if body.Middleware != nil {
+					t.Errorf("expected no middleware trace, got %v", body.Middleware)
+				}
+				return
+			}
+			if len(body.Middleware) == 0 || body.Middleware[0] != "error_handling" || body.Middleware[len(body.Middleware)-1] != "security_headers" {
+				t.Errorf("expected middleware from error_handling to security_headers, got %v", body.Middleware)
+			}
+		})
+	}
+}
+
+func TestRequestAge(t *testing.T) {
+	srv := newTestServer(t)
+	routes := []config.RouteConfig{
+		{PathPattern: "/api/payments/**", Methods: []string{"POST"}, BackendURL: "http://payments:8080", AuthPolicy: "public",
+			RequestAge: config.RequestAgeConfig{MaxSkew: 5 * time.Minute}},
+		{PathPattern: "/api/events/**", Methods: []string{"POST"}, BackendURL: "http://events:8080", AuthPolicy: "public",
+			RequestAge: config.RequestAgeConfig{MaxSkew: time.Minute, AllowMissing: true}},
+		{PathPattern: "/api/users/{id}", Methods: []string{"POST"}, BackendURL: "http://users:8080", AuthPolicy: "public"},
+	}
+	if err := srv.router.LoadRoutes(routes); err != nil {
+		t.Fatalf("LoadRoutes() error = %v", err)
+	}
+
+	handler := srv.requestAge(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	now := time.Now()
+	tests := []struct {
+		name           string
+		path           string
+		headers        map[string]string
+		expectedStatus int
+		expectedError  string
+	}{
+		{"unix seconds", "/api/payments/charge", map[string]string{"X-Request-Timestamp": strconv.FormatInt(now.Unix(), 10)}, http.StatusOK, ""},
+		{"unix milliseconds", "/api/payments/charge", map[string]string{"X-Request-Timestamp": strconv.FormatInt(now.UnixMilli(), 10)}, http.StatusOK, ""},
+		{"date header", "/api/payments/charge", map[string]string{"Date": now.UTC().Format(http.TimeFormat)}, http.StatusOK, ""},
+		{"stale", "/api/payments/charge", map[string]string{"X-Request-Timestamp": now.Add(-10 * time.Minute).Format(time.RFC3339)}, http.StatusBadRequest, "request_timestamp_out_of_range"},
+		{"from the future", "/api/payments/charge", map[string]string{"Date": now.Add(10 * time.Minute).UTC().Format(http.TimeFormat)}, http.StatusBadRequest, "request_timestamp_out_of_range"},
+		{"timestamp header wins over date", "/api/payments/charge", map[string]string{"X-Request-Timestamp": strconv.FormatInt(now.Unix(), 10), "Date": now.Add(-time.Hour).UTC().Format(http.TimeFormat)}, http.StatusOK, ""},
+		{"invalid", "/api/payments/charge", map[string]string{"X-Request-Timestamp": "yesterday"}, http.StatusBadRequest, "invalid_request_timestamp"},
+		{"missing", "/api/payments/charge", nil, http.StatusBadRequest, "missing_request_timestamp"},
+		{"missing allowed", "/api/events/track", nil, http.StatusOK, ""},
+		{"unrestricted route", "/api/users/1", map[string]string{"Date": now.Add(-time.Hour).UTC().Format(http.TimeFormat)}, http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedError != "" && !strings.Contains(rr.Body.String(), tt.expectedError) {
+				t.Errorf("Expected error %s, got %s", tt.expectedError, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestReloadRoutes(t *testing.T) {
+	s := newTestServer(t)
+
+	route := config.RouteConfig{PathPattern: "/api/orders/{id}", Methods: []string{"GET"}, BackendURL: "http://orders:8080", AuthPolicy: "public"}
+	if err := s.ReloadRoutes([]config.RouteConfig{route}); err != nil {
+		t.Fatalf("ReloadRoutes() error = %v", err)
+	}
+	if _, err := s.router.Match(httptest.NewRequest(http.MethodGet, "/api/orders/1", nil)); err != nil {
+		t.Errorf("expected reloaded route to match: %v", err)
+	}
+	if _, err := s.router.Match(httptest.NewRequest(http.MethodGet, "/api/users/1", nil)); err == nil {
+		t.Error("expected removed route not to match")
+	}
+
+	// The request age middleware was not installed at startup
+	route.RequestAge = config.RequestAgeConfig{MaxSkew: time.Minute}
+	err := s.ReloadRoutes([]config.RouteConfig{route})
+	if err == nil || !strings.Contains(err.Error(), "request_age") {
+		t.Fatalf("expected reload to be refused, got %v", err)
+	}
+	if _, err := s.router.Match(httptest.NewRequest(http.MethodGet, "/api/orders/1", nil)); err != nil {
+		t.Errorf("expected current routes to stay after a refused reload: %v", err)
+	}
+}
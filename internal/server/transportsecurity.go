@@ -0,0 +1,99 @@
+package server
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+
+	"github.com/maltehedderich/api-gateway-go/internal/clientip"
+	"github.com/maltehedderich/api-gateway-go/internal/config"
+	"github.com/maltehedderich/api-gateway-go/internal/logger"
+	"github.com/maltehedderich/api-gateway-go/internal/metrics"
+)
+
+// tlsVersions maps configured TLS versions to their protocol constants
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// hasTransportSecurityRoutes reports whether any route restricts its transport
+func hasTransportSecurityRoutes(routes []config.RouteConfig) bool {
+	for _, route := range routes {
+		if route.TransportSecurity.Enabled() {
+			return true
+		}
+	}
+	return false
+}
+
+// transportSecurity enforces the transport requirements of routes. It runs
+// before the HTTPS redirect so that plaintext requests to such routes are
+// refused rather than redirected.
+func (s *Server) transportSecurity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match, err := s.router.Match(r)
+		if err != nil || !match.Route.TransportSecurity.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		policy := match.Route.TransportSecurity
+
+		if r.TLS == nil {
+			if s.terminatedByTrustedProxy(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			status := policy.PlaintextStatus
+			if status == 0 {
+				status = http.StatusUpgradeRequired
+			}
+			if status == http.StatusUpgradeRequired {
+				w.Header().Set("Upgrade", "TLS/1.3, TLS/1.2, HTTP/1.1")
+				w.Header().Set("Connection", "Upgrade")
+			}
+			s.rejectTransport(w, r, match.Route.PathPattern, "plaintext", status, "This endpoint requires HTTPS")
+			return
+		}
+
+		if minVersion, ok := tlsVersions[policy.MinTLSVersion]; ok && r.TLS.Version < minVersion {
+			s.rejectTransport(w, r, match.Route.PathPattern, "tls_version", http.StatusForbidden,
+				"This endpoint requires TLS "+policy.MinTLSVersion+" or later")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// terminatedByTrustedProxy reports whether a trusted proxy received r over
+// TLS. X-Forwarded-Proto from other peers is ignored.
+func (s *Server) terminatedByTrustedProxy(r *http.Request) bool {
+	if r.Header.Get("X-Forwarded-Proto") != "https" {
+		return false
+	}
+	return s.clientIP.Trusted(clientip.ParseAddr(r.RemoteAddr))
+}
+
+// rejectTransport answers a request that violates its route's transport
+// requirements
+func (s *Server) rejectTransport(w http.ResponseWriter, r *http.Request, route, reason string, status int, message string) {
+	metrics.RecordTransportSecurityRejection(route, reason)
+	s.logger.Warn("request rejected by route transport security", logger.Fields{
+		"method":    r.Method,
+		"path":      r.URL.Path,
+		"route":     route,
+		"reason":    reason,
+		"client_ip": clientip.FromRequest(r),
+	})
+
+	errorCode := "forbidden"
+	if status == http.StatusUpgradeRequired {
+		errorCode = "upgrade_required"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":   errorCode,
+		"message": message,
+	})
+}
@@ -9,6 +9,8 @@ import (
 	"go.opentelemetry.io/otel/propagation"
 	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 	"go.opentelemetry.io/otel/trace"
+
+	"github.com/maltehedderich/api-gateway-go/internal/clientip"
 )
 
 // Middleware creates a tracing middleware that extracts and propagates trace context
@@ -93,18 +95,7 @@ func scheme(r *http.Request) string {
 
 // clientIP extracts the client IP from the request
 func clientIP(r *http.Request) string {
-	// Check X-Forwarded-For header first
-	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
-		return forwarded
-	}
-
-	// Check X-Real-IP header
-	if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
-		return realIP
-	}
-
-	// Fall back to RemoteAddr
-	return r.RemoteAddr
+	return clientip.FromRequest(r)
 }
 
 // statusRecorder wraps http.ResponseWriter to record status code
@@ -115,7 +106,9 @@ type statusRecorder struct {
 
 // WriteHeader captures the status code
 func (rec *statusRecorder) WriteHeader(code int) {
-	rec.statusCode = code
+	if code >= http.StatusOK {
+		rec.statusCode = code
+	}
 	rec.ResponseWriter.WriteHeader(code)
 }
 
@@ -104,10 +104,10 @@ func Init(cfg *Config) error {
 	)
 
 	log.Info("distributed tracing initialized", logger.Fields{
-		"endpoint":      cfg.Endpoint,
-		"service_name":  cfg.ServiceName,
-		"environment":   cfg.Environment,
-		"sample_rate":   cfg.SampleRate,
+		"endpoint":     cfg.Endpoint,
+		"service_name": cfg.ServiceName,
+		"environment":  cfg.Environment,
+		"sample_rate":  cfg.SampleRate,
 	})
 
 	return nil
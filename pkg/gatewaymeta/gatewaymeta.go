@@ -0,0 +1,126 @@
+// Package gatewaymeta reads the metadata the gateway attaches to every
+// backend request. The metadata describes how the gateway handled the
+// request (route, authenticated subject, policy result, remaining rate limit)
+// and is signed with a shared key, so backends can trust it and re-check
+// authorization decisions without calling the identity provider.
+//
+// The header value has the form "v1.<payload>.<signature>", where payload is
+// the base64url-encoded JSON of Metadata and signature the base64url-encoded
+// HMAC-SHA256 of "v1.<payload>".
+package gatewaymeta
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultHeader is the header the gateway sends metadata in by default
+const DefaultHeader = "X-Gateway-Metadata"
+
+// version prefixes every header value
+const version = "v1"
+
+var (
+	// ErrMissing is returned when a request carries no metadata
+	ErrMissing = errors.New("gateway metadata missing")
+	// ErrMalformed is returned for values that are not gateway metadata
+	ErrMalformed = errors.New("gateway metadata malformed")
+	// ErrInvalidSignature is returned when no key verifies the signature
+	ErrInvalidSignature = errors.New("gateway metadata signature invalid")
+	// ErrExpired is returned for metadata past its expiry
+	ErrExpired = errors.New("gateway metadata expired")
+)
+
+// Metadata describes how the gateway handled a request
+type Metadata struct {
+	// Route is the path pattern of the matched route
+	Route string `json:"route"`
+	// Subject is the authenticated user ID; empty for anonymous requests
+	Subject string `json:"sub,omitempty"`
+	// Policy is the auth policy that allowed the request, e.g. role-based
+	Policy string `json:"policy,omitempty"`
+	// RateLimitRemaining is the number of requests left in the current
+	// window; nil when no rate limit applied
+	RateLimitRemaining *int `json:"rl_remaining,omitempty"`
+	// Instance identifies the gateway instance that forwarded the request
+	Instance      string `json:"gw,omitempty"`
+	CorrelationID string `json:"cid,omitempty"`
+	// IssuedAt and ExpiresAt are Unix milliseconds
+	IssuedAt  int64 `json:"iat"`
+	ExpiresAt int64 `json:"exp"`
+}
+
+// Issued returns when the gateway forwarded the request
+func (m *Metadata) Issued() time.Time {
+	return time.UnixMilli(m.IssuedAt)
+}
+
+// Sign encodes and signs m with key
+func Sign(m Metadata, key []byte) (string, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	signed := version + "." + base64.RawURLEncoding.EncodeToString(data)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature(signed, key)), nil
+}
+
+// Parse verifies value with any of keys, which allows key rotation, and
+// returns the metadata unless it expired before now
+func Parse(value string, now time.Time, keys ...[]byte) (*Metadata, error) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 || parts[0] != version {
+		return nil, ErrMalformed
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	signed := parts[0] + "." + parts[1]
+	verified := false
+	for _, key := range keys {
+		if hmac.Equal(sig, signature(signed, key)) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrInvalidSignature
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	var m Metadata
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, ErrMalformed
+	}
+	if now.UnixMilli() > m.ExpiresAt {
+		return nil, ErrExpired
+	}
+	return &m, nil
+}
+
+// FromRequest parses the metadata in the DefaultHeader of r
+func FromRequest(r *http.Request, keys ...[]byte) (*Metadata, error) {
+	value := r.Header.Get(DefaultHeader)
+	if value == "" {
+		return nil, ErrMissing
+	}
+	return Parse(value, time.Now(), keys...)
+}
+
+// signature returns the HMAC-SHA256 of signed
+func signature(signed string, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,84 @@
+package gatewaymeta
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAndParse(t *testing.T) {
+	now := time.Now()
+	remaining := 42
+	meta := Metadata{
+		Route:              "/api/orders/:id",
+		Subject:            "user-1",
+		Policy:             "role-based",
+		RateLimitRemaining: &remaining,
+		Instance:           "gateway-0",
+		IssuedAt:           now.UnixMilli(),
+		ExpiresAt:          now.Add(30 * time.Second).UnixMilli(),
+	}
+	value, err := Sign(meta, []byte("current-key"))
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	tamperedPayload, _ := Sign(Metadata{Route: "/admin", ExpiresAt: meta.ExpiresAt}, []byte("current-key"))
+	parts := strings.Split(value, ".")
+	tampered := parts[0] + "." + strings.Split(tamperedPayload, ".")[1] + "." + parts[2]
+
+	tests := []struct {
+		name        string
+		value       string
+		now         time.Time
+		keys        [][]byte
+		expectedErr error
+	}{
+		{"valid", value, now, [][]byte{[]byte("current-key")}, nil},
+		{"rotated key", value, now, [][]byte{[]byte("next-key"), []byte("current-key")}, nil},
+		{"wrong key", value, now, [][]byte{[]byte("other-key")}, ErrInvalidSignature},
+		{"tampered payload", tampered, now, [][]byte{[]byte("current-key")}, ErrInvalidSignature},
+		{"expired", value, now.Add(time.Minute), [][]byte{[]byte("current-key")}, ErrExpired},
+		{"unknown version", "v2" + strings.TrimPrefix(value, "v1"), now, [][]byte{[]byte("current-key")}, ErrMalformed},
+		{"not metadata", "Bearer token", now, [][]byte{[]byte("current-key")}, ErrMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := Parse(tt.value, tt.now, tt.keys...)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Parse() error = %v, expected %v", err, tt.expectedErr)
+			}
+			if err != nil {
+				return
+			}
+			if parsed.Route != meta.Route || parsed.Subject != meta.Subject || parsed.Policy != meta.Policy {
+				t.Errorf("unexpected metadata: %+v", parsed)
+			}
+			if parsed.RateLimitRemaining == nil || *parsed.RateLimitRemaining != remaining {
+				t.Errorf("expected rate limit remaining %d, got %v", remaining, parsed.RateLimitRemaining)
+			}
+			if !parsed.Issued().Equal(time.UnixMilli(meta.IssuedAt)) {
+				t.Errorf("unexpected issue time %v", parsed.Issued())
+			}
+		})
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	if _, err := FromRequest(req, []byte("key")); !errors.Is(err, ErrMissing) {
+		t.Errorf("expected ErrMissing, got %v", err)
+	}
+
+	value, err := Sign(Metadata{Route: "/", ExpiresAt: time.Now().Add(time.Minute).UnixMilli()}, []byte("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(DefaultHeader, value)
+	if _, err := FromRequest(req, []byte("key")); err != nil {
+		t.Errorf("expected valid metadata, got %v", err)
+	}
+}